@@ -0,0 +1,137 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/config"
+	"fingerprint-converter/internal/errreport"
+	"fingerprint-converter/internal/handlers"
+	"fingerprint-converter/internal/openapi"
+	"fingerprint-converter/internal/storage"
+	"fingerprint-converter/internal/upgrade"
+)
+
+// getFileOperation documents GET /api/files/:id on every node role that serves it
+var getFileOperation = openapi.Operation{
+	Summary: "Download a processed file",
+	Tags:    []string{"process"},
+	Query:   []openapi.Parameter{{Name: "name", Description: "File name for Content-Disposition"}},
+	Responses: map[int]openapi.Response{
+		fiber.StatusOK:          {Description: "File contents", ContentType: "application/octet-stream"},
+		fiber.StatusNotModified: {Description: "Cached copy is current"},
+		fiber.StatusNotFound:    {Description: "File not found or expired", ContentType: "text/plain"},
+	},
+}
+
+// serveFiles runs a serve-only node: it answers GET /api/files for the
+// outputs processing nodes publish on the shared volume, and runs no
+// converters, downloads or jobs
+func serveFiles(cfg *config.Config) {
+	if !cfg.StorageShared {
+		log.Fatalf("❌ NODE_ROLE=files requires STORAGE_SHARED")
+	}
+	previousExited, err := upgrade.TakeOver(cfg.UpgradeTimeout)
+	if err != nil {
+		log.Fatalf("❌ Upgrade handoff failed: %v", err)
+	}
+	if previousExited != nil {
+		log.Println("🔄 Took over from previous process")
+	}
+
+	tempStorageDir := filepath.Join(cfg.CacheDir, "temp")
+	tempStorage := storage.NewTempStorage(tempStorageDir, 10*time.Minute)
+	for mediaType, dir := range cfg.TempDirs {
+		if dir == "" {
+			continue
+		}
+		if err := tempStorage.SetMediaDir(mediaType, dir); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+	}
+	host, _ := os.Hostname()
+	if err := tempStorage.SetShared(host+"-"+strconv.Itoa(os.Getpid()), cfg.StorageLeaderTTL, cfg.StorageJobDirMaxAge); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	// Removed files are purged from the CDN by whichever node removes them
+	fileCDN := newCDN(cfg, tempStorage)
+
+	app := newApp(cfg)
+	docs := openapi.New("Fingerprint Media Converter API", "1.0.0")
+	api := openapi.NewRouter(app.Group("/api"), "/api", docs)
+	fileHandler := handlers.NewFileHandler(tempStorage)
+	fileHandler.SetSharedCaching(fileCDN != nil)
+	api.Get("/files/:id", getFileOperation, fileHandler.GetFile)
+	api.Get("/openapi.json", openapi.Operation{
+		Summary: "This OpenAPI document",
+		Tags:    []string{"meta"},
+		Responses: map[int]openapi.Response{
+			fiber.StatusOK: {Description: "OpenAPI 3 document", Body: map[string]any{}},
+		},
+	}, docs.Handler)
+	if cfg.EnableHealthCheck {
+		api.Get("/health", openapi.Operation{
+			Summary: "Service health and temp storage status",
+			Tags:    []string{"meta"},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK: {Description: "Health report", Body: map[string]any{}},
+			},
+		}, fileHandler.Health)
+	}
+
+	ln := listen(cfg)
+
+	// Serving holds no state, so shutdown and upgrade only drain requests
+	stop := func() {
+		if err := app.Shutdown(); err != nil {
+			log.Printf("⚠️  Error during shutdown: %v", err)
+		}
+		tempStorage.Stop()
+		closeCDN(fileCDN)
+		errreport.Close(5 * time.Second)
+		log.Println("👋 Goodbye!")
+		os.Exit(0)
+	}
+	if cfg.GracefulUpgrade {
+		go func() {
+			upgrades := make(chan os.Signal, 1)
+			upgrade.Notify(upgrades)
+			for range upgrades {
+				log.Println("🔄 Upgrade requested, starting new process...")
+				if err := upgrade.Upgrade(ln, cfg.UpgradeTimeout, func() {}); err != nil {
+					log.Printf("⚠️  Upgrade failed, still serving: %v", err)
+					continue
+				}
+				log.Println("🔄 New process took over, draining in-flight requests...")
+				stop()
+			}
+		}()
+	}
+	go func() {
+		sigint := make(chan os.Signal, 1)
+		signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
+		<-sigint
+		log.Println("🛑 Shutting down gracefully...")
+		stop()
+	}()
+
+	if cfg.PIDFile != "" {
+		if err := os.WriteFile(cfg.PIDFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+			log.Printf("⚠️  Failed to write PID file: %v", err)
+		}
+	}
+
+	log.Printf("📂 Serve-only node: GET /api/files from %s on port %s", tempStorageDir, cfg.Port)
+	if err := app.Listener(ln); err != nil {
+		log.Fatalf("❌ Failed to start server: %v", err)
+	}
+	select {}
+}
@@ -1,24 +1,43 @@
 package main
 
 import (
+	"context"
 	"log"
+	"math"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/cors"
 	"github.com/gofiber/fiber/v3/middleware/logger"
+	"github.com/gofiber/fiber/v3/middleware/pprof"
 	"github.com/gofiber/fiber/v3/middleware/recover"
 
+	"fingerprint-converter/internal/cdn"
+	"fingerprint-converter/internal/chaos"
 	"fingerprint-converter/internal/config"
+	"fingerprint-converter/internal/errreport"
 	"fingerprint-converter/internal/handlers"
+	"fingerprint-converter/internal/jobs"
+	"fingerprint-converter/internal/logx"
+	"fingerprint-converter/internal/messaging"
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/objectstore"
+	"fingerprint-converter/internal/openapi"
 	"fingerprint-converter/internal/pool"
+	"fingerprint-converter/internal/redact"
 	"fingerprint-converter/internal/services"
 	"fingerprint-converter/internal/storage"
+	"fingerprint-converter/internal/tenant"
+	"fingerprint-converter/internal/upgrade"
 )
 
 func main() {
@@ -30,11 +49,60 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// Per-module verbosity; warnings and errors survive LOG_LEVEL=warn
+	if err := logx.Configure(cfg.LogLevel, cfg.LogLevels); err != nil {
+		log.Fatalf("❌ Invalid log level: %v", err)
+	}
+
+	// Failure injection is for test deployments only
+	if cfg.ChaosMode {
+		if cfg.ProductionMode {
+			log.Fatalf("❌ CHAOS_MODE cannot be used with PRODUCTION_MODE")
+		}
+		chaos.Enable(chaos.Config{
+			Percent: map[chaos.Fault]float64{
+				chaos.DownloadTimeout: cfg.ChaosDownloadTimeoutPct,
+				chaos.ToolFailure:     cfg.ChaosToolFailurePct,
+				chaos.DiskFull:        cfg.ChaosDiskFullPct,
+				chaos.SlowDisk:        cfg.ChaosSlowDiskPct,
+			},
+			SlowDiskDelay: cfg.ChaosSlowDiskDelay,
+		})
+		log.Printf("🐒 Chaos mode: download_timeout=%g%%, tool_failure=%g%%, disk_full=%g%%, slow_disk=%g%% (%v)",
+			cfg.ChaosDownloadTimeoutPct, cfg.ChaosToolFailurePct, cfg.ChaosDiskFullPct, cfg.ChaosSlowDiskPct, cfg.ChaosSlowDiskDelay)
+	}
+
+	// Hash URLs and file paths in logs before anything request-related is logged
+	if cfg.PIISafeLogs {
+		if cfg.LogHashSalt == "" {
+			log.Printf("⚠️  PII_SAFE_LOGS without LOG_HASH_SALT: hashes will change on restart")
+		}
+		redact.Enable(cfg.LogHashSalt)
+		log.Println("🔒 PII-safe logging enabled")
+	}
+
 	// Set runtime optimizations
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	log.Printf("⚙️  GOMAXPROCS=%d, GOGC=%d, GOMEMLIMIT=%s",
 		runtime.NumCPU(), cfg.GOGC, cfg.GoMemLimit)
 
+	// Report conversion failures to Sentry when a DSN is configured
+	if cfg.SentryDSN != "" {
+		if err := errreport.Enable(cfg.SentryDSN, cfg.SentryEnvironment, cfg.SentryRelease); err != nil {
+			log.Fatalf("❌ SENTRY_DSN: %v", err)
+		}
+		log.Printf("🐛 Error reporting enabled: environment=%s", cfg.SentryEnvironment)
+	}
+
+	// Serve-only nodes deliver the files other nodes store on the shared volume
+	if cfg.NodeRole == "files" {
+		serveFiles(cfg)
+		return
+	}
+
+	// Pick the ffmpeg/ffprobe binaries before anything runs them
+	configureToolchain(cfg)
+
 	// Initialize buffer pool
 	log.Printf("📦 Initializing buffer pool: count=%d, size=%d bytes",
 		cfg.BufferPoolSize, cfg.BufferSize)
@@ -48,86 +116,854 @@ func main() {
 	}
 
 	// Initialize downloader
-	downloader := services.NewDownloader(bufferPool, cfg.MaxDownloadSize, cfg.DownloadTimeout)
+	downloader := services.NewDownloader(bufferPool, cfg.MaxDownloadSize, cfg.DownloadTimeout, cfg.MaxConcurrentDownloads, cfg.DownloadHedgeDelay)
+	downloader.SetCoalescing(cfg.DownloadCoalesce)
+	if err := downloader.SetDialing(cfg.DownloadIPFamily, cfg.DownloadFallbackDelay); err != nil {
+		log.Fatalf("❌ DOWNLOAD_IP_FAMILY: %v", err)
+	}
+	if err := downloader.SetUserAgents(cfg.DownloadUserAgents); err != nil {
+		log.Fatalf("❌ Invalid DOWNLOAD_USER_AGENT: %v", err)
+	} else if len(cfg.DownloadUserAgents) > 1 {
+		log.Printf("🎭 Rotating %d download User-Agents", len(cfg.DownloadUserAgents))
+	}
+	downloadTLS := services.DownloadTLS{
+		CAFile:     cfg.DownloadCAFile,
+		ClientCert: cfg.DownloadClientCert,
+		ClientKey:  cfg.DownloadClientKey,
+	}
+	var tlsHosts map[string]services.DownloadTLS
+	if cfg.DownloadTLSHostsFile != "" {
+		hosts, err := services.LoadTLSHosts(cfg.DownloadTLSHostsFile)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		tlsHosts = hosts
+	}
+	if !downloadTLS.IsZero() || len(tlsHosts) > 0 {
+		if err := downloader.SetTLS(downloadTLS, tlsHosts); err != nil {
+			log.Fatalf("❌ Invalid download TLS settings: %v", err)
+		}
+		log.Printf("🔐 Download TLS: ca=%t, client_cert=%t, hosts=%d",
+			downloadTLS.CAFile != "", downloadTLS.ClientCert != "", len(tlsHosts))
+	}
+	if err := downloader.SetHTTP3(cfg.DownloadHTTP3); err != nil {
+		log.Printf("⚠️  HTTP/3 downloads disabled: %v", err)
+	} else if cfg.DownloadHTTP3 != services.HTTP3Off {
+		log.Printf("🚀 HTTP/3 downloads: mode=%s, falling back to TCP", cfg.DownloadHTTP3)
+	}
 
 	// Initialize converters
 	audioConverter := services.NewAudioConverter(workerPool, bufferPool)
-	imageConverter := services.NewImageConverter(workerPool, bufferPool)
+	imageConverter := services.NewImageConverter(workerPool, bufferPool, cfg.TIFFOutputFormat)
 	videoConverter := services.NewVideoConverter(workerPool, bufferPool)
+	documentConverter := services.NewDocumentConverter(workerPool, bufferPool, cfg.EmbedPDFNonce)
 
 	// Initialize temp storage (10 minutes TTL)
 	tempStorageDir := filepath.Join(cfg.CacheDir, "temp")
 	tempStorage := storage.NewTempStorage(tempStorageDir, 10*time.Minute)
 
+	// Route each media type's outputs and intermediates to its own volume
+	for mediaType, dir := range cfg.TempDirs {
+		if dir == "" {
+			continue
+		}
+		if err := tempStorage.SetMediaDir(mediaType, dir); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+	}
+
+	// Replicas on shared storage leave expiry to one cleanup leader
+	host, _ := os.Hostname()
+	if cfg.StorageShared {
+		if err := tempStorage.SetShared(host+"-"+strconv.Itoa(os.Getpid()), cfg.StorageLeaderTTL, cfg.StorageJobDirMaxAge); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+	}
+	audioConverter.SetTempDir(tempStorage.Dir("audio"))
+	imageConverter.SetTempDir(tempStorage.Dir("image"))
+	videoConverter.SetTempDir(tempStorage.Dir("video"))
+	documentConverter.SetTempDir(tempStorage.Dir("document"))
+	audioConverter.SetVerifyOutput(cfg.VerifyOutput)
+	imageConverter.SetVerifyOutput(cfg.VerifyOutput)
+	videoConverter.SetVerifyOutput(cfg.VerifyOutput)
+	audioConverter.SetRandomizeEncoderTags(cfg.RandomizeEncoderTags)
+	videoConverter.SetRandomizeEncoderTags(cfg.RandomizeEncoderTags)
+	audioConverter.SetCreationTimeWindow(cfg.CreationTimeWindow)
+	imageConverter.SetCreationTimeWindow(cfg.CreationTimeWindow)
+	videoConverter.SetCreationTimeWindow(cfg.CreationTimeWindow)
+
+	durationMode, err := services.ParseDurationCheckMode(cfg.DurationCheck)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	durationCheck := services.DurationCheck{Mode: durationMode, Tolerance: cfg.DurationTolerance, Percent: cfg.DurationTolerancePct}
+	audioConverter.SetDurationCheck(durationCheck)
+	videoConverter.SetDurationCheck(durationCheck)
+
 	// Get base URL for file serving
 	baseURL := os.Getenv("BASE_URL")
 	if baseURL == "" {
 		baseURL = "http://localhost:9090"
 	}
 
+	// Resolve default technique profile
+	defaultProfile, ok := services.LookupProfile(cfg.DefaultProfile)
+	if !ok {
+		log.Printf("⚠️  Unknown DEFAULT_PROFILE %q, using %q", cfg.DefaultProfile, services.DefaultProfileName)
+		defaultProfile, _ = services.LookupProfile(services.DefaultProfileName)
+	}
+
+	registry := services.NewRegistry(audioConverter, imageConverter, videoConverter, documentConverter)
+
+	// Warm up ffmpeg/qpdf and cache codec detection so first requests see steady latency
+	if cfg.WarmUpOnStart {
+		warmCtx, cancelWarm := context.WithTimeout(context.Background(), cfg.WarmUpTimeout)
+		services.WarmUp(warmCtx, registry)
+		cancelWarm()
+	}
+
+	// After a binary upgrade, load jobs only once the previous process has
+	// stopped dispatching, and adopt its stored files when it has exited
+	storageHandoff := filepath.Join(tempStorageDir, "handoff.json")
+	if cfg.StorageShared {
+		// Replicas on other hosts upgrade independently
+		storageHandoff = filepath.Join(tempStorageDir, "handoff-"+host+".json")
+	}
+	previousExited, err := upgrade.TakeOver(cfg.UpgradeTimeout)
+	if err != nil {
+		log.Fatalf("❌ Upgrade handoff failed: %v", err)
+	}
+	if previousExited != nil {
+		log.Println("🔄 Took over from previous process")
+		go func() {
+			<-previousExited
+			removeStaleJobDirs(tempStorage)
+			adopted, err := tempStorage.LoadIndex(storageHandoff)
+			if err != nil {
+				log.Printf("⚠️  Failed to adopt stored files: %v", err)
+				return
+			}
+			log.Printf("📦 Adopted %d stored files from previous process", adopted)
+		}()
+	} else {
+		removeStaleJobDirs(tempStorage)
+	}
+
+	// Per-API-key policies and quotas for processing requests
+	var tenants *tenant.Store
+	var quotas *tenant.Quotas
+	if cfg.TenantsFile != "" {
+		tenants = loadTenants(cfg.TenantsFile)
+		quotas = tenant.NewQuotas(tenants)
+	} else if cfg.RequireAPIKey {
+		log.Fatalf("❌ REQUIRE_API_KEY needs TENANTS_FILE")
+	}
+
 	// Initialize process handler
 	processHandler := handlers.NewProcessHandler(
-		audioConverter,
-		imageConverter,
-		videoConverter,
+		registry,
 		downloader,
 		tempStorage,
 		baseURL,
 		cfg.RequestTimeout,
+		services.ProcessOptions{
+			Profile:            defaultProfile,
+			SilenceThresholdDB: cfg.SilenceThresholdDB,
+			SilenceKeep:        cfg.SilenceKeep,
+			PreserveDuration:   cfg.PreserveDuration,
+		},
+		cfg.HeadProbe,
+		newMemoryGate(cfg),
+		newSpaceGuard(cfg),
+		quotas,
 	)
 
-	// Create Fiber app
-	app := fiber.New(fiber.Config{
-		ServerHeader:     "FingerprintConverter",
-		AppName:          "Fingerprint Media Converter API",
-		BodyLimit:        cfg.BodyLimit,
-		ReadTimeout:      cfg.ReadTimeout,
-		WriteTimeout:     cfg.WriteTimeout,
-		DisableKeepalive: false,
-		ErrorHandler: func(c fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			message := "Internal Server Error"
+	// Sampled diagnostics are only reachable through /admin/samples
+	var sampler *services.Sampler
+	if cfg.AdminToken != "" && (cfg.SampleSlowThreshold > 0 || cfg.SampleSizePercentile > 0) {
+		sampler = services.NewSampler(cfg.SampleSlowThreshold, cfg.SampleSizePercentile, cfg.SampleKeep)
+		processHandler.SetSampler(sampler)
+	}
+	var tenantMetadata *tenant.MetadataDefaults
+	if tenants != nil {
+		tenantMetadata, err = tenant.NewMetadataDefaults(cfg.TenantMetadataFile)
+		if err != nil {
+			log.Fatalf("❌ Failed to load tenant metadata: %v", err)
+		}
+		processHandler.SetTenantMetadata(tenantMetadata)
+	}
+	var escalation *services.EscalationPolicy
+	if cfg.Escalation {
+		escalation, err = services.NewEscalationPolicy(cfg.EscalationFile, cfg.EscalationMaxLevel)
+		if err != nil {
+			log.Fatalf("❌ Failed to load escalation policy: %v", err)
+		}
+		processHandler.SetEscalation(escalation)
+	}
+	var comparator *services.Comparator
+	if len(cfg.SimilarityEngines) > 0 {
+		similarityConfigs, err := services.ParseSimilarityConfig(cfg.SimilarityEngines)
+		if err != nil {
+			log.Fatalf("❌ SIMILARITY_ENGINES: %v", err)
+		}
+		var missing []services.SimilarityConfig
+		comparator, missing = services.NewComparator(similarityConfigs)
+		for _, m := range missing {
+			log.Printf("⚠️  Similarity engine %s disabled: required tool not installed", m.Engine)
+		}
+		processHandler.SetComparator(comparator)
+	}
+	if cfg.ArchiveMaxEntries > 0 {
+		// Entries stay in memory while they are processed, so by default an
+		// archive unpacks to no more than a single download may weigh
+		maxBytes := cfg.ArchiveMaxBytes
+		if maxBytes <= 0 {
+			maxBytes = cfg.MaxDownloadSize
+		}
+		processHandler.SetArchiveLimits(services.ArchiveLimits{
+			MaxEntries:    cfg.ArchiveMaxEntries,
+			MaxEntryBytes: cfg.MaxDownloadSize,
+			MaxTotalBytes: maxBytes,
+		})
+	}
+	if cfg.BatchMaxItems > 0 {
+		processHandler.SetBatchLimits(handlers.BatchLimits{MaxItems: cfg.BatchMaxItems, Concurrency: cfg.BatchConcurrency})
+	}
+	if budget := (services.CostBudget{MaxMegapixelFrames: cfg.CostMaxMegapixelFrames, MaxAudioSeconds: cfg.CostMaxAudioSeconds}); budget.Active() {
+		processHandler.SetCostBudget(budget)
+		log.Printf("💰 Cost budget: megapixel-frames=%.0f, audio=%.0fs (0 = unlimited)", budget.MaxMegapixelFrames, budget.MaxAudioSeconds)
+	}
+	if cfg.GPSSynthesis {
+		processHandler.SetGPSSynthesis(true)
+		log.Printf("📍 GPS synthesis enabled: gps_region requests embed coordinates in images")
+	}
 
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-				message = e.Message
-			}
+	// Source jobs and recurring sources read and write S3, so they need credentials
+	var objects *objectstore.S3
+	if cfg.S3AccessKeyID != "" {
+		objects, err = objectstore.NewS3(cfg.S3Endpoint, cfg.S3Region, cfg.S3AccessKeyID, cfg.S3SecretAccessKey, cfg.S3ForcePathStyle)
+		if err != nil {
+			log.Fatalf("❌ Invalid S3 configuration: %v", err)
+		}
+	}
 
-			return c.Status(code).JSON(fiber.Map{
-				"success":   false,
-				"error":     message,
-				"timestamp": time.Now().Unix(),
-			})
-		},
+	// Outputs requested with archive move to S3 when their TTL ends
+	var archival *handlers.Archival
+	if cfg.ArchiveDestination != "" {
+		if objects == nil {
+			log.Fatal("❌ ARCHIVE_DESTINATION requires S3 credentials")
+		}
+		archival, err = handlers.NewArchival(objects, cfg.ArchiveDestination, cfg.ArchiveLinkTTL, tempStorage)
+		if err != nil {
+			log.Fatalf("❌ Invalid ARCHIVE_DESTINATION: %v", err)
+		}
+		tempStorage.SetArchiver(archival.Upload)
+		processHandler.SetArchival(archival)
+		log.Printf("🗄️  Archival enabled: %s", cfg.ArchiveDestination)
+	}
+
+	// Outputs are handed out and cached on the CDN, and purged from it once removed
+	fileCDN := newCDN(cfg, tempStorage)
+	if fileCDN != nil {
+		processHandler.SetCDN(fileCDN)
+	}
+
+	// Chat platform webhooks, each enabled by its token; without its secret
+	// anyone could post a payload that makes the service fetch and process media
+	var platforms []messaging.Platform
+	if cfg.WhatsAppToken != "" {
+		if cfg.WhatsAppAppSecret == "" {
+			log.Fatal("❌ WHATSAPP_TOKEN requires WHATSAPP_APP_SECRET")
+		}
+		platforms = append(platforms, messaging.NewWhatsApp(cfg.WhatsAppGraphURL, cfg.WhatsAppToken, cfg.WhatsAppAppSecret, cfg.DownloadTimeout))
+	}
+	if cfg.TelegramBotToken != "" {
+		if cfg.TelegramSecretToken == "" {
+			log.Fatal("❌ TELEGRAM_BOT_TOKEN requires TELEGRAM_SECRET_TOKEN")
+		}
+		platforms = append(platforms, messaging.NewTelegram(cfg.TelegramAPIURL, cfg.TelegramBotToken, cfg.TelegramSecretToken, cfg.DownloadTimeout))
+	}
+	if len(platforms) > 0 {
+		processHandler.SetMessaging(platforms...)
+		for _, p := range platforms {
+			log.Printf("💬 Messaging webhooks enabled: POST /api/messaging/%s", p.Name())
+		}
+	}
+
+	// Scheduled jobs run through the same pipeline as /api/process
+	scheduler, err := jobs.NewScheduler(cfg.JobsFile, cfg.JobConcurrency, cfg.JobRetention)
+	if err != nil {
+		log.Fatalf("❌ Failed to load jobs: %v", err)
+	}
+	scheduler.SetRetry(jobs.RetryPolicy{
+		Attempts:            cfg.JobRetries,
+		Backoff:             cfg.JobRetryBackoff,
+		MaxBackoff:          cfg.JobRetryMaxBackoff,
+		Retryable:           handlers.TransientJobFailure,
+		DeadLetterRetention: cfg.JobDeadLetterRetention,
 	})
+	jobHandler := handlers.NewJobHandler(scheduler, processHandler, tenants, cfg.JobMaxDelay, cfg.JobMaxVariants)
+	if objects != nil {
+		jobHandler.SetObjectStore(objects, cfg.JobMaxObjects)
+	}
+	processHandler.SetAsync(jobHandler)
 
-	// Middleware
-	app.Use(recover.New())
+	// A dispatcher leases jobs to worker nodes instead of running them; a
+	// worker runs its own jobs and those it pulls from the dispatcher
+	var worker *handlers.Worker
+	switch cfg.NodeRole {
+	case "standalone":
+		scheduler.Start(jobHandler.Run)
+	case "dispatcher":
+		if cfg.WorkerToken == "" {
+			log.Fatalf("❌ NODE_ROLE=dispatcher requires WORKER_TOKEN")
+		}
+		scheduler.StartRemote(cfg.WorkerLease)
+		log.Printf("📡 Dispatcher: jobs are leased to worker nodes (lease=%v)", cfg.WorkerLease)
+	case "worker":
+		if cfg.DispatcherURL == "" || cfg.WorkerToken == "" {
+			log.Fatalf("❌ NODE_ROLE=worker requires DISPATCHER_URL and WORKER_TOKEN")
+		}
+		scheduler.Start(jobHandler.Run)
+		worker = handlers.NewWorker(jobHandler, cfg.DispatcherURL, cfg.WorkerToken, cfg.WorkerName, cfg.JobConcurrency, cfg.WorkerPollInterval)
+		log.Printf("🛠️  Worker %s: pulling jobs from %s (concurrency=%d)", cfg.WorkerName, cfg.DispatcherURL, cfg.JobConcurrency)
+	default:
+		log.Fatalf("❌ Unknown NODE_ROLE %q (standalone, dispatcher, worker or files)", cfg.NodeRole)
+	}
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	defer stopWorker()
+	if worker != nil {
+		go worker.Run(workerCtx)
+	}
 
-	if cfg.EnableCORS {
-		app.Use(cors.New(cors.Config{
-			AllowOrigins: []string{"*"},
-			AllowMethods: []string{"GET", "POST", "HEAD", "OPTIONS"},
-			AllowHeaders: []string{"Origin", "Content-Type", "Accept"},
-		}))
+	var recurring *jobs.Recurring
+	var recurringHandler *handlers.RecurringHandler
+	if objects != nil {
+		recurring, err = jobs.NewRecurring(cfg.RecurringFile)
+		if err != nil {
+			log.Fatalf("❌ Failed to load recurring sources: %v", err)
+		}
+		recurringHandler = handlers.NewRecurringHandler(recurring, processHandler, tenants, objects, cfg.RecurringMinInterval, cfg.RecurringMaxVariants)
+		recurring.Start(recurringHandler.Run)
+	} else {
+		log.Println("🔁 Recurring sources disabled (S3_ACCESS_KEY_ID not set)")
 	}
 
-	if cfg.EnablePerformanceLogs {
-		app.Use(logger.New(logger.Config{
-			Format: "[${time}] ${status} - ${latency} ${method} ${path}\n",
-		}))
+	app := newApp(cfg)
+
+	if tenants != nil {
+		tenantMiddleware := handlers.TenantMiddleware(tenants, cfg.RequireAPIKey)
+		app.Use("/api/process", tenantMiddleware)
+		app.Use("/api/messaging", tenantMiddleware)
+		app.Use("/api/jobs", tenantMiddleware)
+		app.Use("/api/recurring", tenantMiddleware)
+		log.Printf("🏢 Tenants loaded: %d (require key: %v)", len(tenants.All()), cfg.RequireAPIKey)
 	}
 
-	// Routes
-	api := app.Group("/api")
+	// Routes are registered through openapi.Router so /api/openapi.json
+	// always matches what is actually served
+	docs := openapi.New("Fingerprint Media Converter API", "1.0.0")
+	api := openapi.NewRouter(app.Group("/api"), "/api", docs)
 
 	// Processing endpoint
-	api.Post("/process", processHandler.Process)
-	api.Get("/files/:id", processHandler.GetFile)
+	api.Post("/process", openapi.Operation{
+		Summary:     "Download a file and apply fingerprint techniques",
+		Description: "Instead of the JSON arquivo URL, the file may be sent as multipart/form-data in a \"file\" field, with the other request fields as JSON in an optional \"options\" field. A zip or tar(.gz) of media files is processed entry by entry, returning entries or, with zip, a repackaged archive. With async: true the request is queued as a job and answered at once; poll GET /api/jobs/:id until its state is done or failed.",
+		Tags:        []string{"process"},
+		Request:     models.ProcessRequest{},
+		Headers:     []openapi.Parameter{{Name: handlers.APIKeyHeader, Description: "Selects the per-tenant policy when TENANTS_FILE is configured"}},
+		Responses: map[int]openapi.Response{
+			fiber.StatusOK:                    {Description: "Processed file URL, or the file itself (chunked for audio) with stream: true", Body: models.ProcessResponse{}},
+			fiber.StatusAccepted:              {Description: "Job queued (async); Location is its status URL", Body: models.JobView{}},
+			fiber.StatusBadRequest:            {Description: "Invalid request or download failure", Body: models.ProcessResponse{}},
+			fiber.StatusUnauthorized:          {Description: "Missing or unknown API key", Body: models.ProcessResponse{}},
+			fiber.StatusForbidden:             {Description: "Media type not allowed for this API key", Body: models.ProcessResponse{}},
+			fiber.StatusRequestEntityTooLarge: {Description: "Uploaded file over the size limit (code upload_too_large)", Body: models.ProcessResponse{}},
+			fiber.StatusTooManyRequests:       {Description: "Tenant quota exhausted; see quota.reset_at", Body: models.ProcessResponse{}},
+			fiber.StatusUnprocessableEntity:   {Description: "Input rejected by a rule, not decodable, over the cost budget (code cost_exceeded) or an archive over limits", Body: models.ProcessResponse{}},
+			fiber.StatusServiceUnavailable:    {Description: "Memory budget exhausted, retry later", Body: models.ProcessResponse{}},
+			fiber.StatusInsufficientStorage:   {Description: "Not enough free space in the temp volume", Body: models.ProcessResponse{}},
+		},
+	}, processHandler.Process)
+	api.Post("/process/batch", openapi.Operation{
+		Summary:     "Process several files in one request",
+		Description: "Each item is a URL or a /api/process request object (without async or stream). Items are converted BATCH_CONCURRENCY at a time; every item's response and HTTP status is reported in results, in request order.",
+		Tags:        []string{"process"},
+		Request:     models.BatchRequest{},
+		Headers:     []openapi.Parameter{{Name: handlers.APIKeyHeader, Description: "Selects the per-tenant policy when TENANTS_FILE is configured"}},
+		Responses: map[int]openapi.Response{
+			fiber.StatusOK:           {Description: "Per-item results; success is false when any item failed", Body: models.BatchResponse{}},
+			fiber.StatusBadRequest:   {Description: "Invalid body, no items or more than BATCH_MAX_ITEMS", Body: models.ProcessResponse{}},
+			fiber.StatusUnauthorized: {Description: "Missing or unknown API key", Body: models.ProcessResponse{}},
+		},
+	}, processHandler.ProcessBatch)
+	if len(platforms) > 0 {
+		api.Post("/messaging/:platform", openapi.Operation{
+			Summary:     "Process the media of a WhatsApp or Telegram webhook",
+			Description: "Send a raw WhatsApp Cloud API webhook payload to /api/messaging/whatsapp or a Telegram Bot API update to /api/messaging/telegram. The first media message is fetched with the platform token and processed like an upload; reply holds the platform send request re-uploading nova_url to the sender: the body of WhatsApp's POST /{phone_number_id}/messages, or Telegram method parameters that are also a valid webhook reply.",
+			Tags:        []string{"process"},
+			Headers: []openapi.Parameter{
+				{Name: handlers.APIKeyHeader, Description: "Selects the per-tenant policy when TENANTS_FILE is configured"},
+				{Name: "X-Hub-Signature-256", Description: "WhatsApp payload signature, checked against WHATSAPP_APP_SECRET"},
+				{Name: "X-Telegram-Bot-Api-Secret-Token", Description: "Telegram webhook secret, checked against TELEGRAM_SECRET_TOKEN"},
+			},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK:                    {Description: "Processed file URL and the re-upload request", Body: models.MessagingResponse{}},
+				fiber.StatusBadRequest:            {Description: "Invalid payload", Body: models.ProcessResponse{}},
+				fiber.StatusUnauthorized:          {Description: "Missing or invalid webhook signature, or API key", Body: models.ProcessResponse{}},
+				fiber.StatusNotFound:              {Description: "Platform not configured", Body: models.ProcessResponse{}},
+				fiber.StatusRequestEntityTooLarge: {Description: "Media over the size limit (code media_too_large)", Body: models.ProcessResponse{}},
+				fiber.StatusUnprocessableEntity:   {Description: "No media message in the payload (code no_media), or the media was rejected", Body: models.MessagingResponse{}},
+				fiber.StatusBadGateway:            {Description: "The platform API did not serve the media (code media_fetch_failed)", Body: models.ProcessResponse{}},
+			},
+		}, processHandler.ProcessMessaging)
+	}
+	api.Post("/jobs", openapi.Operation{
+		Summary:     "Schedule a processing request",
+		Description: "Runs the request asynchronously at process_at (immediately when omitted). Output files expire relative to when the job runs. With source (requires S3), every media object under the s3:// prefix is processed and uploaded under destination; progress reports how many are done.",
+		Tags:        []string{"jobs"},
+		Request:     models.JobRequest{},
+		Headers:     []openapi.Parameter{{Name: handlers.APIKeyHeader, Description: "Selects the per-tenant policy when TENANTS_FILE is configured"}},
+		Responses: map[int]openapi.Response{
+			fiber.StatusAccepted:   {Description: "Job scheduled; Location is its status URL", Body: models.JobView{}},
+			fiber.StatusBadRequest: {Description: "Invalid request", Body: models.ProcessResponse{}},
+		},
+	}, jobHandler.Submit)
+	api.Get("/jobs/:id", openapi.Operation{
+		Summary:     "Job status and result",
+		Description: "state is queued, processing, done or failed; phase tells where a processing job is (downloading, converting, storing) and nova_url is set once it is done.",
+		Tags:        []string{"jobs"},
+		Headers:     []openapi.Parameter{{Name: handlers.APIKeyHeader, Description: "Required for jobs submitted with an API key"}},
+		Responses: map[int]openapi.Response{
+			fiber.StatusOK:       {Description: "Job", Body: models.JobView{}},
+			fiber.StatusNotFound: {Description: "Job not found or expired", Body: models.ProcessResponse{}},
+		},
+	}, jobHandler.Get)
+	api.Get("/jobs/:id/manifest", openapi.Operation{
+		Summary:     "Files produced by a job",
+		Description: "One item per output (per page for rasterized documents, per object for source jobs) with URL, size, SHA-256 and the techniques applied. Items whose files expired are listed with status expired.",
+		Tags:        []string{"jobs"},
+		Headers:     []openapi.Parameter{{Name: handlers.APIKeyHeader, Description: "Required for jobs submitted with an API key"}},
+		Responses: map[int]openapi.Response{
+			fiber.StatusOK:       {Description: "Manifest", Body: models.JobManifest{}},
+			fiber.StatusNotFound: {Description: "Job not found or expired", Body: models.ProcessResponse{}},
+		},
+	}, jobHandler.Manifest)
+	api.Get("/jobs/:id/events", openapi.Operation{
+		Summary:     "State transitions of a job",
+		Description: "Every state the job entered (queued, running, downloading, converting, storing, retry_scheduled, done or failed) with its timestamp and how long the job stayed there. The current state of an unfinished job is measured until now.",
+		Tags:        []string{"jobs"},
+		Headers:     []openapi.Parameter{{Name: handlers.APIKeyHeader, Description: "Required for jobs submitted with an API key"}},
+		Responses: map[int]openapi.Response{
+			fiber.StatusOK:       {Description: "Timeline", Body: models.JobEventsResponse{}},
+			fiber.StatusNotFound: {Description: "Job not found or expired", Body: models.ProcessResponse{}},
+		},
+	}, jobHandler.Events)
+	if recurringHandler != nil {
+		api.Post("/recurring", openapi.Operation{
+			Summary:     "Register a source to re-process on a cadence",
+			Description: "Every run produces `variants` fresh outputs and uploads them to destination under <prefix>/<run timestamp>/. The first run starts immediately.",
+			Tags:        []string{"recurring"},
+			Request:     models.RecurringRequest{},
+			Headers:     []openapi.Parameter{{Name: handlers.APIKeyHeader, Description: "Selects the per-tenant policy when TENANTS_FILE is configured"}},
+			Responses: map[int]openapi.Response{
+				fiber.StatusCreated:    {Description: "Source registered", Body: models.RecurringSource{}},
+				fiber.StatusBadRequest: {Description: "Invalid request, cadence, variant count or destination", Body: models.ProcessResponse{}},
+			},
+		}, recurringHandler.Register)
+		api.Get("/recurring", openapi.Operation{
+			Summary: "Registered recurring sources",
+			Tags:    []string{"recurring"},
+			Headers: []openapi.Parameter{{Name: handlers.APIKeyHeader, Description: "Lists only this tenant's sources"}},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK: {Description: "Sources with their last run", Body: []models.RecurringSource{}},
+			},
+		}, recurringHandler.List)
+		api.Get("/recurring/:id", openapi.Operation{
+			Summary: "Recurring source and its last run",
+			Tags:    []string{"recurring"},
+			Headers: []openapi.Parameter{{Name: handlers.APIKeyHeader, Description: "Required for sources registered with an API key"}},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK:       {Description: "Source", Body: models.RecurringSource{}},
+				fiber.StatusNotFound: {Description: "Source not found", Body: models.ProcessResponse{}},
+			},
+		}, recurringHandler.Get)
+		api.Delete("/recurring/:id", openapi.Operation{
+			Summary: "Stop re-processing a source",
+			Tags:    []string{"recurring"},
+			Headers: []openapi.Parameter{{Name: handlers.APIKeyHeader, Description: "Required for sources registered with an API key"}},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK:       {Description: "Source removed", Body: models.ProcessResponse{}},
+				fiber.StatusNotFound: {Description: "Source not found", Body: models.ProcessResponse{}},
+			},
+		}, recurringHandler.Delete)
+	}
+	api.Get("/files/:id", getFileOperation, processHandler.GetFile)
+	if archival != nil {
+		api.Get("/archived/:id", openapi.Operation{
+			Summary:     "Download a file requested with archive",
+			Description: "Redirects to /api/files while the file is in temp storage, then to a short-lived link to its archived copy.",
+			Tags:        []string{"process"},
+			Responses: map[int]openapi.Response{
+				fiber.StatusFound:    {Description: "Redirect to the file"},
+				fiber.StatusNotFound: {Description: "Invalid file name", ContentType: "text/plain"},
+			},
+		}, archival.Get)
+	}
+	nonceHandler := handlers.NewNonceHandler(tempStorage)
+	api.Get("/files/:id/nonce", openapi.Operation{
+		Summary:     "Processing nonce embedded in a stored file",
+		Description: "Reads back the uid marker written into the file's metadata, mapping the file to the request that produced it.",
+		Tags:        []string{"identify"},
+		Responses: map[int]openapi.Response{
+			fiber.StatusOK:       {Description: "Nonce and processing time", Body: models.NonceResponse{}},
+			fiber.StatusNotFound: {Description: "File not found or expired, or no nonce embedded (code no_nonce)", Body: models.ProcessResponse{}},
+		},
+	}, nonceHandler.FileNonce)
+	api.Post("/identify", openapi.Operation{
+		Summary:     "Processing nonce embedded in an uploaded file",
+		Description: "Send the file as a multipart `file` field or as the raw request body. Works on any copy whose metadata survived, e.g. one found circulating.",
+		Tags:        []string{"identify"},
+		Responses: map[int]openapi.Response{
+			fiber.StatusOK:         {Description: "Nonce and processing time", Body: models.NonceResponse{}},
+			fiber.StatusBadRequest: {Description: "No file sent", Body: models.ProcessResponse{}},
+			fiber.StatusNotFound:   {Description: "No nonce embedded (code no_nonce)", Body: models.ProcessResponse{}},
+		},
+	}, nonceHandler.Identify)
+	if comparator != nil {
+		compareHandler := handlers.NewCompareHandler(comparator, tempStorage)
+		api.Post("/compare", openapi.Operation{
+			Summary:     "Compare two files with the similarity engines",
+			Description: "Send the files as multipart `original` and `variant` fields, plus `media_type` for audio, which is not recognized by content. Every engine enabled for their media type reports a similarity from 0 to 1 and a verdict: pass when it no longer matches the variant to the original, fail when it still does.",
+			Tags:        []string{"identify"},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK:                  {Description: "Per-engine results and the overall verdict", Body: models.CompareResponse{}},
+				fiber.StatusBadRequest:          {Description: "Missing file, unrecognized content (code unsupported_media) or different media types (code media_type_mismatch)", Body: models.ProcessResponse{}},
+				fiber.StatusUnprocessableEntity: {Description: "No engine enabled for the media type (code no_engine)", Body: models.ProcessResponse{}},
+			},
+		}, compareHandler.Compare)
+	}
+	var feedbackHandler *handlers.FeedbackHandler
+	if escalation != nil {
+		feedbackHandler = handlers.NewFeedbackHandler(escalation)
+		api.Post("/feedback", openapi.Operation{
+			Summary:     "Report a variant deduplicated downstream",
+			Description: "New variants of the same source are produced with stronger techniques, one level above the flagged variant's.",
+			Tags:        []string{"process"},
+			Headers:     []openapi.Parameter{{Name: handlers.APIKeyHeader, Description: "Required for files produced with an API key"}},
+			Request:     models.FeedbackRequest{},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK:         {Description: "Escalation state of the source", Body: models.FeedbackResponse{}},
+				fiber.StatusBadRequest: {Description: "No file_id", Body: models.ProcessResponse{}},
+				fiber.StatusNotFound:   {Description: "File unknown or produced too long ago (code unknown_file)", Body: models.ProcessResponse{}},
+			},
+		}, feedbackHandler.Submit)
+	}
+	api.Get("/capabilities", openapi.Operation{
+		Summary: "Supported formats, encoders, limits and profiles",
+		Tags:    []string{"meta"},
+		Responses: map[int]openapi.Response{
+			fiber.StatusOK: {Description: "Deployment capabilities", Body: models.CapabilitiesResponse{}},
+		},
+	}, processHandler.Capabilities)
+	api.Get("/openapi.json", openapi.Operation{
+		Summary: "This OpenAPI document",
+		Tags:    []string{"meta"},
+		Responses: map[int]openapi.Response{
+			fiber.StatusOK: {Description: "OpenAPI 3 document", Body: map[string]any{}},
+		},
+	}, docs.Handler)
 
 	// Health check
 	if cfg.EnableHealthCheck {
-		api.Get("/health", processHandler.Health)
+		api.Get("/health", openapi.Operation{
+			Summary: "Service health and toolchain status",
+			Tags:    []string{"meta"},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK: {Description: "Health report", Body: map[string]any{}},
+			},
+		}, processHandler.Health)
+	}
+
+	// Diagnostics (pprof, goroutine dump) only when an admin token is configured
+	if cfg.AdminToken != "" {
+		adminHandler := handlers.NewAdminHandler(cfg.AdminToken, bufferPool)
+		app.Use("/debug/pprof", adminHandler.RequireToken, pprof.New())
+
+		admin := openapi.NewRouter(app.Group("/admin", adminHandler.RequireToken), "/admin", docs)
+		admin.Get("/goroutines", openapi.Operation{
+			Summary:  "Full goroutine stack dump",
+			Tags:     []string{"admin"},
+			Security: true,
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK: {Description: "Stack dump", ContentType: "text/plain"},
+			},
+		}, adminHandler.Goroutines)
+		admin.Get("/runtime", openapi.Operation{
+			Summary:  "Memory, GC and pool counters",
+			Tags:     []string{"admin"},
+			Security: true,
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK: {Description: "Runtime counters", Body: map[string]any{}},
+			},
+		}, adminHandler.Runtime)
+		log.Printf("🔐 Admin diagnostics enabled: /debug/pprof, /admin/goroutines, /admin/runtime")
+
+		calibrationHandler := handlers.NewCalibrationHandler(registry, services.NewCalibrationStore(cfg.CalibrationFile),
+			cfg.MaxWorkers, cfg.CalibrationRuns, cfg.CalibrationTimeout)
+		admin.Post("/calibration", openapi.Operation{
+			Summary:     "Measure converter throughput on this host",
+			Description: "Converts a synthetic 720p image and 5s 720p clip (plus audio and PDF samples) runs times per pipeline and stores the result. Blocks until done.",
+			Tags:        []string{"admin"},
+			Security:    true,
+			Query: []openapi.Parameter{
+				{Name: "runs", Description: "Conversions timed per media type (default CALIBRATION_RUNS)"},
+			},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK:       {Description: "Calibration result", Body: services.Calibration{}},
+				fiber.StatusConflict: {Description: "A calibration is already running"},
+			},
+		}, calibrationHandler.Run)
+		admin.Get("/calibration", openapi.Operation{
+			Summary:  "Last calibration result",
+			Tags:     []string{"admin"},
+			Security: true,
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK:       {Description: "Calibration result", Body: services.Calibration{}},
+				fiber.StatusNotFound: {Description: "No calibration has been run"},
+			},
+		}, calibrationHandler.Latest)
+		log.Printf("🔐 Calibration enabled: /admin/calibration")
+
+		if sampler != nil {
+			sampleHandler := handlers.NewSampleHandler(sampler)
+			admin.Get("/samples", openapi.Operation{
+				Summary:     "Diagnostics of recent slow or large requests",
+				Description: "Per-phase timings, external tool arguments and sizes of requests slower than SAMPLE_SLOW_THRESHOLD or larger than the SAMPLE_SIZE_PERCENTILE of recent inputs, newest first.",
+				Tags:        []string{"admin"},
+				Security:    true,
+				Query: []openapi.Parameter{
+					{Name: "limit", Description: "Most samples returned (default all kept)"},
+				},
+				Responses: map[int]openapi.Response{
+					fiber.StatusOK: {Description: "Recent samples", Body: services.SampleList{}},
+				},
+			}, sampleHandler.List)
+			log.Printf("🔬 Request sampling enabled: /admin/samples (slow>=%v, size>=p%g)", cfg.SampleSlowThreshold, cfg.SampleSizePercentile)
+		}
+
+		statsHandler := handlers.NewStatsHandler(registry)
+		admin.Get("/stats/formats", openapi.Operation{
+			Summary:     "Conversion statistics per input format",
+			Description: "Conversions, failures by class, failure rate and p50/p95/p99 latency for every media type and input format seen since start, highest failure rate first.",
+			Tags:        []string{"admin"},
+			Security:    true,
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK: {Description: "Per-format statistics", Body: []services.FormatStatsRow{}},
+			},
+		}, statsHandler.Formats)
+		log.Printf("🔐 Format stats enabled: /admin/stats/formats")
+
+		storageHandler := handlers.NewStorageHandler(tempStorage)
+		admin.Get("/storage/files", openapi.Operation{
+			Summary:  "Files held in temp storage",
+			Tags:     []string{"admin"},
+			Security: true,
+			Query: []openapi.Parameter{
+				{Name: "tenant", Description: "Only files produced for this tenant"},
+				{Name: "media_type", Description: "audio, image, video, document or archive"},
+				{Name: "older_than", Description: "Minimum age, e.g. 5m"},
+			},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK:         {Description: "Stored files, newest first", Body: models.StorageListResponse{}},
+				fiber.StatusBadRequest: {Description: "Invalid older_than"},
+			},
+		}, storageHandler.List)
+		admin.Delete("/storage/files/:id", openapi.Operation{
+			Summary:  "Delete one stored file before its TTL",
+			Tags:     []string{"admin"},
+			Security: true,
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK:       {Description: "File deleted"},
+				fiber.StatusNotFound: {Description: "File not found"},
+			},
+		}, storageHandler.Delete)
+		admin.Post("/storage/purge", openapi.Operation{
+			Summary:     "Delete stored files by tenant, media type or age",
+			Description: "Filters are combined; all: true purges everything. dry_run reports what would be removed.",
+			Tags:        []string{"admin"},
+			Security:    true,
+			Request:     models.StoragePurgeRequest{},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK:         {Description: "Purge result", Body: models.StoragePurgeResponse{}},
+				fiber.StatusBadRequest: {Description: "No filter given or invalid older_than"},
+			},
+		}, storageHandler.Purge)
+		log.Printf("🔐 Storage admin enabled: /admin/storage")
+
+		admin.Get("/jobs/dead-letter", openapi.Operation{
+			Summary:     "Jobs that failed for good",
+			Description: "Failed jobs, most recent first, with every failed attempt in failures. Kept for JOB_DEAD_LETTER_RETENTION.",
+			Tags:        []string{"admin"},
+			Security:    true,
+			Query:       []openapi.Parameter{{Name: "tenant", Description: "Only jobs of this tenant"}},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK: {Description: "Dead-letter jobs", Body: models.DeadLetterResponse{}},
+			},
+		}, jobHandler.DeadLetters)
+		admin.Post("/jobs/:id/redrive", openapi.Operation{
+			Summary:     "Run a failed job again",
+			Description: "Schedules the job immediately with its retries restored; its failure history is kept.",
+			Tags:        []string{"admin"},
+			Security:    true,
+			Responses: map[int]openapi.Response{
+				fiber.StatusAccepted: {Description: "Job scheduled", Body: models.Job{}},
+				fiber.StatusNotFound: {Description: "No failed job with this ID"},
+			},
+		}, jobHandler.Redrive)
+
+		if quotas != nil {
+			quotaHandler := handlers.NewQuotaHandler(quotas)
+			admin.Get("/quotas", openapi.Operation{
+				Summary:  "Quota limits and usage of every tenant",
+				Tags:     []string{"admin"},
+				Security: true,
+				Responses: map[int]openapi.Response{
+					fiber.StatusOK: {Description: "Quota status per tenant", Body: []tenant.QuotaStatus{}},
+				},
+			}, quotaHandler.List)
+			admin.Get("/quotas/:tenant", openapi.Operation{
+				Summary:  "Quota limits and usage of one tenant",
+				Tags:     []string{"admin"},
+				Security: true,
+				Responses: map[int]openapi.Response{
+					fiber.StatusOK:       {Description: "Quota status", Body: tenant.QuotaStatus{}},
+					fiber.StatusNotFound: {Description: "Unknown tenant"},
+				},
+			}, quotaHandler.Get)
+			admin.Post("/quotas/:tenant", openapi.Operation{
+				Summary:  "Adjust a tenant's quotas until restart",
+				Tags:     []string{"admin"},
+				Security: true,
+				Request:  models.QuotaUpdateRequest{},
+				Responses: map[int]openapi.Response{
+					fiber.StatusOK:         {Description: "Updated quota status", Body: tenant.QuotaStatus{}},
+					fiber.StatusBadRequest: {Description: "Invalid limits"},
+					fiber.StatusNotFound:   {Description: "Unknown tenant"},
+				},
+			}, quotaHandler.Update)
+			log.Printf("🔐 Quota admin enabled: /admin/quotas")
+
+			tenantMetadataHandler := handlers.NewTenantMetadataHandler(tenants, tenantMetadata)
+			admin.Get("/tenants/metadata", openapi.Operation{
+				Summary:  "Default metadata of every tenant that has some",
+				Tags:     []string{"admin"},
+				Security: true,
+				Responses: map[int]openapi.Response{
+					fiber.StatusOK: {Description: "Defaults per tenant", Body: []models.TenantMetadata{}},
+				},
+			}, tenantMetadataHandler.List)
+			admin.Get("/tenants/:tenant/metadata", openapi.Operation{
+				Summary:  "Default metadata of one tenant",
+				Tags:     []string{"admin"},
+				Security: true,
+				Responses: map[int]openapi.Response{
+					fiber.StatusOK:       {Description: "Default fields", Body: models.TenantMetadata{}},
+					fiber.StatusNotFound: {Description: "Unknown tenant"},
+				},
+			}, tenantMetadataHandler.Get)
+			admin.Put("/tenants/:tenant/metadata", openapi.Operation{
+				Summary:     "Replace a tenant's default metadata",
+				Description: "The fields are merged into every output of the tenant; request metadata wins on the same key. Values may contain {variable} placeholders, and a field whose variables a request does not supply is left out of that output.",
+				Tags:        []string{"admin"},
+				Security:    true,
+				Request:     models.TenantMetadataRequest{},
+				Responses: map[int]openapi.Response{
+					fiber.StatusOK:         {Description: "Saved default fields", Body: models.TenantMetadata{}},
+					fiber.StatusBadRequest: {Description: "Invalid key or value"},
+					fiber.StatusNotFound:   {Description: "Unknown tenant"},
+				},
+			}, tenantMetadataHandler.Set)
+			admin.Delete("/tenants/:tenant/metadata", openapi.Operation{
+				Summary:  "Remove a tenant's default metadata",
+				Tags:     []string{"admin"},
+				Security: true,
+				Responses: map[int]openapi.Response{
+					fiber.StatusOK:       {Description: "Defaults removed"},
+					fiber.StatusNotFound: {Description: "Unknown tenant"},
+				},
+			}, tenantMetadataHandler.Delete)
+			log.Printf("🔐 Tenant metadata admin enabled: /admin/tenants/:tenant/metadata")
+		}
+
+		if feedbackHandler != nil {
+			admin.Get("/escalation", openapi.Operation{
+				Summary:  "Learned escalation level and flagged variants of every source",
+				Tags:     []string{"admin"},
+				Security: true,
+				Responses: map[int]openapi.Response{
+					fiber.StatusOK: {Description: "Sources, most recently flagged first", Body: []services.SourcePolicy{}},
+				},
+			}, feedbackHandler.List)
+			admin.Delete("/escalation/:source", openapi.Operation{
+				Summary:  "Forget a source's learned escalation level",
+				Tags:     []string{"admin"},
+				Security: true,
+				Responses: map[int]openapi.Response{
+					fiber.StatusOK:       {Description: "Source reset"},
+					fiber.StatusNotFound: {Description: "Unknown source"},
+				},
+			}, feedbackHandler.Reset)
+			log.Printf("🔐 Escalation admin enabled: /admin/escalation")
+		}
+	}
+
+	// Worker nodes lease jobs and upload their outputs here
+	if cfg.NodeRole == "dispatcher" {
+		dispatchHandler := handlers.NewDispatchHandler(scheduler, tempStorage, tenants, cfg.WorkerToken)
+		workerHeaders := []openapi.Parameter{{Name: handlers.WorkerTokenHeader, Required: true, Description: "WORKER_TOKEN"}}
+		internal := openapi.NewRouter(app.Group("/internal", dispatchHandler.RequireToken), "/internal", docs)
+		internal.Post("/jobs/lease", openapi.Operation{
+			Summary: "Lease the longest-due job to a worker",
+			Tags:    []string{"workers"},
+			Headers: workerHeaders,
+			Request: models.WorkLeaseRequest{},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK:        {Description: "Leased job", Body: models.WorkLease{}},
+				fiber.StatusNoContent: {Description: "No job is due"},
+			},
+		}, dispatchHandler.Lease)
+		internal.Post("/jobs/:id/heartbeat", openapi.Operation{
+			Summary: "Renew a lease and report progress",
+			Tags:    []string{"workers"},
+			Headers: workerHeaders,
+			Request: models.WorkHeartbeat{},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK:       {Description: "Lease renewed"},
+				fiber.StatusConflict: {Description: "Lease lost; abandon the job"},
+			},
+		}, dispatchHandler.Heartbeat)
+		internal.Put("/jobs/:id/files/:file", openapi.Operation{
+			Summary:     "Upload an output of a leased job",
+			Description: ":file is the worker's file ID and extension; the file is served from /api/files under the same ID.",
+			Tags:        []string{"workers"},
+			Headers:     append(workerHeaders, openapi.Parameter{Name: handlers.WorkerNameHeader, Required: true, Description: "Worker holding the lease"}),
+			Query:       []openapi.Parameter{{Name: "media_type", Required: true, Description: "audio, image, video, document or archive"}},
+			Responses: map[int]openapi.Response{
+				fiber.StatusCreated:  {Description: "File stored"},
+				fiber.StatusConflict: {Description: "Lease lost or file ID taken"},
+			},
+		}, dispatchHandler.UploadFile)
+		internal.Post("/jobs/:id/complete", openapi.Operation{
+			Summary: "Record the result of a leased job",
+			Tags:    []string{"workers"},
+			Headers: workerHeaders,
+			Request: models.WorkResult{},
+			Responses: map[int]openapi.Response{
+				fiber.StatusOK:       {Description: "Result recorded"},
+				fiber.StatusConflict: {Description: "Lease lost; the job runs again elsewhere"},
+			},
+		}, dispatchHandler.Complete)
 	}
 
 	// Root endpoint
@@ -138,12 +974,67 @@ func main() {
 			"status":  "running",
 			"endpoints": []string{
 				"POST /api/process",
+				"POST /api/process/batch",
+				"POST /api/messaging/:platform",
+				"POST /api/jobs",
+				"GET  /api/jobs/:id",
+				"GET  /api/jobs/:id/manifest",
+				"GET  /api/jobs/:id/events",
+				"POST /api/recurring",
+				"GET  /api/recurring",
+				"GET  /api/recurring/:id",
+				"DELETE /api/recurring/:id",
 				"GET  /api/files/:id",
+				"POST /api/feedback",
+				"POST /api/compare",
+				"GET  /api/capabilities",
+				"GET  /api/openapi.json",
 				"GET  /api/health",
 			},
 		})
 	})
 
+	// Inherited from the previous process after an upgrade
+	ln := listen(cfg)
+
+	// Zero-downtime upgrade: the new binary takes over the socket and jobs,
+	// this process finishes in-flight requests and hands over stored files
+	if cfg.GracefulUpgrade {
+		go func() {
+			upgrades := make(chan os.Signal, 1)
+			upgrade.Notify(upgrades)
+			for range upgrades {
+				log.Println("🔄 Upgrade requested, starting new process...")
+				err := upgrade.Upgrade(ln, cfg.UpgradeTimeout, func() {
+					stopWorker()
+					scheduler.Stop()
+					if recurring != nil {
+						recurring.Stop()
+					}
+					closeEscalation(escalation)
+				})
+				if err != nil {
+					log.Printf("⚠️  Upgrade failed, still serving: %v", err)
+					continue
+				}
+
+				log.Println("🔄 New process took over, draining in-flight requests...")
+				if err := app.Shutdown(); err != nil {
+					log.Printf("⚠️  Error during shutdown: %v", err)
+				}
+				workerPool.Stop()
+				tempStorage.Stop()
+				if err := tempStorage.SaveIndex(storageHandoff); err != nil {
+					log.Printf("⚠️  Failed to hand over stored files: %v", err)
+				}
+				closeCDN(fileCDN)
+				errreport.Close(5 * time.Second)
+				log.Println("👋 Goodbye!")
+				os.Exit(0)
+			}
+		}()
+	}
+
 	// Graceful shutdown
 	go func() {
 		sigint := make(chan os.Signal, 1)
@@ -152,6 +1043,14 @@ func main() {
 
 		log.Println("🛑 Shutting down gracefully...")
 
+		// Stop dispatching jobs; interrupted jobs resume on restart, leased
+		// ones on another worker once their lease expires
+		stopWorker()
+		scheduler.Stop()
+		if recurring != nil {
+			recurring.Stop()
+		}
+
 		// Stop worker pool
 		workerPool.Stop()
 
@@ -163,17 +1062,235 @@ func main() {
 			log.Printf("⚠️  Error during shutdown: %v", err)
 		}
 
+		// Persist deliveries recorded since the last feedback
+		closeEscalation(escalation)
+
+		// Send purges of files removed before shutdown
+		closeCDN(fileCDN)
+
+		// Flush queued error reports
+		errreport.Close(5 * time.Second)
+
 		log.Println("👋 Goodbye!")
 		os.Exit(0)
 	}()
 
+	if cfg.PIDFile != "" {
+		if err := os.WriteFile(cfg.PIDFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+			log.Printf("⚠️  Failed to write PID file: %v", err)
+		}
+	}
+
 	// Start server
 	log.Printf("🌐 Server starting on port %s", cfg.Port)
 	log.Printf("🎯 Environment: %s", cfg.AppEnv)
 	log.Printf("📊 Anti-Fingerprint Default Level: %s", cfg.DefaultAFLevel)
 	log.Println("✅ Ready to process media!")
 
-	if err := app.Listen(":" + cfg.Port); err != nil {
+	if err := app.Listener(ln); err != nil {
 		log.Fatalf("❌ Failed to start server: %v", err)
 	}
+
+	// Listener returns as soon as shutdown begins; the shutdown goroutine
+	// exits the process once in-flight requests have drained
+	select {}
+}
+
+// newApp creates the Fiber app with the middleware every node role uses
+func newApp(cfg *config.Config) *fiber.App {
+	app := fiber.New(fiber.Config{
+		ServerHeader:     "FingerprintConverter",
+		AppName:          "Fingerprint Media Converter API",
+		BodyLimit:        cfg.BodyLimit,
+		ReadTimeout:      cfg.ReadTimeout,
+		WriteTimeout:     cfg.WriteTimeout,
+		DisableKeepalive: false,
+		ErrorHandler: func(c fiber.Ctx, err error) error {
+			code := fiber.StatusInternalServerError
+			message := "Internal Server Error"
+
+			if e, ok := err.(*fiber.Error); ok {
+				code = e.Code
+				message = e.Message
+			}
+
+			return c.Status(code).JSON(fiber.Map{
+				"success":   false,
+				"error":     message,
+				"timestamp": time.Now().Unix(),
+			})
+		},
+	})
+
+	// Middleware
+	app.Use(recover.New())
+	app.Use(handlers.RequestIDMiddleware)
+
+	if cfg.EnableCORS {
+		app.Use(cors.New(cors.Config{
+			AllowOrigins:  []string{"*"},
+			AllowMethods:  []string{"GET", "POST", "DELETE", "HEAD", "OPTIONS"},
+			AllowHeaders:  []string{"Origin", "Content-Type", "Accept", handlers.APIKeyHeader, handlers.RequestIDHeader, "traceparent"},
+			ExposeHeaders: []string{handlers.RequestIDHeader},
+		}))
+	}
+
+	if cfg.EnableCompression {
+		app.Use(handlers.CompressText())
+	}
+
+	if cfg.EnablePerformanceLogs && logx.For(logx.HTTP).Enabled(logx.Info) {
+		app.Use(logger.New(logger.Config{
+			Format: "[${time}] ${status} - ${latency} ${method} ${path} [${respHeader:X-Request-ID}]\n",
+		}))
+	}
+	return app
+}
+
+// listen opens the server socket, or takes it over from the previous
+// process after an upgrade. Without LISTEN_ADDRESS it accepts IPv4 and IPv6
+func listen(cfg *config.Config) net.Listener {
+	host := strings.TrimSuffix(strings.TrimPrefix(cfg.ListenAddress, "["), "]")
+	addr := net.JoinHostPort(host, cfg.Port)
+	ln, err := upgrade.Listen("tcp", addr, upgrade.ListenOptions{
+		ReusePort: cfg.ListenReusePort,
+		Interface: cfg.ListenInterface,
+	})
+	if err != nil {
+		log.Fatalf("❌ Failed to listen on %s: %v", addr, err)
+	}
+	log.Printf("🔌 Listening on %s", ln.Addr())
+	return ln
+}
+
+// loadTenants reads the tenant store and checks every default profile exists
+func loadTenants(path string) *tenant.Store {
+	tenants, err := tenant.Load(path)
+	if err != nil {
+		log.Fatalf("❌ Failed to load tenants: %v", err)
+	}
+	for _, t := range tenants.All() {
+		if _, ok := services.LookupProfile(t.DefaultProfile); t.DefaultProfile != "" && !ok {
+			log.Fatalf("❌ Tenant %s: unknown default_profile %q", t.Name, t.DefaultProfile)
+		}
+	}
+	return tenants
+}
+
+// configureToolchain points the converters at the configured or bundled
+// ffmpeg/ffprobe and checks their version and encoders
+func configureToolchain(cfg *config.Config) {
+	paths := map[string]string{"ffmpeg": cfg.FFmpegPath, "ffprobe": cfg.FFprobePath}
+	if cfg.FFmpegBundleURL != "" && (paths["ffmpeg"] == "" || paths["ffprobe"] == "") {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.FFmpegBundleTimeout)
+		bundled, err := services.InstallFFmpegBundle(ctx, services.FFmpegBundle{
+			URL:    cfg.FFmpegBundleURL,
+			SHA256: cfg.FFmpegBundleSHA256,
+		}, filepath.Join(cfg.CacheDir, "toolchain"))
+		cancel()
+		if err != nil {
+			log.Fatalf("❌ FFMPEG_BUNDLE_URL: %v", err)
+		}
+		for tool, path := range bundled {
+			if paths[tool] == "" {
+				paths[tool] = path
+			}
+		}
+		log.Printf("📦 ffmpeg bundle installed in %s", filepath.Dir(bundled["ffmpeg"]))
+	}
+	for tool, path := range paths {
+		services.SetToolPath(tool, path)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	version, err := services.VerifyToolchain(ctx, services.ToolchainRequirements{
+		MinFFmpegVersion: cfg.FFmpegMinVersion,
+		Encoders:         cfg.FFmpegRequiredEncoders,
+	})
+	switch {
+	case err == nil:
+		log.Printf("🔧 ffmpeg %s (%s/%s)", version, runtime.GOOS, runtime.GOARCH)
+	case cfg.FFmpegStrict:
+		log.Fatalf("❌ Toolchain check failed: %v", err)
+	default:
+		log.Printf("⚠️  Toolchain check failed: %v", err)
+	}
+}
+
+// removeStaleJobDirs deletes working directories of jobs a crashed process never finished
+func removeStaleJobDirs(ts *storage.TempStorage) {
+	if n := ts.RemoveStaleJobDirs(); n > 0 {
+		log.Printf("🧹 Removed %d stale job directories", n)
+	}
+}
+
+// newSpaceGuard returns the free disk check, or nil when DISK_SPACE_FACTOR is 0
+func newSpaceGuard(cfg *config.Config) *storage.SpaceGuard {
+	if cfg.DiskSpaceFactor <= 0 {
+		return nil
+	}
+	log.Printf("💾 Disk space check: %.1fx input size + %dMB reserve", cfg.DiskSpaceFactor, cfg.DiskSpaceReserve>>20)
+	return storage.NewSpaceGuard(cfg.DiskSpaceFactor, cfg.DiskSpaceReserve)
+}
+
+// newMemoryGate sizes job admission control from GOMEMLIMIT
+// Returns nil (disabled) when MEMORY_BUDGET_FRACTION is 0 or no limit is known
+func newMemoryGate(cfg *config.Config) *pool.MemoryGate {
+	if cfg.MemoryBudgetFraction <= 0 {
+		return nil
+	}
+
+	limit, err := config.ParseByteSize(cfg.GoMemLimit)
+	if err != nil {
+		// Fall back to the limit the runtime is actually using
+		limit = debug.SetMemoryLimit(-1)
+		if limit == math.MaxInt64 {
+			log.Printf("⚠️  Memory admission disabled: %v and no runtime limit set", err)
+			return nil
+		}
+	}
+
+	budget := int64(float64(limit) * cfg.MemoryBudgetFraction)
+	log.Printf("🧮 Memory admission: budget=%dMB (%.0f%% of %dMB)", budget>>20, cfg.MemoryBudgetFraction*100, limit>>20)
+	return pool.NewMemoryGate(budget)
+}
+
+// newCDN returns the CDN outputs are handed out on, or nil when
+// CDN_BASE_URL is empty. Files removed from ts are purged from it
+func newCDN(cfg *config.Config, ts *storage.TempStorage) *cdn.CDN {
+	if cfg.CDNBaseURL == "" {
+		return nil
+	}
+	c, err := cdn.New(cdn.Config{
+		BaseURL:    cfg.CDNBaseURL,
+		SigningKey: cfg.CDNSigningKey,
+		PurgeURL:   cfg.CDNPurgeURL,
+		PurgeToken: cfg.CDNPurgeToken,
+	})
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	ts.SetRemoveHook(func(tf *storage.TempFile) {
+		c.Purge(tf.ID + filepath.Ext(tf.Path))
+	})
+	log.Printf("🌍 CDN enabled: %s (signed=%t, purge=%t)", cfg.CDNBaseURL, cfg.CDNSigningKey != "", cfg.CDNPurgeURL != "")
+	return c
+}
+
+// closeCDN sends queued purges, if a CDN is enabled
+func closeCDN(c *cdn.CDN) {
+	if c != nil {
+		c.Close(10 * time.Second)
+	}
+}
+
+// closeEscalation persists the escalation policy, if enabled
+func closeEscalation(p *services.EscalationPolicy) {
+	if p == nil {
+		return
+	}
+	if err := p.Close(); err != nil {
+		log.Printf("⚠️  Failed to persist escalation policy: %v", err)
+	}
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// bench is a load generator for POST /api/process. It sends the same source
+// URLs repeatedly with a fixed concurrency and reports latency percentiles,
+// throughput and errors, so pipeline changes can be compared run to run.
+//
+//	go run ./cmd/bench -target http://localhost:5001 -url https://example.com/a.jpg -n 200 -c 8
+
+type result struct {
+	latency time.Duration
+	status  int
+	err     error
+}
+
+type urlList []string
+
+func (u *urlList) String() string     { return strings.Join(*u, ",") }
+func (u *urlList) Set(v string) error { *u = append(*u, v); return nil }
+
+func main() {
+	var urls urlList
+	target := flag.String("target", "http://localhost:5001", "API base URL")
+	total := flag.Int("n", 100, "total number of requests")
+	concurrency := flag.Int("c", 4, "concurrent requests")
+	timeout := flag.Duration("timeout", 5*time.Minute, "per-request timeout")
+	profile := flag.String("profile", "", "technique profile to request (empty = server default)")
+	flag.Var(&urls, "url", "source media URL (repeatable, used round-robin)")
+	flag.Parse()
+
+	if len(urls) == 0 {
+		fmt.Fprintln(os.Stderr, "at least one -url is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *concurrency <= 0 || *total <= 0 {
+		fmt.Fprintln(os.Stderr, "-n and -c must be positive")
+		os.Exit(2)
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	endpoint := strings.TrimRight(*target, "/") + "/api/process"
+
+	jobs := make(chan int)
+	results := make(chan result, *total)
+	var sent int64
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results <- send(client, endpoint, urls[i%len(urls)], *profile)
+				if n := atomic.AddInt64(&sent, 1); n%10 == 0 {
+					log.Printf("progress: %d/%d", n, *total)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < *total; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+	elapsed := time.Since(start)
+
+	report(results, elapsed)
+}
+
+// send performs a single /api/process request
+func send(client *http.Client, endpoint, url, profile string) result {
+	body, _ := json.Marshal(map[string]string{"arquivo": url, "profile": profile})
+
+	start := time.Now()
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return result{latency: time.Since(start), err: err}
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return result{latency: time.Since(start), status: resp.StatusCode}
+}
+
+// report prints latency percentiles, throughput and error breakdown
+func report(results <-chan result, elapsed time.Duration) {
+	var latencies []time.Duration
+	statusCounts := map[int]int{}
+	transportErrors := 0
+
+	for r := range results {
+		if r.err != nil {
+			transportErrors++
+			continue
+		}
+		statusCounts[r.status]++
+		if r.status == http.StatusOK {
+			latencies = append(latencies, r.latency)
+		}
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Printf("\nduration:    %v\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("successful:  %d\n", len(latencies))
+	fmt.Printf("throughput:  %.2f req/s\n", float64(len(latencies))/elapsed.Seconds())
+	if len(latencies) > 0 {
+		fmt.Printf("latency min: %v\n", latencies[0].Round(time.Millisecond))
+		for _, p := range []float64{50, 90, 95, 99} {
+			fmt.Printf("latency p%-2.0f: %v\n", p, percentile(latencies, p).Round(time.Millisecond))
+		}
+		fmt.Printf("latency max: %v\n", latencies[len(latencies)-1].Round(time.Millisecond))
+	}
+	for status, count := range statusCounts {
+		if status != http.StatusOK {
+			fmt.Printf("HTTP %d:    %d\n", status, count)
+		}
+	}
+	if transportErrors > 0 {
+		fmt.Printf("transport errors: %d\n", transportErrors)
+	}
+}
+
+// percentile returns the p-th percentile of sorted latencies (nearest rank)
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	idx := int(float64(len(sorted))*p/100+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
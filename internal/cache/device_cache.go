@@ -9,6 +9,8 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
+
+	"fingerprint-converter/internal/redact"
 )
 
 // CacheEntry represents a cached file with metadata
@@ -141,7 +143,7 @@ func (dc *DeviceCache) Set(deviceID, url, processedPath, mediaType string, fileS
 	go dc.scheduleFileDeletion(deviceID, urlHash, processedPath, dc.fileTTL)
 
 	log.Printf("📦 Cache SET: device=%s, url=%s, path=%s, expires=%v",
-		deviceID, truncateURL(url), processedPath, entry.CacheExpires.Format("15:04:05"))
+		deviceID, truncateURL(url), redact.Path(processedPath), entry.CacheExpires.Format("15:04:05"))
 
 	return nil
 }
@@ -163,13 +165,13 @@ func (dc *DeviceCache) scheduleFileDeletion(deviceID, urlHash, filePath string,
 	// Delete physical file
 	if err := os.Remove(filePath); err != nil {
 		if !os.IsNotExist(err) {
-			log.Printf("⚠️  Failed to delete expired file %s: %v", filePath, err)
+			log.Printf("⚠️  Failed to delete expired file %s: %v", redact.Path(filePath), err)
 		}
 	} else {
 		dc.stats.mu.Lock()
 		dc.stats.Evictions++
 		dc.stats.mu.Unlock()
-		log.Printf("🗑️  Deleted expired file: %s (age: %v)", filepath.Base(filePath), ttl)
+		log.Printf("🗑️  Deleted expired file: %s (age: %v)", redact.Path(filepath.Base(filePath)), ttl)
 	}
 }
 
@@ -215,7 +217,7 @@ func (dc *DeviceCache) cleanup() {
 			for _, filePath := range expiredFiles {
 				if err := os.Remove(filePath); err != nil {
 					if !os.IsNotExist(err) {
-						log.Printf("⚠️  Cleanup failed to delete %s: %v", filePath, err)
+						log.Printf("⚠️  Cleanup failed to delete %s: %v", redact.Path(filePath), err)
 					}
 				}
 			}
@@ -303,6 +305,7 @@ func hashURL(url string) string {
 }
 
 func truncateURL(url string) string {
+	url = redact.URL(url)
 	if len(url) > 60 {
 		return url[:57] + "..."
 	}
@@ -0,0 +1,182 @@
+// Package cdn hands processed files out under CDN URLs. The CDN pulls each
+// file from /api/files once and serves the fan-out; URLs can be signed so
+// the edge rejects links past the file's expiry, and expired files are
+// purged from the CDN cache through a purge endpoint
+package cdn
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	queueSize     = 10000
+	maxPurgeBatch = 100 // URLs per purge request
+)
+
+// Config describes the CDN in front of the API's /api/files
+type Config struct {
+	BaseURL    string // e.g. https://cdn.example.com, pulling from the API's BASE_URL
+	SigningKey string // Signs URLs with expires and token query parameters (empty = unsigned)
+	PurgeURL   string // Receives POST {"urls": [...]} for removed files (empty = no purges)
+	PurgeToken string // Sent to PurgeURL as a bearer token
+}
+
+// CDN builds file URLs on the CDN and purges removed files from it
+type CDN struct {
+	baseURL    string
+	signingKey []byte
+	purgeURL   string
+	purgeToken string
+	client     *http.Client
+
+	mu     sync.Mutex
+	closed bool
+	queue  chan string
+	done   chan struct{}
+}
+
+// New checks cfg and, when a purge endpoint is set, starts the purger
+func New(cfg Config) (*CDN, error) {
+	if err := checkURL(cfg.BaseURL); err != nil {
+		return nil, fmt.Errorf("CDN base URL: %w", err)
+	}
+	c := &CDN{
+		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		signingKey: []byte(cfg.SigningKey),
+		purgeURL:   cfg.PurgeURL,
+		purgeToken: cfg.PurgeToken,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		done:       make(chan struct{}),
+	}
+	if cfg.PurgeURL == "" {
+		close(c.done)
+		return c, nil
+	}
+	if err := checkURL(cfg.PurgeURL); err != nil {
+		return nil, fmt.Errorf("CDN purge URL: %w", err)
+	}
+	c.queue = make(chan string, queueSize)
+	go c.purgeLoop()
+	return c, nil
+}
+
+// checkURL accepts absolute http(s) URLs
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("want an http(s) URL, got %q", raw)
+	}
+	return nil
+}
+
+// URL is the CDN URL of the stored file name (ID and extension). Signed
+// URLs carry expires (Unix seconds) and token, the hex HMAC-SHA256 with the
+// signing key of "<path>:<expires>", for the edge to verify
+func (c *CDN) URL(name string, expires time.Time) string {
+	path := "/api/files/" + name
+	if len(c.signingKey) == 0 {
+		return c.baseURL + path
+	}
+	unix := strconv.FormatInt(expires.Unix(), 10)
+	return c.baseURL + path + "?expires=" + unix + "&token=" + Sign(c.signingKey, path, unix)
+}
+
+// Sign is the token of path valid until expires (Unix seconds)
+func Sign(key []byte, path, expires string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path + ":" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Purge queues the file name for removal from the CDN cache; it never blocks
+func (c *CDN) Purge(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.queue == nil || c.closed {
+		return
+	}
+	select {
+	case c.queue <- c.baseURL + "/api/files/" + name:
+	default:
+		log.Printf("⚠️  CDN purge dropped (queue full): %s", name)
+	}
+}
+
+// Close sends queued purges, waiting at most timeout
+func (c *CDN) Close(timeout time.Duration) {
+	c.mu.Lock()
+	if c.closed || c.queue == nil {
+		c.closed = true
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	close(c.queue)
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+	case <-time.After(timeout):
+		log.Printf("⚠️  CDN purges still queued at shutdown: %d", len(c.queue))
+	}
+}
+
+// purgeLoop sends queued URLs, batching those queued while a request runs
+func (c *CDN) purgeLoop() {
+	defer close(c.done)
+	for first := range c.queue {
+		urls := []string{first}
+	batch:
+		for len(urls) < maxPurgeBatch {
+			select {
+			case u, ok := <-c.queue:
+				if !ok {
+					break batch
+				}
+				urls = append(urls, u)
+			default:
+				break batch
+			}
+		}
+		if err := c.purge(urls); err != nil {
+			log.Printf("⚠️  CDN purge of %d files failed: %v", len(urls), err)
+		}
+	}
+}
+
+func (c *CDN) purge(urls []string) error {
+	body, err := json.Marshal(map[string][]string{"urls": urls})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.purgeURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.purgeToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.purgeToken)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package cdn
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestURL(t *testing.T) {
+	expires := time.Unix(1700000000, 0)
+
+	unsigned, err := New(Config{BaseURL: "https://cdn.example.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := unsigned.URL("abc.png", expires); got != "https://cdn.example.com/api/files/abc.png" {
+		t.Errorf("unsigned URL = %s", got)
+	}
+
+	signed, err := New(Config{BaseURL: "https://cdn.example.com", SigningKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "https://cdn.example.com/api/files/abc.png?expires=1700000000&token=" + Sign([]byte("secret"), "/api/files/abc.png", "1700000000")
+	if got := signed.URL("abc.png", expires); got != want {
+		t.Errorf("signed URL = %s, want %s", got, want)
+	}
+	if Sign([]byte("secret"), "/api/files/abc.png", "1700000000") == Sign([]byte("secret"), "/api/files/abd.png", "1700000000") {
+		t.Error("token does not depend on the path")
+	}
+
+	for _, bad := range []Config{{BaseURL: ""}, {BaseURL: "cdn.example.com"}, {BaseURL: "https://cdn.example.com", PurgeURL: "ftp://x"}} {
+		if _, err := New(bad); err == nil {
+			t.Errorf("New(%+v) accepted", bad)
+		}
+	}
+}
+
+func TestPurge(t *testing.T) {
+	var mu sync.Mutex
+	var purged []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct{ URLs []string }
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		purged = append(purged, body.URLs...)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	c, err := New(Config{BaseURL: "https://cdn.example.com", PurgeURL: server.URL, PurgeToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Purge("a.png")
+	c.Purge("b.mp4")
+	c.Close(5 * time.Second)
+	c.Purge("after-close.png") // Dropped without panicking
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(purged, ",") != "https://cdn.example.com/api/files/a.png,https://cdn.example.com/api/files/b.mp4" {
+		t.Errorf("purged = %v", purged)
+	}
+}
@@ -0,0 +1,94 @@
+// Package chaos injects failures into a fraction of operations so retry and
+// error paths can be exercised end to end. It is meant for test deployments
+// only and does nothing until Enable is called
+package chaos
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// Fault is a kind of injected failure
+type Fault string
+
+const (
+	DownloadTimeout Fault = "download_timeout" // Download attempt fails as if it timed out
+	ToolFailure     Fault = "tool_failure"     // ffmpeg/qpdf run fails
+	SlowDisk        Fault = "slow_disk"        // Output and intermediate writes are delayed
+	DiskFull        Fault = "disk_full"        // ffmpeg/qpdf run fails with a full disk
+)
+
+// Faults lists every fault in a stable order
+var Faults = []Fault{DownloadTimeout, ToolFailure, SlowDisk, DiskFull}
+
+// ErrInjected is wrapped by every injected error
+var ErrInjected = errors.New("injected by chaos mode")
+
+// Config sets how often each fault is injected
+type Config struct {
+	Percent       map[Fault]float64 // Share of operations hit, 0-100
+	SlowDiskDelay time.Duration     // Delay added to a slowed write
+}
+
+var (
+	current  atomic.Pointer[Config]
+	injected = make(map[Fault]*atomic.Int64)
+)
+
+func init() {
+	for _, f := range Faults {
+		injected[f] = new(atomic.Int64)
+	}
+}
+
+// Enable starts injecting faults as configured
+func Enable(cfg Config) {
+	current.Store(&cfg)
+}
+
+// Disable stops injecting faults
+func Disable() {
+	current.Store(nil)
+}
+
+// Enabled reports whether chaos mode is on
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+// Hit reports whether the current operation should fail with f
+func Hit(f Fault) bool {
+	cfg := current.Load()
+	if cfg == nil {
+		return false
+	}
+	percent := cfg.Percent[f]
+	if percent <= 0 || rand.Float64()*100 >= percent {
+		return false
+	}
+	injected[f].Add(1)
+	log.Printf("🐒 Chaos: injected %s", f)
+	return true
+}
+
+// Delay sleeps for the configured slow-disk delay when SlowDisk hits
+func Delay() {
+	if !Hit(SlowDisk) {
+		return
+	}
+	if cfg := current.Load(); cfg != nil {
+		time.Sleep(cfg.SlowDiskDelay)
+	}
+}
+
+// Injected returns how many times each fault was injected
+func Injected() map[Fault]int64 {
+	counts := make(map[Fault]int64, len(Faults))
+	for _, f := range Faults {
+		counts[f] = injected[f].Load()
+	}
+	return counts
+}
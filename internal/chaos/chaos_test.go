@@ -0,0 +1,39 @@
+package chaos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHitFollowsPercent(t *testing.T) {
+	t.Cleanup(Disable)
+
+	if Hit(ToolFailure) {
+		t.Fatal("fault injected while disabled")
+	}
+
+	Enable(Config{Percent: map[Fault]float64{ToolFailure: 100, DownloadTimeout: 0}})
+	before := Injected()[ToolFailure]
+	for i := 0; i < 10; i++ {
+		if !Hit(ToolFailure) {
+			t.Fatal("100% fault not injected")
+		}
+		if Hit(DownloadTimeout) {
+			t.Fatal("0% fault injected")
+		}
+	}
+	if got := Injected()[ToolFailure] - before; got != 10 {
+		t.Errorf("injected count = %d, want 10", got)
+	}
+}
+
+func TestDelaySlowsWrites(t *testing.T) {
+	t.Cleanup(Disable)
+	Enable(Config{Percent: map[Fault]float64{SlowDisk: 100}, SlowDiskDelay: 20 * time.Millisecond})
+
+	start := time.Now()
+	Delay()
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Delay returned after %v", elapsed)
+	}
+}
@@ -1,10 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -19,6 +22,14 @@ type Config struct {
 	WriteTimeout time.Duration
 	BodyLimit    int
 
+	// Zero-downtime upgrades: SIGUSR2 starts the new binary on the same socket
+	GracefulUpgrade bool
+	UpgradeTimeout  time.Duration // Startup (including warm-up) allowed to the new process
+	ListenReusePort bool          // SO_REUSEPORT so separately started instances can share the port
+	ListenAddress   string        // IPv4 or IPv6 address to bind (empty = every address, dual-stack)
+	ListenInterface string        // Network interface to bind (Linux; empty = any)
+	PIDFile         string        // Rewritten by each new process so supervisors can follow upgrades
+
 	// Worker pool configuration
 	MaxWorkers          int
 	QueueSizeMultiplier int
@@ -33,32 +44,225 @@ type Config struct {
 	CacheTTL    time.Duration // 28 minutes
 	FileTTL     time.Duration // 30 minutes
 	EnableCache bool
+	// Per-media-type temp directories (e.g. video on a scratch volume); empty = CACHE_DIR/temp
+	TempDirs map[string]string
+	// Replicas sharing the temp directories (NFS): expiry cleanup runs only
+	// on the replica holding a lease file in the shared directory
+	StorageShared       bool
+	StorageLeaderTTL    time.Duration // Cleanup lease; another replica takes over this long after the leader stops
+	StorageJobDirMaxAge time.Duration // Job directories untouched this long are removed by the leader
 
 	// Performance tuning
 	GOGC       int
 	GoMemLimit string
+	// Share of GOMEMLIMIT reserved for in-flight jobs (0 disables admission control)
+	MemoryBudgetFraction float64
+	// Free temp space required per input byte before processing (0 disables the check)
+	DiskSpaceFactor  float64
+	DiskSpaceReserve int64 // Bytes always left free on the temp volume
+	// Most work one input may cause, estimated with ffprobe (0 = unlimited)
+	CostMaxMegapixelFrames float64
+	CostMaxAudioSeconds    float64
 
 	// Download settings
 	DownloadTimeout time.Duration
 	MaxDownloadSize int64
+	// Concurrent downloads, tuned separately from MaxWorkers (CPU-bound conversions)
+	MaxConcurrentDownloads int
+	// Delay before a hedged second request is sent for a slow download (0 = off)
+	DownloadHedgeDelay time.Duration
+	// Concurrent requests for the same URL share one download
+	DownloadCoalesce bool
+	// Address family of download connections (any, ipv4 or ipv6) and the
+	// happy-eyeballs delay before racing the other family when both are allowed
+	DownloadIPFamily      string
+	DownloadFallbackDelay time.Duration
+	// HTTP/3 for downloads: off, auto (hosts advertising h3) or always
+	// Needs a binary built with -tags http3
+	DownloadHTTP3 string
+	// TLS for internal sources: extra root CAs, a client certificate for
+	// mTLS, and a JSON file of per-host overrides
+	DownloadCAFile       string
+	DownloadClientCert   string
+	DownloadClientKey    string
+	DownloadTLSHostsFile string
+	// User-Agent for downloads; several separated by "|" are rotated
+	// (empty keeps Go's default). Requests may override it with user_agent
+	DownloadUserAgents []string
+	// Archive input: media files per zip/tar (0 rejects archives) and their
+	// total uncompressed size, held in memory while the archive is processed
+	// (0 = MaxDownloadSize); each file is also bounded by MaxDownloadSize
+	ArchiveMaxEntries int
+	ArchiveMaxBytes   int64
+	// /api/process/batch: files per request (0 disables batches) and how
+	// many of them one request converts at once
+	BatchMaxItems    int
+	BatchConcurrency int
 
 	// Anti-fingerprint settings
 	DefaultAFLevel string // none/basic/moderate/paranoid
+	DefaultProfile string // standard/color/paranoid - uniqueness techniques for /api/process
+
+	// URL classification
+	HeadProbe bool // HEAD extension-less URLs and classify by Content-Type
+
+	// Image settings
+	TIFFOutputFormat string // jpeg/png - target format for TIFF inputs (BMP always becomes PNG)
+
+	// Audio preprocessing
+	SilenceThresholdDB float64       // Default silence level for trim_silence
+	SilenceKeep        time.Duration // Default silence kept at each edge for trim_silence
+	PreserveDuration   bool          // Default for preserve_duration: delay replaces audio instead of lengthening it
+
+	// Document settings
+	EmbedPDFNonce bool // Append an invisible nonce object to processed PDFs
+
+	// ffmpeg/ffprobe binaries; KEY_<ARCH> (e.g. FFMPEG_PATH_ARM64) overrides KEY
+	FFmpegPath             string        // Empty = bundle, then PATH
+	FFprobePath            string        // Empty = bundle, then PATH
+	FFmpegBundleURL        string        // Pinned static build (.tar.gz/.tgz/.zip) installed into CACHE_DIR
+	FFmpegBundleSHA256     string        // Required digest of the bundle archive
+	FFmpegBundleTimeout    time.Duration // Bundle download at startup
+	FFmpegMinVersion       string        // Oldest accepted ffmpeg release
+	FFmpegRequiredEncoders []string      // Encoders ffmpeg must have
+	FFmpegStrict           bool          // Refuse to start when the toolchain check fails
+
+	// Decode every audio/image/video output before reporting success
+	VerifyOutput bool
+
+	// Replace ffmpeg's Lavf/Lavc encoder, handler and vendor tags with those of real tools
+	RandomizeEncoderTags bool
+
+	// Outputs are dated at a random time within this window before now (0 = off)
+	CreationTimeWindow time.Duration
+
+	// Accept gps_region requests embedding synthesized GPS EXIF in images
+	GPSSynthesis bool
+
+	// Input vs output duration comparison for audio and video
+	DurationCheck        string        // off/warn/fail
+	DurationTolerance    time.Duration // Allowed difference
+	DurationTolerancePct float64       // Allowed difference in percent of the input; the larger wins
+
+	// Startup warm-up of each media pipeline before accepting traffic
+	WarmUpOnStart bool
+	WarmUpTimeout time.Duration
+
+	// Throughput calibration (POST /admin/calibration)
+	CalibrationFile    string        // Last result (survives restarts)
+	CalibrationRuns    int           // Conversions timed per media type
+	CalibrationTimeout time.Duration // Upper bound for one calibration
+
+	// Slow/large request sampling (GET /admin/samples)
+	SampleSlowThreshold  time.Duration // Keep requests at least this slow (0 = off)
+	SampleSizePercentile float64       // Keep inputs at or above this percentile of recent sizes (0 = off)
+	SampleKeep           int           // Samples kept in memory
+
+	// Admin diagnostics (pprof, goroutine dump); disabled when empty
+	AdminToken string
+
+	// Multi-tenant policies keyed by X-API-Key (JSON file; disabled when empty)
+	TenantsFile        string
+	RequireAPIKey      bool   // Reject /api/process requests without a known key
+	TenantMetadataFile string // Default metadata set through /admin/tenants (survives restarts)
+
+	// Scheduled jobs (POST /api/jobs)
+	JobsFile       string        // Persisted job queue (survives restarts)
+	JobConcurrency int           // Jobs processed at once
+	JobRetention   time.Duration // How long finished jobs stay queryable
+	JobMaxDelay    time.Duration // Furthest accepted process_at
+	JobMaxVariants int           // Most variants per job
+	JobMaxObjects  int           // Most objects under the S3 prefix of a source job
+	// Transient job failures run again with exponential backoff; jobs that
+	// fail for good stay listed at /admin/jobs/dead-letter
+	JobRetries             int
+	JobRetryBackoff        time.Duration // Delay before the first retry, doubled for each one after
+	JobRetryMaxBackoff     time.Duration
+	JobDeadLetterRetention time.Duration // How long failed jobs stay queryable
+
+	// Distributed processing: standalone runs jobs itself; a dispatcher
+	// serves the API and leases jobs to worker nodes, which pull them from
+	// DispatcherURL and run up to JobConcurrency at once. A files node only
+	// serves GET /api/files from shared storage (requires StorageShared)
+	NodeRole           string
+	DispatcherURL      string
+	WorkerToken        string        // Shared secret between dispatcher and workers
+	WorkerName         string        // Identifies a worker's leases (default: hostname)
+	WorkerPollInterval time.Duration // Worker wait after finding no due job
+	WorkerLease        time.Duration // Dispatcher: a job returns to the queue when its worker stops renewing
+
+	// S3-compatible object storage for recurring outputs and source jobs (disabled without credentials)
+	S3Endpoint        string // Empty = AWS for S3Region
+	S3Region          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3ForcePathStyle  bool // Bucket in the path (MinIO and most S3-compatible stores)
+
+	// Long-term storage of outputs requested with archive (requires S3)
+	ArchiveDestination string        // s3://bucket/prefix (empty = archive rejected)
+	ArchiveLinkTTL     time.Duration // Validity of the presigned URLs archive_url redirects to
+
+	// CDN in front of /api/files: nova_url points at CDNBaseURL, signed
+	// with CDNSigningKey, and removed files are purged through CDNPurgeURL
+	CDNBaseURL    string // Empty = nova_url on BASE_URL
+	CDNSigningKey string
+	CDNPurgeURL   string
+	CDNPurgeToken string
+
+	// Chat platform webhooks (POST /api/messaging/:platform), each enabled by
+	// its token; media is fetched through the platform API
+	WhatsAppToken       string // Cloud API access token
+	WhatsAppAppSecret   string // Verifies X-Hub-Signature-256 (required with WhatsAppToken)
+	WhatsAppGraphURL    string
+	TelegramBotToken    string
+	TelegramSecretToken string // Expected X-Telegram-Bot-Api-Secret-Token (required with TelegramBotToken)
+	TelegramAPIURL      string
+
+	// Recurring sources (POST /api/recurring)
+	RecurringFile        string        // Persisted registrations (survive restarts)
+	RecurringMinInterval time.Duration // Shortest accepted cadence
+	RecurringMaxVariants int           // Most variants per run
+
+	// Technique escalation on dedup feedback (POST /api/feedback)
+	Escalation         bool
+	EscalationFile     string // Learned levels and recent deliveries (survive restarts)
+	EscalationMaxLevel int    // Highest level; 1 enables every technique, each further level scales them
+
+	// Similarity engines run by compare and POST /api/compare, each
+	// engine[:type+type][:threshold] (empty disables comparison)
+	SimilarityEngines []string
 
 	// Logging configuration
-	LogLevel              string
+	LogLevel              string            // debug, info, warn or error
+	LogLevels             map[string]string // Per-module overrides of LogLevel ("" = inherit)
 	EnablePerformanceLogs bool
+	PIISafeLogs           bool   // Log salted hashes instead of source URLs and file paths
+	LogHashSalt           string // HMAC salt for PIISafeLogs (random per process when empty)
 
 	// Development settings
 	Debug bool
 
+	// Failure injection for integration tests; refused with PRODUCTION_MODE
+	ChaosMode               bool
+	ChaosDownloadTimeoutPct float64       // Download attempts failing as timed out
+	ChaosToolFailurePct     float64       // ffmpeg/qpdf runs failing
+	ChaosDiskFullPct        float64       // ffmpeg/qpdf runs failing with a full disk
+	ChaosSlowDiskPct        float64       // Output and intermediate writes delayed
+	ChaosSlowDiskDelay      time.Duration // Delay of a slowed write
+
 	// Production settings
-	ProductionMode bool
-	EnableCORS     bool
+	ProductionMode    bool
+	EnableCORS        bool
+	EnableCompression bool // gzip/deflate/brotli for JSON responses (never media)
 
 	// Monitoring settings
 	EnableHealthCheck   bool
 	EnableStatsEndpoint bool
+
+	// Error reporting: conversion failures sent to a Sentry-compatible DSN
+	SentryDSN         string // Empty disables reporting
+	SentryEnvironment string
+	SentryRelease     string
 }
 
 // Load loads configuration from environment variables and .env file
@@ -78,6 +282,14 @@ func Load() *Config {
 		WriteTimeout: getDuration("WRITE_TIMEOUT", 5*time.Minute),
 		BodyLimit:    getInt("BODY_LIMIT", 500*1024*1024), // 500MB
 
+		// Zero-downtime upgrades
+		GracefulUpgrade: getBool("GRACEFUL_UPGRADE", true),
+		UpgradeTimeout:  getDuration("UPGRADE_TIMEOUT", 2*time.Minute),
+		ListenReusePort: getBool("LISTEN_REUSEPORT", false),
+		ListenAddress:   getEnv("LISTEN_ADDRESS", ""),
+		ListenInterface: getEnv("LISTEN_INTERFACE", ""),
+		PIDFile:         getEnv("PID_FILE", ""),
+
 		// Worker pool - smart defaults based on CPU
 		MaxWorkers:          getWorkerCount(),
 		QueueSizeMultiplier: getInt("QUEUE_SIZE_MULTIPLIER", 10),
@@ -92,32 +304,207 @@ func Load() *Config {
 		CacheTTL:    getDuration("CACHE_TTL", 28*time.Minute),
 		FileTTL:     getDuration("FILE_TTL", 30*time.Minute),
 		EnableCache: getBool("ENABLE_CACHE", true),
+		TempDirs: map[string]string{
+			"audio":    getEnv("TEMP_DIR_AUDIO", ""),
+			"image":    getEnv("TEMP_DIR_IMAGE", ""),
+			"video":    getEnv("TEMP_DIR_VIDEO", ""),
+			"document": getEnv("TEMP_DIR_DOCUMENT", ""),
+		},
+		StorageShared:       getBool("STORAGE_SHARED", false),
+		StorageLeaderTTL:    getDuration("STORAGE_LEADER_TTL", 3*time.Minute),
+		StorageJobDirMaxAge: getDuration("STORAGE_JOB_DIR_MAX_AGE", 6*time.Hour),
 
 		// GC and memory tuning
-		GOGC:       getInt("GOGC", 100),
-		GoMemLimit: getEnv("GOMEMLIMIT", "2GiB"),
+		GOGC:                 getInt("GOGC", 100),
+		GoMemLimit:           getEnv("GOMEMLIMIT", "2GiB"),
+		MemoryBudgetFraction: getFloat("MEMORY_BUDGET_FRACTION", 0.7),
+		DiskSpaceFactor:      getFloat("DISK_SPACE_FACTOR", 3),
+		DiskSpaceReserve:     getInt64("DISK_SPACE_RESERVE", 256*1024*1024), // 256MB
+
+		CostMaxMegapixelFrames: getFloat("COST_MAX_MEGAPIXEL_FRAMES", 0),
+		CostMaxAudioSeconds:    getFloat("COST_MAX_AUDIO_SECONDS", 0),
 
 		// Download settings
-		DownloadTimeout: getDuration("DOWNLOAD_TIMEOUT", 2*time.Minute), // Aumentado para 2min (vídeos grandes)
-		MaxDownloadSize: getInt64("MAX_DOWNLOAD_SIZE", 500*1024*1024),   // 500MB
+		DownloadTimeout:        getDuration("DOWNLOAD_TIMEOUT", 2*time.Minute), // Aumentado para 2min (vídeos grandes)
+		MaxDownloadSize:        getInt64("MAX_DOWNLOAD_SIZE", 500*1024*1024),   // 500MB
+		MaxConcurrentDownloads: getInt("MAX_CONCURRENT_DOWNLOADS", 16),
+		DownloadHedgeDelay:     getDuration("DOWNLOAD_HEDGE_DELAY", 0),
+		DownloadCoalesce:       getBool("DOWNLOAD_COALESCE", true),
+		DownloadIPFamily:       getEnv("DOWNLOAD_IP_FAMILY", "any"),
+		DownloadFallbackDelay:  getDuration("DOWNLOAD_FALLBACK_DELAY", 300*time.Millisecond),
+		DownloadHTTP3:          getEnv("DOWNLOAD_HTTP3", "off"),
+		DownloadCAFile:         getEnv("DOWNLOAD_CA_FILE", ""),
+		DownloadClientCert:     getEnv("DOWNLOAD_CLIENT_CERT", ""),
+		DownloadClientKey:      getEnv("DOWNLOAD_CLIENT_KEY", ""),
+		DownloadTLSHostsFile:   getEnv("DOWNLOAD_TLS_HOSTS_FILE", ""),
+		DownloadUserAgents:     getListSep("DOWNLOAD_USER_AGENT", "", "|"),
+		ArchiveMaxEntries:      getInt("ARCHIVE_MAX_ENTRIES", 100),
+		ArchiveMaxBytes:        getInt64("ARCHIVE_MAX_BYTES", 0),
+		BatchMaxItems:          getInt("BATCH_MAX_ITEMS", 50),
+		BatchConcurrency:       getInt("BATCH_CONCURRENCY", 4),
 
 		// Anti-fingerprint settings
 		DefaultAFLevel: getEnv("DEFAULT_AF_LEVEL", "moderate"),
+		DefaultProfile: getEnv("DEFAULT_PROFILE", "standard"),
+
+		// URL classification
+		HeadProbe: getBool("HEAD_PROBE", true),
+
+		// Image settings
+		TIFFOutputFormat: getEnv("TIFF_OUTPUT_FORMAT", "jpeg"),
+
+		// Audio preprocessing
+		SilenceThresholdDB: getFloat("SILENCE_THRESHOLD_DB", -50),
+		SilenceKeep:        getDuration("SILENCE_KEEP", 200*time.Millisecond),
+		PreserveDuration:   getBool("PRESERVE_AUDIO_DURATION", false),
+
+		// Document settings
+		EmbedPDFNonce: getBool("EMBED_PDF_NONCE", true),
+
+		// ffmpeg toolchain
+		FFmpegPath:             getArchEnv("FFMPEG_PATH", ""),
+		FFprobePath:            getArchEnv("FFPROBE_PATH", ""),
+		FFmpegBundleURL:        getArchEnv("FFMPEG_BUNDLE_URL", ""),
+		FFmpegBundleSHA256:     getArchEnv("FFMPEG_BUNDLE_SHA256", ""),
+		FFmpegBundleTimeout:    getDuration("FFMPEG_BUNDLE_TIMEOUT", 5*time.Minute),
+		FFmpegMinVersion:       getEnv("FFMPEG_MIN_VERSION", "4.4"),
+		FFmpegRequiredEncoders: getList("FFMPEG_REQUIRED_ENCODERS", "libx264,aac,libmp3lame,libopus,mjpeg,png"),
+		FFmpegStrict:           getBool("FFMPEG_STRICT", false),
+
+		// Output verification
+		VerifyOutput: getBool("VERIFY_OUTPUT", true),
+
+		// Encoder identification
+		RandomizeEncoderTags: getBool("RANDOMIZE_ENCODER_TAGS", true),
+
+		// Creation time randomization
+		CreationTimeWindow: getDuration("CREATION_TIME_WINDOW", 30*24*time.Hour),
+
+		// GPS synthesis (opt-in)
+		GPSSynthesis: getBool("GPS_SYNTHESIS", false),
+
+		// Duration consistency check
+		DurationCheck:        getEnv("DURATION_CHECK", "warn"),
+		DurationTolerance:    getDuration("DURATION_TOLERANCE", 500*time.Millisecond),
+		DurationTolerancePct: getFloat("DURATION_TOLERANCE_PCT", 0.5),
+
+		// Startup warm-up
+		WarmUpOnStart: getBool("WARMUP_ON_START", true),
+		WarmUpTimeout: getDuration("WARMUP_TIMEOUT", 30*time.Second),
+
+		// Throughput calibration
+		CalibrationFile:    getEnv("CALIBRATION_FILE", filepath.Join(getEnv("CACHE_DIR", "/tmp/media-cache"), "calibration.json")),
+		CalibrationRuns:    getInt("CALIBRATION_RUNS", 3),
+		CalibrationTimeout: getDuration("CALIBRATION_TIMEOUT", 2*time.Minute),
+
+		// Slow/large request sampling
+		SampleSlowThreshold:  getDuration("SAMPLE_SLOW_THRESHOLD", 10*time.Second),
+		SampleSizePercentile: getFloat("SAMPLE_SIZE_PERCENTILE", 99),
+		SampleKeep:           getInt("SAMPLE_KEEP", 50),
+
+		// Admin diagnostics
+		AdminToken: getEnv("ADMIN_TOKEN", ""),
+
+		// Multi-tenant policies
+		TenantsFile:        getEnv("TENANTS_FILE", ""),
+		RequireAPIKey:      getBool("REQUIRE_API_KEY", false),
+		TenantMetadataFile: getEnv("TENANT_METADATA_FILE", filepath.Join(getEnv("CACHE_DIR", "/tmp/media-cache"), "tenant-metadata.json")),
+
+		// Scheduled jobs
+		JobsFile:       getEnv("JOBS_FILE", filepath.Join(getEnv("CACHE_DIR", "/tmp/media-cache"), "jobs.json")),
+		JobConcurrency: getInt("JOB_CONCURRENCY", 4),
+		JobRetention:   getDuration("JOB_RETENTION", time.Hour),
+		JobMaxDelay:    getDuration("JOB_MAX_DELAY", 7*24*time.Hour),
+		JobMaxVariants: getInt("JOB_MAX_VARIANTS", 20),
+		JobMaxObjects:  getInt("JOB_MAX_OBJECTS", 1000),
+
+		JobRetries:             getInt("JOB_RETRIES", 2),
+		JobRetryBackoff:        getDuration("JOB_RETRY_BACKOFF", 30*time.Second),
+		JobRetryMaxBackoff:     getDuration("JOB_RETRY_MAX_BACKOFF", 10*time.Minute),
+		JobDeadLetterRetention: getDuration("JOB_DEAD_LETTER_RETENTION", 7*24*time.Hour),
+
+		// Distributed processing
+		NodeRole:           getEnv("NODE_ROLE", "standalone"),
+		DispatcherURL:      getEnv("DISPATCHER_URL", ""),
+		WorkerToken:        getEnv("WORKER_TOKEN", ""),
+		WorkerName:         getEnv("WORKER_NAME", hostname()),
+		WorkerPollInterval: getDuration("WORKER_POLL_INTERVAL", 2*time.Second),
+		WorkerLease:        getDuration("WORKER_LEASE", 2*time.Minute),
+
+		// Object storage
+		S3Endpoint:        getEnv("S3_ENDPOINT", ""),
+		S3Region:          getEnv("S3_REGION", "us-east-1"),
+		S3AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+		S3ForcePathStyle:  getBool("S3_FORCE_PATH_STYLE", false),
+
+		// Archival
+		ArchiveDestination: getEnv("ARCHIVE_DESTINATION", ""),
+		ArchiveLinkTTL:     getDuration("ARCHIVE_LINK_TTL", 15*time.Minute),
+
+		// CDN
+		CDNBaseURL:    getEnv("CDN_BASE_URL", ""),
+		CDNSigningKey: getEnv("CDN_SIGNING_KEY", ""),
+		CDNPurgeURL:   getEnv("CDN_PURGE_URL", ""),
+		CDNPurgeToken: getEnv("CDN_PURGE_TOKEN", ""),
+
+		// Messaging webhooks
+		WhatsAppToken:       getEnv("WHATSAPP_TOKEN", ""),
+		WhatsAppAppSecret:   getEnv("WHATSAPP_APP_SECRET", ""),
+		WhatsAppGraphURL:    getEnv("WHATSAPP_GRAPH_URL", "https://graph.facebook.com/v21.0"),
+		TelegramBotToken:    getEnv("TELEGRAM_BOT_TOKEN", ""),
+		TelegramSecretToken: getEnv("TELEGRAM_SECRET_TOKEN", ""),
+		TelegramAPIURL:      getEnv("TELEGRAM_API_URL", "https://api.telegram.org"),
+
+		// Recurring sources
+		RecurringFile:        getEnv("RECURRING_FILE", filepath.Join(getEnv("CACHE_DIR", "/tmp/media-cache"), "recurring.json")),
+		RecurringMinInterval: getDuration("RECURRING_MIN_INTERVAL", 5*time.Minute),
+		RecurringMaxVariants: getInt("RECURRING_MAX_VARIANTS", 100),
+
+		// Escalation
+		Escalation:         getBool("ESCALATION", true),
+		EscalationFile:     getEnv("ESCALATION_FILE", filepath.Join(getEnv("CACHE_DIR", "/tmp/media-cache"), "escalation.json")),
+		EscalationMaxLevel: getInt("ESCALATION_MAX_LEVEL", 3),
+
+		// Similarity engines
+		SimilarityEngines: getList("SIMILARITY_ENGINES", "phash,blockhash,ssim,chromaprint,videohash"),
 
 		// Logging configuration
-		LogLevel:              getEnv("LOG_LEVEL", "info"),
+		LogLevel: getEnv("LOG_LEVEL", "info"),
+		LogLevels: map[string]string{
+			"downloader": getEnv("LOG_LEVEL_DOWNLOADER", ""),
+			"converters": getEnv("LOG_LEVEL_CONVERTERS", ""),
+			"storage":    getEnv("LOG_LEVEL_STORAGE", ""),
+			"http":       getEnv("LOG_LEVEL_HTTP", ""),
+		},
 		EnablePerformanceLogs: getBool("ENABLE_PERFORMANCE_LOGS", true),
+		PIISafeLogs:           getBool("PII_SAFE_LOGS", false),
+		LogHashSalt:           getEnv("LOG_HASH_SALT", ""),
 
 		// Development settings
 		Debug: getBool("DEBUG", false),
 
+		// Failure injection
+		ChaosMode:               getBool("CHAOS_MODE", false),
+		ChaosDownloadTimeoutPct: getFloat("CHAOS_DOWNLOAD_TIMEOUT_PCT", 0),
+		ChaosToolFailurePct:     getFloat("CHAOS_TOOL_FAILURE_PCT", 0),
+		ChaosDiskFullPct:        getFloat("CHAOS_DISK_FULL_PCT", 0),
+		ChaosSlowDiskPct:        getFloat("CHAOS_SLOW_DISK_PCT", 0),
+		ChaosSlowDiskDelay:      getDuration("CHAOS_SLOW_DISK_DELAY", 2*time.Second),
+
 		// Production settings
-		ProductionMode: getBool("PRODUCTION_MODE", false),
-		EnableCORS:     getBool("ENABLE_CORS", true),
+		ProductionMode:    getBool("PRODUCTION_MODE", false),
+		EnableCORS:        getBool("ENABLE_CORS", true),
+		EnableCompression: getBool("ENABLE_COMPRESSION", true),
 
 		// Monitoring settings
 		EnableHealthCheck:   getBool("ENABLE_HEALTH_CHECK", true),
 		EnableStatsEndpoint: getBool("ENABLE_STATS_ENDPOINT", true),
+
+		// Error reporting
+		SentryDSN:         getEnv("SENTRY_DSN", ""),
+		SentryEnvironment: getEnv("SENTRY_ENVIRONMENT", getEnv("APP_ENV", "development")),
+		SentryRelease:     getEnv("SENTRY_RELEASE", ""),
 	}
 }
 
@@ -130,6 +517,39 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getArchEnv prefers KEY_<GOARCH> (e.g. FFMPEG_PATH_ARM64) over KEY, so one
+// environment can serve hosts of different architectures
+func getArchEnv(key, defaultValue string) string {
+	if value := os.Getenv(key + "_" + strings.ToUpper(runtime.GOARCH)); value != "" {
+		return value
+	}
+	return getEnv(key, defaultValue)
+}
+
+// hostname returns the machine's host name, "worker" when unknown
+func hostname() string {
+	if name, err := os.Hostname(); err == nil && name != "" {
+		return name
+	}
+	return "worker"
+}
+
+// getList splits a comma-separated value, dropping empty entries
+func getList(key, defaultValue string) []string {
+	return getListSep(key, defaultValue, ",")
+}
+
+// getListSep splits a value on sep, for items that may contain commas
+func getListSep(key, defaultValue, sep string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func getInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
 		if parsed, err := strconv.Atoi(value); err == nil {
@@ -150,6 +570,16 @@ func getInt64(key string, defaultValue int64) int64 {
 	return defaultValue
 }
 
+func getFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+		log.Printf("Warning: Invalid float value for %s: %s, using default: %v", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
 func getBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if parsed, err := strconv.ParseBool(value); err == nil {
@@ -170,6 +600,37 @@ func getDuration(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+// ParseByteSize parses sizes in GOMEMLIMIT syntax: a number with an optional
+// B, KiB, MiB, GiB or TiB suffix
+func ParseByteSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		factor int64
+	}{
+		{"TiB", 1 << 40},
+		{"GiB", 1 << 30},
+		{"MiB", 1 << 20},
+		{"KiB", 1 << 10},
+		{"B", 1},
+	}
+
+	s = strings.TrimSpace(s)
+	factor := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSuffix(s, u.suffix)
+			factor = u.factor
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	return n * factor, nil
+}
+
 func getWorkerCount() int {
 	if value := os.Getenv("MAX_WORKERS"); value != "" {
 		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
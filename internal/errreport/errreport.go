@@ -0,0 +1,346 @@
+// Package errreport sends processing failures to a Sentry-compatible
+// endpoint, so operators learn about new failure modes without tailing logs
+package errreport
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	clientName = "fingerprint-converter/1.0"
+	queueSize  = 64 // Failures waiting to be sent; more are dropped
+	maxFrames  = 32
+)
+
+var (
+	urlRe     = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"']+`)
+	absPathRe = regexp.MustCompile(`/[^\s:'",]+`)
+)
+
+// Failure describes one processing failure. It must not carry URLs, paths or
+// tenant data; messages are scrubbed again before sending
+type Failure struct {
+	Err     error
+	Message string            // Groups events, e.g. "video conversion failed"
+	Level   string            // error (default) or warning
+	Tags    map[string]string // Low-cardinality: media_type, error_class, tool
+	Extra   map[string]any    // e.g. input_size
+}
+
+// Reporter sends failures in the background
+type Reporter struct {
+	dsn         string
+	endpoint    string
+	auth        string
+	environment string
+	release     string
+	serverName  string
+	client      *http.Client
+	mu          sync.Mutex
+	closed      bool
+	queue       chan []byte
+	done        chan struct{}
+	dropped     atomic.Int64
+}
+
+// NewReporter parses a DSN such as https://key@o1.ingest.sentry.io/42 and
+// starts the sender
+func NewReporter(dsn, environment, release string) (*Reporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil || u.User.Username() == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid DSN: want scheme://key@host/project")
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	project := path[slash+1:]
+	if project == "" {
+		return nil, fmt.Errorf("invalid DSN: missing project ID")
+	}
+
+	host, _ := os.Hostname()
+	r := &Reporter{
+		dsn:         dsn,
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, path[:slash], project),
+		auth:        fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s, sentry_key=%s", clientName, u.User.Username()),
+		environment: environment,
+		release:     release,
+		serverName:  host,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		queue:       make(chan []byte, queueSize),
+		done:        make(chan struct{}),
+	}
+	go r.sendLoop()
+	return r, nil
+}
+
+// Capture queues f with a stack trace of the caller; it never blocks
+func (r *Reporter) Capture(f Failure) {
+	envelope, err := r.envelope(f, stackFrames(3))
+	if err != nil {
+		log.Printf("⚠️  Error report dropped: %v", err)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.queue <- envelope:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Close sends queued reports, waiting at most timeout
+func (r *Reporter) Close(timeout time.Duration) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.queue)
+	r.mu.Unlock()
+
+	select {
+	case <-r.done:
+	case <-time.After(timeout):
+		log.Printf("⚠️  Error reports still queued at shutdown: %d", len(r.queue))
+	}
+	if n := r.dropped.Load(); n > 0 {
+		log.Printf("⚠️  Error reports dropped (queue full): %d", n)
+	}
+}
+
+func (r *Reporter) sendLoop() {
+	defer close(r.done)
+	for envelope := range r.queue {
+		if err := r.send(envelope); err != nil {
+			log.Printf("⚠️  Error report failed: %v", err)
+		}
+	}
+}
+
+func (r *Reporter) send(envelope []byte) error {
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(envelope))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// event is the subset of the Sentry event payload this service sends
+type event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Message     string            `json:"message"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]any    `json:"extra,omitempty"`
+	Fingerprint []string          `json:"fingerprint"`
+	Exception   struct {
+		Values []exception `json:"values"`
+	} `json:"exception"`
+}
+
+type exception struct {
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	Stacktrace struct {
+		Frames []frame `json:"frames"`
+	} `json:"stacktrace"`
+}
+
+type frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// envelope renders f as a Sentry envelope: header, item header, event
+func (r *Reporter) envelope(f Failure, frames []frame) ([]byte, error) {
+	id := make([]byte, 16)
+	rand.Read(id)
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	level := f.Level
+	if level == "" {
+		level = "error"
+	}
+	ev := event{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   now,
+		Platform:    "go",
+		Level:       level,
+		Logger:      "processing",
+		ServerName:  r.serverName,
+		Environment: r.environment,
+		Release:     r.release,
+		Message:     Scrub(f.Message),
+		Tags:        f.Tags,
+		Extra:       f.Extra,
+		// Group by failure class rather than by the varying error text
+		Fingerprint: []string{f.Message, f.Tags["media_type"], f.Tags["error_class"], f.Tags["tool"]},
+	}
+	exc := exception{Type: "error", Value: ev.Message}
+	if f.Err != nil {
+		exc.Type = errorType(f.Err)
+		exc.Value = Scrub(f.Err.Error())
+	}
+	exc.Stacktrace.Frames = frames
+	ev.Exception.Values = []exception{exc}
+
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	header, _ := json.Marshal(map[string]string{"event_id": ev.EventID, "sent_at": now, "dsn": r.dsn})
+	item, _ := json.Marshal(map[string]any{"type": "event", "length": len(payload)})
+
+	var buf bytes.Buffer
+	buf.Write(header)
+	buf.WriteByte('\n')
+	buf.Write(item)
+	buf.WriteByte('\n')
+	buf.Write(payload)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// Scrub replaces URLs and reduces absolute paths to file names
+func Scrub(s string) string {
+	s = urlRe.ReplaceAllString(s, "[url]")
+	return absPathRe.ReplaceAllStringFunc(s, filepath.Base)
+}
+
+// errorType names the first error in the chain that is more than wrapped
+// text, e.g. "services.ExecError"
+func errorType(err error) string {
+	for err != nil {
+		t := reflect.TypeOf(err)
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		name := t.String()
+		if name != "errors.errorString" && name != "fmt.wrapError" && name != "fmt.wrapErrors" {
+			return name
+		}
+		next := errors.Unwrap(err)
+		if next == nil {
+			return name
+		}
+		err = next
+	}
+	return "error"
+}
+
+// stackFrames returns the caller's stack, outermost call first as Sentry expects
+func stackFrames(skip int) []frame {
+	pcs := make([]uintptr, maxFrames)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var out []frame
+	for {
+		f, more := frames.Next()
+		module, function := splitFunction(f.Function)
+		out = append(out, frame{
+			Function: function,
+			Module:   module,
+			Filename: filepath.Base(f.File),
+			Lineno:   f.Line,
+			InApp:    strings.HasPrefix(f.Function, "fingerprint-converter/"),
+		})
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// splitFunction splits "pkg/path.(*T).Method" into its package and function
+func splitFunction(name string) (string, string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:slash+1+dot], name[slash+2+dot:]
+}
+
+var (
+	mu      sync.Mutex
+	current *Reporter
+)
+
+// Enable installs the process-wide reporter used by Capture
+func Enable(dsn, environment, release string) error {
+	r, err := NewReporter(dsn, environment, release)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	current = r
+	mu.Unlock()
+	return nil
+}
+
+// Capture reports f through the process-wide reporter, if enabled
+func Capture(f Failure) {
+	mu.Lock()
+	r := current
+	mu.Unlock()
+	if r != nil {
+		r.Capture(f)
+	}
+}
+
+// Close flushes and disables the process-wide reporter
+func Close(timeout time.Duration) {
+	mu.Lock()
+	r := current
+	current = nil
+	mu.Unlock()
+	if r != nil {
+		r.Close(timeout)
+	}
+}
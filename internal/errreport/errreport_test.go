@@ -0,0 +1,103 @@
+package errreport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewReporterEndpoint(t *testing.T) {
+	r, err := NewReporter("https://abc123@o1.ingest.sentry.io/prefix/42", "prod", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close(time.Second)
+
+	if want := "https://o1.ingest.sentry.io/prefix/api/42/envelope/"; r.endpoint != want {
+		t.Errorf("endpoint = %q, want %q", r.endpoint, want)
+	}
+	if !strings.Contains(r.auth, "sentry_key=abc123") {
+		t.Errorf("auth = %q", r.auth)
+	}
+}
+
+func TestNewReporterInvalidDSN(t *testing.T) {
+	for _, dsn := range []string{"not a url", "https://o1.ingest.sentry.io/42", "https://key@host/"} {
+		if _, err := NewReporter(dsn, "", ""); err == nil {
+			t.Errorf("NewReporter(%q) succeeded", dsn)
+		}
+	}
+}
+
+func TestCaptureSendsScrubbedEnvelope(t *testing.T) {
+	received := make(chan []byte, 1)
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth = req.Header.Get("X-Sentry-Auth")
+		body, _ := io.ReadAll(req.Body)
+		received <- body
+	}))
+	defer srv.Close()
+
+	r, err := NewReporter(strings.Replace(srv.URL, "://", "://key@", 1)+"/7", "test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Capture(Failure{
+		Err:     fmt.Errorf("ffmpeg failed on https://cdn.example.com/a.mp4: open /var/tmp/media/in.mp4: %w", errors.New("boom")),
+		Message: "video conversion failed",
+		Tags:    map[string]string{"media_type": "video", "error_class": "tool_failed"},
+		Extra:   map[string]any{"input_size": 1024},
+	})
+	r.Close(5 * time.Second)
+
+	var body []byte
+	select {
+	case body = <-received:
+	default:
+		t.Fatal("no envelope received")
+	}
+	if !strings.Contains(auth, "sentry_key=key") {
+		t.Errorf("auth header = %q", auth)
+	}
+
+	lines := bufio.NewScanner(bytes.NewReader(body))
+	var parts []string
+	for lines.Scan() {
+		parts = append(parts, lines.Text())
+	}
+	if len(parts) != 3 {
+		t.Fatalf("envelope has %d lines, want 3", len(parts))
+	}
+
+	var ev event
+	if err := json.Unmarshal([]byte(parts[2]), &ev); err != nil {
+		t.Fatalf("event: %v", err)
+	}
+	if ev.Level != "error" || ev.Environment != "test" || ev.Tags["media_type"] != "video" {
+		t.Errorf("event = %+v", ev)
+	}
+	value := ev.Exception.Values[0].Value
+	if strings.Contains(value, "cdn.example.com") || strings.Contains(value, "/var/tmp") {
+		t.Errorf("exception not scrubbed: %q", value)
+	}
+	if len(ev.Exception.Values[0].Stacktrace.Frames) == 0 {
+		t.Error("missing stack trace")
+	}
+}
+
+func TestScrub(t *testing.T) {
+	got := Scrub(`download https://x.test/a?token=s failed: open /tmp/media/abc.mp3: no such file`)
+	want := `download [url] failed: open abc.mp3: no such file`
+	if got != want {
+		t.Errorf("Scrub = %q, want %q", got, want)
+	}
+}
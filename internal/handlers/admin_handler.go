@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/chaos"
+	"fingerprint-converter/internal/pool"
+)
+
+// AdminHandler serves runtime diagnostics for operators
+type AdminHandler struct {
+	token      string
+	bufferPool *pool.BufferPool
+	startedAt  time.Time
+}
+
+// NewAdminHandler creates a new admin handler guarded by token
+func NewAdminHandler(token string, bufferPool *pool.BufferPool) *AdminHandler {
+	return &AdminHandler{
+		token:      token,
+		bufferPool: bufferPool,
+		startedAt:  time.Now(),
+	}
+}
+
+// RequireToken rejects requests without the admin token
+// Accepts "Authorization: Bearer <token>" or "X-Admin-Token: <token>"
+func (h *AdminHandler) RequireToken(c fiber.Ctx) error {
+	provided := c.Get("X-Admin-Token")
+	if provided == "" {
+		provided = strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+	}
+
+	if h.token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(h.token)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"error":   "admin token required",
+		})
+	}
+
+	return c.Next()
+}
+
+// Goroutines handles GET /admin/goroutines with a full stack dump
+func (h *AdminHandler) Goroutines(c fiber.Ctx) error {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 2); err != nil {
+		return err
+	}
+
+	c.Set("Content-Type", "text/plain; charset=utf-8")
+	return c.Send(buf.Bytes())
+}
+
+// Runtime handles GET /admin/runtime with memory, GC and pool counters
+func (h *AdminHandler) Runtime(c fiber.Ctx) error {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	resp := fiber.Map{
+		"uptime":      time.Since(h.startedAt).Round(time.Second).String(),
+		"goroutines":  runtime.NumGoroutine(),
+		"gomaxprocs":  runtime.GOMAXPROCS(0),
+		"heap_alloc":  mem.HeapAlloc,
+		"heap_inuse":  mem.HeapInuse,
+		"heap_sys":    mem.HeapSys,
+		"sys":         mem.Sys,
+		"num_gc":      mem.NumGC,
+		"gc_pause_ns": mem.PauseNs[(mem.NumGC+255)%256],
+		"buffer_pool": h.bufferPool.GetStats(),
+	}
+	// Lets integration tests confirm their failures were actually injected
+	if chaos.Enabled() {
+		resp["chaos_injected"] = chaos.Injected()
+	}
+	return c.JSON(resp)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/objectstore"
+	"fingerprint-converter/internal/storage"
+)
+
+// Archival keeps outputs requested with archive in an S3 prefix once their
+// temp TTL ends, and serves them under a URL that stays the same. How long
+// they are kept there is up to the bucket's lifecycle rules
+type Archival struct {
+	objects     *objectstore.S3
+	bucket      string
+	prefix      string
+	linkTTL     time.Duration // Validity of the presigned URLs redirected to
+	tempStorage *storage.TempStorage
+}
+
+// NewArchival archives into destination ("s3://bucket/prefix") through objects
+func NewArchival(objects *objectstore.S3, destination string, linkTTL time.Duration, tempStorage *storage.TempStorage) (*Archival, error) {
+	bucket, prefix, err := objectstore.ParseURL(destination)
+	if err != nil {
+		return nil, err
+	}
+	if linkTTL <= 0 {
+		linkTTL = 15 * time.Minute
+	}
+	return &Archival{
+		objects:     objects,
+		bucket:      bucket,
+		prefix:      prefix,
+		linkTTL:     linkTTL,
+		tempStorage: tempStorage,
+	}, nil
+}
+
+// Upload copies an expiring file to the archive under its file ID and
+// extension. It is the temp storage's archiver
+func (a *Archival) Upload(tf *storage.TempFile) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	return a.objects.PutFile(ctx, a.bucket, a.key(tf.ID+filepath.Ext(tf.Path)), tf.Path, getContentTypeFromPath(tf.Path))
+}
+
+// key is the object key of an archived file name
+func (a *Archival) key(name string) string {
+	return strings.TrimPrefix(path.Join(a.prefix, name), "/")
+}
+
+// Get handles GET /api/archived/:id, where :id is the file ID and extension
+// Files still in temp storage are served from there, archived ones from S3
+func (a *Archival) Get(c fiber.Ctx) error {
+	name := c.Params("id")
+	ext := filepath.Ext(name)
+	fileID := strings.TrimSuffix(name, ext)
+	if !storedFileID.MatchString(fileID) || !storedFileExt.MatchString(ext) {
+		return c.Status(fiber.StatusNotFound).SendString("File not found")
+	}
+
+	if _, err := a.tempStorage.Get(fileID); err == nil {
+		return c.Redirect().Status(fiber.StatusFound).To("/api/files/" + name)
+	}
+	c.Set("Cache-Control", "no-store")
+	return c.Redirect().Status(fiber.StatusFound).To(a.objects.PresignGet(a.bucket, a.key(name), a.linkTTL))
+}
+
+// archiveOutputs marks every output of resp for archival and fills in its
+// archive URLs
+func (h *ProcessHandler) archiveOutputs(resp *models.ProcessResponse) {
+	archive := func(fileID string) string {
+		tf, err := h.tempStorage.Get(fileID)
+		if err != nil || !h.tempStorage.MarkArchive(fileID) {
+			return ""
+		}
+		return fmt.Sprintf("%s/api/archived/%s%s", h.baseURL, fileID, filepath.Ext(tf.Path))
+	}
+
+	if resp.FileID != "" {
+		resp.ArchiveURL = archive(resp.FileID)
+	}
+	for i := range resp.Pages {
+		resp.Pages[i].ArchiveURL = archive(resp.Pages[i].FileID)
+	}
+	for i := range resp.Entries {
+		if resp.Entries[i].FileID != "" {
+			resp.Entries[i].ArchiveURL = archive(resp.Entries[i].FileID)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/objectstore"
+)
+
+func TestArchivedOutputKeepsItsURL(t *testing.T) {
+	png, err := os.ReadFile(filepath.Join("testdata", "tiny.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(png)
+	}))
+	defer source.Close()
+	bucket := &fakeBucket{objects: map[string][]byte{}}
+	s3 := httptest.NewServer(bucket)
+	defer s3.Close()
+	objects, err := objectstore.NewS3(s3.URL, "", "AKID", "secret", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, ts := newTestProcessHandler(t, &fakeConverter{mediaType: "image", output: "processed image"})
+
+	req := &models.ProcessRequest{Arquivo: source.URL + "/a.png", Archive: true}
+	if status, _ := h.process(context.Background(), req, nil); status != fiber.StatusBadRequest {
+		t.Fatalf("archive without archival = %d, want 400", status)
+	}
+
+	archival, err := NewArchival(objects, "s3://media/archive", time.Minute, ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.SetArchiver(archival.Upload)
+	h.SetArchival(archival)
+
+	req = &models.ProcessRequest{Arquivo: source.URL + "/a.png", Archive: true}
+	status, resp := h.process(context.Background(), req, nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("process = %d: %s", status, resp.Message)
+	}
+	tf, err := ts.Get(resp.FileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := resp.FileID + filepath.Ext(tf.Path)
+	if resp.ArchiveURL != "http://files/api/archived/"+name {
+		t.Fatalf("archive_url = %q", resp.ArchiveURL)
+	}
+	if !tf.Archive {
+		t.Error("output not marked for archival")
+	}
+
+	app := fiber.New()
+	app.Get("/api/archived/:id", archival.Get)
+	location := func() string {
+		t.Helper()
+		res, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/archived/"+name, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != fiber.StatusFound {
+			t.Fatalf("GET archived = %d", res.StatusCode)
+		}
+		return res.Header.Get("Location")
+	}
+
+	// Served from temp storage until it expires
+	if got := location(); got != "/api/files/"+name {
+		t.Errorf("before expiry Location = %q", got)
+	}
+
+	if err := archival.Upload(tf); err != nil {
+		t.Fatal(err)
+	}
+	ts.Delete(resp.FileID)
+	if len(bucket.uploaded) != 1 || bucket.uploaded[0] != "archive/"+name {
+		t.Fatalf("archived objects = %v", bucket.uploaded)
+	}
+	got := location()
+	if !strings.HasPrefix(got, s3.URL+"/media/archive/"+name+"?") || !strings.Contains(got, "X-Amz-Signature=") {
+		t.Errorf("after expiry Location = %q", got)
+	}
+}
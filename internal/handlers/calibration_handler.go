@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/services"
+)
+
+// maxCalibrationRuns bounds ?runs= so a calibration can't monopolize the host
+const maxCalibrationRuns = 20
+
+// CalibrationHandler measures converter throughput on this host
+type CalibrationHandler struct {
+	registry *services.Registry
+	store    *services.CalibrationStore
+	workers  int
+	runs     int
+	timeout  time.Duration
+	running  atomic.Bool
+}
+
+// NewCalibrationHandler creates a new calibration handler
+func NewCalibrationHandler(registry *services.Registry, store *services.CalibrationStore, workers, runs int, timeout time.Duration) *CalibrationHandler {
+	return &CalibrationHandler{
+		registry: registry,
+		store:    store,
+		workers:  workers,
+		runs:     runs,
+		timeout:  timeout,
+	}
+}
+
+// Run handles POST /admin/calibration?runs=N
+// Runs synchronously; only one calibration may run at a time
+func (h *CalibrationHandler) Run(c fiber.Ctx) error {
+	runs := h.runs
+	if raw := c.Query("runs"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxCalibrationRuns {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "runs must be between 1 and " + strconv.Itoa(maxCalibrationRuns),
+			})
+		}
+		runs = n
+	}
+
+	if !h.running.CompareAndSwap(false, true) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"error":   "calibration already running",
+		})
+	}
+	defer h.running.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	result, err := services.Calibrate(ctx, h.registry, runs, h.workers)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+	if err := h.store.Save(result); err != nil {
+		httpLog.Warnf("⚠️  Failed to persist calibration: %v", err)
+	}
+	return c.JSON(result)
+}
+
+// Latest handles GET /admin/calibration with the last stored result
+func (h *CalibrationHandler) Latest(c fiber.Ctx) error {
+	result := h.store.Latest()
+	if result == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "no calibration has been run",
+		})
+	}
+	return c.JSON(result)
+}
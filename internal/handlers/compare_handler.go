@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/services"
+	"fingerprint-converter/internal/storage"
+)
+
+// CompareHandler runs the configured similarity engines on two uploaded files
+type CompareHandler struct {
+	comparator  *services.Comparator
+	tempStorage *storage.TempStorage
+}
+
+// NewCompareHandler creates a new compare handler
+func NewCompareHandler(comparator *services.Comparator, tempStorage *storage.TempStorage) *CompareHandler {
+	return &CompareHandler{comparator: comparator, tempStorage: tempStorage}
+}
+
+// Compare handles POST /api/compare with the files as multipart "original"
+// and "variant" fields and an optional "media_type" field
+func (h *CompareHandler) Compare(c fiber.Ctx) error {
+	originalHeader, err := c.FormFile("original")
+	if err != nil {
+		return compareError(c, fiber.StatusBadRequest, "Send the files as multipart \"original\" and \"variant\" fields", "")
+	}
+	variantHeader, err := c.FormFile("variant")
+	if err != nil {
+		return compareError(c, fiber.StatusBadRequest, "Send the files as multipart \"original\" and \"variant\" fields", "")
+	}
+
+	originalData, err := readFormFile(originalHeader)
+	if err != nil {
+		return compareError(c, fiber.StatusBadRequest, "Failed to read original: "+err.Error(), "")
+	}
+	variantData, err := readFormFile(variantHeader)
+	if err != nil {
+		return compareError(c, fiber.StatusBadRequest, "Failed to read variant: "+err.Error(), "")
+	}
+
+	// Audio is not recognized by content, so the media type can be given
+	mediaType := c.FormValue("media_type")
+	if mediaType == "" {
+		var variantType string
+		mediaType, _ = services.SniffMedia(originalData)
+		variantType, _ = services.SniffMedia(variantData)
+		if mediaType == "" || variantType == "" {
+			return compareError(c, fiber.StatusBadRequest, "Unrecognized file content; set media_type", "unsupported_media")
+		}
+		if mediaType != variantType {
+			return compareError(c, fiber.StatusBadRequest, "original is "+mediaType+" but variant is "+variantType, "media_type_mismatch")
+		}
+	}
+	if !h.comparator.Supports(mediaType) {
+		return compareError(c, fiber.StatusUnprocessableEntity, "No similarity engine is enabled for "+mediaType, "no_engine")
+	}
+
+	job, err := h.tempStorage.NewJobDir(mediaType)
+	if err != nil {
+		return compareError(c, fiber.StatusInternalServerError, "Failed to prepare comparison", "")
+	}
+	defer job.Close()
+
+	originalPath := job.Path("original" + uploadExtension(originalHeader))
+	variantPath := job.Path("variant" + uploadExtension(variantHeader))
+	if err := os.WriteFile(originalPath, originalData, 0644); err != nil {
+		return compareError(c, fiber.StatusInternalServerError, "Failed to prepare comparison", "")
+	}
+	if err := os.WriteFile(variantPath, variantData, 0644); err != nil {
+		return compareError(c, fiber.StatusInternalServerError, "Failed to prepare comparison", "")
+	}
+
+	results := h.comparator.Compare(c.Context(), mediaType, originalPath, variantPath)
+	return c.JSON(models.CompareResponse{
+		Success:   true,
+		MediaType: mediaType,
+		Verdict:   services.OverallVerdict(results),
+		Results:   toSimilarityResults(results),
+	})
+}
+
+func readFormFile(header *multipart.FileHeader) ([]byte, error) {
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// uploadExtension is the extension of an uploaded file name, if it looks like one
+func uploadExtension(header *multipart.FileHeader) string {
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if len(ext) < 2 || len(ext) > 5 || strings.ContainsAny(ext[1:], "./\\") {
+		return ""
+	}
+	return ext
+}
+
+func compareError(c fiber.Ctx, status int, message, code string) error {
+	return c.Status(status).JSON(models.ProcessResponse{
+		Success: false,
+		Message: message,
+		Code:    code,
+	})
+}
+
+// compareOutput runs the similarity engines on a processed output and returns
+// the results, the overall verdict and the pHash distance when phash ran
+func compareOutput(ctx context.Context, comparator *services.Comparator, mediaType, originalPath, outputPath string) ([]models.SimilarityResult, string, *int) {
+	results := comparator.Compare(ctx, mediaType, originalPath, outputPath)
+	var phashDistance *int
+	for _, r := range results {
+		if r.Engine == "phash" && r.Verdict != services.VerdictError {
+			distance := int(r.Score)
+			phashDistance = &distance
+		}
+		if r.Verdict == services.VerdictError {
+			httpLog.Warnf("⚠️  Compare: %s failed: %s", r.Engine, r.Error)
+		}
+	}
+	return toSimilarityResults(results), services.OverallVerdict(results), phashDistance
+}
+
+func toSimilarityResults(results []services.SimilarityResult) []models.SimilarityResult {
+	out := make([]models.SimilarityResult, len(results))
+	for i, r := range results {
+		out[i] = models.SimilarityResult{
+			Engine:     r.Engine,
+			Score:      r.Score,
+			Similarity: r.Similarity,
+			Threshold:  r.Threshold,
+			Verdict:    r.Verdict,
+			Error:      r.Error,
+		}
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/services"
+	"fingerprint-converter/internal/storage"
+)
+
+func newCompareApp(t *testing.T) *fiber.App {
+	t.Helper()
+	tempStorage := storage.NewTempStorage(t.TempDir(), time.Minute)
+	t.Cleanup(tempStorage.Stop)
+
+	configs, err := services.ParseSimilarityConfig([]string{"phash", "blockhash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comparator, _ := services.NewComparator(configs)
+	app := fiber.New()
+	app.Post("/api/compare", NewCompareHandler(comparator, tempStorage).Compare)
+	return app
+}
+
+// postCompare uploads the testdata fixtures as original and variant
+func postCompare(t *testing.T, app *fiber.App, original, variant, mediaType string) (int, []byte) {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for field, name := range map[string]string{"original": original, "variant": variant} {
+		if name == "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		part, _ := w.CreateFormFile(field, name)
+		part.Write(data)
+	}
+	if mediaType != "" {
+		w.WriteField("media_type", mediaType)
+	}
+	w.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/compare", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	resp, err := app.Test(req, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out bytes.Buffer
+	out.ReadFrom(resp.Body)
+	return resp.StatusCode, out.Bytes()
+}
+
+func TestCompareIdenticalImages(t *testing.T) {
+	app := newCompareApp(t)
+
+	status, body := postCompare(t, app, "tiny.png", "tiny.png", "")
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d: %s", status, body)
+	}
+	var resp models.CompareResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.MediaType != "image" || resp.Verdict != services.VerdictFail || len(resp.Results) != 2 {
+		t.Fatalf("response = %+v", resp)
+	}
+	for _, r := range resp.Results {
+		if r.Similarity != 1 || r.Score != 0 {
+			t.Errorf("%s on identical images: %+v", r.Engine, r)
+		}
+	}
+}
+
+func TestCompareRejections(t *testing.T) {
+	app := newCompareApp(t)
+
+	tests := []struct {
+		original, variant, mediaType string
+		status                       int
+		code                         string
+	}{
+		{"tiny.png", "", "", fiber.StatusBadRequest, ""},
+		{"tiny.png", "tiny.opus", "", fiber.StatusBadRequest, "unsupported_media"},
+		{"tiny.opus", "tiny.opus", "audio", fiber.StatusUnprocessableEntity, "no_engine"},
+	}
+	for _, tt := range tests {
+		status, body := postCompare(t, app, tt.original, tt.variant, tt.mediaType)
+		var resp models.ProcessResponse
+		json.Unmarshal(body, &resp)
+		if status != tt.status || resp.Code != tt.code || resp.Success {
+			t.Errorf("%s/%s: status = %d, code = %q, want %d %q", tt.original, tt.variant, status, resp.Code, tt.status, tt.code)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/valyala/fasthttp"
+)
+
+// CompressText compresses text responses such as JSON and HTML. Media,
+// archives and streamed bodies are sent as they are: they are already
+// compressed, and compressing a stream would hold back its chunks
+func CompressText() fiber.Handler {
+	compressor := fasthttp.CompressHandlerBrotliLevel(func(*fasthttp.RequestCtx) {},
+		fasthttp.CompressBrotliBestSpeed,
+		fasthttp.CompressBestSpeed,
+	)
+	return func(c fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if c.Response().IsBodyStream() || !isTextContentType(string(c.Response().Header.ContentType())) {
+			return nil
+		}
+		compressor(c.Context())
+		return nil
+	}
+}
+
+// isTextContentType reports whether contentType is text worth compressing
+func isTextContentType(contentType string) bool {
+	mime, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mime = strings.TrimSpace(mime)
+	switch {
+	case strings.HasPrefix(mime, "text/"),
+		strings.HasSuffix(mime, "+json"),
+		strings.HasSuffix(mime, "+xml"):
+		return true
+	}
+	switch mime {
+	case fiber.MIMEApplicationJSON, fiber.MIMEApplicationXML, fiber.MIMEApplicationJavaScript, "application/yaml":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestCompressText(t *testing.T) {
+	text := strings.Repeat(`{"success": true}`, 200)
+	media := bytes.Repeat([]byte{0x00, 0xff}, 2000)
+
+	app := fiber.New()
+	app.Use(CompressText())
+	app.Get("/json", func(c fiber.Ctx) error {
+		c.Set("Content-Type", fiber.MIMEApplicationJSONCharsetUTF8)
+		return c.SendString(text)
+	})
+	app.Get("/zip", func(c fiber.Ctx) error {
+		c.Set("Content-Type", "application/zip")
+		return c.Send(media)
+	})
+	app.Get("/stream", func(c fiber.Ctx) error {
+		c.Set("Content-Type", "text/plain")
+		return c.SendStream(bytes.NewReader([]byte(text)))
+	})
+
+	for path, wantEncoding := range map[string]string{"/json": "gzip", "/zip": "", "/stream": ""} {
+		req := httptest.NewRequest(fiber.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := app.Test(req, 10*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if got := resp.Header.Get("Content-Encoding"); got != wantEncoding {
+			t.Errorf("%s: Content-Encoding = %q, want %q", path, got, wantEncoding)
+		}
+		if wantEncoding == "" && len(body) != len(text) && !bytes.Equal(body, media) {
+			t.Errorf("%s: body altered (%d bytes)", path, len(body))
+		}
+	}
+}
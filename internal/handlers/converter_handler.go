@@ -6,9 +6,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -18,6 +16,7 @@ import (
 	"fingerprint-converter/internal/cache"
 	"fingerprint-converter/internal/models"
 	"fingerprint-converter/internal/pool"
+	"fingerprint-converter/internal/redact"
 	"fingerprint-converter/internal/services"
 	"fingerprint-converter/internal/storage"
 )
@@ -115,13 +114,13 @@ func (h *ConverterHandler) Convert(c fiber.Ctx) error {
 				Details: "Supported extensions: audio (.mp3,.opus,.ogg,.m4a,.wav,.aac), image (.jpg,.jpeg,.png,.webp,.gif), video (.mp4,.avi,.mov,.mkv,.webm,.flv)",
 			})
 		}
-		log.Printf("🔍 Auto-detected media type: %s from URL: %s", req.MediaType, truncateURL(req.URL))
+		httpLog.Infof("🔍 Auto-detected media type: %s from URL: %s", req.MediaType, truncateURL(req.URL))
 	}
 
 	// Set default anti-fingerprint level if not provided
 	if req.AntiFingerprintLevel == "" {
 		req.AntiFingerprintLevel = getDefaultAFLevel(req.MediaType)
-		log.Printf("🎯 Using default AF level: %s for media type: %s", req.AntiFingerprintLevel, req.MediaType)
+		httpLog.Infof("🎯 Using default AF level: %s for media type: %s", req.AntiFingerprintLevel, req.MediaType)
 	}
 
 	// Check cache first (unless unique processing was requested)
@@ -131,8 +130,8 @@ func (h *ConverterHandler) Convert(c fiber.Ctx) error {
 			// Cache hit - return cached file
 			fileInfo, err := os.Stat(cachedEntry.ProcessedPath)
 			if err == nil {
-				log.Printf("✅ CACHE HIT: device=%s, url=%s, path=%s",
-					req.DeviceID, truncateURL(req.URL), cachedEntry.ProcessedPath)
+				httpLog.Infof("✅ CACHE HIT: device=%s, url=%s, path=%s",
+					req.DeviceID, truncateURL(req.URL), redact.Path(cachedEntry.ProcessedPath))
 
 				// If download mode, return file stream
 				if downloadMode {
@@ -157,10 +156,10 @@ func (h *ConverterHandler) Convert(c fiber.Ctx) error {
 
 	// Cache miss or unique requested - process file
 	if uniqueMode {
-		log.Printf("⚡ UNIQUE MODE: device=%s, url=%s, forcing reprocess...",
+		httpLog.Infof("⚡ UNIQUE MODE: device=%s, url=%s, forcing reprocess...",
 			req.DeviceID, truncateURL(req.URL))
 	} else {
-		log.Printf("⚡ CACHE MISS: device=%s, url=%s, processing...",
+		httpLog.Infof("⚡ CACHE MISS: device=%s, url=%s, processing...",
 			req.DeviceID, truncateURL(req.URL))
 	}
 
@@ -199,11 +198,11 @@ func (h *ConverterHandler) Convert(c fiber.Ctx) error {
 	mediaSubdir := getMediaSubdir(req.MediaType)
 	mediaCacheDir := filepath.Join(h.cacheDir, mediaSubdir)
 
-	log.Printf("📁 Creating directory: %s", mediaCacheDir)
+	httpLog.Infof("📁 Creating directory: %s", mediaCacheDir)
 
 	// Ensure media subdirectory exists
 	if err := os.MkdirAll(mediaCacheDir, 0755); err != nil {
-		log.Printf("❌ Failed to create directory %s: %v", mediaCacheDir, err)
+		httpLog.Errorf("❌ Failed to create directory %s: %v", mediaCacheDir, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Success: false,
 			Error:   "Failed to create media cache directory",
@@ -211,7 +210,7 @@ func (h *ConverterHandler) Convert(c fiber.Ctx) error {
 		})
 	}
 
-	log.Printf("✅ Directory ready: %s", mediaCacheDir)
+	httpLog.Infof("✅ Directory ready: %s", mediaCacheDir)
 
 	// Generate output path in media-specific subdirectory
 	var outputPath string
@@ -236,11 +235,11 @@ func (h *ConverterHandler) Convert(c fiber.Ctx) error {
 		switch req.MediaType {
 		case "audio":
 			// For audio script techniques, try to preserve input format if possible (blank will default)
-			err = h.audioConverter.ConvertWithScriptTechniques(ctx, inputData, outputPath, "")
+			err = h.audioConverter.ConvertWithScriptTechniques(ctx, inputData, outputPath, "", services.ProcessOptions{})
 		case "image":
-			err = h.imageConverter.ConvertWithScriptTechniques(ctx, inputData, outputPath)
+			err = h.imageConverter.ConvertWithScriptTechniques(ctx, inputData, outputPath, services.ProcessOptions{})
 		case "video":
-			err = h.videoConverter.ConvertWithScriptTechniques(ctx, inputData, outputPath)
+			_, err = h.videoConverter.ConvertWithScriptTechniques(ctx, inputData, outputPath, services.ProcessOptions{})
 		}
 	} else {
 		switch req.MediaType {
@@ -277,10 +276,10 @@ func (h *ConverterHandler) Convert(c fiber.Ctx) error {
 	// Store in cache (skip when uniqueMode requested)
 	if !uniqueMode {
 		if err := h.cache.Set(req.DeviceID, req.URL, outputPath, req.MediaType, processedSize); err != nil {
-			log.Printf("⚠️  Failed to cache file: %v", err)
+			httpLog.Warnf("⚠️  Failed to cache file: %v", err)
 		}
 	} else {
-		log.Printf("ℹ️  Skipping cache due to unique processing: device=%s, url=%s", req.DeviceID, truncateURL(req.URL))
+		httpLog.Infof("ℹ️  Skipping cache due to unique processing: device=%s, url=%s", req.DeviceID, truncateURL(req.URL))
 	}
 
 	// Get cache entry for expiration times
@@ -292,7 +291,7 @@ func (h *ConverterHandler) Convert(c fiber.Ctx) error {
 		fileExpires = cacheEntry.FileExpires.Format(time.RFC3339)
 	}
 
-	log.Printf("✅ PROCESSED: device=%s, type=%s, level=%s, size=%d→%d (+%.1f%%), time=%dms",
+	httpLog.Infof("✅ PROCESSED: device=%s, type=%s, level=%s, size=%d→%d (+%.1f%%), time=%dms",
 		req.DeviceID, req.MediaType, req.AntiFingerprintLevel,
 		originalSize, processedSize, sizeIncrease, time.Since(processingStart).Milliseconds())
 
@@ -307,7 +306,7 @@ func (h *ConverterHandler) Convert(c fiber.Ctx) error {
 		if id, err := h.tempStorage.Store(outputPath, "", req.MediaType); err == nil {
 			processedURL = fmt.Sprintf("%s/api/files/%s%s", h.baseURL, id, filepath.Ext(outputPath))
 		} else {
-			log.Printf("⚠️ Failed to store processed file in temp storage: %v", err)
+			httpLog.Warnf("⚠️ Failed to store processed file in temp storage: %v", err)
 		}
 	}
 
@@ -351,14 +350,8 @@ func (h *ConverterHandler) GetCacheStats(c fiber.Ctx) error {
 
 // Health handles GET /api/health
 func (h *ConverterHandler) Health(c fiber.Ctx) error {
-	// Check FFmpeg availability
-	ffmpegVersion := "unknown"
-	if output, err := exec.Command("ffmpeg", "-version").Output(); err == nil {
-		lines := strings.Split(string(output), "\n")
-		if len(lines) > 0 {
-			ffmpegVersion = strings.TrimSpace(lines[0])
-		}
-	}
+	// Toolchain is probed once per process, not per health check
+	ffmpegVersion := services.DetectCapabilities().FFmpegVersion
 
 	workerStats := h.workerPool.GetStats()
 	bufferStats := h.bufferPool.GetStats()
@@ -487,7 +480,7 @@ func (h *ConverterHandler) sendFile(c fiber.Ctx, filePath, mediaType string) err
 
 	// Set headers
 	c.Set("Content-Type", contentType)
-	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	c.Set("Content-Disposition", contentDisposition(fileName))
 
 	// Send file
 	return c.SendFile(filePath)
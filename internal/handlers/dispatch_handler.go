@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/jobs"
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/storage"
+	"fingerprint-converter/internal/tenant"
+)
+
+// Worker node authentication, sent with every request to the dispatcher
+const (
+	WorkerTokenHeader = "X-Worker-Token"
+	WorkerNameHeader  = "X-Worker-Name" // Worker holding the lease (file uploads)
+)
+
+var (
+	// storedFileID matches the IDs TempStorage assigns
+	storedFileID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	// storedFileExt matches the extensions of converter outputs
+	storedFileExt = regexp.MustCompile(`^\.[a-z0-9]{1,5}$`)
+)
+
+// DispatchHandler hands due jobs to worker nodes and stores what they
+// produce, so nova_url and manifests are served by the dispatcher
+type DispatchHandler struct {
+	scheduler   *jobs.Scheduler
+	tempStorage *storage.TempStorage
+	tenants     *tenant.Store // Resolves the TTL of uploaded files (nil = no tenants)
+	token       string
+}
+
+// NewDispatchHandler creates a dispatch handler for workers presenting token
+func NewDispatchHandler(scheduler *jobs.Scheduler, tempStorage *storage.TempStorage, tenants *tenant.Store, token string) *DispatchHandler {
+	return &DispatchHandler{
+		scheduler:   scheduler,
+		tempStorage: tempStorage,
+		tenants:     tenants,
+		token:       token,
+	}
+}
+
+// RequireToken rejects requests without the worker token
+func (h *DispatchHandler) RequireToken(c fiber.Ctx) error {
+	provided := c.Get(WorkerTokenHeader)
+	if h.token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(h.token)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"error":   "worker token required",
+		})
+	}
+	return c.Next()
+}
+
+// Lease handles POST /internal/jobs/lease, answering 204 when no job is due
+func (h *DispatchHandler) Lease(c fiber.Ctx) error {
+	var req models.WorkLeaseRequest
+	if err := c.Bind().JSON(&req); err != nil || req.Worker == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "worker is required",
+		})
+	}
+
+	lease, ok := h.scheduler.Lease(req.Worker)
+	if !ok {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+	return c.JSON(lease)
+}
+
+// Heartbeat handles POST /internal/jobs/:id/heartbeat
+func (h *DispatchHandler) Heartbeat(c fiber.Ctx) error {
+	var req models.WorkHeartbeat
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "invalid heartbeat",
+		})
+	}
+
+	until, err := h.scheduler.Renew(c.Params("id"), req.Worker, req.Progress, req.Events)
+	if err != nil {
+		return leaseLost(c, err)
+	}
+	return c.JSON(fiber.Map{"success": true, "lease_until": until})
+}
+
+// UploadFile handles PUT /internal/jobs/:id/files/:file, storing an output
+// under the file ID the worker assigned. :file is "<file id><extension>"
+func (h *DispatchHandler) UploadFile(c fiber.Ctx) error {
+	id := c.Params("id")
+	if !h.scheduler.Leased(id, c.Get(WorkerNameHeader)) {
+		return leaseLost(c, jobs.ErrLeaseLost)
+	}
+
+	// Fiber reuses the request's memory; the stored file outlives it
+	name := strings.Clone(c.Params("file"))
+	ext := filepath.Ext(name)
+	fileID := name[:len(name)-len(ext)]
+	mediaType := strings.Clone(c.Query("media_type"))
+	if !storedFileID.MatchString(fileID) || !storedFileExt.MatchString(ext) || mediaType == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "invalid file name or media type",
+		})
+	}
+
+	if _, err := h.tempStorage.Get(fileID); err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"error":   "file already stored",
+		})
+	}
+
+	job, _ := h.scheduler.Get(id)
+	var ttl time.Duration
+	if job.Tenant != "" && h.tenants != nil {
+		if t, ok := h.tenants.ByName(job.Tenant); ok {
+			ttl = t.FileTTL
+		}
+	}
+
+	path := filepath.Join(h.tempStorage.Dir(mediaType), fileID+ext)
+	if err := os.WriteFile(path, c.Body(), 0644); err != nil {
+		httpLog.Errorf("❌ Failed to save worker output: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "failed to save file",
+		})
+	}
+	if err := h.tempStorage.StoreAs(fileID, path, "", mediaType, job.Tenant, ttl); err != nil {
+		os.Remove(path)
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+	if job.RequestID != "" {
+		h.tempStorage.SetRequestID(fileID, job.RequestID)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"success": true, "file_id": fileID})
+}
+
+// Complete handles POST /internal/jobs/:id/complete once the worker has
+// uploaded every file the result refers to
+func (h *DispatchHandler) Complete(c fiber.Ctx) error {
+	var req models.WorkResult
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "invalid result",
+		})
+	}
+
+	id := c.Params("id")
+	if err := h.scheduler.Complete(id, req.Worker, req.HTTPStatus, req.Result, req.Events); err != nil {
+		return leaseLost(c, err)
+	}
+
+	// Uploads are stored like any file; archival follows the request
+	if job, ok := h.scheduler.Get(id); ok && job.Request.Archive && req.Result.Success {
+		for _, fileID := range outputFileIDs(req.Result) {
+			h.tempStorage.MarkArchive(fileID)
+		}
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// leaseLost tells a worker to abandon a job it no longer holds
+func leaseLost(c fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	if errors.Is(err, jobs.ErrLeaseLost) {
+		status = fiber.StatusConflict
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/jobs"
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/storage"
+)
+
+// appTransport sends a client's requests straight to a fiber app
+type appTransport struct{ app *fiber.App }
+
+func (t appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.app.Test(req, 10*time.Second)
+}
+
+// newDispatcher serves the worker routes of a remote scheduler
+func newDispatcher(t *testing.T) (*fiber.App, *jobs.Scheduler, *storage.TempStorage) {
+	t.Helper()
+	scheduler, err := jobs.NewScheduler("", 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.StartRemote(time.Minute)
+	t.Cleanup(scheduler.Stop)
+	ts := storage.NewTempStorage(t.TempDir(), time.Minute)
+	t.Cleanup(ts.Stop)
+
+	h := NewDispatchHandler(scheduler, ts, nil, "secret")
+	app := fiber.New()
+	internal := app.Group("/internal", h.RequireToken)
+	internal.Post("/jobs/lease", h.Lease)
+	internal.Post("/jobs/:id/heartbeat", h.Heartbeat)
+	internal.Put("/jobs/:id/files/:file", h.UploadFile)
+	internal.Post("/jobs/:id/complete", h.Complete)
+	return app, scheduler, ts
+}
+
+// newTestWorker runs jobs with a fake image converter against app
+func newTestWorker(t *testing.T, app *fiber.App, name string) (*Worker, *storage.TempStorage) {
+	t.Helper()
+	processHandler, ts := newTestProcessHandler(t, &fakeConverter{mediaType: "image", output: "processed image"})
+
+	scheduler, err := jobs.NewScheduler("", 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWorker(NewJobHandler(scheduler, processHandler, nil, 0, 0), "http://dispatcher/", "secret", name, 1, 10*time.Millisecond)
+	w.client = &http.Client{Transport: appTransport{app}}
+	return w, ts
+}
+
+func TestWorkerRunsLeasedJob(t *testing.T) {
+	png, err := os.ReadFile(filepath.Join("testdata", "tiny.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(png)
+	}))
+	defer source.Close()
+
+	app, scheduler, dispatcherStorage := newDispatcher(t)
+	worker, workerStorage := newTestWorker(t, app, "w1")
+
+	job := &models.Job{Request: models.ProcessRequest{Arquivo: source.URL + "/a.png"}}
+	if err := scheduler.Submit(job); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go worker.Run(ctx)
+
+	var done models.Job
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if done, _ = scheduler.Get(job.ID); done.Status != models.JobScheduled && done.Status != models.JobRunning {
+			break
+		}
+	}
+	if done.Status != models.JobSucceeded || done.Worker != "w1" || done.Result == nil {
+		t.Fatalf("job = %+v", done)
+	}
+
+	// The output is served by the dispatcher under the worker's file ID
+	tf, err := dispatcherStorage.Get(done.Result.FileID)
+	if err != nil {
+		t.Fatalf("output not on the dispatcher: %v", err)
+	}
+	if data, _ := os.ReadFile(tf.Path); string(data) != "processed image" || tf.MediaType != "image" {
+		t.Errorf("stored output = %q (%s)", data, tf.MediaType)
+	}
+	if _, err := workerStorage.Get(done.Result.FileID); err == nil {
+		t.Error("worker kept its copy of the output")
+	}
+}
+
+func TestDispatchRequiresTokenAndLease(t *testing.T) {
+	app, scheduler, _ := newDispatcher(t)
+
+	send := func(method, path, token string, body any) int {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(WorkerTokenHeader, token)
+		req.Header.Set(WorkerNameHeader, "w2")
+		resp, err := app.Test(req, 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := send(http.MethodPost, "/internal/jobs/lease", "wrong", models.WorkLeaseRequest{Worker: "w1"}); status != http.StatusUnauthorized {
+		t.Fatalf("lease with a wrong token = %d", status)
+	}
+	if status := send(http.MethodPost, "/internal/jobs/lease", "secret", models.WorkLeaseRequest{Worker: "w1"}); status != http.StatusNoContent {
+		t.Fatalf("lease with no due job = %d", status)
+	}
+
+	job := &models.Job{}
+	scheduler.Submit(job)
+	if status := send(http.MethodPost, "/internal/jobs/lease", "secret", models.WorkLeaseRequest{Worker: "w1"}); status != http.StatusOK {
+		t.Fatalf("lease = %d", status)
+	}
+
+	// w2 does not hold the lease
+	if status := send(http.MethodPost, "/internal/jobs/"+job.ID+"/heartbeat", "secret", models.WorkHeartbeat{Worker: "w2"}); status != http.StatusConflict {
+		t.Errorf("heartbeat by another worker = %d", status)
+	}
+	if status := send(http.MethodPut, "/internal/jobs/"+job.ID+"/files/0123456789abcdef0123456789abcdef.jpg?media_type=image", "secret", nil); status != http.StatusConflict {
+		t.Errorf("upload by another worker = %d", status)
+	}
+	if status := send(http.MethodPost, "/internal/jobs/"+job.ID+"/complete", "secret", models.WorkResult{Worker: "w2", HTTPStatus: 200}); status != http.StatusConflict {
+		t.Errorf("complete by another worker = %d", status)
+	}
+	if status := send(http.MethodPost, "/internal/jobs/"+job.ID+"/heartbeat", "secret", models.WorkHeartbeat{Worker: "w1"}); status != http.StatusOK {
+		t.Errorf("heartbeat by the lease holder = %d", status)
+	}
+}
+
+func TestOutputFileIDs(t *testing.T) {
+	resp := models.ProcessResponse{
+		FileID:   "a",
+		Pages:    []models.PageInfo{{FileID: "a"}, {FileID: "b"}},
+		Entries:  []models.ArchiveEntryInfo{{FileID: "c"}, {Name: "failed"}},
+		Variants: []models.VariantInfo{{FileID: "d"}},
+		Objects:  []models.ObjectInfo{{FileID: "e"}},
+	}
+	got, _ := json.Marshal(outputFileIDs(resp))
+	if string(got) != `["a","b","c","d","e"]` {
+		t.Errorf("outputFileIDs = %s", got)
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxDownloadNameLength caps client-supplied download names (in bytes)
+const maxDownloadNameLength = 200
+
+// transliterations maps common non-ASCII letters (Latin, Greek, Cyrillic) to ASCII
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE", 'ø': "o", 'Ø': "O",
+	'đ': "d", 'Đ': "D", 'ð': "d", 'Ð': "D", 'þ': "th", 'Þ': "Th", 'ł': "l", 'Ł': "L",
+	'ı': "i", '€': "EUR", '–': "-", '—': "-", '‘': "'", '’': "'", '“': "'", '”': "'",
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "iu", 'я': "ia",
+}
+
+// latinBase groups accented Latin letters by their unaccented base letter
+var latinBase = map[string]string{
+	"a": "àáâãäåāăą", "A": "ÀÁÂÃÄÅĀĂĄ",
+	"c": "çćĉċč", "C": "ÇĆĈĊČ",
+	"d": "ď", "D": "Ď",
+	"e": "èéêëēĕėęě", "E": "ÈÉÊËĒĔĖĘĚ",
+	"g": "ĝğġģ", "G": "ĜĞĠĢ",
+	"h": "ĥħ", "H": "ĤĦ",
+	"i": "ìíîïĩīĭį", "I": "ÌÍÎÏĨĪĬĮİ",
+	"j": "ĵ", "J": "Ĵ",
+	"k": "ķ", "K": "Ķ",
+	"l": "ĺļľŀ", "L": "ĹĻĽĿ",
+	"n": "ñńņňŉ", "N": "ÑŃŅŇ",
+	"o": "òóôõöōŏő", "O": "ÒÓÔÕÖŌŎŐ",
+	"r": "ŕŗř", "R": "ŔŖŘ",
+	"s": "śŝşš", "S": "ŚŜŞŠ",
+	"t": "ţťŧ", "T": "ŢŤŦ",
+	"u": "ùúûüũūŭůűų", "U": "ÙÚÛÜŨŪŬŮŰŲ",
+	"w": "ŵ", "W": "Ŵ",
+	"y": "ýÿŷ", "Y": "ÝŸŶ",
+	"z": "źżž", "Z": "ŹŻŽ",
+}
+
+func init() {
+	for base, letters := range latinBase {
+		for _, r := range letters {
+			if _, ok := transliterations[r]; !ok {
+				transliterations[r] = base
+			}
+		}
+	}
+	// Uppercase Greek and Cyrillic share the lowercase mapping, capitalized
+	upper := make(map[rune]string)
+	for r, s := range transliterations {
+		if u := unicode.ToUpper(r); u != r && s != "" {
+			upper[u] = strings.ToUpper(s[:1]) + s[1:]
+		}
+	}
+	for r, s := range upper {
+		if _, ok := transliterations[r]; !ok {
+			transliterations[r] = s
+		}
+	}
+}
+
+// contentDisposition builds an attachment header that is valid for any file
+// name: a transliterated ASCII filename for legacy clients plus the exact
+// UTF-8 name as an RFC 5987 filename* parameter when they differ
+func contentDisposition(name string) string {
+	fallback := asciiFilename(name)
+	if fallback == name {
+		return fmt.Sprintf("attachment; filename=\"%s\"", name)
+	}
+	return fmt.Sprintf("attachment; filename=\"%s\"; filename*=UTF-8''%s", fallback, rfc5987Encode(name))
+}
+
+// asciiFilename transliterates name to printable ASCII safe inside a quoted string
+func asciiFilename(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == '"' || r == '\\' || r == '/':
+			b.WriteByte('_')
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		default:
+			if s, ok := transliterations[r]; ok {
+				b.WriteString(s)
+			} else {
+				b.WriteByte('_')
+			}
+		}
+	}
+	return b.String()
+}
+
+// rfc5987Encode percent-encodes everything outside the RFC 5987 attr-char set
+func rfc5987Encode(s string) string {
+	const attrChars = "!#$&+-.^_`|~"
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte(attrChars, c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// downloadName picks the name a served file is saved as: the client-supplied
+// name (directory parts dropped, extension forced to match the file) or the stored name
+func downloadName(requested, storedPath string) string {
+	stored := filepath.Base(storedPath)
+	name := strings.TrimSpace(requested)
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" || name == "." || name == ".." || !utf8.ValidString(name) || strings.ContainsFunc(name, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return stored
+	}
+
+	ext := filepath.Ext(stored)
+	if !strings.EqualFold(filepath.Ext(name), ext) {
+		name += ext
+	}
+	for len(name) > maxDownloadNameLength {
+		_, size := utf8.DecodeLastRuneInString(strings.TrimSuffix(name, ext))
+		name = name[:len(name)-len(ext)-size] + ext
+	}
+	return name
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct{ name, want string }{
+		{"abc123.mp4", `attachment; filename="abc123.mp4"`},
+		{"Relatório Final.pdf", `attachment; filename="Relatorio Final.pdf"; filename*=UTF-8''Relat%C3%B3rio%20Final.pdf`},
+		{"Ковалёв.jpg", `attachment; filename="Kovalev.jpg"; filename*=UTF-8''%D0%9A%D0%BE%D0%B2%D0%B0%D0%BB%D1%91%D0%B2.jpg`},
+		{"写真.png", `attachment; filename="__.png"; filename*=UTF-8''%E5%86%99%E7%9C%9F.png`},
+		{`say "hi".mp3`, `attachment; filename="say _hi_.mp3"; filename*=UTF-8''say%20%22hi%22.mp3`},
+	}
+	for _, tt := range tests {
+		if got := contentDisposition(tt.name); got != tt.want {
+			t.Errorf("contentDisposition(%q)\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDownloadName(t *testing.T) {
+	const stored = "/tmp/media-cache/temp/abcdef123456.jpg"
+	tests := []struct{ requested, want string }{
+		{"", "abcdef123456.jpg"},
+		{"foto férias.jpg", "foto férias.jpg"},
+		{"foto.JPG", "foto.JPG"},
+		{"foto.png", "foto.png.jpg"},
+		{"../../etc/passwd", "passwd.jpg"},
+		{"a\r\nb", "abcdef123456.jpg"},
+	}
+	for _, tt := range tests {
+		if got := downloadName(tt.requested, stored); got != tt.want {
+			t.Errorf("downloadName(%q) = %q, want %q", tt.requested, got, tt.want)
+		}
+	}
+
+	long := downloadName(strings.Repeat("é", 300), stored)
+	if len(long) > maxDownloadNameLength || !strings.HasSuffix(long, ".jpg") {
+		t.Errorf("long name not capped: %d bytes", len(long))
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/services"
+)
+
+// maxFeedbackReason bounds the free-text reason stored with a flag
+const maxFeedbackReason = 200
+
+// FeedbackHandler takes reports of variants deduplicated downstream and feeds
+// them to the escalation policy
+type FeedbackHandler struct {
+	policy *services.EscalationPolicy
+}
+
+// NewFeedbackHandler creates a new feedback handler
+func NewFeedbackHandler(policy *services.EscalationPolicy) *FeedbackHandler {
+	return &FeedbackHandler{policy: policy}
+}
+
+// Submit handles POST /api/feedback
+func (h *FeedbackHandler) Submit(c fiber.Ctx) error {
+	var req models.FeedbackRequest
+	if err := c.Bind().JSON(&req); err != nil || req.FileID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "file_id is required",
+		})
+	}
+	if len(req.Reason) > maxFeedbackReason {
+		req.Reason = req.Reason[:maxFeedbackReason]
+	}
+
+	// Accept the ID with the extension of nova_url, like GetFile
+	fileID := req.FileID
+	if idx := strings.LastIndex(fileID, "."); idx > 0 {
+		fileID = fileID[:idx]
+	}
+
+	// Feedback on another tenant's output looks like an unknown file
+	d, ok := h.policy.Delivery(fileID)
+	if !ok || !ownsTenantResource(tenantFrom(c), d.Tenant) {
+		return unknownDelivery(c)
+	}
+
+	src, escalated, err := h.policy.Flag(fileID, req.Reason)
+	if err != nil {
+		return unknownDelivery(c) // Forgotten since the lookup
+	}
+
+	message := "Feedback recorded"
+	if escalated {
+		message = "Feedback recorded, techniques escalated for new variants of this source"
+	}
+	return c.JSON(models.FeedbackResponse{
+		Success:   true,
+		Message:   message,
+		Level:     src.Level,
+		MaxLevel:  h.policy.MaxLevel(),
+		Escalated: escalated,
+		Flags:     src.Flags,
+	})
+}
+
+func unknownDelivery(c fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(models.ProcessResponse{
+		Success: false,
+		Message: "File unknown or produced too long ago",
+		Code:    "unknown_file",
+	})
+}
+
+// List handles GET /admin/escalation
+func (h *FeedbackHandler) List(c fiber.Ctx) error {
+	return c.JSON(h.policy.Sources())
+}
+
+// Reset handles DELETE /admin/escalation/:source
+func (h *FeedbackHandler) Reset(c fiber.Ctx) error {
+	if !h.policy.Reset(c.Params("source")) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "unknown source",
+		})
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
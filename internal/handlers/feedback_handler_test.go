@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/services"
+)
+
+func TestFeedbackEndpoint(t *testing.T) {
+	policy, err := services.NewEscalationPolicy("", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := services.SourceKey("", []byte("input"))
+	policy.Record(services.Delivery{FileID: "abc123", Source: source, Profile: "standard"})
+	policy.Record(services.Delivery{FileID: "theirs", Source: source, Tenant: "acme"})
+
+	h := NewFeedbackHandler(policy)
+	app := fiber.New()
+	app.Post("/api/feedback", h.Submit)
+
+	post := func(body string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, "/api/feedback", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	if status, _ := post(`{}`); status != fiber.StatusBadRequest {
+		t.Errorf("no file_id: status = %d", status)
+	}
+	if status, out := post(`{"file_id": "nope"}`); status != fiber.StatusNotFound || out["code"] != "unknown_file" {
+		t.Errorf("unknown file: status = %d, body = %v", status, out)
+	}
+	// Outputs of a tenant are invisible without its key
+	if status, _ := post(`{"file_id": "theirs.jpg"}`); status != fiber.StatusNotFound {
+		t.Errorf("other tenant's file: status = %d", status)
+	}
+
+	status, out := post(`{"file_id": "abc123.jpg", "reason": "deduplicated"}`)
+	if status != fiber.StatusOK || out["escalated"] != true || out["level"] != float64(1) {
+		t.Fatalf("status = %d, body = %v", status, out)
+	}
+	if policy.Level(source) != 1 {
+		t.Errorf("policy level = %d", policy.Level(source))
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/redact"
+	"fingerprint-converter/internal/storage"
+)
+
+// FileHandler serves stored files. Serve-only nodes run it alone against
+// the shared storage volume, so delivery scales apart from processing
+type FileHandler struct {
+	tempStorage   *storage.TempStorage
+	sharedCaching bool // Let shared caches such as a CDN keep served files
+}
+
+// NewFileHandler creates a new file handler
+func NewFileHandler(tempStorage *storage.TempStorage) *FileHandler {
+	return &FileHandler{tempStorage: tempStorage}
+}
+
+// SetSharedCaching marks served files public, so a CDN pulling from this
+// handler caches them until they expire. Call before the handler serves requests
+func (h *FileHandler) SetSharedCaching(shared bool) {
+	h.sharedCaching = shared
+}
+
+// GetFile handles GET /api/files/:id
+func (h *FileHandler) GetFile(c fiber.Ctx) error {
+	fileIDWithExt := c.Params("id")
+	if fileIDWithExt == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("File ID is required")
+	}
+
+	// Remove extension from ID (e.g., "abc123.opus" -> "abc123")
+	fileID := fileIDWithExt
+	if idx := strings.LastIndex(fileIDWithExt, "."); idx > 0 {
+		fileID = fileIDWithExt[:idx]
+	}
+
+	httpLog.Infof("🔍 GetFile: id_with_ext=%s, id=%s", fileIDWithExt, fileID)
+
+	// Get file from storage
+	tf, err := h.tempStorage.Get(fileID)
+	if err != nil {
+		httpLog.Errorf("❌ GetFile: storage.Get failed: %v", err)
+		return c.Status(fiber.StatusNotFound).SendString("File not found or expired")
+	}
+
+	httpLog.Infof("📂 GetFile: found file path=%s", redact.Path(tf.Path))
+
+	// Stored files never change, so ID+size is a strong validator; answer
+	// conditional requests from retrying senders without touching the disk
+	etag := fmt.Sprintf("\"%s-%d\"", tf.ID, tf.Size)
+	lastModified := tf.CreatedAt.UTC().Truncate(time.Second)
+	c.Set("ETag", etag)
+	c.Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	scope := "private"
+	if h.sharedCaching {
+		scope = "public"
+	}
+	c.Set("Cache-Control", fmt.Sprintf("%s, max-age=%d, immutable", scope, int(time.Until(tf.ExpiresAt).Seconds())))
+
+	if isNotModified(c.Get("If-None-Match"), c.Get("If-Modified-Since"), etag, lastModified) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	// Check if file exists
+	if _, err := os.Stat(tf.Path); os.IsNotExist(err) {
+		httpLog.Errorf("❌ GetFile: file not found on disk: %s", redact.Path(tf.Path))
+		return c.Status(fiber.StatusNotFound).SendString("File not found on disk")
+	}
+
+	// Set appropriate content type based on file extension
+	contentType := getContentTypeFromPath(tf.Path)
+	c.Set("Content-Type", contentType)
+	c.Set("Content-Disposition", contentDisposition(downloadName(c.Query("name"), tf.Path)))
+
+	// Send file
+	return c.SendFile(tf.Path)
+}
+
+// Health handles GET /api/health on serve-only nodes, which run no converters
+func (h *FileHandler) Health(c fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status":       "healthy",
+		"timestamp":    time.Now().Format(time.RFC3339),
+		"role":         "files",
+		"temp_storage": h.tempStorage.GetStats(),
+	})
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"fingerprint-converter/internal/pool"
+	"fingerprint-converter/internal/services"
+	"fingerprint-converter/internal/storage"
+)
+
+// fakeConverter writes a fixed output, copying it to opts.Stream when live is set
+type fakeConverter struct {
+	mediaType string
+	output    string
+	live      bool
+}
+
+func (f *fakeConverter) MediaType() string { return f.mediaType }
+
+func (f *fakeConverter) Process(_ context.Context, _ []byte, outputPath, _ string, opts services.ProcessOptions) (*services.ProcessResult, error) {
+	if f.live && opts.Stream != nil {
+		if _, err := io.WriteString(opts.Stream, f.output); err != nil {
+			return nil, err
+		}
+	}
+	return &services.ProcessResult{}, os.WriteFile(outputPath, []byte(f.output), 0644)
+}
+
+func (f *fakeConverter) GetOutputExtension() string { return ".bin" }
+
+func (f *fakeConverter) GenerateOutputPath(cacheDir, _, _ string) string { return cacheDir }
+
+func (f *fakeConverter) GetStats() services.ConverterStats { return services.ConverterStats{} }
+
+// newTestProcessHandler returns a handler running converters over temp
+// storage that is stopped when the test ends
+func newTestProcessHandler(t *testing.T, converters ...services.Converter) (*ProcessHandler, *storage.TempStorage) {
+	t.Helper()
+	ts := storage.NewTempStorage(t.TempDir(), time.Minute)
+	t.Cleanup(ts.Stop)
+	downloader := services.NewDownloader(pool.NewBufferPool(1, 1024), 1<<20, 10*time.Second, 2, 0)
+	return NewProcessHandler(services.NewRegistry(converters...), downloader, ts, "http://files", time.Minute, services.ProcessOptions{}, false, nil, nil, nil), ts
+}
@@ -0,0 +1,232 @@
+package handlers
+
+// Integration tests run the real converters end to end: a fixture server
+// serves testdata, POST /api/process downloads, converts and stores it, and
+// the returned URL is fetched back through GET /api/files. They need ffmpeg.
+//
+// The checked-in fixtures are hand-built so they need no tools to regenerate:
+// tiny.png and tiny.jpg are a 32x32 gradient, tiny.mp3 is 1s of silent
+// MPEG-1 Layer III frames and tiny.opus 1s of Ogg Opus silence packets.
+// tiny.mp4 is rendered by ffmpeg when the tests start
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/pool"
+	"fingerprint-converter/internal/services"
+	"fingerprint-converter/internal/storage"
+)
+
+const integrationBaseURL = "http://files.test"
+
+// integrationEnv is a ProcessHandler wired like cmd/api, plus a fixture server
+type integrationEnv struct {
+	app      *fiber.App
+	fixtures *httptest.Server
+	dir      string // Files served by fixtures
+}
+
+func newIntegrationEnv(t *testing.T) *integrationEnv {
+	t.Helper()
+	for _, tool := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available, skipping integration test", tool)
+		}
+	}
+
+	dir := t.TempDir()
+	copyFixtures(t, dir)
+	renderVideoFixture(t, filepath.Join(dir, "tiny.mp4"))
+	// Named like a video but not one, to exercise the invalid input path
+	if err := os.WriteFile(filepath.Join(dir, "garbage.mp4"), bytes.Repeat([]byte{0x00, 0x01, 0xFE, 0x7F}, 192), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A CDN error page served with status 200 under a media name
+	if err := os.WriteFile(filepath.Join(dir, "error-page.mp3"), []byte("<!DOCTYPE html><html><body><h1>Access denied</h1></body></html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fixtures := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	t.Cleanup(fixtures.Close)
+
+	bufferPool := pool.NewBufferPool(4, 1<<20)
+	workerPool := pool.NewWorkerPool(2)
+	if err := workerPool.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(workerPool.Stop)
+
+	tempStorage := storage.NewTempStorage(t.TempDir(), time.Minute)
+	t.Cleanup(tempStorage.Stop)
+
+	audio := services.NewAudioConverter(workerPool, bufferPool)
+	image := services.NewImageConverter(workerPool, bufferPool, "jpeg")
+	video := services.NewVideoConverter(workerPool, bufferPool)
+	document := services.NewDocumentConverter(workerPool, bufferPool, false)
+	audio.SetTempDir(tempStorage.Dir("audio"))
+	image.SetTempDir(tempStorage.Dir("image"))
+	video.SetTempDir(tempStorage.Dir("video"))
+	document.SetTempDir(tempStorage.Dir("document"))
+
+	profile, _ := services.LookupProfile(services.DefaultProfileName)
+	h := NewProcessHandler(
+		services.NewRegistry(audio, image, video, document),
+		services.NewDownloader(bufferPool, 50<<20, 30*time.Second, 4, 0),
+		tempStorage,
+		integrationBaseURL,
+		time.Minute,
+		services.ProcessOptions{Profile: profile},
+		false,
+		nil,
+		nil,
+		nil,
+	)
+
+	app := fiber.New()
+	app.Post("/api/process", h.Process)
+	app.Get("/api/files/:id", h.GetFile)
+	return &integrationEnv{app: app, fixtures: fixtures, dir: dir}
+}
+
+// copyFixtures copies the checked-in fixtures to dir
+func copyFixtures(t *testing.T, dir string) {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "tiny.*"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no fixtures in testdata: %v", err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(path)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// renderVideoFixture writes a 1s 64x64 H.264/AAC clip to path
+func renderVideoFixture(t *testing.T, path string) {
+	t.Helper()
+	cmd := exec.Command("ffmpeg", "-y", "-loglevel", "error",
+		"-f", "lavfi", "-i", "testsrc=size=64x64:rate=10:duration=1",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=1",
+		"-c:v", "libx264", "-pix_fmt", "yuv420p", "-c:a", "aac", "-shortest",
+		"-movflags", "+faststart", path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to render video fixture: %v: %s", err, output)
+	}
+}
+
+// process posts a request for the fixture name and decodes the response
+func (e *integrationEnv) process(t *testing.T, name string) (int, models.ProcessResponse) {
+	t.Helper()
+	body, _ := json.Marshal(models.ProcessRequest{Arquivo: e.fixtures.URL + "/" + name})
+	req := httptest.NewRequest(http.MethodPost, "/api/process", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.app.Test(req, time.Minute)
+	if err != nil {
+		t.Fatalf("POST /api/process: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var out models.ProcessResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return resp.StatusCode, out
+}
+
+// fetch downloads a nova_url returned by process
+func (e *integrationEnv) fetch(t *testing.T, novaURL string) (*http.Response, []byte) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, strings.TrimPrefix(novaURL, integrationBaseURL), nil)
+	resp, err := e.app.Test(req, 10*time.Second)
+	if err != nil {
+		t.Fatalf("GET %s: %v", novaURL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+func TestIntegrationProcessFixtures(t *testing.T) {
+	env := newIntegrationEnv(t)
+
+	tests := []struct {
+		fixture     string
+		mediaType   string
+		contentType string
+	}{
+		{"tiny.png", "image", "image/"},
+		{"tiny.jpg", "image", "image/"},
+		{"tiny.mp3", "audio", "audio/"},
+		{"tiny.opus", "audio", "audio/"},
+		{"tiny.mp4", "video", "video/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			status, resp := env.process(t, tt.fixture)
+			if status != fiber.StatusOK || !resp.Success {
+				t.Fatalf("status = %d, response = %+v", status, resp)
+			}
+			if resp.MediaType != tt.mediaType || resp.FileID == "" || resp.NovaURL == "" {
+				t.Fatalf("response = %+v", resp)
+			}
+
+			fetched, body := env.fetch(t, resp.NovaURL)
+			if fetched.StatusCode != fiber.StatusOK {
+				t.Fatalf("fetch status = %d", fetched.StatusCode)
+			}
+			if ct := fetched.Header.Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+				t.Errorf("Content-Type = %q, want %s*", ct, tt.contentType)
+			}
+
+			input, err := os.ReadFile(filepath.Join(env.dir, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(body) == 0 || bytes.Equal(body, input) {
+				t.Errorf("output (%d bytes) is empty or identical to the input", len(body))
+			}
+		})
+	}
+}
+
+func TestIntegrationProcessFailures(t *testing.T) {
+	env := newIntegrationEnv(t)
+
+	tests := []struct {
+		fixture string
+		status  int
+		code    string
+	}{
+		{"missing.mp3", fiber.StatusBadRequest, ""},
+		{"garbage.mp4", fiber.StatusUnprocessableEntity, ""},
+		{"error-page.mp3", fiber.StatusBadRequest, "download_content_mismatch"},
+	}
+
+	for _, tt := range tests {
+		status, resp := env.process(t, tt.fixture)
+		if status != tt.status || resp.Success || resp.Code != tt.code {
+			t.Errorf("%s: status = %d, code = %q, want %d %q (%s)", tt.fixture, status, resp.Code, tt.status, tt.code, resp.Message)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+)
+
+// jobEventsKey carries the phase reporter of the job a request runs for
+type jobEventsKey struct{}
+
+// withJobEvents reports the pipeline phases of the request run with ctx to
+// the job with id, labelled with detail (a variant or source object)
+func (h *JobHandler) withJobEvents(ctx context.Context, id, detail string) context.Context {
+	return context.WithValue(ctx, jobEventsKey{}, func(state string) {
+		h.addEvent(id, state, detail)
+	})
+}
+
+// jobEvent reports that the request run with ctx entered a pipeline phase;
+// requests not run as jobs report nothing
+func jobEvent(ctx context.Context, state string) {
+	if report, ok := ctx.Value(jobEventsKey{}).(func(string)); ok {
+		report(state)
+	}
+}
+
+// addEvent records a pipeline phase of a running job
+func (h *JobHandler) addEvent(id, state, detail string) {
+	h.scheduler.AddEvent(id, state, detail)
+	if h.onEvent != nil {
+		h.onEvent(id, models.JobEvent{State: state, At: time.Now(), Detail: detail})
+	}
+}
+
+// Events handles GET /api/jobs/:id/events, the job's state transitions and
+// how long it spent in each
+func (h *JobHandler) Events(c fiber.Ctx) error {
+	job, ok := h.scheduler.Get(c.Params("id"))
+	if !ok || !ownsJob(tenantFrom(c), &job) {
+		return c.Status(fiber.StatusNotFound).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "Job not found or expired",
+		})
+	}
+	return c.JSON(jobTimeline(&job, time.Now()))
+}
+
+// jobTimeline measures each event of job until the next one. The last state
+// of an unfinished job is measured until now, so a stalled phase stands out
+func jobTimeline(job *models.Job, now time.Time) models.JobEventsResponse {
+	finished := job.Status == models.JobSucceeded || job.Status == models.JobFailed
+	timeline := models.JobEventsResponse{
+		JobID:  job.ID,
+		Status: job.Status,
+		Events: make([]models.JobEventSpan, len(job.Events)),
+	}
+	for i, event := range job.Events {
+		end := now
+		if i+1 < len(job.Events) {
+			end = job.Events[i+1].At
+		} else if finished {
+			end = event.At
+		}
+		timeline.Events[i] = models.JobEventSpan{JobEvent: event, DurationMs: end.Sub(event.At).Milliseconds()}
+	}
+	if !finished && len(job.Events) > 0 {
+		timeline.Current = job.Events[len(job.Events)-1].State
+	}
+	return timeline
+}
@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/jobs"
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/objectstore"
+	"fingerprint-converter/internal/services"
+	"fingerprint-converter/internal/tenant"
+)
+
+// JobHandler accepts processing requests to run later through the scheduler
+type JobHandler struct {
+	scheduler      *jobs.Scheduler
+	processHandler *ProcessHandler
+	tenants        *tenant.Store   // Resolves job tenants at run time (nil = no tenants)
+	maxDelay       time.Duration   // Furthest process_at accepted
+	maxVariants    int             // Most variants per job
+	objects        *objectstore.S3 // Lists and reads source jobs (nil = source rejected)
+	maxObjects     int             // Most objects under a source prefix
+
+	// Also receive source job progress and pipeline phases, for jobs leased
+	// from a dispatcher
+	onProgress func(id string, progress models.JobProgress)
+	onEvent    func(id string, event models.JobEvent)
+}
+
+// diversityClusterDistance is the pHash distance below which two variants
+// count as perceptual near-duplicates
+const diversityClusterDistance = 4
+
+// NewJobHandler creates a new job handler
+func NewJobHandler(scheduler *jobs.Scheduler, processHandler *ProcessHandler, tenants *tenant.Store, maxDelay time.Duration, maxVariants int) *JobHandler {
+	if maxDelay <= 0 {
+		maxDelay = 7 * 24 * time.Hour
+	}
+	if maxVariants <= 0 {
+		maxVariants = 20
+	}
+
+	return &JobHandler{
+		scheduler:      scheduler,
+		processHandler: processHandler,
+		tenants:        tenants,
+		maxDelay:       maxDelay,
+		maxVariants:    maxVariants,
+	}
+}
+
+// SetObjectStore accepts jobs that process every object under an S3 prefix,
+// at most maxObjects per job. Call before the handler serves requests
+func (h *JobHandler) SetObjectStore(objects *objectstore.S3, maxObjects int) {
+	if maxObjects <= 0 {
+		maxObjects = 1000
+	}
+	h.objects = objects
+	h.maxObjects = maxObjects
+}
+
+// setProgress records the progress of a running job
+func (h *JobHandler) setProgress(id string, progress models.JobProgress) {
+	h.scheduler.SetProgress(id, progress)
+	if h.onProgress != nil {
+		h.onProgress(id, progress)
+	}
+}
+
+// Submit handles POST /api/jobs
+func (h *JobHandler) Submit(c fiber.Ctx) error {
+	var req models.JobRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+	return h.submit(c, req)
+}
+
+// submit validates and schedules req, answering 202 with the job
+func (h *JobHandler) submit(c fiber.Ctx, req models.JobRequest) error {
+	// Reject invalid requests now rather than when the job runs. Source jobs
+	// validate their options against a stand-in for the objects' URLs
+	t := tenantFrom(c)
+	probe := req.ProcessRequest
+	if req.Source != "" {
+		if msg := h.validateSource(&req); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+				Success: false,
+				Message: msg,
+			})
+		}
+		bucket, prefix, _ := objectstore.ParseURL(req.Source)
+		probe.Arquivo = h.objects.PresignGet(bucket, prefix, sourceURLExpiry)
+	}
+	if _, _, status, resp := h.processHandler.validate(&probe, t); status != 0 {
+		return c.Status(status).JSON(resp)
+	}
+
+	if req.Variants < 0 || req.Variants > h.maxVariants {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+			Success: false,
+			Message: fmt.Sprintf("variants must be between 1 and %d", h.maxVariants),
+		})
+	}
+	if req.Variants > 1 && req.Rasterize {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "variants and rasterize are mutually exclusive",
+		})
+	}
+
+	job := &models.Job{
+		Request:     req.ProcessRequest,
+		Variants:    req.Variants,
+		Source:      req.Source,
+		Destination: req.Destination,
+		MediaTypes:  req.MediaTypes,
+	}
+	if req.ProcessAt != nil {
+		if time.Until(*req.ProcessAt) > h.maxDelay {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+				Success: false,
+				Message: fmt.Sprintf("process_at must be within %v", h.maxDelay),
+			})
+		}
+		job.ProcessAt = req.ProcessAt.UTC()
+	}
+	if t != nil {
+		job.Tenant = t.Name
+	}
+	job.RequestID = requestIDFrom(c)
+
+	if err := h.scheduler.Submit(job); err != nil {
+		if errors.Is(err, jobs.ErrStopped) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(models.ProcessResponse{
+				Success: false,
+				Message: "Server is restarting, retry shortly",
+			})
+		}
+		httpLog.Errorf("❌ Job submission failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "Failed to schedule job",
+		})
+	}
+
+	c.Set("Location", "/api/jobs/"+job.ID)
+	return c.Status(fiber.StatusAccepted).JSON(jobView(job))
+}
+
+// Get handles GET /api/jobs/:id
+// Jobs submitted with an API key are only visible with that tenant's key
+func (h *JobHandler) Get(c fiber.Ctx) error {
+	job, ok := h.scheduler.Get(c.Params("id"))
+	if !ok || !ownsJob(tenantFrom(c), &job) {
+		return c.Status(fiber.StatusNotFound).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "Job not found or expired",
+		})
+	}
+	return c.JSON(jobView(&job))
+}
+
+// jobStates maps job statuses to the states polling clients see
+var jobStates = map[string]string{
+	models.JobScheduled: models.JobStateQueued,
+	models.JobRunning:   models.JobStateProcessing,
+	models.JobSucceeded: models.JobStateDone,
+	models.JobFailed:    models.JobStateFailed,
+}
+
+// jobView summarizes job for polling: its state, the phase it is in while
+// running and the URL of its output once done
+func jobView(job *models.Job) models.JobView {
+	view := models.JobView{Job: *job, State: jobStates[job.Status]}
+	if job.Status == models.JobRunning && len(job.Events) > 0 {
+		view.Phase = job.Events[len(job.Events)-1].State
+	}
+	if job.Result != nil && job.Result.Success {
+		view.NovaURL = job.Result.NovaURL
+	}
+	return view
+}
+
+// Manifest handles GET /api/jobs/:id/manifest
+// Hashes are computed from the stored files, so they match what url serves
+func (h *JobHandler) Manifest(c fiber.Ctx) error {
+	job, ok := h.scheduler.Get(c.Params("id"))
+	if !ok || !ownsJob(tenantFrom(c), &job) {
+		return c.Status(fiber.StatusNotFound).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "Job not found or expired",
+		})
+	}
+	return c.JSON(h.buildManifest(&job))
+}
+
+// buildManifest lists the files job produced: one per variant, rasterized
+// page or source object, otherwise the single output
+func (h *JobHandler) buildManifest(job *models.Job) models.JobManifest {
+	manifest := models.JobManifest{
+		JobID:      job.ID,
+		Status:     job.Status,
+		Progress:   job.Progress,
+		FinishedAt: job.FinishedAt,
+		Items:      []models.ManifestItem{},
+	}
+
+	res := job.Result
+	if res != nil && len(res.Objects) > 0 {
+		manifest.Profile = res.Profile
+		if profile, ok := services.LookupProfile(res.Profile); ok {
+			manifest.Techniques = profile.Techniques()
+		}
+		for i, obj := range res.Objects {
+			item := models.ManifestItem{Status: models.ManifestItemFailed, MediaType: obj.MediaType, Error: obj.Error}
+			if obj.Error == "" {
+				item = h.manifestItem(obj.FileID, obj.NovaURL, obj.MediaType)
+			}
+			item.Index = i + 1
+			item.Source = obj.Source
+			item.Objects = obj.Objects
+			manifest.Items = append(manifest.Items, item)
+		}
+		return manifest
+	}
+
+	switch {
+	case res == nil:
+		manifest.Items = append(manifest.Items, models.ManifestItem{Index: 1, Status: models.ManifestItemPending})
+		return manifest
+	case !res.Success:
+		manifest.Items = append(manifest.Items, models.ManifestItem{Index: 1, Status: models.ManifestItemFailed, Error: res.Message})
+		return manifest
+	}
+
+	manifest.Profile = res.Profile
+	if profile, ok := services.LookupProfile(res.Profile); ok {
+		manifest.Techniques = profile.Techniques()
+	}
+
+	if len(res.Variants) > 0 {
+		var hashes []uint64
+		for i, v := range res.Variants {
+			item := h.manifestItem(v.FileID, v.NovaURL, v.MediaType)
+			item.Index = i + 1
+			item.Variant = v.Variant
+			item.Speed = v.Speed
+			item.Encoding = v.Encoding
+			if item.Status == models.ManifestItemReady && v.MediaType == "image" {
+				if hash, ok := h.perceptualHash(v.FileID); ok {
+					item.PHash = fmt.Sprintf("%016x", hash)
+					hashes = append(hashes, hash)
+				}
+			}
+			manifest.Items = append(manifest.Items, item)
+		}
+		manifest.Diversity = diversityReport(hashes)
+		return manifest
+	}
+
+	if len(res.Pages) > 0 {
+		for i, page := range res.Pages {
+			item := h.manifestItem(page.FileID, page.NovaURL, "image")
+			item.Index = i + 1
+			item.Page = page.Page
+			manifest.Items = append(manifest.Items, item)
+		}
+		return manifest
+	}
+
+	item := h.manifestItem(res.FileID, res.NovaURL, res.MediaType)
+	item.Index = 1
+	item.Speed = res.Speed
+	item.Encoding = res.Encoding
+	manifest.Items = append(manifest.Items, item)
+	return manifest
+}
+
+// manifestItem describes a stored output, or marks it expired once the
+// file is gone
+func (h *JobHandler) manifestItem(fileID, url, mediaType string) models.ManifestItem {
+	item := models.ManifestItem{
+		Status:    models.ManifestItemExpired,
+		FileID:    fileID,
+		MediaType: mediaType,
+	}
+
+	tf, err := h.processHandler.tempStorage.Get(fileID)
+	if err != nil {
+		return item
+	}
+	sum, err := fileSHA256(tf.Path)
+	if err != nil {
+		return item
+	}
+
+	expiresAt := tf.ExpiresAt
+	item.Status = models.ManifestItemReady
+	item.URL = url
+	item.Size = tf.Size
+	item.SHA256 = sum
+	item.ExpiresAt = &expiresAt
+	return item
+}
+
+// perceptualHash returns the pHash of a stored image, false when it is gone
+// or not a JPEG/PNG
+func (h *JobHandler) perceptualHash(fileID string) (uint64, bool) {
+	tf, err := h.processHandler.tempStorage.Get(fileID)
+	if err != nil {
+		return 0, false
+	}
+	data, err := os.ReadFile(tf.Path)
+	if err != nil {
+		return 0, false
+	}
+	hash, err := services.PerceptualHash(data)
+	return hash, err == nil
+}
+
+// diversityReport summarizes the pairwise distances of variant hashes, nil
+// with fewer than two
+func diversityReport(hashes []uint64) *models.DiversityReport {
+	distances := services.PairwiseDistances(hashes)
+	if len(distances) == 0 {
+		return nil
+	}
+
+	report := &models.DiversityReport{
+		Hashed:           len(hashes),
+		Pairs:            len(distances),
+		MinDistance:      distances[0],
+		MaxDistance:      distances[0],
+		ClusterThreshold: diversityClusterDistance,
+		Verdict:          models.DiversityDiverse,
+	}
+	sum := 0
+	for _, d := range distances {
+		report.MinDistance = min(report.MinDistance, d)
+		report.MaxDistance = max(report.MaxDistance, d)
+		sum += d
+		if d < diversityClusterDistance {
+			report.ClusteredPairs++
+		}
+	}
+	report.MeanDistance = math.Round(float64(sum)/float64(len(distances))*100) / 100
+	if report.ClusteredPairs > 0 {
+		report.Verdict = models.DiversityClustering
+	}
+	return report
+}
+
+// fileSHA256 returns the hex SHA-256 of the file at path
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Run is the scheduler's Runner: it processes the job as its tenant, once
+// per variant. A failed variant fails the job
+func (h *JobHandler) Run(ctx context.Context, job *models.Job) (int, models.ProcessResponse) {
+	ctx = services.WithRequestID(ctx, job.RequestID)
+	var t *tenant.Tenant
+	if job.Tenant != "" {
+		if h.tenants != nil {
+			t, _ = h.tenants.ByName(job.Tenant)
+		}
+		if t == nil {
+			return fiber.StatusForbidden, models.ProcessResponse{
+				Success: false,
+				Message: fmt.Sprintf("tenant %s no longer exists", job.Tenant),
+			}
+		}
+	}
+
+	if job.Source != "" {
+		return h.runSource(ctx, job, t)
+	}
+	if job.Variants <= 1 {
+		req := job.Request
+		return h.processHandler.process(h.withJobEvents(ctx, job.ID, ""), &req, t)
+	}
+
+	var combined models.ProcessResponse
+	for i := 1; i <= job.Variants; i++ {
+		req := job.Request
+		status, resp := h.processHandler.process(h.withJobEvents(ctx, job.ID, fmt.Sprintf("variant %d", i)), &req, t)
+		if !resp.Success {
+			resp.Message = fmt.Sprintf("variant %d: %s", i, resp.Message)
+			return status, resp
+		}
+		if i == 1 {
+			combined = resp
+		}
+		combined.Variants = append(combined.Variants, models.VariantInfo{
+			Variant:    i,
+			NovaURL:    resp.NovaURL,
+			ArchiveURL: resp.ArchiveURL,
+			FileID:     resp.FileID,
+			MediaType:  resp.MediaType,
+			Speed:      resp.Speed,
+			Encoding:   resp.Encoding,
+		})
+	}
+	combined.Message = fmt.Sprintf("%d variants processed", job.Variants)
+	return fiber.StatusOK, combined
+}
+
+// ownsJob reports whether the caller's tenant may see job
+func ownsJob(t *tenant.Tenant, job *models.Job) bool {
+	return ownsTenantResource(t, job.Tenant)
+}
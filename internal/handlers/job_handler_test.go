@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fingerprint-converter/internal/jobs"
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/storage"
+)
+
+func TestBuildManifest(t *testing.T) {
+	dir := t.TempDir()
+	ts := storage.NewTempStorage(dir, time.Minute)
+	defer ts.Stop()
+	h := &JobHandler{processHandler: &ProcessHandler{tempStorage: ts}}
+
+	content := []byte("page one")
+	path := filepath.Join(dir, "page1.png")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fileID, err := ts.Store(path, "", "image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	job := &models.Job{
+		ID:     "job1",
+		Status: models.JobSucceeded,
+		Result: &models.ProcessResponse{
+			Success: true,
+			Profile: "paranoid",
+			Pages: []models.PageInfo{
+				{Page: 1, NovaURL: "http://x/api/files/" + fileID + ".png", FileID: fileID},
+				{Page: 2, NovaURL: "http://x/api/files/gone.png", FileID: "gone"},
+			},
+		},
+	}
+
+	m := h.buildManifest(job)
+	if len(m.Items) != 2 || len(m.Techniques) == 0 {
+		t.Fatalf("manifest = %+v", m)
+	}
+
+	sum := sha256.Sum256(content)
+	ready := m.Items[0]
+	if ready.Status != models.ManifestItemReady || ready.Page != 1 || ready.Size != int64(len(content)) ||
+		ready.SHA256 != hex.EncodeToString(sum[:]) || ready.URL == "" || ready.ExpiresAt == nil {
+		t.Errorf("ready item = %+v", ready)
+	}
+	if expired := m.Items[1]; expired.Status != models.ManifestItemExpired || expired.Index != 2 || expired.URL != "" {
+		t.Errorf("expired item = %+v", expired)
+	}
+}
+
+// storeStripes stores a PNG of vertical stripes of the given width
+func storeStripes(t *testing.T, ts *storage.TempStorage, dir, name string, width int) string {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			if (x/width)%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fileID, err := ts.Store(path, "", "image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	return fileID
+}
+
+func TestBuildManifestVariantDiversity(t *testing.T) {
+	dir := t.TempDir()
+	ts := storage.NewTempStorage(dir, time.Minute)
+	defer ts.Stop()
+	h := &JobHandler{processHandler: &ProcessHandler{tempStorage: ts}}
+
+	// Two identical variants and a distinct one
+	ids := []string{
+		storeStripes(t, ts, dir, "a.png", 8),
+		storeStripes(t, ts, dir, "b.png", 8),
+		storeStripes(t, ts, dir, "c.png", 3),
+	}
+	res := &models.ProcessResponse{Success: true}
+	for i, id := range ids {
+		res.Variants = append(res.Variants, models.VariantInfo{Variant: i + 1, FileID: id, NovaURL: "http://x/api/files/" + id + ".png", MediaType: "image"})
+	}
+
+	m := h.buildManifest(&models.Job{ID: "job1", Status: models.JobSucceeded, Variants: 3, Result: res})
+	if len(m.Items) != 3 {
+		t.Fatalf("items = %+v", m.Items)
+	}
+	for i, item := range m.Items {
+		if item.Variant != i+1 || item.Status != models.ManifestItemReady || len(item.PHash) != 16 {
+			t.Errorf("item %d = %+v", i, item)
+		}
+	}
+
+	d := m.Diversity
+	if d == nil {
+		t.Fatal("no diversity report")
+	}
+	if d.Hashed != 3 || d.Pairs != 3 || d.MinDistance != 0 || d.MaxDistance == 0 || d.ClusteredPairs < 1 || d.Verdict != models.DiversityClustering {
+		t.Errorf("diversity = %+v", d)
+	}
+}
+
+func TestDiversityReport(t *testing.T) {
+	if diversityReport([]uint64{1}) != nil {
+		t.Error("report for a single variant")
+	}
+	d := diversityReport([]uint64{0, 0xFF, 0xFF00})
+	if d.Verdict != models.DiversityDiverse || d.MinDistance != 8 || d.MaxDistance != 16 || d.MeanDistance != 10.67 {
+		t.Errorf("diversity = %+v", d)
+	}
+}
+
+func TestBuildManifestUnfinishedJobs(t *testing.T) {
+	h := &JobHandler{}
+
+	pending := h.buildManifest(&models.Job{ID: "a", Status: models.JobScheduled})
+	if len(pending.Items) != 1 || pending.Items[0].Status != models.ManifestItemPending {
+		t.Errorf("pending manifest = %+v", pending)
+	}
+
+	failed := h.buildManifest(&models.Job{ID: "b", Status: models.JobFailed, Result: &models.ProcessResponse{Message: "boom"}})
+	if len(failed.Items) != 1 || failed.Items[0].Status != models.ManifestItemFailed || failed.Items[0].Error != "boom" {
+		t.Errorf("failed manifest = %+v", failed)
+	}
+}
+
+func TestTransientJobFailure(t *testing.T) {
+	tests := []struct {
+		status int
+		code   string
+		want   bool
+	}{
+		{status: 500, want: true},
+		{status: 503, want: true},
+		{status: 507, want: true},
+		{status: 501, want: false},
+		{status: 400, code: "download_unavailable", want: true},
+		{status: 400, code: "download_empty", want: false},
+		{status: 422, want: false},
+		{status: 429, code: "quota_exceeded", want: false},
+	}
+	for _, tt := range tests {
+		if got := TransientJobFailure(tt.status, models.ProcessResponse{Code: tt.code}); got != tt.want {
+			t.Errorf("TransientJobFailure(%d, %q) = %v, want %v", tt.status, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestJobTimeline(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []models.JobEvent{
+		{State: models.JobEventQueued, At: start},
+		{State: models.JobEventRunning, At: start.Add(2 * time.Second)},
+		{State: models.JobEventDownloading, At: start.Add(3 * time.Second)},
+	}
+	now := start.Add(10 * time.Second)
+
+	running := jobTimeline(&models.Job{ID: "a", Status: models.JobRunning, Events: events}, now)
+	if running.Current != models.JobEventDownloading {
+		t.Errorf("current = %q, want downloading", running.Current)
+	}
+	want := []int64{2000, 1000, 7000}
+	for i, span := range running.Events {
+		if span.DurationMs != want[i] {
+			t.Errorf("%s duration = %dms, want %dms", span.State, span.DurationMs, want[i])
+		}
+	}
+
+	done := append(events, models.JobEvent{State: models.JobEventDone, At: start.Add(5 * time.Second)})
+	finished := jobTimeline(&models.Job{ID: "a", Status: models.JobSucceeded, Events: done}, now)
+	if finished.Current != "" || finished.Events[2].DurationMs != 2000 || finished.Events[3].DurationMs != 0 {
+		t.Errorf("finished timeline = %+v", finished)
+	}
+}
+
+func TestJobEventContext(t *testing.T) {
+	scheduler, err := jobs.NewScheduler("", 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &JobHandler{scheduler: scheduler}
+	var reported []models.JobEvent
+	h.onEvent = func(id string, event models.JobEvent) {
+		if id == "job1" {
+			reported = append(reported, event)
+		}
+	}
+
+	jobEvent(context.Background(), models.JobEventDownloading) // Not a job: ignored
+	ctx := h.withJobEvents(context.Background(), "job1", "variant 2")
+	jobEvent(ctx, models.JobEventDownloading)
+	jobEvent(ctx, models.JobEventStoring)
+
+	if len(reported) != 2 || reported[0].State != models.JobEventDownloading || reported[1].Detail != "variant 2" {
+		t.Errorf("reported = %+v", reported)
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+)
+
+// TransientJobFailure reports whether a failed job may succeed when run
+// again: the origin was unreachable or overloaded, memory or disk ran short,
+// or a conversion tool crashed. Rejected input and quota errors are final
+func TransientJobFailure(status int, resp models.ProcessResponse) bool {
+	switch {
+	case resp.Code == "download_unavailable":
+		return true
+	case status == fiber.StatusNotImplemented:
+		return false
+	default:
+		return status >= fiber.StatusInternalServerError
+	}
+}
+
+// DeadLetters handles GET /admin/jobs/dead-letter?tenant=
+func (h *JobHandler) DeadLetters(c fiber.Ctx) error {
+	jobs := h.scheduler.DeadLetters(c.Query("tenant"))
+	return c.JSON(models.DeadLetterResponse{Count: len(jobs), Jobs: jobs})
+}
+
+// Redrive handles POST /admin/jobs/:id/redrive, running a failed job again
+func (h *JobHandler) Redrive(c fiber.Ctx) error {
+	job, err := h.scheduler.Redrive(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/objectstore"
+	"fingerprint-converter/internal/redact"
+	"fingerprint-converter/internal/tenant"
+)
+
+// sourceURLExpiry is how long the presigned URL of a source object stays valid
+const sourceURLExpiry = 30 * time.Minute
+
+// sourceMediaTypes are the media_types a source job may filter on
+var sourceMediaTypes = []string{"audio", "image", "video", "document"}
+
+// validateSource checks the S3 fields of a source job, returning the problem
+// or "" when valid
+func (h *JobHandler) validateSource(req *models.JobRequest) string {
+	if h.objects == nil {
+		return "source requires S3 storage (S3_ACCESS_KEY_ID not set)"
+	}
+	if req.Arquivo != "" {
+		return "arquivo and source are mutually exclusive"
+	}
+	if req.Variants > 1 {
+		return "variants and source are mutually exclusive"
+	}
+	if _, _, err := objectstore.ParseURL(req.Source); err != nil {
+		return "source: " + err.Error()
+	}
+	if _, _, err := objectstore.ParseURL(req.Destination); err != nil {
+		return "destination: " + err.Error()
+	}
+	for _, mediaType := range req.MediaTypes {
+		if !slices.Contains(sourceMediaTypes, mediaType) {
+			return fmt.Sprintf("unknown media type %q in media_types (supported: %s)", mediaType, strings.Join(sourceMediaTypes, ", "))
+		}
+	}
+	return ""
+}
+
+// sourceObject is a listed object selected for processing
+type sourceObject struct {
+	key       string
+	mediaType string
+}
+
+// runSource processes every media object under the job's source prefix as
+// its tenant and uploads the outputs under the destination prefix, keeping
+// each object's path relative to the source. A failed object is recorded
+// and the job continues; it fails only when no object succeeds
+func (h *JobHandler) runSource(ctx context.Context, job *models.Job, t *tenant.Tenant) (int, models.ProcessResponse) {
+	if h.objects == nil {
+		return fiber.StatusServiceUnavailable, models.ProcessResponse{
+			Success: false,
+			Message: "S3 storage is no longer configured",
+		}
+	}
+	bucket, prefix, err := objectstore.ParseURL(job.Source)
+	if err != nil {
+		return fiber.StatusBadRequest, models.ProcessResponse{Success: false, Message: err.Error()}
+	}
+	destBucket, destPrefix, err := objectstore.ParseURL(job.Destination)
+	if err != nil {
+		return fiber.StatusBadRequest, models.ProcessResponse{Success: false, Message: err.Error()}
+	}
+
+	listed, err := h.objects.List(ctx, bucket, prefix, h.maxObjects+1)
+	if err != nil {
+		httpLog.Warnf("⚠️  Source listing failed: job=%s: %s", job.ID, redact.Error(err))
+		return fiber.StatusBadGateway, models.ProcessResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list source: %v", err),
+		}
+	}
+	if len(listed) > h.maxObjects {
+		return fiber.StatusUnprocessableEntity, models.ProcessResponse{
+			Success: false,
+			Message: fmt.Sprintf("source holds more than %d objects; narrow the prefix", h.maxObjects),
+		}
+	}
+
+	var selected []sourceObject
+	for _, obj := range listed {
+		mediaType, _ := detectMediaTypeAndFormatFromURL(obj.Key)
+		if mediaType == "" || strings.HasSuffix(obj.Key, "/") {
+			continue
+		}
+		if len(job.MediaTypes) > 0 && !slices.Contains(job.MediaTypes, mediaType) {
+			continue
+		}
+		selected = append(selected, sourceObject{key: obj.Key, mediaType: mediaType})
+	}
+	if len(selected) == 0 {
+		return fiber.StatusUnprocessableEntity, models.ProcessResponse{
+			Success: false,
+			Message: fmt.Sprintf("no matching media objects under %s", job.Source),
+		}
+	}
+
+	progress := models.JobProgress{Total: len(selected)}
+	h.setProgress(job.ID, progress)
+	httpLog.Infof("🪣 Source job: id=%s, objects=%d (listed %d)", job.ID, len(selected), len(listed))
+
+	infos := make([]models.ObjectInfo, len(selected))
+	for i, obj := range selected {
+		infos[i] = models.ObjectInfo{Source: "s3://" + bucket + "/" + obj.key, MediaType: obj.mediaType}
+	}
+
+	var status int     // Last processing failure
+	var failure string // Set when the remaining objects are bound to fail too
+	var profile string
+	for i, obj := range selected {
+		if ctx.Err() != nil {
+			break
+		}
+		if failure != "" {
+			infos[i].Error = failure
+			progress.Failed++
+			continue
+		}
+
+		req := job.Request
+		req.Arquivo = h.objects.PresignGet(bucket, obj.key, sourceURLExpiry)
+		code, resp := h.processHandler.process(h.withJobEvents(ctx, job.ID, obj.key), &req, t)
+		if !resp.Success {
+			status = code
+			infos[i].Error = resp.Message
+			progress.Failed++
+			if code == fiber.StatusTooManyRequests || code == fiber.StatusForbidden {
+				failure = resp.Message
+			}
+			h.setProgress(job.ID, progress)
+			continue
+		}
+		infos[i].MediaType = resp.MediaType
+		infos[i].NovaURL = resp.NovaURL
+		infos[i].FileID = resp.FileID
+		profile = resp.Profile
+
+		// Outputs keep the object's path below the source prefix
+		rel := strings.TrimPrefix(strings.TrimPrefix(obj.key, prefix), "/")
+		name := strings.TrimSuffix(rel, path.Ext(rel))
+		if err := h.uploadSourceOutputs(ctx, destBucket, destPrefix, name, resp, &infos[i]); err != nil {
+			httpLog.Warnf("⚠️  Source upload failed: job=%s: %s", job.ID, redact.Error(err))
+			status = fiber.StatusBadGateway
+			infos[i].Error = err.Error()
+			progress.Failed++
+		} else {
+			progress.Processed++
+		}
+		h.setProgress(job.ID, progress)
+	}
+
+	resp := models.ProcessResponse{
+		Success: progress.Processed > 0,
+		Message: fmt.Sprintf("%d of %d objects processed", progress.Processed, progress.Total),
+		Profile: profile,
+		Objects: infos,
+	}
+	if !resp.Success {
+		if status == 0 {
+			status = fiber.StatusUnprocessableEntity
+		}
+		return status, resp
+	}
+	return fiber.StatusOK, resp
+}
+
+// uploadSourceOutputs uploads the output of one object, one file per page
+// when it was rasterized, recording the s3:// URLs in info
+func (h *JobHandler) uploadSourceOutputs(ctx context.Context, bucket, prefix, name string, resp models.ProcessResponse, info *models.ObjectInfo) error {
+	type output struct{ name, fileID string }
+	outputs := []output{{name, resp.FileID}}
+	if len(resp.Pages) > 0 {
+		outputs = outputs[:0]
+		for _, page := range resp.Pages {
+			outputs = append(outputs, output{fmt.Sprintf("%s-p%03d", name, page.Page), page.FileID})
+		}
+	}
+
+	for _, o := range outputs {
+		key, err := uploadOutput(ctx, h.objects, h.processHandler.tempStorage, bucket, prefix, o.name, o.fileID)
+		if err != nil {
+			return err
+		}
+		info.Objects = append(info.Objects, "s3://"+bucket+"/"+key)
+	}
+	return nil
+}
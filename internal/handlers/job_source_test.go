@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fingerprint-converter/internal/jobs"
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/objectstore"
+)
+
+// fakeBucket serves ListObjectsV2, GET and PUT for one path-style bucket
+type fakeBucket struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	uploaded []string
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/media/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/media":
+		prefix := r.URL.Query().Get("prefix")
+		var keys []string
+		for k := range b.objects {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		io.WriteString(w, "<ListBucketResult><IsTruncated>false</IsTruncated>")
+		for _, k := range keys {
+			io.WriteString(w, "<Contents><Key>"+k+"</Key><Size>1</Size></Contents>")
+		}
+		io.WriteString(w, "</ListBucketResult>")
+	case r.Method == http.MethodGet && r.URL.Query().Get("X-Amz-Signature") != "":
+		data, ok := b.objects[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodPut:
+		b.uploaded = append(b.uploaded, key)
+	default:
+		w.WriteHeader(http.StatusForbidden)
+	}
+}
+
+func newSourceJobHandler(t *testing.T, bucket *fakeBucket) (*JobHandler, *jobs.Scheduler) {
+	t.Helper()
+	server := httptest.NewServer(bucket)
+	t.Cleanup(server.Close)
+	objects, err := objectstore.NewS3(server.URL, "", "AKID", "secret", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processHandler, _ := newTestProcessHandler(t,
+		&fakeConverter{mediaType: "audio", output: "processed audio"},
+		&fakeConverter{mediaType: "image", output: "processed image"},
+	)
+
+	scheduler, err := jobs.NewScheduler("", 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewJobHandler(scheduler, processHandler, nil, 0, 0)
+	h.SetObjectStore(objects, 10)
+	return h, scheduler
+}
+
+func TestRunSourceJob(t *testing.T) {
+	fixture := func(name string) []byte {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	bucket := &fakeBucket{objects: map[string][]byte{
+		"in/a.png":       fixture("tiny.png"),
+		"in/sub/b.mp3":   fixture("tiny.mp3"),
+		"in/broken.opus": []byte("<html>not audio</html>"),
+		"in/notes.txt":   []byte("notes"),
+		"other/c.png":    fixture("tiny.png"),
+	}}
+	h, scheduler := newSourceJobHandler(t, bucket)
+
+	job := &models.Job{Source: "s3://media/in/", Destination: "s3://media/out"}
+	if err := scheduler.Submit(job); err != nil {
+		t.Fatal(err)
+	}
+	status, resp := h.Run(context.Background(), job)
+	if status != http.StatusOK || !resp.Success || len(resp.Objects) != 3 {
+		t.Fatalf("status = %d, response = %+v", status, resp)
+	}
+
+	sort.Strings(bucket.uploaded)
+	if strings.Join(bucket.uploaded, ",") != "out/a.png,out/sub/b.mp3" {
+		t.Errorf("uploaded = %v", bucket.uploaded)
+	}
+	for _, obj := range resp.Objects {
+		broken := obj.Source == "s3://media/in/broken.opus"
+		if broken != (obj.Error != "") || broken != (len(obj.Objects) == 0) {
+			t.Errorf("object = %+v", obj)
+		}
+	}
+
+	stored, _ := scheduler.Get(job.ID)
+	if p := stored.Progress; p == nil || p.Total != 3 || p.Processed != 2 || p.Failed != 1 {
+		t.Errorf("progress = %+v", stored.Progress)
+	}
+
+	stored.Result = &resp
+	manifest := h.buildManifest(&stored)
+	if len(manifest.Items) != 3 || manifest.Progress == nil {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+	for _, item := range manifest.Items {
+		if item.Source == "" || (item.Status == models.ManifestItemReady) == (item.Error != "") {
+			t.Errorf("manifest item = %+v", item)
+		}
+	}
+}
+
+func TestRunSourceJobFilters(t *testing.T) {
+	bucket := &fakeBucket{objects: map[string][]byte{
+		"in/a.png": []byte("png"),
+		"in/b.mp3": []byte("mp3"),
+	}}
+	h, _ := newSourceJobHandler(t, bucket)
+
+	job := &models.Job{ID: "job1", Source: "s3://media/in", Destination: "s3://media/out", MediaTypes: []string{"video"}}
+	if status, resp := h.Run(context.Background(), job); status != http.StatusUnprocessableEntity || resp.Success {
+		t.Errorf("no matching objects: status = %d, response = %+v", status, resp)
+	}
+
+	h.maxObjects = 1
+	job.MediaTypes = nil
+	if status, resp := h.Run(context.Background(), job); status != http.StatusUnprocessableEntity || !strings.Contains(resp.Message, "more than 1") {
+		t.Errorf("too many objects: status = %d, response = %+v", status, resp)
+	}
+}
+
+func TestValidateSource(t *testing.T) {
+	h, _ := newSourceJobHandler(t, &fakeBucket{})
+	valid := models.JobRequest{Source: "s3://media/in", Destination: "s3://media/out"}
+
+	tests := []struct {
+		name   string
+		modify func(*models.JobRequest)
+		want   string
+	}{
+		{"valid", func(*models.JobRequest) {}, ""},
+		{"with arquivo", func(r *models.JobRequest) { r.Arquivo = "https://x/a.png" }, "mutually exclusive"},
+		{"with variants", func(r *models.JobRequest) { r.Variants = 2 }, "mutually exclusive"},
+		{"bad source", func(r *models.JobRequest) { r.Source = "https://media/in" }, "source:"},
+		{"no destination", func(r *models.JobRequest) { r.Destination = "" }, "destination:"},
+		{"unknown media type", func(r *models.JobRequest) { r.MediaTypes = []string{"gif"} }, "unknown media type"},
+	}
+	for _, tt := range tests {
+		req := valid
+		tt.modify(&req)
+		got := h.validateSource(&req)
+		if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+			t.Errorf("%s: validateSource = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	h.objects = nil
+	if got := h.validateSource(&valid); !strings.Contains(got, "S3") {
+		t.Errorf("without S3: validateSource = %q", got)
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/services"
+	"fingerprint-converter/internal/storage"
+)
+
+// NonceHandler reads the processing nonce back from files, so a circulating
+// copy can be traced to the request that produced it
+type NonceHandler struct {
+	tempStorage *storage.TempStorage
+}
+
+// NewNonceHandler creates a new nonce handler
+func NewNonceHandler(tempStorage *storage.TempStorage) *NonceHandler {
+	return &NonceHandler{tempStorage: tempStorage}
+}
+
+// FileNonce handles GET /api/files/:id/nonce for a stored file
+func (h *NonceHandler) FileNonce(c fiber.Ctx) error {
+	// Accept the ID with the extension of nova_url, like GetFile
+	fileID := c.Params("id")
+	if idx := strings.LastIndex(fileID, "."); idx > 0 {
+		fileID = fileID[:idx]
+	}
+
+	tf, err := h.tempStorage.Get(fileID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "File not found or expired",
+		})
+	}
+
+	nonce, err := services.ExtractNonce(tf.Path)
+	if err != nil {
+		return nonceError(c, err)
+	}
+	return c.JSON(models.NonceResponse{
+		Success:     true,
+		Nonce:       nonce.Nonce,
+		ProcessedAt: nonce.ProcessedAt(),
+		FileID:      tf.ID,
+		MediaType:   tf.MediaType,
+	})
+}
+
+// Identify handles POST /api/identify with the file as a multipart "file"
+// field or as the raw request body
+func (h *NonceHandler) Identify(c fiber.Ctx) error {
+	var nonce *services.ProcessingNonce
+	var err error
+	if header, formErr := c.FormFile("file"); formErr == nil {
+		f, openErr := header.Open()
+		if openErr != nil {
+			return nonceError(c, openErr)
+		}
+		defer f.Close()
+		nonce, err = services.ScanNonce(f)
+	} else if body := c.Body(); len(body) > 0 {
+		var ok bool
+		if nonce, ok = services.FindNonce(body); !ok {
+			err = services.ErrNoNonce
+		}
+	} else {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "Send the file as a multipart \"file\" field or as the request body",
+		})
+	}
+	if err != nil {
+		return nonceError(c, err)
+	}
+
+	return c.JSON(models.NonceResponse{
+		Success:     true,
+		Nonce:       nonce.Nonce,
+		ProcessedAt: nonce.ProcessedAt(),
+	})
+}
+
+// nonceError reports a file without a marker as 404 no_nonce, anything else as 500
+func nonceError(c fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrNoNonce) {
+		return c.Status(fiber.StatusNotFound).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "The file carries no embedded nonce; it was not produced by this service or its metadata was stripped",
+			Code:    "no_nonce",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ProcessResponse{
+		Success: false,
+		Message: "Failed to read file: " + err.Error(),
+	})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/services"
+	"fingerprint-converter/internal/storage"
+)
+
+func TestNonceEndpoints(t *testing.T) {
+	dir := t.TempDir()
+	tempStorage := storage.NewTempStorage(dir, time.Minute)
+	t.Cleanup(tempStorage.Stop)
+
+	nonce := services.GenerateNonce()
+	content := append([]byte("ID3\x04\x00\x00TIT2\x00\x00\x00\x40\x00\x00\x00uid:"+nonce.Nonce), make([]byte, 512)...)
+	path := filepath.Join(dir, "out.mp3")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fileID, err := tempStorage.Store(path, "", "audio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := filepath.Join(dir, "plain.mp3")
+	os.WriteFile(plain, make([]byte, 512), 0644)
+	plainID, _ := tempStorage.Store(plain, "", "audio")
+
+	h := NewNonceHandler(tempStorage)
+	app := fiber.New()
+	app.Get("/api/files/:id/nonce", h.FileNonce)
+	app.Post("/api/identify", h.Identify)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, _ := mw.CreateFormFile("file", "circulating.mp3")
+	part.Write(content)
+	mw.Close()
+
+	tests := []struct {
+		name        string
+		req         *http.Request
+		status      int
+		code        string
+		wantNonce   bool
+		contentType string
+	}{
+		{"stored file", httptest.NewRequest(http.MethodGet, "/api/files/"+fileID+".mp3/nonce", nil), fiber.StatusOK, "", true, ""},
+		{"stored file without marker", httptest.NewRequest(http.MethodGet, "/api/files/"+plainID+"/nonce", nil), fiber.StatusNotFound, "no_nonce", false, ""},
+		{"unknown file", httptest.NewRequest(http.MethodGet, "/api/files/missing/nonce", nil), fiber.StatusNotFound, "", false, ""},
+		{"raw upload", httptest.NewRequest(http.MethodPost, "/api/identify", bytes.NewReader(content)), fiber.StatusOK, "", true, "application/octet-stream"},
+		{"multipart upload", httptest.NewRequest(http.MethodPost, "/api/identify", &form), fiber.StatusOK, "", true, mw.FormDataContentType()},
+		{"empty upload", httptest.NewRequest(http.MethodPost, "/api/identify", nil), fiber.StatusBadRequest, "", false, ""},
+	}
+
+	for _, tt := range tests {
+		if tt.contentType != "" {
+			tt.req.Header.Set("Content-Type", tt.contentType)
+		}
+		resp, err := app.Test(tt.req, 5*time.Second)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var body struct {
+			models.NonceResponse
+			Code string `json:"code"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.status || body.Code != tt.code {
+			t.Errorf("%s: status = %d, code = %q, want %d %q", tt.name, resp.StatusCode, body.Code, tt.status, tt.code)
+		}
+		if tt.wantNonce && (body.Nonce != nonce.Nonce || !body.ProcessedAt.Equal(nonce.ProcessedAt())) {
+			t.Errorf("%s: nonce = %q at %v, want %q", tt.name, body.Nonce, body.ProcessedAt, nonce.Nonce)
+		}
+	}
+}
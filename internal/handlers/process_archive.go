@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/services"
+	"fingerprint-converter/internal/storage"
+	"fingerprint-converter/internal/tenant"
+)
+
+// archiveOutput is a processed entry waiting to be stored or repackaged
+type archiveOutput struct {
+	index      int    // Position in the entries list
+	name       string // Name inside a repackaged zip
+	outputPath string
+	mediaType  string
+}
+
+// processArchive unpacks a zip or tar archive and runs every media entry
+// through its converter. Entries that cannot be processed are reported in
+// the manifest; the request fails only when none succeeds
+// Each entry counts as a conversion of its unpacked size against the
+// tenant's quotas, and the request's rules apply to each
+func (h *ProcessHandler) processArchive(ctx context.Context, t *tenant.Tenant, req *models.ProcessRequest, inputData []byte, archiveFormat string, opts services.ProcessOptions, rules services.ProcessRules, live *liveOutput) (int, models.ProcessResponse) {
+	if req.Rasterize {
+		return fiber.StatusBadRequest, models.ProcessResponse{
+			Success: false,
+			Message: "rasterize is only supported for .pdf and .tiff files",
+		}
+	}
+	if live != nil && !req.Zip {
+		return fiber.StatusBadRequest, models.ProcessResponse{
+			Success: false,
+			Message: "stream of an archive input requires zip",
+			Code:    "stream_unsupported",
+		}
+	}
+
+	trace := services.TraceFrom(ctx)
+	reqLog := httpLog.WithID(services.RequestIDFrom(ctx))
+	entries, err := services.ExtractArchive(inputData, archiveFormat, h.archiveLimits)
+	trace.Mark("unpack")
+	if err != nil {
+		code := "invalid_archive"
+		if errors.Is(err, services.ErrArchiveLimit) {
+			code = "archive_too_large"
+		}
+		return fiber.StatusUnprocessableEntity, models.ProcessResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to unpack archive: %v", err),
+			Code:    code,
+		}
+	}
+	if len(entries) == 0 {
+		return fiber.StatusUnprocessableEntity, models.ProcessResponse{
+			Success: false,
+			Message: "Archive contains no files",
+			Code:    "empty_archive",
+		}
+	}
+
+	var unpacked int64
+	for _, e := range entries {
+		unpacked += int64(len(e.Data))
+	}
+	if h.spaceGuard != nil {
+		if err := h.spaceGuard.Check(h.tempStorage.Dir("archive"), int64(len(inputData))+2*unpacked); err != nil {
+			reqLog.Infof("💾 Rejected for disk space: %v", err)
+			return fiber.StatusInsufficientStorage, models.ProcessResponse{
+				Success: false,
+				Message: err.Error(),
+				Code:    "insufficient_storage",
+			}
+		}
+	}
+
+	job, err := h.tempStorage.NewJobDirFor("archive", services.RequestIDFrom(ctx))
+	if err != nil {
+		reqLog.Errorf("❌ %v", err)
+		return fiber.StatusInternalServerError, models.ProcessResponse{
+			Success: false,
+			Message: "Failed to create job directory",
+		}
+	}
+	defer job.Close()
+	ctx = services.WithWorkdir(ctx, job.Dir())
+
+	// Every entry is stored against the archive it came from
+	originalPath := job.Path("input.original")
+	if err := os.WriteFile(originalPath, inputData, 0644); err != nil {
+		return fiber.StatusInternalServerError, models.ProcessResponse{
+			Success: false,
+			Message: "Failed to save original file",
+		}
+	}
+
+	reqLog.Infof("📦 Processing %s archive: entries=%d, unpacked=%dMB", archiveFormat, len(entries), unpacked>>20)
+	processingStart := time.Now()
+
+	infos := make([]models.ArchiveEntryInfo, len(entries))
+	outputs := make([]archiveOutput, 0, len(entries))
+	var quotaErr error
+	for i, entry := range entries {
+		infos[i].Name = entry.Name
+		if quotaErr != nil {
+			infos[i].Error = quotaErr.Error()
+			continue
+		}
+		output, err := h.processArchiveEntry(ctx, t, job, i, entry, opts, rules)
+		infos[i].MediaType = output.mediaType
+		if err != nil {
+			if ctx.Err() != nil {
+				return processErrorStatus(err), models.ProcessResponse{
+					Success: false,
+					Message: fmt.Sprintf("Processing %s failed: %v", entry.Name, err),
+				}
+			}
+			// Once a quota runs out, no later entry can be admitted
+			if errors.As(err, new(*tenant.QuotaError)) {
+				if len(outputs) == 0 {
+					return quotaExceededResponse(err)
+				}
+				quotaErr = err
+			}
+			reqLog.Warnf("⚠️  Archive entry %s skipped: %v", entry.Name, err)
+			infos[i].Error = err.Error()
+			continue
+		}
+		outputs = append(outputs, output)
+	}
+	trace.Mark("convert")
+	jobEvent(ctx, models.JobEventStoring)
+
+	if len(outputs) == 0 {
+		return fiber.StatusUnprocessableEntity, models.ProcessResponse{
+			Success: false,
+			Message: "No archive entry could be processed",
+			Code:    "no_processable_entries",
+			Entries: infos,
+		}
+	}
+
+	if req.Zip {
+		zipPath := job.Path("entries.zip")
+		if err := writeArchiveZip(zipPath, outputs); err != nil {
+			return fiber.StatusInternalServerError, models.ProcessResponse{
+				Success: false,
+				Message: fmt.Sprintf("Failed to build zip: %v", err),
+			}
+		}
+
+		fileID, err := h.store(t, job, zipPath, originalPath, "archive")
+		if err != nil {
+			return fiber.StatusInternalServerError, models.ProcessResponse{
+				Success: false,
+				Message: "Failed to store processed file",
+			}
+		}
+		trace.Mark("store")
+
+		reqLog.Infof("✅ Archive processed: format=%s, entries=%d/%d, id=%s, time=%dms",
+			archiveFormat, len(outputs), len(entries), fileID, time.Since(processingStart).Milliseconds())
+
+		return fiber.StatusOK, models.ProcessResponse{
+			Success:   true,
+			Message:   "arquivo modificado com sucesso!",
+			NovaURL:   h.fileURL(fileID, ".zip"),
+			MediaType: "archive",
+			FileID:    fileID,
+			Profile:   opts.Profile.Name,
+			Level:     opts.Level,
+			Entries:   infos,
+		}
+	}
+
+	for _, output := range outputs {
+		fileID, err := h.store(t, job, output.outputPath, originalPath, output.mediaType)
+		if err != nil {
+			return fiber.StatusInternalServerError, models.ProcessResponse{
+				Success: false,
+				Message: "Failed to store processed file",
+			}
+		}
+		infos[output.index].NovaURL = h.fileURL(fileID, filepath.Ext(output.outputPath))
+		infos[output.index].FileID = fileID
+	}
+	trace.Mark("store")
+
+	reqLog.Infof("✅ Archive processed: format=%s, entries=%d/%d, time=%dms",
+		archiveFormat, len(outputs), len(entries), time.Since(processingStart).Milliseconds())
+
+	first := infos[outputs[0].index]
+	return fiber.StatusOK, models.ProcessResponse{
+		Success:   true,
+		Message:   "arquivo modificado com sucesso!",
+		NovaURL:   first.NovaURL,
+		MediaType: "archive",
+		FileID:    first.FileID,
+		Profile:   opts.Profile.Name,
+		Level:     opts.Level,
+		Entries:   infos,
+	}
+}
+
+// processArchiveEntry converts one unpacked file, detecting its media type
+// from its name and then its content like a downloaded file
+func (h *ProcessHandler) processArchiveEntry(ctx context.Context, t *tenant.Tenant, job *storage.JobDir, index int, entry services.ArchiveEntry, opts services.ProcessOptions, rules services.ProcessRules) (output archiveOutput, err error) {
+	output = archiveOutput{index: index}
+
+	mediaType, inputFormat := detectMediaTypeAndFormatFromURL(entry.Name)
+	sniffedType, sniffedFormat := services.SniffMedia(entry.Data)
+	if mediaType == "" || isMislabeled(mediaType, sniffedType) {
+		mediaType, inputFormat = sniffedType, sniffedFormat
+	}
+	output.mediaType = mediaType
+	if mediaType == "" {
+		return output, errors.New("unsupported media type")
+	}
+	if t != nil && !t.AllowsMediaType(mediaType) {
+		return output, fmt.Errorf("media type %s is not allowed for this API key", mediaType)
+	}
+	converter, ok := h.converters.Get(mediaType)
+	if !ok {
+		return output, fmt.Errorf("unsupported media type: %s", mediaType)
+	}
+	if opts.Level != "" && inputFormat == "svg" {
+		return output, errors.New("level is not supported for .svg files")
+	}
+	if limit := h.downloader.SizeLimit(ctx); int64(len(entry.Data)) > limit {
+		return output, fmt.Errorf("entry exceeds %d bytes", limit)
+	}
+
+	if t != nil && h.quotas != nil {
+		reservation, err := h.quotas.Admit(t.Name)
+		if err != nil {
+			return output, err
+		}
+		if err := reservation.AddBytes(int64(len(entry.Data))); err != nil {
+			reservation.Refund()
+			return output, err
+		}
+		defer func() {
+			if err != nil {
+				reservation.Refund()
+			}
+		}()
+	}
+
+	output.outputPath = job.Path(fmt.Sprintf("entry-%03d%s", index+1, getExtensionForFormat(inputFormat)))
+	checkCost := h.costBudget.Active() && mediaType != "document"
+
+	// Probing and cost estimates read the entry from disk
+	originalPath := job.Path(fmt.Sprintf("entry-%03d.original%s", index+1, getExtensionForFormat(inputFormat)))
+	if (rules.Active() && rules.NeedsProbe()) || checkCost {
+		if err := os.WriteFile(originalPath, entry.Data, 0644); err != nil {
+			return output, err
+		}
+	}
+
+	if rules.Active() {
+		var info services.MediaInfo
+		if rules.NeedsProbe() {
+			if info, err = services.ProbeMedia(ctx, originalPath); err != nil {
+				return output, fmt.Errorf("could not probe media for rules: %w", err)
+			}
+		}
+		switch decision := rules.Evaluate(int64(len(entry.Data)), info); decision.Action {
+		case services.RuleReject:
+			return output, fmt.Errorf("rejected by rule: %s", decision.Reason)
+		case services.RuleSkip:
+			// Passed through unmodified, like a skipped single file
+			output.name = entry.Name
+			return output, os.WriteFile(output.outputPath, entry.Data, 0644)
+		}
+	}
+
+	if checkCost {
+		if status, resp := h.checkCost(ctx, originalPath); status != 0 {
+			return output, errors.New(resp.Message)
+		}
+	}
+
+	if h.memoryGate != nil {
+		estimate := services.EstimateJobMemory(mediaType, len(entry.Data))
+		if err := h.memoryGate.Acquire(ctx, estimate); err != nil {
+			return output, errors.New("server busy: memory budget exhausted")
+		}
+		defer h.memoryGate.Release(estimate)
+	}
+
+	result, err := converter.Process(ctx, entry.Data, output.outputPath, inputFormat, opts)
+	if err != nil {
+		reportFailure(err, "archive entry conversion failed", mediaType, inputFormat, len(entry.Data), opts)
+		return output, err
+	}
+	if result.OutputPath != "" {
+		output.outputPath = result.OutputPath
+	}
+
+	// Repackaged outputs keep their archive path, with the output's extension
+	output.name = strings.TrimSuffix(entry.Name, path.Ext(entry.Name)) + filepath.Ext(output.outputPath)
+	return output, nil
+}
+
+// writeArchiveZip bundles processed entries into a zip under their archive names
+func writeArchiveZip(zipPath string, outputs []archiveOutput) error {
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, output := range outputs {
+		data, err := os.ReadFile(output.outputPath)
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create(output.name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
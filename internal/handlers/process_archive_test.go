@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/services"
+	"fingerprint-converter/internal/tenant"
+)
+
+// zipFixtures builds a zip of the given names; tiny.* values are testdata
+// fixtures and any other value is the literal content
+func zipFixtures(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, fixture := range files {
+		data := []byte(fixture)
+		if strings.HasPrefix(fixture, "tiny.") {
+			var err error
+			if data, err = os.ReadFile(filepath.Join("testdata", fixture)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w, _ := zw.Create(name)
+		w.Write(data)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestProcessArchive(t *testing.T) {
+	archives := map[string][]byte{
+		"/bulk.zip": zipFixtures(t, map[string]string{
+			"photos/a.png":     "tiny.png",
+			"b.mp3":            "tiny.mp3",
+			"notes.txt":        "plain text notes",
+			"__MACOSX/._a.png": "resource fork",
+		}),
+		"/slip.zip": zipFixtures(t, map[string]string{"../evil.png": "tiny.png"}),
+		"/text.zip": zipFixtures(t, map[string]string{"notes.txt": "plain text notes"}),
+	}
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if data, ok := archives[r.URL.Path]; ok {
+			w.Write(data)
+			return
+		}
+		data, _ := os.ReadFile(filepath.Join("testdata", filepath.Base(r.URL.Path)))
+		w.Write(data)
+	}))
+	defer source.Close()
+
+	h, ts := newTestProcessHandler(t,
+		&fakeConverter{mediaType: "audio", output: "processed audio"},
+		&fakeConverter{mediaType: "image", output: "processed image"},
+	)
+	h.SetArchiveLimits(services.ArchiveLimits{MaxEntries: 10, MaxEntryBytes: 1 << 20, MaxTotalBytes: 4 << 20})
+
+	app := fiber.New()
+	app.Post("/api/process", h.Process)
+
+	post := func(body string) (int, models.ProcessResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/process", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 10*time.Second)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		defer resp.Body.Close()
+		var out models.ProcessResponse
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	t.Run("manifest", func(t *testing.T) {
+		status, resp := post(`{"arquivo": "` + source.URL + `/bulk.zip"}`)
+		if status != fiber.StatusOK || resp.MediaType != "archive" || len(resp.Entries) != 3 {
+			t.Fatalf("status = %d, response = %+v", status, resp)
+		}
+		byName := make(map[string]models.ArchiveEntryInfo)
+		for _, e := range resp.Entries {
+			byName[e.Name] = e
+		}
+		for name, mediaType := range map[string]string{"photos/a.png": "image", "b.mp3": "audio"} {
+			e := byName[name]
+			if e.MediaType != mediaType || e.FileID == "" || e.Error != "" || !strings.HasPrefix(e.NovaURL, "http://files/api/files/") {
+				t.Errorf("%s: %+v", name, e)
+			}
+		}
+		if e := byName["notes.txt"]; e.Error == "" || e.FileID != "" {
+			t.Errorf("notes.txt: %+v, want an error", e)
+		}
+		if resp.FileID != resp.Entries[0].FileID && resp.FileID != resp.Entries[1].FileID {
+			t.Errorf("file_id %s is not one of the entries", resp.FileID)
+		}
+	})
+
+	t.Run("repackaged", func(t *testing.T) {
+		status, resp := post(`{"arquivo": "` + source.URL + `/bulk.zip", "zip": true}`)
+		if status != fiber.StatusOK || !strings.HasSuffix(resp.NovaURL, ".zip") {
+			t.Fatalf("status = %d, response = %+v", status, resp)
+		}
+		tf, err := ts.Get(resp.FileID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := zip.OpenReader(tf.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		var names []string
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+		sort.Strings(names)
+		if strings.Join(names, ",") != "b.mp3,photos/a.png" {
+			t.Errorf("zip entries = %v", names)
+		}
+	})
+
+	t.Run("quota per entry", func(t *testing.T) {
+		store, err := tenant.Parse([]byte(`{"tenants": [{"name": "a", "key": "k", "conversions_per_day": 1}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, _ := store.ByName("a")
+		h.quotas = tenant.NewQuotas(store)
+		defer func() { h.quotas = nil }()
+
+		// Two media entries against a quota of one conversion
+		status, resp := h.process(context.Background(), &models.ProcessRequest{Arquivo: source.URL + "/bulk.zip"}, a)
+		if status != fiber.StatusOK {
+			t.Fatalf("status = %d, response = %+v", status, resp)
+		}
+		var converted, overQuota int
+		for _, e := range resp.Entries {
+			switch {
+			case e.FileID != "":
+				converted++
+			case strings.Contains(e.Error, tenant.QuotaConversionsDay):
+				overQuota++
+			}
+		}
+		if converted != 1 || overQuota == 0 {
+			t.Errorf("entries = %+v, want one converted and the rest over quota", resp.Entries)
+		}
+		if st, _ := h.quotas.Status("a"); st.ConversionsToday != 1 {
+			t.Errorf("conversions = %d, want 1", st.ConversionsToday)
+		}
+
+		status, resp = h.process(context.Background(), &models.ProcessRequest{Arquivo: source.URL + "/bulk.zip"}, a)
+		if status != fiber.StatusTooManyRequests || resp.Success {
+			t.Errorf("exhausted quota: status = %d, response = %+v", status, resp)
+		}
+	})
+
+	tests := []struct {
+		name, body string
+		status     int
+		code       string
+	}{
+		{"zip slip", `{"arquivo": "` + source.URL + `/slip.zip"}`, fiber.StatusUnprocessableEntity, "invalid_archive"},
+		{"nothing processable", `{"arquivo": "` + source.URL + `/text.zip"}`, fiber.StatusUnprocessableEntity, "no_processable_entries"},
+		{"stream without zip", `{"arquivo": "` + source.URL + `/bulk.zip", "stream": true}`, fiber.StatusBadRequest, "stream_unsupported"},
+		{"zip of a single file", `{"arquivo": "` + source.URL + `/tiny.png", "zip": true}`, fiber.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		status, resp := post(tt.body)
+		if status != tt.status || resp.Code != tt.code || resp.Success {
+			t.Errorf("%s: status = %d, code = %q, want %d %q (%s)", tt.name, status, resp.Code, tt.status, tt.code, resp.Message)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/services"
+)
+
+// BatchLimits bounds /api/process/batch requests
+type BatchLimits struct {
+	MaxItems    int // Items per request (0 = batches rejected)
+	Concurrency int // Items processed at once per request
+}
+
+// SetBatchLimits accepts batches of up to limits.MaxItems files
+// Call before the handler serves requests
+func (h *ProcessHandler) SetBatchLimits(limits BatchLimits) {
+	h.batchLimits = limits
+}
+
+// ProcessBatch handles POST /api/process/batch: every item runs through the
+// same pipeline as /api/process, a few at a time, and its response is
+// reported in place of a single aggregate status
+func (h *ProcessHandler) ProcessBatch(c fiber.Ctx) error {
+	var req models.BatchRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+	if h.batchLimits.MaxItems == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "batch is disabled on this server",
+		})
+	}
+	if len(req.Items) == 0 || len(req.Items) > h.batchLimits.MaxItems {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+			Success: false,
+			Message: fmt.Sprintf("items must hold 1 to %d files", h.batchLimits.MaxItems),
+		})
+	}
+
+	t := tenantFrom(c)
+	requestID := requestIDFrom(c)
+	results := make([]models.BatchResult, len(req.Items))
+	sem := make(chan struct{}, max(h.batchLimits.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i := range req.Items {
+		item := &req.Items[i].ProcessRequest
+		if item.Async || item.Stream {
+			results[i] = models.BatchResult{Index: i, HTTPStatus: fiber.StatusBadRequest, ProcessResponse: models.ProcessResponse{
+				Success: false,
+				Message: "async and stream are not supported in a batch",
+			}}
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			ctx := services.WithRequestID(context.Background(), fmt.Sprintf("%s-%d", requestID, i))
+			status, resp := h.process(ctx, item, t)
+			results[i] = models.BatchResult{Index: i, HTTPStatus: status, ProcessResponse: resp}
+		}()
+	}
+	wg.Wait()
+
+	batch := models.BatchResponse{Total: len(results), Results: results}
+	for _, result := range results {
+		if result.Success {
+			batch.Succeeded++
+		} else {
+			batch.Failed++
+		}
+		if result.Quota != nil {
+			setQuotaHeaders(c, result.Quota)
+		}
+	}
+	batch.Success = batch.Failed == 0
+	batch.Message = fmt.Sprintf("%d of %d files processed", batch.Succeeded, batch.Total)
+	httpLog.Infof("📚 Batch done: %d succeeded, %d failed", batch.Succeeded, batch.Failed)
+	return c.JSON(batch)
+}
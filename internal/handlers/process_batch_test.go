@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+)
+
+func TestProcessBatch(t *testing.T) {
+	png, err := os.ReadFile(filepath.Join("testdata", "tiny.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/missing") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(png)
+	}))
+	defer source.Close()
+
+	h, _ := newTestProcessHandler(t, &fakeConverter{mediaType: "image", output: "processed image"})
+	app := fiber.New()
+	app.Post("/api/process/batch", h.ProcessBatch)
+	post := func(body string) (int, models.BatchResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/process/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, 10*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var batch models.BatchResponse
+		json.NewDecoder(res.Body).Decode(&batch)
+		return res.StatusCode, batch
+	}
+
+	items := `{"items":["` + source.URL + `/a.png",{"arquivo":"` + source.URL + `/b.png","filename":"second"},"` +
+		source.URL + `/missing.png",{"arquivo":"` + source.URL + `/c.png","stream":true}]}`
+	if status, _ := post(items); status != fiber.StatusBadRequest {
+		t.Fatalf("batch while disabled = %d, want 400", status)
+	}
+
+	h.SetBatchLimits(BatchLimits{MaxItems: 4, Concurrency: 2})
+	status, batch := post(items)
+	if status != fiber.StatusOK || batch.Total != 4 || batch.Succeeded != 2 || batch.Failed != 2 || batch.Success {
+		t.Fatalf("batch = %d %+v", status, batch)
+	}
+	for i, result := range batch.Results {
+		if result.Index != i {
+			t.Errorf("results[%d].index = %d", i, result.Index)
+		}
+	}
+	if !batch.Results[0].Success || !strings.Contains(batch.Results[1].NovaURL, "name=second") {
+		t.Errorf("processed items = %+v, %+v", batch.Results[0], batch.Results[1])
+	}
+	if batch.Results[2].HTTPStatus != fiber.StatusBadRequest || batch.Results[3].HTTPStatus != fiber.StatusBadRequest {
+		t.Errorf("failed items = %d, %d, want 400", batch.Results[2].HTTPStatus, batch.Results[3].HTTPStatus)
+	}
+
+	tooMany := `{"items":["a","b","c","d","e"]}`
+	if status, _ := post(tooMany); status != fiber.StatusBadRequest {
+		t.Errorf("batch over the limit = %d, want 400", status)
+	}
+	if status, _ := post(`{"items":[]}`); status != fiber.StatusBadRequest {
+		t.Errorf("empty batch = %d, want 400", status)
+	}
+}
@@ -1,222 +1,1220 @@
 package handlers
 
 import (
+	"archive/zip"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"math"
+	"net/http"
+	neturl "net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
 
+	"fingerprint-converter/internal/cdn"
+	"fingerprint-converter/internal/errreport"
+	"fingerprint-converter/internal/logx"
+	"fingerprint-converter/internal/messaging"
 	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/pool"
+	"fingerprint-converter/internal/redact"
 	"fingerprint-converter/internal/services"
 	"fingerprint-converter/internal/storage"
+	"fingerprint-converter/internal/tenant"
 )
 
+// httpLog carries the per-request logs of every handler
+var httpLog = logx.For(logx.HTTP)
+
 // ProcessHandler handles simplified processing requests
 type ProcessHandler struct {
-	audioConverter *services.AudioConverter
-	imageConverter *services.ImageConverter
-	videoConverter *services.VideoConverter
+	converters     *services.Registry
 	downloader     *services.Downloader
 	tempStorage    *storage.TempStorage
 	baseURL        string // e.g., "http://localhost:4000"
 	requestTimeout time.Duration
+	defaults       services.ProcessOptions    // Server defaults for optional request settings
+	headProbe      bool                       // HEAD the URL when it has no usable extension
+	memoryGate     *pool.MemoryGate           // Admission control by estimated job memory (nil = disabled)
+	spaceGuard     *storage.SpaceGuard        // Free disk check before processing (nil = disabled)
+	quotas         *tenant.Quotas             // Per-tenant conversion/byte quotas (nil = disabled)
+	sampler        *services.Sampler          // Keeps diagnostics of slow or large requests (nil = disabled)
+	gpsSynthesis   bool                       // Accept gps_region
+	escalation     *services.EscalationPolicy // Escalates sources flagged as deduplicated (nil = disabled)
+	comparator     *services.Comparator       // Similarity engines run by compare (nil = compare ignored)
+	tenantMetadata *tenant.MetadataDefaults   // Per-tenant default metadata fields (nil = disabled)
+	archiveLimits  services.ArchiveLimits     // Bounds on archive input (zero = archives rejected)
+	archival       *Archival                  // Long-term storage for outputs requested with archive (nil = disabled)
+	jobs           *JobHandler                // Runs requests made with async (nil = disabled)
+	costBudget     services.CostBudget        // Most work one input may cause (zero = unlimited)
+	batchLimits    BatchLimits                // Bounds on /api/process/batch (zero = batches rejected)
+	files          *FileHandler               // Serves GET /api/files
+	cdn            *cdn.CDN                   // Hands nova_url out on a CDN (nil = on baseURL)
+	// Chat platforms whose webhooks are processed, by name (nil = none)
+	messaging map[string]messaging.Platform
 }
 
 // NewProcessHandler creates a new process handler
 func NewProcessHandler(
-	audioConverter *services.AudioConverter,
-	imageConverter *services.ImageConverter,
-	videoConverter *services.VideoConverter,
+	converters *services.Registry,
 	downloader *services.Downloader,
 	tempStorage *storage.TempStorage,
 	baseURL string,
 	requestTimeout time.Duration,
+	defaults services.ProcessOptions,
+	headProbe bool,
+	memoryGate *pool.MemoryGate,
+	spaceGuard *storage.SpaceGuard,
+	quotas *tenant.Quotas,
 ) *ProcessHandler {
 	if requestTimeout <= 0 {
 		requestTimeout = 5 * time.Minute
 	}
 
 	return &ProcessHandler{
-		audioConverter: audioConverter,
-		imageConverter: imageConverter,
-		videoConverter: videoConverter,
+		converters:     converters,
 		downloader:     downloader,
 		tempStorage:    tempStorage,
 		baseURL:        baseURL,
 		requestTimeout: requestTimeout,
+		defaults:       defaults,
+		headProbe:      headProbe,
+		memoryGate:     memoryGate,
+		spaceGuard:     spaceGuard,
+		quotas:         quotas,
+		files:          NewFileHandler(tempStorage),
+	}
+}
+
+// SetSampler keeps extended diagnostics of slow or large requests in s
+// Call before the handler serves requests
+func (h *ProcessHandler) SetSampler(s *services.Sampler) {
+	h.sampler = s
+}
+
+// SetGPSSynthesis makes the handler accept gps_region, embedding synthesized
+// coordinates in images. Call before the handler serves requests
+func (h *ProcessHandler) SetGPSSynthesis(enabled bool) {
+	h.gpsSynthesis = enabled
+}
+
+// SetEscalation strengthens techniques for sources whose variants were
+// reported as deduplicated. Call before the handler serves requests
+func (h *ProcessHandler) SetEscalation(p *services.EscalationPolicy) {
+	h.escalation = p
+}
+
+// SetComparator runs c on input and output when a request sets compare
+// Call before the handler serves requests
+func (h *ProcessHandler) SetComparator(c *services.Comparator) {
+	h.comparator = c
+}
+
+// SetTenantMetadata merges each tenant's default metadata fields into its
+// outputs. Call before the handler serves requests
+func (h *ProcessHandler) SetTenantMetadata(d *tenant.MetadataDefaults) {
+	h.tenantMetadata = d
+}
+
+// SetArchiveLimits accepts zip and tar archives of media files as input,
+// unpacked within limits. Call before the handler serves requests
+func (h *ProcessHandler) SetArchiveLimits(limits services.ArchiveLimits) {
+	h.archiveLimits = limits
+}
+
+// SetCDN hands outputs out under CDN URLs and lets the CDN cache them
+// Call before the handler serves requests
+func (h *ProcessHandler) SetCDN(c *cdn.CDN) {
+	h.cdn = c
+	h.files.SetSharedCaching(true)
+}
+
+// fileURL is the nova_url of a stored file: on the CDN when one is set,
+// signed until the file expires, else on this API
+func (h *ProcessHandler) fileURL(fileID, ext string) string {
+	if h.cdn != nil {
+		if tf, err := h.tempStorage.Get(fileID); err == nil {
+			return h.cdn.URL(fileID+ext, tf.ExpiresAt)
+		}
 	}
+	return fmt.Sprintf("%s/api/files/%s%s", h.baseURL, fileID, ext)
+}
+
+// SetArchival accepts archive, keeping those outputs retrievable past their TTL
+// Call before the handler serves requests
+func (h *ProcessHandler) SetArchival(a *Archival) {
+	h.archival = a
+}
+
+// SetCostBudget rejects inputs whose estimated work exceeds budget before
+// they are converted. Call before the handler serves requests
+func (h *ProcessHandler) SetCostBudget(budget services.CostBudget) {
+	h.costBudget = budget
+}
+
+// SetAsync accepts async, running those requests as jobs of jobs
+// Call before the handler serves requests
+func (h *ProcessHandler) SetAsync(jobs *JobHandler) {
+	h.jobs = jobs
 }
 
 // Process handles POST /api/process
 func (h *ProcessHandler) Process(c fiber.Ctx) error {
 	// Parse request
 	var req models.ProcessRequest
-	if err := c.Bind().JSON(&req); err != nil {
+	if isMultipart(c) {
+		if err := bindUpload(c, &req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+				Success: false,
+				Message: "Invalid multipart body: " + err.Error(),
+			})
+		}
+	} else if err := c.Bind().JSON(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
 			Success: false,
 			Message: "Invalid request body",
 		})
 	}
 
-	// Validate URL
-	if req.Arquivo == "" {
+	if req.Async {
+		return h.processAsync(c, req)
+	}
+	if req.Stream {
+		return h.processStreaming(c, &req, tenantFrom(c))
+	}
+
+	status, resp := h.process(services.WithRequestID(context.Background(), requestIDFrom(c)), &req, tenantFrom(c))
+	if resp.Quota != nil {
+		setQuotaHeaders(c, resp.Quota)
+	}
+	return c.Status(status).JSON(resp)
+}
+
+// isMultipart reports whether the request body is multipart/form-data
+func isMultipart(c fiber.Ctx) bool {
+	return strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm)
+}
+
+// bindUpload reads a multipart request into req: the input in the "file"
+// field and, optionally, the usual JSON options in the "options" field
+func bindUpload(c fiber.Ctx, req *models.ProcessRequest) error {
+	if options := c.FormValue("options"); options != "" {
+		if err := json.Unmarshal([]byte(options), req); err != nil {
+			return fmt.Errorf("options: %w", err)
+		}
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return errors.New(`a "file" field is required`)
+	}
+	file, err := header.Open()
+	if err != nil {
+		return fmt.Errorf("file: %w", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("file: %w", err)
+	}
+	req.Upload = &models.Upload{Name: filepath.Base(header.Filename), Data: data}
+	return nil
+}
+
+// processAsync schedules req as a job to run now and answers 202 with it
+func (h *ProcessHandler) processAsync(c fiber.Ctx, req models.ProcessRequest) error {
+	if h.jobs == nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
 			Success: false,
-			Message: "arquivo (URL) is required",
+			Message: "async is disabled on this server",
 		})
 	}
-
-	// Detect media type and format from URL
-	mediaType, inputFormat := detectMediaTypeAndFormatFromURL(req.Arquivo)
-	if mediaType == "" {
+	if req.Stream {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
 			Success: false,
-			Message: "Could not detect media type from URL. Supported: .mp3, .opus, .mp4, .jpg, .jpeg, .png",
+			Message: "stream cannot be combined with async",
+			Code:    "stream_unsupported",
 		})
 	}
+	if req.Upload != nil {
+		// Jobs are persisted and may run on remote workers, which fetch arquivo
+		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "async requires arquivo; uploaded files are processed synchronously",
+		})
+	}
+	req.Async = false
+	return h.jobs.submit(c, models.JobRequest{ProcessRequest: req})
+}
+
+// process validates and runs one request for tenant t (nil without an API key)
+// It is shared by the synchronous endpoint and scheduled jobs
+func (h *ProcessHandler) process(parent context.Context, req *models.ProcessRequest, t *tenant.Tenant) (int, models.ProcessResponse) {
+	return h.processTo(parent, req, t, nil)
+}
+
+// processTo is process that copies the encoded output to live while the
+// pipeline runs (nil = no live output)
+func (h *ProcessHandler) processTo(parent context.Context, req *models.ProcessRequest, t *tenant.Tenant, live *liveOutput) (status int, resp models.ProcessResponse) {
+	if req.Archive {
+		defer func() {
+			if resp.Success {
+				h.archiveOutputs(&resp)
+			}
+		}()
+	}
+	trace := services.NewRequestTrace()
+	status, resp = h.runProcess(services.WithTrace(parent, trace), req, t, live)
+	resp.Timings = requestTimings(trace)
+	resp.RequestID = services.RequestIDFrom(parent)
+	if h.sampler == nil {
+		return status, resp
+	}
+
+	var errMsg string
+	if !resp.Success {
+		errMsg = errreport.Scrub(resp.Message)
+	}
+	if sample, kept := h.sampler.Observe(trace, status, errMsg); kept {
+		httpLog.Infof("🔬 Sampled %s request: type=%s, size=%d bytes, time=%dms",
+			strings.Join(sample.Reasons, "+"), sample.MediaType, sample.InputBytes, sample.TotalMs)
+	}
+	return status, resp
+}
+
+// requestTimings groups the phases marked in trace into the response's timings
+func requestTimings(trace *services.RequestTrace) *models.Timings {
+	phases, total := trace.PhaseTotals()
+	return &models.Timings{
+		DownloadMs: phases["download"],
+		ProbeMs:    phases["detect"] + phases["prepare"],
+		WaitMs:     phases["admission"],
+		ConvertMs:  phases["unpack"] + phases["rasterize"] + phases["convert"],
+		CompareMs:  phases["compare"],
+		StoreMs:    phases["store"],
+		TotalMs:    total,
+	}
+}
+
+// runProcess does the work of processTo, marking phases in the request's trace
+func (h *ProcessHandler) runProcess(parent context.Context, req *models.ProcessRequest, t *tenant.Tenant, live *liveOutput) (status int, resp models.ProcessResponse) {
+	trace := services.TraceFrom(parent)
+	reqLog := httpLog.WithID(services.RequestIDFrom(parent))
+	opts, rules, status, resp := h.validate(req, t)
+	if status != 0 {
+		return status, resp
+	}
 
-	log.Printf("🔄 Processing: type=%s, format=%s, url=%s", mediaType, inputFormat, truncateURL(req.Arquivo))
+	// The conversion is reserved before any work and its bytes once the
+	// download succeeds; failed requests give both back
+	var reservation *tenant.Reservation
+	if t != nil && h.quotas != nil {
+		var err error
+		if reservation, err = h.quotas.Admit(t.Name); err != nil {
+			return quotaExceededResponse(err)
+		}
+		defer func() {
+			if reservation != nil && !resp.Success {
+				reservation.Refund()
+			}
+		}()
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), h.requestTimeout)
+	ctx, cancel := context.WithTimeout(parent, h.requestTimeout)
 	defer cancel()
+	if t != nil {
+		ctx = services.WithDownloadLimit(ctx, t.MaxFileSize)
+	}
+	ctx = services.WithUserAgent(ctx, req.UserAgent)
 
-	// Download file
-	log.Printf("📥 Downloading file...")
-	inputData, err := h.downloader.Download(ctx, req.Arquivo)
+	// Detect media type and format from URL, then from the HEAD Content-Type
+	// for extension-less URLs; content sniffing after download is the last resort
+	location := req.Arquivo
+	if req.Upload != nil {
+		location = neturl.PathEscape(req.Upload.Name)
+	}
+	mediaType, inputFormat := detectMediaTypeAndFormatFromURL(location)
+	if mediaType == "" && h.headProbe && req.Upload == nil {
+		if contentType, err := h.downloader.ContentType(ctx, req.Arquivo); err != nil {
+			reqLog.Warnf("⚠️  HEAD probe failed: %s", redact.Error(err))
+		} else {
+			mediaType, inputFormat = services.MediaFromContentType(contentType)
+			reqLog.Infof("🔎 HEAD Content-Type: %s -> %s/%s", contentType, mediaType, inputFormat)
+		}
+	}
+
+	reqLog.Infof("🔄 Processing: type=%s, format=%s, url=%s", mediaType, inputFormat, truncateURL(location))
+	trace.Mark("detect")
+	jobEvent(ctx, models.JobEventDownloading)
+
+	// Download file, unless it was uploaded with the request
+	var inputData []byte
+	if req.Upload != nil {
+		if limit := h.downloader.SizeLimit(ctx); int64(len(req.Upload.Data)) > limit {
+			trace.Mark("download")
+			return fiber.StatusRequestEntityTooLarge, models.ProcessResponse{
+				Success: false,
+				Message: fmt.Sprintf("uploaded file exceeds %d bytes", limit),
+				Code:    "upload_too_large",
+			}
+		}
+		reqLog.Infof("📤 Using uploaded file: %d bytes", len(req.Upload.Data))
+		inputData = req.Upload.Data
+	} else {
+		reqLog.Infof("📥 Downloading file...")
+		var err error
+		inputData, _, err = h.downloader.DownloadWithMirrors(ctx, req.Arquivo, req.ArquivoMirrors)
+		if err != nil {
+			trace.Mark("download")
+			return fiber.StatusBadRequest, models.ProcessResponse{
+				Success: false,
+				Message: fmt.Sprintf("Failed to download file: %v", err),
+				Code:    downloadErrorCode(err),
+			}
+		}
+	}
+	trace.Mark("download")
+	jobEvent(ctx, models.JobEventConverting)
+	if reservation != nil {
+		if err := reservation.AddBytes(int64(len(inputData))); err != nil {
+			return quotaExceededResponse(err)
+		}
+	}
+
+	// CDNs sometimes serve images under video names and vice versa; trust the bytes
+	sniffedType, sniffedFormat := services.SniffMedia(inputData)
+	if mediaType == "" {
+		mediaType, inputFormat = sniffedType, sniffedFormat
+	} else if isMislabeled(mediaType, sniffedType) {
+		reqLog.Infof("🔀 Media type corrected: url says %s/%s, content is %s/%s", mediaType, inputFormat, sniffedType, sniffedFormat)
+		mediaType, inputFormat = sniffedType, sniffedFormat
+	}
+	if sniffedType == "" && h.archiveLimits.MaxEntries > 0 {
+		if archiveFormat := services.SniffArchive(inputData); archiveFormat != "" {
+			trace.SetInput("archive", archiveFormat, len(inputData))
+			// Quotas count every entry instead of the archive itself
+			if reservation != nil {
+				reservation.Refund()
+				reservation = nil
+			}
+			return h.processArchive(ctx, t, req, inputData, archiveFormat, opts, rules, live)
+		}
+	}
+	if req.Zip && !req.Rasterize {
+		return fiber.StatusBadRequest, models.ProcessResponse{
+			Success: false,
+			Message: "zip requires rasterize or an archive input",
+		}
+	}
+	trace.SetInput(mediaType, inputFormat, len(inputData))
+	if mediaType == "" {
+		return fiber.StatusBadRequest, models.ProcessResponse{
+			Success: false,
+			Message: "Could not detect media type from URL, Content-Type or content. Supported: " + h.supportedExtensions(),
+		}
+	}
+
+	if t != nil && !t.AllowsMediaType(mediaType) {
+		return fiber.StatusForbidden, models.ProcessResponse{
+			Success: false,
+			Message: fmt.Sprintf("media type %s is not allowed for this API key", mediaType),
+			Code:    "media_type_not_allowed",
+		}
+	}
+
+	if req.Rasterize && inputFormat != "pdf" && inputFormat != "tiff" {
+		return fiber.StatusBadRequest, models.ProcessResponse{
+			Success: false,
+			Message: "rasterize is only supported for .pdf and .tiff files",
+		}
+	}
+
+	// Levels re-encode pixels through ffmpeg, which cannot decode SVG
+	if opts.Level != "" && inputFormat == "svg" {
+		return fiber.StatusBadRequest, models.ProcessResponse{
+			Success: false,
+			Message: "level is not supported for .svg files",
+		}
+	}
+
+	// Fail now with 507 rather than mid-encode with an ffmpeg write error
+	if h.spaceGuard != nil {
+		if err := h.spaceGuard.Check(h.tempStorage.Dir(mediaType), int64(len(inputData))); err != nil {
+			reqLog.Infof("💾 Rejected for disk space: %v", err)
+			return fiber.StatusInsufficientStorage, models.ProcessResponse{
+				Success: false,
+				Message: err.Error(),
+				Code:    "insufficient_storage",
+			}
+		}
+	}
+
+	// Defer the job until its estimated peak memory fits the budget
+	if h.memoryGate != nil {
+		estimate := services.EstimateJobMemory(mediaType, len(inputData))
+		if err := h.memoryGate.Acquire(ctx, estimate); err != nil {
+			reqLog.Warnf("⚠️  Memory admission timed out: need=%dMB, stats=%+v", estimate>>20, h.memoryGate.GetStats())
+			return fiber.StatusServiceUnavailable, models.ProcessResponse{
+				Success: false,
+				Message: "Server busy: memory budget exhausted, retry later",
+			}
+		}
+		defer h.memoryGate.Release(estimate)
+	}
+	trace.Mark("admission")
+
+	// Everything the job writes goes to its own directory, removed when it ends
+	job, err := h.tempStorage.NewJobDirFor(mediaType, services.RequestIDFrom(ctx))
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+		reqLog.Errorf("❌ %v", err)
+		return fiber.StatusInternalServerError, models.ProcessResponse{
 			Success: false,
-			Message: fmt.Sprintf("Failed to download file: %v", err),
-		})
+			Message: "Failed to create job directory",
+		}
 	}
+	defer job.Close()
+	ctx = services.WithWorkdir(ctx, job.Dir())
 
 	// Save original file temporarily
-	originalPath := h.tempStorage.GenerateTempPath(mediaType) + ".original"
+	originalPath := job.Path("input.original")
 	if err := os.WriteFile(originalPath, inputData, 0644); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ProcessResponse{
+		return fiber.StatusInternalServerError, models.ProcessResponse{
 			Success: false,
 			Message: "Failed to save original file",
-		})
+		}
+	}
+
+	// Server-side rules: reject or pass through without fingerprinting
+	if rules.Active() {
+		var info services.MediaInfo
+		if rules.NeedsProbe() {
+			if info, err = services.ProbeMedia(ctx, originalPath); err != nil {
+				return fiber.StatusUnprocessableEntity, models.ProcessResponse{
+					Success: false,
+					Message: fmt.Sprintf("Could not probe media for rules: %v", err),
+				}
+			}
+		}
+
+		switch decision := rules.Evaluate(int64(len(inputData)), info); decision.Action {
+		case services.RuleReject:
+			return fiber.StatusUnprocessableEntity, models.ProcessResponse{
+				Success: false,
+				Message: fmt.Sprintf("Rejected by rule: %s", decision.Reason),
+			}
+		case services.RuleSkip:
+			reqLog.Infof("⏭️  Skipping fingerprinting: %s", decision.Reason)
+			return h.passThrough(t, job, inputData, mediaType, inputFormat, originalPath, decision.Reason)
+		}
+	}
+
+	if h.costBudget.Active() && mediaType != "document" {
+		if status, resp := h.checkCost(ctx, originalPath); status != 0 {
+			return status, resp
+		}
+	}
+
+	trace.Mark("prepare")
+
+	if req.Rasterize {
+		return h.processPages(ctx, t, job, req, inputData, inputFormat, originalPath, opts)
 	}
 
 	// Generate output path with original format extension
-	outputPath := h.tempStorage.GenerateTempPathWithFormat(mediaType, inputFormat)
+	outputPath := job.Path("output" + getExtensionForFormat(inputFormat))
 
-	// Process file with script techniques (always use "script" level)
-	log.Printf("🧬 Applying fingerprint techniques...")
+	// Process file with the script techniques, or the level pipeline when set
+	reqLog.Infof("🧬 Applying fingerprint techniques...")
 	processingStart := time.Now()
 
-	switch mediaType {
-	case "audio":
-		err = h.audioConverter.ConvertWithScriptTechniques(ctx, inputData, outputPath, inputFormat)
-	case "image":
-		err = h.imageConverter.ConvertWithScriptTechniques(ctx, inputData, outputPath)
-	case "video":
-		err = h.videoConverter.ConvertWithScriptTechniques(ctx, inputData, outputPath)
-	default:
-		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+	converter, ok := h.converters.Get(mediaType)
+	if !ok {
+		return fiber.StatusBadRequest, models.ProcessResponse{
 			Success: false,
 			Message: fmt.Sprintf("Unsupported media type: %s", mediaType),
-		})
+		}
+	}
+
+	if live != nil {
+		live.outputPath = outputPath
+		opts.Stream = live
 	}
 
+	// Sources whose earlier variants were flagged downstream get stronger techniques
+	var source string
+	var level int
+	if h.escalation != nil && opts.Level == "" {
+		source = services.SourceKey(tenantName(t), inputData)
+		if level = h.escalation.Level(source); level > 0 {
+			opts.Profile = services.Escalate(opts.Profile, level)
+			reqLog.Infof("📈 Techniques escalated: level=%d", level)
+		}
+	}
+
+	result, err := converter.Process(ctx, inputData, outputPath, inputFormat, opts)
+	trace.Mark("convert")
 	if err != nil {
-		// Cleanup original file on error
-		os.Remove(originalPath)
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ProcessResponse{
+		reportFailure(err, mediaType+" conversion failed", mediaType, inputFormat, len(inputData), opts)
+		return processErrorStatus(err), models.ProcessResponse{
 			Success: false,
 			Message: fmt.Sprintf("Processing failed: %v", err),
-		})
+		}
+	}
+
+	// Converters that change format (e.g. TIFF -> JPEG) report the real path
+	extension := getExtensionForFormat(inputFormat)
+	if result.OutputPath != "" {
+		outputPath = result.OutputPath
+		extension = filepath.Ext(outputPath)
 	}
 
 	// Verify output file was created
-	if _, err := os.Stat(outputPath); os.IsNotExist(err) {
-		os.Remove(originalPath)
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ProcessResponse{
+	outputInfo, err := os.Stat(outputPath)
+	if os.IsNotExist(err) {
+		return fiber.StatusInternalServerError, models.ProcessResponse{
 			Success: false,
 			Message: "Output file was not created",
-		})
+		}
+	}
+	if err == nil {
+		trace.SetOutput(outputInfo.Size())
 	}
 
-	log.Printf("📁 Output file created: %s", outputPath)
+	reqLog.Infof("📁 Output file created: %s", redact.Path(outputPath))
+
+	// Optional similarity comparison of input and output
+	var similarity []models.SimilarityResult
+	var similarityVerdict string
+	var phashDistance *int
+	if req.Compare && h.comparator != nil {
+		similarity, similarityVerdict, phashDistance = compareOutput(ctx, h.comparator, mediaType, originalPath, outputPath)
+		trace.Mark("compare")
+	}
 
 	// Store in temp storage
-	fileID, err := h.tempStorage.Store(outputPath, originalPath, mediaType)
+	jobEvent(ctx, models.JobEventStoring)
+	fileID, err := h.store(t, job, outputPath, originalPath, mediaType)
+	trace.Mark("store")
 	if err != nil {
-		os.Remove(outputPath)
-		os.Remove(originalPath)
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ProcessResponse{
+		return fiber.StatusInternalServerError, models.ProcessResponse{
 			Success: false,
 			Message: "Failed to store processed file",
+		}
+	}
+
+	if source != "" {
+		h.escalation.Record(services.Delivery{
+			FileID:   fileID,
+			Source:   source,
+			Tenant:   tenantName(t),
+			Level:    level,
+			Profile:  opts.Profile.Name,
+			Strength: opts.Profile.Strength,
+			Speed:    appliedSpeed(mediaType, opts),
+			At:       time.Now().UTC(),
 		})
 	}
 
-	// Generate URL with original format extension
-	extension := getExtensionForFormat(inputFormat)
-	novaURL := fmt.Sprintf("%s/api/files/%s%s", h.baseURL, fileID, extension)
+	// Generate URL with output format extension
+	novaURL := h.fileURL(fileID, extension)
+	if filename, _ := services.ExpandTemplate(req.Filename, req.Variables); filename != "" {
+		separator := "?"
+		if strings.Contains(novaURL, "?") {
+			separator = "&"
+		}
+		novaURL += separator + "name=" + neturl.QueryEscape(filename)
+	}
 
-	log.Printf("✅ Processed: type=%s, format=%s, id=%s, path=%s, time=%dms",
-		mediaType, inputFormat, fileID, outputPath, time.Since(processingStart).Milliseconds())
+	reqLog.Infof("✅ Processed: type=%s, format=%s, id=%s, time=%dms",
+		mediaType, inputFormat, fileID, time.Since(processingStart).Milliseconds())
 
-	return c.JSON(models.ProcessResponse{
-		Success:   true,
-		Message:   "arquivo modificado com sucesso!",
-		NovaURL:   novaURL,
-		MediaType: mediaType,
-		FileID:    fileID,
-	})
+	return fiber.StatusOK, models.ProcessResponse{
+		Success:           true,
+		Message:           "arquivo modificado com sucesso!",
+		NovaURL:           novaURL,
+		MediaType:         mediaType,
+		FileID:            fileID,
+		Encoding:          toEncodingInfo(result.Encoding),
+		Speed:             appliedSpeed(mediaType, opts),
+		Profile:           opts.Profile.Name,
+		Level:             opts.Level,
+		PHashDistance:     phashDistance,
+		Similarity:        similarity,
+		SimilarityVerdict: similarityVerdict,
+		Escalation:        level,
+	}
+}
+
+// validate checks a request before any download and resolves its options
+// A non-zero status means the request was rejected with resp
+func (h *ProcessHandler) validate(req *models.ProcessRequest, t *tenant.Tenant) (services.ProcessOptions, services.ProcessRules, int, models.ProcessResponse) {
+	var opts services.ProcessOptions
+	var rules services.ProcessRules
+
+	// Validate URL, or the uploaded file replacing it
+	if req.Upload != nil {
+		if req.Arquivo != "" || len(req.ArquivoMirrors) > 0 {
+			return opts, rules, fiber.StatusBadRequest, models.ProcessResponse{
+				Success: false,
+				Message: "arquivo cannot be combined with an uploaded file",
+			}
+		}
+		if len(req.Upload.Data) == 0 {
+			return opts, rules, fiber.StatusBadRequest, models.ProcessResponse{
+				Success: false,
+				Message: "uploaded file is empty",
+			}
+		}
+	} else if req.Arquivo == "" {
+		return opts, rules, fiber.StatusBadRequest, models.ProcessResponse{
+			Success: false,
+			Message: "arquivo (URL) is required",
+		}
+	}
+
+	// Resolve optional processing settings
+	var err error
+	opts, err = h.buildOptions(req, t)
+	if err != nil {
+		return opts, rules, fiber.StatusBadRequest, models.ProcessResponse{
+			Success: false,
+			Message: err.Error(),
+		}
+	}
+
+	rules, err = buildRules(req)
+	if err != nil {
+		return opts, rules, fiber.StatusBadRequest, models.ProcessResponse{
+			Success: false,
+			Message: err.Error(),
+		}
+	}
+
+	if len(req.ArquivoMirrors) > services.MaxMirrors {
+		return opts, rules, fiber.StatusBadRequest, models.ProcessResponse{
+			Success: false,
+			Message: fmt.Sprintf("arquivo_mirrors accepts at most %d URLs", services.MaxMirrors),
+		}
+	}
+	// Validate and normalize source URLs before they are logged or fetched
+	if req.Upload == nil {
+		if req.Arquivo, err = services.ValidateSourceURL(req.Arquivo); err != nil {
+			return opts, rules, fiber.StatusBadRequest, urlErrorResponse("arquivo", err)
+		}
+	}
+	for i, mirror := range req.ArquivoMirrors {
+		if req.ArquivoMirrors[i], err = services.ValidateSourceURL(mirror); err != nil {
+			return opts, rules, fiber.StatusBadRequest, urlErrorResponse(fmt.Sprintf("arquivo_mirrors[%d]", i), err)
+		}
+	}
+
+	if err := services.ValidateUserAgent(req.UserAgent); err != nil {
+		return opts, rules, fiber.StatusBadRequest, models.ProcessResponse{
+			Success: false,
+			Message: "user_agent: " + err.Error(),
+		}
+	}
+
+	if req.Archive && h.archival == nil {
+		return opts, rules, fiber.StatusBadRequest, models.ProcessResponse{
+			Success: false,
+			Message: "archive is disabled on this server",
+		}
+	}
+
+	// With archives enabled, zip is checked once the input is known
+	if req.Zip && !req.Rasterize && h.archiveLimits.MaxEntries == 0 {
+		return opts, rules, fiber.StatusBadRequest, models.ProcessResponse{
+			Success: false,
+			Message: "zip requires rasterize",
+		}
+	}
+
+	return opts, rules, 0, models.ProcessResponse{}
 }
 
 // GetFile handles GET /api/files/:id
 func (h *ProcessHandler) GetFile(c fiber.Ctx) error {
-	fileIDWithExt := c.Params("id")
-	if fileIDWithExt == "" {
-		return c.Status(fiber.StatusBadRequest).SendString("File ID is required")
+	return h.files.GetFile(c)
+}
+
+// isNotModified evaluates If-None-Match (preferred) or If-Modified-Since
+func isNotModified(ifNoneMatch, ifModifiedSince, etag string, lastModified time.Time) bool {
+	if ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ifModifiedSince != "" {
+		if since, err := http.ParseTime(ifModifiedSince); err == nil {
+			return !lastModified.After(since)
+		}
+	}
+	return false
+}
+
+// store moves a finished file and its original out of the job directory and
+// keeps them, recording the tenant, for the tenant's TTL (server default when unset)
+func (h *ProcessHandler) store(t *tenant.Tenant, job *storage.JobDir, filePath, originalPath, mediaType string) (string, error) {
+	filePath, err := job.Keep(filePath, mediaType)
+	if err != nil {
+		return "", err
+	}
+	if originalPath, err = job.Keep(originalPath, mediaType); err != nil {
+		os.Remove(filePath)
+		return "", err
+	}
+
+	var name string
+	var ttl time.Duration
+	if t != nil {
+		name, ttl = t.Name, t.FileTTL
+	}
+	id, err := h.tempStorage.StoreWithTTL(filePath, originalPath, mediaType, name, ttl)
+	if err != nil {
+		os.Remove(filePath)
+		os.Remove(originalPath)
+		return "", err
+	}
+	if requestID := job.RequestID(); requestID != "" {
+		h.tempStorage.SetRequestID(id, requestID)
+	}
+	return id, nil
+}
+
+// checkCost estimates the work of converting path and rejects it with 422
+// when over budget. Inputs ffprobe cannot read are left to the converter
+func (h *ProcessHandler) checkCost(ctx context.Context, path string) (int, models.ProcessResponse) {
+	estimate, err := services.EstimateCost(ctx, path)
+	if err != nil {
+		httpLog.WithID(services.RequestIDFrom(ctx)).Warnf("⚠️  Cost estimate failed: %v", err)
+		return 0, models.ProcessResponse{}
+	}
+	if !h.costBudget.Exceeds(estimate) {
+		return 0, models.ProcessResponse{}
+	}
+	return fiber.StatusUnprocessableEntity, models.ProcessResponse{
+		Success: false,
+		Message: fmt.Sprintf("Input exceeds the processing budget: %.0f megapixel-frames, %.0fs of audio", estimate.MegapixelFrames, estimate.AudioSeconds),
+		Code:    "cost_exceeded",
+		Cost: &models.CostInfo{
+			MegapixelFrames:    estimate.MegapixelFrames,
+			AudioSeconds:       estimate.AudioSeconds,
+			MaxMegapixelFrames: h.costBudget.MaxMegapixelFrames,
+			MaxAudioSeconds:    h.costBudget.MaxAudioSeconds,
+		},
+	}
+}
+
+// passThrough stores the downloaded file unmodified and returns its URL
+func (h *ProcessHandler) passThrough(t *tenant.Tenant, job *storage.JobDir, inputData []byte, mediaType, inputFormat, originalPath, reason string) (int, models.ProcessResponse) {
+	outputPath := job.Path("output" + getExtensionForFormat(inputFormat))
+	if err := os.WriteFile(outputPath, inputData, 0644); err != nil {
+		return fiber.StatusInternalServerError, models.ProcessResponse{
+			Success: false,
+			Message: "Failed to save file",
+		}
+	}
+
+	fileID, err := h.store(t, job, outputPath, originalPath, mediaType)
+	if err != nil {
+		return fiber.StatusInternalServerError, models.ProcessResponse{
+			Success: false,
+			Message: "Failed to store processed file",
+		}
+	}
+
+	return fiber.StatusOK, models.ProcessResponse{
+		Success:    true,
+		Message:    "arquivo não modificado (regra)",
+		NovaURL:    h.fileURL(fileID, getExtensionForFormat(inputFormat)),
+		MediaType:  mediaType,
+		FileID:     fileID,
+		Skipped:    true,
+		SkipReason: reason,
 	}
+}
 
-	// Remove extension from ID (e.g., "abc123.opus" -> "abc123")
-	fileID := fileIDWithExt
-	if idx := strings.LastIndex(fileIDWithExt, "."); idx > 0 {
-		fileID = fileIDWithExt[:idx]
+// processPages rasterizes a multi-page document and runs every page through the
+// image converter, returning one file per page or a single zip
+func (h *ProcessHandler) processPages(ctx context.Context, t *tenant.Tenant, job *storage.JobDir, req *models.ProcessRequest, inputData []byte, inputFormat, originalPath string, opts services.ProcessOptions) (int, models.ProcessResponse) {
+	converter, ok := h.converters.Get("image")
+	if !ok {
+		return fiber.StatusBadRequest, models.ProcessResponse{
+			Success: false,
+			Message: "Unsupported media type: image",
+		}
 	}
 
-	log.Printf("🔍 GetFile: id_with_ext=%s, id=%s", fileIDWithExt, fileID)
+	reqLog := httpLog.WithID(services.RequestIDFrom(ctx))
+	reqLog.Infof("🖨️  Rasterizing %s pages...", inputFormat)
+	processingStart := time.Now()
 
-	// Get file from storage
-	tf, err := h.tempStorage.Get(fileID)
+	trace := services.TraceFrom(ctx)
+	pages, err := services.RasterizePages(ctx, inputData, inputFormat, job.Dir())
+	trace.Mark("rasterize")
 	if err != nil {
-		log.Printf("❌ GetFile: storage.Get failed: %v", err)
-		return c.Status(fiber.StatusNotFound).SendString("File not found or expired")
+		reportFailure(err, "rasterization failed", "document", inputFormat, len(inputData), opts)
+		return processErrorStatus(err), models.ProcessResponse{
+			Success: false,
+			Message: fmt.Sprintf("Rasterization failed: %v", err),
+		}
+	}
+
+	outputPaths := make([]string, 0, len(pages))
+	for i, page := range pages {
+		outputPath := job.Path(fmt.Sprintf("page-%03d.png", i+1))
+		result, err := converter.Process(ctx, page, outputPath, "png", opts)
+		if err != nil {
+			reportFailure(err, "image conversion failed", "image", "png", len(page), opts)
+			return processErrorStatus(err), models.ProcessResponse{
+				Success: false,
+				Message: fmt.Sprintf("Processing page %d failed: %v", i+1, err),
+			}
+		}
+		if result.OutputPath != "" {
+			outputPath = result.OutputPath
+		}
+		outputPaths = append(outputPaths, outputPath)
 	}
+	trace.Mark("convert")
+	jobEvent(ctx, models.JobEventStoring)
+
+	if req.Zip {
+		zipPath := job.Path("pages.zip")
+		if err := writePagesZip(zipPath, outputPaths); err != nil {
+			return fiber.StatusInternalServerError, models.ProcessResponse{
+				Success: false,
+				Message: fmt.Sprintf("Failed to build zip: %v", err),
+			}
+		}
+
+		fileID, err := h.store(t, job, zipPath, originalPath, "archive")
+		if err != nil {
+			return fiber.StatusInternalServerError, models.ProcessResponse{
+				Success: false,
+				Message: "Failed to store processed file",
+			}
+		}
+		trace.Mark("store")
 
-	log.Printf("📂 GetFile: found file path=%s", tf.Path)
+		reqLog.Infof("✅ Rasterized: format=%s, pages=%d, id=%s, time=%dms",
+			inputFormat, len(pages), fileID, time.Since(processingStart).Milliseconds())
 
-	// Check if file exists
-	if _, err := os.Stat(tf.Path); os.IsNotExist(err) {
-		log.Printf("❌ GetFile: file not found on disk: %s", tf.Path)
-		return c.Status(fiber.StatusNotFound).SendString("File not found on disk")
+		return fiber.StatusOK, models.ProcessResponse{
+			Success:   true,
+			Message:   "arquivo modificado com sucesso!",
+			NovaURL:   h.fileURL(fileID, ".zip"),
+			MediaType: "archive",
+			FileID:    fileID,
+			Profile:   opts.Profile.Name,
+			Level:     opts.Level,
+		}
+	}
+
+	infos := make([]models.PageInfo, 0, len(outputPaths))
+	for i, p := range outputPaths {
+		fileID, err := h.store(t, job, p, originalPath, "image")
+		if err != nil {
+			return fiber.StatusInternalServerError, models.ProcessResponse{
+				Success: false,
+				Message: "Failed to store processed file",
+			}
+		}
+		infos = append(infos, models.PageInfo{
+			Page:    i + 1,
+			NovaURL: h.fileURL(fileID, filepath.Ext(p)),
+			FileID:  fileID,
+		})
 	}
+	trace.Mark("store")
 
-	// Set appropriate content type based on file extension
-	contentType := getContentTypeFromPath(tf.Path)
-	c.Set("Content-Type", contentType)
-	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(tf.Path)))
+	reqLog.Infof("✅ Rasterized: format=%s, pages=%d, time=%dms",
+		inputFormat, len(pages), time.Since(processingStart).Milliseconds())
 
-	// Send file
-	return c.SendFile(tf.Path)
+	return fiber.StatusOK, models.ProcessResponse{
+		Success:   true,
+		Message:   "arquivo modificado com sucesso!",
+		NovaURL:   infos[0].NovaURL,
+		MediaType: "image",
+		FileID:    infos[0].FileID,
+		Profile:   opts.Profile.Name,
+		Level:     opts.Level,
+		Pages:     infos,
+	}
 }
 
 // Helper functions
 
+// writePagesZip bundles page files into a zip named page-001.png, page-002.png, ...
+func writePagesZip(zipPath string, pagePaths []string) error {
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for i, p := range pagePaths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create(fmt.Sprintf("page-%03d%s", i+1, filepath.Ext(p)))
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// buildOptions merges request settings over the tenant and server defaults and validates them
+func (h *ProcessHandler) buildOptions(req *models.ProcessRequest, t *tenant.Tenant) (services.ProcessOptions, error) {
+	opts := h.defaults
+	if t != nil && t.DefaultProfile != "" {
+		if profile, ok := services.LookupProfile(t.DefaultProfile); ok {
+			opts.Profile = profile
+		}
+	}
+
+	if req.Profile != "" {
+		profile, ok := services.LookupProfile(req.Profile)
+		if !ok {
+			return opts, fmt.Errorf("unknown profile %q (supported: %s)", req.Profile, strings.Join(services.ProfileNames(), ", "))
+		}
+		opts.Profile = profile
+	}
+
+	// A level replaces the script techniques, so their settings cannot apply
+	if req.Level != "" {
+		if !services.ValidAFLevel(req.Level) {
+			return opts, fmt.Errorf("unknown level %q (supported: %s)", req.Level, strings.Join(services.AFLevels, ", "))
+		}
+		if req.Profile != "" || req.Speed != 0 || len(req.SpeedRange) > 0 || req.TrimSilence || len(req.Metadata) > 0 || req.GPSRegion != nil ||
+			req.NoMarker || req.PreserveDuration != nil || req.Stream {
+			return opts, fmt.Errorf("level cannot be combined with profile, speed, trim_silence, metadata, gps_region, no_marker, preserve_duration or stream")
+		}
+		// The level pipelines write no metadata, so tenant defaults would be lost
+		if t != nil && h.tenantMetadata != nil && len(h.tenantMetadata.Get(t.Name)) > 0 {
+			return opts, fmt.Errorf("level cannot be used while tenant %q has default metadata", t.Name)
+		}
+		opts.Level = req.Level
+		opts.Profile = services.Profile{}
+	}
+
+	opts.TrimSilence = req.TrimSilence
+	if req.SilenceThresholdDB != nil {
+		if *req.SilenceThresholdDB < -100 || *req.SilenceThresholdDB > 0 {
+			return opts, fmt.Errorf("silence_threshold_db must be between -100 and 0")
+		}
+		opts.SilenceThresholdDB = *req.SilenceThresholdDB
+	}
+	if req.SilenceKeepMs != 0 {
+		if req.SilenceKeepMs < 0 || req.SilenceKeepMs > 10000 {
+			return opts, fmt.Errorf("silence_keep_ms must be between 0 and 10000")
+		}
+		opts.SilenceKeep = time.Duration(req.SilenceKeepMs) * time.Millisecond
+	}
+
+	if req.PreserveDuration != nil {
+		opts.PreserveDuration = *req.PreserveDuration
+	}
+
+	if req.Speed != 0 && len(req.SpeedRange) > 0 {
+		return opts, fmt.Errorf("speed and speed_range are mutually exclusive")
+	}
+	if req.Speed != 0 {
+		if req.Speed < services.MinSpeed || req.Speed > services.MaxSpeed {
+			return opts, fmt.Errorf("speed must be between %.1f and %.1f", services.MinSpeed, services.MaxSpeed)
+		}
+		opts.Speed = req.Speed
+	}
+	if len(req.SpeedRange) > 0 {
+		if len(req.SpeedRange) != 2 || req.SpeedRange[0] > req.SpeedRange[1] {
+			return opts, fmt.Errorf("speed_range must be [min, max]")
+		}
+		if req.SpeedRange[0] < services.MinSpeed || req.SpeedRange[1] > services.MaxSpeed {
+			return opts, fmt.Errorf("speed_range must be within %.1f and %.1f", services.MinSpeed, services.MaxSpeed)
+		}
+		opts.SpeedMin, opts.SpeedMax = req.SpeedRange[0], req.SpeedRange[1]
+	}
+
+	// Templates are checked here so a bad placeholder fails before the download
+	if err := services.ValidateVariables(req.Variables); err != nil {
+		return opts, fmt.Errorf("variables: %w", err)
+	}
+	if _, err := services.ExpandTemplate(req.Filename, req.Variables); err != nil {
+		return opts, fmt.Errorf("filename: %w", err)
+	}
+	if req.NoMarker && len(req.Metadata) > 0 {
+		return opts, fmt.Errorf("no_marker and metadata are mutually exclusive")
+	}
+	opts.NoMarker = req.NoMarker
+	// Tenant defaults are merged unless the request asks for no metadata at all
+	var defaults map[string]string
+	if t != nil && h.tenantMetadata != nil && !req.NoMarker {
+		defaults = h.tenantMetadata.Get(t.Name)
+	}
+	metadata, err := services.MergeMetadata(defaults, req.Metadata, req.Variables)
+	if err != nil {
+		return opts, err
+	}
+	opts.Metadata = metadata
+
+	if req.GPSRegion != nil {
+		if !h.gpsSynthesis {
+			return opts, fmt.Errorf("gps_region is disabled on this server")
+		}
+		if req.NoMarker {
+			return opts, fmt.Errorf("no_marker and gps_region are mutually exclusive")
+		}
+		region := services.GeoRegion{
+			MinLat: req.GPSRegion.MinLat, MaxLat: req.GPSRegion.MaxLat,
+			MinLon: req.GPSRegion.MinLon, MaxLon: req.GPSRegion.MaxLon,
+		}
+		if err := region.Validate(); err != nil {
+			return opts, fmt.Errorf("gps_region: %w", err)
+		}
+		opts.GPSRegion = &region
+	}
+
+	return opts.ResolveSpeed(), nil
+}
+
+// supportedExtensions lists the input formats of the registered converters
+// as extensions, e.g. ".mp3, .opus, .jpg"
+func (h *ProcessHandler) supportedExtensions() string {
+	var extensions []string
+	for _, mediaType := range h.converters.MediaTypes() {
+		for _, f := range h.converters.Formats(mediaType) {
+			extensions = append(extensions, "."+f.Input)
+		}
+	}
+	return strings.Join(extensions, ", ")
+}
+
+// urlErrorResponse builds the 400 body naming the offending field and the validation code
+func urlErrorResponse(field string, err error) models.ProcessResponse {
+	resp := models.ProcessResponse{
+		Success: false,
+		Message: fmt.Sprintf("%s: %v", field, err),
+		Code:    "url_invalid",
+	}
+	var urlErr *services.URLError
+	if errors.As(err, &urlErr) {
+		resp.Code = urlErr.Code
+	}
+	return resp
+}
+
+// downloadErrorCode names download failures the client can act on
+func downloadErrorCode(err error) string {
+	switch {
+	case errors.Is(err, services.ErrContentMismatch):
+		return "download_content_mismatch"
+	case errors.Is(err, services.ErrEmptyDownload):
+		return "download_empty"
+	case services.IsTransient(err):
+		return "download_unavailable"
+	default:
+		return ""
+	}
+}
+
+// processErrorStatus maps conversion failures to HTTP status codes:
+// bad input is the client's problem (422), a full disk is 507, the rest 500
+func processErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidInput), errors.Is(err, services.ErrUnsupportedCodec):
+		return fiber.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrDiskFull):
+		return fiber.StatusInsufficientStorage
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+// reportFailure sends a conversion failure to error reporting, if enabled
+// Bad input and client cancellations are expected and reported as warnings
+// or not at all; only the class and sizes leave the process, never the URL
+func reportFailure(err error, message, mediaType, inputFormat string, inputSize int, opts services.ProcessOptions) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	class := services.FailureClass(err)
+	tags := map[string]string{
+		"media_type":   mediaType,
+		"input_format": inputFormat,
+		"error_class":  class,
+	}
+	var execErr *services.ExecError
+	if errors.As(err, &execErr) {
+		tags["tool"] = execErr.Tool
+	}
+	if opts.Profile.Name != "" {
+		tags["profile"] = opts.Profile.Name
+	}
+	level := "error"
+	if class == "invalid_input" || class == "unsupported_codec" {
+		level = "warning"
+	}
+	errreport.Capture(errreport.Failure{
+		Err:     err,
+		Message: message,
+		Level:   level,
+		Tags:    tags,
+		Extra:   map[string]any{"input_size": inputSize},
+	})
+}
+
+// isMislabeled reports whether sniffed content contradicts the URL between image and video
+func isMislabeled(urlType, sniffedType string) bool {
+	visual := func(t string) bool { return t == "image" || t == "video" }
+	return visual(urlType) && visual(sniffedType) && urlType != sniffedType
+}
+
+// buildRules validates the conditional processing fields of a request
+func buildRules(req *models.ProcessRequest) (services.ProcessRules, error) {
+	rules := services.ProcessRules{
+		SkipIfSmallerThan:   req.SkipIfSmallerThan,
+		OnlyIfDurationBelow: req.OnlyIfDurationBelow,
+	}
+	if rules.SkipIfSmallerThan < 0 {
+		return rules, fmt.Errorf("skip_if_smaller_than must be positive")
+	}
+	if rules.OnlyIfDurationBelow < 0 {
+		return rules, fmt.Errorf("only_if_duration_below must be positive")
+	}
+	if req.ErrorIfResolutionAbove != "" {
+		w, h, err := services.ParseResolution(req.ErrorIfResolutionAbove)
+		if err != nil {
+			return rules, fmt.Errorf("error_if_resolution_above: %w", err)
+		}
+		rules.MaxWidth, rules.MaxHeight = w, h
+	}
+	return rules, nil
+}
+
 // detectMediaTypeAndFormatFromURL detects both media type and format from URL
+// Query string and fragment are ignored, so presigned ".../a.jpg?X-Amz-..." URLs match
 func detectMediaTypeAndFormatFromURL(url string) (mediaType string, format string) {
 	urlLower := strings.ToLower(url)
+	if u, err := neturl.Parse(url); err == nil {
+		urlLower = strings.ToLower(u.Path)
+	}
 
 	// Audio formats
 	if strings.HasSuffix(urlLower, ".mp3") {
@@ -248,6 +1246,15 @@ func detectMediaTypeAndFormatFromURL(url string) (mediaType string, format strin
 	if strings.HasSuffix(urlLower, ".webp") {
 		return "image", "webp"
 	}
+	if strings.HasSuffix(urlLower, ".tif") || strings.HasSuffix(urlLower, ".tiff") {
+		return "image", "tiff"
+	}
+	if strings.HasSuffix(urlLower, ".bmp") {
+		return "image", "bmp"
+	}
+	if strings.HasSuffix(urlLower, ".svg") {
+		return "image", "svg"
+	}
 
 	// Video formats
 	if strings.HasSuffix(urlLower, ".mp4") {
@@ -265,10 +1272,44 @@ func detectMediaTypeAndFormatFromURL(url string) (mediaType string, format strin
 	if strings.HasSuffix(urlLower, ".webm") {
 		return "video", "webm"
 	}
+	if strings.HasSuffix(urlLower, ".3gp") || strings.HasSuffix(urlLower, ".3g2") {
+		return "video", "3gp"
+	}
+	if strings.HasSuffix(urlLower, ".ts") || strings.HasSuffix(urlLower, ".mts") || strings.HasSuffix(urlLower, ".m2ts") {
+		return "video", "ts"
+	}
+
+	// Document formats
+	if strings.HasSuffix(urlLower, ".pdf") {
+		return "document", "pdf"
+	}
 
 	return "", ""
 }
 
+// appliedSpeed returns the speed used for the output, or 0 when unchanged
+func appliedSpeed(mediaType string, opts services.ProcessOptions) float64 {
+	if mediaType == "image" || opts.Speed == 1.0 {
+		return 0
+	}
+	return math.Round(opts.Speed*10000) / 10000
+}
+
+// toEncodingInfo converts the video encoder decision to its response model
+func toEncodingInfo(d *services.EncodingDecision) *models.EncodingInfo {
+	if d == nil {
+		return nil
+	}
+	return &models.EncodingInfo{
+		CRF:               d.CRF,
+		MaxBitrateKbps:    d.MaxBitrateKbps,
+		SourceBitrateKbps: d.SourceBitrateKbps,
+		SourceResolution:  d.SourceResolution,
+		Reason:            d.Reason,
+		AudioCopied:       d.AudioCopied,
+	}
+}
+
 // getExtensionForFormat returns extension for a specific format
 func getExtensionForFormat(format string) string {
 	format = strings.ToLower(format)
@@ -313,6 +1354,12 @@ func getContentTypeFromPath(filePath string) string {
 		return "image/png"
 	case ".webp":
 		return "image/webp"
+	case ".svg":
+		return "image/svg+xml"
+	case ".tif", ".tiff":
+		return "image/tiff"
+	case ".bmp":
+		return "image/bmp"
 	case ".mp4":
 		return "video/mp4"
 	case ".avi":
@@ -323,6 +1370,14 @@ func getContentTypeFromPath(filePath string) string {
 		return "video/x-matroska"
 	case ".webm":
 		return "video/webm"
+	case ".3gp":
+		return "video/3gpp"
+	case ".ts":
+		return "video/mp2t"
+	case ".pdf":
+		return "application/pdf"
+	case ".zip":
+		return "application/zip"
 	default:
 		return "application/octet-stream"
 	}
@@ -330,22 +1385,77 @@ func getContentTypeFromPath(filePath string) string {
 
 // Health handles GET /api/health
 func (h *ProcessHandler) Health(c fiber.Ctx) error {
-	// Check FFmpeg availability
-	ffmpegVersion := "unknown"
-	if output, err := exec.Command("ffmpeg", "-version").Output(); err == nil {
-		lines := strings.Split(string(output), "\n")
-		if len(lines) > 0 {
-			ffmpegVersion = strings.TrimSpace(lines[0])
-		}
-	}
+	// Toolchain is probed once per process, not per health check
+	capabilities := services.DetectCapabilities()
+	ffmpegVersion := capabilities.FFmpegVersion
 
 	// Get temp storage stats
 	storageStats := h.tempStorage.GetStats()
 
-	return c.JSON(fiber.Map{
-		"status":        "healthy",
-		"timestamp":     time.Now().Format(time.RFC3339),
+	health := fiber.Map{
+		"status":         "healthy",
+		"timestamp":      time.Now().Format(time.RFC3339),
 		"ffmpeg_version": ffmpegVersion,
-		"temp_storage":  storageStats,
+		"temp_storage":   storageStats,
+		"downloads":      h.downloader.GetStats(),
+		"converters":     h.converters.Stats(),
+		"capabilities":   capabilities,
+	}
+	if coalesced := h.downloader.Coalesced(); coalesced > 0 {
+		health["downloads_coalesced"] = coalesced
+	}
+	if h.memoryGate != nil {
+		health["memory_admission"] = h.memoryGate.GetStats()
+	}
+
+	return c.JSON(health)
+}
+
+// Capabilities handles GET /api/capabilities so clients can adapt to the
+// formats, encoders, limits and profiles of this deployment
+func (h *ProcessHandler) Capabilities(c fiber.Ctx) error {
+	toolchain := services.DetectCapabilities()
+
+	mediaTypes := make([]models.MediaTypeInfo, 0)
+	for _, mediaType := range h.converters.MediaTypes() {
+		info := models.MediaTypeInfo{MediaType: mediaType}
+		for _, f := range h.converters.Formats(mediaType) {
+			info.Formats = append(info.Formats, models.FormatInfo{Input: f.Input, Output: f.Output})
+		}
+		mediaTypes = append(mediaTypes, info)
+	}
+
+	profiles := make([]models.ProfileInfo, 0)
+	for _, name := range services.ProfileNames() {
+		profile, _ := services.LookupProfile(name)
+		profiles = append(profiles, models.ProfileInfo{Name: name, Techniques: profile.Techniques()})
+	}
+
+	similarityEngines := make([]models.SimilarityEngineInfo, 0)
+	if h.comparator != nil {
+		for _, cfg := range h.comparator.Engines() {
+			similarityEngines = append(similarityEngines, models.SimilarityEngineInfo{Engine: cfg.Engine, MediaTypes: cfg.MediaTypes, Threshold: cfg.Threshold})
+		}
+	}
+
+	return c.JSON(models.CapabilitiesResponse{
+		MediaTypes:       mediaTypes,
+		FFmpegVersion:    toolchain.FFmpegVersion,
+		Encoders:         toolchain.Encoders,
+		HardwareEncoders: toolchain.HardwareEncoders,
+		Tools:            toolchain.Tools,
+		Limits: models.CapabilityLimits{
+			MaxDownloadBytes:      h.downloader.MaxSize(),
+			MaxMirrors:            services.MaxMirrors,
+			MaxBatchItems:         h.batchLimits.MaxItems,
+			MinSpeed:              services.MinSpeed,
+			MaxSpeed:              services.MaxSpeed,
+			RequestTimeoutSeconds: int(h.requestTimeout.Seconds()),
+		},
+		Techniques:        services.TechniqueNames(),
+		Profiles:          profiles,
+		DefaultProfile:    h.defaults.Profile.Name,
+		Levels:            services.AFLevels,
+		SimilarityEngines: similarityEngines,
 	})
 }
@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/cdn"
+	"fingerprint-converter/internal/jobs"
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/services"
+	"fingerprint-converter/internal/storage"
+	"fingerprint-converter/internal/tenant"
+)
+
+func TestIsNotModified(t *testing.T) {
+	etag := `"abc123-2048"`
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		ifModSince  string
+		want        bool
+	}{
+		{"no conditions", "", "", false},
+		{"etag match", etag, "", true},
+		{"weak etag in list", `"other", W/"abc123-2048"`, "", true},
+		{"wildcard", "*", "", true},
+		{"etag mismatch wins over date", `"other"`, modified.Format(http.TimeFormat), false},
+		{"not modified since", "", modified.Format(http.TimeFormat), true},
+		{"modified after", "", modified.Add(-time.Hour).Format(http.TimeFormat), false},
+		{"bad date", "", "yesterday", false},
+	}
+
+	for _, tt := range tests {
+		if got := isNotModified(tt.ifNoneMatch, tt.ifModSince, etag, modified); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildOptionsGPSRegion(t *testing.T) {
+	region := &models.GPSRegion{MinLat: -23.7, MaxLat: -23.4, MinLon: -46.8, MaxLon: -46.4}
+	h := &ProcessHandler{}
+
+	if _, err := h.buildOptions(&models.ProcessRequest{GPSRegion: region}, nil); err == nil {
+		t.Error("gps_region accepted while GPS synthesis is disabled")
+	}
+
+	h.SetGPSSynthesis(true)
+	opts, err := h.buildOptions(&models.ProcessRequest{GPSRegion: region}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.GPSRegion == nil || opts.GPSRegion.MinLat != region.MinLat || opts.GPSRegion.MaxLon != region.MaxLon {
+		t.Errorf("GPSRegion = %+v", opts.GPSRegion)
+	}
+
+	for name, req := range map[string]models.ProcessRequest{
+		"inverted":  {GPSRegion: &models.GPSRegion{MinLat: 10, MaxLat: 5, MinLon: 0, MaxLon: 1}},
+		"off globe": {GPSRegion: &models.GPSRegion{MinLat: 0, MaxLat: 95, MinLon: 0, MaxLon: 1}},
+		"no_marker": {GPSRegion: region, NoMarker: true},
+	} {
+		if _, err := h.buildOptions(&req, nil); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestBuildOptionsLevel(t *testing.T) {
+	standard, _ := services.LookupProfile(services.DefaultProfileName)
+	h := &ProcessHandler{defaults: services.ProcessOptions{Profile: standard}}
+
+	opts, err := h.buildOptions(&models.ProcessRequest{Level: "paranoid"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Level != "paranoid" || opts.Profile.Name != "" {
+		t.Errorf("Level = %q, Profile = %q; want the level without the default profile", opts.Level, opts.Profile.Name)
+	}
+
+	for name, req := range map[string]models.ProcessRequest{
+		"unknown":           {Level: "extreme"},
+		"profile":           {Level: "basic", Profile: services.DefaultProfileName},
+		"speed":             {Level: "basic", Speed: 1.05},
+		"metadata":          {Level: "basic", Metadata: map[string]string{"artist": "Acme"}},
+		"no_marker":         {Level: "basic", NoMarker: true},
+		"preserve_duration": {Level: "basic", PreserveDuration: new(bool)},
+		"stream":            {Level: "basic", Stream: true},
+	} {
+		if _, err := h.buildOptions(&req, nil); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+
+	// Tenant default metadata would be dropped by the level pipeline
+	defaults, _ := tenant.NewMetadataDefaults("")
+	defaults.Set("marketing", map[string]string{"copyright": "Acme"})
+	h.SetTenantMetadata(defaults)
+	if _, err := h.buildOptions(&models.ProcessRequest{Level: "basic"}, &tenant.Tenant{Name: "marketing"}); err == nil {
+		t.Error("tenant default metadata: accepted")
+	}
+	if _, err := h.buildOptions(&models.ProcessRequest{Level: "basic"}, &tenant.Tenant{Name: "support"}); err != nil {
+		t.Errorf("tenant without defaults: %v", err)
+	}
+}
+
+func TestBuildOptionsTenantMetadata(t *testing.T) {
+	defaults, _ := tenant.NewMetadataDefaults("")
+	defaults.Set("marketing", map[string]string{"copyright": "Acme", "artist": "Acme"})
+	h := &ProcessHandler{}
+	h.SetTenantMetadata(defaults)
+	marketing := &tenant.Tenant{Name: "marketing"}
+
+	opts, err := h.buildOptions(&models.ProcessRequest{Metadata: map[string]string{"artist": "Guest"}}, marketing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Metadata["copyright"] != "Acme" || opts.Metadata["artist"] != "Guest" {
+		t.Errorf("Metadata = %v", opts.Metadata)
+	}
+
+	// Other tenants, anonymous and no_marker requests get no defaults
+	for name, tt := range map[string]struct {
+		req models.ProcessRequest
+		t   *tenant.Tenant
+	}{
+		"other tenant": {models.ProcessRequest{}, &tenant.Tenant{Name: "support"}},
+		"anonymous":    {models.ProcessRequest{}, nil},
+		"no_marker":    {models.ProcessRequest{NoMarker: true}, marketing},
+	} {
+		opts, err := h.buildOptions(&tt.req, tt.t)
+		if err != nil || len(opts.Metadata) != 0 {
+			t.Errorf("%s: Metadata = %v, err = %v", name, opts.Metadata, err)
+		}
+	}
+}
+
+func TestProcessUndetectedMediaListsFormats(t *testing.T) {
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(bytes.Repeat([]byte{0x00, 0xff}, 64))
+	}))
+	defer source.Close()
+
+	h, _ := newTestProcessHandler(t, &fakeConverter{mediaType: "audio"}, &fakeConverter{mediaType: "image"})
+	status, resp := h.process(context.Background(), &models.ProcessRequest{Arquivo: source.URL + "/blob"}, nil)
+	if status != http.StatusBadRequest {
+		t.Fatalf("process = %d: %s", status, resp.Message)
+	}
+	// Formats come from the registered converters only
+	if !strings.Contains(resp.Message, ".wav") || !strings.Contains(resp.Message, ".webp") || strings.Contains(resp.Message, ".pdf") {
+		t.Errorf("message = %q", resp.Message)
+	}
+}
+
+func TestProcessTimings(t *testing.T) {
+	png, err := os.ReadFile(filepath.Join("testdata", "tiny.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write(png)
+	}))
+	defer source.Close()
+
+	h, _ := newTestProcessHandler(t, &fakeConverter{mediaType: "image", output: "processed image"})
+
+	status, resp := h.process(context.Background(), &models.ProcessRequest{Arquivo: source.URL + "/a.png"}, nil)
+	if status != http.StatusOK {
+		t.Fatalf("process = %d: %s", status, resp.Message)
+	}
+	timings := resp.Timings
+	if timings == nil {
+		t.Fatal("no timings in response")
+	}
+	if timings.DownloadMs < 40 {
+		t.Errorf("download_ms = %d, want the 50ms the origin took", timings.DownloadMs)
+	}
+	if sum := timings.DownloadMs + timings.ProbeMs + timings.WaitMs + timings.ConvertMs + timings.StoreMs; timings.TotalMs < sum {
+		t.Errorf("total_ms = %d, less than the phases' %d", timings.TotalMs, sum)
+	}
+
+	// Failed requests report how far they got
+	_, resp = h.process(context.Background(), &models.ProcessRequest{Arquivo: "http://127.0.0.1:1/a.png"}, nil)
+	if resp.Success || resp.Timings == nil || resp.Timings.ConvertMs != 0 {
+		t.Errorf("failed request timings = %+v", resp.Timings)
+	}
+}
+
+func TestProcessUpload(t *testing.T) {
+	png, err := os.ReadFile(filepath.Join("testdata", "tiny.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _ := newTestProcessHandler(t, &fakeConverter{mediaType: "image", output: "processed image"})
+	app := fiber.New()
+	app.Post("/api/process", h.Process)
+
+	post := func(options string, data []byte) (int, models.ProcessResponse) {
+		t.Helper()
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		if options != "" {
+			form.WriteField("options", options)
+		}
+		if data != nil {
+			part, _ := form.CreateFormFile("file", "photo.png")
+			part.Write(data)
+		}
+		form.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/process", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		res, err := app.Test(req, 10*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp models.ProcessResponse
+		if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, resp
+	}
+
+	status, resp := post(`{"filename":"upload"}`, png)
+	if status != fiber.StatusOK || !resp.Success || resp.MediaType != "image" {
+		t.Fatalf("upload = %d %+v", status, resp)
+	}
+	if !strings.Contains(resp.NovaURL, "name=upload") {
+		t.Errorf("options not applied: nova_url=%s", resp.NovaURL)
+	}
+
+	tests := []struct {
+		name    string
+		options string
+		data    []byte
+		want    int
+	}{
+		{"no file", `{"filename":"upload"}`, nil, fiber.StatusBadRequest},
+		{"bad options", `{`, png, fiber.StatusBadRequest},
+		{"with arquivo", `{"arquivo":"https://example.com/a.png"}`, png, fiber.StatusBadRequest},
+		{"async", `{"async":true}`, png, fiber.StatusBadRequest},
+		{"too large", "", make([]byte, 2<<20), fiber.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		if status, resp := post(tt.options, tt.data); status != tt.want {
+			t.Errorf("%s = %d %+v, want %d", tt.name, status, resp, tt.want)
+		}
+	}
+}
+
+func TestProcessRejectsLevelForSVG(t *testing.T) {
+	h, _ := newTestProcessHandler(t, &fakeConverter{mediaType: "image", output: "processed image"})
+	svg := []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" width="1" height="1"/>`)
+
+	req := &models.ProcessRequest{Upload: &models.Upload{Name: "logo.svg", Data: svg}, Level: "moderate"}
+	if status, resp := h.process(context.Background(), req, nil); status != fiber.StatusBadRequest || resp.Success {
+		t.Errorf("svg with level = %d %+v, want 400", status, resp)
+	}
+	req.Level = ""
+	if status, resp := h.process(context.Background(), req, nil); status != fiber.StatusOK {
+		t.Errorf("svg without level = %d %+v", status, resp)
+	}
+}
+
+func TestProcessAsync(t *testing.T) {
+	png, err := os.ReadFile(filepath.Join("testdata", "tiny.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(png)
+	}))
+	defer source.Close()
+
+	h, _ := newTestProcessHandler(t, &fakeConverter{mediaType: "image", output: "processed image"})
+
+	app := fiber.New()
+	app.Post("/api/process", h.Process)
+	post := func() *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/process", strings.NewReader(`{"arquivo":"`+source.URL+`/a.png","async":true}`))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, 10*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	if res := post(); res.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("async without jobs = %d, want 400", res.StatusCode)
+	}
+
+	scheduler, err := jobs.NewScheduler("", 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobHandler := NewJobHandler(scheduler, h, nil, 0, 0)
+	scheduler.Start(jobHandler.Run)
+	defer scheduler.Stop()
+	h.SetAsync(jobHandler)
+	app.Get("/api/jobs/:id", jobHandler.Get)
+
+	res := post()
+	var queued models.JobView
+	if err := json.NewDecoder(res.Body).Decode(&queued); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != fiber.StatusAccepted || queued.ID == "" || res.Header.Get("Location") != "/api/jobs/"+queued.ID {
+		t.Fatalf("async = %d, id=%q, location=%q", res.StatusCode, queued.ID, res.Header.Get("Location"))
+	}
+	if queued.Request.Async {
+		t.Error("job request still async")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		res, err := app.Test(httptest.NewRequest(http.MethodGet, res.Header.Get("Location"), nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var view models.JobView
+		if err := json.NewDecoder(res.Body).Decode(&view); err != nil {
+			t.Fatal(err)
+		}
+		if view.State == models.JobStateDone {
+			if !strings.HasPrefix(view.NovaURL, "http://files/api/files/") {
+				t.Errorf("nova_url = %q", view.NovaURL)
+			}
+			break
+		}
+		if view.State == models.JobStateFailed || time.Now().After(deadline) {
+			t.Fatalf("job = %+v", view)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCDNFileURL(t *testing.T) {
+	dir := t.TempDir()
+	ts := storage.NewTempStorage(dir, time.Minute)
+	defer ts.Stop()
+	h := NewProcessHandler(services.NewRegistry(), nil, ts, "http://files", time.Minute, services.ProcessOptions{}, false, nil, nil, nil)
+	path := filepath.Join(dir, "out.png")
+	os.WriteFile(path, []byte("png"), 0644)
+	id, err := ts.Store(path, "", "image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.fileURL(id, ".png"); got != "http://files/api/files/"+id+".png" {
+		t.Errorf("without CDN = %s", got)
+	}
+
+	c, err := cdn.New(cdn.Config{BaseURL: "https://cdn.example.com", SigningKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetCDN(c)
+	if got := h.fileURL(id, ".png"); !strings.HasPrefix(got, "https://cdn.example.com/api/files/"+id+".png?expires=") {
+		t.Errorf("with CDN = %s", got)
+	}
+
+	app := fiber.New()
+	app.Get("/api/files/:id", h.GetFile)
+	res, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/files/"+id+".png", nil), 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc := res.Header.Get("Cache-Control"); !strings.HasPrefix(cc, "public,") {
+		t.Errorf("Cache-Control = %q, want public", cc)
+	}
+}
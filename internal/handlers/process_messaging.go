@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/messaging"
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/redact"
+	"fingerprint-converter/internal/services"
+)
+
+// SetMessaging processes the webhooks of platforms at
+// /api/messaging/:platform. Call before the handler serves requests
+func (h *ProcessHandler) SetMessaging(platforms ...messaging.Platform) {
+	h.messaging = make(map[string]messaging.Platform, len(platforms))
+	for _, p := range platforms {
+		h.messaging[p.Name()] = p
+	}
+}
+
+// ProcessMessaging handles POST /api/messaging/:platform: the media of a raw
+// webhook payload is fetched through the platform API, processed like an
+// upload, and answered with the send request re-uploading the result
+func (h *ProcessHandler) ProcessMessaging(c fiber.Ctx) error {
+	platform, ok := h.messaging[c.Params("platform")]
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "messaging platform not configured",
+		})
+	}
+	body := c.Body()
+	if err := platform.Verify(http.Header(c.GetReqHeaders()), body); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.ProcessResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+	media, err := platform.Parse(body)
+	if errors.Is(err, messaging.ErrNoMedia) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.ProcessResponse{
+			Success: false,
+			Message: err.Error(),
+			Code:    "no_media",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	t := tenantFrom(c)
+	ctx := services.WithRequestID(context.Background(), requestIDFrom(c))
+	reqLog := httpLog.WithID(requestIDFrom(c))
+	reqLog.Infof("💬 Webhook media: platform=%s, kind=%s", platform.Name(), media.Kind)
+
+	// The tenant's size limit applies as it does to downloads
+	limitCtx := ctx
+	if t != nil {
+		limitCtx = services.WithDownloadLimit(ctx, t.MaxFileSize)
+	}
+	fetchCtx, cancel := context.WithTimeout(ctx, h.requestTimeout)
+	name, data, err := platform.Fetch(fetchCtx, media, h.downloader.SizeLimit(limitCtx))
+	cancel()
+	if errors.Is(err, messaging.ErrTooLarge) {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(models.ProcessResponse{
+			Success: false,
+			Message: err.Error(),
+			Code:    "media_too_large",
+		})
+	}
+	if err != nil {
+		reqLog.Warnf("⚠️  Webhook media fetch failed: %s", redact.Error(err))
+		return c.Status(fiber.StatusBadGateway).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "Failed to fetch media from " + platform.Name() + ": " + err.Error(),
+			Code:    "media_fetch_failed",
+		})
+	}
+
+	status, resp := h.process(ctx, &models.ProcessRequest{Upload: &models.Upload{Name: name, Data: data}}, t)
+	if resp.Quota != nil {
+		setQuotaHeaders(c, resp.Quota)
+	}
+	out := models.MessagingResponse{ProcessResponse: resp, Platform: platform.Name()}
+	if resp.Success && resp.NovaURL != "" {
+		out.Reply = platform.Reply(media, resp.NovaURL)
+	}
+	return c.Status(status).JSON(out)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/messaging"
+	"fingerprint-converter/internal/models"
+)
+
+func TestProcessMessaging(t *testing.T) {
+	png, err := os.ReadFile(filepath.Join("testdata", "tiny.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var graph *httptest.Server
+	graph = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/555":
+			json.NewEncoder(w).Encode(map[string]any{"url": graph.URL + "/media/555", "mime_type": "image/png"})
+		case "/media/555":
+			w.Write(png)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer graph.Close()
+
+	h, _ := newTestProcessHandler(t, &fakeConverter{mediaType: "image", output: "processed image"})
+	h.SetMessaging(messaging.NewWhatsApp(graph.URL, "token", "", 5*time.Second))
+	app := fiber.New()
+	app.Post("/api/messaging/:platform", h.ProcessMessaging)
+
+	post := func(platform, payload string) (int, models.MessagingResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/messaging/"+platform, strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, 10*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp models.MessagingResponse
+		if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, resp
+	}
+	webhook := func(mediaID string) string {
+		return `{"entry": [{"changes": [{"value": {"metadata": {"phone_number_id": "1099"}, "messages": [
+			{"from": "5511999990000", "id": "wamid.I", "type": "image", "image": {"id": "` + mediaID + `", "mime_type": "image/png", "caption": "promo"}}
+		]}}]}]}`
+	}
+
+	status, resp := post("whatsapp", webhook("555"))
+	if status != fiber.StatusOK || !resp.Success || resp.MediaType != "image" || resp.Platform != "whatsapp" {
+		t.Fatalf("webhook = %d %+v", status, resp)
+	}
+	reply, _ := resp.Reply.(map[string]any)
+	image, _ := reply["image"].(map[string]any)
+	if reply["to"] != "5511999990000" || image["link"] != resp.NovaURL || image["caption"] != "promo" {
+		t.Errorf("reply = %v", resp.Reply)
+	}
+
+	tests := []struct {
+		name     string
+		platform string
+		payload  string
+		want     int
+	}{
+		{"unconfigured platform", "telegram", `{"message": {}}`, fiber.StatusNotFound},
+		{"invalid payload", "whatsapp", `{`, fiber.StatusBadRequest},
+		{"no media", "whatsapp", `{"entry": [{"changes": [{"value": {"statuses": [{"id": "x"}]}}]}]}`, fiber.StatusUnprocessableEntity},
+		{"unknown media", "whatsapp", webhook("404"), fiber.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		if status, resp := post(tt.platform, tt.payload); status != tt.want || resp.Reply != nil {
+			t.Errorf("%s = %d %+v, want %d", tt.name, status, resp, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/services"
+	"fingerprint-converter/internal/tenant"
+)
+
+// liveOutput carries encoded bytes from a running pipeline to the response
+// started is closed just before the first byte is written
+type liveOutput struct {
+	pw         *io.PipeWriter
+	outputPath string // Set before the pipeline runs; names the response
+	started    chan struct{}
+	once       sync.Once
+}
+
+func newLiveOutput(pw *io.PipeWriter) *liveOutput {
+	return &liveOutput{pw: pw, started: make(chan struct{})}
+}
+
+// Write blocks until the response has read p or the client went away
+func (l *liveOutput) Write(p []byte) (int, error) {
+	l.once.Do(func() { close(l.started) })
+	return l.pw.Write(p)
+}
+
+// processOutcome is the result of a request processed in the background
+type processOutcome struct {
+	status int
+	resp   models.ProcessResponse
+}
+
+// processStreaming handles POST /api/process with stream: true
+// Failures before the pipeline emits output answer with the usual JSON and
+// status. After the first byte the response is committed as a chunked 200;
+// a later failure aborts the connection so clients see a truncated body
+// rather than a complete-looking corrupt file
+func (h *ProcessHandler) processStreaming(c fiber.Ctx, req *models.ProcessRequest, t *tenant.Tenant) error {
+	if req.Rasterize {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "stream cannot be combined with rasterize",
+			Code:    "stream_unsupported",
+		})
+	}
+
+	parent := services.WithRequestID(context.Background(), requestIDFrom(c))
+	pr, pw := io.Pipe()
+	live := newLiveOutput(pw)
+	done := make(chan processOutcome, 1)
+	go func() {
+		status, resp := h.processTo(parent, req, t, live)
+		if resp.Success {
+			pw.Close()
+		} else {
+			pw.CloseWithError(errors.New(resp.Message))
+		}
+		done <- processOutcome{status: status, resp: resp}
+	}()
+
+	select {
+	case <-live.started:
+		httpLog.Infof("📡 Streaming output while encoding")
+		c.Set("Content-Type", getContentTypeFromPath(live.outputPath))
+		c.Set("Content-Disposition", contentDisposition(streamName(req, live.outputPath)))
+		return c.SendStream(pr)
+	case res := <-done:
+		pr.Close()
+		if res.resp.Quota != nil {
+			setQuotaHeaders(c, res.resp.Quota)
+		}
+		if !res.resp.Success {
+			return c.Status(res.status).JSON(res.resp)
+		}
+		return h.sendProcessed(c, req, res.resp)
+	}
+}
+
+// sendProcessed answers with the bytes of a finished, stored result
+// The headers still point at the stored copy for clients that need to refetch
+func (h *ProcessHandler) sendProcessed(c fiber.Ctx, req *models.ProcessRequest, resp models.ProcessResponse) error {
+	tf, err := h.tempStorage.Get(resp.FileID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "Processed file is no longer stored",
+		})
+	}
+
+	c.Set("Content-Type", getContentTypeFromPath(tf.Path))
+	c.Set("Content-Disposition", contentDisposition(streamName(req, tf.Path)))
+	c.Set("X-File-ID", resp.FileID)
+	c.Set("X-Nova-URL", resp.NovaURL)
+	c.Set("X-Media-Type", resp.MediaType)
+	return c.SendFile(tf.Path)
+}
+
+// streamName is the download name of a streamed response
+func streamName(req *models.ProcessRequest, path string) string {
+	filename, _ := services.ExpandTemplate(req.Filename, req.Variables)
+	return downloadName(filename, path)
+}
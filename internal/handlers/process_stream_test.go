@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestProcessStreaming(t *testing.T) {
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.mp3" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(strings.Repeat("\x00x", 100)))
+	}))
+	defer source.Close()
+
+	h, _ := newTestProcessHandler(t,
+		&fakeConverter{mediaType: "audio", output: "live audio", live: true},
+		&fakeConverter{mediaType: "image", output: "finished image"},
+	)
+
+	app := fiber.New()
+	app.Post("/api/process", h.Process)
+
+	post := func(body string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/process", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 10*time.Second)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		name, body      string
+		status          int
+		wantBody        string
+		wantContentType string
+		wantFileID      bool
+	}{
+		{
+			name:            "live while encoding",
+			body:            `{"arquivo": "` + source.URL + `/a.mp3", "stream": true}`,
+			status:          fiber.StatusOK,
+			wantBody:        "live audio",
+			wantContentType: "audio/mpeg",
+		},
+		{
+			name:            "finished file",
+			body:            `{"arquivo": "` + source.URL + `/a.png", "stream": true}`,
+			status:          fiber.StatusOK,
+			wantBody:        "finished image",
+			wantContentType: "image/png",
+			wantFileID:      true,
+		},
+		{
+			name:            "download failure stays json",
+			body:            `{"arquivo": "` + source.URL + `/missing.mp3", "stream": true}`,
+			status:          fiber.StatusBadRequest,
+			wantContentType: "application/json",
+		},
+		{
+			name:            "rasterize rejected",
+			body:            `{"arquivo": "` + source.URL + `/a.pdf", "stream": true, "rasterize": true}`,
+			status:          fiber.StatusBadRequest,
+			wantContentType: "application/json",
+		},
+	}
+
+	for _, tt := range tests {
+		resp := post(tt.body)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, resp.StatusCode, tt.status, body)
+			continue
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, tt.wantContentType) {
+			t.Errorf("%s: Content-Type = %q, want %q", tt.name, ct, tt.wantContentType)
+		}
+		if tt.wantBody != "" && string(body) != tt.wantBody {
+			t.Errorf("%s: body = %q, want %q", tt.name, body, tt.wantBody)
+		}
+		if got := resp.Header.Get("X-File-ID") != ""; got != tt.wantFileID {
+			t.Errorf("%s: X-File-ID present = %v, want %v", tt.name, got, tt.wantFileID)
+		}
+	}
+}
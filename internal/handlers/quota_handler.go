@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/tenant"
+)
+
+// QuotaHandler lets operators view and adjust tenant quotas at runtime
+type QuotaHandler struct {
+	quotas *tenant.Quotas
+}
+
+// NewQuotaHandler creates a new quota handler
+func NewQuotaHandler(quotas *tenant.Quotas) *QuotaHandler {
+	return &QuotaHandler{quotas: quotas}
+}
+
+// List handles GET /admin/quotas
+func (h *QuotaHandler) List(c fiber.Ctx) error {
+	return c.JSON(h.quotas.All())
+}
+
+// Get handles GET /admin/quotas/:tenant
+func (h *QuotaHandler) Get(c fiber.Ctx) error {
+	status, ok := h.quotas.Status(c.Params("tenant"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "unknown tenant",
+		})
+	}
+	return c.JSON(status)
+}
+
+// Update handles POST /admin/quotas/:tenant
+func (h *QuotaHandler) Update(c fiber.Ctx) error {
+	name := c.Params("tenant")
+	status, ok := h.quotas.Status(name)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "unknown tenant",
+		})
+	}
+
+	var req models.QuotaUpdateRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+
+	limits := status.Limits
+	if req.ConversionsPerDay != nil {
+		limits.ConversionsPerDay = *req.ConversionsPerDay
+	}
+	if req.BytesPerMonth != nil {
+		limits.BytesPerMonth = *req.BytesPerMonth
+	}
+	if err := h.quotas.SetLimits(name, limits); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+
+	status, _ = h.quotas.Status(name)
+	return c.JSON(status)
+}
+
+// quotaExceededResponse builds the 429 naming the exhausted quota and its reset time
+func quotaExceededResponse(err error) (int, models.ProcessResponse) {
+	resp := models.ProcessResponse{
+		Success: false,
+		Message: err.Error(),
+		Code:    "quota_exceeded",
+	}
+
+	var quotaErr *tenant.QuotaError
+	if errors.As(err, &quotaErr) {
+		resp.Quota = &models.QuotaInfo{
+			Kind:    quotaErr.Kind,
+			Limit:   quotaErr.Limit,
+			Used:    quotaErr.Used,
+			ResetAt: quotaErr.ResetAt,
+		}
+	}
+	return fiber.StatusTooManyRequests, resp
+}
+
+// setQuotaHeaders tells HTTP clients when an exhausted quota resets
+func setQuotaHeaders(c fiber.Ctx, quota *models.QuotaInfo) {
+	retryAfter := int(time.Until(quota.ResetAt).Seconds()) + 1
+	c.Set("Retry-After", strconv.Itoa(retryAfter))
+	c.Set("X-Quota-Reset", strconv.FormatInt(quota.ResetAt.Unix(), 10))
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/jobs"
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/objectstore"
+	"fingerprint-converter/internal/redact"
+	"fingerprint-converter/internal/storage"
+	"fingerprint-converter/internal/tenant"
+)
+
+// RecurringHandler registers sources that are re-processed on a cadence,
+// uploading every run's variants to an S3 prefix
+type RecurringHandler struct {
+	recurring      *jobs.Recurring
+	processHandler *ProcessHandler
+	tenants        *tenant.Store // Resolves source tenants at run time (nil = no tenants)
+	objects        *objectstore.S3
+	minEvery       time.Duration // Shortest accepted cadence
+	maxVariants    int           // Most variants per run
+}
+
+// NewRecurringHandler creates a new recurring source handler
+func NewRecurringHandler(recurring *jobs.Recurring, processHandler *ProcessHandler, tenants *tenant.Store, objects *objectstore.S3, minEvery time.Duration, maxVariants int) *RecurringHandler {
+	if minEvery <= 0 {
+		minEvery = 5 * time.Minute
+	}
+	if maxVariants <= 0 {
+		maxVariants = 100
+	}
+
+	return &RecurringHandler{
+		recurring:      recurring,
+		processHandler: processHandler,
+		tenants:        tenants,
+		objects:        objects,
+		minEvery:       minEvery,
+		maxVariants:    maxVariants,
+	}
+}
+
+// Register handles POST /api/recurring
+func (h *RecurringHandler) Register(c fiber.Ctx) error {
+	var req models.RecurringRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	if msg := h.validateSchedule(&req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ProcessResponse{
+			Success: false,
+			Message: msg,
+		})
+	}
+
+	t := tenantFrom(c)
+	if _, _, status, resp := h.processHandler.validate(&req.ProcessRequest, t); status != 0 {
+		return c.Status(status).JSON(resp)
+	}
+
+	src := &models.RecurringSource{
+		Request:     req.ProcessRequest,
+		Every:       req.Every,
+		Variants:    req.Variants,
+		Destination: req.Destination,
+	}
+	if t != nil {
+		src.Tenant = t.Name
+	}
+
+	if err := h.recurring.Register(src); err != nil {
+		if errors.Is(err, jobs.ErrStopped) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(models.ProcessResponse{
+				Success: false,
+				Message: "Server is restarting, retry shortly",
+			})
+		}
+		httpLog.Errorf("❌ Recurring registration failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "Failed to register recurring source",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(src)
+}
+
+// List handles GET /api/recurring, returning the caller's sources
+func (h *RecurringHandler) List(c fiber.Ctx) error {
+	t := tenantFrom(c)
+	sources := make([]models.RecurringSource, 0)
+	for _, src := range h.recurring.List() {
+		if ownsTenantResource(t, src.Tenant) {
+			sources = append(sources, src)
+		}
+	}
+	return c.JSON(sources)
+}
+
+// Get handles GET /api/recurring/:id
+func (h *RecurringHandler) Get(c fiber.Ctx) error {
+	src, ok := h.recurring.Get(c.Params("id"))
+	if !ok || !ownsTenantResource(tenantFrom(c), src.Tenant) {
+		return c.Status(fiber.StatusNotFound).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "Recurring source not found",
+		})
+	}
+	return c.JSON(src)
+}
+
+// Delete handles DELETE /api/recurring/:id
+func (h *RecurringHandler) Delete(c fiber.Ctx) error {
+	id := c.Params("id")
+	src, ok := h.recurring.Get(id)
+	if !ok || !ownsTenantResource(tenantFrom(c), src.Tenant) || !h.recurring.Delete(id) {
+		return c.Status(fiber.StatusNotFound).JSON(models.ProcessResponse{
+			Success: false,
+			Message: "Recurring source not found",
+		})
+	}
+	return c.JSON(models.ProcessResponse{
+		Success: true,
+		Message: "Recurring source removed",
+	})
+}
+
+// Run is the recurring scheduler's SourceRunner: it produces the source's
+// variants as its tenant and uploads each output under
+// <prefix>/<run timestamp>/<n><ext>
+func (h *RecurringHandler) Run(ctx context.Context, src *models.RecurringSource) models.RecurringRun {
+	run := models.RecurringRun{StartedAt: time.Now().UTC()}
+	defer func() { run.FinishedAt = time.Now().UTC() }()
+
+	var t *tenant.Tenant
+	if src.Tenant != "" {
+		if h.tenants != nil {
+			t, _ = h.tenants.ByName(src.Tenant)
+		}
+		if t == nil {
+			run.Error = fmt.Sprintf("tenant %s no longer exists", src.Tenant)
+			return run
+		}
+	}
+
+	bucket, prefix, err := objectstore.ParseURL(src.Destination)
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	runPrefix := path.Join(prefix, run.StartedAt.Format("20060102T150405Z"))
+
+	for i := 1; i <= src.Variants && ctx.Err() == nil; i++ {
+		req := src.Request
+		status, resp := h.processHandler.process(ctx, &req, t)
+		if !resp.Success {
+			run.Failed++
+			run.Error = resp.Message
+			if status == fiber.StatusTooManyRequests || status == fiber.StatusForbidden {
+				break // Remaining variants would be rejected the same way
+			}
+			continue
+		}
+
+		fileIDs := []string{resp.FileID}
+		if resp.FileID == "" {
+			fileIDs = fileIDs[:0]
+			for _, page := range resp.Pages {
+				fileIDs = append(fileIDs, page.FileID)
+			}
+		}
+
+		for n, fileID := range fileIDs {
+			name := fmt.Sprintf("%03d", i)
+			if len(fileIDs) > 1 {
+				name = fmt.Sprintf("%03d-p%03d", i, n+1)
+			}
+			key, err := uploadOutput(ctx, h.objects, h.processHandler.tempStorage, bucket, runPrefix, name, fileID)
+			if err != nil {
+				httpLog.Warnf("⚠️  Recurring upload failed: id=%s, variant=%d: %s", src.ID, i, redact.Error(err))
+				run.Failed++
+				run.Error = err.Error()
+				continue
+			}
+			run.Uploaded++
+			run.Objects = append(run.Objects, "s3://"+bucket+"/"+key)
+		}
+	}
+	return run
+}
+
+// uploadOutput copies a stored output to bucket under dir/name, keeping its extension
+func uploadOutput(ctx context.Context, objects *objectstore.S3, tempStorage *storage.TempStorage, bucket, dir, name, fileID string) (string, error) {
+	tf, err := tempStorage.Get(fileID)
+	if err != nil {
+		return "", err
+	}
+
+	key := strings.TrimPrefix(path.Join(dir, name+filepath.Ext(tf.Path)), "/")
+	if err := objects.PutFile(ctx, bucket, key, tf.Path, getContentTypeFromPath(tf.Path)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// validateSchedule checks cadence, variant count and destination, returning
+// the problem or "" when valid
+func (h *RecurringHandler) validateSchedule(req *models.RecurringRequest) string {
+	every, err := time.ParseDuration(req.Every)
+	if err != nil {
+		return fmt.Sprintf("every must be a duration such as \"1h\", got %q", req.Every)
+	}
+	if every < h.minEvery {
+		return fmt.Sprintf("every must be at least %v", h.minEvery)
+	}
+	if req.Variants < 1 || req.Variants > h.maxVariants {
+		return fmt.Sprintf("variants must be between 1 and %d", h.maxVariants)
+	}
+	if _, _, err := objectstore.ParseURL(req.Destination); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// ownsTenantResource reports whether the caller's tenant may see a resource
+// created by owner ("" = created without an API key)
+func ownsTenantResource(t *tenant.Tenant, owner string) bool {
+	if owner == "" {
+		return true
+	}
+	return t != nil && t.Name == owner
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// RequestIDHeader carries the ID a request is traced under. Callers may set
+// it; it is echoed on every response
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDLocalsKey stores the request ID in fiber locals
+const requestIDLocalsKey = "request_id"
+
+var (
+	// Caller IDs end up in file names and log lines, so they are restricted
+	// to characters safe in both
+	requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+	// W3C trace context: version-traceid-parentid-flags
+	traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+)
+
+// RequestIDMiddleware adopts the caller's X-Request-ID, else the trace ID of
+// its traceparent header, else generates an ID. Handlers pass it on to logs,
+// job directories, stored files and response bodies
+func RequestIDMiddleware(c fiber.Ctx) error {
+	id := callerRequestID(c.Get(RequestIDHeader), c.Get("traceparent"))
+	if id == "" {
+		b := make([]byte, 16)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	c.Locals(requestIDLocalsKey, id)
+	c.Set(RequestIDHeader, id)
+	return c.Next()
+}
+
+// callerRequestID returns the usable ID among the caller's headers, or ""
+func callerRequestID(requestID, traceparent string) string {
+	if requestIDPattern.MatchString(requestID) {
+		return strings.Clone(requestID)
+	}
+	if m := traceparentPattern.FindStringSubmatch(traceparent); m != nil && m[1] != strings.Repeat("0", 32) {
+		return strings.Clone(m[1])
+	}
+	return ""
+}
+
+// requestIDFrom returns the ID of this request, or "" outside the middleware
+func requestIDFrom(c fiber.Ctx) string {
+	id, _ := c.Locals(requestIDLocalsKey).(string)
+	return id
+}
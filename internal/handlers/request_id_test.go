@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+)
+
+func TestCallerRequestID(t *testing.T) {
+	tests := []struct {
+		requestID, traceparent, want string
+	}{
+		{requestID: "order-42.retry_1", want: "order-42.retry_1"},
+		{traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", want: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{requestID: "abc", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", want: "abc"},
+		{requestID: "../../etc/passwd"},
+		{requestID: "has space"},
+		{requestID: strings.Repeat("a", 65)},
+		{traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{traceparent: "garbage"},
+	}
+	for _, tt := range tests {
+		if got := callerRequestID(tt.requestID, tt.traceparent); got != tt.want {
+			t.Errorf("callerRequestID(%q, %q) = %q, want %q", tt.requestID, tt.traceparent, got, tt.want)
+		}
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	png, err := os.ReadFile(filepath.Join("testdata", "tiny.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(png)
+	}))
+	defer source.Close()
+
+	h, ts := newTestProcessHandler(t, &fakeConverter{mediaType: "image", output: "processed image"})
+
+	app := fiber.New()
+	app.Use(RequestIDMiddleware)
+	app.Post("/api/process", h.Process)
+	post := func(requestID string) (*http.Response, models.ProcessResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/process", strings.NewReader(`{"arquivo":"`+source.URL+`/a.png"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if requestID != "" {
+			req.Header.Set(RequestIDHeader, requestID)
+		}
+		res, err := app.Test(req, 10*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body models.ProcessResponse
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return res, body
+	}
+
+	res, body := post("order-42")
+	if !body.Success {
+		t.Fatalf("process failed: %s", body.Message)
+	}
+	if got := res.Header.Get(RequestIDHeader); got != "order-42" {
+		t.Errorf("%s header = %q, want order-42", RequestIDHeader, got)
+	}
+	if body.RequestID != "order-42" {
+		t.Errorf("request_id = %q, want order-42", body.RequestID)
+	}
+	if tf, err := ts.Get(body.FileID); err != nil || tf.RequestID != "order-42" {
+		t.Errorf("stored file request ID = %+v, %v", tf, err)
+	}
+
+	res, body = post("")
+	if id := res.Header.Get(RequestIDHeader); !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(id) || body.RequestID != id {
+		t.Errorf("generated ID = %q, body %q", id, body.RequestID)
+	}
+}
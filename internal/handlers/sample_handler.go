@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/services"
+)
+
+// SampleHandler exposes the diagnostics kept for slow and large requests
+type SampleHandler struct {
+	sampler *services.Sampler
+}
+
+// NewSampleHandler creates a new sample handler
+func NewSampleHandler(sampler *services.Sampler) *SampleHandler {
+	return &SampleHandler{sampler: sampler}
+}
+
+// List handles GET /admin/samples?limit=N, newest first
+func (h *SampleHandler) List(c fiber.Ctx) error {
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"error":   "limit must be a positive integer",
+			})
+		}
+		limit = n
+	}
+	return c.JSON(h.sampler.List(limit))
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/services"
+)
+
+// StatsHandler exposes conversion statistics broken down by input format
+type StatsHandler struct {
+	converters *services.Registry
+}
+
+// NewStatsHandler creates a new stats handler
+func NewStatsHandler(converters *services.Registry) *StatsHandler {
+	return &StatsHandler{converters: converters}
+}
+
+// Formats handles GET /admin/stats/formats, highest failure rate first
+func (h *StatsHandler) Formats(c fiber.Ctx) error {
+	return c.JSON(h.converters.FormatTable())
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/storage"
+)
+
+// StorageHandler lets operators inspect and reclaim temp storage without a restart
+type StorageHandler struct {
+	tempStorage *storage.TempStorage
+}
+
+// NewStorageHandler creates a new storage admin handler
+func NewStorageHandler(tempStorage *storage.TempStorage) *StorageHandler {
+	return &StorageHandler{tempStorage: tempStorage}
+}
+
+// List handles GET /admin/storage/files?tenant=&media_type=&older_than=
+func (h *StorageHandler) List(c fiber.Ctx) error {
+	filter, err := storageFilter(c.Query("tenant"), c.Query("media_type"), c.Query("older_than"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+
+	resp := models.StorageListResponse{Files: []models.StoredFile{}}
+	for _, tf := range h.tempStorage.List(filter) {
+		resp.Files = append(resp.Files, models.StoredFile{
+			ID:        tf.ID,
+			MediaType: tf.MediaType,
+			Tenant:    tf.Tenant,
+			Size:      tf.Size,
+			CreatedAt: tf.CreatedAt,
+			ExpiresAt: tf.ExpiresAt,
+			RequestID: tf.RequestID,
+		})
+		resp.TotalBytes += tf.Size
+	}
+	resp.Count = len(resp.Files)
+	return c.JSON(resp)
+}
+
+// Delete handles DELETE /admin/storage/files/:id
+func (h *StorageHandler) Delete(c fiber.Ctx) error {
+	if !h.tempStorage.Delete(c.Params("id")) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"error":   "file not found",
+		})
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// Purge handles POST /admin/storage/purge
+func (h *StorageHandler) Purge(c fiber.Ctx) error {
+	var req models.StoragePurgeRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+
+	filter, err := storageFilter(req.Tenant, req.MediaType, req.OlderThan)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+	// An empty body must not wipe everything by accident
+	if filter == (storage.Filter{}) && !req.All {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "set tenant, media_type or older_than, or all: true",
+		})
+	}
+
+	purged, freed := h.tempStorage.Purge(filter, req.DryRun)
+	return c.JSON(models.StoragePurgeResponse{
+		Success:    true,
+		Purged:     purged,
+		FreedBytes: freed,
+		DryRun:     req.DryRun,
+	})
+}
+
+// storageFilter builds a storage filter from request parameters
+func storageFilter(tenant, mediaType, olderThan string) (storage.Filter, error) {
+	filter := storage.Filter{Tenant: tenant, MediaType: mediaType}
+	if olderThan != "" {
+		age, err := time.ParseDuration(olderThan)
+		if err != nil || age < 0 {
+			return filter, fmt.Errorf("invalid older_than %q", olderThan)
+		}
+		filter.OlderThan = age
+	}
+	return filter, nil
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/tenant"
+)
+
+// tenantLocalsKey stores the resolved *tenant.Tenant in fiber locals
+const tenantLocalsKey = "tenant"
+
+// APIKeyHeader carries the caller's API key
+const APIKeyHeader = "X-API-Key"
+
+// TenantMiddleware resolves the X-API-Key header to its tenant policy
+// Unknown keys are rejected; requests without a key use the global
+// configuration unless requireKey is set
+func TenantMiddleware(store *tenant.Store, requireKey bool) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		key := c.Get(APIKeyHeader)
+		if key == "" {
+			if requireKey {
+				return c.Status(fiber.StatusUnauthorized).JSON(models.ProcessResponse{
+					Success: false,
+					Message: APIKeyHeader + " header is required",
+					Code:    "api_key_required",
+				})
+			}
+			return c.Next()
+		}
+
+		t, ok := store.Lookup(key)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.ProcessResponse{
+				Success: false,
+				Message: "invalid API key",
+				Code:    "api_key_invalid",
+			})
+		}
+
+		c.Locals(tenantLocalsKey, t)
+		return c.Next()
+	}
+}
+
+// tenantFrom returns the tenant resolved for this request, or nil
+func tenantFrom(c fiber.Ctx) *tenant.Tenant {
+	t, _ := c.Locals(tenantLocalsKey).(*tenant.Tenant)
+	return t
+}
+
+// tenantName returns the name of t, or "" without a tenant
+func tenantName(t *tenant.Tenant) string {
+	if t == nil {
+		return ""
+	}
+	return t.Name
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/services"
+	"fingerprint-converter/internal/tenant"
+)
+
+// TenantMetadataHandler lets operators set the metadata fields merged into
+// every output of a tenant
+type TenantMetadataHandler struct {
+	tenants  *tenant.Store
+	defaults *tenant.MetadataDefaults
+}
+
+// NewTenantMetadataHandler creates a new tenant metadata handler
+func NewTenantMetadataHandler(tenants *tenant.Store, defaults *tenant.MetadataDefaults) *TenantMetadataHandler {
+	return &TenantMetadataHandler{tenants: tenants, defaults: defaults}
+}
+
+// List handles GET /admin/tenants/metadata
+func (h *TenantMetadataHandler) List(c fiber.Ctx) error {
+	all := h.defaults.All()
+	list := make([]models.TenantMetadata, 0, len(all))
+	for _, t := range h.tenants.All() {
+		if fields, ok := all[t.Name]; ok {
+			list = append(list, models.TenantMetadata{Tenant: t.Name, Metadata: fields})
+		}
+	}
+	return c.JSON(list)
+}
+
+// Get handles GET /admin/tenants/:tenant/metadata
+func (h *TenantMetadataHandler) Get(c fiber.Ctx) error {
+	name := c.Params("tenant")
+	if _, ok := h.tenants.ByName(name); !ok {
+		return unknownTenant(c)
+	}
+	fields := h.defaults.Get(name)
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	return c.JSON(models.TenantMetadata{Tenant: name, Metadata: fields})
+}
+
+// Set handles PUT /admin/tenants/:tenant/metadata, replacing the defaults
+func (h *TenantMetadataHandler) Set(c fiber.Ctx) error {
+	name := c.Params("tenant")
+	if _, ok := h.tenants.ByName(name); !ok {
+		return unknownTenant(c)
+	}
+
+	var req models.TenantMetadataRequest
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   "Invalid request body",
+		})
+	}
+	if err := services.ValidateMetadataTemplates(req.Metadata); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+	if err := h.defaults.Set(name, req.Metadata); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to save tenant metadata: " + err.Error(),
+		})
+	}
+	return h.Get(c)
+}
+
+// Delete handles DELETE /admin/tenants/:tenant/metadata
+func (h *TenantMetadataHandler) Delete(c fiber.Ctx) error {
+	name := c.Params("tenant")
+	if _, ok := h.tenants.ByName(name); !ok {
+		return unknownTenant(c)
+	}
+	if err := h.defaults.Set(name, nil); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"error":   "Failed to save tenant metadata: " + err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+func unknownTenant(c fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		"success": false,
+		"error":   "unknown tenant",
+	})
+}
@@ -1,7 +1,10 @@
 package handlers
 
-// truncateURL truncates a URL to 60 characters for logging
+import "fingerprint-converter/internal/redact"
+
+// truncateURL truncates a URL to 60 characters for logging (hashed in PII-safe mode)
 func truncateURL(url string) string {
+	url = redact.URL(url)
 	if len(url) > 60 {
 		return url[:57] + "..."
 	}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/redact"
+)
+
+// errLeaseLost is answered by the dispatcher when the job was given to
+// another worker, so the result is discarded
+var errLeaseLost = errors.New("lease lost")
+
+// Worker runs jobs leased from a dispatcher node through the local
+// pipeline, then uploads the outputs and result back to the dispatcher
+type Worker struct {
+	jobs        *JobHandler
+	dispatcher  string // Base URL of the dispatcher
+	token       string
+	name        string
+	concurrency int
+	poll        time.Duration // Wait after finding no due job
+	client      *http.Client
+
+	mu       sync.Mutex
+	progress map[string]models.JobProgress // Reported with the next heartbeat
+	events   map[string][]models.JobEvent  // Phases not reported yet
+}
+
+// NewWorker creates a worker named name that runs up to concurrency jobs
+// from dispatcherURL at once through jobs
+func NewWorker(jobs *JobHandler, dispatcherURL, token, name string, concurrency int, poll time.Duration) *Worker {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if poll <= 0 {
+		poll = 2 * time.Second
+	}
+
+	w := &Worker{
+		jobs:        jobs,
+		dispatcher:  strings.TrimSuffix(dispatcherURL, "/"),
+		token:       token,
+		name:        name,
+		concurrency: concurrency,
+		poll:        poll,
+		client:      &http.Client{Timeout: 10 * time.Minute},
+		progress:    make(map[string]models.JobProgress),
+		events:      make(map[string][]models.JobEvent),
+	}
+	jobs.onProgress = w.noteProgress
+	jobs.onEvent = w.noteEvent
+	return w
+}
+
+// Run pulls and runs jobs until ctx is cancelled. Jobs cut short are not
+// completed; the dispatcher reschedules them once their lease expires
+func (w *Worker) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// loop leases one job at a time, sleeping between empty polls
+func (w *Worker) loop(ctx context.Context) {
+	for ctx.Err() == nil {
+		lease, err := w.lease(ctx)
+		if err != nil && ctx.Err() == nil {
+			httpLog.Warnf("⚠️  Lease from dispatcher failed: %s", redact.Error(err))
+		}
+		if lease == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(w.poll):
+			}
+			continue
+		}
+		w.work(ctx, lease)
+	}
+}
+
+// lease asks the dispatcher for a due job, nil when none is due
+func (w *Worker) lease(ctx context.Context) (*models.WorkLease, error) {
+	var lease models.WorkLease
+	status, err := w.call(ctx, http.MethodPost, "/internal/jobs/lease", models.WorkLeaseRequest{Worker: w.name}, &lease)
+	if err != nil || status == http.StatusNoContent {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// work runs a leased job, renewing the lease until it is done
+func (w *Worker) work(parent context.Context, lease *models.WorkLease) {
+	job := lease.Job
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	httpLog.Infof("📥 Leased job: id=%s", job.ID)
+
+	go w.renew(ctx, cancel, job.ID, time.Until(lease.LeaseUntil)/3)
+
+	status, resp := w.jobs.Run(ctx, &job)
+	fileIDs := outputFileIDs(resp)
+	defer w.removeOutputs(fileIDs)
+	defer w.forgetProgress(job.ID)
+	if ctx.Err() != nil {
+		httpLog.Warnf("⚠️  Job abandoned: id=%s", job.ID)
+		return
+	}
+
+	for _, fileID := range fileIDs {
+		if err := w.upload(ctx, job.ID, fileID); err != nil {
+			if errors.Is(err, errLeaseLost) {
+				httpLog.Warnf("⚠️  Job abandoned: id=%s: %v", job.ID, err)
+				return
+			}
+			httpLog.Errorf("❌ Output upload failed: job=%s: %s", job.ID, redact.Error(err))
+			status, resp = fiber.StatusBadGateway, models.ProcessResponse{
+				Success: false,
+				Message: fmt.Sprintf("Failed to upload output to the dispatcher: %v", err),
+			}
+			break
+		}
+	}
+
+	result := models.WorkResult{Worker: w.name, HTTPStatus: status, Result: resp, Events: w.takeEvents(job.ID)}
+	if _, err := w.call(ctx, http.MethodPost, "/internal/jobs/"+job.ID+"/complete", result, nil); err != nil {
+		httpLog.Warnf("⚠️  Job result not accepted: id=%s: %s", job.ID, redact.Error(err))
+		return
+	}
+	httpLog.Infof("📤 Job completed on worker: id=%s, success=%v", job.ID, resp.Success)
+}
+
+// renew sends heartbeats with the job's progress every interval, and
+// cancels the job once the dispatcher no longer holds it for this worker
+func (w *Worker) renew(ctx context.Context, cancel context.CancelFunc, id string, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		beat := models.WorkHeartbeat{Worker: w.name}
+		w.mu.Lock()
+		if progress, ok := w.progress[id]; ok {
+			beat.Progress = &progress
+		}
+		w.mu.Unlock()
+		beat.Events = w.takeEvents(id)
+
+		_, err := w.call(ctx, http.MethodPost, "/internal/jobs/"+id+"/heartbeat", beat, nil)
+		if errors.Is(err, errLeaseLost) {
+			httpLog.Warnf("⚠️  Lease lost, stopping job: id=%s", id)
+			cancel()
+			return
+		}
+		if err != nil && ctx.Err() == nil {
+			httpLog.Warnf("⚠️  Heartbeat failed: id=%s: %s", id, redact.Error(err))
+			w.restoreEvents(id, beat.Events)
+		}
+	}
+}
+
+// upload sends a stored output to the dispatcher under the same file ID
+func (w *Worker) upload(ctx context.Context, jobID, fileID string) error {
+	tf, err := w.jobs.processHandler.tempStorage.Get(fileID)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(tf.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	path := fmt.Sprintf("/internal/jobs/%s/files/%s%s?media_type=%s",
+		jobID, fileID, filepath.Ext(tf.Path), url.QueryEscape(tf.MediaType))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, w.dispatcher+path, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = tf.Size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(WorkerNameHeader, w.name)
+	_, err = w.do(req, nil)
+	return err
+}
+
+// call sends body as JSON and decodes the answer into out (if not nil)
+func (w *Worker) call(ctx context.Context, method, path string, body, out any) (int, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, w.dispatcher+path, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return w.do(req, out)
+}
+
+// do authenticates req and maps the dispatcher's errors
+func (w *Worker) do(req *http.Request, out any) (int, error) {
+	req.Header.Set(WorkerTokenHeader, w.token)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		return resp.StatusCode, errLeaseLost
+	case resp.StatusCode >= 300:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("dispatcher returned HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	case out != nil && resp.StatusCode != http.StatusNoContent:
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("invalid dispatcher response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// noteProgress keeps the latest progress of a running job for its heartbeat
+func (w *Worker) noteProgress(id string, progress models.JobProgress) {
+	w.mu.Lock()
+	w.progress[id] = progress
+	w.mu.Unlock()
+}
+
+func (w *Worker) forgetProgress(id string) {
+	w.mu.Lock()
+	delete(w.progress, id)
+	delete(w.events, id)
+	w.mu.Unlock()
+}
+
+// noteEvent queues a pipeline phase of a running job for its heartbeat
+func (w *Worker) noteEvent(id string, event models.JobEvent) {
+	w.mu.Lock()
+	w.events[id] = append(w.events[id], event)
+	w.mu.Unlock()
+}
+
+// takeEvents returns the phases of a job not reported yet
+func (w *Worker) takeEvents(id string) []models.JobEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	events := w.events[id]
+	delete(w.events, id)
+	return events
+}
+
+// restoreEvents queues phases again after a failed heartbeat
+func (w *Worker) restoreEvents(id string, events []models.JobEvent) {
+	if len(events) == 0 {
+		return
+	}
+	w.mu.Lock()
+	w.events[id] = append(events, w.events[id]...)
+	w.mu.Unlock()
+}
+
+// removeOutputs deletes local copies once they live on the dispatcher
+func (w *Worker) removeOutputs(fileIDs []string) {
+	for _, fileID := range fileIDs {
+		w.jobs.processHandler.tempStorage.Delete(fileID)
+	}
+}
+
+// outputFileIDs lists every stored file a job result refers to
+func outputFileIDs(resp models.ProcessResponse) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	add(resp.FileID)
+	for _, page := range resp.Pages {
+		add(page.FileID)
+	}
+	for _, entry := range resp.Entries {
+		add(entry.FileID)
+	}
+	for _, variant := range resp.Variants {
+		add(variant.FileID)
+	}
+	for _, obj := range resp.Objects {
+		add(obj.FileID)
+	}
+	return ids
+}
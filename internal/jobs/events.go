@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"fingerprint-converter/internal/models"
+)
+
+// maxJobEvents bounds the history of a job; source jobs report phases for
+// every object, so the oldest pipeline phases are dropped first
+const maxJobEvents = 200
+
+// AddEvent records that the running job with id entered a pipeline phase.
+// Phases are persisted with the job's next state change
+func (s *Scheduler) AddEvent(id, state, detail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok && job.Status == models.JobRunning {
+		s.addEvent(job, state, detail)
+	}
+}
+
+// addEvent appends an event to the history of job; callers hold s.mu
+func (s *Scheduler) addEvent(job *models.Job, state, detail string) {
+	appendEvents(job, models.JobEvent{State: state, At: s.now(), Detail: detail})
+}
+
+// appendEvents adds events to the history of job, keeping it within
+// maxJobEvents. The first event, when the job was queued, is always kept
+func appendEvents(job *models.Job, events ...models.JobEvent) {
+	job.Events = append(job.Events, events...)
+	if over := len(job.Events) - maxJobEvents; over > 0 {
+		job.Events = append(job.Events[:1], job.Events[1+over:]...)
+	}
+}
@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fingerprint-converter/internal/models"
+)
+
+func states(job models.Job) []string {
+	var states []string
+	for _, event := range job.Events {
+		states = append(states, event.State)
+	}
+	return states
+}
+
+func TestSchedulerRecordsEvents(t *testing.T) {
+	s, err := NewScheduler("", 1, time.Hour)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	s.Start(func(ctx context.Context, job *models.Job) (int, models.ProcessResponse) {
+		s.AddEvent(job.ID, models.JobEventDownloading, "")
+		s.AddEvent(job.ID, models.JobEventConverting, "")
+		return 200, models.ProcessResponse{Success: true}
+	})
+	defer s.Stop()
+
+	job := &models.Job{}
+	if err := s.Submit(job); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	done := waitForStatus(t, s, job.ID, models.JobSucceeded)
+
+	want := []string{models.JobEventQueued, models.JobEventRunning, models.JobEventDownloading, models.JobEventConverting, models.JobEventDone}
+	if got := states(done); len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	} else {
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("events = %v, want %v", got, want)
+			}
+		}
+	}
+	for i := 1; i < len(done.Events); i++ {
+		if done.Events[i].At.Before(done.Events[i-1].At) {
+			t.Errorf("event %d at %v is before the previous one", i, done.Events[i].At)
+		}
+	}
+
+	// Phases of jobs that are not running are ignored
+	s.AddEvent(job.ID, models.JobEventStoring, "")
+	if after, _ := s.Get(job.ID); len(after.Events) != len(want) {
+		t.Errorf("event added to a finished job: %v", states(after))
+	}
+}
+
+func TestAppendEventsKeepsQueued(t *testing.T) {
+	job := &models.Job{}
+	appendEvents(job, models.JobEvent{State: models.JobEventQueued})
+	for i := 0; i < maxJobEvents+10; i++ {
+		appendEvents(job, models.JobEvent{State: models.JobEventConverting})
+	}
+	appendEvents(job, models.JobEvent{State: models.JobEventDone})
+
+	if len(job.Events) != maxJobEvents {
+		t.Fatalf("events = %d, want %d", len(job.Events), maxJobEvents)
+	}
+	if job.Events[0].State != models.JobEventQueued || job.Events[len(job.Events)-1].State != models.JobEventDone {
+		t.Errorf("first/last = %s/%s, want queued/done", job.Events[0].State, job.Events[len(job.Events)-1].State)
+	}
+}
@@ -0,0 +1,115 @@
+package jobs
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"fingerprint-converter/internal/models"
+)
+
+// ErrLeaseLost is returned to a worker whose job was handed to another
+// worker, finished, or rescheduled after its lease expired
+var ErrLeaseLost = errors.New("job lease lost")
+
+// StartRemote begins scheduling for worker nodes instead of running jobs
+// here: due jobs wait for Lease and go back to the schedule when their
+// worker stops renewing them within lease
+func (s *Scheduler) StartRemote(lease time.Duration) {
+	if lease <= 0 {
+		lease = 2 * time.Minute
+	}
+	s.lease = lease
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// Lease hands the longest-due job to worker, false when none is due
+func (s *Scheduler) Lease(worker string) (models.WorkLease, bool) {
+	if s.ctx.Err() != nil {
+		return models.WorkLease{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var next *models.Job
+	for _, job := range s.jobs {
+		if job.Status != models.JobScheduled || job.ProcessAt.After(now) {
+			continue
+		}
+		if next == nil || job.ProcessAt.Before(next.ProcessAt) {
+			next = job
+		}
+	}
+	if next == nil {
+		return models.WorkLease{}, false
+	}
+
+	until := now.Add(s.lease)
+	next.Status = models.JobRunning
+	next.Worker = worker
+	next.StartedAt = &now
+	next.LeaseUntil = &until
+	s.addEvent(next, models.JobEventRunning, worker)
+	if err := s.persist(); err != nil {
+		log.Printf("⚠️  Failed to persist jobs: %v", err)
+	}
+	s.notify() // The loop now watches the lease
+
+	log.Printf("📤 Job leased: id=%s, worker=%s", next.ID, worker)
+	return models.WorkLease{Job: *next, LeaseUntil: until}, true
+}
+
+// Renew extends worker's lease on the job with id and records its progress
+// and the pipeline phases it entered since the last heartbeat
+func (s *Scheduler) Renew(id, worker string, progress *models.JobProgress, events []models.JobEvent) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.leased(id, worker)
+	if err != nil {
+		return time.Time{}, err
+	}
+	until := s.now().Add(s.lease)
+	job.LeaseUntil = &until
+	if progress != nil {
+		job.Progress = progress
+	}
+	appendEvents(job, events...)
+	return until, nil
+}
+
+// Complete records the result worker produced for the job with id, after
+// the phases it has not reported yet
+func (s *Scheduler) Complete(id, worker string, status int, resp models.ProcessResponse, events []models.JobEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.leased(id, worker)
+	if err != nil {
+		return err
+	}
+	appendEvents(job, events...)
+	s.finish(job, status, resp)
+	return nil
+}
+
+// Leased reports whether worker still holds the job with id
+func (s *Scheduler) Leased(id, worker string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.leased(id, worker)
+	return err == nil
+}
+
+// leased returns the job with id while worker holds it; callers hold s.mu
+func (s *Scheduler) leased(id, worker string) (*models.Job, error) {
+	job, ok := s.jobs[id]
+	if !ok || job.Status != models.JobRunning || job.Worker != worker || job.LeaseUntil == nil {
+		return nil, ErrLeaseLost
+	}
+	return job, nil
+}
@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"fingerprint-converter/internal/models"
+)
+
+func TestLeaseHandsOutDueJobsOnce(t *testing.T) {
+	s, err := NewScheduler("", 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.StartRemote(time.Minute)
+	defer s.Stop()
+
+	later := &models.Job{ProcessAt: time.Now().Add(time.Hour)}
+	first := &models.Job{}
+	second := &models.Job{}
+	for _, job := range []*models.Job{later, first, second} {
+		if err := s.Submit(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		lease, ok := s.Lease("w1")
+		if !ok {
+			t.Fatalf("lease %d: no job", i)
+		}
+		if seen[lease.Job.ID] || lease.Job.ID == later.ID {
+			t.Fatalf("lease %d handed out %s", i, lease.Job.ID)
+		}
+		seen[lease.Job.ID] = true
+		if lease.Job.Status != models.JobRunning || lease.Job.Worker != "w1" {
+			t.Errorf("leased job = %+v", lease.Job)
+		}
+	}
+	if _, ok := s.Lease("w1"); ok {
+		t.Fatal("leased a job that is not due")
+	}
+
+	// Remote jobs never run on the dispatcher
+	time.Sleep(50 * time.Millisecond)
+	if job, _ := s.Get(first.ID); job.Status != models.JobRunning || job.Result != nil {
+		t.Fatalf("job = %+v, want running on the worker", job)
+	}
+}
+
+func TestLeaseRenewAndComplete(t *testing.T) {
+	s, err := NewScheduler("", 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.StartRemote(time.Minute)
+	defer s.Stop()
+
+	job := &models.Job{}
+	s.Submit(job)
+	lease, _ := s.Lease("w1")
+
+	if _, err := s.Renew(job.ID, "w2", nil, nil); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("Renew by another worker = %v, want ErrLeaseLost", err)
+	}
+	until, err := s.Renew(job.ID, "w1", &models.JobProgress{Total: 4, Processed: 1}, nil)
+	if err != nil || until.Before(lease.LeaseUntil) {
+		t.Fatalf("Renew = %v, %v", until, err)
+	}
+	if got, _ := s.Get(job.ID); got.Progress == nil || got.Progress.Processed != 1 {
+		t.Errorf("progress = %+v", got.Progress)
+	}
+
+	if err := s.Complete(job.ID, "w1", 200, models.ProcessResponse{Success: true, FileID: "f1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	done, _ := s.Get(job.ID)
+	if done.Status != models.JobSucceeded || done.Result.FileID != "f1" || done.LeaseUntil != nil {
+		t.Fatalf("completed job = %+v", done)
+	}
+	if err := s.Complete(job.ID, "w1", 200, models.ProcessResponse{Success: true}, nil); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("second Complete = %v, want ErrLeaseLost", err)
+	}
+}
+
+func TestExpiredLeaseIsRescheduled(t *testing.T) {
+	s, err := NewScheduler("", 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.StartRemote(50 * time.Millisecond)
+	defer s.Stop()
+
+	job := &models.Job{}
+	s.Submit(job)
+	if _, ok := s.Lease("w1"); !ok {
+		t.Fatal("no job leased")
+	}
+
+	waitForStatus(t, s, job.ID, models.JobScheduled)
+	if s.Leased(job.ID, "w1") {
+		t.Fatal("expired lease still held")
+	}
+	lease, ok := s.Lease("w2")
+	if !ok || lease.Job.ID != job.ID {
+		t.Fatal("rescheduled job not leased again")
+	}
+	if err := s.Complete(job.ID, "w1", 200, models.ProcessResponse{Success: true}, nil); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("Complete by the expired worker = %v, want ErrLeaseLost", err)
+	}
+}
@@ -0,0 +1,292 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"fingerprint-converter/internal/models"
+)
+
+// SourceRunner produces one run of variants for a recurring source
+type SourceRunner func(ctx context.Context, src *models.RecurringSource) models.RecurringRun
+
+// Recurring re-processes registered sources on their cadence. Sources are
+// persisted like jobs; a source never overlaps with its own previous run
+type Recurring struct {
+	mu      sync.Mutex
+	sources map[string]*models.RecurringSource
+	every   map[string]time.Duration // Parsed cadence by source ID
+	path    string                   // Persistence file ("" keeps sources in memory only)
+	run     SourceRunner
+	wake    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	now     func() time.Time
+}
+
+// NewRecurring creates a recurring scheduler and loads the sources persisted at path
+func NewRecurring(path string) (*Recurring, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Recurring{
+		sources: make(map[string]*models.RecurringSource),
+		every:   make(map[string]time.Duration),
+		path:    path,
+		wake:    make(chan struct{}, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+		now:     time.Now,
+	}
+
+	if err := r.load(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Start begins running due sources with run
+func (r *Recurring) Start(run SourceRunner) {
+	r.run = run
+	r.wg.Add(1)
+	go r.loop()
+}
+
+// Stop stops the scheduler and cancels runs in progress
+func (r *Recurring) Stop() {
+	r.cancel()
+	r.wg.Wait()
+	log.Println("🛑 Recurring scheduler stopped")
+}
+
+// Register adds src, assigning its ID; the first run starts immediately
+func (r *Recurring) Register(src *models.RecurringSource) error {
+	every, err := time.ParseDuration(src.Every)
+	if err != nil || every <= 0 {
+		return fmt.Errorf("invalid every %q", src.Every)
+	}
+	if r.ctx.Err() != nil {
+		return ErrStopped
+	}
+
+	now := r.now()
+	src.ID = newID()
+	src.CreatedAt = now
+	src.NextRunAt = now
+	src.Running = false
+	src.LastRun = nil
+
+	r.mu.Lock()
+	r.sources[src.ID] = src
+	r.every[src.ID] = every
+	err = r.persist()
+	if err != nil {
+		delete(r.sources, src.ID)
+		delete(r.every, src.ID)
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("🔁 Recurring source registered: id=%s, every=%s, variants=%d", src.ID, every, src.Variants)
+	r.notify()
+	return nil
+}
+
+// Get returns a snapshot of the source with id
+func (r *Recurring) Get(id string) (models.RecurringSource, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	src, ok := r.sources[id]
+	if !ok {
+		return models.RecurringSource{}, false
+	}
+	return *src, true
+}
+
+// List returns snapshots of every source, oldest first
+func (r *Recurring) List() []models.RecurringSource {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sources := make([]models.RecurringSource, 0, len(r.sources))
+	for _, src := range r.sources {
+		sources = append(sources, *src)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].CreatedAt.Before(sources[j].CreatedAt) })
+	return sources
+}
+
+// Delete unregisters the source with id; a run in progress finishes
+func (r *Recurring) Delete(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sources[id]; !ok {
+		return false
+	}
+	delete(r.sources, id)
+	delete(r.every, id)
+	if err := r.persist(); err != nil {
+		log.Printf("⚠️  Failed to persist recurring sources: %v", err)
+	}
+	log.Printf("🔁 Recurring source removed: id=%s", id)
+	return true
+}
+
+// notify wakes the loop to re-evaluate the schedule
+func (r *Recurring) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// loop starts due sources and sleeps until the next one
+func (r *Recurring) loop() {
+	defer r.wg.Done()
+
+	for {
+		wait := r.dispatch()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return
+		case <-r.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// dispatch starts every due source that is not already running and returns
+// how long to sleep until the next one is due
+func (r *Recurring) dispatch() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	wait := pruneInterval
+	for id, src := range r.sources {
+		if src.Running {
+			continue
+		}
+		if until := src.NextRunAt.Sub(now); until > 0 {
+			wait = min(wait, until)
+			continue
+		}
+		src.Running = true
+		r.wg.Add(1)
+		go r.execute(id)
+	}
+	return wait
+}
+
+// execute performs one run of a source and schedules the next
+func (r *Recurring) execute(id string) {
+	defer r.wg.Done()
+
+	r.mu.Lock()
+	src := *r.sources[id]
+	r.mu.Unlock()
+
+	log.Printf("🔁 Recurring run started: id=%s, variants=%d", id, src.Variants)
+	run := r.run(r.ctx, &src)
+	if r.ctx.Err() != nil {
+		return // Interrupted by shutdown; the source is due again on restart
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.sources[id]
+	if !ok {
+		return // Deleted while running
+	}
+	stored.Running = false
+	stored.LastRun = &run
+
+	// Keep the cadence anchored to the schedule, skipping runs missed while
+	// this one was still going
+	every := r.every[id]
+	next := stored.NextRunAt.Add(every)
+	if now := r.now(); !next.After(now) {
+		next = now.Add(every)
+	}
+	stored.NextRunAt = next
+	if err := r.persist(); err != nil {
+		log.Printf("⚠️  Failed to persist recurring sources: %v", err)
+	}
+
+	log.Printf("🔁 Recurring run finished: id=%s, uploaded=%d, failed=%d, next=%s",
+		id, run.Uploaded, run.Failed, next.Format(time.RFC3339))
+	r.notify()
+}
+
+// load reads persisted sources; runs interrupted by a restart are due at once
+func (r *Recurring) load() error {
+	if r.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read recurring sources file: %w", err)
+	}
+
+	var sources []*models.RecurringSource
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return fmt.Errorf("invalid recurring sources file: %w", err)
+	}
+
+	for _, src := range sources {
+		every, err := time.ParseDuration(src.Every)
+		if err != nil || every <= 0 {
+			return fmt.Errorf("recurring source %s: invalid every %q", src.ID, src.Every)
+		}
+		src.Running = false
+		r.sources[src.ID] = src
+		r.every[src.ID] = every
+	}
+	log.Printf("🔁 Recurring sources restored: %d", len(sources))
+	return nil
+}
+
+// persist atomically writes all sources to the sources file; callers hold r.mu
+func (r *Recurring) persist() error {
+	if r.path == "" {
+		return nil
+	}
+
+	sources := make([]*models.RecurringSource, 0, len(r.sources))
+	for _, src := range r.sources {
+		sources = append(sources, src)
+	}
+	data, err := json.Marshal(sources)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
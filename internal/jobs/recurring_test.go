@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fingerprint-converter/internal/models"
+)
+
+func TestRecurringRunsOnCadence(t *testing.T) {
+	r, err := NewRecurring("")
+	if err != nil {
+		t.Fatalf("NewRecurring: %v", err)
+	}
+	var runs atomic.Int32
+	r.Start(func(ctx context.Context, src *models.RecurringSource) models.RecurringRun {
+		runs.Add(1)
+		return models.RecurringRun{Uploaded: src.Variants}
+	})
+	defer r.Stop()
+
+	src := &models.RecurringSource{Every: "150ms", Variants: 3}
+	if err := r.Register(src); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runs.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if runs.Load() < 3 {
+		t.Fatalf("runs = %d, want at least 3", runs.Load())
+	}
+
+	got, ok := r.Get(src.ID)
+	if !ok || got.LastRun == nil || got.LastRun.Uploaded != 3 {
+		t.Errorf("last run not recorded: %+v", got)
+	}
+	if !got.NextRunAt.After(got.CreatedAt) {
+		t.Errorf("next_run_at %v not advanced past %v", got.NextRunAt, got.CreatedAt)
+	}
+}
+
+func TestRecurringRejectsInvalidCadence(t *testing.T) {
+	r, err := NewRecurring("")
+	if err != nil {
+		t.Fatalf("NewRecurring: %v", err)
+	}
+	for _, every := range []string{"", "soon", "-1h", "0s"} {
+		if err := r.Register(&models.RecurringSource{Every: every}); err == nil {
+			t.Errorf("Register(every=%q) succeeded", every)
+		}
+	}
+}
+
+func TestRecurringPersistsAndDeletes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recurring.json")
+
+	first, err := NewRecurring(path)
+	if err != nil {
+		t.Fatalf("NewRecurring: %v", err)
+	}
+	keep := &models.RecurringSource{Every: "1h", Variants: 50, Destination: "s3://media/daily"}
+	drop := &models.RecurringSource{Every: "2h", Variants: 1, Destination: "s3://media/other"}
+	for _, src := range []*models.RecurringSource{keep, drop} {
+		if err := first.Register(src); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+	if !first.Delete(drop.ID) {
+		t.Fatalf("Delete(%s) = false", drop.ID)
+	}
+	if first.Delete(drop.ID) {
+		t.Errorf("second Delete(%s) = true", drop.ID)
+	}
+
+	second, err := NewRecurring(path)
+	if err != nil {
+		t.Fatalf("NewRecurring (reload): %v", err)
+	}
+	sources := second.List()
+	if len(sources) != 1 || sources[0].ID != keep.ID || sources[0].Variants != 50 || sources[0].Destination != keep.Destination {
+		t.Errorf("restored sources = %+v", sources)
+	}
+}
@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"fingerprint-converter/internal/models"
+)
+
+// ErrNotFailed is returned when redriving a job that has not failed
+var ErrNotFailed = errors.New("job has not failed")
+
+// RetryPolicy decides which failed jobs run again and when. Jobs that fail
+// for good are kept as dead letters until their retention ends
+type RetryPolicy struct {
+	Attempts   int           // Retries after the first failure (0 = none)
+	Backoff    time.Duration // Delay before the first retry, doubled for each one after
+	MaxBackoff time.Duration
+	// Retryable reports whether a failure is transient (nil = any 5xx)
+	Retryable func(status int, resp models.ProcessResponse) bool
+	// DeadLetterRetention is how long failed jobs stay queryable (the
+	// scheduler's retention when shorter)
+	DeadLetterRetention time.Duration
+}
+
+// SetRetry retries transient failures according to p. Call before Start
+func (s *Scheduler) SetRetry(p RetryPolicy) {
+	if p.Backoff <= 0 {
+		p.Backoff = 30 * time.Second
+	}
+	if p.MaxBackoff < p.Backoff {
+		p.MaxBackoff = p.Backoff
+	}
+	if p.Retryable == nil {
+		p.Retryable = func(status int, _ models.ProcessResponse) bool { return status >= 500 }
+	}
+	s.retry = p
+}
+
+// delay is the backoff before retry number attempt (1 for the first)
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxBackoff)
+}
+
+// retryFailed records a failed run of job and reschedules it while the
+// policy allows, reporting whether it did; callers hold s.mu
+func (s *Scheduler) retryFailed(job *models.Job, status int, resp models.ProcessResponse) bool {
+	now := s.now()
+	retryable := s.retry.Retryable != nil && s.retry.Retryable(status, resp)
+	job.Attempts++
+	job.Failures = append(job.Failures, models.JobFailure{
+		Attempt:    job.Attempts,
+		At:         now,
+		HTTPStatus: status,
+		Code:       resp.Code,
+		Message:    resp.Message,
+		Worker:     job.Worker,
+		Retryable:  retryable,
+	})
+	if !retryable || job.Attempts > s.retry.Attempts {
+		return false
+	}
+
+	delay := s.retry.delay(job.Attempts)
+	requeue(job)
+	job.ProcessAt = now.Add(delay)
+	s.addEvent(job, models.JobEventRetry, fmt.Sprintf("attempt %d of %d in %v", job.Attempts, s.retry.Attempts, delay))
+	if err := s.persist(); err != nil {
+		log.Printf("⚠️  Failed to persist jobs: %v", err)
+	}
+	s.notify()
+	log.Printf("🔁 Job retry scheduled: id=%s, attempt=%d/%d, in=%v: %s", job.ID, job.Attempts, s.retry.Attempts, delay, resp.Message)
+	return true
+}
+
+// retentionOf is how long a finished job stays queryable
+func (s *Scheduler) retentionOf(job *models.Job) time.Duration {
+	if job.Status == models.JobFailed {
+		return max(s.retention, s.retry.DeadLetterRetention)
+	}
+	return s.retention
+}
+
+// DeadLetters returns snapshots of the failed jobs of tenant (all when
+// empty), most recently failed first
+func (s *Scheduler) DeadLetters(tenant string) []models.Job {
+	s.mu.Lock()
+	jobs := []models.Job{}
+	for _, job := range s.jobs {
+		if job.Status == models.JobFailed && (tenant == "" || job.Tenant == tenant) {
+			jobs = append(jobs, *job)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].FinishedAt.After(*jobs[j].FinishedAt) })
+	return jobs
+}
+
+// Redrive schedules a failed job to run again now, with its retries
+// restored; its failure history is kept
+func (s *Scheduler) Redrive(id string) (models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || job.Status != models.JobFailed {
+		return models.Job{}, ErrNotFailed
+	}
+	requeue(job)
+	job.ProcessAt = s.now()
+	job.FinishedAt = nil
+	job.HTTPStatus = 0
+	job.Result = nil
+	job.Attempts = 0
+	s.addEvent(job, models.JobEventQueued, "redriven")
+	if err := s.persist(); err != nil {
+		log.Printf("⚠️  Failed to persist jobs: %v", err)
+	}
+	s.notify()
+	log.Printf("🔁 Job redriven: id=%s", id)
+	return *job, nil
+}
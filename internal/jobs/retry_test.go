@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fingerprint-converter/internal/models"
+)
+
+func TestTransientFailuresAreRetried(t *testing.T) {
+	s, err := NewScheduler("", 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetRetry(RetryPolicy{Attempts: 2, Backoff: 10 * time.Millisecond})
+	var runs atomic.Int32
+	s.Start(func(ctx context.Context, job *models.Job) (int, models.ProcessResponse) {
+		if runs.Add(1) < 3 {
+			return 503, models.ProcessResponse{Message: "memory budget exhausted"}
+		}
+		return 200, models.ProcessResponse{Success: true}
+	})
+	defer s.Stop()
+
+	job := &models.Job{}
+	s.Submit(job)
+	done := waitForStatus(t, s, job.ID, models.JobSucceeded)
+	if runs.Load() != 3 || done.Attempts != 2 || len(done.Failures) != 2 {
+		t.Fatalf("runs = %d, job = %+v", runs.Load(), done)
+	}
+	if f := done.Failures[1]; f.Attempt != 2 || f.HTTPStatus != 503 || !f.Retryable || f.Message != "memory budget exhausted" {
+		t.Errorf("failure = %+v", f)
+	}
+}
+
+func TestFailedJobsBecomeDeadLetters(t *testing.T) {
+	s, err := NewScheduler("", 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetRetry(RetryPolicy{Attempts: 1, Backoff: 10 * time.Millisecond, DeadLetterRetention: 48 * time.Hour})
+	var runs atomic.Int32
+	s.Start(func(ctx context.Context, job *models.Job) (int, models.ProcessResponse) {
+		runs.Add(1)
+		if job.Request.Arquivo == "bad" {
+			return 422, models.ProcessResponse{Message: "invalid input"}
+		}
+		return 500, models.ProcessResponse{Message: "ffmpeg crashed"}
+	})
+	defer s.Stop()
+
+	rejected := &models.Job{Request: models.ProcessRequest{Arquivo: "bad"}, Tenant: "a"}
+	crashing := &models.Job{Tenant: "b"}
+	s.Submit(rejected)
+	waitForStatus(t, s, rejected.ID, models.JobFailed)
+	s.Submit(crashing)
+	exhausted := waitForStatus(t, s, crashing.ID, models.JobFailed)
+
+	if runs.Load() != 3 {
+		t.Errorf("runs = %d, want 1 for the rejected job and 2 for the crashing one", runs.Load())
+	}
+	if len(exhausted.Failures) != 2 {
+		t.Errorf("failures = %+v", exhausted.Failures)
+	}
+	if dead := s.DeadLetters(""); len(dead) != 2 || dead[0].ID != crashing.ID {
+		t.Fatalf("dead letters = %+v", dead)
+	}
+	if dead := s.DeadLetters("a"); len(dead) != 1 || dead[0].ID != rejected.ID {
+		t.Fatalf("dead letters of tenant a = %+v", dead)
+	}
+
+	// Kept past the retention of succeeded jobs
+	s.mu.Lock()
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	s.mu.Unlock()
+	s.dispatch()
+	if _, ok := s.Get(rejected.ID); !ok {
+		t.Fatal("dead letter pruned with the job retention")
+	}
+	s.mu.Lock()
+	s.now = time.Now
+	s.mu.Unlock()
+
+	if _, err := s.Redrive(crashing.ID); err != nil {
+		t.Fatal(err)
+	}
+	redriven := waitForStatus(t, s, crashing.ID, models.JobFailed)
+	if len(redriven.Failures) != 4 || redriven.Attempts != 2 {
+		t.Errorf("redriven job = %+v", redriven)
+	}
+	if _, err := s.Redrive("missing"); !errors.Is(err, ErrNotFailed) {
+		t.Errorf("Redrive(missing) = %v", err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := p.delay(attempt); got != want {
+			t.Errorf("delay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"fingerprint-converter/internal/models"
+	"fingerprint-converter/internal/pool"
+)
+
+// Runner processes one job and returns the HTTP status and body the
+// synchronous endpoint would have answered with
+type Runner func(ctx context.Context, job *models.Job) (int, models.ProcessResponse)
+
+// ErrStopped is returned for new work after Stop, e.g. while a binary
+// upgrade hands persisted state to the new process
+var ErrStopped = errors.New("scheduler stopped")
+
+// pruneInterval bounds how long the loop sleeps, so finished jobs are
+// dropped after their retention even when nothing is scheduled
+const pruneInterval = time.Minute
+
+// Scheduler runs jobs at their process_at time. Every state change is
+// written to a JSON file, so pending jobs survive restarts; jobs that were
+// running when the process stopped are run again
+type Scheduler struct {
+	mu        sync.Mutex
+	jobs      map[string]*models.Job
+	path      string // Persistence file ("" keeps jobs in memory only)
+	retention time.Duration
+	slots     *pool.Semaphore
+	run       Runner
+	lease     time.Duration // Remote mode: how long a worker holds a job (0 = jobs run here)
+	wake      chan struct{}
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	now       func() time.Time
+
+	retry RetryPolicy // Transient failures run again (zero = jobs fail at once)
+}
+
+// NewScheduler creates a scheduler and loads the jobs persisted at path
+// At most concurrency jobs run at once; finished jobs are kept for retention
+func NewScheduler(path string, concurrency int, retention time.Duration) (*Scheduler, error) {
+	if retention <= 0 {
+		retention = time.Hour
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		jobs:      make(map[string]*models.Job),
+		path:      path,
+		retention: retention,
+		slots:     pool.NewSemaphore(concurrency),
+		wake:      make(chan struct{}, 1),
+		ctx:       ctx,
+		cancel:    cancel,
+		now:       time.Now,
+	}
+
+	if err := s.load(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Start begins dispatching due jobs to run
+func (s *Scheduler) Start(run Runner) {
+	s.run = run
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// Stop stops dispatching and cancels running jobs, which are persisted as
+// running and therefore run again on the next start
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	log.Println("🛑 Job scheduler stopped")
+}
+
+// Submit schedules a copy of job, assigning its ID, status and timestamps
+// to both; job stays the caller's while the copy runs
+func (s *Scheduler) Submit(job *models.Job) error {
+	if s.ctx.Err() != nil {
+		return ErrStopped
+	}
+
+	now := s.now()
+	job.ID = newID()
+	job.Status = models.JobScheduled
+	job.CreatedAt = now
+	if job.ProcessAt.Before(now) {
+		job.ProcessAt = now
+	}
+	appendEvents(job, models.JobEvent{State: models.JobEventQueued, At: now})
+
+	stored := *job
+	stored.Events = slices.Clone(job.Events)
+	s.mu.Lock()
+	s.jobs[job.ID] = &stored
+	err := s.persist()
+	if err != nil {
+		delete(s.jobs, job.ID)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("🗓️  Job scheduled: id=%s, process_at=%s", job.ID, job.ProcessAt.Format(time.RFC3339))
+	s.notify()
+	return nil
+}
+
+// Get returns a snapshot of the job with id
+func (s *Scheduler) Get(id string) (models.Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return models.Job{}, false
+	}
+	return *job, true
+}
+
+// SetProgress records how far the running job with id has got. An
+// interrupted job runs again from the start, so progress is not restored
+func (s *Scheduler) SetProgress(id string, progress models.JobProgress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		job.Progress = &progress
+	}
+}
+
+// notify wakes the loop to re-evaluate the schedule
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// loop dispatches due jobs and sleeps until the next one
+func (s *Scheduler) loop() {
+	defer s.wg.Done()
+
+	for {
+		wait := s.dispatch()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// dispatch starts every due job, prunes expired finished jobs and returns
+// how long to sleep until the next scheduled job
+func (s *Scheduler) dispatch() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	wait := pruneInterval
+	changed := false
+
+	for id, job := range s.jobs {
+		switch job.Status {
+		case models.JobScheduled:
+			if until := job.ProcessAt.Sub(now); until > 0 {
+				wait = min(wait, until)
+				continue
+			}
+			if s.lease > 0 {
+				continue // Waits for a worker to lease it
+			}
+			job.Status = models.JobRunning
+			changed = true
+			s.wg.Add(1)
+			go s.execute(job.ID)
+
+		case models.JobRunning:
+			if job.LeaseUntil == nil {
+				continue
+			}
+			if until := job.LeaseUntil.Sub(now); until > 0 {
+				wait = min(wait, until)
+				continue
+			}
+			log.Printf("⏰ Job lease expired: id=%s, worker=%s, rescheduling", job.ID, job.Worker)
+			s.addEvent(job, models.JobEventRequeued, "lease of "+job.Worker+" expired")
+			requeue(job)
+			changed = true
+
+		case models.JobSucceeded, models.JobFailed:
+			if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > s.retentionOf(job) {
+				delete(s.jobs, id)
+				changed = true
+			}
+		}
+	}
+
+	if changed {
+		if err := s.persist(); err != nil {
+			log.Printf("⚠️  Failed to persist jobs: %v", err)
+		}
+	}
+	return wait
+}
+
+// execute runs one job once a slot is free and records its result
+func (s *Scheduler) execute(id string) {
+	defer s.wg.Done()
+
+	if err := s.slots.Acquire(s.ctx); err != nil {
+		return // Shutting down; the job stays running and is retried on restart
+	}
+	defer s.slots.Release()
+
+	s.mu.Lock()
+	job := *s.jobs[id]
+	started := s.now()
+	s.jobs[id].StartedAt = &started
+	s.addEvent(s.jobs[id], models.JobEventRunning, "")
+	s.mu.Unlock()
+
+	log.Printf("▶️  Job started: id=%s", id)
+	status, resp := s.run(s.ctx, &job)
+	if s.ctx.Err() != nil {
+		return // Interrupted by shutdown, retried on restart
+	}
+
+	s.mu.Lock()
+	s.finish(s.jobs[id], status, resp)
+	s.mu.Unlock()
+}
+
+// finish records the result of job and persists it, unless a failure is
+// retried; callers hold s.mu
+func (s *Scheduler) finish(job *models.Job, status int, resp models.ProcessResponse) {
+	if !resp.Success && s.retryFailed(job, status, resp) {
+		return
+	}
+
+	finished := s.now()
+	job.FinishedAt = &finished
+	job.HTTPStatus = status
+	job.Result = &resp
+	job.LeaseUntil = nil
+	job.Status = models.JobFailed
+	if resp.Success {
+		job.Status = models.JobSucceeded
+		s.addEvent(job, models.JobEventDone, "")
+	} else {
+		s.addEvent(job, models.JobEventFailed, resp.Message)
+	}
+	if err := s.persist(); err != nil {
+		log.Printf("⚠️  Failed to persist jobs: %v", err)
+	}
+
+	var elapsed time.Duration
+	if job.StartedAt != nil {
+		elapsed = finished.Sub(*job.StartedAt)
+	}
+	log.Printf("⏹️  Job finished: id=%s, status=%s, time=%dms", job.ID, job.Status, elapsed.Milliseconds())
+}
+
+// requeue returns an interrupted job to the schedule, to run from the start
+func requeue(job *models.Job) {
+	job.Status = models.JobScheduled
+	job.StartedAt = nil
+	job.Progress = nil
+	job.Worker = ""
+	job.LeaseUntil = nil
+}
+
+// load reads persisted jobs; running jobs were interrupted and are rescheduled
+func (s *Scheduler) load() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read jobs file: %w", err)
+	}
+
+	var jobs []*models.Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return fmt.Errorf("invalid jobs file: %w", err)
+	}
+
+	pending := 0
+	for _, job := range jobs {
+		if job.Status == models.JobRunning {
+			s.addEvent(job, models.JobEventRequeued, "restart")
+			requeue(job)
+		}
+		if job.Status == models.JobScheduled {
+			pending++
+		}
+		s.jobs[job.ID] = job
+	}
+	log.Printf("🗓️  Jobs restored: total=%d, pending=%d", len(jobs), pending)
+	return nil
+}
+
+// persist atomically writes all jobs to the jobs file; callers hold s.mu
+func (s *Scheduler) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	jobs := make([]*models.Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	data, err := json.Marshal(jobs)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// newID returns a random job ID
+func newID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fingerprint-converter/internal/models"
+)
+
+// waitForStatus polls until job id reaches status or the deadline passes
+func waitForStatus(t *testing.T, s *Scheduler, id, status string) models.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, ok := s.Get(id); ok && job.Status == status {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	job, _ := s.Get(id)
+	t.Fatalf("job %s status = %q, want %q", id, job.Status, status)
+	return job
+}
+
+func TestSchedulerRunsAtProcessAt(t *testing.T) {
+	s, err := NewScheduler("", 2, time.Hour)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	started := make(chan time.Time, 1)
+	s.Start(func(ctx context.Context, job *models.Job) (int, models.ProcessResponse) {
+		started <- time.Now()
+		return 200, models.ProcessResponse{Success: true, FileID: "abc"}
+	})
+	defer s.Stop()
+
+	processAt := time.Now().Add(200 * time.Millisecond)
+	job := &models.Job{ProcessAt: processAt}
+	if err := s.Submit(job); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	done := waitForStatus(t, s, job.ID, models.JobSucceeded)
+	if at := <-started; at.Before(processAt) {
+		t.Errorf("job ran at %v, before process_at %v", at, processAt)
+	}
+	if done.Result == nil || done.Result.FileID != "abc" || done.HTTPStatus != 200 {
+		t.Errorf("result not recorded: %+v", done)
+	}
+}
+
+func TestSchedulerRestoresPendingJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+
+	first, err := NewScheduler(path, 1, time.Hour)
+	if err != nil {
+		t.Fatalf("NewScheduler: %v", err)
+	}
+	job := &models.Job{ProcessAt: time.Now().Add(time.Hour)}
+	if err := first.Submit(job); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	// The first scheduler never starts, as if the process died
+
+	second, err := NewScheduler(path, 1, time.Hour)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	restored, ok := second.Get(job.ID)
+	if !ok || restored.Status != models.JobScheduled || !restored.ProcessAt.Equal(job.ProcessAt) {
+		t.Errorf("restored job = %+v, want scheduled at %v", restored, job.ProcessAt)
+	}
+}
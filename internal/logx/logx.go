@@ -0,0 +1,120 @@
+// Package logx filters the standard logger by level, per module, so the
+// per-request emoji logs can be silenced without losing warnings and errors
+package logx
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Level orders log lines by importance
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+// Modules with their own verbosity setting
+const (
+	Downloader = "downloader"
+	Converters = "converters"
+	Storage    = "storage"
+	HTTP       = "http"
+)
+
+var levelNames = map[string]Level{
+	"debug":   Debug,
+	"info":    Info,
+	"warn":    Warn,
+	"warning": Warn,
+	"error":   Error,
+}
+
+// ParseLevel accepts debug, info, warn (or warning) and error
+func ParseLevel(s string) (Level, error) {
+	level, ok := levelNames[strings.ToLower(strings.TrimSpace(s))]
+	if !ok {
+		return Info, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+	}
+	return level, nil
+}
+
+// levels is replaced as a whole by Configure, so loggers never lock
+type levels struct {
+	fallback Level
+	modules  map[string]Level
+}
+
+var current atomic.Pointer[levels]
+
+func init() {
+	current.Store(&levels{fallback: Info})
+}
+
+// Configure sets the default level and per-module overrides; modules mapped
+// to an empty string use the default
+func Configure(fallback string, modules map[string]string) error {
+	l := &levels{modules: make(map[string]Level)}
+	var err error
+	if l.fallback, err = ParseLevel(fallback); err != nil {
+		return err
+	}
+	for module, name := range modules {
+		if name == "" {
+			continue
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", module, err)
+		}
+		l.modules[module] = level
+	}
+	current.Store(l)
+	return nil
+}
+
+// Logger writes to the standard logger lines at or above its module's level
+type Logger struct {
+	module string
+	prefix string
+}
+
+// For returns the logger of module; its level follows later Configure calls
+func For(module string) *Logger {
+	return &Logger{module: module}
+}
+
+// WithID returns a logger of the same module whose lines start with [id],
+// so every line of one request can be found by its ID
+func (l *Logger) WithID(id string) *Logger {
+	if id == "" {
+		return l
+	}
+	return &Logger{module: l.module, prefix: "[" + id + "] "}
+}
+
+// Enabled reports whether lines at level are written
+func (l *Logger) Enabled(level Level) bool {
+	levels := current.Load()
+	min, ok := levels.modules[l.module]
+	if !ok {
+		min = levels.fallback
+	}
+	return level >= min
+}
+
+func (l *Logger) Debugf(format string, args ...any) { l.output(Debug, format, args...) }
+func (l *Logger) Infof(format string, args ...any)  { l.output(Info, format, args...) }
+func (l *Logger) Warnf(format string, args ...any)  { l.output(Warn, format, args...) }
+func (l *Logger) Errorf(format string, args ...any) { l.output(Error, format, args...) }
+
+func (l *Logger) output(level Level, format string, args ...any) {
+	if l.Enabled(level) {
+		log.Output(3, l.prefix+fmt.Sprintf(format, args...))
+	}
+}
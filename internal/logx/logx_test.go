@@ -0,0 +1,69 @@
+package logx
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestModuleLevels(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	flags := log.Flags()
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+		Configure("info", nil)
+	})
+
+	if err := Configure("info", map[string]string{HTTP: "warn", Storage: ""}); err != nil {
+		t.Fatal(err)
+	}
+
+	httpLog, storageLog := For(HTTP), For(Storage)
+	httpLog.Infof("request")
+	httpLog.Warnf("slow")
+	storageLog.Debugf("detail")
+	storageLog.Infof("stored")
+
+	if got, want := buf.String(), "slow\nstored\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestConfigureRejectsUnknownLevel(t *testing.T) {
+	if err := Configure("loud", nil); err == nil {
+		t.Error("unknown default level accepted")
+	}
+	err := Configure("info", map[string]string{Downloader: "verbose"})
+	if err == nil || !strings.Contains(err.Error(), Downloader) {
+		t.Errorf("Configure = %v, want error naming the module", err)
+	}
+}
+
+func TestWithIDPrefixesLines(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	flags := log.Flags()
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+		Configure("info", nil)
+	})
+	if err := Configure("info", map[string]string{HTTP: "warn"}); err != nil {
+		t.Fatal(err)
+	}
+
+	l := For(HTTP).WithID("req-1")
+	l.Infof("filtered")
+	l.Warnf("slow")
+	For(HTTP).WithID("").Warnf("plain")
+
+	if got, want := buf.String(), "[req-1] slow\nplain\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
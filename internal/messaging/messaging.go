@@ -0,0 +1,99 @@
+// Package messaging adapts the media webhooks of chat platforms (WhatsApp
+// Cloud API, Telegram Bot API) to the processing pipeline: it finds the
+// media a payload carries, fetches it through the platform API and builds
+// the send request that re-uploads the processed file to the chat
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"fingerprint-converter/internal/services"
+)
+
+// Errors of Verify, Parse and Fetch
+var (
+	ErrBadSignature = errors.New("webhook signature missing or invalid")
+	ErrNoMedia      = errors.New("payload carries no media message")
+	ErrTooLarge     = errors.New("media over the size limit")
+)
+
+// Media is the media message of a webhook payload
+type Media struct {
+	Kind     string // Platform message type, e.g. image, voice, document
+	FileID   string // Platform media ID
+	MimeType string // When the payload states it
+	Filename string // Documents only
+	Caption  string
+	Chat     string // Sender: WhatsApp phone number, Telegram chat ID
+	Account  string // Receiving WhatsApp phone_number_id (empty for Telegram)
+	Message  string // ID of the message, quoted by the reply
+}
+
+// Platform is a chat platform whose webhooks can be processed
+type Platform interface {
+	// Name is the platform's path segment in /api/messaging/:platform
+	Name() string
+	// Verify checks that a delivery comes from the platform
+	Verify(header http.Header, body []byte) error
+	// Parse finds the first media message of a webhook payload
+	Parse(body []byte) (*Media, error)
+	// Fetch downloads the media of m, failing with ErrTooLarge past limit
+	// bytes. name carries an extension for media type detection
+	Fetch(ctx context.Context, m *Media, limit int64) (name string, data []byte, err error)
+	// Reply builds the platform send request re-uploading fileURL to the
+	// chat m came from
+	Reply(m *Media, fileURL string) any
+}
+
+// get sends a GET for rawURL with the optional bearer token and returns the
+// response when it succeeded. Errors leave out the URL, which may embed a token
+func get(ctx context.Context, client *http.Client, rawURL, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, errors.New("invalid platform URL")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// readLimited reads body, failing with ErrTooLarge past limit bytes
+func readLimited(body io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// mediaName names a file of mimeType so its extension tells the media type;
+// unknown types get no extension and are recognized by content
+func mediaName(mimeType string) string {
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	if _, format := services.MediaFromContentType(mediaType); format != "" {
+		return "media." + format
+	}
+	return "media"
+}
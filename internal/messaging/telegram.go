@@ -0,0 +1,206 @@
+package messaging
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// telegramSendMethods maps message kinds to the Bot API method and field
+// re-sending them. Video notes cannot be sent by URL and go out as videos
+var telegramSendMethods = map[string][2]string{
+	"photo":      {"sendPhoto", "photo"},
+	"animation":  {"sendAnimation", "animation"},
+	"video":      {"sendVideo", "video"},
+	"video_note": {"sendVideo", "video"},
+	"audio":      {"sendAudio", "audio"},
+	"voice":      {"sendVoice", "voice"},
+	"document":   {"sendDocument", "document"},
+	"sticker":    {"sendSticker", "sticker"},
+}
+
+// Telegram resolves the media of Telegram Bot API updates through getFile
+type Telegram struct {
+	apiURL string
+	token  string
+	secret string
+	client *http.Client
+}
+
+// NewTelegram creates the adapter for the bot with token on the Bot API at
+// apiURL; secret is the webhook's secret_token, checked when set
+func NewTelegram(apiURL, token, secret string, timeout time.Duration) *Telegram {
+	return &Telegram{
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		token:  token,
+		secret: secret,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// telegramUpdate is the part of an Update this adapter reads
+type telegramUpdate struct {
+	Message           *telegramMessage `json:"message"`
+	EditedMessage     *telegramMessage `json:"edited_message"`
+	ChannelPost       *telegramMessage `json:"channel_post"`
+	EditedChannelPost *telegramMessage `json:"edited_channel_post"`
+}
+
+type telegramMessage struct {
+	MessageID int64 `json:"message_id"`
+	Chat      struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Caption string `json:"caption"`
+	// Sizes of one photo, smallest first
+	Photo     []telegramFile `json:"photo"`
+	Animation *telegramFile  `json:"animation"`
+	Video     *telegramFile  `json:"video"`
+	VideoNote *telegramFile  `json:"video_note"`
+	Audio     *telegramFile  `json:"audio"`
+	Voice     *telegramFile  `json:"voice"`
+	Document  *telegramFile  `json:"document"`
+	Sticker   *telegramFile  `json:"sticker"`
+}
+
+type telegramFile struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	MimeType string `json:"mime_type"`
+}
+
+// media returns the kind and file of the message's media. Animations also
+// fill document, so they are checked first
+func (m *telegramMessage) media() (string, *telegramFile) {
+	if len(m.Photo) > 0 {
+		return "photo", &m.Photo[len(m.Photo)-1]
+	}
+	for _, f := range []struct {
+		kind string
+		file *telegramFile
+	}{
+		{"animation", m.Animation},
+		{"video", m.Video},
+		{"video_note", m.VideoNote},
+		{"audio", m.Audio},
+		{"voice", m.Voice},
+		{"document", m.Document},
+		{"sticker", m.Sticker},
+	} {
+		if f.file != nil && f.file.FileID != "" {
+			return f.kind, f.file
+		}
+	}
+	return "", nil
+}
+
+// Name implements Platform
+func (t *Telegram) Name() string {
+	return "telegram"
+}
+
+// Verify implements Platform: Telegram sends the webhook's secret_token in
+// X-Telegram-Bot-Api-Secret-Token
+func (t *Telegram) Verify(header http.Header, _ []byte) error {
+	if t.secret == "" {
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(header.Get("X-Telegram-Bot-Api-Secret-Token")), []byte(t.secret)) != 1 {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// Parse implements Platform
+func (t *Telegram) Parse(body []byte) (*Media, error) {
+	var update telegramUpdate
+	if err := json.Unmarshal(body, &update); err != nil {
+		return nil, fmt.Errorf("invalid Telegram update: %w", err)
+	}
+	for _, message := range []*telegramMessage{update.Message, update.EditedMessage, update.ChannelPost, update.EditedChannelPost} {
+		if message == nil {
+			continue
+		}
+		if kind, file := message.media(); file != nil {
+			return &Media{
+				Kind:     kind,
+				FileID:   file.FileID,
+				MimeType: file.MimeType,
+				Filename: file.FileName,
+				Caption:  message.Caption,
+				Chat:     strconv.FormatInt(message.Chat.ID, 10),
+				Message:  strconv.FormatInt(message.MessageID, 10),
+			}, nil
+		}
+	}
+	return nil, ErrNoMedia
+}
+
+// Fetch implements Platform: getFile resolves the file ID to a path under
+// the bot's file URL. The Bot API serves files of up to 20 MB
+func (t *Telegram) Fetch(ctx context.Context, m *Media, limit int64) (string, []byte, error) {
+	resp, err := get(ctx, t.client, t.apiURL+"/bot"+t.token+"/getFile?file_id="+url.QueryEscape(m.FileID), "")
+	if err != nil {
+		return "", nil, fmt.Errorf("getFile failed: %w", err)
+	}
+	var file struct {
+		OK     bool `json:"ok"`
+		Result struct {
+			FilePath string `json:"file_path"`
+			FileSize int64  `json:"file_size"`
+		} `json:"result"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&file)
+	resp.Body.Close()
+	if err != nil || !file.OK || file.Result.FilePath == "" {
+		return "", nil, errors.New("getFile failed: no file path returned")
+	}
+	if file.Result.FileSize > limit {
+		return "", nil, ErrTooLarge
+	}
+
+	resp, err = get(ctx, t.client, t.apiURL+"/file/bot"+t.token+"/"+file.Result.FilePath, "")
+	if err != nil {
+		return "", nil, fmt.Errorf("file download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := readLimited(resp.Body, limit)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Voice messages are stored as .oga, which is Ogg audio
+	name := path.Base(file.Result.FilePath)
+	if ext := path.Ext(name); ext == ".oga" {
+		name = strings.TrimSuffix(name, ext) + ".ogg"
+	} else if ext == "" {
+		name = mediaName(m.MimeType)
+	}
+	return name, data, nil
+}
+
+// Reply implements Platform: the parameters of the send method, named in
+// "method" so the object is also a valid webhook reply
+func (t *Telegram) Reply(m *Media, fileURL string) any {
+	send := telegramSendMethods[m.Kind]
+	reply := map[string]any{
+		"method":  send[0],
+		"chat_id": json.Number(m.Chat),
+		send[1]:   fileURL,
+	}
+	if m.Caption != "" && m.Kind != "sticker" {
+		reply["caption"] = m.Caption
+	}
+	if m.Message != "" {
+		reply["reply_parameters"] = map[string]any{"message_id": json.Number(m.Message)}
+	}
+	return reply
+}
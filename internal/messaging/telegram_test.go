@@ -0,0 +1,98 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTelegramParse(t *testing.T) {
+	tg := NewTelegram("https://api.example", "42:abc", "", time.Second)
+
+	media, err := tg.Parse([]byte(`{"update_id": 1, "message": {"message_id": 7, "chat": {"id": -100123}, "caption": "look",
+		"photo": [{"file_id": "small", "width": 90}, {"file_id": "large", "width": 1280}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Media{Kind: "photo", FileID: "large", Caption: "look", Chat: "-100123", Message: "7"}
+	if *media != want {
+		t.Errorf("photo: Parse = %+v, want %+v", *media, want)
+	}
+
+	// Animations also carry a document
+	media, err = tg.Parse([]byte(`{"channel_post": {"message_id": 3, "chat": {"id": 5},
+		"animation": {"file_id": "gif", "mime_type": "video/mp4"}, "document": {"file_id": "gif", "mime_type": "video/mp4"}}}`))
+	if err != nil || media.Kind != "animation" || media.MimeType != "video/mp4" {
+		t.Errorf("animation: Parse = %+v, %v", media, err)
+	}
+
+	if _, err := tg.Parse([]byte(`{"message": {"message_id": 1, "chat": {"id": 5}, "text": "hi"}}`)); !errors.Is(err, ErrNoMedia) {
+		t.Errorf("text message: err = %v, want ErrNoMedia", err)
+	}
+}
+
+func TestTelegramVerify(t *testing.T) {
+	tg := NewTelegram("https://api.example", "42:abc", "s3cret", time.Second)
+	if err := tg.Verify(http.Header{"X-Telegram-Bot-Api-Secret-Token": {"s3cret"}}, nil); err != nil {
+		t.Errorf("valid secret: %v", err)
+	}
+	if err := tg.Verify(http.Header{"X-Telegram-Bot-Api-Secret-Token": {"guess"}}, nil); err != ErrBadSignature {
+		t.Errorf("wrong secret: err = %v", err)
+	}
+}
+
+func TestTelegramFetch(t *testing.T) {
+	content := bytes.Repeat([]byte{0x4f, 0x67}, 64)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bot42:abc/getFile":
+			if r.URL.Query().Get("file_id") != "voice-1" {
+				json.NewEncoder(rw).Encode(map[string]any{"ok": false, "description": "Bad Request: invalid file_id"})
+				return
+			}
+			json.NewEncoder(rw).Encode(map[string]any{"ok": true, "result": map[string]any{"file_path": "voice/file_3.oga", "file_size": len(content)}})
+		case "/file/bot42:abc/voice/file_3.oga":
+			rw.Write(content)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tg := NewTelegram(server.URL, "42:abc", "", 5*time.Second)
+	name, data, err := tg.Fetch(context.Background(), &Media{Kind: "voice", FileID: "voice-1"}, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "file_3.ogg" || !bytes.Equal(data, content) {
+		t.Errorf("Fetch = %q, %d bytes", name, len(data))
+	}
+
+	if _, _, err := tg.Fetch(context.Background(), &Media{Kind: "voice", FileID: "voice-1"}, 16); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("over limit: err = %v, want ErrTooLarge", err)
+	}
+	if _, _, err := tg.Fetch(context.Background(), &Media{Kind: "voice", FileID: "other"}, 1024); err == nil {
+		t.Error("unknown file fetched")
+	}
+
+	// Errors never reveal the bot token
+	_, _, err = NewTelegram("http://127.0.0.1:1", "42:abc", "", time.Second).Fetch(context.Background(), &Media{FileID: "x"}, 1024)
+	if err == nil || bytes.Contains([]byte(err.Error()), []byte("42:abc")) {
+		t.Errorf("unreachable API: err = %v", err)
+	}
+}
+
+func TestTelegramReply(t *testing.T) {
+	tg := NewTelegram("https://api.example", "42:abc", "", time.Second)
+
+	got, _ := json.Marshal(tg.Reply(&Media{Kind: "video_note", Chat: "-100123", Message: "7", Caption: "hey"}, "https://files/x.mp4"))
+	want := `{"caption":"hey","chat_id":-100123,"method":"sendVideo","reply_parameters":{"message_id":7},"video":"https://files/x.mp4"}`
+	if string(got) != want {
+		t.Errorf("Reply = %s\nwant %s", got, want)
+	}
+}
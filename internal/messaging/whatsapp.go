@@ -0,0 +1,178 @@
+package messaging
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// whatsAppMediaKinds are the message types carrying a media object
+var whatsAppMediaKinds = map[string]bool{"image": true, "audio": true, "video": true, "document": true, "sticker": true}
+
+// WhatsApp resolves the media of WhatsApp Cloud API webhooks through the
+// Graph API
+type WhatsApp struct {
+	graphURL  string
+	token     string
+	appSecret []byte
+	client    *http.Client
+}
+
+// NewWhatsApp creates the adapter for the Graph API at graphURL; appSecret
+// verifies X-Hub-Signature-256 when set
+func NewWhatsApp(graphURL, token, appSecret string, timeout time.Duration) *WhatsApp {
+	return &WhatsApp{
+		graphURL:  strings.TrimSuffix(graphURL, "/"),
+		token:     token,
+		appSecret: []byte(appSecret),
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+// whatsAppPayload is the part of a Cloud API webhook this adapter reads
+type whatsAppPayload struct {
+	Entry []struct {
+		Changes []struct {
+			Value struct {
+				Metadata struct {
+					PhoneNumberID string `json:"phone_number_id"`
+				} `json:"metadata"`
+				Messages []map[string]json.RawMessage `json:"messages"`
+			} `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+// whatsAppMedia is the media object of a message, keyed by its type
+type whatsAppMedia struct {
+	ID       string `json:"id"`
+	MimeType string `json:"mime_type"`
+	Filename string `json:"filename"`
+	Caption  string `json:"caption"`
+}
+
+// Name implements Platform
+func (w *WhatsApp) Name() string {
+	return "whatsapp"
+}
+
+// Verify implements Platform: X-Hub-Signature-256 is "sha256=<hex>", the
+// HMAC-SHA256 of the body with the app secret
+func (w *WhatsApp) Verify(header http.Header, body []byte) error {
+	if len(w.appSecret) == 0 {
+		return nil
+	}
+	mac := hmac.New(sha256.New, w.appSecret)
+	mac.Write(body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(header.Get("X-Hub-Signature-256")), []byte(want)) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// Parse implements Platform
+func (w *WhatsApp) Parse(body []byte) (*Media, error) {
+	var payload whatsAppPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid WhatsApp payload: %w", err)
+	}
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			for _, message := range change.Value.Messages {
+				var kind, from, id string
+				json.Unmarshal(message["type"], &kind)
+				if !whatsAppMediaKinds[kind] {
+					continue
+				}
+				var media whatsAppMedia
+				if err := json.Unmarshal(message[kind], &media); err != nil || media.ID == "" {
+					continue
+				}
+				json.Unmarshal(message["from"], &from)
+				json.Unmarshal(message["id"], &id)
+				return &Media{
+					Kind:     kind,
+					FileID:   media.ID,
+					MimeType: media.MimeType,
+					Filename: media.Filename,
+					Caption:  media.Caption,
+					Chat:     from,
+					Account:  change.Value.Metadata.PhoneNumberID,
+					Message:  id,
+				}, nil
+			}
+		}
+	}
+	return nil, ErrNoMedia
+}
+
+// Fetch implements Platform: the Graph API resolves the media ID to a
+// short-lived URL, which also requires the access token
+func (w *WhatsApp) Fetch(ctx context.Context, m *Media, limit int64) (string, []byte, error) {
+	resp, err := get(ctx, w.client, w.graphURL+"/"+url.PathEscape(m.FileID), w.token)
+	if err != nil {
+		return "", nil, fmt.Errorf("media lookup failed: %w", err)
+	}
+	var info struct {
+		URL      string `json:"url"`
+		MimeType string `json:"mime_type"`
+		FileSize int64  `json:"file_size"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	resp.Body.Close()
+	if err != nil || info.URL == "" {
+		return "", nil, fmt.Errorf("media lookup failed: no URL returned")
+	}
+	if info.FileSize > limit {
+		return "", nil, ErrTooLarge
+	}
+
+	resp, err = get(ctx, w.client, info.URL, w.token)
+	if err != nil {
+		return "", nil, fmt.Errorf("media download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := readLimited(resp.Body, limit)
+	if err != nil {
+		return "", nil, err
+	}
+
+	name := mediaName(info.MimeType)
+	if filepath.Ext(m.Filename) != "" {
+		name = filepath.Base(m.Filename)
+	}
+	return name, data, nil
+}
+
+// Reply implements Platform: the body of POST /{phone_number_id}/messages
+// sending fileURL back as a reply to the original message
+func (w *WhatsApp) Reply(m *Media, fileURL string) any {
+	media := map[string]string{"link": fileURL}
+	// Audio and stickers take no caption
+	if m.Caption != "" && m.Kind != "audio" && m.Kind != "sticker" {
+		media["caption"] = m.Caption
+	}
+	if m.Kind == "document" && m.Filename != "" {
+		media["filename"] = m.Filename
+	}
+	reply := map[string]any{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                m.Chat,
+		"type":              m.Kind,
+		m.Kind:              media,
+	}
+	if m.Message != "" {
+		reply["context"] = map[string]string{"message_id": m.Message}
+	}
+	return reply
+}
@@ -0,0 +1,123 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const whatsAppVoice = `{
+	"object": "whatsapp_business_account",
+	"entry": [{"id": "1", "changes": [{"field": "messages", "value": {
+		"messaging_product": "whatsapp",
+		"metadata": {"display_phone_number": "15550000000", "phone_number_id": "1099"},
+		"statuses": [{"id": "wamid.S", "status": "read"}],
+		"messages": [
+			{"from": "5511999990000", "id": "wamid.T", "type": "text", "text": {"body": "hi"}},
+			{"from": "5511999990000", "id": "wamid.A", "type": "audio", "audio": {"id": "777", "mime_type": "audio/ogg; codecs=opus", "voice": true}}
+		]
+	}}]}]
+}`
+
+func TestWhatsAppParse(t *testing.T) {
+	w := NewWhatsApp("https://graph.example", "token", "", time.Second)
+
+	media, err := w.Parse([]byte(whatsAppVoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Media{Kind: "audio", FileID: "777", MimeType: "audio/ogg; codecs=opus", Chat: "5511999990000", Account: "1099", Message: "wamid.A"}
+	if *media != want {
+		t.Errorf("Parse = %+v, want %+v", *media, want)
+	}
+
+	if _, err := w.Parse([]byte(`{"entry": [{"changes": [{"value": {"statuses": [{"id": "x"}]}}]}]}`)); !errors.Is(err, ErrNoMedia) {
+		t.Errorf("status update: err = %v, want ErrNoMedia", err)
+	}
+	if _, err := w.Parse([]byte(`not json`)); err == nil || errors.Is(err, ErrNoMedia) {
+		t.Errorf("invalid payload: err = %v", err)
+	}
+}
+
+func TestWhatsAppVerify(t *testing.T) {
+	body := []byte(whatsAppVoice)
+	mac := hmac.New(sha256.New, []byte("app-secret"))
+	mac.Write(body)
+	signed := http.Header{"X-Hub-Signature-256": {"sha256=" + hex.EncodeToString(mac.Sum(nil))}}
+
+	w := NewWhatsApp("https://graph.example", "token", "app-secret", time.Second)
+	if err := w.Verify(signed, body); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	if err := w.Verify(signed, append(body, ' ')); err != ErrBadSignature {
+		t.Errorf("altered body: err = %v", err)
+	}
+	if err := w.Verify(http.Header{}, body); err != ErrBadSignature {
+		t.Errorf("unsigned: err = %v", err)
+	}
+	if err := NewWhatsApp("https://graph.example", "token", "", time.Second).Verify(http.Header{}, body); err != nil {
+		t.Errorf("no app secret: %v", err)
+	}
+}
+
+func TestWhatsAppFetch(t *testing.T) {
+	content := bytes.Repeat([]byte{0x4f, 0x67}, 64)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/777":
+			json.NewEncoder(rw).Encode(map[string]any{"url": server.URL + "/lookaside/777", "mime_type": "audio/ogg", "file_size": len(content)})
+		case "/lookaside/777":
+			rw.Write(content)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	w := NewWhatsApp(server.URL, "token", "", 5*time.Second)
+	media := &Media{Kind: "audio", FileID: "777"}
+	name, data, err := w.Fetch(context.Background(), media, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "media.ogg" || !bytes.Equal(data, content) {
+		t.Errorf("Fetch = %q, %d bytes", name, len(data))
+	}
+
+	if _, _, err := w.Fetch(context.Background(), media, 16); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("over limit: err = %v, want ErrTooLarge", err)
+	}
+	if _, _, err := NewWhatsApp(server.URL, "wrong", "", 5*time.Second).Fetch(context.Background(), media, 1024); err == nil {
+		t.Error("fetched with a wrong token")
+	}
+}
+
+func TestWhatsAppReply(t *testing.T) {
+	w := NewWhatsApp("https://graph.example", "token", "", time.Second)
+	reply := w.Reply(&Media{Kind: "document", Filename: "menu.pdf", Caption: "Menu", Chat: "5511999990000", Message: "wamid.D"}, "https://files/x.pdf")
+
+	got, _ := json.Marshal(reply)
+	want := `{"context":{"message_id":"wamid.D"},"document":{"caption":"Menu","filename":"menu.pdf","link":"https://files/x.pdf"},"messaging_product":"whatsapp","recipient_type":"individual","to":"5511999990000","type":"document"}`
+	if string(got) != want {
+		t.Errorf("Reply = %s\nwant %s", got, want)
+	}
+
+	// Audio takes no caption
+	got, _ = json.Marshal(w.Reply(&Media{Kind: "audio", Caption: "ignored", Chat: "1"}, "https://files/x.ogg"))
+	if want := `{"audio":{"link":"https://files/x.ogg"},"messaging_product":"whatsapp","recipient_type":"individual","to":"1","type":"audio"}`; string(got) != want {
+		t.Errorf("audio Reply = %s", got)
+	}
+}
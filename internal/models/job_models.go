@@ -0,0 +1,243 @@
+package models
+
+import "time"
+
+// Job statuses
+const (
+	JobScheduled = "scheduled" // Waiting for process_at
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// States recorded in a job's event history. The pipeline phases between
+// running and done are reported by the processing itself
+const (
+	JobEventQueued      = "queued"
+	JobEventRunning     = "running"
+	JobEventDownloading = "downloading"
+	JobEventConverting  = "converting"
+	JobEventStoring     = "storing"
+	JobEventRetry       = "retry_scheduled"
+	JobEventRequeued    = "requeued" // Interrupted by a restart or an expired lease
+	JobEventDone        = "done"
+	JobEventFailed      = "failed"
+)
+
+// JobRequest submits a processing request to run asynchronously
+type JobRequest struct {
+	ProcessRequest
+	// When to process (RFC 3339); immediately when empty or in the past
+	ProcessAt *time.Time `json:"process_at,omitempty"`
+	// Outputs produced from the source, each processed independently (1 when 0)
+	Variants int `json:"variants,omitempty"`
+	// Process every object under an s3://bucket/prefix instead of arquivo,
+	// uploading the outputs under destination (s3://bucket/prefix)
+	Source      string   `json:"source,omitempty"`
+	Destination string   `json:"destination,omitempty"`
+	MediaTypes  []string `json:"media_types,omitempty"` // Only objects of these types (all media when empty)
+}
+
+// Job is an asynchronous processing request and, once finished, its result
+type Job struct {
+	ID          string           `json:"id"`
+	Status      string           `json:"status"`
+	Tenant      string           `json:"tenant,omitempty"`     // Tenant name when submitted with an API key
+	RequestID   string           `json:"request_id,omitempty"` // X-Request-ID of the submission, carried into every run
+	Request     ProcessRequest   `json:"request"`
+	ProcessAt   time.Time        `json:"process_at"`
+	Variants    int              `json:"variants,omitempty"`    // Outputs to produce (1 when 0)
+	Source      string           `json:"source,omitempty"`      // s3:// prefix processed instead of request.arquivo
+	Destination string           `json:"destination,omitempty"` // s3:// prefix receiving the outputs of a source job
+	MediaTypes  []string         `json:"media_types,omitempty"`
+	Progress    *JobProgress     `json:"progress,omitempty"` // Source jobs, once their objects are listed
+	Worker      string           `json:"worker,omitempty"`   // Worker node running the job (dispatcher role)
+	LeaseUntil  *time.Time       `json:"lease_until,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	StartedAt   *time.Time       `json:"started_at,omitempty"`
+	FinishedAt  *time.Time       `json:"finished_at,omitempty"`
+	HTTPStatus  int              `json:"http_status,omitempty"` // Status /api/process would have returned
+	Result      *ProcessResponse `json:"result,omitempty"`
+	Attempts    int              `json:"attempts,omitempty"` // Failed runs since submitted or redriven
+	Failures    []JobFailure     `json:"failures,omitempty"` // Every failed run, oldest first
+	Events      []JobEvent       `json:"events,omitempty"`   // State transitions, oldest first
+}
+
+// JobEvent records when a job entered a state
+type JobEvent struct {
+	State  string    `json:"state"`
+	At     time.Time `json:"at"`
+	Detail string    `json:"detail,omitempty"` // Variant, source object, worker or failure
+}
+
+// JobEventSpan is an event and how long the job stayed in its state
+type JobEventSpan struct {
+	JobEvent
+	DurationMs int64 `json:"duration_ms"` // Until the next event, or until now for the current state
+}
+
+// JobEventsResponse is the timeline of a job
+type JobEventsResponse struct {
+	JobID   string         `json:"job_id"`
+	Status  string         `json:"status"`
+	Current string         `json:"current,omitempty"` // State of an unfinished job
+	Events  []JobEventSpan `json:"events"`
+}
+
+// Job states reported to polling clients
+const (
+	JobStateQueued     = "queued"
+	JobStateProcessing = "processing"
+	JobStateDone       = "done"
+	JobStateFailed     = "failed"
+)
+
+// JobView is a job as the jobs API returns it, summarized for polling
+type JobView struct {
+	Job
+	State   string `json:"state"`              // queued, processing, done or failed
+	Phase   string `json:"phase,omitempty"`    // Latest state entered while processing, e.g. converting
+	NovaURL string `json:"nova_url,omitempty"` // Output of a finished job
+}
+
+// JobFailure records one failed run of a job
+type JobFailure struct {
+	Attempt    int       `json:"attempt"`
+	At         time.Time `json:"at"`
+	HTTPStatus int       `json:"http_status"`
+	Code       string    `json:"code,omitempty"`
+	Message    string    `json:"message"`
+	Worker     string    `json:"worker,omitempty"`
+	Retryable  bool      `json:"retryable"` // Transient, so retried while attempts remained
+}
+
+// DeadLetterResponse lists failed jobs kept for inspection and redrive
+type DeadLetterResponse struct {
+	Count int   `json:"count"`
+	Jobs  []Job `json:"jobs"`
+}
+
+// JobProgress counts the objects of a source job handled so far
+type JobProgress struct {
+	Total     int `json:"total"`     // Media objects listed under the source prefix
+	Processed int `json:"processed"` // Processed and uploaded
+	Failed    int `json:"failed"`
+}
+
+// WorkLeaseRequest asks the dispatcher for a due job
+type WorkLeaseRequest struct {
+	Worker string `json:"worker"`
+}
+
+// WorkLease hands a job to a worker until LeaseUntil; the worker renews it
+// with heartbeats, otherwise the job is run again elsewhere
+type WorkLease struct {
+	Job        Job       `json:"job"`
+	LeaseUntil time.Time `json:"lease_until"`
+}
+
+// WorkHeartbeat renews a lease and reports the job's progress
+type WorkHeartbeat struct {
+	Worker   string       `json:"worker"`
+	Progress *JobProgress `json:"progress,omitempty"`
+	Events   []JobEvent   `json:"events,omitempty"` // Pipeline phases since the last heartbeat
+}
+
+// WorkResult is the outcome of a leased job, once its files are uploaded
+type WorkResult struct {
+	Worker     string          `json:"worker"`
+	HTTPStatus int             `json:"http_status"`
+	Result     ProcessResponse `json:"result"`
+	Events     []JobEvent      `json:"events,omitempty"` // Phases not yet sent with a heartbeat
+}
+
+// RecurringRequest registers a source to re-process on a cadence
+type RecurringRequest struct {
+	ProcessRequest
+	Every       string `json:"every"`       // Cadence as a Go duration, e.g. "1h"
+	Variants    int    `json:"variants"`    // Fresh variants produced per run
+	Destination string `json:"destination"` // s3://bucket/prefix receiving each run's outputs
+}
+
+// RecurringSource is a registered source and the outcome of its latest run
+type RecurringSource struct {
+	ID          string         `json:"id"`
+	Tenant      string         `json:"tenant,omitempty"` // Tenant name when registered with an API key
+	Request     ProcessRequest `json:"request"`
+	Every       string         `json:"every"`
+	Variants    int            `json:"variants"`
+	Destination string         `json:"destination"`
+	CreatedAt   time.Time      `json:"created_at"`
+	NextRunAt   time.Time      `json:"next_run_at"`
+	Running     bool           `json:"running"`
+	LastRun     *RecurringRun  `json:"last_run,omitempty"`
+}
+
+// RecurringRun summarizes one run of a recurring source
+type RecurringRun struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Uploaded   int       `json:"uploaded"`
+	Failed     int       `json:"failed"`
+	Objects    []string  `json:"objects,omitempty"` // s3:// URLs written by the run
+	Error      string    `json:"error,omitempty"`   // Last failure, if any
+}
+
+// Manifest item statuses
+const (
+	ManifestItemReady   = "ready"   // File available at url
+	ManifestItemExpired = "expired" // File produced but no longer stored
+	ManifestItemFailed  = "failed"  // Nothing was produced
+	ManifestItemPending = "pending" // Job has not finished
+)
+
+// JobManifest lists every file a job produced, for ingestion by sending pipelines
+type JobManifest struct {
+	JobID      string           `json:"job_id"`
+	Status     string           `json:"status"`
+	Progress   *JobProgress     `json:"progress,omitempty"` // Source jobs
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	Profile    string           `json:"profile,omitempty"`
+	Techniques []string         `json:"techniques,omitempty"` // Optional techniques the profile enabled
+	Items      []ManifestItem   `json:"items"`
+	Diversity  *DiversityReport `json:"diversity,omitempty"` // Image variants only
+}
+
+// Diversity verdicts
+const (
+	DiversityDiverse    = "diverse"    // Every pair of variants is perceptually distinct
+	DiversityClustering = "clustering" // Some variants are perceptual near-duplicates
+)
+
+// DiversityReport summarizes the pairwise pHash distances (0-64) between the
+// variants of a job
+type DiversityReport struct {
+	Hashed           int     `json:"hashed"` // Variants with a perceptual hash
+	Pairs            int     `json:"pairs"`
+	MinDistance      int     `json:"min_distance"`
+	MaxDistance      int     `json:"max_distance"`
+	MeanDistance     float64 `json:"mean_distance"`
+	ClusterThreshold int     `json:"cluster_threshold"` // Pairs closer than this are clustered
+	ClusteredPairs   int     `json:"clustered_pairs"`
+	Verdict          string  `json:"verdict"`
+}
+
+// ManifestItem is one produced file
+type ManifestItem struct {
+	Index     int           `json:"index"`
+	Status    string        `json:"status"`
+	Page      int           `json:"page,omitempty"`    // Rasterized page number
+	Variant   int           `json:"variant,omitempty"` // Variant number of a multi-variant job
+	Source    string        `json:"source,omitempty"`  // s3:// object processed by a source job
+	Objects   []string      `json:"objects,omitempty"` // s3:// outputs uploaded for source
+	FileID    string        `json:"file_id,omitempty"`
+	URL       string        `json:"url,omitempty"`
+	MediaType string        `json:"media_type,omitempty"`
+	Size      int64         `json:"size,omitempty"`
+	SHA256    string        `json:"sha256,omitempty"`
+	PHash     string        `json:"phash,omitempty"` // Hex perceptual hash (image variants)
+	ExpiresAt *time.Time    `json:"expires_at,omitempty"`
+	Speed     float64       `json:"speed,omitempty"`
+	Encoding  *EncodingInfo `json:"encoding,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
@@ -1,15 +1,354 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // ProcessRequest represents a simple processing request
 type ProcessRequest struct {
 	Arquivo string `json:"arquivo" validate:"required"` // URL do arquivo
+	// Alternative URLs for the same file, tried in order if arquivo fails
+	ArquivoMirrors []string `json:"arquivo_mirrors,omitempty"`
+	Profile        string   `json:"profile,omitempty"` // standard/color/paranoid (server default if empty)
+	Compare        bool     `json:"compare,omitempty"` // Run the server's similarity engines on input and output
+	// Anti-fingerprint level basic/moderate/paranoid, run instead of the
+	// profile's script techniques; audio outputs become Opus and videos MP4
+	// Excludes profile, speed, trim_silence, metadata, gps_region, no_marker,
+	// preserve_duration and stream, and tenants with default metadata
+	Level string `json:"level,omitempty"`
+	// Name the file is saved as when nova_url is downloaded (any language; extension follows the output)
+	// May contain {variable} placeholders, e.g. "promo-{campaign_id}-{recipient}"
+	Filename string `json:"filename,omitempty"`
+	// Values substituted into filename and metadata placeholders
+	Variables map[string]string `json:"variables,omitempty"`
+	// Tags embedded in the output (audio/image/video), e.g. {"comment": "campaign={campaign_id}"}
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Embed no uid marker or encoder tags; outputs carry no metadata at all
+	// and cannot be traced with /api/identify. Excludes metadata
+	NoMarker bool `json:"no_marker,omitempty"`
+	// Embed random GPS coordinates within this region in JPEG/PNG outputs
+	// Only accepted when the server enables GPS_SYNTHESIS
+	GPSRegion *GPSRegion `json:"gps_region,omitempty"`
+	// User-Agent sent when downloading arquivo and its mirrors (server default if empty)
+	UserAgent string `json:"user_agent,omitempty"`
+	// Move the outputs to long-term storage when their temp TTL ends instead
+	// of deleting them; archive_url keeps serving them afterwards
+	// Only accepted when the server configures ARCHIVE_DESTINATION
+	Archive bool `json:"archive,omitempty"`
+
+	// Conditional processing, evaluated after probing the download
+	SkipIfSmallerThan      int64   `json:"skip_if_smaller_than,omitempty"`      // Bytes; smaller files are returned unmodified
+	OnlyIfDurationBelow    float64 `json:"only_if_duration_below,omitempty"`    // Seconds; longer files are returned unmodified
+	ErrorIfResolutionAbove string  `json:"error_if_resolution_above,omitempty"` // e.g. "1920x1080"; larger inputs fail with 422
+
+	// Multi-page rasterization (PDF/TIFF): one image per page, optionally zipped
+	Rasterize bool `json:"rasterize,omitempty"`
+	Zip       bool `json:"zip,omitempty"` // Return a single .zip instead of one file per page or archive entry
+
+	// Return the processed bytes in the response body instead of a JSON nova_url
+	// Audio is sent chunked while ffmpeg encodes; other media once finished
+	Stream bool `json:"stream,omitempty"`
+
+	// Answer at once with a job to poll at GET /api/jobs/:id instead of
+	// waiting for the conversion, for inputs that outlast client timeouts
+	Async bool `json:"async,omitempty"`
+
+	// Audio silence trimming (optional, thresholds fall back to server defaults)
+	TrimSilence        bool     `json:"trim_silence,omitempty"`
+	SilenceThresholdDB *float64 `json:"silence_threshold_db,omitempty"` // e.g. -50
+	SilenceKeepMs      int      `json:"silence_keep_ms,omitempty"`      // Silence kept at each edge, e.g. 200
+
+	// Keep the audio output exactly as long as the input (server default if omitted)
+	PreserveDuration *bool `json:"preserve_duration,omitempty"`
+
+	// Playback speed (audio/video): fixed value or [min, max] range for micro-variation
+	Speed      float64   `json:"speed,omitempty"`       // e.g. 1.05
+	SpeedRange []float64 `json:"speed_range,omitempty"` // e.g. [0.98, 1.02]
+
+	// File sent as multipart/form-data in place of arquivo; never part of the JSON body
+	Upload *Upload `json:"-"`
+}
+
+// Upload is a file sent in the request body instead of fetched from a URL
+type Upload struct {
+	Name string // Client-side filename, used like the path of arquivo for type detection
+	Data []byte
+}
+
+// BatchRequest processes several files in one call
+type BatchRequest struct {
+	// Each item is a URL string or a full process request object
+	Items []BatchItem `json:"items"`
+}
+
+// BatchItem is one file of a batch, given as a bare URL or a process request
+type BatchItem struct {
+	ProcessRequest
+}
+
+// UnmarshalJSON accepts a URL string as shorthand for {"arquivo": url}
+func (i *BatchItem) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		*i = BatchItem{ProcessRequest{Arquivo: url}}
+		return nil
+	}
+	return json.Unmarshal(data, &i.ProcessRequest)
+}
+
+// BatchResponse holds the result of every item, in request order
+type BatchResponse struct {
+	Success   bool          `json:"success"` // Every item succeeded
+	Message   string        `json:"message"`
+	Total     int           `json:"total"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []BatchResult `json:"results"`
+}
+
+// BatchResult is the response one item would have had from /api/process
+type BatchResult struct {
+	Index      int `json:"index"`
+	HTTPStatus int `json:"http_status"`
+	ProcessResponse
+}
+
+// MessagingResponse is the result of processing the media of a chat
+// platform webhook
+type MessagingResponse struct {
+	ProcessResponse
+	Platform string `json:"platform"` // whatsapp or telegram
+	// Platform send request re-uploading nova_url to the sender, quoting the
+	// original message: the body of WhatsApp's POST /{phone_number_id}/messages,
+	// or Telegram parameters naming their method (also a valid webhook reply)
+	Reply any `json:"reply,omitempty"`
+}
+
+// GPSRegion is a bounding box in decimal degrees
+type GPSRegion struct {
+	MinLat float64 `json:"min_lat"`
+	MaxLat float64 `json:"max_lat"`
+	MinLon float64 `json:"min_lon"`
+	MaxLon float64 `json:"max_lon"`
 }
 
 // ProcessResponse represents the processing response
 type ProcessResponse struct {
+	Success           bool               `json:"success"`
+	Message           string             `json:"message"`
+	Code              string             `json:"code,omitempty"`        // Machine-readable error code (validation and download failures)
+	NovaURL           string             `json:"nova_url,omitempty"`    // On the CDN when CDN_BASE_URL is set
+	ArchiveURL        string             `json:"archive_url,omitempty"` // Stable URL of the output, valid past its TTL (archive only)
+	MediaType         string             `json:"media_type,omitempty"`
+	FileID            string             `json:"file_id,omitempty"`
+	Encoding          *EncodingInfo      `json:"encoding,omitempty"`           // Video encoder decision
+	Speed             float64            `json:"speed,omitempty"`              // Applied playback speed
+	Profile           string             `json:"profile,omitempty"`            // Technique profile used
+	Level             string             `json:"level,omitempty"`              // Anti-fingerprint level used instead of a profile
+	PHashDistance     *int               `json:"phash_distance,omitempty"`     // Input/output pHash distance 0-64 (compare only)
+	Similarity        []SimilarityResult `json:"similarity,omitempty"`         // Per-engine input/output comparison (compare only)
+	SimilarityVerdict string             `json:"similarity_verdict,omitempty"` // pass, fail or error over all engines
+	Pages             []PageInfo         `json:"pages,omitempty"`              // Per-page results (rasterize only)
+	Entries           []ArchiveEntryInfo `json:"entries,omitempty"`            // Per-entry results (archive input only)
+	Skipped           bool               `json:"skipped,omitempty"`            // A rule returned the file unmodified
+	SkipReason        string             `json:"skip_reason,omitempty"`
+	Quota             *QuotaInfo         `json:"quota,omitempty"`      // Exhausted quota (429 only)
+	Cost              *CostInfo          `json:"cost,omitempty"`       // Estimate over the cost budget (cost_exceeded only)
+	Variants          []VariantInfo      `json:"variants,omitempty"`   // Every output of a multi-variant job
+	Objects           []ObjectInfo       `json:"objects,omitempty"`    // Every object of an S3 source job
+	Escalation        int                `json:"escalation,omitempty"` // Technique escalation level after dedup feedback
+	Timings           *Timings           `json:"timings,omitempty"`    // Time spent in each phase of the request
+	RequestID         string             `json:"request_id,omitempty"` // X-Request-ID the request was traced under
+}
+
+// Timings splits the time of a request between network and CPU bound
+// phases. Phases the request did not reach are 0
+type Timings struct {
+	DownloadMs int64 `json:"download_ms"`
+	ProbeMs    int64 `json:"probe_ms"`          // Media type detection and inspection
+	WaitMs     int64 `json:"wait_ms,omitempty"` // Queued for the memory budget
+	ConvertMs  int64 `json:"convert_ms"`        // Including rasterizing and unpacking
+	CompareMs  int64 `json:"compare_ms,omitempty"`
+	StoreMs    int64 `json:"store_ms"`
+	TotalMs    int64 `json:"total_ms"`
+}
+
+// NonceResponse is the processing nonce read back from a file
+type NonceResponse struct {
+	Success     bool      `json:"success"`
+	Nonce       string    `json:"nonce"`        // <unix nanos>_<32 hex>, as embedded in the uid:... marker
+	ProcessedAt time.Time `json:"processed_at"` // When the nonce was generated
+	FileID      string    `json:"file_id,omitempty"`
+	MediaType   string    `json:"media_type,omitempty"`
+}
+
+// CostInfo is the estimated work of an input and the budget it exceeded
+// Limits of 0 are not enforced
+type CostInfo struct {
+	MegapixelFrames    float64 `json:"megapixel_frames"` // Width × height × frames, in millions
+	AudioSeconds       float64 `json:"audio_seconds"`
+	MaxMegapixelFrames float64 `json:"max_megapixel_frames"`
+	MaxAudioSeconds    float64 `json:"max_audio_seconds"`
+}
+
+// QuotaInfo describes the quota that rejected a request and when it resets
+type QuotaInfo struct {
+	Kind    string    `json:"kind"` // conversions_per_day/bytes_per_month
+	Limit   int64     `json:"limit"`
+	Used    int64     `json:"used"`
+	ResetAt time.Time `json:"reset_at"`
+}
+
+// QuotaUpdateRequest adjusts a tenant's quotas; omitted fields keep their value
+type QuotaUpdateRequest struct {
+	ConversionsPerDay *int64 `json:"conversions_per_day,omitempty"` // 0 = unlimited
+	BytesPerMonth     *int64 `json:"bytes_per_month,omitempty"`     // 0 = unlimited
+}
+
+// TenantMetadataRequest replaces a tenant's default metadata fields
+// Values may contain {variable} placeholders filled from each request
+type TenantMetadataRequest struct {
+	Metadata map[string]string `json:"metadata"` // Empty removes the defaults
+}
+
+// TenantMetadata is the metadata merged into every output of a tenant
+type TenantMetadata struct {
+	Tenant   string            `json:"tenant"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// PageInfo describes one rasterized page
+type PageInfo struct {
+	Page       int    `json:"page"`
+	NovaURL    string `json:"nova_url"`
+	ArchiveURL string `json:"archive_url,omitempty"`
+	FileID     string `json:"file_id"`
+}
+
+// ArchiveEntryInfo describes the result for one file of an archive input
+type ArchiveEntryInfo struct {
+	Name       string `json:"name"` // Path inside the archive
+	MediaType  string `json:"media_type,omitempty"`
+	NovaURL    string `json:"nova_url,omitempty"` // Omitted when zip repackages the outputs
+	ArchiveURL string `json:"archive_url,omitempty"`
+	FileID     string `json:"file_id,omitempty"`
+	Error      string `json:"error,omitempty"` // Why the entry was not processed
+}
+
+// ObjectInfo describes the result for one object of an S3 source job
+type ObjectInfo struct {
+	Source    string   `json:"source"` // s3:// object processed
+	MediaType string   `json:"media_type,omitempty"`
+	NovaURL   string   `json:"nova_url,omitempty"`
+	FileID    string   `json:"file_id,omitempty"`
+	Objects   []string `json:"objects,omitempty"` // s3:// outputs uploaded (one per page when rasterized)
+	Error     string   `json:"error,omitempty"`
+}
+
+// VariantInfo describes one output of a multi-variant job
+type VariantInfo struct {
+	Variant    int           `json:"variant"`
+	NovaURL    string        `json:"nova_url"`
+	ArchiveURL string        `json:"archive_url,omitempty"`
+	FileID     string        `json:"file_id"`
+	MediaType  string        `json:"media_type"`
+	Speed      float64       `json:"speed,omitempty"`
+	Encoding   *EncodingInfo `json:"encoding,omitempty"`
+}
+
+// EncodingInfo describes how a video was re-encoded
+type EncodingInfo struct {
+	CRF               int    `json:"crf"`
+	MaxBitrateKbps    int    `json:"max_bitrate_kbps,omitempty"`
+	SourceBitrateKbps int    `json:"source_bitrate_kbps,omitempty"`
+	SourceResolution  string `json:"source_resolution,omitempty"`
+	Reason            string `json:"reason"`
+	AudioCopied       bool   `json:"audio_copied"` // Audio passed through without re-encode
+}
+
+// CapabilitiesResponse describes what this deployment accepts and produces
+type CapabilitiesResponse struct {
+	MediaTypes        []MediaTypeInfo        `json:"media_types"`
+	FFmpegVersion     string                 `json:"ffmpeg_version"`
+	Encoders          map[string]bool        `json:"encoders"`
+	HardwareEncoders  map[string]bool        `json:"hardware_encoders"`
+	Tools             map[string]bool        `json:"tools"`
+	Limits            CapabilityLimits       `json:"limits"`
+	Techniques        []string               `json:"techniques"` // Optional techniques selectable through profiles
+	Profiles          []ProfileInfo          `json:"profiles"`
+	DefaultProfile    string                 `json:"default_profile"`
+	Levels            []string               `json:"levels"`             // Anti-fingerprint levels accepted as level
+	SimilarityEngines []SimilarityEngineInfo `json:"similarity_engines"` // Engines run by compare
+}
+
+// SimilarityEngineInfo is one comparison engine enabled on this deployment
+type SimilarityEngineInfo struct {
+	Engine     string   `json:"engine"`
+	MediaTypes []string `json:"media_types"`
+	Threshold  float64  `json:"threshold"` // Similarity at or above which the verdict is fail
+}
+
+// SimilarityResult is the outcome of one engine comparing input and output
+type SimilarityResult struct {
+	Engine     string  `json:"engine"`
+	Score      float64 `json:"score"`      // Raw engine output: a distance, or SSIM
+	Similarity float64 `json:"similarity"` // 0 (unrelated) to 1 (identical)
+	Threshold  float64 `json:"threshold"`
+	// pass: the engine no longer matches the output to its input; fail: it
+	// still does; error: the engine could not compare them
+	Verdict string `json:"verdict"`
+	Error   string `json:"error,omitempty"`
+}
+
+// CompareResponse is the result of POST /api/compare
+type CompareResponse struct {
+	Success   bool               `json:"success"`
+	MediaType string             `json:"media_type"`
+	Verdict   string             `json:"verdict"` // fail when any engine still matches, pass when none does
+	Results   []SimilarityResult `json:"results"`
+}
+
+// MediaTypeInfo lists the formats accepted for one media type
+type MediaTypeInfo struct {
+	MediaType string       `json:"media_type"`
+	Formats   []FormatInfo `json:"formats"`
+}
+
+// FormatInfo pairs an input format with the format it is returned as
+type FormatInfo struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+// CapabilityLimits are the server-side bounds a request must respect
+type CapabilityLimits struct {
+	MaxDownloadBytes      int64   `json:"max_download_bytes"`
+	MaxMirrors            int     `json:"max_mirrors"`
+	MaxBatchItems         int     `json:"max_batch_items"` // 0 = /api/process/batch disabled
+	MinSpeed              float64 `json:"min_speed"`
+	MaxSpeed              float64 `json:"max_speed"`
+	RequestTimeoutSeconds int     `json:"request_timeout_seconds"`
+}
+
+// ProfileInfo names a technique profile and the techniques it enables
+type ProfileInfo struct {
+	Name       string   `json:"name"`
+	Techniques []string `json:"techniques"`
+}
+
+// FeedbackRequest reports a delivered variant as flagged or deduplicated downstream
+type FeedbackRequest struct {
+	FileID string `json:"file_id"`          // file_id or nova_url file name of the variant
+	Reason string `json:"reason,omitempty"` // e.g. "whatsapp forwarded-many-times"
+}
+
+// FeedbackResponse is the source's escalation state after the report
+type FeedbackResponse struct {
 	Success   bool   `json:"success"`
 	Message   string `json:"message"`
-	NovaURL   string `json:"nova_url,omitempty"`
-	MediaType string `json:"media_type,omitempty"`
-	FileID    string `json:"file_id,omitempty"`
+	Level     int    `json:"level"` // Level new variants of the source are produced at
+	MaxLevel  int    `json:"max_level"`
+	Escalated bool   `json:"escalated"`
+	Flags     int    `json:"flags"` // Reports received for the source
 }
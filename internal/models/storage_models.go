@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// StoredFile describes a processed file held in temp storage
+type StoredFile struct {
+	ID        string    `json:"id"`
+	MediaType string    `json:"media_type"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RequestID string    `json:"request_id,omitempty"` // Request that produced the file
+}
+
+// StorageListResponse lists stored files and their total size
+type StorageListResponse struct {
+	Files      []StoredFile `json:"files"`
+	Count      int          `json:"count"`
+	TotalBytes int64        `json:"total_bytes"`
+}
+
+// StoragePurgeRequest selects files to delete before their TTL
+// At least one filter is required unless all is set
+type StoragePurgeRequest struct {
+	Tenant    string `json:"tenant,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
+	OlderThan string `json:"older_than,omitempty"` // Minimum age, e.g. "5m"
+	All       bool   `json:"all,omitempty"`
+	DryRun    bool   `json:"dry_run,omitempty"` // Report what would be purged without deleting
+}
+
+// StoragePurgeResponse reports what a purge removed
+type StoragePurgeResponse struct {
+	Success    bool  `json:"success"`
+	Purged     int   `json:"purged"`
+	FreedBytes int64 `json:"freed_bytes"`
+	DryRun     bool  `json:"dry_run"`
+}
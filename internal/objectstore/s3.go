@@ -0,0 +1,329 @@
+package objectstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Object is an object returned by List
+type Object struct {
+	Key  string
+	Size int64
+}
+
+// S3 reads and writes objects in S3 or an S3-compatible store (MinIO, R2, ...) using
+// Signature Version 4, without pulling in the AWS SDK
+type S3 struct {
+	endpoint  *neturl.URL
+	region    string
+	accessKey string
+	secretKey string
+	pathStyle bool // bucket in the path instead of the host name
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewS3 creates an S3 client; endpoint defaults to AWS for region
+func NewS3(endpoint, region, accessKey, secretKey string, pathStyle bool) (*S3, error) {
+	if region == "" {
+		region = "us-east-1"
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	u, err := neturl.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("S3 credentials are required")
+	}
+
+	return &S3{
+		endpoint:  u,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		pathStyle: pathStyle,
+		client:    &http.Client{Timeout: 10 * time.Minute},
+		now:       time.Now,
+	}, nil
+}
+
+// ParseURL splits "s3://bucket/prefix" into bucket and key prefix
+func ParseURL(raw string) (bucket, prefix string, err error) {
+	u, err := neturl.Parse(raw)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid S3 URL %q (want s3://bucket/prefix)", raw)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// PutFile uploads the file at path as bucket/key
+func (s *S3) PutFile(ctx context.Context, bucket, key, path, contentType string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The payload hash is part of the signature, so the file is read twice
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(bucket, key), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, hex.EncodeToString(hash.Sum(nil)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("S3 upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("S3 upload failed: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// objectURL addresses key in bucket, path-style or virtual-hosted
+func (s *S3) objectURL(bucket, key string) string {
+	u := *s.endpoint
+	if s.pathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket + "/" + key
+	} else {
+		u.Host = bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	// Send exactly the encoding that is signed
+	u.RawPath = escapePath(u.Path)
+	return u.String()
+}
+
+// List returns the objects whose keys start with prefix, in key order,
+// stopping once limit objects are listed (0 = all)
+func (s *S3) List(ctx context.Context, bucket, prefix string, limit int) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		q := neturl.Values{"list-type": {"2"}}
+		if prefix != "" {
+			q.Set("prefix", prefix)
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u := s.bucketURL(bucket)
+		u.RawQuery = canonicalQuery(q)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		s.sign(req, emptyPayloadHash)
+
+		page, err := s.listPage(req)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range page.Contents {
+			objects = append(objects, Object{Key: c.Key, Size: c.Size})
+			if limit > 0 && len(objects) >= limit {
+				return objects, nil
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// listBucketResult is the ListObjectsV2 response body
+type listBucketResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) listPage(req *http.Request) (*listBucketResult, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 list failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("S3 list failed: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var page listBucketResult
+	if err := xml.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("S3 list failed: invalid response: %w", err)
+	}
+	return &page, nil
+}
+
+// PresignGet returns a URL that downloads bucket/key without credentials
+// until expires has passed, so objects can go through the URL pipeline
+func (s *S3) PresignGet(bucket, key string, expires time.Duration) string {
+	u, _ := neturl.Parse(s.objectURL(bucket, key))
+	now := s.now().UTC()
+
+	q := neturl.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {s.accessKey + "/" + s.scope(now)},
+		"X-Amz-Date":          {now.Format(amzDateFormat)},
+		"X-Amz-Expires":       {strconv.Itoa(int(expires.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	query := canonicalQuery(q)
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		escapePath(u.Path),
+		query,
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	u.RawQuery = query + "&X-Amz-Signature=" + s.signature(now, canonicalRequest)
+	return u.String()
+}
+
+// bucketURL addresses the bucket itself, path-style or virtual-hosted
+func (s *S3) bucketURL(bucket string) *neturl.URL {
+	u := *s.endpoint
+	if s.pathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket
+	} else {
+		u.Host = bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/"
+	}
+	u.RawPath = escapePath(u.Path)
+	return &u
+}
+
+// amzDateFormat is the timestamp format of X-Amz-Date
+const amzDateFormat = "20060102T150405Z"
+
+// sign adds SigV4 headers to req for a payload with the given SHA-256
+func (s *S3) sign(req *http.Request, payloadHash string) {
+	now := s.now().UTC()
+	amzDate := now.Format(amzDateFormat)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, s.scope(now), signedHeaders, s.signature(now, canonicalRequest)))
+}
+
+// scope is the credential scope of a request signed at t
+func (s *S3) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+}
+
+// signature is the SigV4 signature of a canonical request made at t
+func (s *S3) signature(t time.Time, canonicalRequest string) string {
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + t.Format(amzDateFormat) + "\n" + s.scope(t) + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// canonicalQuery encodes q sorted by key with SigV4 escaping; requests must
+// send exactly this encoding, since it is what gets signed
+func canonicalQuery(q neturl.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, uriEncode(k, false)+"="+uriEncode(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath percent-encodes everything but unreserved characters and '/',
+// as SigV4 requires
+func escapePath(path string) string {
+	return uriEncode(path, true)
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and '/'
+// when keepSlash is set
+func uriEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c == '/' && keepSlash) || c == '-' || c == '.' || c == '_' || c == '~' ||
+			('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,275 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Document is an OpenAPI 3 document built from registered operations
+// Schemas are generated from the Go request/response types by reflection
+type Document struct {
+	mu         sync.RWMutex
+	title      string
+	version    string
+	paths      map[string]map[string]any
+	components map[string]any
+}
+
+// Operation describes one route for the generated document
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Request     any              // JSON request body type (a zero value), nil when none
+	Query       []Parameter      // Query string parameters
+	Headers     []Parameter      // Request headers
+	Responses   map[int]Response // Keyed by HTTP status
+	Security    bool             // Requires the admin token
+	pathParams  []string         // Filled from ":name" segments on registration
+}
+
+// Parameter is a query string or header parameter
+type Parameter struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// Response is one documented response of an operation
+type Response struct {
+	Description string
+	Body        any    // JSON body type (a zero value), nil for non-JSON bodies
+	ContentType string // Overrides application/json (e.g. application/octet-stream)
+}
+
+// pathParamPattern matches fiber path parameters such as ":id"
+var pathParamPattern = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// New creates an empty document
+func New(title, version string) *Document {
+	return &Document{
+		title:      title,
+		version:    version,
+		paths:      make(map[string]map[string]any),
+		components: make(map[string]any),
+	}
+}
+
+// Add registers op for method and a fiber-style path ("/api/files/:id")
+func (d *Document) Add(method, path string, op Operation) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, m := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		op.pathParams = append(op.pathParams, m[1])
+	}
+	path = pathParamPattern.ReplaceAllString(path, "{$1}")
+
+	if d.paths[path] == nil {
+		d.paths[path] = make(map[string]any)
+	}
+	d.paths[path][strings.ToLower(method)] = d.operation(op)
+}
+
+// JSON returns the document as a JSON-serializable map
+func (d *Document) JSON() map[string]any {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   d.title,
+			"version": d.version,
+		},
+		"paths": d.paths,
+		"components": map[string]any{
+			"schemas": d.components,
+			"securitySchemes": map[string]any{
+				"adminToken": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// operation converts op to its OpenAPI object; callers hold d.mu
+func (d *Document) operation(op Operation) map[string]any {
+	out := map[string]any{}
+	if op.Summary != "" {
+		out["summary"] = op.Summary
+	}
+	if op.Description != "" {
+		out["description"] = op.Description
+	}
+	if len(op.Tags) > 0 {
+		out["tags"] = op.Tags
+	}
+
+	var params []any
+	for _, name := range op.pathParams {
+		params = append(params, map[string]any{
+			"name": name, "in": "path", "required": true,
+			"schema": map[string]any{"type": "string"},
+		})
+	}
+	for _, p := range op.Query {
+		param := map[string]any{
+			"name": p.Name, "in": "query", "required": p.Required,
+			"schema": map[string]any{"type": "string"},
+		}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		params = append(params, param)
+	}
+	for _, p := range op.Headers {
+		param := map[string]any{
+			"name": p.Name, "in": "header", "required": p.Required,
+			"schema": map[string]any{"type": "string"},
+		}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		params = append(params, param)
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+
+	if op.Request != nil {
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": d.schema(reflect.TypeOf(op.Request))},
+			},
+		}
+	}
+
+	responses := map[string]any{}
+	for status, resp := range op.Responses {
+		r := map[string]any{"description": resp.Description}
+		contentType := resp.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		switch {
+		case resp.Body != nil:
+			r["content"] = map[string]any{contentType: map[string]any{"schema": d.schema(reflect.TypeOf(resp.Body))}}
+		case resp.ContentType != "":
+			r["content"] = map[string]any{contentType: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}}
+		}
+		responses[statusKey(status)] = r
+	}
+	if len(responses) == 0 {
+		responses["200"] = map[string]any{"description": "OK"}
+	}
+	out["responses"] = responses
+
+	if op.Security {
+		out["security"] = []any{map[string]any{"adminToken": []string{}}}
+	}
+	return out
+}
+
+// schema returns the schema for t, registering named structs as components
+func (d *Document) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name := t.Name()
+		if _, ok := d.components[name]; !ok {
+			d.components[name] = nil // Reserve the name so recursive types terminate
+			d.components[name] = d.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return d.structSchema(t)
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": d.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": d.schema(t.Elem())}
+	default:
+		// interface{} and anything else accept any JSON value
+		return map[string]any{}
+	}
+}
+
+// structSchema describes the JSON encoding of a struct: fields without
+// omitempty are required, embedded structs are flattened
+func (d *Document) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := d.structSchema(field.Type)
+			for k, v := range embedded["properties"].(map[string]any) {
+				properties[k] = v
+			}
+			if req, ok := embedded["required"].([]string); ok {
+				required = append(required, req...)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = d.schema(field.Type)
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+
+	out := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		out["required"] = required
+	}
+	return out
+}
+
+// statusKey formats an HTTP status as an OpenAPI response key
+func statusKey(status int) string {
+	if status == 0 {
+		return "default"
+	}
+	return strconv.Itoa(status)
+}
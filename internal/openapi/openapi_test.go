@@ -0,0 +1,73 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type testItem struct {
+	Name string `json:"name"`
+}
+
+type testRequest struct {
+	URL     string           `json:"url"`
+	Tags    []string         `json:"tags,omitempty"`
+	Level   *float64         `json:"level,omitempty"`
+	Items   []testItem       `json:"items"`
+	Extra   map[string]int64 `json:"extra,omitempty"`
+	Ignored string           `json:"-"`
+	hidden  string
+}
+
+func TestDocumentAdd(t *testing.T) {
+	doc := New("Test", "1.0.0")
+	doc.Add("POST", "/api/things/:id", Operation{
+		Summary: "Create",
+		Request: testRequest{},
+		Responses: map[int]Response{
+			200: {Description: "OK", Body: testItem{}},
+			404: {Description: "Missing", ContentType: "text/plain"},
+		},
+	})
+
+	// Round-trip through JSON the way clients see it
+	raw, err := json.Marshal(doc.JSON())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var spec map[string]any
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	op := spec["paths"].(map[string]any)["/api/things/{id}"].(map[string]any)["post"].(map[string]any)
+	param := op["parameters"].([]any)[0].(map[string]any)
+	if param["name"] != "id" || param["in"] != "path" {
+		t.Errorf("path parameter = %v", param)
+	}
+	if _, ok := op["responses"].(map[string]any)["404"]; !ok {
+		t.Errorf("404 response missing: %v", op["responses"])
+	}
+
+	schemas := spec["components"].(map[string]any)["schemas"].(map[string]any)
+	req := schemas["testRequest"].(map[string]any)
+	props := req["properties"].(map[string]any)
+	wantProps := []string{"extra", "items", "level", "tags", "url"}
+	var gotProps []string
+	for name := range props {
+		gotProps = append(gotProps, name)
+	}
+	if len(gotProps) != len(wantProps) {
+		t.Errorf("properties = %v, want %v", gotProps, wantProps)
+	}
+	if got := req["required"]; !reflect.DeepEqual(got, []any{"items", "url"}) {
+		t.Errorf("required = %v, want [items url]", got)
+	}
+	if ref := props["items"].(map[string]any)["items"].(map[string]any)["$ref"]; ref != "#/components/schemas/testItem" {
+		t.Errorf("items $ref = %v", ref)
+	}
+	if f := props["extra"].(map[string]any)["additionalProperties"].(map[string]any)["format"]; f != "int64" {
+		t.Errorf("map value format = %v, want int64", f)
+	}
+}
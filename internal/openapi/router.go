@@ -0,0 +1,47 @@
+package openapi
+
+import (
+	"github.com/gofiber/fiber/v3"
+)
+
+// Router registers fiber routes and documents them in one step, so the
+// served spec cannot drift from the routes that actually exist
+type Router struct {
+	router fiber.Router
+	prefix string
+	doc    *Document
+}
+
+// NewRouter wraps router; prefix is the path router is mounted at (e.g. "/api")
+func NewRouter(router fiber.Router, prefix string, doc *Document) *Router {
+	return &Router{router: router, prefix: prefix, doc: doc}
+}
+
+// Get registers a documented GET route
+func (r *Router) Get(path string, op Operation, handler fiber.Handler) {
+	r.router.Get(path, handler)
+	r.doc.Add(fiber.MethodGet, r.prefix+path, op)
+}
+
+// Post registers a documented POST route
+func (r *Router) Post(path string, op Operation, handler fiber.Handler) {
+	r.router.Post(path, handler)
+	r.doc.Add(fiber.MethodPost, r.prefix+path, op)
+}
+
+// Put registers a documented PUT route
+func (r *Router) Put(path string, op Operation, handler fiber.Handler) {
+	r.router.Put(path, handler)
+	r.doc.Add(fiber.MethodPut, r.prefix+path, op)
+}
+
+// Delete registers a documented DELETE route
+func (r *Router) Delete(path string, op Operation, handler fiber.Handler) {
+	r.router.Delete(path, handler)
+	r.doc.Add(fiber.MethodDelete, r.prefix+path, op)
+}
+
+// Handler serves the document as JSON
+func (d *Document) Handler(c fiber.Ctx) error {
+	return c.JSON(d.JSON())
+}
@@ -0,0 +1,76 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// MemoryGate admits jobs while their estimated memory fits a fixed budget
+// Jobs that don't fit wait until running jobs release their reservation
+type MemoryGate struct {
+	mu      sync.Mutex
+	budget  int64
+	inUse   int64
+	waiting int32
+	changed chan struct{} // Closed and replaced on every release
+}
+
+// NewMemoryGate creates a gate with budget bytes
+func NewMemoryGate(budget int64) *MemoryGate {
+	return &MemoryGate{
+		budget:  budget,
+		changed: make(chan struct{}),
+	}
+}
+
+// Acquire reserves n bytes, blocking until they fit or ctx is done
+// A job larger than the whole budget is admitted alone so it can't starve
+func (g *MemoryGate) Acquire(ctx context.Context, n int64) error {
+	atomic.AddInt32(&g.waiting, 1)
+	defer atomic.AddInt32(&g.waiting, -1)
+
+	for {
+		g.mu.Lock()
+		if g.inUse+n <= g.budget || g.inUse == 0 {
+			g.inUse += n
+			g.mu.Unlock()
+			return nil
+		}
+		changed := g.changed
+		g.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release returns n bytes reserved by Acquire
+func (g *MemoryGate) Release(n int64) {
+	g.mu.Lock()
+	g.inUse -= n
+	close(g.changed)
+	g.changed = make(chan struct{})
+	g.mu.Unlock()
+}
+
+// MemoryGateStats reports current reservations
+type MemoryGateStats struct {
+	BudgetBytes int64 `json:"budget_bytes"`
+	InUseBytes  int64 `json:"in_use_bytes"`
+	Waiting     int32 `json:"waiting"`
+}
+
+// GetStats returns current statistics
+func (g *MemoryGate) GetStats() MemoryGateStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return MemoryGateStats{
+		BudgetBytes: g.budget,
+		InUseBytes:  g.inUse,
+		Waiting:     atomic.LoadInt32(&g.waiting),
+	}
+}
@@ -0,0 +1,49 @@
+package pool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryGateDefersOverBudget(t *testing.T) {
+	g := NewMemoryGate(100)
+	ctx := context.Background()
+
+	if err := g.Acquire(ctx, 60); err != nil {
+		t.Fatal(err)
+	}
+
+	admitted := make(chan struct{})
+	go func() {
+		if err := g.Acquire(ctx, 60); err == nil {
+			close(admitted)
+		}
+	}()
+
+	select {
+	case <-admitted:
+		t.Fatal("second job admitted over budget")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	g.Release(60)
+	select {
+	case <-admitted:
+	case <-time.After(time.Second):
+		t.Fatal("second job not admitted after release")
+	}
+}
+
+func TestMemoryGateOversizedJobRunsAlone(t *testing.T) {
+	g := NewMemoryGate(100)
+	if err := g.Acquire(context.Background(), 500); err != nil {
+		t.Fatalf("oversized job on idle gate: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := g.Acquire(ctx, 1); err == nil {
+		t.Fatal("expected timeout while oversized job holds the gate")
+	}
+}
@@ -0,0 +1,54 @@
+package pool
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Semaphore bounds how many operations of one kind run at once
+type Semaphore struct {
+	slots   chan struct{}
+	waiting int32
+}
+
+// NewSemaphore creates a semaphore with size slots
+func NewSemaphore(size int) *Semaphore {
+	if size <= 0 {
+		size = 1
+	}
+	return &Semaphore{slots: make(chan struct{}, size)}
+}
+
+// Acquire blocks until a slot is free or ctx is done
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	atomic.AddInt32(&s.waiting, 1)
+	defer atomic.AddInt32(&s.waiting, -1)
+
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot taken by Acquire
+func (s *Semaphore) Release() {
+	<-s.slots
+}
+
+// SemaphoreStats reports current semaphore usage
+type SemaphoreStats struct {
+	Size    int   `json:"size"`
+	InUse   int   `json:"in_use"`
+	Waiting int32 `json:"waiting"`
+}
+
+// GetStats returns current statistics
+func (s *Semaphore) GetStats() SemaphoreStats {
+	return SemaphoreStats{
+		Size:    cap(s.slots),
+		InUse:   len(s.slots),
+		Waiting: atomic.LoadInt32(&s.waiting),
+	}
+}
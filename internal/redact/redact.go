@@ -0,0 +1,84 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// hashLength is the number of hex characters kept from each HMAC
+const hashLength = 12
+
+// salt is nil while redaction is disabled
+var salt atomic.Pointer[[]byte]
+
+// Enable turns on PII-safe logging: URLs and file paths passed through this
+// package are replaced with salted hashes. An empty salt picks a random one,
+// which keeps hashes correlatable only within a single process lifetime
+func Enable(s string) {
+	key := []byte(s)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	salt.Store(&key)
+}
+
+// Disable turns redaction off (mainly for tests)
+func Disable() {
+	salt.Store(nil)
+}
+
+// Enabled reports whether log values are being hashed
+func Enabled() bool {
+	return salt.Load() != nil
+}
+
+// URL returns a stable hash of a source URL, or the URL itself when disabled
+func URL(raw string) string {
+	if !Enabled() || raw == "" {
+		return raw
+	}
+	return "url:" + hash(raw)
+}
+
+// Path returns a stable hash of a file path, keeping the extension so logs
+// still show the media kind, or the path itself when disabled
+func Path(p string) string {
+	if !Enabled() || p == "" {
+		return p
+	}
+	return "file:" + hash(p) + filepath.Ext(p)
+}
+
+// Error renders err with any URLs it carries (net/http wraps them in *url.Error) hashed
+func Error(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+	msg := err.Error()
+	if !Enabled() {
+		return msg
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.URL != "" {
+		msg = strings.ReplaceAll(msg, urlErr.URL, URL(urlErr.URL))
+	}
+	return msg
+}
+
+func hash(s string) string {
+	key := salt.Load()
+	if key == nil {
+		return s
+	}
+	mac := hmac.New(sha256.New, *key)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))[:hashLength]
+}
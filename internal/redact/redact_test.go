@@ -0,0 +1,36 @@
+package redact
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	const src = "https://bucket.example.com/customer/photo.jpg?X-Amz-Signature=abc"
+	t.Cleanup(Disable)
+
+	if got := URL(src); got != src {
+		t.Fatalf("disabled URL() = %q, want unchanged", got)
+	}
+
+	Enable("salt-a")
+	a := URL(src)
+	if a == src || !strings.HasPrefix(a, "url:") || a != URL(src) {
+		t.Fatalf("URL() = %q, want stable hash", a)
+	}
+	if p := Path("/tmp/media-cache/temp/abc.mp4"); !strings.HasPrefix(p, "file:") || !strings.HasSuffix(p, ".mp4") {
+		t.Errorf("Path() = %q", p)
+	}
+
+	err := fmt.Errorf("download failed: %w", &url.Error{Op: "Get", URL: src, Err: fmt.Errorf("timeout")})
+	if msg := Error(err); strings.Contains(msg, "bucket.example.com") || !strings.Contains(msg, a) {
+		t.Errorf("Error() = %q, want URL replaced by %q", msg, a)
+	}
+
+	Enable("salt-b")
+	if URL(src) == a {
+		t.Error("different salts produced the same hash")
+	}
+}
@@ -0,0 +1,23 @@
+package services
+
+// jobMemoryMultipliers approximate peak memory per input byte for each pipeline:
+// images are decoded to raw frames, audio/video stream through ffmpeg but keep
+// the input, stdout buffer and encoder state resident
+var jobMemoryMultipliers = map[string]int64{
+	"image":    10,
+	"audio":    4,
+	"video":    3,
+	"document": 4,
+}
+
+// jobMemoryBase covers ffmpeg process overhead independent of input size
+const jobMemoryBase = 64 * 1024 * 1024
+
+// EstimateJobMemory returns the expected peak memory of processing inputSize bytes
+func EstimateJobMemory(mediaType string, inputSize int) int64 {
+	multiplier, ok := jobMemoryMultipliers[mediaType]
+	if !ok {
+		multiplier = 4
+	}
+	return int64(inputSize)*multiplier + jobMemoryBase
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Archive formats recognized by SniffArchive
+const (
+	ArchiveZip   = "zip"
+	ArchiveTar   = "tar"
+	ArchiveTarGz = "tar.gz"
+)
+
+// ErrArchiveLimit is wrapped by extraction errors caused by ArchiveLimits
+var ErrArchiveLimit = errors.New("archive exceeds limits")
+
+// ArchiveLimits bound what ExtractArchive unpacks, so a small download
+// cannot expand into unbounded memory (zip bombs)
+type ArchiveLimits struct {
+	MaxEntries    int   // Media entries per archive (0 disables archive input)
+	MaxEntryBytes int64 // Uncompressed size of one entry
+	MaxTotalBytes int64 // Uncompressed size of all entries together
+}
+
+// ArchiveEntry is one regular file unpacked from an archive
+type ArchiveEntry struct {
+	Name string // Cleaned relative path inside the archive
+	Data []byte
+}
+
+// SniffArchive returns the archive format of data, or "" for anything else
+// A gzip stream counts only when it holds a tar archive
+func SniffArchive(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")), bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		return ArchiveZip
+	case isTar(data):
+		return ArchiveTar
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return ""
+		}
+		header := make([]byte, 512)
+		if n, _ := io.ReadFull(zr, header); isTar(header[:n]) {
+			return ArchiveTarGz
+		}
+	}
+	return ""
+}
+
+// isTar reports whether data starts with a POSIX or GNU tar header
+func isTar(data []byte) bool {
+	return len(data) >= 262 && bytes.Equal(data[257:262], []byte("ustar"))
+}
+
+// ExtractArchive unpacks the regular files of a zip or tar archive in memory
+// Directories, links and hidden or resource-fork entries (__MACOSX, ._*) are
+// skipped; entries whose path escapes the archive root are an error
+func ExtractArchive(data []byte, format string, limits ArchiveLimits) ([]ArchiveEntry, error) {
+	u := &unpacker{limits: limits}
+	var err error
+	switch format {
+	case ArchiveZip:
+		err = u.zip(data)
+	case ArchiveTar:
+		err = u.tar(bytes.NewReader(data))
+	case ArchiveTarGz:
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("invalid gzip stream: %w", err)
+		}
+		defer zr.Close()
+		err = u.tar(zr)
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u.entries, nil
+}
+
+// unpacker collects entries while enforcing the limits across them
+type unpacker struct {
+	limits  ArchiveLimits
+	entries []ArchiveEntry
+	total   int64
+}
+
+func (u *unpacker) zip(data []byte) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+		err = u.add(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *unpacker) tar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := u.add(header.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// add reads one regular file, reading at most one byte past the limits
+func (u *unpacker) add(name string, r io.Reader) error {
+	clean, ok := cleanEntryName(name)
+	if !ok {
+		return fmt.Errorf("entry %q escapes the archive root", name)
+	}
+	if skippedEntry(clean) {
+		return nil
+	}
+	if len(u.entries) >= u.limits.MaxEntries {
+		return fmt.Errorf("%w: more than %d entries", ErrArchiveLimit, u.limits.MaxEntries)
+	}
+
+	limit := min(u.limits.MaxEntryBytes, u.limits.MaxTotalBytes-u.total)
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return fmt.Errorf("%s: %w", clean, err)
+	}
+	if int64(len(data)) > limit {
+		return fmt.Errorf("%w: %s is larger than %d bytes uncompressed", ErrArchiveLimit, clean, limit)
+	}
+	u.total += int64(len(data))
+	u.entries = append(u.entries, ArchiveEntry{Name: clean, Data: data})
+	return nil
+}
+
+// cleanEntryName normalizes an entry path, rejecting absolute paths and any
+// that leave the archive root (zip slip)
+func cleanEntryName(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+		return "", false
+	}
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") || clean == "." {
+		return "", false
+	}
+	return clean, true
+}
+
+// skippedEntry reports entries that are archiver metadata rather than content
+func skippedEntry(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if part == "__MACOSX" || strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var testArchiveLimits = ArchiveLimits{MaxEntries: 10, MaxEntryBytes: 1 << 10, MaxTotalBytes: 4 << 10}
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTar(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestSniffArchive(t *testing.T) {
+	files := map[string]string{"a.png": "png"}
+	tarData := buildTar(t, files)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"zip", buildZip(t, files), ArchiveZip},
+		{"tar", tarData, ArchiveTar},
+		{"tar.gz", gzipBytes(tarData), ArchiveTarGz},
+		{"plain gzip", gzipBytes([]byte("not a tar")), ""},
+		{"png", []byte("\x89PNG\r\n\x1a\n"), ""},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := SniffArchive(tt.data); got != tt.want {
+			t.Errorf("%s: SniffArchive = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	files := map[string]string{
+		"photos/a.png":       "png",
+		"b.mp3":              "mp3",
+		"__MACOSX/._a.png":   "fork",
+		"photos/.DS_Store":   "meta",
+		"photos/./../c.opus": "opus",
+	}
+	tarData := buildTar(t, files)
+
+	for format, data := range map[string][]byte{
+		ArchiveZip:   buildZip(t, files),
+		ArchiveTar:   tarData,
+		ArchiveTarGz: gzipBytes(tarData),
+	} {
+		entries, err := ExtractArchive(data, format, testArchiveLimits)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		got := make(map[string]string)
+		for _, e := range entries {
+			got[e.Name] = string(e.Data)
+		}
+		want := map[string]string{"photos/a.png": "png", "b.mp3": "mp3", "c.opus": "opus"}
+		if len(got) != len(want) {
+			t.Fatalf("%s: entries = %v, want %v", format, got, want)
+		}
+		for name, content := range want {
+			if got[name] != content {
+				t.Errorf("%s: %s = %q, want %q", format, name, got[name], content)
+			}
+		}
+	}
+}
+
+func TestExtractArchiveRejectsEscapes(t *testing.T) {
+	for _, name := range []string{"../evil.png", "a/../../evil.png", "/etc/evil.png", "C:/evil.png", "..\\evil.png"} {
+		data := buildZip(t, map[string]string{name: "x"})
+		if _, err := ExtractArchive(data, ArchiveZip, testArchiveLimits); err == nil || !strings.Contains(err.Error(), "escapes") {
+			t.Errorf("%s: err = %v, want escape error", name, err)
+		}
+	}
+}
+
+func TestExtractArchiveLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"entry too large", map[string]string{"big.png": strings.Repeat("x", 1<<10+1)}},
+		{"total too large", map[string]string{
+			"1.png": strings.Repeat("x", 1<<10), "2.png": strings.Repeat("x", 1<<10),
+			"3.png": strings.Repeat("x", 1<<10), "4.png": strings.Repeat("x", 1<<10),
+			"5.png": "x",
+		}},
+		{"too many entries", func() map[string]string {
+			files := make(map[string]string)
+			for _, c := range "abcdefghijk" {
+				files[string(c)+".png"] = "x"
+			}
+			return files
+		}()},
+	}
+	for _, tt := range tests {
+		_, err := ExtractArchive(buildZip(t, tt.files), ArchiveZip, testArchiveLimits)
+		if !errors.Is(err, ErrArchiveLimit) {
+			t.Errorf("%s: err = %v, want ErrArchiveLimit", tt.name, err)
+		}
+	}
+}
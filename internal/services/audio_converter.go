@@ -1,16 +1,12 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	mathrand "math/rand"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"fingerprint-converter/internal/pool"
@@ -18,24 +14,64 @@ import (
 
 // AudioConverter handles audio conversion with anti-fingerprinting
 type AudioConverter struct {
-	workerPool *pool.WorkerPool
-	bufferPool *pool.BufferPool
-	mu         sync.RWMutex
-	stats      AudioStats
+	baseConverter
 }
 
 // AudioStats tracks conversion metrics
-type AudioStats struct {
-	TotalConversions  int64
-	FailedConversions int64
-	AvgConversionTime time.Duration
-}
+type AudioStats = ConverterStats
 
 // NewAudioConverter creates a new audio converter
 func NewAudioConverter(workerPool *pool.WorkerPool, bufferPool *pool.BufferPool) *AudioConverter {
 	return &AudioConverter{
-		workerPool: workerPool,
-		bufferPool: bufferPool,
+		baseConverter: baseConverter{
+			mediaType:  "audio",
+			outputExt:  ".opus",
+			workerPool: workerPool,
+			bufferPool: bufferPool,
+		},
+	}
+}
+
+// Process implements Converter using the script techniques, or the
+// anti-fingerprint level of opts when set
+func (ac *AudioConverter) Process(ctx context.Context, inputData []byte, outputPath, inputFormat string, opts ProcessOptions) (*ProcessResult, error) {
+	result := &ProcessResult{}
+	convert := func(opts ProcessOptions) (string, error) {
+		return outputPath, ac.ConvertWithScriptTechniques(ctx, inputData, outputPath, inputFormat, opts)
+	}
+	if opts.Level != "" {
+		// The level pipeline always encodes Opus
+		if ext := filepath.Ext(outputPath); ext != ac.outputExt {
+			outputPath = strings.TrimSuffix(outputPath, ext) + ac.outputExt
+			result.OutputPath = outputPath
+		}
+		convert = func(ProcessOptions) (string, error) {
+			return outputPath, ac.Convert(ctx, inputData, opts.Level, outputPath)
+		}
+	}
+
+	start := time.Now()
+	err := ac.verified(ctx, opts, convert)
+	if err == nil {
+		err = ac.checkDuration(ctx, inputData, outputPath, opts)
+	}
+	ac.observe(inputFormat, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// OutputFormat returns the format written for inputFormat
+// Raw AAC is muxed into M4A, unknown formats become Opus
+func (ac *AudioConverter) OutputFormat(inputFormat string) string {
+	switch strings.ToLower(inputFormat) {
+	case "mp3", "opus", "m4a", "ogg", "wav":
+		return strings.ToLower(inputFormat)
+	case "aac":
+		return "m4a"
+	default:
+		return "opus"
 	}
 }
 
@@ -52,12 +88,11 @@ func (ac *AudioConverter) Convert(ctx context.Context, inputData []byte, level s
 	params := ac.getRandomizedParams(level)
 
 	// Build FFmpeg command with anti-fingerprinting
-	cmd := exec.CommandContext(ctx, "ffmpeg",
+	cmd := toolCommand(ctx, "ffmpeg",
 		"-hide_banner",
 		"-loglevel", "error",
 		"-i", "pipe:0", // Input from stdin
-		"-vn",           // No video
-		"-map", "0:a:0", // First audio stream
+		"-vn",          // No video
 		"-c:a", "libopus",
 		"-b:a", params.bitrate,
 		"-vbr", "on",
@@ -68,26 +103,41 @@ func (ac *AudioConverter) Convert(ctx context.Context, inputData []byte, level s
 	)
 
 	// Add anti-fingerprint filters
-	filters := []string{}
+	graph := NewFilterGraph()
 
 	// Add silence padding (basic, moderate, paranoid)
 	if params.silencePadding > 0 {
-		filters = append(filters, fmt.Sprintf("adelay=%d:all=1", params.silencePadding))
+		graph.Add(NewFilter("adelay").Arg(params.silencePadding).Set("all", 1))
 	}
 
 	// Add pitch shift (moderate, paranoid)
 	if params.pitchShift != 0 {
-		filters = append(filters, fmt.Sprintf("asetrate=48000*%.6f,aresample=48000", params.pitchShift))
+		graph.Add(
+			NewFilter("asetrate").Setf("r", "48000*%.6f", params.pitchShift),
+			NewFilter("aresample").Arg(48000),
+		)
 	}
 
-	// Add subtle noise (paranoid only)
-	if params.addNoise {
-		filters = append(filters, fmt.Sprintf("anoisesrc=d=%d:c=pink:r=48000:a=0.001,amix=inputs=2:weights=1 %.6f",
-			len(inputData)/1000, params.noiseLevel))
+	filter := "anull"
+	if !graph.Empty() {
+		var err error
+		if filter, err = graph.Build(); err != nil {
+			ac.recordFailure()
+			return fmt.Errorf("invalid audio filter: %w", err)
+		}
 	}
 
-	if len(filters) > 0 {
-		cmd.Args = append(cmd.Args, "-af", strings.Join(filters, ","))
+	// Add subtle noise (paranoid only) - the noise source is a second input,
+	// so the mix needs a complex graph; it ends with the first audio stream
+	if params.addNoise {
+		noise := NewFilter("anoisesrc").Set("c", "pink").Set("r", 48000).Set("a", 0.001)
+		mix := NewFilter("amix").Set("inputs", 2).Set("duration", "first").Setf("weights", "1 %.6f", params.noiseLevel)
+		cmd.Args = append(cmd.Args,
+			"-filter_complex", fmt.Sprintf("[0:a:0]%s[main];%s[noise];[main][noise]%s[out]", filter, noise, mix),
+			"-map", "[out]",
+		)
+	} else {
+		cmd.Args = append(cmd.Args, "-map", "0:a:0", "-af", filter) // First audio stream
 	}
 
 	// Output settings
@@ -97,29 +147,15 @@ func (ac *AudioConverter) Convert(ctx context.Context, inputData []byte, level s
 		"pipe:1", // Output to stdout
 	)
 
-	// Set up pipes
-	cmd.Stdin = bytes.NewReader(inputData)
-	var outputBuffer bytes.Buffer
-	var errorBuffer bytes.Buffer
-	cmd.Stdout = &outputBuffer
-	cmd.Stderr = &errorBuffer
-
 	// Execute conversion
-	if err := cmd.Run(); err != nil {
-		ac.recordFailure()
-		return fmt.Errorf("ffmpeg error: %v, stderr: %s", err, errorBuffer.String())
-	}
-
-	output := outputBuffer.Bytes()
-	if len(output) == 0 {
-		ac.recordFailure()
-		return fmt.Errorf("ffmpeg produced no output")
+	output, err := ac.runFFmpeg(ctx, cmd, inputData)
+	if err != nil {
+		return err
 	}
 
 	// Write to file
-	if err := os.WriteFile(outputPath, output, 0644); err != nil {
-		ac.recordFailure()
-		return fmt.Errorf("failed to write output file: %w", err)
+	if err := ac.writeOutput(outputPath, output); err != nil {
+		return err
 	}
 
 	ac.recordSuccess(time.Since(start))
@@ -127,7 +163,7 @@ func (ac *AudioConverter) Convert(ctx context.Context, inputData []byte, level s
 }
 
 // ConvertWithScriptTechniques processes audio using micro-variation volume + delay while maintaining original format
-func (ac *AudioConverter) ConvertWithScriptTechniques(ctx context.Context, inputData []byte, outputPath string, inputFormat string) error {
+func (ac *AudioConverter) ConvertWithScriptTechniques(ctx context.Context, inputData []byte, outputPath string, inputFormat string, opts ProcessOptions) error {
 	start := time.Now()
 
 	if len(inputData) == 0 {
@@ -159,7 +195,43 @@ func (ac *AudioConverter) ConvertWithScriptTechniques(ctx context.Context, input
 	volume += float64(nonce.Timestamp%100) / 100000.0 // ±0.00099 additional variation
 
 	// Combined filter: resample + delay + volume
-	filter := fmt.Sprintf("aresample=48000,adelay=%d:all=1,volume=%.4f", delayMs, volume)
+	graph := NewFilterGraph(NewFilter("aresample").Arg(48000))
+
+	// Duration-neutral delay: drop as much audio from the head as adelay adds
+	if opts.PreserveDuration {
+		graph.Add(delayCompensationFilter(delayMs)...)
+	}
+
+	graph.Add(
+		NewFilter("adelay").Arg(delayMs).Set("all", 1),
+		NewFilter("volume").Setf("volume", "%.4f", volume),
+	)
+
+	// Optional silence trimming runs first so the delay is applied to the trimmed audio
+	if opts.TrimSilence {
+		graph.Prepend(opts.silenceTrimFilter()...)
+	}
+
+	// Optional speed change
+	if opts.hasSpeedChange() {
+		graph.Add(opts.atempoFilter())
+	}
+
+	// 3. Micro time-stretch (profile) - shifts the waveform fingerprint more than delay+volume
+	if opts.Profile.AudioTimeStretch && !opts.PreserveDuration {
+		graph.Add(NewFilter("atempo").Setf("tempo", "%.6f", timeStretchFactor(localRand, opts.Profile.strength())))
+	}
+
+	// 4. Phase/EQ micro-perturbation (profile)
+	if opts.Profile.AudioPhaseEQ {
+		graph.Add(phaseEQFilter(localRand, opts.Profile.strength())...)
+	}
+
+	filter, err := graph.Build()
+	if err != nil {
+		ac.recordFailure()
+		return fmt.Errorf("invalid audio filter: %w", err)
+	}
 
 	var codec string
 	var format string
@@ -192,7 +264,7 @@ func (ac *AudioConverter) ConvertWithScriptTechniques(ctx context.Context, input
 		extraArgs = []string{"-vbr", "on"}
 	}
 
-	cmd := exec.CommandContext(ctx, "ffmpeg",
+	cmd := toolCommand(ctx, "ffmpeg",
 		"-hide_banner",
 		"-loglevel", "error",
 		"-i", "pipe:0",
@@ -204,35 +276,49 @@ func (ac *AudioConverter) ConvertWithScriptTechniques(ctx context.Context, input
 
 	cmd.Args = append(cmd.Args, extraArgs...)
 
+	// Remove original metadata and set title
+	cmd.Args = append(cmd.Args, "-map_metadata", "-1")
+	cmd.Args = append(cmd.Args, outputMetadataArgs("title", uniqueTitle, opts)...)
+
+	var identity encoderIdentity
+	if ac.randomizeEncoderTags {
+		identity = pickIdentity(audioIdentities[format], localRand)
+		if !opts.NoMarker {
+			cmd.Args = append(cmd.Args, identity.args(false, true)...)
+		}
+	}
+
+	created, dated := ac.creationTime(localRand)
+	if dated && !opts.NoMarker {
+		cmd.Args = append(cmd.Args, creationTimeArgs(format, created)...)
+	}
+
 	cmd.Args = append(cmd.Args,
-		// Remove original metadata and set title
-		"-map_metadata", "-1",
-		"-metadata", "title="+uniqueTitle,
 		"-f", format,
 		"-threads", "0",
 		"pipe:1",
 	)
 
-	cmd.Stdin = bytes.NewReader(inputData)
-	var outputBuffer bytes.Buffer
-	var errorBuffer bytes.Buffer
-	cmd.Stdout = &outputBuffer
-	cmd.Stderr = &errorBuffer
-
-	if err := cmd.Run(); err != nil {
-		ac.recordFailure()
-		return fmt.Errorf("ffmpeg error: %v, stderr: %s", err, errorBuffer.String())
+	output, err := ac.runFFmpegTo(ctx, cmd, inputData, opts.Stream)
+	if err != nil {
+		return err
 	}
 
-	output := outputBuffer.Bytes()
-	if len(output) == 0 {
-		ac.recordFailure()
-		return fmt.Errorf("ffmpeg produced no output")
+	// Ogg comment headers carry ffmpeg's vendor string whatever the options;
+	// a streamed output has already left
+	if identity.Vendor != "" && opts.Stream == nil {
+		encoder := identity.AudioEncoder
+		if opts.NoMarker {
+			encoder = ""
+		}
+		output, _ = rewriteOggComments(output, identity.Vendor, encoder)
 	}
 
-	if err := os.WriteFile(outputPath, output, 0644); err != nil {
-		ac.recordFailure()
-		return fmt.Errorf("failed to write output file: %w", err)
+	if err := ac.writeOutput(outputPath, output); err != nil {
+		return err
+	}
+	if dated {
+		stampFile(outputPath, created)
 	}
 
 	ac.recordSuccess(time.Since(start))
@@ -284,36 +370,3 @@ func (ac *AudioConverter) getRandomizedParams(level string) audioParams {
 
 	return params
 }
-
-func (ac *AudioConverter) recordSuccess(duration time.Duration) {
-	ac.mu.Lock()
-	defer ac.mu.Unlock()
-	ac.stats.TotalConversions++
-	// Update average (simple moving average)
-	ac.stats.AvgConversionTime = (ac.stats.AvgConversionTime*time.Duration(ac.stats.TotalConversions-1) + duration) / time.Duration(ac.stats.TotalConversions)
-}
-
-func (ac *AudioConverter) recordFailure() {
-	ac.mu.Lock()
-	defer ac.mu.Unlock()
-	ac.stats.FailedConversions++
-}
-
-// GetStats returns current statistics
-func (ac *AudioConverter) GetStats() AudioStats {
-	ac.mu.RLock()
-	defer ac.mu.RUnlock()
-	return ac.stats
-}
-
-// GetOutputExtension returns the file extension for this converter
-func (ac *AudioConverter) GetOutputExtension() string {
-	return ".opus"
-}
-
-// GenerateOutputPath creates a unique output path
-func (ac *AudioConverter) GenerateOutputPath(cacheDir, deviceID, urlHash string) string {
-	timestamp := time.Now().UnixNano()
-	filename := fmt.Sprintf("%s_%s_%d%s", deviceID, urlHash[:8], timestamp, ac.GetOutputExtension())
-	return filepath.Join(cacheDir, filename)
-}
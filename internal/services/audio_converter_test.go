@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertAudioLevels(t *testing.T) {
+	requireFFmpeg(t)
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		t.Skip("ffprobe not available, skipping")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.mp3")
+	if out, err := exec.Command("ffmpeg", "-hide_banner", "-loglevel", "error",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=1", src).CombinedOutput(); err != nil {
+		t.Fatalf("generate fixture: %v: %s", err, out)
+	}
+	input, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ac := NewAudioConverter(nil, nil)
+	for _, level := range AFLevels {
+		out := filepath.Join(dir, level+".opus")
+		if _, err := ac.Process(context.Background(), input, out, "mp3", ProcessOptions{Level: level}); err != nil {
+			t.Errorf("%s: %v", level, err)
+			continue
+		}
+		if err := verifyPlayable(context.Background(), out); err != nil {
+			t.Errorf("%s: output not playable: %v", level, err)
+		}
+		// The noise mixed in at paranoid must not outlast the input
+		if info, err := ProbeMedia(context.Background(), out); err != nil || math.Abs(info.DurationSeconds-1) > 0.2 {
+			t.Errorf("%s: output duration = %v, %v", level, info.DurationSeconds, err)
+		}
+	}
+}
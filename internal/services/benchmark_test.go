@@ -0,0 +1,186 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// Benchmarks for the script-technique pipelines. Run with:
+//
+//	go test -run '^$' -bench . -benchmem ./internal/services/
+//
+// Fixtures are synthetic so results are comparable between machines and runs.
+
+var benchImageSizes = []struct {
+	name string
+	w, h int
+}{
+	{"small_320x240", 320, 240},
+	{"medium_1280x720", 1280, 720},
+	{"large_1920x1080", 1920, 1080},
+}
+
+var benchAudioDurations = []float64{1, 5, 30} // seconds
+
+var benchVideoSizes = []struct {
+	name     string
+	size     string
+	duration string
+}{
+	{"small_320x240_2s", "320x240", "2"},
+	{"medium_1280x720_5s", "1280x720", "5"},
+}
+
+func requireFFmpeg(b testing.TB) {
+	b.Helper()
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		b.Skip("ffmpeg not available, skipping")
+	}
+}
+
+// makeBenchJPEG renders a colorful pattern so the encoder has real work to do
+func makeBenchJPEG(b *testing.B, w, h int) []byte {
+	b.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{
+				R: uint8(128 + 100*math.Sin(float64(x)/17)),
+				G: uint8(128 + 100*math.Cos(float64(y)/23)),
+				B: uint8((x ^ y) & 0xFF),
+				A: 255,
+			})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		b.Fatalf("jpeg encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// makeSineWAV builds a mono 16-bit PCM WAV with a 440Hz tone
+func makeSineWAV(seconds float64, sampleRate int) []byte {
+	ns := int(float64(sampleRate) * seconds)
+	buf := make([]byte, 44+ns*2)
+	copy(buf[0:], []byte("RIFF"))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(36+ns*2))
+	copy(buf[8:], []byte("WAVEfmt "))
+	binary.LittleEndian.PutUint32(buf[16:], 16)
+	binary.LittleEndian.PutUint16(buf[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(buf[22:], 1) // mono
+	binary.LittleEndian.PutUint32(buf[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(buf[28:], uint32(sampleRate*2))
+	binary.LittleEndian.PutUint16(buf[32:], 2)
+	binary.LittleEndian.PutUint16(buf[34:], 16)
+	copy(buf[36:], []byte("data"))
+	binary.LittleEndian.PutUint32(buf[40:], uint32(ns*2))
+	for i := 0; i < ns; i++ {
+		s := int16(30000 * math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate)))
+		binary.LittleEndian.PutUint16(buf[44+i*2:], uint16(s))
+	}
+	return buf
+}
+
+// makeBenchMP4 generates an H.264/AAC test clip with ffmpeg's lavfi sources
+func makeBenchMP4(b *testing.B, size, duration string) []byte {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "fixture.mp4")
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-loglevel", "error",
+		"-f", "lavfi", "-i", "testsrc=size="+size+":rate=30:duration="+duration,
+		"-f", "lavfi", "-i", "sine=frequency=440:duration="+duration,
+		"-c:v", "libx264", "-preset", "veryfast", "-c:a", "aac", "-shortest",
+		path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		b.Fatalf("generate video fixture: %v: %s", err, out)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		b.Fatalf("read video fixture: %v", err)
+	}
+	return data
+}
+
+func BenchmarkImageConvertWithScriptTechniques(b *testing.B) {
+	requireFFmpeg(b)
+	ic := NewImageConverter(nil, nil, "jpeg")
+
+	for _, size := range benchImageSizes {
+		input := makeBenchJPEG(b, size.w, size.h)
+		b.Run(size.name, func(b *testing.B) {
+			dir := b.TempDir()
+			b.SetBytes(int64(len(input)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// A fresh path each run, ffmpeg does not overwrite without -y
+				out := filepath.Join(dir, fmt.Sprintf("out-%d.jpg", i))
+				if err := ic.ConvertWithScriptTechniques(context.Background(), input, out, ProcessOptions{}); err != nil {
+					b.Fatalf("convert: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAudioConvertWithScriptTechniques(b *testing.B) {
+	requireFFmpeg(b)
+	ac := NewAudioConverter(nil, nil)
+
+	for _, seconds := range benchAudioDurations {
+		input := makeSineWAV(seconds, 48000)
+		b.Run(fmt.Sprintf("wav_%gs", seconds), func(b *testing.B) {
+			dir := b.TempDir()
+			b.SetBytes(int64(len(input)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				out := filepath.Join(dir, fmt.Sprintf("out-%d.opus", i))
+				if err := ac.ConvertWithScriptTechniques(context.Background(), input, out, "opus", ProcessOptions{}); err != nil {
+					b.Fatalf("convert: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkVideoConvertWithScriptTechniques(b *testing.B) {
+	requireFFmpeg(b)
+	vc := NewVideoConverter(nil, nil)
+
+	for _, size := range benchVideoSizes {
+		input := makeBenchMP4(b, size.size, size.duration)
+		b.Run(size.name, func(b *testing.B) {
+			dir := b.TempDir()
+			b.SetBytes(int64(len(input)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				out := filepath.Join(dir, fmt.Sprintf("out-%d.mp4", i))
+				if _, err := vc.ConvertWithScriptTechniques(context.Background(), input, out, ProcessOptions{}); err != nil {
+					b.Fatalf("convert: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPerceptualHash(b *testing.B) {
+	for _, size := range benchImageSizes {
+		input := makeBenchJPEG(b, size.w, size.h)
+		b.Run(size.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := PerceptualHash(input); err != nil {
+					b.Fatalf("hash: %v", err)
+				}
+			}
+		})
+	}
+}
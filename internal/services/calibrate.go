@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// calibrationSpec approximates a typical upload: a 720p still or a 5s 720p clip
+var calibrationSpec = sampleSpec{Size: "1280x720", Rate: 30, Seconds: 5}
+
+// CalibrationEntry is the measured single-worker throughput of one pipeline
+type CalibrationEntry struct {
+	MediaType           string  `json:"media_type"`
+	SampleBytes         int     `json:"sample_bytes"`
+	SampleSeconds       float64 `json:"sample_seconds,omitempty"`
+	Runs                int     `json:"runs"`
+	AvgMs               int64   `json:"avg_ms"`
+	MinMs               int64   `json:"min_ms"`
+	MaxMs               int64   `json:"max_ms"`
+	JobsPerSecond       float64 `json:"jobs_per_second_per_worker"`
+	InputBytesPerSecond float64 `json:"input_bytes_per_second_per_worker"`
+	RealtimeFactor      float64 `json:"realtime_factor,omitempty"` // Media seconds processed per wall second
+	JobsPerMinute       float64 `json:"estimated_jobs_per_minute"` // Across all workers
+	Error               string  `json:"error,omitempty"`
+}
+
+// Calibration is the result of one calibration run on this host
+type Calibration struct {
+	MeasuredAt    time.Time          `json:"measured_at"`
+	DurationMs    int64              `json:"duration_ms"`
+	Host          string             `json:"host"`
+	CPUs          int                `json:"cpus"`
+	Workers       int                `json:"workers"`
+	FFmpegVersion string             `json:"ffmpeg_version"`
+	Results       []CalibrationEntry `json:"results"`
+}
+
+// Calibrate times runs sequential conversions of a typical synthetic input
+// for every registered pipeline. Sequential runs measure one worker; the
+// per-minute estimate scales that by workers
+func Calibrate(ctx context.Context, registry *Registry, runs, workers int) (*Calibration, error) {
+	if runs < 1 {
+		runs = 1
+	}
+	start := time.Now()
+	caps := DetectCapabilities()
+
+	dir, err := os.MkdirTemp("", "calibrate-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create calibration dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	host, _ := os.Hostname()
+	result := &Calibration{
+		MeasuredAt:    start.UTC(),
+		Host:          host,
+		CPUs:          runtime.NumCPU(),
+		Workers:       workers,
+		FFmpegVersion: caps.FFmpegVersion,
+		Results:       []CalibrationEntry{},
+	}
+
+	for _, mediaType := range registry.MediaTypes() {
+		entry := calibrateMediaType(ctx, registry, mediaType, dir, caps, runs, workers)
+		if entry.Error != "" {
+			convertLog.Warnf("⚠️  Calibration %s failed: %s", mediaType, entry.Error)
+		} else {
+			convertLog.Infof("📏 Calibration %s: avg=%dms, %.2f jobs/s per worker", mediaType, entry.AvgMs, entry.JobsPerSecond)
+		}
+		result.Results = append(result.Results, entry)
+	}
+
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+// calibrateMediaType synthesizes one sample and times runs conversions of it
+func calibrateMediaType(ctx context.Context, registry *Registry, mediaType, dir string, caps Capabilities, runs, workers int) CalibrationEntry {
+	entry := CalibrationEntry{MediaType: mediaType}
+	converter, _ := registry.Get(mediaType)
+
+	input, format, err := synthesizeSample(ctx, mediaType, dir, caps, calibrationSpec)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+
+	var seconds float64
+	if mediaType == "audio" || mediaType == "video" {
+		seconds = calibrationSpec.Seconds
+	}
+
+	durations := make([]time.Duration, 0, runs)
+	for i := 0; i < runs; i++ {
+		output := filepath.Join(dir, fmt.Sprintf("out-%s-%d%s", mediaType, i, converter.GetOutputExtension()))
+		runStart := time.Now()
+		if _, err := converter.Process(ctx, input, output, format, ProcessOptions{}); err != nil {
+			entry.Error = err.Error()
+			return entry
+		}
+		durations = append(durations, time.Since(runStart))
+		os.Remove(output)
+	}
+
+	return throughputEntry(mediaType, len(input), seconds, durations, workers)
+}
+
+// throughputEntry derives per-worker and host throughput from run durations
+func throughputEntry(mediaType string, sampleBytes int, sampleSeconds float64, durations []time.Duration, workers int) CalibrationEntry {
+	entry := CalibrationEntry{
+		MediaType:     mediaType,
+		SampleBytes:   sampleBytes,
+		SampleSeconds: sampleSeconds,
+		Runs:          len(durations),
+	}
+	if len(durations) == 0 {
+		entry.Error = "no successful runs"
+		return entry
+	}
+
+	var total time.Duration
+	minimum, maximum := durations[0], durations[0]
+	for _, d := range durations {
+		total += d
+		minimum = min(minimum, d)
+		maximum = max(maximum, d)
+	}
+	avg := total / time.Duration(len(durations))
+	entry.AvgMs = avg.Milliseconds()
+	entry.MinMs = minimum.Milliseconds()
+	entry.MaxMs = maximum.Milliseconds()
+
+	perJob := avg.Seconds()
+	if perJob <= 0 {
+		return entry
+	}
+	entry.JobsPerSecond = 1 / perJob
+	entry.InputBytesPerSecond = float64(sampleBytes) / perJob
+	if sampleSeconds > 0 {
+		entry.RealtimeFactor = sampleSeconds / perJob
+	}
+	entry.JobsPerMinute = entry.JobsPerSecond * float64(max(workers, 1)) * 60
+	return entry
+}
+
+// CalibrationStore keeps the latest calibration and persists it to disk
+type CalibrationStore struct {
+	path   string
+	mu     sync.RWMutex
+	latest *Calibration
+}
+
+// NewCalibrationStore loads the last calibration from path if present
+func NewCalibrationStore(path string) *CalibrationStore {
+	s := &CalibrationStore{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			convertLog.Warnf("⚠️  Failed to read calibration %s: %v", path, err)
+		}
+		return s
+	}
+	var c Calibration
+	if err := json.Unmarshal(data, &c); err != nil {
+		convertLog.Warnf("⚠️  Ignoring invalid calibration %s: %v", path, err)
+		return s
+	}
+	s.latest = &c
+	return s
+}
+
+// Latest returns the most recent calibration, or nil if none was run
+func (s *CalibrationStore) Latest() *Calibration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.latest
+}
+
+// Save records c as the latest calibration and writes it atomically
+func (s *CalibrationStore) Save(c *Calibration) error {
+	s.mu.Lock()
+	s.latest = c
+	s.mu.Unlock()
+
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestThroughputEntry(t *testing.T) {
+	durations := []time.Duration{400 * time.Millisecond, 500 * time.Millisecond, 600 * time.Millisecond}
+	entry := throughputEntry("video", 1_000_000, 5, durations, 4)
+
+	if entry.Runs != 3 || entry.AvgMs != 500 || entry.MinMs != 400 || entry.MaxMs != 600 {
+		t.Errorf("timings = %+v", entry)
+	}
+	for name, got := range map[string][2]float64{
+		"jobs/s":        {entry.JobsPerSecond, 2},
+		"bytes/s":       {entry.InputBytesPerSecond, 2_000_000},
+		"realtime":      {entry.RealtimeFactor, 10},
+		"jobs/min (x4)": {entry.JobsPerMinute, 480},
+	} {
+		if math.Abs(got[0]-got[1]) > 1e-9 {
+			t.Errorf("%s = %v, want %v", name, got[0], got[1])
+		}
+	}
+
+	if still := throughputEntry("image", 100, 0, durations, 1); still.RealtimeFactor != 0 {
+		t.Errorf("image realtime factor = %v, want 0", still.RealtimeFactor)
+	}
+	if empty := throughputEntry("audio", 100, 5, nil, 1); empty.Error == "" || empty.JobsPerSecond != 0 {
+		t.Errorf("entry without runs = %+v", empty)
+	}
+}
+
+func TestCalibrationStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "calibration.json")
+	store := NewCalibrationStore(path)
+	if store.Latest() != nil {
+		t.Fatal("fresh store has a calibration")
+	}
+
+	c := &Calibration{Workers: 8, Results: []CalibrationEntry{{MediaType: "image", AvgMs: 42}}}
+	if err := store.Save(c); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reloaded := NewCalibrationStore(path).Latest()
+	if reloaded == nil || reloaded.Workers != 8 || len(reloaded.Results) != 1 || reloaded.Results[0].AvgMs != 42 {
+		t.Errorf("reloaded calibration = %+v", reloaded)
+	}
+}
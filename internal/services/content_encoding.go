@@ -0,0 +1,67 @@
+package services
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding is sent with every download. Setting it ourselves stops the
+// transport from decoding gzip behind our back, so Content-Length always
+// describes the bytes read and truncation stays detectable
+const acceptEncoding = "gzip, deflate, br"
+
+// decodeBody undoes the Content-Encoding of a fully read body, last applied
+// coding first. At most limit decoded bytes are accepted so a small
+// compressed body cannot expand without bound
+func decodeBody(data []byte, contentEncoding string, limit int64) ([]byte, error) {
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		if coding == "" || coding == "identity" {
+			continue
+		}
+
+		r, err := newDecoder(coding, data)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := io.ReadAll(io.LimitReader(r, limit+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s body: %w", coding, err)
+		}
+		if int64(len(decoded)) > limit {
+			return nil, fmt.Errorf("file too large: decoded %s body exceeds %d bytes", coding, limit)
+		}
+		data = decoded
+	}
+	return data, nil
+}
+
+// newDecoder returns a reader decoding data compressed with coding
+func newDecoder(coding string, data []byte) (io.Reader, error) {
+	switch coding {
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode gzip body: %w", err)
+		}
+		return r, nil
+	case "deflate":
+		// RFC 9110 deflate is zlib-wrapped, but some servers send raw deflate
+		if r, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+			return r, nil
+		}
+		return flate.NewReader(bytes.NewReader(data)), nil
+	case "br":
+		return brotli.NewReader(bytes.NewReader(data)), nil
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", coding)
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+
+	"fingerprint-converter/internal/pool"
+)
+
+// compress encodes data with coding the way an HTTP server would
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown coding %s", coding)
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDownloadDecodesCompressedSources(t *testing.T) {
+	payload := makeSineWAV(0.1, 8000)
+	var gotAccept string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAccept = r.Header.Get("Accept-Encoding")
+		coding := r.URL.Query().Get("coding")
+		body := payload
+		if coding != "identity" {
+			body = compress(t, coding, payload)
+		}
+		header := coding
+		if coding == "raw-deflate" {
+			header = "deflate"
+		}
+		w.Header().Set("Content-Encoding", header)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	d := NewDownloader(pool.NewBufferPool(2, 1<<20), 1<<20, 5*time.Second, 2, 0)
+	for _, coding := range []string{"gzip", "deflate", "raw-deflate", "br", "identity"} {
+		data, err := d.Download(context.Background(), srv.URL+"/a.wav?coding="+coding)
+		if err != nil {
+			t.Errorf("%s: %v", coding, err)
+			continue
+		}
+		if !bytes.Equal(data, payload) {
+			t.Errorf("%s: decoded %d bytes, want the %d byte source", coding, len(data), len(payload))
+		}
+	}
+	if gotAccept != acceptEncoding {
+		t.Errorf("Accept-Encoding = %q, want %q", gotAccept, acceptEncoding)
+	}
+}
+
+func TestDownloadDetectsTruncatedCompressedBody(t *testing.T) {
+	body := compress(t, "gzip", makeSineWAV(0.1, 8000))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body[:len(body)/2])
+	}))
+	defer srv.Close()
+
+	d := NewDownloader(pool.NewBufferPool(2, 1<<20), 1<<20, 5*time.Second, 2, 0)
+	if _, err := d.downloadWithValidation(context.Background(), srv.URL+"/a.wav", 1); err == nil {
+		t.Error("accepted a truncated body")
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	payload := bytes.Repeat([]byte{0x00, 0x42}, 4096)
+
+	// Codings are undone last first
+	stacked := compress(t, "br", compress(t, "gzip", payload))
+	if got, err := decodeBody(stacked, "gzip, br", 1<<20); err != nil || !bytes.Equal(got, payload) {
+		t.Errorf("stacked codings: %d bytes, %v", len(got), err)
+	}
+
+	// A small body must not expand past the limit
+	if _, err := decodeBody(compress(t, "gzip", payload), "gzip", 1024); err == nil {
+		t.Error("accepted a body decoding past the limit")
+	}
+
+	if _, err := decodeBody(payload, "compress", 1<<20); err == nil {
+		t.Error("accepted an unsupported coding")
+	}
+	if _, err := decodeBody(payload, "gzip", 1<<20); err == nil {
+		t.Error("accepted a body that is not gzip")
+	}
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"fingerprint-converter/internal/chaos"
+	"fingerprint-converter/internal/logx"
+	"fingerprint-converter/internal/pool"
+)
+
+// convertLog is shared by the converters, warm-up and calibration
+var convertLog = logx.For(logx.Converters)
+
+// Converter is implemented by every media converter so handlers can dispatch by media type
+type Converter interface {
+	// MediaType returns the media type handled (audio/image/video/document)
+	MediaType() string
+	// Process applies the script techniques to inputData and writes the result to outputPath
+	// inputFormat is the source format detected from the URL (e.g. "mp3"), may be empty
+	Process(ctx context.Context, inputData []byte, outputPath, inputFormat string, opts ProcessOptions) (*ProcessResult, error)
+	// GetOutputExtension returns the default file extension for outputs
+	GetOutputExtension() string
+	// GenerateOutputPath creates a unique output path
+	GenerateOutputPath(cacheDir, deviceID, urlHash string) string
+	// GetStats returns current statistics
+	GetStats() ConverterStats
+}
+
+// Compile-time checks that all converters implement Converter
+var (
+	_ Converter = (*AudioConverter)(nil)
+	_ Converter = (*ImageConverter)(nil)
+	_ Converter = (*VideoConverter)(nil)
+	_ Converter = (*DocumentConverter)(nil)
+)
+
+// ProcessResult carries converter-specific details of a finished conversion
+type ProcessResult struct {
+	Encoding   *EncodingDecision // Video only
+	OutputPath string            // Set when the output was written elsewhere (format changed)
+}
+
+// baseConverter holds the plumbing shared by all converters: pools, stats,
+// output path generation and ffmpeg execution
+type baseConverter struct {
+	mediaType     string
+	outputExt     string
+	workerPool    *pool.WorkerPool
+	bufferPool    *pool.BufferPool
+	tempDir       string // Intermediate files outside a job workdir ("" = system temp dir)
+	verifyOutput  bool   // Decode outputs before reporting success
+	durationCheck DurationCheck
+	// Replace ffmpeg's encoder tags with a real tool chain's, see encoderIdentity
+	randomizeEncoderTags bool
+	creationWindow       time.Duration // Outputs get a creation time this far back at most (0 = off)
+	statsRecorder
+}
+
+// SetTempDir places the converter's intermediate files in dir
+// Call before the converter is used
+func (b *baseConverter) SetTempDir(dir string) {
+	b.tempDir = dir
+}
+
+// MediaType returns the media type handled by this converter
+func (b *baseConverter) MediaType() string {
+	return b.mediaType
+}
+
+// GetOutputExtension returns the file extension for this converter
+func (b *baseConverter) GetOutputExtension() string {
+	return b.outputExt
+}
+
+// GenerateOutputPath creates a unique output path
+func (b *baseConverter) GenerateOutputPath(cacheDir, deviceID, urlHash string) string {
+	timestamp := time.Now().UnixNano()
+	filename := fmt.Sprintf("%s_%s_%d%s", deviceID, urlHash[:8], timestamp, b.GetOutputExtension())
+	return filepath.Join(cacheDir, filename)
+}
+
+// GetStats returns current statistics
+func (b *baseConverter) GetStats() ConverterStats {
+	return b.snapshot()
+}
+
+// observe records the outcome of one Process call for inputFormat
+func (b *baseConverter) observe(inputFormat string, duration time.Duration, err error) {
+	b.statsRecorder.observe(b.mediaType, inputFormat, duration, err)
+}
+
+// runFFmpeg executes cmd with input on stdin and returns its stdout
+// If the primary run fails, it retries once with the fallback strategy: input
+// from a seekable temp file (format auto-detected) and decoder errors ignored.
+// Failures are recorded in the converter stats
+func (b *baseConverter) runFFmpeg(ctx context.Context, cmd *exec.Cmd, input []byte) ([]byte, error) {
+	return b.runFFmpegTo(ctx, cmd, input, nil)
+}
+
+// runFFmpegTo is runFFmpeg that also copies stdout to stream as ffmpeg emits it
+// Once bytes have reached stream a failure is final: a retry would send them twice
+func (b *baseConverter) runFFmpegTo(ctx context.Context, cmd *exec.Cmd, input []byte, stream io.Writer) ([]byte, error) {
+	var sent *countingWriter
+	if stream != nil {
+		sent = &countingWriter{w: stream}
+		stream = sent
+	}
+
+	started := time.Now()
+	output, err := execFFmpeg(cmd, input, stream)
+	traceCommand(ctx, cmd, started, err)
+	if err == nil {
+		return output, nil
+	}
+	if !shouldRetryFFmpeg(ctx, err) || (sent != nil && sent.n > 0) {
+		b.recordFailure()
+		return nil, err
+	}
+
+	convertLog.Warnf("⚠️  ffmpeg failed (%v), retrying with fallback strategy", err)
+
+	tempFile, tempErr := newIntermediate(b.scratchDir(ctx), "ffmpeg-fallback-*", input)
+	if tempErr != nil {
+		b.recordFailure()
+		return nil, err
+	}
+	defer tempFile.Close()
+
+	args := withErrorTolerance(replacePipeInput(cmd.Args[1:], tempFile.Path()))
+	fallback := toolCommand(ctx, cmd.Args[0], args...)
+	started = time.Now()
+	output, fallbackErr := execFFmpeg(fallback, nil, stream)
+	traceCommand(ctx, fallback, started, fallbackErr)
+	if fallbackErr != nil {
+		b.recordFailure()
+		return nil, fmt.Errorf("%w (fallback also failed: %v)", err, fallbackErr)
+	}
+
+	b.recordFallback()
+	return output, nil
+}
+
+// execFFmpeg runs cmd once, feeding input on stdin when non-nil
+// and copying stdout to stream when non-nil
+func execFFmpeg(cmd *exec.Cmd, input []byte, stream io.Writer) ([]byte, error) {
+	if fault := injectedFault("ffmpeg"); fault != nil {
+		return nil, fault
+	}
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+	var outputBuffer bytes.Buffer
+	errorBuffer := newCappedBuffer()
+	cmd.Stdout = &outputBuffer
+	if stream != nil {
+		cmd.Stdout = io.MultiWriter(&outputBuffer, stream)
+	}
+	cmd.Stderr = errorBuffer
+
+	if err := cmd.Run(); err != nil {
+		return nil, newExecError("ffmpeg", err, errorBuffer)
+	}
+
+	if outputBuffer.Len() == 0 {
+		return nil, fmt.Errorf("ffmpeg produced no output")
+	}
+
+	return outputBuffer.Bytes(), nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// shouldRetryFFmpeg reports whether the fallback strategy could help
+// Cancelled requests and a full disk fail the same way on retry
+func shouldRetryFFmpeg(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && !errors.Is(err, ErrDiskFull)
+}
+
+// replacePipeInput points "-i pipe:0" at path
+func replacePipeInput(args []string, path string) []string {
+	out := append([]string(nil), args...)
+	for i := 0; i+1 < len(out); i++ {
+		if out[i] == "-i" && out[i+1] == "pipe:0" {
+			out[i+1] = path
+		}
+	}
+	return out
+}
+
+// withErrorTolerance makes every input skip corrupt packets instead of aborting
+func withErrorTolerance(args []string) []string {
+	out := make([]string, 0, len(args)+4)
+	for _, a := range args {
+		if a == "-i" {
+			out = append(out, "-err_detect", "ignore_err", "-fflags", "+discardcorrupt+genpts")
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// writeOutput writes converted data to path, recording failures in the stats
+func (b *baseConverter) writeOutput(path string, data []byte) error {
+	chaos.Delay()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		b.recordFailure()
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
+
+// Registry maps media types to their converters
+type Registry struct {
+	converters map[string]Converter
+}
+
+// NewRegistry creates a registry holding the given converters
+func NewRegistry(converters ...Converter) *Registry {
+	r := &Registry{converters: make(map[string]Converter)}
+	for _, c := range converters {
+		r.Register(c)
+	}
+	return r
+}
+
+// Register adds or replaces the converter for its media type
+func (r *Registry) Register(c Converter) {
+	r.converters[c.MediaType()] = c
+}
+
+// Get returns the converter for mediaType
+func (r *Registry) Get(mediaType string) (Converter, bool) {
+	c, ok := r.converters[mediaType]
+	return c, ok
+}
+
+// Stats returns the statistics of every converter keyed by media type
+func (r *Registry) Stats() map[string]ConverterStats {
+	stats := make(map[string]ConverterStats, len(r.converters))
+	for t, c := range r.converters {
+		stats[t] = c.GetStats()
+	}
+	return stats
+}
+
+// MediaTypes returns the registered media types in sorted order
+func (r *Registry) MediaTypes() []string {
+	types := make([]string, 0, len(r.converters))
+	for t := range r.converters {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
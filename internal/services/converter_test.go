@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFallbackArgs(t *testing.T) {
+	args := []string{"-hide_banner", "-i", "pipe:0", "-vf", "eq=gamma=1.0", "pipe:1"}
+
+	got := withErrorTolerance(replacePipeInput(args, "/tmp/in"))
+	want := []string{"-hide_banner", "-err_detect", "ignore_err", "-fflags", "+discardcorrupt+genpts",
+		"-i", "/tmp/in", "-vf", "eq=gamma=1.0", "pipe:1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if args[2] != "pipe:0" {
+		t.Error("replacePipeInput modified its input")
+	}
+}
+
+func TestShouldRetryFFmpeg(t *testing.T) {
+	ctx := context.Background()
+	if !shouldRetryFFmpeg(ctx, &ExecError{Kind: ErrInvalidInput}) {
+		t.Error("invalid input should be retried")
+	}
+	if shouldRetryFFmpeg(ctx, &ExecError{Kind: ErrDiskFull}) {
+		t.Error("disk full must not be retried")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if shouldRetryFFmpeg(cancelled, &ExecError{Kind: ErrToolFailed}) {
+		t.Error("cancelled request must not be retried")
+	}
+}
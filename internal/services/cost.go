@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CostBudget caps the work one request may cause. Zero limits are off
+type CostBudget struct {
+	MaxMegapixelFrames float64 // Width × height × frames, in millions
+	MaxAudioSeconds    float64
+}
+
+// Active reports whether any limit is set
+func (b CostBudget) Active() bool {
+	return b.MaxMegapixelFrames > 0 || b.MaxAudioSeconds > 0
+}
+
+// CostEstimate is the predicted work of converting one input
+type CostEstimate struct {
+	MegapixelFrames float64 // Images count one frame
+	AudioSeconds    float64
+}
+
+// Exceeds reports whether e is over any limit of b
+func (b CostBudget) Exceeds(e CostEstimate) bool {
+	return (b.MaxMegapixelFrames > 0 && e.MegapixelFrames > b.MaxMegapixelFrames) ||
+		(b.MaxAudioSeconds > 0 && e.AudioSeconds > b.MaxAudioSeconds)
+}
+
+// EstimateCost probes path with ffprobe: the largest video or image stream
+// gives the pixels per frame, the container duration and its frame rate
+// the frame count, and an audio stream the seconds of audio. Cover art
+// attached to audio is not counted
+func EstimateCost(ctx context.Context, path string) (CostEstimate, error) {
+	output, err := toolCommand(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration:stream=codec_type,width,height,avg_frame_rate:stream_disposition=attached_pic",
+		"-of", "default=noprint_wrappers=1",
+		path,
+	).Output()
+	if err != nil {
+		return CostEstimate{}, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return parseCostProbe(string(output)), nil
+}
+
+// probedStream is one stream section of ffprobe's output
+type probedStream struct {
+	codecType     string
+	width, height float64
+	fps           float64
+	attachedPic   bool // Cover art
+}
+
+// parseCostProbe turns ffprobe's key=value lines into an estimate. Each
+// stream's fields follow its codec_type; the format's duration comes last
+func parseCostProbe(output string) CostEstimate {
+	var streams []probedStream
+	var duration float64
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		if key == "codec_type" {
+			streams = append(streams, probedStream{codecType: value})
+			continue
+		}
+		if key == "duration" {
+			duration, _ = strconv.ParseFloat(value, 64)
+			continue
+		}
+		if len(streams) == 0 {
+			continue
+		}
+		stream := &streams[len(streams)-1]
+		switch key {
+		case "width":
+			stream.width, _ = strconv.ParseFloat(value, 64)
+		case "height":
+			stream.height, _ = strconv.ParseFloat(value, 64)
+		case "avg_frame_rate":
+			stream.fps = parseFrameRate(value)
+		case "DISPOSITION:attached_pic":
+			stream.attachedPic = value == "1"
+		}
+	}
+
+	var estimate CostEstimate
+	var largest probedStream
+	for _, stream := range streams {
+		switch {
+		case stream.codecType == "audio":
+			estimate.AudioSeconds = duration
+		case stream.codecType == "video" && !stream.attachedPic && stream.width*stream.height > largest.width*largest.height:
+			largest = stream
+		}
+	}
+	if pixels := largest.width * largest.height; pixels > 0 {
+		frames := 1.0 // Still images, and streams without a usable rate
+		if duration > 0 && largest.fps > 0 {
+			frames = duration * largest.fps
+		}
+		estimate.MegapixelFrames = pixels * frames / 1e6
+	}
+	return estimate
+}
+
+// parseFrameRate reads ffprobe rates such as "30000/1001"; 0 when unknown
+func parseFrameRate(rate string) float64 {
+	num, den, ok := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !ok {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
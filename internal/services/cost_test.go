@@ -0,0 +1,56 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseCostProbe(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   CostEstimate
+	}{
+		{
+			name: "video with audio",
+			output: "codec_type=video\nwidth=1920\nheight=1080\navg_frame_rate=30/1\nDISPOSITION:attached_pic=0\n" +
+				"codec_type=audio\navg_frame_rate=0/0\nDISPOSITION:attached_pic=0\nduration=60.000000\n",
+			want: CostEstimate{MegapixelFrames: 1920 * 1080 * 30 * 60 / 1e6, AudioSeconds: 60},
+		},
+		{
+			name:   "still image",
+			output: "codec_type=video\nwidth=4000\nheight=3000\navg_frame_rate=25/1\nDISPOSITION:attached_pic=0\nduration=N/A\n",
+			want:   CostEstimate{MegapixelFrames: 12},
+		},
+		{
+			name: "audio with cover art",
+			output: "codec_type=audio\navg_frame_rate=0/0\nDISPOSITION:attached_pic=0\n" +
+				"codec_type=video\nwidth=600\nheight=600\navg_frame_rate=90000/1\nDISPOSITION:attached_pic=1\nduration=7200.5\n",
+			want: CostEstimate{AudioSeconds: 7200.5},
+		},
+		{
+			name:   "ntsc rate",
+			output: "codec_type=video\nwidth=100\nheight=100\navg_frame_rate=30000/1001\nduration=1001\n",
+			want:   CostEstimate{MegapixelFrames: 100 * 100 * 30000 / 1e6},
+		},
+	}
+	for _, tt := range tests {
+		got := parseCostProbe(tt.output)
+		if math.Abs(got.MegapixelFrames-tt.want.MegapixelFrames) > 1e-6 || got.AudioSeconds != tt.want.AudioSeconds {
+			t.Errorf("%s: estimate = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCostBudgetExceeds(t *testing.T) {
+	budget := CostBudget{MaxMegapixelFrames: 1000}
+	if budget.Exceeds(CostEstimate{MegapixelFrames: 999, AudioSeconds: 1e6}) {
+		t.Error("unlimited audio enforced")
+	}
+	if !budget.Exceeds(CostEstimate{MegapixelFrames: 1001}) {
+		t.Error("pixel budget not enforced")
+	}
+	if (CostBudget{}).Active() {
+		t.Error("zero budget active")
+	}
+}
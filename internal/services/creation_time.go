@@ -0,0 +1,47 @@
+package services
+
+import (
+	mathrand "math/rand"
+	"os"
+	"time"
+)
+
+// SetCreationTimeWindow makes outputs carry a random creation time within
+// window before now, in their metadata and as file modification time.
+// 0 leaves timestamps alone. Call before the converter is used
+func (b *baseConverter) SetCreationTimeWindow(window time.Duration) {
+	b.creationWindow = window
+}
+
+// creationTime picks the output's creation time from rng, false when disabled
+func (b *baseConverter) creationTime(rng *mathrand.Rand) (time.Time, bool) {
+	if b.creationWindow <= 0 {
+		return time.Time{}, false
+	}
+	return pickCreationTime(rng, b.creationWindow, time.Now()), true
+}
+
+// pickCreationTime returns a time uniformly within window before now, to the second
+func pickCreationTime(rng *mathrand.Rand, window time.Duration, now time.Time) time.Time {
+	back := time.Duration(rng.Int63n(int64(window)))
+	return now.Add(-back).Truncate(time.Second).UTC()
+}
+
+// creationTimeArgs returns the ffmpeg metadata arguments carrying t for an
+// output format: MP4/M4A creation_time (mvhd, tkhd, mdhd) or, elsewhere, the
+// date tag (ID3 TDRC, Ogg DATE, WAV ICRD)
+func creationTimeArgs(format string, t time.Time) []string {
+	switch format {
+	case "mp4", "m4a", "mov":
+		return []string{"-metadata", "creation_time=" + t.Format("2006-01-02T15:04:05.000000Z")}
+	default:
+		return []string{"-metadata", "date=" + t.Format("2006-01-02T15:04:05")}
+	}
+}
+
+// stampFile sets the access and modification times of path to t
+func stampFile(path string, t time.Time) {
+	if err := os.Chtimes(path, t, t); err != nil {
+		convertLog.Warnf("⚠️  Failed to set file times: %v", err)
+	}
+}
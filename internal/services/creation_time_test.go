@@ -0,0 +1,71 @@
+package services
+
+import (
+	mathrand "math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPickCreationTimeWithinWindow(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	window := 30 * 24 * time.Hour
+	rng := mathrand.New(mathrand.NewSource(1))
+
+	seen := map[time.Time]bool{}
+	for i := 0; i < 100; i++ {
+		created := pickCreationTime(rng, window, now)
+		if created.After(now) || created.Before(now.Add(-window)) {
+			t.Fatalf("creation time %v outside [%v, %v]", created, now.Add(-window), now)
+		}
+		if created.Nanosecond() != 0 {
+			t.Fatalf("creation time %v has sub-second precision", created)
+		}
+		seen[created] = true
+	}
+	if len(seen) < 90 {
+		t.Errorf("only %d distinct creation times in 100 picks", len(seen))
+	}
+}
+
+func TestCreationTimeDisabled(t *testing.T) {
+	b := &baseConverter{}
+	if _, ok := b.creationTime(mathrand.New(mathrand.NewSource(1))); ok {
+		t.Error("creation time picked without a window")
+	}
+}
+
+func TestCreationTimeArgs(t *testing.T) {
+	created := time.Date(2026, 4, 2, 8, 30, 15, 0, time.UTC)
+	tests := map[string]string{
+		"mp4": "creation_time=2026-04-02T08:30:15.000000Z",
+		"m4a": "creation_time=2026-04-02T08:30:15.000000Z",
+		"mp3": "date=2026-04-02T08:30:15",
+		"ogg": "date=2026-04-02T08:30:15",
+	}
+	for format, want := range tests {
+		args := creationTimeArgs(format, created)
+		if strings.Join(args, " ") != "-metadata "+want {
+			t.Errorf("%s: args = %q, want %q", format, args, want)
+		}
+	}
+}
+
+func TestStampFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.mp3")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2026, 4, 2, 8, 30, 15, 0, time.UTC)
+	stampFile(path, created)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(created) {
+		t.Errorf("mtime = %v, want %v", info.ModTime(), created)
+	}
+}
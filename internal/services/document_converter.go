@@ -0,0 +1,176 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+
+	"fingerprint-converter/internal/pool"
+)
+
+// DocumentConverter handles PDF rewriting for byte-level uniqueness
+// Uses qpdf to drop metadata and renumber objects, then appends an
+// unreferenced nonce object as an incremental update
+type DocumentConverter struct {
+	baseConverter
+	embedNonce bool
+}
+
+// NewDocumentConverter creates a new document converter
+func NewDocumentConverter(workerPool *pool.WorkerPool, bufferPool *pool.BufferPool, embedNonce bool) *DocumentConverter {
+	return &DocumentConverter{
+		baseConverter: baseConverter{
+			mediaType:  "document",
+			outputExt:  ".pdf",
+			workerPool: workerPool,
+			bufferPool: bufferPool,
+		},
+		embedNonce: embedNonce,
+	}
+}
+
+// Process implements Converter
+func (dc *DocumentConverter) Process(ctx context.Context, inputData []byte, outputPath, inputFormat string, opts ProcessOptions) (*ProcessResult, error) {
+	start := time.Now()
+	err := dc.ConvertWithScriptTechniques(ctx, inputData, outputPath, opts)
+	dc.observe(inputFormat, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+	return &ProcessResult{}, nil
+}
+
+// ConvertWithScriptTechniques rewrites a PDF: strips the Info dictionary and XMP
+// metadata, regenerates object numbering, xref table and document ID, and
+// optionally embeds an invisible nonce object (never with NoMarker)
+func (dc *DocumentConverter) ConvertWithScriptTechniques(ctx context.Context, inputData []byte, outputPath string, opts ProcessOptions) error {
+	start := time.Now()
+
+	if len(inputData) == 0 {
+		return fmt.Errorf("empty input data")
+	}
+
+	if !bytes.HasPrefix(inputData, []byte("%PDF-")) {
+		return fmt.Errorf("invalid PDF file: missing %%PDF header")
+	}
+
+	// qpdf needs a seekable input
+	input, err := newIntermediate(dc.scratchDir(ctx), "document-input-*.pdf", inputData)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	tempInput := input.Path()
+
+	// Classic xref (no object streams) keeps the trailer parseable for the nonce update
+	cmd := toolCommand(ctx, "qpdf",
+		"--remove-info",
+		"--remove-metadata",
+		"--object-streams=disable",
+		"--compress-streams=y",
+		tempInput,
+		outputPath,
+	)
+
+	errorBuffer := newCappedBuffer()
+	cmd.Stderr = errorBuffer
+
+	if fault := injectedFault("qpdf"); fault != nil {
+		dc.recordFailure()
+		return fault
+	}
+
+	started := time.Now()
+	err = cmd.Run()
+	traceCommand(ctx, cmd, started, err)
+	if err != nil {
+		// Exit code 3 means the file was written but qpdf repaired something
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+			dc.recordFailure()
+			return newExecError("qpdf", err, errorBuffer)
+		}
+	}
+
+	if dc.embedNonce && !opts.NoMarker {
+		output, err := os.ReadFile(outputPath)
+		if err != nil {
+			dc.recordFailure()
+			return fmt.Errorf("failed to read qpdf output: %w", err)
+		}
+
+		updated, err := appendNonceObject(output, GenerateNonce())
+		if err != nil {
+			dc.recordFailure()
+			return fmt.Errorf("failed to embed nonce: %w", err)
+		}
+
+		if err := dc.writeOutput(outputPath, updated); err != nil {
+			return err
+		}
+	}
+
+	dc.recordSuccess(time.Since(start))
+	return nil
+}
+
+var (
+	pdfStartXrefRe = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+	pdfSizeRe      = regexp.MustCompile(`/Size\s+(\d+)`)
+	pdfRootRe      = regexp.MustCompile(`/Root\s+(\d+\s+\d+\s+R)`)
+	pdfIDRe        = regexp.MustCompile(`/ID\s*\[[^\]]*\]`)
+)
+
+// appendNonceObject adds an unreferenced object holding the nonce as a PDF
+// incremental update. Viewers never render it, but the bytes (and the xref
+// offsets after it) are unique per processing.
+func appendNonceObject(data []byte, nonce *ProcessingNonce) ([]byte, error) {
+	match := pdfStartXrefRe.FindSubmatch(data)
+	if match == nil {
+		return nil, fmt.Errorf("startxref not found")
+	}
+	prevXref := string(match[1])
+
+	trailerIdx := bytes.LastIndex(data, []byte("trailer"))
+	if trailerIdx < 0 {
+		return nil, fmt.Errorf("trailer not found (cross-reference streams are not supported)")
+	}
+	trailer := data[trailerIdx:]
+
+	sizeMatch := pdfSizeRe.FindSubmatch(trailer)
+	rootMatch := pdfRootRe.FindSubmatch(trailer)
+	if sizeMatch == nil || rootMatch == nil {
+		return nil, fmt.Errorf("trailer missing /Size or /Root")
+	}
+	size, err := strconv.Atoi(string(sizeMatch[1]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid /Size: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data)
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	objOffset := buf.Len()
+	fmt.Fprintf(&buf, "%d 0 obj\n<< /FPNonce (uid:%s) >>\nendobj\n", size, nonce.Nonce)
+
+	xrefOffset := buf.Len()
+	fmt.Fprintf(&buf, "xref\n%d 1\n%010d 00000 n \n", size, objOffset)
+
+	idEntry := ""
+	if id := pdfIDRe.Find(trailer); id != nil {
+		idEntry = " " + string(id)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %s /Prev %s%s >>\nstartxref\n%d\n%%%%EOF\n",
+		size+1, rootMatch[1], prevXref, idEntry, xrefOffset)
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestAppendNonceObject(t *testing.T) {
+	original := minimalPDF()
+
+	out1, err := appendNonceObject(original, GenerateNonce())
+	if err != nil {
+		t.Fatalf("append nonce: %v", err)
+	}
+	out2, err := appendNonceObject(original, GenerateNonce())
+	if err != nil {
+		t.Fatalf("append nonce: %v", err)
+	}
+
+	if !bytes.HasPrefix(out1, original) {
+		t.Fatal("incremental update must keep the original bytes intact")
+	}
+	if bytes.Equal(out1, out2) {
+		t.Fatal("two updates produced identical bytes")
+	}
+
+	// New object is numbered /Size and the new xref entry points at it
+	if !bytes.Contains(out1, []byte("4 0 obj\n<< /FPNonce (uid:")) {
+		t.Fatalf("nonce object not found:\n%s", out1[len(original):])
+	}
+	entry := regexp.MustCompile(`xref\n4 1\n(\d{10}) 00000 n `).FindSubmatch(out1)
+	if entry == nil {
+		t.Fatalf("xref subsection for object 4 not found:\n%s", out1[len(original):])
+	}
+	offset, _ := strconv.Atoi(string(entry[1]))
+	if !bytes.HasPrefix(out1[offset:], []byte("4 0 obj")) {
+		t.Errorf("xref offset %d does not point at the nonce object", offset)
+	}
+
+	// Trailer chains to the previous xref and keeps root and ID
+	if !bytes.Contains(out1, []byte("/Size 5 /Root 1 0 R /Prev ")) || !bytes.Contains(out1[len(original):], []byte("/ID [<aa><bb>]")) {
+		t.Errorf("unexpected trailer:\n%s", out1[len(original):])
+	}
+	last := pdfStartXrefRe.FindSubmatch(out1)
+	xref, _ := strconv.Atoi(string(last[1]))
+	if !bytes.HasPrefix(out1[xref:], []byte("xref\n4 1")) {
+		t.Errorf("startxref %d does not point at the new xref section", xref)
+	}
+}
+
+func TestAppendNonceObjectRejectsXrefStreams(t *testing.T) {
+	data := []byte("%PDF-1.5\n1 0 obj\n<< /Type /XRef /Size 2 /Root 2 0 R >>\nendobj\nstartxref\n9\n%%EOF\n")
+	if _, err := appendNonceObject(data, GenerateNonce()); err == nil {
+		t.Fatal("expected error for file without a classic trailer")
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// SetCoalescing makes concurrent downloads of the same URL share a single
+// fetch. Call before the downloader is used
+func (d *Downloader) SetCoalescing(enabled bool) {
+	if enabled {
+		d.coalescer = &coalescer{calls: make(map[string]*inflightDownload)}
+	} else {
+		d.coalescer = nil
+	}
+}
+
+// Coalesced returns how many downloads were served by joining another
+// request's fetch instead of contacting the origin
+func (d *Downloader) Coalesced() int64 {
+	if d.coalescer == nil {
+		return 0
+	}
+	return d.coalescer.joined.Load()
+}
+
+// coalescer is a singleflight for downloads. The fetch runs detached from
+// any one caller, so a client that goes away does not fail the others; it
+// is cancelled only once every caller has gone
+type coalescer struct {
+	mu     sync.Mutex
+	calls  map[string]*inflightDownload
+	joined atomic.Int64
+}
+
+// inflightDownload is one shared fetch and its result
+type inflightDownload struct {
+	done    chan struct{}
+	data    []byte
+	err     error
+	callers int // Joined over the fetch's lifetime; final once done is closed
+	waiting int // Still waiting for the result
+	cancel  context.CancelFunc
+}
+
+// do runs fetch for url, or waits for an identical fetch already running
+// The size limit is part of the key, so a tenant never receives a file
+// larger than it may download, and so is a per-request User-Agent
+func (c *coalescer) do(ctx context.Context, url string, limit int64, userAgent string, fetch func(context.Context, string) ([]byte, error)) ([]byte, error) {
+	key := strconv.FormatInt(limit, 10) + " " + url
+	if userAgent != "" {
+		key = userAgent + "\n" + key
+	}
+
+	c.mu.Lock()
+	call, shared := c.calls[key]
+	if !shared {
+		fetchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &inflightDownload{done: make(chan struct{}), cancel: cancel}
+		c.calls[key] = call
+		go func() {
+			call.data, call.err = fetch(fetchCtx, url)
+			cancel()
+			c.mu.Lock()
+			if c.calls[key] == call {
+				delete(c.calls, key)
+			}
+			c.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	call.callers++
+	call.waiting++
+	c.mu.Unlock()
+
+	if shared {
+		c.joined.Add(1)
+		downloadLog.Infof("🔗 Joined in-flight download: url=%s", truncateURL(url))
+	}
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+		// Callers may modify their input, so a shared result is copied
+		if call.callers > 1 {
+			return bytes.Clone(call.data), nil
+		}
+		return call.data, nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		call.waiting--
+		if call.waiting == 0 {
+			call.cancel()
+			// Later requests start a fresh fetch rather than join a cancelled one
+			if c.calls[key] == call {
+				delete(c.calls, key)
+			}
+		}
+		c.mu.Unlock()
+		return nil, fmt.Errorf("download failed: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fingerprint-converter/internal/pool"
+)
+
+// gatedServer serves payload once release is closed, counting requests
+func gatedServer(t *testing.T, payload []byte) (*httptest.Server, chan struct{}, *int32) {
+	t.Helper()
+	release := make(chan struct{})
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Write(payload)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, release, &hits
+}
+
+// waitForJoins waits until n downloads have joined an in-flight fetch
+func waitForJoins(t *testing.T, d *Downloader, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for d.Coalesced() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("joined = %d, want %d", d.Coalesced(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDownloadCoalescesConcurrentRequests(t *testing.T) {
+	payload := bytes.Repeat([]byte{0x00, 0xCC}, 128)
+	srv, release, hits := gatedServer(t, payload)
+
+	d := NewDownloader(pool.NewBufferPool(2, 1024), 1<<20, 5*time.Second, 4, 0)
+	d.SetCoalescing(true)
+
+	const callers = 5
+	results := make([][]byte, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := d.Download(context.Background(), srv.URL+"/a.png")
+			if err != nil {
+				t.Errorf("caller %d: %v", i, err)
+			}
+			results[i] = data
+		}()
+	}
+	waitForJoins(t, d, callers-1)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Errorf("origin requests = %d, want 1", got)
+	}
+	for i, data := range results {
+		if !bytes.Equal(data, payload) {
+			t.Fatalf("caller %d: payload mismatch", i)
+		}
+	}
+	// Every caller owns its bytes
+	results[0][0] = 0xFF
+	if results[1][0] == 0xFF {
+		t.Error("callers share one buffer")
+	}
+}
+
+func TestDownloadCoalescingSurvivesCancelledCaller(t *testing.T) {
+	payload := bytes.Repeat([]byte{0x00, 0xDD}, 128)
+	srv, release, hits := gatedServer(t, payload)
+
+	d := NewDownloader(pool.NewBufferPool(2, 1024), 1<<20, 5*time.Second, 4, 0)
+	d.SetCoalescing(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := d.Download(ctx, srv.URL+"/a.png")
+		firstErr <- err
+	}()
+
+	second := make(chan []byte, 1)
+	go func() {
+		data, _ := d.Download(context.Background(), srv.URL+"/a.png")
+		second <- data
+	}()
+	waitForJoins(t, d, 1)
+
+	cancel()
+	if err := <-firstErr; err == nil {
+		t.Error("cancelled caller got no error")
+	}
+	close(release)
+	if data := <-second; !bytes.Equal(data, payload) {
+		t.Error("remaining caller lost the download")
+	}
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Errorf("origin requests = %d, want 1", got)
+	}
+}
+
+func TestDownloadCoalescingKeysBySizeLimit(t *testing.T) {
+	payload := bytes.Repeat([]byte{0x00, 0xEE}, 128)
+	srv, release, hits := gatedServer(t, payload)
+
+	d := NewDownloader(pool.NewBufferPool(2, 1024), 1<<20, 5*time.Second, 4, 0)
+	d.SetCoalescing(true)
+
+	var wg sync.WaitGroup
+	for _, limit := range []int64{0, 1 << 10} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.Download(WithDownloadLimit(context.Background(), limit), srv.URL+"/a.png")
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(hits) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(hits); got != 2 || d.Coalesced() != 0 {
+		t.Errorf("origin requests = %d, joined = %d, want 2 separate downloads", got, d.Coalesced())
+	}
+}
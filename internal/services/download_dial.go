@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Address families downloads may connect over
+const (
+	IPFamilyAny  = "any" // Dual-stack: both families, racing them (happy eyeballs)
+	IPFamilyIPv4 = "ipv4"
+	IPFamilyIPv6 = "ipv6"
+)
+
+// dialNetworks maps address families to the networks dialed
+var dialNetworks = map[string]string{
+	IPFamilyAny:  "tcp",
+	IPFamilyIPv4: "tcp4",
+	IPFamilyIPv6: "tcp6",
+}
+
+// newDialer is the dialer of download connections
+func newDialer(fallbackDelay time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout:       30 * time.Second,
+		KeepAlive:     30 * time.Second,
+		FallbackDelay: fallbackDelay,
+	}
+}
+
+// SetDialing restricts download connections to an address family and sets
+// the happy-eyeballs delay: with both families allowed, a host's first
+// address of the other family is tried when the preferred one has not
+// connected after fallbackDelay (0 = 300ms, negative = only after it fails).
+// HTTP/3 is not restricted and falls back to TCP where QUIC cannot connect.
+// Call before SetTLS and SetHTTP3
+func (d *Downloader) SetDialing(family string, fallbackDelay time.Duration) error {
+	network, ok := dialNetworks[family]
+	if !ok {
+		return fmt.Errorf("unknown address family %q (any, ipv4 or ipv6)", family)
+	}
+	transport, ok := d.client.Transport.(*http.Transport)
+	if !ok {
+		return errors.New("dialing must be set before TLS hosts and HTTP/3")
+	}
+	dialer := newDialer(fallbackDelay)
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fingerprint-converter/internal/pool"
+)
+
+func TestSetDialingFamily(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte{0x00, 0xAA}, 128))
+	}))
+	defer srv.Close()
+
+	download := func(family string) error {
+		d := NewDownloader(pool.NewBufferPool(1, 1024), 1<<20, 5*time.Second, 1, 0)
+		if err := d.SetDialing(family, 0); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err := d.DownloadWithMirrors(context.Background(), srv.URL, nil)
+		return err
+	}
+	if err := download(IPFamilyAny); err != nil {
+		t.Errorf("any: %v", err)
+	}
+	if err := download(IPFamilyIPv4); err != nil {
+		t.Errorf("ipv4 to an IPv4 server: %v", err)
+	}
+	if err := download(IPFamilyIPv6); err == nil {
+		t.Error("ipv6 connected to an IPv4-only server")
+	}
+
+	d := NewDownloader(pool.NewBufferPool(1, 1024), 1<<20, 5*time.Second, 1, 0)
+	if err := d.SetDialing("ipv5", 0); err == nil {
+		t.Error("unknown family accepted")
+	}
+}
+
+func TestSetDialingIPv6(t *testing.T) {
+	ln, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte{0x00, 0xAA}, 128))
+	}))
+	srv.Listener.Close()
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+
+	d := NewDownloader(pool.NewBufferPool(1, 1024), 1<<20, 5*time.Second, 1, 0)
+	if err := d.SetDialing(IPFamilyIPv6, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := d.DownloadWithMirrors(context.Background(), srv.URL, nil); err != nil {
+		t.Errorf("ipv6 download from %s: %v", srv.URL, err)
+	}
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTP/3 modes for downloads
+const (
+	HTTP3Off    = "off"    // TCP only
+	HTTP3Auto   = "auto"   // HTTP/3 to hosts that advertised it in Alt-Svc
+	HTTP3Always = "always" // HTTP/3 first for every https host
+)
+
+// errHTTP3Unavailable is returned when the binary was built without QUIC
+var errHTTP3Unavailable = errors.New("built without HTTP/3 support (rebuild with -tags http3)")
+
+// http3BrokenFor is how long a host whose QUIC attempt failed stays on TCP
+const http3BrokenFor = 5 * time.Minute
+
+// SetHTTP3 routes downloads over QUIC according to mode, falling back to
+// HTTP/2 or 1.1 when a host does not answer over UDP. Call before the
+// downloader is used
+func (d *Downloader) SetHTTP3(mode string) error {
+	switch mode {
+	case "", HTTP3Off:
+		return nil
+	case HTTP3Auto, HTTP3Always:
+	default:
+		return fmt.Errorf("unknown HTTP/3 mode %q (supported: off, auto, always)", mode)
+	}
+
+	quic, err := newQUICTransport(d.tlsConfig)
+	if err != nil {
+		return err
+	}
+	d.client.Transport = newHTTP3Fallback(quic, d.client.Transport, mode == HTTP3Always)
+	return nil
+}
+
+// http3Fallback sends https requests over QUIC to hosts known to speak
+// HTTP/3 and everything else over TCP. Hosts are learned from the Alt-Svc
+// header of TCP responses, like browsers do; a QUIC failure (UDP blocked by
+// a firewall, typically) retries the request over TCP and keeps the host
+// there for a while
+type http3Fallback struct {
+	quic   http.RoundTripper
+	tcp    http.RoundTripper
+	always bool // Try QUIC before any Alt-Svc is seen
+
+	mu    sync.Mutex
+	hosts map[string]http3Host // By host:port
+	now   func() time.Time
+}
+
+// http3Host is what is known about one origin
+type http3Host struct {
+	advertised time.Time // Alt-Svc h3 is valid until then
+	broken     time.Time // QUIC failed; TCP only until then
+}
+
+func newHTTP3Fallback(quic, tcp http.RoundTripper, always bool) *http3Fallback {
+	if tcp == nil {
+		tcp = http.DefaultTransport
+	}
+	return &http3Fallback{
+		quic:   quic,
+		tcp:    tcp,
+		always: always,
+		hosts:  make(map[string]http3Host),
+		now:    time.Now,
+	}
+}
+
+func (f *http3Fallback) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" || !f.useQUIC(hostKey(req)) {
+		resp, err := f.tcp.RoundTrip(req)
+		if err == nil {
+			f.learn(hostKey(req), resp.Header.Get("Alt-Svc"))
+		}
+		return resp, err
+	}
+
+	resp, err := f.quic.RoundTrip(req)
+	if err == nil || req.Context().Err() != nil {
+		return resp, err
+	}
+	downloadLog.Warnf("⚠️  HTTP/3 failed for %s, falling back to TCP: %v", req.URL.Host, err)
+	f.markBroken(hostKey(req))
+	return f.tcp.RoundTrip(req)
+}
+
+// useQUIC reports whether requests to host should try HTTP/3
+func (f *http3Fallback) useQUIC(host string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state := f.hosts[host]
+	now := f.now()
+	if now.Before(state.broken) {
+		return false
+	}
+	return f.always || now.Before(state.advertised)
+}
+
+func (f *http3Fallback) markBroken(host string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state := f.hosts[host]
+	state.broken = f.now().Add(http3BrokenFor)
+	f.hosts[host] = state
+}
+
+// learn records the h3 advertisement of an Alt-Svc header. Only h3 on the
+// origin's own port counts, since the QUIC transport dials the request URL
+func (f *http3Fallback) learn(host, altSvc string) {
+	if altSvc == "" {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state := f.hosts[host]
+	if strings.TrimSpace(altSvc) == "clear" {
+		state.advertised = time.Time{}
+		f.hosts[host] = state
+		return
+	}
+	_, port, _ := net.SplitHostPort(host)
+	if maxAge, ok := parseAltSvcH3(altSvc, port); ok {
+		state.advertised = f.now().Add(maxAge)
+		f.hosts[host] = state
+	}
+}
+
+// parseAltSvcH3 finds an h3 alternative on port in an Alt-Svc header and
+// returns its max age (24h when unset, per RFC 7838)
+func parseAltSvcH3(header, port string) (time.Duration, bool) {
+	for _, alt := range strings.Split(header, ",") {
+		params := strings.Split(alt, ";")
+		protocol, authority, ok := strings.Cut(strings.TrimSpace(params[0]), "=")
+		if !ok || protocol != "h3" {
+			continue
+		}
+		if _, altPort, err := net.SplitHostPort(strings.Trim(authority, `"`)); err != nil || altPort != port {
+			continue
+		}
+
+		maxAge := 24 * time.Hour
+		for _, p := range params[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "ma="); ok {
+				if seconds, err := strconv.Atoi(v); err == nil {
+					maxAge = time.Duration(seconds) * time.Second
+				}
+			}
+		}
+		return maxAge, maxAge > 0
+	}
+	return 0, false
+}
+
+// hostKey is the host:port a request goes to
+func hostKey(req *http.Request) string {
+	if req.URL.Port() != "" {
+		return req.URL.Host
+	}
+	return net.JoinHostPort(req.URL.Hostname(), "443")
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// fakeTransport answers with altSvc, or fails when fail is set, recording calls
+type fakeTransport struct {
+	calls  int
+	altSvc string
+	fail   bool
+}
+
+func (t *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls++
+	if t.fail {
+		return nil, errors.New("no route")
+	}
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: req}
+	if t.altSvc != "" {
+		resp.Header.Set("Alt-Svc", t.altSvc)
+	}
+	return resp, nil
+}
+
+func get(t *testing.T, rt http.RoundTripper, url string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip(%s): %v", url, err)
+	}
+}
+
+func TestHTTP3FallbackLearnsAltSvc(t *testing.T) {
+	quic := &fakeTransport{}
+	tcp := &fakeTransport{altSvc: `h3=":443"; ma=60, h2=":443"`}
+	f := newHTTP3Fallback(quic, tcp, false)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	get(t, f, "https://cdn.example/a.mp4")
+	if tcp.calls != 1 || quic.calls != 0 {
+		t.Fatalf("first request: tcp=%d quic=%d, want TCP until Alt-Svc is seen", tcp.calls, quic.calls)
+	}
+	get(t, f, "https://cdn.example/b.mp4")
+	if quic.calls != 1 {
+		t.Errorf("after Alt-Svc: quic=%d, want 1", quic.calls)
+	}
+	get(t, f, "http://cdn.example/c.mp4")
+	get(t, f, "https://other.example/c.mp4")
+	if quic.calls != 1 {
+		t.Errorf("plain http and unadvertised hosts used QUIC: quic=%d", quic.calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	get(t, f, "https://cdn.example/d.mp4")
+	if quic.calls != 1 {
+		t.Error("expired Alt-Svc still used")
+	}
+}
+
+func TestHTTP3FallbackRetriesOverTCP(t *testing.T) {
+	quic := &fakeTransport{fail: true}
+	tcp := &fakeTransport{}
+	f := newHTTP3Fallback(quic, tcp, true)
+
+	get(t, f, "https://cdn.example/a.mp4")
+	if quic.calls != 1 || tcp.calls != 1 {
+		t.Fatalf("quic=%d tcp=%d, want a QUIC attempt then TCP", quic.calls, tcp.calls)
+	}
+	get(t, f, "https://cdn.example/b.mp4")
+	if quic.calls != 1 || tcp.calls != 2 {
+		t.Errorf("broken host retried QUIC: quic=%d tcp=%d", quic.calls, tcp.calls)
+	}
+}
+
+func TestParseAltSvcH3(t *testing.T) {
+	tests := []struct {
+		header, port string
+		want         time.Duration
+		ok           bool
+	}{
+		{`h3=":443"`, "443", 24 * time.Hour, true},
+		{`h2=":443", h3=":443"; ma=3600`, "443", time.Hour, true},
+		{`h3="alt.example:443"; ma=10`, "443", 10 * time.Second, true},
+		{`h3=":8443"`, "443", 0, false},
+		{`h3-29=":443"`, "443", 0, false},
+		{`h3=":443"; ma=0`, "443", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseAltSvcH3(tt.header, tt.port)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseAltSvcH3(%q) = %v, %v, want %v, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSetHTTP3(t *testing.T) {
+	d := NewDownloader(nil, 0, 0, 0, 0)
+	if err := d.SetHTTP3(HTTP3Off); err != nil {
+		t.Errorf("off: %v", err)
+	}
+	if err := d.SetHTTP3("sometimes"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// DownloadTLS is the trust and client identity used to fetch from servers
+// with a private CA or that require mutual TLS
+type DownloadTLS struct {
+	CAFile     string `json:"ca_file,omitempty"`     // PEM bundle of trusted root certificates
+	ClientCert string `json:"client_cert,omitempty"` // PEM client certificate presented for mTLS
+	ClientKey  string `json:"client_key,omitempty"`  // PEM private key of ClientCert
+}
+
+// IsZero reports whether no setting is made
+func (s DownloadTLS) IsZero() bool {
+	return s == DownloadTLS{}
+}
+
+// LoadTLSHosts reads per-host TLS settings: a JSON object from host name,
+// or "*.domain" for every subdomain, to DownloadTLS
+func LoadTLSHosts(path string) (map[string]DownloadTLS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS hosts file: %w", err)
+	}
+	var hosts map[string]DownloadTLS
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		return nil, fmt.Errorf("invalid TLS hosts file: %w", err)
+	}
+	return hosts, nil
+}
+
+// SetTLS applies custom trust and client certificates to downloads. The
+// defaults CA bundle extends the system roots; a host's own ca_file replaces
+// them, so an internal host only trusts its private CA. Host settings left
+// empty inherit the defaults; HTTP/3 only presents the defaults. Call before
+// SetHTTP3 and before the downloader is used
+func (d *Downloader) SetTLS(defaults DownloadTLS, hosts map[string]DownloadTLS) error {
+	base, ok := d.client.Transport.(*http.Transport)
+	if !ok {
+		return errors.New("TLS settings must be applied before HTTP/3")
+	}
+
+	cfg, err := defaults.clientConfig(nil)
+	if err != nil {
+		return err
+	}
+	base.TLSClientConfig = cfg
+	d.tlsConfig = cfg
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	router := &hostTransports{fallback: base, hosts: make(map[string]http.RoundTripper, len(hosts))}
+	for host, settings := range hosts {
+		hostCfg, err := settings.clientConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", host, err)
+		}
+		t := base.Clone()
+		t.TLSClientConfig = hostCfg
+		router.hosts[strings.ToLower(host)] = t
+	}
+	d.client.Transport = router
+	return nil
+}
+
+// clientConfig builds the TLS client config of s over inherited (nil = the
+// system defaults, whose roots a CA bundle extends)
+func (s DownloadTLS) clientConfig(inherited *tls.Config) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if inherited != nil {
+		cfg = inherited.Clone()
+	}
+
+	if s.CAFile != "" {
+		pem, err := os.ReadFile(s.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if inherited == nil {
+			if system, err := x509.SystemCertPool(); err == nil {
+				roots = system
+			}
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA bundle %s", s.CAFile)
+		}
+		cfg.RootCAs = roots
+	}
+
+	if (s.ClientCert == "") != (s.ClientKey == "") {
+		return nil, errors.New("client_cert and client_key must be set together")
+	}
+	if s.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(s.ClientCert, s.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// hostTransports sends requests through the transport configured for their
+// host, and through fallback otherwise
+type hostTransports struct {
+	fallback http.RoundTripper
+	hosts    map[string]http.RoundTripper // By lowercase host name or "*.domain"
+}
+
+func (h *hostTransports) RoundTrip(req *http.Request) (*http.Response, error) {
+	return h.transportFor(req.URL.Hostname()).RoundTrip(req)
+}
+
+// transportFor matches the exact host first, then the closest wildcard
+func (h *hostTransports) transportFor(host string) http.RoundTripper {
+	host = strings.ToLower(host)
+	if t, ok := h.hosts[host]; ok {
+		return t
+	}
+	for domain := host; ; {
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			return h.fallback
+		}
+		if t, ok := h.hosts["*."+parent]; ok {
+			return t
+		}
+		domain = parent
+	}
+}
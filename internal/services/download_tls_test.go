@@ -0,0 +1,209 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fingerprint-converter/internal/pool"
+)
+
+// testPKI is a private CA with a server certificate for 127.0.0.1 and a
+// client certificate, written as PEM files under dir
+type testPKI struct {
+	caFile, clientCert, clientKey string
+	caPool                        *x509.CertPool
+	server                        tls.Certificate
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	dir := t.TempDir()
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage) ([]byte, *ecdsa.PrivateKey) {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "test"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der, key
+	}
+	writePEM := func(name, typ string, der []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	pki := testPKI{caFile: writePEM("ca.pem", "CERTIFICATE", caDER), caPool: x509.NewCertPool()}
+	pki.caPool.AddCert(ca)
+
+	serverDER, serverKey := issue(2, x509.ExtKeyUsageServerAuth)
+	pki.server = tls.Certificate{Certificate: [][]byte{serverDER}, PrivateKey: serverKey}
+
+	clientDER, clientKey := issue(3, x509.ExtKeyUsageClientAuth)
+	keyDER, _ := x509.MarshalECPrivateKey(clientKey)
+	pki.clientCert = writePEM("client.pem", "CERTIFICATE", clientDER)
+	pki.clientKey = writePEM("client-key.pem", "EC PRIVATE KEY", keyDER)
+	return pki
+}
+
+// tlsPayload is binary content that passes the downloader's media check
+var tlsPayload = bytes.Repeat([]byte{0x00, 0xBB}, 128)
+
+// mtlsServer serves tlsPayload only to clients presenting a certificate of pki's CA
+func mtlsServer(t *testing.T, pki testPKI) *httptest.Server {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(tlsPayload)
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pki.server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pki.caPool,
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTLSTestDownloader() *Downloader {
+	return NewDownloader(pool.NewBufferPool(1, 1024), 1<<20, 5*time.Second, 2, 0)
+}
+
+func TestSetTLSMutualAuth(t *testing.T) {
+	pki := newTestPKI(t)
+	srv := mtlsServer(t, pki)
+
+	cases := []struct {
+		name     string
+		defaults DownloadTLS
+		hosts    map[string]DownloadTLS
+		ok       bool
+	}{
+		{"system defaults", DownloadTLS{}, nil, false},
+		{"CA without client cert", DownloadTLS{CAFile: pki.caFile}, nil, false},
+		{"CA and client cert", DownloadTLS{CAFile: pki.caFile, ClientCert: pki.clientCert, ClientKey: pki.clientKey}, nil, true},
+		{"per-host", DownloadTLS{}, map[string]DownloadTLS{
+			"127.0.0.1": {CAFile: pki.caFile, ClientCert: pki.clientCert, ClientKey: pki.clientKey},
+		}, true},
+		{"per-host cert over default CA", DownloadTLS{CAFile: pki.caFile}, map[string]DownloadTLS{
+			"127.0.0.1": {ClientCert: pki.clientCert, ClientKey: pki.clientKey},
+		}, true},
+		{"other host", DownloadTLS{}, map[string]DownloadTLS{
+			"files.internal": {CAFile: pki.caFile, ClientCert: pki.clientCert, ClientKey: pki.clientKey},
+		}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := newTLSTestDownloader()
+			if err := d.SetTLS(tc.defaults, tc.hosts); err != nil {
+				t.Fatalf("SetTLS: %v", err)
+			}
+			data, err := d.Download(context.Background(), srv.URL+"/file")
+			if tc.ok && (err != nil || !bytes.Equal(data, tlsPayload)) {
+				t.Fatalf("Download = %d bytes, %v; want the payload", len(data), err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("Download succeeded; want a TLS failure")
+			}
+		})
+	}
+}
+
+func TestSetTLSInvalid(t *testing.T) {
+	pki := newTestPKI(t)
+	cases := map[string]DownloadTLS{
+		"missing CA":       {CAFile: filepath.Join(t.TempDir(), "none.pem")},
+		"CA without PEM":   {CAFile: pki.clientKey},
+		"cert without key": {ClientCert: pki.clientCert},
+	}
+	for name, settings := range cases {
+		if err := newTLSTestDownloader().SetTLS(settings, nil); err == nil {
+			t.Errorf("%s: SetTLS succeeded", name)
+		}
+		if err := newTLSTestDownloader().SetTLS(DownloadTLS{}, map[string]DownloadTLS{"h": settings}); err == nil {
+			t.Errorf("%s per-host: SetTLS succeeded", name)
+		}
+	}
+}
+
+func TestHostTransportsMatching(t *testing.T) {
+	fallback, exact, wildcard := &fakeTransport{}, &fakeTransport{}, &fakeTransport{}
+	h := &hostTransports{fallback: fallback, hosts: map[string]http.RoundTripper{
+		"media.internal":  exact,
+		"*.corp.internal": wildcard,
+	}}
+
+	cases := map[string]http.RoundTripper{
+		"media.internal":       exact,
+		"MEDIA.internal":       exact,
+		"a.corp.internal":      wildcard,
+		"a.b.corp.internal":    wildcard,
+		"corp.internal":        fallback,
+		"example.com":          fallback,
+		"other.media.internal": fallback,
+	}
+	for host, want := range cases {
+		if got := h.transportFor(host); got != want {
+			t.Errorf("transportFor(%s) picked the wrong transport", host)
+		}
+	}
+}
+
+func TestLoadTLSHosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.json")
+	os.WriteFile(path, []byte(`{"*.corp.internal": {"ca_file": "/etc/ca.pem", "client_cert": "c.pem", "client_key": "k.pem"}}`), 0600)
+	hosts, err := LoadTLSHosts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DownloadTLS{CAFile: "/etc/ca.pem", ClientCert: "c.pem", ClientKey: "k.pem"}
+	if hosts["*.corp.internal"] != want {
+		t.Fatalf("hosts = %+v", hosts)
+	}
+
+	os.WriteFile(path, []byte(`[]`), 0600)
+	if _, err := LoadTLSHosts(path); err == nil {
+		t.Fatal("LoadTLSHosts accepted a non-object")
+	}
+}
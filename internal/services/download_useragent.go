@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+)
+
+// MaxUserAgentLength bounds a User-Agent given per request
+const MaxUserAgentLength = 512
+
+// userAgents rotates through a pool of User-Agent strings
+type userAgents struct {
+	pool []string
+	next atomic.Uint64
+}
+
+// pick returns the next User-Agent in the pool
+func (u *userAgents) pick() string {
+	if len(u.pool) == 1 {
+		return u.pool[0]
+	}
+	return u.pool[(u.next.Add(1)-1)%uint64(len(u.pool))]
+}
+
+// SetUserAgents sets the User-Agent sent with downloads: one string, or a
+// pool rotated request by request (empty keeps Go's default). Call before
+// the downloader is used
+func (d *Downloader) SetUserAgents(agents []string) error {
+	var pool []string
+	for _, ua := range agents {
+		if ua == "" {
+			continue
+		}
+		if err := ValidateUserAgent(ua); err != nil {
+			return err
+		}
+		pool = append(pool, ua)
+	}
+	if len(pool) == 0 {
+		d.userAgents = nil
+		return nil
+	}
+	d.userAgents = &userAgents{pool: pool}
+	return nil
+}
+
+// ValidateUserAgent rejects User-Agent values that are too long or would
+// break the request header
+func ValidateUserAgent(ua string) error {
+	if len(ua) > MaxUserAgentLength {
+		return errors.New("user agent is too long")
+	}
+	for i := 0; i < len(ua); i++ {
+		if c := ua[i]; c < 0x20 || c > 0x7e {
+			return errors.New("user agent must be printable ASCII")
+		}
+	}
+	return nil
+}
+
+// userAgentKey carries a per-request User-Agent in the context
+type userAgentKey struct{}
+
+// WithUserAgent overrides the downloader's User-Agent for requests made with ctx
+func WithUserAgent(ctx context.Context, ua string) context.Context {
+	if ua == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, userAgentKey{}, ua)
+}
+
+// requestUserAgent returns the User-Agent override carried by ctx, if any
+func requestUserAgent(ctx context.Context) string {
+	ua, _ := ctx.Value(userAgentKey{}).(string)
+	return ua
+}
+
+// setUserAgent applies the request's override, else the next pooled agent
+func (d *Downloader) setUserAgent(req *http.Request) {
+	if ua := requestUserAgent(req.Context()); ua != "" {
+		req.Header.Set("User-Agent", ua)
+	} else if d.userAgents != nil {
+		req.Header.Set("User-Agent", d.userAgents.pick())
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fingerprint-converter/internal/pool"
+)
+
+// uaServer serves a media payload, recording the User-Agent of every request
+func uaServer(t *testing.T) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.UserAgent())
+		mu.Unlock()
+		w.Write(tlsPayload)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), seen...)
+	}
+}
+
+func TestUserAgentRotation(t *testing.T) {
+	srv, seen := uaServer(t)
+	d := NewDownloader(pool.NewBufferPool(1, 1024), 1<<20, 5*time.Second, 2, 0)
+	if err := d.SetUserAgents([]string{"agent-a", "", "agent-b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := d.Download(context.Background(), srv.URL+"/file"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.Download(WithUserAgent(context.Background(), "override"), srv.URL+"/file"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"agent-a", "agent-b", "agent-a", "agent-b", "override"}
+	if got := seen(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("User-Agents = %v, want %v", got, want)
+	}
+}
+
+func TestUserAgentDefault(t *testing.T) {
+	srv, seen := uaServer(t)
+	d := NewDownloader(pool.NewBufferPool(1, 1024), 1<<20, 5*time.Second, 2, 0)
+	if _, err := d.Download(context.Background(), srv.URL+"/file"); err != nil {
+		t.Fatal(err)
+	}
+	if got := seen(); len(got) != 1 || !strings.HasPrefix(got[0], "Go-http-client") {
+		t.Fatalf("User-Agents = %v, want Go's default", got)
+	}
+}
+
+func TestValidateUserAgent(t *testing.T) {
+	valid := []string{"", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko)"}
+	for _, ua := range valid {
+		if err := ValidateUserAgent(ua); err != nil {
+			t.Errorf("ValidateUserAgent(%q) = %v", ua, err)
+		}
+	}
+	invalid := []string{"bot\r\nX-Injected: 1", "agent\x00", "agënt", strings.Repeat("a", MaxUserAgentLength+1)}
+	for _, ua := range invalid {
+		if err := ValidateUserAgent(ua); err == nil {
+			t.Errorf("ValidateUserAgent(%q) accepted", ua)
+		}
+	}
+
+	d := NewDownloader(pool.NewBufferPool(1, 1024), 1<<20, 5*time.Second, 2, 0)
+	if err := d.SetUserAgents([]string{"ok", "bad\n"}); err == nil {
+		t.Error("SetUserAgents accepted a header break")
+	}
+}
+
+func TestCoalescingKeepsUserAgentsApart(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.UserAgent())
+		mu.Unlock()
+		<-release
+		w.Write(tlsPayload)
+	}))
+	defer srv.Close()
+
+	d := NewDownloader(pool.NewBufferPool(2, 1024), 1<<20, 5*time.Second, 4, 0)
+	d.SetCoalescing(true)
+
+	var wg sync.WaitGroup
+	for _, ua := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(ua string) {
+			defer wg.Done()
+			if _, err := d.Download(WithUserAgent(context.Background(), ua), srv.URL+"/a.png"); err != nil {
+				t.Error(err)
+			}
+		}(ua)
+	}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		mu.Lock()
+		n := len(seen)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	if len(seen) != 2 || d.Coalesced() != 0 {
+		t.Fatalf("requests = %v, coalesced = %d; want one fetch per User-Agent", seen, d.Coalesced())
+	}
+}
@@ -3,14 +3,28 @@ package services
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"regexp"
 	"strings"
 	"time"
 
+	"fingerprint-converter/internal/chaos"
+	"fingerprint-converter/internal/logx"
 	"fingerprint-converter/internal/pool"
+	"fingerprint-converter/internal/redact"
+)
+
+// downloadLog carries download progress and retries
+var downloadLog = logx.For(logx.Downloader)
+
+// Download content failures, usable with errors.Is
+var (
+	ErrEmptyDownload   = errors.New("downloaded file is empty")
+	ErrContentMismatch = errors.New("downloaded content is not media")
 )
 
 // Downloader handles file downloads from URLs (S3, HTTP, HTTPS)
@@ -18,10 +32,17 @@ type Downloader struct {
 	client     *http.Client
 	bufferPool *pool.BufferPool
 	maxSize    int64
+	slots      *pool.Semaphore // Limits concurrent downloads independently of conversions
+	hedgeDelay time.Duration   // Start a second GET when the first is this slow (0 = off)
+	coalescer  *coalescer      // Shares identical in-flight downloads (nil = off)
+	tlsConfig  *tls.Config     // Custom trust and client certificate (nil = system defaults)
+	userAgents *userAgents     // User-Agent rotation (nil = Go's default)
 }
 
 // NewDownloader creates a new downloader with optimized HTTP client
-func NewDownloader(bufferPool *pool.BufferPool, maxSize int64, timeout time.Duration) *Downloader {
+// maxConcurrent caps simultaneous downloads (<= 0 uses the default of 16)
+// hedgeDelay enables hedged requests when > 0
+func NewDownloader(bufferPool *pool.BufferPool, maxSize int64, timeout time.Duration, maxConcurrent int, hedgeDelay time.Duration) *Downloader {
 	if timeout <= 0 {
 		timeout = 2 * time.Minute // Aumentado de 30s para 2min (vídeos grandes)
 	}
@@ -30,10 +51,15 @@ func NewDownloader(bufferPool *pool.BufferPool, maxSize int64, timeout time.Dura
 		maxSize = 500 * 1024 * 1024 // 500MB default
 	}
 
+	if maxConcurrent <= 0 {
+		maxConcurrent = 16
+	}
+
 	// Optimized HTTP client for high throughput
 	client := &http.Client{
 		Timeout: timeout,
 		Transport: &http.Transport{
+			DialContext:         newDialer(0).DialContext,
 			MaxIdleConns:        100,
 			MaxIdleConnsPerHost: 100,
 			IdleConnTimeout:     90 * time.Second,
@@ -46,11 +72,52 @@ func NewDownloader(bufferPool *pool.BufferPool, maxSize int64, timeout time.Dura
 		client:     client,
 		bufferPool: bufferPool,
 		maxSize:    maxSize,
+		slots:      pool.NewSemaphore(maxConcurrent),
+		hedgeDelay: hedgeDelay,
+	}
+}
+
+// MaxSize returns the largest download accepted, in bytes
+func (d *Downloader) MaxSize() int64 {
+	return d.maxSize
+}
+
+// downloadLimitKey carries a per-request size limit in the context
+type downloadLimitKey struct{}
+
+// WithDownloadLimit lowers the maximum download size for requests made with ctx
+// Limits at or above the downloader's own maximum have no effect
+func WithDownloadLimit(ctx context.Context, maxSize int64) context.Context {
+	if maxSize <= 0 {
+		return ctx
 	}
+	return context.WithValue(ctx, downloadLimitKey{}, maxSize)
+}
+
+// SizeLimit returns the effective maximum download size for ctx
+func (d *Downloader) SizeLimit(ctx context.Context) int64 {
+	if limit, ok := ctx.Value(downloadLimitKey{}).(int64); ok && limit < d.maxSize {
+		return limit
+	}
+	return d.maxSize
+}
+
+// GetStats returns download concurrency statistics
+func (d *Downloader) GetStats() pool.SemaphoreStats {
+	return d.slots.GetStats()
 }
 
 // Download fetches a file from URL (S3, HTTP, HTTPS) with retry logic
+// With coalescing on, concurrent calls for the same URL share one fetch
 func (d *Downloader) Download(ctx context.Context, url string) ([]byte, error) {
+	if d.coalescer != nil {
+		return d.coalescer.do(ctx, url, d.SizeLimit(ctx), requestUserAgent(ctx), d.download)
+	}
+	return d.download(ctx, url)
+}
+
+// download fetches url with retries, without coalescing
+func (d *Downloader) download(ctx context.Context, url string) ([]byte, error) {
 	// Validate URL
 	if url == "" {
 		return nil, fmt.Errorf("empty URL")
@@ -63,7 +130,7 @@ func (d *Downloader) Download(ctx context.Context, url string) ([]byte, error) {
 	// Retry logic: até 3 tentativas
 	var lastErr error
 	for attempt := 1; attempt <= 3; attempt++ {
-		data, err := d.downloadWithValidation(ctx, url, attempt)
+		data, err := d.downloadOnce(ctx, url, attempt)
 		if err == nil {
 			return data, nil
 		}
@@ -75,7 +142,7 @@ func (d *Downloader) Download(ctx context.Context, url string) ([]byte, error) {
 		}
 
 		if attempt < 3 {
-			log.Printf("⚠️  Download attempt %d failed: %v, retrying...", attempt, err)
+			downloadLog.Warnf("⚠️  Download attempt %d failed: %s, retrying...", attempt, redact.Error(err))
 			time.Sleep(time.Duration(attempt) * time.Second) // Backoff: 1s, 2s
 		}
 	}
@@ -83,20 +150,88 @@ func (d *Downloader) Download(ctx context.Context, url string) ([]byte, error) {
 	return nil, fmt.Errorf("download failed after 3 attempts: %w", lastErr)
 }
 
-// downloadWithValidation performs the actual download with validation
-func (d *Downloader) downloadWithValidation(ctx context.Context, url string, attempt int) ([]byte, error) {
+// MaxMirrors caps how many alternative URLs a single request may list
+const MaxMirrors = 5
 
-	// Create request with context
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// DownloadWithMirrors tries url first and then each mirror in order, each with
+// the usual retries. Returns the data and the URL that served it
+func (d *Downloader) DownloadWithMirrors(ctx context.Context, url string, mirrors []string) ([]byte, string, error) {
+	sources := append([]string{url}, mirrors...)
+
+	var errs []error
+	for i, source := range sources {
+		data, err := d.Download(ctx, source)
+		if err == nil {
+			if i > 0 {
+				downloadLog.Infof("🪞 Downloaded from mirror %d: %s", i, truncateURL(source))
+			}
+			return data, source, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", truncateURL(source), err))
+
+		if ctx.Err() != nil {
+			break
+		}
+		if i < len(sources)-1 {
+			downloadLog.Warnf("⚠️  Source failed (%s), trying next mirror", redact.Error(err))
+		}
+	}
+
+	if len(errs) == 1 {
+		return nil, "", errs[0]
+	}
+	return nil, "", fmt.Errorf("all %d sources failed: %w", len(sources), errors.Join(errs...))
+}
+
+// downloadOnce holds a download slot for a single attempt, so backoff sleeps
+// between retries don't block other downloads
+func (d *Downloader) downloadOnce(ctx context.Context, url string, attempt int) ([]byte, error) {
+	if err := d.slots.Acquire(ctx); err != nil {
+		return nil, fmt.Errorf("waiting for download slot: %w", err)
+	}
+	defer d.slots.Release()
+
+	return d.downloadWithValidation(ctx, url, attempt)
+}
+
+// ContentType issues a HEAD request and returns the MIME type without parameters
+// Used to classify URLs that carry no usable extension (e.g. presigned URLs)
+func (d *Downloader) ContentType(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// Execute request
+	d.setUserAgent(req)
+
 	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("HEAD failed: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HEAD failed: HTTP %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	return strings.ToLower(strings.TrimSpace(mediaType)), nil
+}
+
+// downloadWithValidation performs the actual download with validation
+func (d *Downloader) downloadWithValidation(ctx context.Context, url string, attempt int) ([]byte, error) {
+	maxSize := d.SizeLimit(ctx)
+
+	if chaos.Hit(chaos.DownloadTimeout) {
+		return nil, fmt.Errorf("download failed: %w: %w", chaos.ErrInjected, context.DeadlineExceeded)
+	}
+
+	// Execute request (hedged when enabled)
+	resp, cancel, err := d.doGet(ctx, url)
 	if err != nil {
 		return nil, fmt.Errorf("download failed: %w", err)
 	}
+	defer cancel()
 	defer resp.Body.Close()
 
 	// Check status code
@@ -106,11 +241,11 @@ func (d *Downloader) downloadWithValidation(ctx context.Context, url string, att
 
 	// Check content length
 	contentLength := resp.ContentLength
-	if contentLength > d.maxSize {
-		return nil, fmt.Errorf("file too large: %d bytes (max: %d)", contentLength, d.maxSize)
+	if contentLength > maxSize {
+		return nil, fmt.Errorf("file too large: %d bytes (max: %d)", contentLength, maxSize)
 	}
 
-	log.Printf("📥 Downloading: size=%d bytes, attempt=%d, url=%s", contentLength, attempt, truncateURL(url))
+	downloadLog.Infof("📥 Downloading: size=%d bytes, attempt=%d, url=%s", contentLength, attempt, truncateURL(url))
 
 	// Use buffer pool for efficient memory management
 	var data []byte
@@ -142,7 +277,7 @@ func (d *Downloader) downloadWithValidation(ctx context.Context, url string, att
 		} else {
 			// Too large for pool, read directly with validation
 			var readErr error
-			data, readErr = io.ReadAll(io.LimitReader(resp.Body, d.maxSize+1))
+			data, readErr = io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
 			if readErr != nil {
 				return nil, fmt.Errorf("read failed: %w", readErr)
 			}
@@ -153,27 +288,43 @@ func (d *Downloader) downloadWithValidation(ctx context.Context, url string, att
 			}
 
 			// Verifica se excedeu o limite
-			if int64(len(data)) > d.maxSize {
-				return nil, fmt.Errorf("file too large: %d bytes (max: %d)", len(data), d.maxSize)
+			if int64(len(data)) > maxSize {
+				return nil, fmt.Errorf("file too large: %d bytes (max: %d)", len(data), maxSize)
 			}
 		}
 	} else {
 		// Unknown size - use limited reader
-		log.Printf("⚠️  Content-Length not provided, reading until EOF (url=%s)", truncateURL(url))
+		downloadLog.Warnf("⚠️  Content-Length not provided, reading until EOF (url=%s)", truncateURL(url))
 		var readErr error
-		data, readErr = io.ReadAll(io.LimitReader(resp.Body, d.maxSize+1))
+		data, readErr = io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
 		if readErr != nil {
 			return nil, fmt.Errorf("read failed: %w", readErr)
 		}
 
 		// Para tamanho desconhecido, verifica se chegou ao limite (possível truncamento)
-		if int64(len(data)) > d.maxSize {
-			return nil, fmt.Errorf("file too large: %d bytes (max: %d)", len(data), d.maxSize)
+		if int64(len(data)) > maxSize {
+			return nil, fmt.Errorf("file too large: %d bytes (max: %d)", len(data), maxSize)
 		}
 	}
 
+	// Content-Length and the checks above apply to the encoded bytes
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+		decoded, err := decodeBody(data, encoding, maxSize)
+		if err != nil {
+			return nil, err
+		}
+		downloadLog.Debugf("Decoded %s body: %d -> %d bytes (url=%s)", encoding, len(data), len(decoded), truncateURL(url))
+		data = decoded
+	}
+
 	if len(data) == 0 {
-		return nil, fmt.Errorf("downloaded file is empty")
+		return nil, ErrEmptyDownload
+	}
+
+	// CDNs and expired links often answer 200 with an error page
+	if kind := textPayload(data); kind != "" {
+		downloadLog.Debugf("Download is %s, not media (url=%s): %q", kind, truncateURL(url), data[:min(len(data), 256)])
+		return nil, fmt.Errorf("%w: server returned %s (%d bytes)", ErrContentMismatch, kind, len(data))
 	}
 
 	// Validação adicional: tamanho mínimo esperado para arquivos de mídia
@@ -183,16 +334,107 @@ func (d *Downloader) downloadWithValidation(ctx context.Context, url string, att
 	}
 
 	// Validação de integridade básica para vídeos MP4
-	if contentLength > 0 && isVideoURL(url) {
+	// Images served under a video name are left for the handler to re-route
+	if contentLength > 0 && isVideoURL(url) && detectImageFormat(data) == "unknown" {
 		if err := validateVideoData(data); err != nil {
 			return nil, fmt.Errorf("video validation failed: %w (file may be corrupted or truncated)", err)
 		}
 	}
 
-	log.Printf("✅ Download complete: size=%d bytes, url=%s", len(data), truncateURL(url))
+	downloadLog.Infof("✅ Download complete: size=%d bytes, url=%s", len(data), truncateURL(url))
 	return data, nil
 }
 
+// doGet issues the GET request. With hedging enabled, a second identical
+// request starts if the first has not returned response headers within
+// hedgeDelay; the first response wins and the other request is cancelled.
+// The returned cancel func must be called once the body has been read
+func (d *Downloader) doGet(ctx context.Context, url string) (*http.Response, context.CancelFunc, error) {
+	if d.hedgeDelay <= 0 {
+		reqCtx, cancel := context.WithCancel(ctx)
+		resp, err := d.get(reqCtx, url)
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		return resp, cancel, nil
+	}
+
+	type result struct {
+		resp *http.Response
+		err  error
+		idx  int
+	}
+	results := make(chan result, 2)
+	var cancels []context.CancelFunc
+
+	launch := func() {
+		reqCtx, cancel := context.WithCancel(ctx)
+		idx := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := d.get(reqCtx, url)
+			results <- result{resp, err, idx}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(d.hedgeDelay)
+	defer timer.Stop()
+
+	var lastErr error
+	for pending := 1; pending > 0; {
+		select {
+		case <-timer.C:
+			downloadLog.Infof("🐢 No response after %v, sending hedged request (url=%s)", d.hedgeDelay, truncateURL(url))
+			launch()
+			pending++
+
+		case r := <-results:
+			pending--
+			if r.err != nil {
+				lastErr = r.err
+				continue
+			}
+
+			for i, cancel := range cancels {
+				if i != r.idx {
+					cancel()
+				}
+			}
+			// The loser may still deliver a response; release its connection
+			go func(n int) {
+				for ; n > 0; n-- {
+					if late := <-results; late.resp != nil {
+						late.resp.Body.Close()
+					}
+				}
+			}(pending)
+
+			if r.idx > 0 {
+				downloadLog.Infof("🏁 Hedged request won (url=%s)", truncateURL(url))
+			}
+			return r.resp, cancels[r.idx], nil
+		}
+	}
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return nil, nil, lastErr
+}
+
+// get performs a single GET request
+func (d *Downloader) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	d.setUserAgent(req)
+	return d.client.Do(req)
+}
+
 // isRetryableError checks if error is retryable (network/timeout errors)
 func isRetryableError(err error) bool {
 	if err == nil {
@@ -223,6 +465,15 @@ func isRetryableError(err error) bool {
 	return false
 }
 
+// unavailableStatus matches origin answers worth trying again later
+var unavailableStatus = regexp.MustCompile(`HTTP (408|429|5\d\d)\b`)
+
+// IsTransient reports whether a failed download may succeed if tried again
+// later: network errors, timeouts and the origin being overloaded or down
+func IsTransient(err error) bool {
+	return isRetryableError(err) || (err != nil && unavailableStatus.MatchString(err.Error()))
+}
+
 // isVideoURL checks if URL is a video based on extension
 func isVideoURL(url string) bool {
 	urlLower := strings.ToLower(url)
@@ -271,6 +522,7 @@ func validateVideoData(data []byte) error {
 
 // truncateURL truncates URL for logging
 func truncateURL(url string) string {
+	url = redact.URL(url)
 	if len(url) > 60 {
 		return url[:57] + "..."
 	}
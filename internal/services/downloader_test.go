@@ -0,0 +1,129 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fingerprint-converter/internal/pool"
+)
+
+func TestDownloadWithMirrorsFallsBack(t *testing.T) {
+	payload := bytes.Repeat([]byte{0x00, 0xAA}, 128)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(payload)
+	}))
+	defer srv.Close()
+
+	d := NewDownloader(pool.NewBufferPool(2, 1024), 1<<20, 5*time.Second, 2, 0)
+
+	data, source, err := d.DownloadWithMirrors(context.Background(), srv.URL+"/missing", []string{srv.URL + "/mirror"})
+	if err != nil {
+		t.Fatalf("DownloadWithMirrors: %v", err)
+	}
+	if source != srv.URL+"/mirror" {
+		t.Errorf("served by %q, want mirror", source)
+	}
+	if !bytes.Equal(data, payload) {
+		t.Error("payload mismatch")
+	}
+
+	if _, _, err := d.DownloadWithMirrors(context.Background(), srv.URL+"/missing", []string{srv.URL + "/missing"}); err == nil {
+		t.Error("expected error when every source fails")
+	}
+}
+
+func TestHedgedDownloadTakesFasterResponse(t *testing.T) {
+	payload := bytes.Repeat([]byte{0x00, 0xBB}, 128)
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// First request stalls until the client gives up on it
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Write(payload)
+	}))
+	defer srv.Close()
+
+	d := NewDownloader(pool.NewBufferPool(2, 1024), 1<<20, 10*time.Second, 2, 50*time.Millisecond)
+
+	start := time.Now()
+	data, err := d.Download(context.Background(), srv.URL+"/file")
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if !bytes.Equal(data, payload) {
+		t.Error("payload mismatch")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("hedged download took %v, want well under the stalled request", elapsed)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("server saw %d requests, want 2", n)
+	}
+}
+
+func TestWithDownloadLimit(t *testing.T) {
+	payload := bytes.Repeat([]byte{0x00, 0xCC}, 256)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(payload)
+	}))
+	defer srv.Close()
+
+	d := NewDownloader(pool.NewBufferPool(2, 1024), 1<<20, 5*time.Second, 2, 0)
+
+	if _, err := d.Download(WithDownloadLimit(context.Background(), 256), srv.URL); err == nil {
+		t.Error("expected per-request limit to reject a 512-byte file")
+	}
+	if _, err := d.Download(WithDownloadLimit(context.Background(), 1<<30), srv.URL); err != nil {
+		t.Errorf("limit above the downloader maximum should be ignored: %v", err)
+	}
+}
+
+func TestDownloadRejectsErrorPages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/empty.mp3" {
+			return
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte("<html><body><h1>Link expired</h1></body></html>"))
+	}))
+	defer srv.Close()
+
+	d := NewDownloader(pool.NewBufferPool(2, 1024), 1<<20, 5*time.Second, 2, 0)
+
+	if _, err := d.Download(context.Background(), srv.URL+"/a.mp3"); !errors.Is(err, ErrContentMismatch) {
+		t.Errorf("error page: err = %v", err)
+	}
+	if _, err := d.Download(context.Background(), srv.URL+"/empty.mp3"); !errors.Is(err, ErrEmptyDownload) {
+		t.Errorf("empty body: err = %v", err)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	for msg, want := range map[string]bool{
+		"download failed: HTTP 503":             true,
+		"download failed: HTTP 429":             true,
+		"download failed: HTTP 404":             false,
+		"read failed: connection reset by peer": true,
+		"all 2 sources failed: a: download failed: HTTP 502\nb: download failed: HTTP 404": true,
+		"file too large: 10 bytes (max: 5)":                                                false,
+	} {
+		if got := IsTransient(errors.New(msg)); got != want {
+			t.Errorf("IsTransient(%q) = %v, want %v", msg, got, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Duration check modes
+const (
+	DurationCheckOff  = "off"  // Durations are not compared
+	DurationCheckWarn = "warn" // Mismatches are logged and counted
+	DurationCheckFail = "fail" // Mismatches fail the conversion
+)
+
+// DurationCheck compares input and output durations of audio and video
+// conversions, catching outputs truncated or padded by the filter pipeline
+type DurationCheck struct {
+	Mode      string        // off, warn or fail
+	Tolerance time.Duration // Allowed difference
+	Percent   float64       // Allowed difference relative to the input; the larger tolerance wins
+}
+
+// ParseDurationCheckMode validates a DURATION_CHECK value
+func ParseDurationCheckMode(mode string) (string, error) {
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case "":
+		return DurationCheckOff, nil
+	case DurationCheckOff, DurationCheckWarn, DurationCheckFail:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid duration check mode %q (want off, warn or fail)", mode)
+}
+
+// SetDurationCheck compares input and output durations after each Process
+// Call before the converter is used
+func (b *baseConverter) SetDurationCheck(check DurationCheck) {
+	b.durationCheck = check
+}
+
+// checkDuration probes input and output and reports an output whose duration
+// differs from the expected one by more than the tolerance
+func (b *baseConverter) checkDuration(ctx context.Context, inputData []byte, outputPath string, opts ProcessOptions) error {
+	check := b.durationCheck
+	if check.Mode == "" || check.Mode == DurationCheckOff {
+		return nil
+	}
+
+	input, err := newIntermediate(b.scratchDir(ctx), "duration-check-*", inputData)
+	if err != nil {
+		convertLog.Warnf("⚠️  Duration check skipped: %v", err)
+		return nil
+	}
+	defer input.Close()
+
+	in, err := ProbeMedia(ctx, input.Path())
+	if err != nil || in.DurationSeconds <= 0 {
+		convertLog.Debugf("Duration check skipped: input duration unknown")
+		return nil
+	}
+	out, err := ProbeMedia(ctx, outputPath)
+	if err != nil {
+		convertLog.Warnf("⚠️  Duration check skipped: %v", err)
+		return nil
+	}
+
+	mismatch := durationMismatch(in.DurationSeconds, out.DurationSeconds, opts, check)
+	if mismatch == nil {
+		return nil
+	}
+	b.durationMismatches.Add(1)
+	if check.Mode == DurationCheckFail {
+		b.recordFailure()
+		return mismatch
+	}
+	convertLog.Warnf("⚠️  %v", mismatch)
+	return nil
+}
+
+// durationMismatch returns an error when out is outside the tolerance around
+// the duration expected for in. Silence trimming only shortens the audio, so
+// with it just an output longer than expected is a mismatch
+func durationMismatch(in, out float64, opts ProcessOptions, check DurationCheck) error {
+	expected := in
+	if opts.hasSpeedChange() {
+		expected = in / opts.Speed
+	}
+	tolerance := math.Max(check.Tolerance.Seconds(), expected*check.Percent/100)
+
+	diff := out - expected
+	if opts.TrimSilence && diff < 0 {
+		return nil
+	}
+	if math.Abs(diff) <= tolerance {
+		return nil
+	}
+	return fmt.Errorf("%w: input %.3fs, output %.3fs, expected %.3fs ±%.3fs", ErrDurationMismatch, in, out, expected, tolerance)
+}
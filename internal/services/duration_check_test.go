@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDurationMismatch(t *testing.T) {
+	check := DurationCheck{Tolerance: 500 * time.Millisecond, Percent: 0.5}
+	tests := []struct {
+		name     string
+		in, out  float64
+		opts     ProcessOptions
+		mismatch bool
+	}{
+		{"equal", 10, 10, ProcessOptions{}, false},
+		{"delay within tolerance", 10, 10.05, ProcessOptions{}, false},
+		{"truncated", 10, 7, ProcessOptions{}, true},
+		{"padded", 10, 11, ProcessOptions{}, true},
+		{"percent tolerance on long input", 3600, 3610, ProcessOptions{}, false},
+		{"speed change", 10, 8, ProcessOptions{Speed: 1.25}, false},
+		{"speed ignored", 10, 10, ProcessOptions{Speed: 1.25}, true},
+		{"trim shortens", 10, 6, ProcessOptions{TrimSilence: true}, false},
+		{"trim cannot lengthen", 10, 12, ProcessOptions{TrimSilence: true}, true},
+	}
+	for _, tt := range tests {
+		err := durationMismatch(tt.in, tt.out, tt.opts, check)
+		if (err != nil) != tt.mismatch {
+			t.Errorf("%s: err = %v, want mismatch %v", tt.name, err, tt.mismatch)
+		}
+		if err != nil && FailureClass(err) != "duration_mismatch" {
+			t.Errorf("%s: class = %s", tt.name, FailureClass(err))
+		}
+	}
+}
+
+func TestParseDurationCheckMode(t *testing.T) {
+	for in, want := range map[string]string{"": "off", "WARN": "warn", " fail ": "fail"} {
+		if got, err := ParseDurationCheckMode(in); err != nil || got != want {
+			t.Errorf("ParseDurationCheckMode(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseDurationCheckMode("strict"); err == nil {
+		t.Error("accepted invalid mode")
+	}
+}
+
+func TestCheckDurationModes(t *testing.T) {
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		t.Skip("ffprobe not available")
+	}
+	input := makeSineWAV(2, 8000)
+	truncated := filepath.Join(t.TempDir(), "out.wav")
+	if err := os.WriteFile(truncated, makeSineWAV(1, 8000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &baseConverter{mediaType: "audio"}
+	b.SetDurationCheck(DurationCheck{Mode: DurationCheckWarn, Tolerance: 100 * time.Millisecond})
+	if err := b.checkDuration(context.Background(), input, truncated, ProcessOptions{}); err != nil {
+		t.Fatalf("warn mode failed: %v", err)
+	}
+
+	b.SetDurationCheck(DurationCheck{Mode: DurationCheckFail, Tolerance: 100 * time.Millisecond})
+	err := b.checkDuration(context.Background(), input, truncated, ProcessOptions{})
+	if !errors.Is(err, ErrDurationMismatch) {
+		t.Fatalf("fail mode err = %v", err)
+	}
+	if stats := b.GetStats(); stats.DurationMismatches != 2 || stats.FailedConversions != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	mathrand "math/rand"
+)
+
+// encoderIdentity is the set of encoder strings one real tool chain writes.
+// An output gets a whole identity, so its tags stay consistent with each
+// other instead of mixing e.g. an iTunes TSSE with a HandBrake handler
+type encoderIdentity struct {
+	Encoder      string // Container tag: MP4 ©too, ID3 TSSE, WAV ISFT
+	AudioEncoder string // Audio stream tag: the MP3 LAME header version, Ogg ENCODER comment ("" = none)
+	VideoHandler string // MP4 video track handler name
+	AudioHandler string // MP4 audio track handler name
+	Vendor       string // Ogg Opus/Vorbis vendor string
+}
+
+// videoIdentities replace ffmpeg's Lavf encoder and handler tags in MP4 outputs
+var videoIdentities = []encoderIdentity{
+	{Encoder: "HandBrake 1.7.3 2024021000", VideoHandler: "VideoHandler", AudioHandler: "SoundHandler"},
+	{Encoder: "HandBrake 1.6.1 2023012300", VideoHandler: "VideoHandler", AudioHandler: "SoundHandler"},
+	{Encoder: "Google", VideoHandler: "VideoHandle", AudioHandler: "SoundHandle"},
+	{Encoder: "Adobe Premiere Pro 2024.0 (Windows)", VideoHandler: "Mainconcept Video Media Handler", AudioHandler: "Mainconcept MP4 Sound Media Handler"},
+	{Encoder: "iMovie 10.4", VideoHandler: "Core Media Video", AudioHandler: "Core Media Audio"},
+}
+
+// audioIdentities are the identities per audio output format
+var audioIdentities = map[string][]encoderIdentity{
+	"mp3": {
+		{Encoder: "LAME 64bits version 3.100 (http://lame.sf.net)", AudioEncoder: "LAME3.100"},
+		{Encoder: "Audacity 3.4.2", AudioEncoder: "LAME3.100"},
+		{Encoder: "Adobe Audition 23.6 (Windows)", AudioEncoder: "LAME3.99r"},
+	},
+	"m4a": {
+		{Encoder: "iTunes 12.12.9.4", AudioHandler: "Core Media Audio"},
+		{Encoder: "GarageBand 10.4.8", AudioHandler: "Core Media Audio"},
+		{Encoder: "Google", AudioHandler: "SoundHandle"},
+	},
+	"opus": {
+		{Vendor: "libopus 1.3.1", AudioEncoder: "opusenc from opus-tools 0.2"},
+		{Vendor: "libopus 1.4", AudioEncoder: "opusenc from opus-tools 0.2"},
+		{Vendor: "libopus 1.3.1"},
+		{Vendor: "libopus 1.5.2"},
+	},
+	"ogg": {
+		{Vendor: "Xiph.Org libVorbis I 20200704 (Reducing Environment)"},
+		{Vendor: "Xiph.Org libVorbis I 20180316 (Now 100% fewer shells)"},
+		{Vendor: "Xiph.Org libVorbis I 20150105 (⛄⛄⛄⛄)"},
+	},
+	"wav": {
+		{Encoder: "Adobe Audition 23.6 (Windows)"},
+		{Encoder: "Sound Forge Pro 16.1"},
+		{Encoder: "Logic Pro 10.8.1"},
+	},
+}
+
+// SetRandomizeEncoderTags replaces ffmpeg's Lavf/Lavc encoder, handler and
+// vendor strings with those of a randomly picked real tool chain. Call
+// before the converter is used
+func (b *baseConverter) SetRandomizeEncoderTags(enabled bool) {
+	b.randomizeEncoderTags = enabled
+}
+
+// pickIdentity returns a random identity from pool (zero when pool is empty)
+func pickIdentity(pool []encoderIdentity, rng *mathrand.Rand) encoderIdentity {
+	if len(pool) == 0 {
+		return encoderIdentity{}
+	}
+	return pool[rng.Intn(len(pool))]
+}
+
+// args returns the ffmpeg -metadata arguments setting the identity's tags.
+// ffmpeg only writes its own encoder tags where none were given. Ogg comment
+// headers are rewritten after encoding instead, see rewriteOggComments
+func (id encoderIdentity) args(hasVideo, hasAudio bool) []string {
+	var args []string
+	add := func(spec, key, value string) {
+		if value != "" {
+			args = append(args, spec, key+"="+value)
+		}
+	}
+	add("-metadata", "encoder", id.Encoder)
+	if hasVideo {
+		add("-metadata:s:v:0", "handler_name", id.VideoHandler)
+	}
+	if hasAudio {
+		add("-metadata:s:a:0", "encoder", id.AudioEncoder)
+		add("-metadata:s:a:0", "handler_name", id.AudioHandler)
+	}
+	return args
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	maxDeliveries     = 10000 // Outputs remembered for feedback, oldest forgotten first
+	maxFailureRecords = 20    // Flagged variants kept per source
+)
+
+// ErrUnknownDelivery is returned for feedback on an output the policy never
+// saw or has forgotten
+var ErrUnknownDelivery = errors.New("unknown or forgotten file")
+
+// Delivery is what an output was produced from and with
+type Delivery struct {
+	FileID   string    `json:"file_id"`
+	Source   string    `json:"source"` // SourceKey of the input
+	Tenant   string    `json:"tenant,omitempty"`
+	Level    int       `json:"level"`
+	Profile  string    `json:"profile"`
+	Strength float64   `json:"strength"`
+	Speed    float64   `json:"speed,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// FailedVariant is a delivery reported as deduplicated downstream
+type FailedVariant struct {
+	Delivery
+	Reason    string    `json:"reason,omitempty"`
+	FlaggedAt time.Time `json:"flagged_at"`
+}
+
+// SourcePolicy is the learned escalation level of one source
+type SourcePolicy struct {
+	Source    string          `json:"source"`
+	Level     int             `json:"level"`
+	Flags     int             `json:"flags"`
+	Failed    []FailedVariant `json:"failed"` // Most recent last
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// EscalationPolicy raises technique strength for sources whose variants are
+// reported as deduplicated. Level 0 runs the request as asked, level 1 turns
+// on every optional technique and each further level scales their strength
+type EscalationPolicy struct {
+	path     string
+	maxLevel int
+
+	mu         sync.Mutex
+	sources    map[string]*SourcePolicy
+	deliveries map[string]Delivery
+	order      []string // Delivery file IDs, oldest first
+}
+
+// escalationState is the persisted form of the policy
+type escalationState struct {
+	Sources    []*SourcePolicy `json:"sources"`
+	Deliveries []Delivery      `json:"deliveries"`
+}
+
+// SourceKey identifies an input per tenant by its content
+func SourceKey(tenant string, data []byte) string {
+	h := sha256.New()
+	h.Write([]byte(tenant))
+	h.Write([]byte{0})
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NewEscalationPolicy loads the policy persisted at path ("" keeps it in memory)
+func NewEscalationPolicy(path string, maxLevel int) (*EscalationPolicy, error) {
+	if maxLevel <= 0 {
+		maxLevel = 3
+	}
+	p := &EscalationPolicy{
+		path:       path,
+		maxLevel:   maxLevel,
+		sources:    make(map[string]*SourcePolicy),
+		deliveries: make(map[string]Delivery),
+	}
+	if path == "" {
+		return p, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read escalation policy: %w", err)
+	}
+	var state escalationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid escalation policy file: %w", err)
+	}
+	for _, src := range state.Sources {
+		src.Level = min(src.Level, maxLevel)
+		p.sources[src.Source] = src
+	}
+	for _, d := range state.Deliveries {
+		p.remember(d)
+	}
+	return p, nil
+}
+
+// MaxLevel is the highest escalation level
+func (p *EscalationPolicy) MaxLevel() int {
+	return p.maxLevel
+}
+
+// Level returns the escalation level new variants of source are produced at
+func (p *EscalationPolicy) Level(source string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if src, ok := p.sources[source]; ok {
+		return src.Level
+	}
+	return 0
+}
+
+// Record remembers an output so feedback on it can be attributed. Deliveries
+// are persisted with the next flag or on Close
+func (p *EscalationPolicy) Record(d Delivery) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.remember(d)
+}
+
+// remember adds d, forgetting the oldest deliveries beyond maxDeliveries; callers hold p.mu
+func (p *EscalationPolicy) remember(d Delivery) {
+	if _, ok := p.deliveries[d.FileID]; !ok {
+		p.order = append(p.order, d.FileID)
+	}
+	p.deliveries[d.FileID] = d
+	for len(p.order) > maxDeliveries {
+		delete(p.deliveries, p.order[0])
+		p.order = p.order[1:]
+	}
+}
+
+// Delivery returns the recorded delivery of fileID
+func (p *EscalationPolicy) Delivery(fileID string) (Delivery, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	d, ok := p.deliveries[fileID]
+	return d, ok
+}
+
+// Flag records that the output fileID was deduplicated downstream and
+// escalates its source one level above the level that output was made at.
+// Flags on outputs made below the current level don't escalate again, so a
+// batch flagged at once raises the level only once
+func (p *EscalationPolicy) Flag(fileID, reason string) (SourcePolicy, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	d, ok := p.deliveries[fileID]
+	if !ok {
+		return SourcePolicy{}, false, ErrUnknownDelivery
+	}
+
+	src, ok := p.sources[d.Source]
+	if !ok {
+		src = &SourcePolicy{Source: d.Source}
+		p.sources[d.Source] = src
+	}
+	now := time.Now().UTC()
+	src.Flags++
+	src.UpdatedAt = now
+	src.Failed = append(src.Failed, FailedVariant{Delivery: d, Reason: reason, FlaggedAt: now})
+	if len(src.Failed) > maxFailureRecords {
+		src.Failed = src.Failed[len(src.Failed)-maxFailureRecords:]
+	}
+
+	escalated := false
+	if d.Level >= src.Level && src.Level < p.maxLevel {
+		src.Level = d.Level + 1
+		escalated = true
+		convertLog.Infof("📈 Escalated source %s to level %d after %d flags", d.Source[:12], src.Level, src.Flags)
+	}
+
+	if err := p.persist(); err != nil {
+		convertLog.Warnf("⚠️  Failed to persist escalation policy: %v", err)
+	}
+	return *src, escalated, nil
+}
+
+// Sources returns every source with feedback, most recently updated first
+func (p *EscalationPolicy) Sources() []SourcePolicy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	list := make([]SourcePolicy, 0, len(p.sources))
+	for _, src := range p.sources {
+		list = append(list, *src)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UpdatedAt.After(list[j].UpdatedAt) })
+	return list
+}
+
+// Reset forgets the learned level of source
+func (p *EscalationPolicy) Reset(source string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.sources[source]; !ok {
+		return false
+	}
+	delete(p.sources, source)
+	if err := p.persist(); err != nil {
+		convertLog.Warnf("⚠️  Failed to persist escalation policy: %v", err)
+	}
+	return true
+}
+
+// Close persists deliveries recorded since the last flag. Later changes stay
+// in memory, so a process handing over to its upgrade never overwrites the file
+func (p *EscalationPolicy) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := p.persist()
+	p.path = ""
+	return err
+}
+
+// persist atomically writes the policy to its file; callers hold p.mu
+func (p *EscalationPolicy) persist() error {
+	if p.path == "" {
+		return nil
+	}
+
+	state := escalationState{
+		Sources:    make([]*SourcePolicy, 0, len(p.sources)),
+		Deliveries: make([]Delivery, 0, len(p.order)),
+	}
+	for _, src := range p.sources {
+		state.Sources = append(state.Sources, src)
+	}
+	for _, id := range p.order {
+		state.Deliveries = append(state.Deliveries, p.deliveries[id])
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return err
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.path)
+}
+
+// Escalate returns profile p strengthened to level: every optional
+// technique from level 1, their amplitudes scaled by the level from level 2
+func Escalate(p Profile, level int) Profile {
+	if level <= 0 {
+		return p
+	}
+	p.AudioTimeStretch = true
+	p.AudioPhaseEQ = true
+	p.ImageMicroWarp = true
+	p.ImageChromaNoise = true
+	p.ImageWhiteBalance = true
+	p.VideoGammaDither = true
+	p.VideoAudioOffset = true
+	if level >= 2 {
+		p.Strength = float64(level)
+	}
+	return p
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEscalationPolicyFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "escalation.json")
+	p, err := NewEscalationPolicy(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := SourceKey("", []byte("input"))
+	deliver := func(fileID string) {
+		p.Record(Delivery{FileID: fileID, Source: source, Level: p.Level(source), Profile: "standard", At: time.Now()})
+	}
+
+	if _, _, err := p.Flag("missing", ""); !errors.Is(err, ErrUnknownDelivery) {
+		t.Fatalf("Flag(missing) = %v", err)
+	}
+
+	// Two variants of the same batch flagged together escalate once
+	deliver("a")
+	deliver("b")
+	if src, escalated, err := p.Flag("a", "dedup"); err != nil || !escalated || src.Level != 1 {
+		t.Fatalf("Flag(a) = %+v, %v, %v", src, escalated, err)
+	}
+	if src, escalated, _ := p.Flag("b", ""); escalated || src.Level != 1 || src.Flags != 2 {
+		t.Fatalf("Flag(b) = %+v, %v", src, escalated)
+	}
+
+	// A variant made at the new level escalates again, up to the maximum
+	deliver("c")
+	if src, _, _ := p.Flag("c", ""); src.Level != 2 {
+		t.Fatalf("level after c = %d", src.Level)
+	}
+	deliver("d")
+	if src, escalated, _ := p.Flag("d", ""); escalated || src.Level != 2 {
+		t.Fatalf("Flag(d) = %+v, %v", src, escalated)
+	}
+
+	// Another tenant's copy of the same bytes is a different source
+	if level := p.Level(SourceKey("acme", []byte("input"))); level != 0 {
+		t.Errorf("other tenant level = %d", level)
+	}
+
+	// Levels, failures and deliveries survive a restart
+	deliver("e")
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewEscalationPolicy(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level := reloaded.Level(source); level != 2 {
+		t.Errorf("reloaded level = %d", level)
+	}
+	if _, ok := reloaded.Delivery("e"); !ok {
+		t.Error("delivery recorded before Close not persisted")
+	}
+	if sources := reloaded.Sources(); len(sources) != 1 || len(sources[0].Failed) != 4 || sources[0].Failed[0].Reason != "dedup" {
+		t.Errorf("sources = %+v", sources)
+	}
+
+	if !reloaded.Reset(source) || reloaded.Level(source) != 0 {
+		t.Error("Reset did not forget the level")
+	}
+}
+
+func TestEscalate(t *testing.T) {
+	base, _ := LookupProfile("standard")
+	if p := Escalate(base, 0); p != base {
+		t.Errorf("level 0 changed the profile: %+v", p)
+	}
+
+	p := Escalate(base, 1)
+	if len(p.Techniques()) != len(TechniqueNames()) || p.strength() != 1 {
+		t.Errorf("level 1 = %+v", p)
+	}
+	if p := Escalate(base, 3); p.strength() != 3 || p.Name != base.Name {
+		t.Errorf("level 3 = %+v", p)
+	}
+}
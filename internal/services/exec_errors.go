@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"fingerprint-converter/internal/chaos"
+)
+
+// Failure classes parsed from external tool stderr, usable with errors.Is
+var (
+	ErrInvalidInput     = errors.New("invalid or corrupt input data")
+	ErrUnsupportedCodec = errors.New("unsupported codec or format")
+	ErrDiskFull         = errors.New("no space left on device")
+	ErrToolFailed       = errors.New("conversion tool failed")
+	ErrUnplayableOutput = errors.New("output does not decode")
+	ErrDurationMismatch = errors.New("output duration differs from input")
+)
+
+const (
+	// stderrCaptureLimit bounds how much stderr is kept in memory (the tail wins)
+	stderrCaptureLimit = 64 * 1024
+	// stderrReportLines and stderrReportBytes bound the tail surfaced in errors
+	stderrReportLines = 3
+	stderrReportBytes = 512
+)
+
+// stderrSignatures map known stderr messages to failure classes, checked in order
+var stderrSignatures = []struct {
+	pattern string
+	kind    error
+}{
+	{"no space left on device", ErrDiskFull},
+	{"disk quota exceeded", ErrDiskFull},
+	{"unknown encoder", ErrUnsupportedCodec},
+	{"unknown decoder", ErrUnsupportedCodec},
+	{"decoder not found", ErrUnsupportedCodec},
+	{"encoder not found", ErrUnsupportedCodec},
+	{"not supported", ErrUnsupportedCodec},
+	{"unsupported codec", ErrUnsupportedCodec},
+	{"invalid data found when processing input", ErrInvalidInput},
+	{"moov atom not found", ErrInvalidInput},
+	{"could not find codec parameters", ErrInvalidInput},
+	{"end of file", ErrInvalidInput},
+	{"damaged", ErrInvalidInput},
+	{"not a pdf file", ErrInvalidInput},
+}
+
+// absPathRe matches absolute paths so temp/cache locations don't leak into responses
+var absPathRe = regexp.MustCompile(`/[^\s:'",]+`)
+
+// ExecError is returned when ffmpeg/qpdf/etc. exit with an error
+// Kind is one of the Err* classes; Tail is a short sanitized stderr excerpt
+type ExecError struct {
+	Tool string
+	Kind error
+	Tail string
+	Err  error
+}
+
+func (e *ExecError) Error() string {
+	if e.Tail == "" {
+		return fmt.Sprintf("%s: %v (%v)", e.Tool, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s: %v: %s", e.Tool, e.Kind, e.Tail)
+}
+
+// Unwrap exposes both the failure class and the underlying exec error
+func (e *ExecError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// injectedFault returns the failure chaos mode injects into a run of tool, or nil
+func injectedFault(tool string) *ExecError {
+	switch {
+	case chaos.Hit(chaos.DiskFull):
+		return &ExecError{Tool: tool, Kind: ErrDiskFull, Tail: "No space left on device", Err: chaos.ErrInjected}
+	case chaos.Hit(chaos.ToolFailure):
+		return &ExecError{Tool: tool, Kind: ErrToolFailed, Err: chaos.ErrInjected}
+	}
+	return nil
+}
+
+// FailureClasses lists every class FailureClass returns
+var FailureClasses = [...]string{"invalid_input", "unsupported_codec", "disk_full", "timeout", "tool_failed", "unplayable_output", "duration_mismatch", "other"}
+
+// FailureClass names the failure class of err for error reports:
+// invalid_input, unsupported_codec, disk_full, tool_failed, timeout,
+// unplayable_output, duration_mismatch or other
+func FailureClass(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidInput):
+		return "invalid_input"
+	case errors.Is(err, ErrUnsupportedCodec):
+		return "unsupported_codec"
+	case errors.Is(err, ErrDiskFull):
+		return "disk_full"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, ErrToolFailed):
+		return "tool_failed"
+	case errors.Is(err, ErrUnplayableOutput):
+		return "unplayable_output"
+	case errors.Is(err, ErrDurationMismatch):
+		return "duration_mismatch"
+	default:
+		return "other"
+	}
+}
+
+// newExecError classifies a failed command from its captured stderr
+func newExecError(tool string, err error, stderr *cappedBuffer) *ExecError {
+	text := stderr.String()
+	lower := strings.ToLower(text)
+
+	kind := ErrToolFailed
+	for _, sig := range stderrSignatures {
+		if strings.Contains(lower, sig.pattern) {
+			kind = sig.kind
+			break
+		}
+	}
+
+	return &ExecError{
+		Tool: tool,
+		Kind: kind,
+		Tail: stderrTail(text),
+		Err:  err,
+	}
+}
+
+// stderrTail returns the last non-empty lines with absolute paths reduced to file names
+func stderrTail(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > stderrReportLines {
+		lines = lines[len(lines)-stderrReportLines:]
+	}
+
+	tail := strings.Join(lines, " | ")
+	tail = absPathRe.ReplaceAllStringFunc(tail, filepath.Base)
+	if len(tail) > stderrReportBytes {
+		tail = "..." + tail[len(tail)-stderrReportBytes:]
+	}
+	return tail
+}
+
+// cappedBuffer is an io.Writer that keeps only the last limit bytes written
+type cappedBuffer struct {
+	limit int
+	buf   []byte
+}
+
+func newCappedBuffer() *cappedBuffer {
+	return &cappedBuffer{limit: stderrCaptureLimit}
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(p) >= b.limit {
+		p = p[len(p)-b.limit:]
+		b.buf = append(b.buf[:0], p...)
+		return n, nil
+	}
+	if overflow := len(b.buf) + len(p) - b.limit; overflow > 0 {
+		b.buf = append(b.buf[:0], b.buf[overflow:]...)
+	}
+	b.buf = append(b.buf, p...)
+	return n, nil
+}
+
+func (b *cappedBuffer) String() string {
+	return string(b.buf)
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"fingerprint-converter/internal/chaos"
+)
+
+func TestNewExecErrorClassifies(t *testing.T) {
+	tests := []struct {
+		stderr string
+		kind   error
+		class  string
+	}{
+		{"[mov,mp4] moov atom not found\n/tmp/cache/abc.input.mp4: Invalid data found when processing input\n", ErrInvalidInput, "invalid_input"},
+		{"Unknown encoder 'libfoo'\n", ErrUnsupportedCodec, "unsupported_codec"},
+		{"av_interleaved_write_frame(): No space left on device\n", ErrDiskFull, "disk_full"},
+		{"something unexpected\n", ErrToolFailed, "tool_failed"},
+	}
+
+	for _, tt := range tests {
+		buf := newCappedBuffer()
+		buf.Write([]byte(tt.stderr))
+		err := newExecError("ffmpeg", &exec.ExitError{}, buf)
+		if !errors.Is(err, tt.kind) {
+			t.Errorf("%q: got kind %v, want %v", tt.stderr, err.Kind, tt.kind)
+		}
+		if class := FailureClass(err); class != tt.class {
+			t.Errorf("%q: FailureClass = %q, want %q", tt.stderr, class, tt.class)
+		}
+		if strings.Contains(err.Error(), "/tmp/cache") {
+			t.Errorf("error leaks absolute path: %s", err)
+		}
+	}
+}
+
+func TestCappedBufferKeepsTail(t *testing.T) {
+	buf := &cappedBuffer{limit: 8}
+	buf.Write([]byte("0123456"))
+	buf.Write([]byte("789ab"))
+	if got := buf.String(); got != "456789ab" {
+		t.Errorf("got %q, want %q", got, "456789ab")
+	}
+	buf.Write([]byte("0123456789XYZ"))
+	if got := buf.String(); got != "56789XYZ" {
+		t.Errorf("got %q, want %q", got, "56789XYZ")
+	}
+}
+
+func TestStderrTailLimitsLines(t *testing.T) {
+	tail := stderrTail("a\nb\n\nc\nd\n")
+	if tail != "b | c | d" {
+		t.Errorf("got %q", tail)
+	}
+}
+
+func TestInjectedFaultClasses(t *testing.T) {
+	t.Cleanup(chaos.Disable)
+	if injectedFault("ffmpeg") != nil {
+		t.Fatal("fault injected without chaos mode")
+	}
+
+	chaos.Enable(chaos.Config{Percent: map[chaos.Fault]float64{chaos.DiskFull: 100}})
+	if err := injectedFault("ffmpeg"); err == nil || FailureClass(err) != "disk_full" {
+		t.Errorf("disk_full fault = %v", err)
+	}
+
+	chaos.Enable(chaos.Config{Percent: map[chaos.Fault]float64{chaos.ToolFailure: 100}})
+	err := injectedFault("qpdf")
+	if err == nil || FailureClass(err) != "tool_failed" || !errors.Is(err, chaos.ErrInjected) {
+		t.Errorf("tool_failure fault = %v", err)
+	}
+}
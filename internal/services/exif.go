@@ -0,0 +1,199 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"sort"
+	"time"
+)
+
+// EXIF tags and field types written by the converters
+const (
+	exifTagDateTime          = 0x0132
+	exifTagExifIFD           = 0x8769
+	exifTagGPSIFD            = 0x8825
+	exifTagDateTimeOriginal  = 0x9003
+	exifTagDateTimeDigitized = 0x9004
+
+	exifTypeByte     = 1
+	exifTypeASCII    = 2
+	exifTypeLong     = 4
+	exifTypeRational = 5
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// exifEntry is one IFD field; value is already big-endian encoded
+type exifEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// exifIFD is an image file directory
+type exifIFD []exifEntry
+
+func exifASCII(tag uint16, s string) exifEntry {
+	return exifEntry{tag: tag, typ: exifTypeASCII, count: uint32(len(s) + 1), value: append([]byte(s), 0)}
+}
+
+func exifLong(tag uint16, v uint32) exifEntry {
+	return exifEntry{tag: tag, typ: exifTypeLong, count: 1, value: binary.BigEndian.AppendUint32(nil, v)}
+}
+
+// exifRationals encodes numerator/denominator pairs
+func exifRationals(tag uint16, pairs ...[2]uint32) exifEntry {
+	var value []byte
+	for _, p := range pairs {
+		value = binary.BigEndian.AppendUint32(value, p[0])
+		value = binary.BigEndian.AppendUint32(value, p[1])
+	}
+	return exifEntry{tag: tag, typ: exifTypeRational, count: uint32(len(pairs)), value: value}
+}
+
+// size is the encoded length of the directory and its out-of-line values
+func (ifd exifIFD) size() int {
+	n := 2 + 12*len(ifd) + 4
+	for _, e := range ifd {
+		if len(e.value) > 4 {
+			n += len(e.value) + len(e.value)%2
+		}
+	}
+	return n
+}
+
+// encode writes the directory as if it started at offset in the TIFF data
+func (ifd exifIFD) encode(offset uint32) []byte {
+	sorted := append(exifIFD(nil), ifd...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].tag < sorted[j].tag })
+
+	var entries, data bytes.Buffer
+	dataOffset := offset + uint32(2+12*len(sorted)+4)
+	binary.Write(&entries, binary.BigEndian, uint16(len(sorted)))
+	for _, e := range sorted {
+		binary.Write(&entries, binary.BigEndian, [2]uint16{e.tag, e.typ})
+		binary.Write(&entries, binary.BigEndian, e.count)
+		if len(e.value) <= 4 {
+			var inline [4]byte
+			copy(inline[:], e.value)
+			entries.Write(inline[:])
+			continue
+		}
+		binary.Write(&entries, binary.BigEndian, dataOffset+uint32(data.Len()))
+		data.Write(e.value)
+		if len(e.value)%2 == 1 {
+			data.WriteByte(0) // Values start on word boundaries
+		}
+	}
+	entries.Write([]byte{0, 0, 0, 0}) // No next IFD
+	entries.Write(data.Bytes())
+	return entries.Bytes()
+}
+
+// encodeEXIF returns big-endian TIFF data with ifd0 and, when not empty, the
+// Exif and GPS sub-directories it points to
+func encodeEXIF(ifd0, exif, gps exifIFD) []byte {
+	ifd0 = append(exifIFD(nil), ifd0...)
+	// Pointers are fixed size, so sub-directory offsets are known once they are added
+	if len(exif) > 0 {
+		ifd0 = append(ifd0, exifLong(exifTagExifIFD, 0))
+	}
+	if len(gps) > 0 {
+		ifd0 = append(ifd0, exifLong(exifTagGPSIFD, 0))
+	}
+	offset := uint32(8 + ifd0.size())
+	for i, e := range ifd0 {
+		switch e.tag {
+		case exifTagExifIFD:
+			ifd0[i] = exifLong(e.tag, offset)
+			offset += uint32(exif.size())
+		case exifTagGPSIFD:
+			ifd0[i] = exifLong(e.tag, offset)
+			offset += uint32(gps.size())
+		}
+	}
+
+	out := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	out = append(out, ifd0.encode(8)...)
+	if len(exif) > 0 {
+		out = append(out, exif.encode(uint32(len(out)))...)
+	}
+	if len(gps) > 0 {
+		out = append(out, gps.encode(uint32(len(out)))...)
+	}
+	return out
+}
+
+// exifTimeIFDs returns the IFD0 and Exif fields dating a picture at t
+func exifTimeIFDs(t time.Time) (ifd0, exif exifIFD) {
+	stamp := t.Format("2006:01:02 15:04:05")
+	ifd0 = exifIFD{exifASCII(exifTagDateTime, stamp)}
+	exif = exifIFD{
+		exifASCII(exifTagDateTimeOriginal, stamp),
+		exifASCII(exifTagDateTimeDigitized, stamp),
+	}
+	return ifd0, exif
+}
+
+// embedEXIF inserts TIFF data as an EXIF block into a JPEG (APP1 segment) or
+// PNG (eXIf chunk). Other formats and malformed data are returned unchanged
+func embedEXIF(data []byte, format string, tiff []byte) ([]byte, bool) {
+	switch format {
+	case "jpeg":
+		return embedJPEGEXIF(data, tiff)
+	case "png":
+		return embedPNGEXIF(data, tiff)
+	}
+	return data, false
+}
+
+func embedJPEGEXIF(data, tiff []byte) ([]byte, bool) {
+	payload := len(exifHeader) + len(tiff)
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 || payload+2 > 0xFFFF {
+		return data, false
+	}
+
+	// After a JFIF APP0 segment, which readers expect first
+	at := 2
+	if data[2] == 0xFF && data[3] == 0xE0 && len(data) >= 6 {
+		at = 4 + int(binary.BigEndian.Uint16(data[4:]))
+		if at > len(data) {
+			return data, false
+		}
+	}
+
+	segment := make([]byte, 0, 4+payload)
+	segment = append(segment, 0xFF, 0xE1)
+	segment = binary.BigEndian.AppendUint16(segment, uint16(payload+2))
+	segment = append(segment, exifHeader...)
+	segment = append(segment, tiff...)
+
+	out := make([]byte, 0, len(data)+len(segment))
+	out = append(out, data[:at]...)
+	out = append(out, segment...)
+	out = append(out, data[at:]...)
+	return out, true
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+func embedPNGEXIF(data, tiff []byte) ([]byte, bool) {
+	// The IHDR chunk is always first and 13 bytes long
+	at := len(pngSignature) + 8 + 13 + 4
+	if len(data) < at || !bytes.HasPrefix(data, pngSignature) || string(data[12:16]) != "IHDR" {
+		return data, false
+	}
+
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(tiff)))
+	chunk = append(chunk, "eXIf"...)
+	chunk = append(chunk, tiff...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	out := make([]byte, 0, len(data)+len(chunk))
+	out = append(out, data[:at]...)
+	out = append(out, chunk...)
+	out = append(out, data[at:]...)
+	return out, true
+}
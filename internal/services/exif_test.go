@@ -0,0 +1,80 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+)
+
+// exifField returns the ASCII value of tag in the directory at offset of
+// big-endian TIFF data, following the Exif IFD pointer
+func exifField(t *testing.T, tiff []byte, offset uint32, tag uint16) string {
+	t.Helper()
+	n := int(binary.BigEndian.Uint16(tiff[offset:]))
+	for i := 0; i < n; i++ {
+		e := tiff[int(offset)+2+12*i:]
+		switch binary.BigEndian.Uint16(e) {
+		case tag:
+			count := binary.BigEndian.Uint32(e[4:])
+			at := binary.BigEndian.Uint32(e[8:])
+			return string(tiff[at : at+count-1])
+		case exifTagExifIFD:
+			if v := exifField(t, tiff, binary.BigEndian.Uint32(e[8:]), tag); v != "" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+func TestEncodeEXIFTimes(t *testing.T) {
+	created := time.Date(2026, 4, 2, 8, 30, 15, 0, time.UTC)
+	ifd0, exif := exifTimeIFDs(created)
+	tiff := encodeEXIF(ifd0, exif, nil)
+
+	if !bytes.HasPrefix(tiff, []byte("MM\x00\x2a\x00\x00\x00\x08")) {
+		t.Fatalf("TIFF header = % x", tiff[:8])
+	}
+	for _, tag := range []uint16{exifTagDateTime, exifTagDateTimeOriginal, exifTagDateTimeDigitized} {
+		if got := exifField(t, tiff, 8, tag); got != "2026:04:02 08:30:15" {
+			t.Errorf("tag %#x = %q", tag, got)
+		}
+	}
+}
+
+func TestEmbedEXIF(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	var jpegBuf, pngBuf bytes.Buffer
+	jpeg.Encode(&jpegBuf, img, nil)
+	png.Encode(&pngBuf, img)
+	ifd0, exif := exifTimeIFDs(time.Now())
+	tiff := encodeEXIF(ifd0, exif, nil)
+
+	out, ok := embedEXIF(jpegBuf.Bytes(), "jpeg", tiff)
+	if !ok || !bytes.Contains(out, append([]byte{0xFF, 0xE1}, 0, byte(len(tiff)+8))) {
+		t.Fatal("APP1 segment not inserted")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("JPEG with EXIF does not decode: %v", err)
+	}
+
+	out, ok = embedEXIF(pngBuf.Bytes(), "png", tiff)
+	if !ok || !bytes.Contains(out, []byte("eXIf")) {
+		t.Fatal("eXIf chunk not inserted")
+	}
+	// The decoder checks every chunk's CRC
+	if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("PNG with EXIF does not decode: %v", err)
+	}
+
+	for _, format := range []string{"webp", "jpeg", "png"} {
+		data := []byte("RIFF\x24\x00\x00\x00WEBPVP8 ")
+		if out, ok := embedEXIF(data, format, tiff); ok || !bytes.Equal(out, data) {
+			t.Errorf("%s: unsupported data changed", format)
+		}
+	}
+}
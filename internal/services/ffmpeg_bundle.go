@@ -0,0 +1,220 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxBundleSize caps the archive download and each extracted binary
+const maxBundleSize = 512 << 20
+
+// bundledTools are the binaries taken from a bundle archive
+var bundledTools = []string{"ffmpeg", "ffprobe"}
+
+// FFmpegBundle is a pinned static ffmpeg build: a .tar.gz, .tgz or .zip
+// archive holding ffmpeg and ffprobe, anywhere in its tree
+type FFmpegBundle struct {
+	URL    string
+	SHA256 string // Hex digest of the archive, required
+}
+
+// InstallFFmpegBundle installs the bundle under dir, downloading it only when
+// no copy with the same digest is there yet, and returns the path of each tool
+func InstallFFmpegBundle(ctx context.Context, bundle FFmpegBundle, dir string) (map[string]string, error) {
+	digest := strings.ToLower(strings.TrimSpace(bundle.SHA256))
+	if len(digest) != sha256.Size*2 {
+		return nil, fmt.Errorf("a SHA-256 digest is required to install %s", bundle.URL)
+	}
+
+	// Each digest gets its own directory, so a new pin never mixes with the old one
+	installDir := filepath.Join(dir, "ffmpeg-"+digest[:16])
+	paths := make(map[string]string, len(bundledTools))
+	installed := true
+	for _, tool := range bundledTools {
+		paths[tool] = filepath.Join(installDir, tool)
+		if _, err := os.Stat(paths[tool]); err != nil {
+			installed = false
+		}
+	}
+	if installed {
+		return paths, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create toolchain directory: %w", err)
+	}
+	archive, err := downloadBundle(ctx, bundle.URL, digest, dir)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(archive)
+
+	// Extract next to the final directory and rename, so a crash never leaves a half install
+	staging, err := os.MkdirTemp(dir, "staging-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := extractBundle(archive, bundle.URL, staging); err != nil {
+		return nil, err
+	}
+	os.RemoveAll(installDir)
+	if err := os.Rename(staging, installDir); err != nil {
+		return nil, fmt.Errorf("failed to install ffmpeg bundle: %w", err)
+	}
+	return paths, nil
+}
+
+// downloadBundle saves url to a file in dir and checks its SHA-256 digest
+func downloadBundle(ctx context.Context, url, digest, dir string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid bundle URL: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download ffmpeg bundle: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download ffmpeg bundle: HTTP %d", resp.StatusCode)
+	}
+
+	f, err := os.CreateTemp(dir, "bundle-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create bundle file: %w", err)
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(resp.Body, maxBundleSize+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > maxBundleSize {
+		err = fmt.Errorf("bundle exceeds %dMB", maxBundleSize>>20)
+	}
+	if err == nil {
+		if got := hex.EncodeToString(hash.Sum(nil)); got != digest {
+			err = fmt.Errorf("bundle SHA-256 is %s, want %s", got, digest)
+		}
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to download ffmpeg bundle: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// extractBundle writes the bundled tools found in archive to dir; the
+// archive format is taken from the file name in url
+func extractBundle(archive, url, dir string) error {
+	name := strings.ToLower(path.Base(strings.SplitN(url, "?", 2)[0]))
+	var err error
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		err = extractTarGz(archive, dir)
+	case strings.HasSuffix(name, ".zip"):
+		err = extractZip(archive, dir)
+	default:
+		return fmt.Errorf("unsupported bundle format %q (want .tar.gz, .tgz or .zip)", name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract ffmpeg bundle: %w", err)
+	}
+
+	for _, tool := range bundledTools {
+		if _, err := os.Stat(filepath.Join(dir, tool)); err != nil {
+			return fmt.Errorf("ffmpeg bundle has no %s binary", tool)
+		}
+	}
+	return nil
+}
+
+func extractTarGz(archive, dir string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if err := writeBundledTool(dir, hdr.Name, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractZip(archive, dir string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, file := range zr.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = writeBundledTool(dir, file.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBundledTool writes the archive entry name to dir when it is one of the
+// bundled tools; other entries are skipped. Only the base name is used, so
+// entries cannot escape dir
+func writeBundledTool(dir, name string, r io.Reader) error {
+	tool := path.Base(name)
+	for _, want := range bundledTools {
+		if tool != want {
+			continue
+		}
+		out, err := os.OpenFile(filepath.Join(dir, want), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(out, io.LimitReader(r, maxBundleSize+1))
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil && n > maxBundleSize {
+			err = fmt.Errorf("%s exceeds %dMB", tool, maxBundleSize>>20)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// bundleArchive builds a .tar.gz holding the given files
+func bundleArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestInstallFFmpegBundle(t *testing.T) {
+	archive := bundleArchive(t, map[string]string{
+		"ffmpeg-6.1-amd64-static/ffmpeg":     "ffmpeg binary",
+		"ffmpeg-6.1-amd64-static/ffprobe":    "ffprobe binary",
+		"ffmpeg-6.1-amd64-static/readme.txt": "docs",
+	})
+	sum := sha256.Sum256(archive)
+	digest := hex.EncodeToString(sum[:])
+
+	var downloads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	bundle := FFmpegBundle{URL: server.URL + "/ffmpeg-6.1-amd64-static.tar.gz", SHA256: digest}
+	paths, err := InstallFFmpegBundle(context.Background(), bundle, dir)
+	if err != nil {
+		t.Fatalf("InstallFFmpegBundle: %v", err)
+	}
+	for tool, want := range map[string]string{"ffmpeg": "ffmpeg binary", "ffprobe": "ffprobe binary"} {
+		data, err := os.ReadFile(paths[tool])
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v", tool, data, err)
+		}
+	}
+
+	// A verified copy is reused without downloading again
+	if _, err := InstallFFmpegBundle(context.Background(), bundle, dir); err != nil || downloads.Load() != 1 {
+		t.Errorf("second install: err = %v, downloads = %d", err, downloads.Load())
+	}
+
+	bundle.SHA256 = strings.Repeat("0", 64)
+	if _, err := InstallFFmpegBundle(context.Background(), bundle, t.TempDir()); err == nil || !strings.Contains(err.Error(), "SHA-256") {
+		t.Errorf("digest mismatch accepted: %v", err)
+	}
+	bundle.SHA256 = ""
+	if _, err := InstallFFmpegBundle(context.Background(), bundle, t.TempDir()); err == nil {
+		t.Error("bundle without digest accepted")
+	}
+}
+
+func TestInstallFFmpegBundleMissingTool(t *testing.T) {
+	archive := bundleArchive(t, map[string]string{"bin/ffmpeg": "ffmpeg binary"})
+	sum := sha256.Sum256(archive)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	bundle := FFmpegBundle{URL: server.URL + "/ffmpeg.tgz", SHA256: hex.EncodeToString(sum[:])}
+	if _, err := InstallFFmpegBundle(context.Background(), bundle, t.TempDir()); err == nil || !strings.Contains(err.Error(), "ffprobe") {
+		t.Errorf("bundle without ffprobe accepted: %v", err)
+	}
+}
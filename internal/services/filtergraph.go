@@ -0,0 +1,194 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	filterNameRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+	optionKeyRe  = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// Filter is one ffmpeg filter with its options kept in insertion order
+// Values are escaped when the graph is built, so expressions such as
+// "if(gt(iw,32),iw-2,iw)" are passed in plain form
+type Filter struct {
+	name string
+	args []filterArg
+	err  error
+}
+
+type filterArg struct {
+	key   string // empty for positional arguments
+	value string
+}
+
+// NewFilter starts a filter, e.g. NewFilter("eq").Set("gamma", 1.002)
+func NewFilter(name string) *Filter {
+	f := &Filter{name: name}
+	if !filterNameRe.MatchString(name) {
+		f.err = fmt.Errorf("invalid filter name %q", name)
+	}
+	return f
+}
+
+// Set adds a named option; value may be a string, integer or float64
+// Floats use the shortest exact representation, use Setf to fix precision
+func (f *Filter) Set(key string, value any) *Filter {
+	if f.err != nil {
+		return f
+	}
+	if !optionKeyRe.MatchString(key) {
+		f.err = fmt.Errorf("%s: invalid option name %q", f.name, key)
+		return f
+	}
+	s, err := formatFilterValue(value)
+	if err != nil {
+		f.err = fmt.Errorf("%s: option %s: %w", f.name, key, err)
+		return f
+	}
+	f.args = append(f.args, filterArg{key: key, value: s})
+	return f
+}
+
+// Setf adds a named option formatted with fmt.Sprintf
+func (f *Filter) Setf(key, format string, args ...any) *Filter {
+	for _, a := range args {
+		if v, ok := a.(float64); ok && (math.IsNaN(v) || math.IsInf(v, 0)) {
+			if f.err == nil {
+				f.err = fmt.Errorf("%s: option %s: non-finite value %v", f.name, key, v)
+			}
+			return f
+		}
+	}
+	return f.Set(key, fmt.Sprintf(format, args...))
+}
+
+// Arg adds a positional option; ffmpeg requires these before named options
+func (f *Filter) Arg(value any) *Filter {
+	if f.err != nil {
+		return f
+	}
+	if n := len(f.args); n > 0 && f.args[n-1].key != "" {
+		f.err = fmt.Errorf("%s: positional option after named options", f.name)
+		return f
+	}
+	s, err := formatFilterValue(value)
+	if err != nil {
+		f.err = fmt.Errorf("%s: positional option %d: %w", f.name, len(f.args)+1, err)
+		return f
+	}
+	f.args = append(f.args, filterArg{value: s})
+	return f
+}
+
+// String renders the filter with both escaping levels applied
+func (f *Filter) String() string {
+	if len(f.args) == 0 {
+		return f.name
+	}
+	parts := make([]string, len(f.args))
+	for i, a := range f.args {
+		if a.key == "" {
+			parts[i] = escapeFilterOption(a.value)
+		} else {
+			parts[i] = a.key + "=" + escapeFilterOption(a.value)
+		}
+	}
+	return f.name + "=" + escapeFilterGraph(strings.Join(parts, ":"))
+}
+
+// FilterGraph is a linear filter chain as passed to -vf/-af
+type FilterGraph struct {
+	filters []*Filter
+}
+
+// NewFilterGraph creates a chain from filters in order (nil entries are skipped)
+func NewFilterGraph(filters ...*Filter) *FilterGraph {
+	return new(FilterGraph).Add(filters...)
+}
+
+// Add appends filters to the end of the chain
+func (g *FilterGraph) Add(filters ...*Filter) *FilterGraph {
+	for _, f := range filters {
+		if f != nil {
+			g.filters = append(g.filters, f)
+		}
+	}
+	return g
+}
+
+// Prepend inserts filters at the start of the chain, keeping their order
+func (g *FilterGraph) Prepend(filters ...*Filter) *FilterGraph {
+	head := NewFilterGraph(filters...).filters
+	g.filters = append(head, g.filters...)
+	return g
+}
+
+// Empty reports whether the chain has no filters
+func (g *FilterGraph) Empty() bool {
+	return len(g.filters) == 0
+}
+
+// Build validates the chain and renders it as a single ffmpeg argument
+func (g *FilterGraph) Build() (string, error) {
+	if g.Empty() {
+		return "", fmt.Errorf("empty filter graph")
+	}
+	parts := make([]string, len(g.filters))
+	for i, f := range g.filters {
+		if f.err != nil {
+			return "", fmt.Errorf("filter %d: %w", i+1, f.err)
+		}
+		parts[i] = f.String()
+	}
+	return strings.Join(parts, ","), nil
+}
+
+func formatFilterValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("non-finite value %v", v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+// escapeFilterOption applies the first escaping level (option values)
+func escapeFilterOption(s string) string {
+	return escapeChars(s, `\':`)
+}
+
+// escapeFilterGraph applies the second escaping level (filtergraph description)
+func escapeFilterGraph(s string) string {
+	return escapeChars(s, `\'[],;`)
+}
+
+func escapeChars(s, special string) string {
+	if !strings.ContainsAny(s, special) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
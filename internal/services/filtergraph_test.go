@@ -0,0 +1,113 @@
+package services
+
+import (
+	"math"
+	mathrand "math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilterGraphBuild(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *FilterGraph
+		want  string
+	}{
+		{
+			name:  "options in insertion order",
+			graph: NewFilterGraph(NewFilter("eq").Setf("gamma", "%.6f", 1.0015).Set("contrast", 1)),
+			want:  "eq=gamma=1.001500:contrast=1",
+		},
+		{
+			name: "commas in expressions are escaped at graph level",
+			graph: NewFilterGraph(NewFilter("crop").
+				Set("w", "if(gt(iw,32),iw-2,iw)").Set("h", "if(gt(ih,32),ih-2,ih)").
+				Set("x", "(iw-ow)/2").Set("y", "(ih-oh)/2")),
+			want: `crop=w=if(gt(iw\,32)\,iw-2\,iw):h=if(gt(ih\,32)\,ih-2\,ih):x=(iw-ow)/2:y=(ih-oh)/2`,
+		},
+		{
+			name:  "colons and quotes are escaped at both levels",
+			graph: NewFilterGraph(NewFilter("drawtext").Set("text", "it's 10:30")),
+			want:  `drawtext=text=it\\\'s 10\\:30`,
+		},
+		{
+			name:  "brackets and semicolons are escaped",
+			graph: NewFilterGraph(NewFilter("drawtext").Set("text", "[a];b")),
+			want:  `drawtext=text=\[a\]\;b`,
+		},
+		{
+			name:  "positional arguments and bare filters",
+			graph: NewFilterGraph(NewFilter("aresample").Arg(48000), NewFilter("areverse"), nil),
+			want:  "aresample=48000,areverse",
+		},
+		{
+			name: "prepend keeps order",
+			graph: NewFilterGraph(NewFilter("volume").Set("volume", 0.5)).
+				Prepend(NewFilter("silenceremove").Set("start_periods", 1), NewFilter("areverse")),
+			want: "silenceremove=start_periods=1,areverse,volume=volume=0.5",
+		},
+		{
+			name:  "integer types",
+			graph: NewFilterGraph(NewFilter("noise").Set("c1_seed", int32(7)).Set("alls", int64(3))),
+			want:  "noise=c1_seed=7:alls=3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.graph.Build()
+			if err != nil {
+				t.Fatalf("Build() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Build()\n got %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterGraphValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		graph   *FilterGraph
+		wantErr string
+	}{
+		{"empty graph", NewFilterGraph(), "empty filter graph"},
+		{"invalid filter name", NewFilterGraph(NewFilter("eq,noise")), "invalid filter name"},
+		{"invalid option name", NewFilterGraph(NewFilter("eq").Set("gam:ma", 1)), "invalid option name"},
+		{"NaN value", NewFilterGraph(NewFilter("eq").Set("gamma", math.NaN())), "non-finite"},
+		{"infinite Setf value", NewFilterGraph(NewFilter("eq").Setf("gamma", "%.6f", math.Inf(1))), "non-finite"},
+		{"unsupported type", NewFilterGraph(NewFilter("eq").Set("gamma", true)), "unsupported value type"},
+		{"positional after named", NewFilterGraph(NewFilter("unsharp").Set("lx", 3).Arg(3)), "positional option after named"},
+		{"error in later filter", NewFilterGraph(NewFilter("areverse"), NewFilter("Bad")), "filter 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.graph.Build()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Build() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTechniqueFiltersBuild(t *testing.T) {
+	rng := mathrand.New(mathrand.NewSource(1))
+	opts := ProcessOptions{Speed: 1.05, SilenceThresholdDB: -50, SilenceKeep: 200 * time.Millisecond}
+
+	graph := NewFilterGraph(microWarpFilter(rng, 1), gammaDitherFilter(rng, 1.001, 1), opts.setptsFilter()).
+		Add(chromaNoiseFilter(rng, 1)...).
+		Add(phaseEQFilter(rng, 1)...).
+		Add(opts.silenceTrimFilter()...).
+		Add(opts.atempoFilter())
+	got, err := graph.Build()
+	if err != nil {
+		t.Fatalf("Build() error: %v", err)
+	}
+	for _, want := range []string{"perspective=x0=", "eq=gamma=1.001000+", ":eval=frame", "setpts=expr=PTS/1.0500",
+		"format=yuv444p", "allpass=f=", "silenceremove=start_periods=1:start_threshold=-50.0dB:start_silence=0.200", "atempo=tempo=1.0500"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("graph missing %q:\n%s", want, got)
+		}
+	}
+}
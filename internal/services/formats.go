@@ -0,0 +1,41 @@
+package services
+
+// inputFormats lists the accepted input formats per media type, using the
+// same names as URL detection and content sniffing
+var inputFormats = map[string][]string{
+	"audio":    {"mp3", "opus", "ogg", "m4a", "wav", "aac"},
+	"image":    {"jpg", "png", "webp", "tiff", "bmp", "svg"},
+	"video":    {"mp4", "avi", "mov", "mkv", "webm", "3gp", "ts"},
+	"document": {"pdf"},
+}
+
+// outputFormatter is implemented by converters that write some inputs in a
+// different format (e.g. TIFF -> JPEG); others keep the input format
+type outputFormatter interface {
+	OutputFormat(inputFormat string) string
+}
+
+// FormatMapping pairs an accepted input format with the format it is written as
+type FormatMapping struct {
+	Input  string
+	Output string
+}
+
+// Formats returns the input/output format pairs handled for mediaType
+// Returns nil when no converter is registered for it
+func (r *Registry) Formats(mediaType string) []FormatMapping {
+	converter, ok := r.Get(mediaType)
+	if !ok {
+		return nil
+	}
+
+	formats := make([]FormatMapping, 0, len(inputFormats[mediaType]))
+	for _, input := range inputFormats[mediaType] {
+		output := input
+		if f, ok := converter.(outputFormatter); ok {
+			output = f.OutputFormat(input)
+		}
+		formats = append(formats, FormatMapping{Input: input, Output: output})
+	}
+	return formats
+}
@@ -0,0 +1,45 @@
+package services
+
+import "testing"
+
+func TestRegistryFormats(t *testing.T) {
+	registry := NewRegistry(NewImageConverter(nil, nil, "png"), NewVideoConverter(nil, nil), NewAudioConverter(nil, nil))
+
+	want := map[string]map[string]string{
+		"image": {"jpg": "jpg", "tiff": "png", "bmp": "png", "svg": "svg"},
+		"video": {"mp4": "mp4", "3gp": "mp4", "ts": "mp4", "webm": "mp4"},
+		"audio": {"mp3": "mp3", "aac": "m4a", "opus": "opus"},
+	}
+	for mediaType, outputs := range want {
+		got := make(map[string]string)
+		for _, f := range registry.Formats(mediaType) {
+			got[f.Input] = f.Output
+		}
+		for input, output := range outputs {
+			if got[input] != output {
+				t.Errorf("%s %s -> %q, want %q", mediaType, input, got[input], output)
+			}
+		}
+	}
+
+	if formats := registry.Formats("document"); formats != nil {
+		t.Errorf("unregistered media type returned %v", formats)
+	}
+}
+
+func TestProfileTechniques(t *testing.T) {
+	standard, _ := LookupProfile("standard")
+	if got := standard.Techniques(); len(got) != 0 {
+		t.Errorf("standard techniques = %v, want none", got)
+	}
+
+	color, _ := LookupProfile("color")
+	if got := color.Techniques(); len(got) != 1 || got[0] != "image_white_balance" {
+		t.Errorf("color techniques = %v, want image_white_balance", got)
+	}
+
+	paranoid, _ := LookupProfile("paranoid")
+	if got, all := paranoid.Techniques(), TechniqueNames(); len(got) != len(all) {
+		t.Errorf("paranoid techniques = %v, want all of %v", got, all)
+	}
+}
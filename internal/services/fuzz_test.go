@@ -0,0 +1,114 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"slices"
+	"testing"
+)
+
+// fuzzSeeds returns small well-formed and truncated inputs for every format
+// the byte-level parsers see, including WAV and Ogg audio
+func fuzzSeeds(f *testing.F) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	img.Set(1, 1, color.NRGBA{R: 255, A: 255})
+	var pngBuf, jpegBuf bytes.Buffer
+	png.Encode(&pngBuf, img)
+	jpeg.Encode(&jpegBuf, img, nil)
+
+	ftyp := []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isomiso2\x00\x00\x00\x08mdat")
+	ts := bytes.Repeat(append([]byte{0x47}, make([]byte, 187)...), 3)
+	ogg := []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x13OpusHead\x01\x01\x38\x01\x80\xbb\x00\x00\x00\x00\x00")
+
+	for _, seed := range [][]byte{
+		pngBuf.Bytes(),
+		pngBuf.Bytes()[:20],
+		jpegBuf.Bytes(),
+		[]byte("RIFF\x24\x00\x00\x00WEBPVP8 "),
+		makeSineWAV(0.01, 8000),
+		ogg,
+		ftyp,
+		ftyp[:12],
+		ts,
+		[]byte("%PDF-1.4\n"),
+		[]byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"/>`),
+		{},
+	} {
+		f.Add(seed)
+	}
+}
+
+func FuzzSniffMedia(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		mediaType, format := SniffMedia(data)
+		if (mediaType == "") != (format == "") {
+			t.Fatalf("SniffMedia = %q, %q", mediaType, format)
+		}
+		if mediaType != "" && !slices.Contains(inputFormats[mediaType], format) {
+			t.Fatalf("SniffMedia returned unsupported %s/%s", mediaType, format)
+		}
+	})
+}
+
+func FuzzDetectFormat(f *testing.F) {
+	fuzzSeeds(f)
+	ic := &ImageConverter{}
+	known := map[string]bool{"png": true, "jpeg": true, "webp": true, "tiff": true, "bmp": true, "svg": true, "unknown": true}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if format := ic.detectFormat(data); !known[format] {
+			t.Fatalf("detectFormat = %q", format)
+		}
+	})
+}
+
+func FuzzValidateMP4Integrity(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if validateMP4Integrity(data) == nil && !bytes.Contains(data, []byte("ftyp")) {
+			t.Fatal("accepted data without an ftyp box")
+		}
+	})
+}
+
+func FuzzValidateVideoData(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if validateVideoData(data) == nil && len(data) < 32 {
+			t.Fatalf("accepted %d bytes", len(data))
+		}
+	})
+}
+
+func FuzzValidateMPEGTS(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if validateMPEGTS(data) == nil && tsPacketSize(data) == 0 {
+			t.Fatal("accepted data without transport stream sync bytes")
+		}
+	})
+}
+
+// FuzzModifyImageLSB decodes images in-process, the one path where hostile
+// bytes are parsed by Go rather than ffmpeg
+func FuzzModifyImageLSB(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Keep iterations fast; maxLSBPixels guards the real limit
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil && cfg.Width*cfg.Height > 1<<20 {
+			t.Skip()
+		}
+		for _, format := range []string{"png", "jpeg"} {
+			out, err := modifyImageLSB(data, format)
+			if err != nil && !bytes.Equal(out, data) {
+				t.Fatalf("%s: failure changed the data", format)
+			}
+		}
+	})
+}
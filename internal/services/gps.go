@@ -0,0 +1,89 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	mathrand "math/rand"
+	"time"
+)
+
+// GPS IFD tags
+const (
+	gpsTagVersionID    = 0x0000
+	gpsTagLatitudeRef  = 0x0001
+	gpsTagLatitude     = 0x0002
+	gpsTagLongitudeRef = 0x0003
+	gpsTagLongitude    = 0x0004
+	gpsTagAltitudeRef  = 0x0005
+	gpsTagAltitude     = 0x0006
+	gpsTagTimeStamp    = 0x0007
+	gpsTagDateStamp    = 0x001D
+)
+
+// GeoRegion is a latitude/longitude bounding box in decimal degrees
+type GeoRegion struct {
+	MinLat, MaxLat float64
+	MinLon, MaxLon float64
+}
+
+// Validate checks the box is ordered and on the globe
+func (r GeoRegion) Validate() error {
+	for _, v := range []float64{r.MinLat, r.MaxLat, r.MinLon, r.MaxLon} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("coordinates must be finite")
+		}
+	}
+	if r.MinLat < -90 || r.MaxLat > 90 || r.MinLat > r.MaxLat {
+		return fmt.Errorf("latitude must satisfy -90 <= min_lat <= max_lat <= 90")
+	}
+	if r.MinLon < -180 || r.MaxLon > 180 || r.MinLon > r.MaxLon {
+		return fmt.Errorf("longitude must satisfy -180 <= min_lon <= max_lon <= 180")
+	}
+	return nil
+}
+
+// pick returns a uniformly random point in the box
+func (r GeoRegion) pick(rng *mathrand.Rand) (lat, lon float64) {
+	return r.MinLat + rng.Float64()*(r.MaxLat-r.MinLat), r.MinLon + rng.Float64()*(r.MaxLon-r.MinLon)
+}
+
+// exifGPSIFD returns the GPS fields of a phone-like fix at lat/lon with a
+// plausible altitude, timestamped at fixed when it is not zero
+func exifGPSIFD(lat, lon float64, rng *mathrand.Rand, fixed time.Time) exifIFD {
+	latRef, lonRef := "N", "E"
+	if lat < 0 {
+		latRef, lat = "S", -lat
+	}
+	if lon < 0 {
+		lonRef, lon = "W", -lon
+	}
+
+	altitude := uint32(rng.Intn(90000)) // Centimetres above sea level, up to 900m
+	ifd := exifIFD{
+		{tag: gpsTagVersionID, typ: exifTypeByte, count: 4, value: []byte{2, 2, 0, 0}},
+		exifASCII(gpsTagLatitudeRef, latRef),
+		exifRationals(gpsTagLatitude, dmsRationals(lat)...),
+		exifASCII(gpsTagLongitudeRef, lonRef),
+		exifRationals(gpsTagLongitude, dmsRationals(lon)...),
+		{tag: gpsTagAltitudeRef, typ: exifTypeByte, count: 1, value: []byte{0}},
+		exifRationals(gpsTagAltitude, [2]uint32{altitude, 100}),
+	}
+	if !fixed.IsZero() {
+		fixed = fixed.UTC()
+		ifd = append(ifd,
+			exifRationals(gpsTagTimeStamp, [2]uint32{uint32(fixed.Hour()), 1}, [2]uint32{uint32(fixed.Minute()), 1}, [2]uint32{uint32(fixed.Second()), 1}),
+			exifASCII(gpsTagDateStamp, fixed.Format("2006:01:02")),
+		)
+	}
+	return ifd
+}
+
+// dmsRationals splits non-negative decimal degrees into degrees, minutes and
+// seconds to 1/100, the precision phones write
+func dmsRationals(deg float64) [][2]uint32 {
+	hundredths := uint32(math.Round(deg * 3600 * 100))
+	d := hundredths / (3600 * 100)
+	m := hundredths / (60 * 100) % 60
+	s := hundredths % (60 * 100)
+	return [][2]uint32{{d, 1}, {m, 1}, {s, 100}}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"encoding/binary"
+	mathrand "math/rand"
+	"testing"
+	"time"
+)
+
+func TestGeoRegionPickStaysInside(t *testing.T) {
+	region := GeoRegion{MinLat: -23.7, MaxLat: -23.4, MinLon: -46.8, MaxLon: -46.4}
+	if err := region.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	rng := mathrand.New(mathrand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		lat, lon := region.pick(rng)
+		if lat < region.MinLat || lat > region.MaxLat || lon < region.MinLon || lon > region.MaxLon {
+			t.Fatalf("picked %f,%f outside %+v", lat, lon, region)
+		}
+	}
+}
+
+func TestDMSRationals(t *testing.T) {
+	got := dmsRationals(23.5505)
+	want := [][2]uint32{{23, 1}, {33, 1}, {180, 100}} // 23°33'1.80"
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("dmsRationals = %v, want %v", got, want)
+		}
+	}
+}
+
+// gpsEntry finds tag in the GPS IFD of big-endian TIFF data
+func gpsEntry(t *testing.T, tiff []byte, tag uint16) []byte {
+	t.Helper()
+	n := int(binary.BigEndian.Uint16(tiff[8:]))
+	var gpsOffset uint32
+	for i := 0; i < n; i++ {
+		e := tiff[10+12*i:]
+		if binary.BigEndian.Uint16(e) == exifTagGPSIFD {
+			gpsOffset = binary.BigEndian.Uint32(e[8:])
+		}
+	}
+	if gpsOffset == 0 {
+		t.Fatal("no GPS IFD pointer")
+	}
+	n = int(binary.BigEndian.Uint16(tiff[gpsOffset:]))
+	for i := 0; i < n; i++ {
+		e := tiff[int(gpsOffset)+2+12*i:]
+		if binary.BigEndian.Uint16(e) == tag {
+			return e[:12]
+		}
+	}
+	return nil
+}
+
+func TestExifGPSIFD(t *testing.T) {
+	rng := mathrand.New(mathrand.NewSource(1))
+	fixed := time.Date(2026, 4, 2, 8, 30, 15, 0, time.UTC)
+	ifd0, exif := exifTimeIFDs(fixed)
+	tiff := encodeEXIF(ifd0, exif, exifGPSIFD(-23.5505, -46.6333, rng, fixed))
+
+	// Short ASCII values are stored inline
+	if ref := gpsEntry(t, tiff, gpsTagLatitudeRef); ref == nil || ref[8] != 'S' {
+		t.Errorf("latitude ref entry = % x", ref)
+	}
+	if ref := gpsEntry(t, tiff, gpsTagLongitudeRef); ref == nil || ref[8] != 'W' {
+		t.Errorf("longitude ref entry = % x", ref)
+	}
+
+	lat := gpsEntry(t, tiff, gpsTagLatitude)
+	if lat == nil || binary.BigEndian.Uint32(lat[4:]) != 3 {
+		t.Fatalf("latitude entry = % x", lat)
+	}
+	at := binary.BigEndian.Uint32(lat[8:])
+	if deg := binary.BigEndian.Uint32(tiff[at:]); deg != 23 {
+		t.Errorf("latitude degrees = %d, want 23", deg)
+	}
+
+	if gpsEntry(t, tiff, gpsTagDateStamp) == nil {
+		t.Error("no GPS date stamp")
+	}
+	// The Exif IFD is still reachable next to the GPS one
+	if got := exifField(t, tiff, 8, exifTagDateTimeOriginal); got != "2026:04:02 08:30:15" {
+		t.Errorf("DateTimeOriginal = %q", got)
+	}
+}
@@ -3,19 +3,16 @@ package services
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"fmt"
 	"image"
 	"image/draw"
 	"image/jpeg"
 	"image/png"
-	"log"
 	mathrand "math/rand"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"fingerprint-converter/internal/pool"
@@ -23,27 +20,68 @@ import (
 
 // ImageConverter handles image conversion with anti-fingerprinting
 type ImageConverter struct {
-	workerPool *pool.WorkerPool
-	bufferPool *pool.BufferPool
-	mu         sync.RWMutex
-	stats      ImageStats
+	baseConverter
+	tiffOutput string // jpeg or png
 }
 
 // ImageStats tracks conversion metrics
-type ImageStats struct {
-	TotalConversions  int64
-	FailedConversions int64
-	AvgConversionTime time.Duration
-}
+type ImageStats = ConverterStats
 
 // NewImageConverter creates a new image converter
-func NewImageConverter(workerPool *pool.WorkerPool, bufferPool *pool.BufferPool) *ImageConverter {
+// tiffOutput selects the format TIFF inputs are converted to (jpeg or png)
+func NewImageConverter(workerPool *pool.WorkerPool, bufferPool *pool.BufferPool, tiffOutput string) *ImageConverter {
+	switch strings.ToLower(tiffOutput) {
+	case "png":
+		tiffOutput = "png"
+	case "jpeg", "jpg", "":
+		tiffOutput = "jpeg"
+	default:
+		convertLog.Warnf("⚠️  Unknown TIFF output format %q, using jpeg", tiffOutput)
+		tiffOutput = "jpeg"
+	}
+
 	return &ImageConverter{
-		workerPool: workerPool,
-		bufferPool: bufferPool,
+		baseConverter: baseConverter{
+			mediaType:  "image",
+			outputExt:  ".jpg", // Default, will be adjusted based on input format
+			workerPool: workerPool,
+			bufferPool: bufferPool,
+		},
+		tiffOutput: tiffOutput,
 	}
 }
 
+// Process implements Converter using the script techniques, or the
+// anti-fingerprint level of opts when set
+// The output format follows the detected input bytes, so inputFormat is unused
+func (ic *ImageConverter) Process(ctx context.Context, inputData []byte, outputPath, inputFormat string, opts ProcessOptions) (*ProcessResult, error) {
+	// SVG outputs are markup, not something ffmpeg can verify by decoding
+	finalPath := ""
+	if format := ic.detectFormat(inputData); opts.Level != "" {
+		finalPath = ic.adjustOutputPath(outputPath, levelOutputFormat(format))
+	} else if format != "svg" {
+		finalPath = ic.adjustOutputPath(outputPath, ic.OutputFormat(format))
+	}
+
+	start := time.Now()
+	err := ic.verified(ctx, opts, func(opts ProcessOptions) (string, error) {
+		if opts.Level != "" {
+			return finalPath, ic.Convert(ctx, inputData, opts.Level, outputPath)
+		}
+		return finalPath, ic.ConvertWithScriptTechniques(ctx, inputData, outputPath, opts)
+	})
+	ic.observe(inputFormat, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ProcessResult{}
+	if finalPath != "" && finalPath != outputPath {
+		result.OutputPath = finalPath
+	}
+	return result, nil
+}
+
 // Convert processes image with anti-fingerprinting
 func (ic *ImageConverter) Convert(ctx context.Context, inputData []byte, level string, outputPath string) error {
 	start := time.Now()
@@ -60,40 +98,43 @@ func (ic *ImageConverter) Convert(ctx context.Context, inputData []byte, level s
 	params := ic.getRandomizedParams(level, inputFormat)
 
 	// Build FFmpeg command with anti-fingerprinting
-	cmd := exec.CommandContext(ctx, "ffmpeg",
+	cmd := toolCommand(ctx, "ffmpeg",
 		"-hide_banner",
 		"-loglevel", "error",
 		"-i", "pipe:0", // Input from stdin
 	)
 
 	// Add anti-fingerprint filters
-	filters := []string{}
+	graph := NewFilterGraph()
 
 	// Add noise based on level and format
 	if params.addNoise {
-		filters = append(filters, fmt.Sprintf("noise=alls=%d:allf=t", params.noiseStrength))
+		graph.Add(NewFilter("noise").Set("alls", params.noiseStrength).Set("allf", "t"))
 	}
 
 	// Add subtle color adjustment (moderate, paranoid)
 	if params.colorAdjust {
-		filters = append(filters, fmt.Sprintf("eq=brightness=%.6f:contrast=%.6f",
-			params.brightness, params.contrast))
+		graph.Add(NewFilter("eq").
+			Setf("brightness", "%.6f", params.brightness).
+			Setf("contrast", "%.6f", params.contrast))
 	}
 
 	// Add slight blur (paranoid only)
 	if params.addBlur {
-		filters = append(filters, fmt.Sprintf("unsharp=3:3:%.2f", params.blurAmount))
+		graph.Add(NewFilter("unsharp").Arg(3).Arg(3).Setf("luma_amount", "%.2f", params.blurAmount))
 	}
 
-	if len(filters) > 0 {
-		cmd.Args = append(cmd.Args, "-vf", strings.Join(filters, ","))
+	if !graph.Empty() {
+		filter, err := graph.Build()
+		if err != nil {
+			ic.recordFailure()
+			return fmt.Errorf("invalid image filter: %w", err)
+		}
+		cmd.Args = append(cmd.Args, "-vf", filter)
 	}
 
 	// Determine output format (always output as input format or fallback to JPEG)
-	outputFormat := inputFormat
-	if outputFormat != "png" && outputFormat != "jpeg" && outputFormat != "jpg" && outputFormat != "webp" {
-		outputFormat = "jpeg" // Fallback to JPEG for unsupported formats
-	}
+	outputFormat := levelOutputFormat(inputFormat)
 
 	// Output codec and quality settings
 	switch outputFormat {
@@ -121,36 +162,53 @@ func (ic *ImageConverter) Convert(ctx context.Context, inputData []byte, level s
 		"pipe:1", // Output to stdout
 	)
 
-	// Set up pipes
-	cmd.Stdin = bytes.NewReader(inputData)
-	var outputBuffer bytes.Buffer
-	var errorBuffer bytes.Buffer
-	cmd.Stdout = &outputBuffer
-	cmd.Stderr = &errorBuffer
-
 	// Execute conversion
-	if err := cmd.Run(); err != nil {
-		ic.recordFailure()
-		return fmt.Errorf("ffmpeg error: %v, stderr: %s", err, errorBuffer.String())
-	}
-
-	output := outputBuffer.Bytes()
-	if len(output) == 0 {
-		ic.recordFailure()
-		return fmt.Errorf("ffmpeg produced no output")
+	output, err := ic.runFFmpeg(ctx, cmd, inputData)
+	if err != nil {
+		return err
 	}
 
 	// Write to file with correct extension
 	finalPath := ic.adjustOutputPath(outputPath, outputFormat)
-	if err := os.WriteFile(finalPath, output, 0644); err != nil {
-		ic.recordFailure()
-		return fmt.Errorf("failed to write output file: %w", err)
+	if err := ic.writeOutput(finalPath, output); err != nil {
+		return err
 	}
 
 	ic.recordSuccess(time.Since(start))
 	return nil
 }
 
+// levelOutputFormat is the format Convert writes for inputFormat: the input
+// format when ffmpeg encodes it, JPEG otherwise
+func levelOutputFormat(inputFormat string) string {
+	switch inputFormat {
+	case "png", "jpeg", "jpg", "webp":
+		return inputFormat
+	default:
+		return "jpeg"
+	}
+}
+
+// maxLSBPixels bounds images decoded in-process: a few header bytes can
+// declare dimensions that would allocate gigabytes before ffmpeg sees them
+const maxLSBPixels = 50_000_000
+
+// decodeForLSB decodes data after checking its declared dimensions
+func decodeForLSB(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode failed: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxLSBPixels {
+		return nil, fmt.Errorf("image dimensions not supported for LSB modification: %dx%d", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode failed: %w", err)
+	}
+	return img, nil
+}
+
 // modifyImageLSBWithNonce makes very small LSB changes using nonce for guaranteed uniqueness
 func modifyImageLSBWithNonce(data []byte, format string, nonce *ProcessingNonce) ([]byte, error) {
 	if len(data) == 0 {
@@ -162,9 +220,9 @@ func modifyImageLSBWithNonce(data []byte, format string, nonce *ProcessingNonce)
 		return data, fmt.Errorf("format not supported for LSB modification: %s", format)
 	}
 
-	img, _, err := image.Decode(bytes.NewReader(data))
+	img, err := decodeForLSB(data)
 	if err != nil {
-		return data, fmt.Errorf("decode failed: %w", err)
+		return data, err
 	}
 
 	bounds := img.Bounds()
@@ -235,9 +293,9 @@ func modifyImageLSB(data []byte, format string) ([]byte, error) {
 		return data, fmt.Errorf("format not supported for LSB modification: %s", format)
 	}
 
-	img, _, err := image.Decode(bytes.NewReader(data))
+	img, err := decodeForLSB(data)
 	if err != nil {
-		return data, fmt.Errorf("decode failed: %w", err)
+		return data, err
 	}
 
 	bounds := img.Bounds()
@@ -293,7 +351,8 @@ func modifyImageLSB(data []byte, format string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (ic *ImageConverter) ConvertWithScriptTechniques(ctx context.Context, inputData []byte, outputPath string) error {
+// ConvertWithScriptTechniques processes image using LSB tweaks, a safe crop and gamma micro-variation
+func (ic *ImageConverter) ConvertWithScriptTechniques(ctx context.Context, inputData []byte, outputPath string, opts ProcessOptions) error {
 	start := time.Now()
 
 	if len(inputData) == 0 {
@@ -309,6 +368,14 @@ func (ic *ImageConverter) ConvertWithScriptTechniques(ctx context.Context, input
 	// Detect format
 	inputFormat := ic.detectFormat(inputData)
 
+	// SVG is vector markup: rewrite the document instead of re-encoding pixels
+	if inputFormat == "svg" {
+		return ic.convertSVG(inputData, outputPath, nonce, !opts.NoMarker, start)
+	}
+
+	// TIFF and BMP are re-encoded to a web format
+	outputFormat := ic.OutputFormat(inputFormat)
+
 	// Attempt LSB modification for formats we support
 	// Pass nonce seed to ensure LSB modifications are unique
 	if inputFormat == "jpeg" || inputFormat == "png" {
@@ -316,7 +383,7 @@ func (ic *ImageConverter) ConvertWithScriptTechniques(ctx context.Context, input
 			inputData = modified
 		} else {
 			// Log but continue with original data
-			log.Printf("⚠️  LSB modification failed: %v", err)
+			convertLog.Warnf("⚠️  LSB modification failed: %v", err)
 		}
 	}
 
@@ -331,8 +398,8 @@ func (ic *ImageConverter) ConvertWithScriptTechniques(ctx context.Context, input
 	}
 	
 	// Use a safe min dimension constant to avoid cropping tiny images
-	cropExprW := fmt.Sprintf("if(gt(iw\\,32)\\,iw-%d\\,iw)", cropPixels*2)
-	cropExprH := fmt.Sprintf("if(gt(ih\\,32)\\,ih-%d\\,ih)", cropPixels*2)
+	cropExprW := fmt.Sprintf("if(gt(iw,32),iw-%d,iw)", cropPixels*2)
+	cropExprH := fmt.Sprintf("if(gt(ih,32),ih-%d,ih)", cropPixels*2)
 	xExpr := "(iw-ow)/2"
 	yExpr := "(ih-oh)/2"
 
@@ -345,12 +412,39 @@ func (ic *ImageConverter) ConvertWithScriptTechniques(ctx context.Context, input
 		gamma = 1.005
 	}
 	
-	vfilter := fmt.Sprintf("crop=w=%s:h=%s:x=%s:y=%s,eq=gamma=%.6f", cropExprW, cropExprH, xExpr, yExpr, gamma)
+	graph := NewFilterGraph(
+		NewFilter("crop").Set("w", cropExprW).Set("h", cropExprH).Set("x", xExpr).Set("y", yExpr),
+	)
+	// The white-balance shift (profile) replaces the gamma micro-variation
+	if !opts.Profile.ImageWhiteBalance {
+		graph.Add(NewFilter("eq").Setf("gamma", "%.6f", gamma))
+	}
+
+	// Sub-pixel perspective warp (profile) - stronger than the fixed crop alone
+	if opts.Profile.ImageMicroWarp {
+		graph.Add(microWarpFilter(localRand, opts.Profile.strength()))
+	}
+
+	// Chroma-only noise (profile) - shifts perceptual hashes without touching luma
+	if opts.Profile.ImageChromaNoise {
+		graph.Add(chromaNoiseFilter(localRand, opts.Profile.strength())...)
+	}
+
+	// Per-channel gain imbalance (profile) - shifts color histograms, grays keep their luma
+	if opts.Profile.ImageWhiteBalance {
+		graph.Add(whiteBalanceFilter(localRand, opts.Profile.strength()))
+	}
+
+	vfilter, err := graph.Build()
+	if err != nil {
+		ic.recordFailure()
+		return fmt.Errorf("invalid image filter: %w", err)
+	}
 
 	// Use standard comment metadata field (more portable than custom tags) - includes nonce for guaranteed uniqueness
 	uniqueComment := fmt.Sprintf("uid:%s", nonce.Nonce)
 
-	cmd := exec.CommandContext(ctx, "ffmpeg",
+	cmd := toolCommand(ctx, "ffmpeg",
 		"-hide_banner",
 		"-loglevel", "error",
 		"-i", "pipe:0",
@@ -358,7 +452,9 @@ func (ic *ImageConverter) ConvertWithScriptTechniques(ctx context.Context, input
 		"-q:v", "2", // High quality for JPEG
 		"-compression_level", "3",
 		"-map_metadata", "-1",
-		"-metadata", "comment="+uniqueComment,
+	)
+	cmd.Args = append(cmd.Args, outputMetadataArgs("comment", uniqueComment, opts)...)
+	cmd.Args = append(cmd.Args,
 		"-f", "image2",
 		"-threads", "0",
 		"pipe:1",
@@ -380,27 +476,44 @@ func (ic *ImageConverter) ConvertWithScriptTechniques(ctx context.Context, input
 		cmd.Args = append(cmd.Args, "-quality", "98")
 	}
 
-	cmd.Stdin = bytes.NewReader(inputData)
-	var outputBuffer bytes.Buffer
-	var errorBuffer bytes.Buffer
-	cmd.Stdout = &outputBuffer
-	cmd.Stderr = &errorBuffer
+	// Converted formats need an explicit encoder (image2 would otherwise guess from the input)
+	if outputFormat != inputFormat {
+		codec := "mjpeg"
+		if outputFormat == "png" {
+			codec = "png"
+		}
+		cmd.Args = append(cmd.Args[:len(cmd.Args)-1], "-c:v", codec, "pipe:1")
+	}
 
-	if err := cmd.Run(); err != nil {
-		ic.recordFailure()
-		return fmt.Errorf("ffmpeg error: %v, stderr: %s", err, errorBuffer.String())
+	output, err := ic.runFFmpeg(ctx, cmd, inputData)
+	if err != nil {
+		return err
 	}
 
-	output := outputBuffer.Bytes()
-	if len(output) == 0 {
-		ic.recordFailure()
-		return fmt.Errorf("ffmpeg produced no output")
+	// ffmpeg writes no EXIF, so the capture date and location are added to the encoded image
+	created, dated := ic.creationTime(localRand)
+	var ifd0, exif, gps exifIFD
+	if dated && !opts.NoMarker {
+		ifd0, exif = exifTimeIFDs(created)
+	}
+	if opts.GPSRegion != nil {
+		lat, lon := opts.GPSRegion.pick(localRand)
+		var fixed time.Time
+		if dated {
+			fixed = created
+		}
+		gps = exifGPSIFD(lat, lon, localRand, fixed)
+	}
+	if len(ifd0) > 0 || len(gps) > 0 {
+		output, _ = embedEXIF(output, outputFormat, encodeEXIF(ifd0, exif, gps))
 	}
 
-	finalPath := ic.adjustOutputPath(outputPath, inputFormat)
-	if err := os.WriteFile(finalPath, output, 0644); err != nil {
-		ic.recordFailure()
-		return fmt.Errorf("failed to write output file: %w", err)
+	finalPath := ic.adjustOutputPath(outputPath, outputFormat)
+	if err := ic.writeOutput(finalPath, output); err != nil {
+		return err
+	}
+	if dated {
+		stampFile(finalPath, created)
 	}
 
 	ic.recordSuccess(time.Since(start))
@@ -479,6 +592,11 @@ func (ic *ImageConverter) getRandomizedParams(level string, format string) image
 }
 
 func (ic *ImageConverter) detectFormat(data []byte) string {
+	return detectImageFormat(data)
+}
+
+// detectImageFormat identifies an image by its signature
+func detectImageFormat(data []byte) string {
 	if len(data) < 12 {
 		return "unknown"
 	}
@@ -498,9 +616,68 @@ func (ic *ImageConverter) detectFormat(data []byte) string {
 		return "webp"
 	}
 
+	// TIFF signatures (little and big endian)
+	if bytes.Equal(data[0:4], []byte{0x49, 0x49, 0x2A, 0x00}) || bytes.Equal(data[0:4], []byte{0x4D, 0x4D, 0x00, 0x2A}) {
+		return "tiff"
+	}
+
+	// BMP signature, checked against the headers since "BM" alone is common
+	if isBMP(data) {
+		return "bmp"
+	}
+
+	if isSVG(data) {
+		return "svg"
+	}
+
 	return "unknown"
 }
 
+// isBMP reports whether data starts with a BMP file header: the BM
+// signature, a known DIB header size at offset 14, and a file size at
+// offset 2 large enough to hold both headers
+func isBMP(data []byte) bool {
+	if len(data) < 18 || data[0] != 'B' || data[1] != 'M' {
+		return false
+	}
+	dibSize := binary.LittleEndian.Uint32(data[14:18])
+	switch dibSize {
+	case 12, 40, 52, 56, 108, 124:
+	default:
+		return false
+	}
+	return binary.LittleEndian.Uint32(data[2:6]) >= 14+dibSize
+}
+
+// OutputFormat returns the format written for inputFormat
+// TIFF follows the configured target, BMP becomes lossless PNG
+func (ic *ImageConverter) OutputFormat(inputFormat string) string {
+	switch inputFormat {
+	case "tiff":
+		return ic.tiffOutput
+	case "bmp":
+		return "png"
+	default:
+		return inputFormat
+	}
+}
+
+// convertSVG sanitizes and uniquifies an SVG document without rasterizing it
+func (ic *ImageConverter) convertSVG(inputData []byte, outputPath string, nonce *ProcessingNonce, marker bool, start time.Time) error {
+	output, err := sanitizeSVG(inputData, nonce, marker)
+	if err != nil {
+		ic.recordFailure()
+		return fmt.Errorf("svg processing failed: %w", err)
+	}
+
+	if err := ic.writeOutput(outputPath, output); err != nil {
+		return err
+	}
+
+	ic.recordSuccess(time.Since(start))
+	return nil
+}
+
 func (ic *ImageConverter) adjustOutputPath(path, format string) string {
 	ext := filepath.Ext(path)
 	base := strings.TrimSuffix(path, ext)
@@ -510,39 +687,9 @@ func (ic *ImageConverter) adjustOutputPath(path, format string) string {
 		return base + ".png"
 	case "webp":
 		return base + ".webp"
+	case "svg":
+		return base + ".svg"
 	default:
 		return base + ".jpg"
 	}
 }
-
-func (ic *ImageConverter) recordSuccess(duration time.Duration) {
-	ic.mu.Lock()
-	defer ic.mu.Unlock()
-	ic.stats.TotalConversions++
-	ic.stats.AvgConversionTime = (ic.stats.AvgConversionTime*time.Duration(ic.stats.TotalConversions-1) + duration) / time.Duration(ic.stats.TotalConversions)
-}
-
-func (ic *ImageConverter) recordFailure() {
-	ic.mu.Lock()
-	defer ic.mu.Unlock()
-	ic.stats.FailedConversions++
-}
-
-// GetStats returns current statistics
-func (ic *ImageConverter) GetStats() ImageStats {
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-	return ic.stats
-}
-
-// GetOutputExtension returns the file extension for this converter
-func (ic *ImageConverter) GetOutputExtension() string {
-	return ".jpg" // Default, will be adjusted based on input format
-}
-
-// GenerateOutputPath creates a unique output path
-func (ic *ImageConverter) GenerateOutputPath(cacheDir, deviceID, urlHash string) string {
-	timestamp := time.Now().UnixNano()
-	filename := fmt.Sprintf("%s_%s_%d%s", deviceID, urlHash[:8], timestamp, ic.GetOutputExtension())
-	return filepath.Join(cacheDir, filename)
-}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDetectFormatTIFFAndBMP(t *testing.T) {
+	ic := NewImageConverter(nil, nil, "png")
+	pad := make([]byte, 12)
+
+	tests := []struct {
+		name   string
+		header []byte
+		format string
+		output string
+	}{
+		{"tiff little endian", []byte{0x49, 0x49, 0x2A, 0x00}, "tiff", "png"},
+		{"tiff big endian", []byte{0x4D, 0x4D, 0x00, 0x2A}, "tiff", "png"},
+		{"bmp", bmpHeader(70, 40), "bmp", "png"},
+		{"bmp core header", bmpHeader(26+6, 12), "bmp", "png"},
+		{"BM text", []byte("BM is not a bitmap"), "unknown", "unknown"},
+		{"bmp unknown dib size", bmpHeader(70, 41), "unknown", "unknown"},
+		{"bmp size below headers", bmpHeader(20, 40), "unknown", "unknown"},
+		{"jpeg", []byte{0xFF, 0xD8}, "jpeg", "jpeg"},
+	}
+
+	for _, tt := range tests {
+		data := append(append([]byte{}, tt.header...), pad...)
+		if got := ic.detectFormat(data); got != tt.format {
+			t.Errorf("%s: detectFormat = %q, want %q", tt.name, got, tt.format)
+		}
+		if got := ic.OutputFormat(tt.format); got != tt.output {
+			t.Errorf("%s: outputFormat = %q, want %q", tt.name, got, tt.output)
+		}
+	}
+}
+
+// bmpHeader returns a BMP file header declaring fileSize followed by the
+// size field of a DIB header
+func bmpHeader(fileSize, dibSize uint32) []byte {
+	header := make([]byte, 18)
+	copy(header, "BM")
+	binary.LittleEndian.PutUint32(header[2:], fileSize)
+	header[10] = 14
+	binary.LittleEndian.PutUint32(header[14:], dibSize)
+	return header
+}
+
+func TestNewImageConverterTIFFOutputDefault(t *testing.T) {
+	for _, in := range []string{"", "jpg", "gif"} {
+		if got := NewImageConverter(nil, nil, in).OutputFormat("tiff"); got != "jpeg" {
+			t.Errorf("tiffOutput %q: got %q, want jpeg", in, got)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"fmt"
+	"os"
+
+	"fingerprint-converter/internal/chaos"
+)
+
+// intermediate is a scratch copy of an input that external tools (ffmpeg,
+// ffprobe, qpdf) read by path. Where supported it has no directory entry, so
+// it disappears when closed or when the process dies instead of leaking into
+// the temp directory
+type intermediate struct {
+	file  *os.File
+	path  string // Path handed to child processes
+	named bool   // path is a real directory entry, removed on Close
+}
+
+// newIntermediate writes data to a new intermediate file in dir ("" = system
+// temp dir); pattern names the file where a named file has to be used
+func newIntermediate(dir, pattern string, data []byte) (*intermediate, error) {
+	in, err := createIntermediate(dir, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create intermediate file: %w", err)
+	}
+	chaos.Delay()
+	if _, err := in.file.Write(data); err != nil {
+		in.Close()
+		return nil, fmt.Errorf("failed to write intermediate file: %w", err)
+	}
+	return in, nil
+}
+
+// Path returns the path child processes open to read the file
+func (in *intermediate) Path() string {
+	return in.path
+}
+
+// Close releases the file; unlinked files are freed by the kernel
+func (in *intermediate) Close() error {
+	err := in.file.Close()
+	if in.named {
+		os.Remove(in.path)
+	}
+	return err
+}
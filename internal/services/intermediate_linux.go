@@ -0,0 +1,34 @@
+//go:build linux
+
+package services
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// oTmpfile is O_TMPFILE (__O_TMPFILE | O_DIRECTORY); syscall does not export it
+const oTmpfile = 0x400000 | syscall.O_DIRECTORY
+
+// createIntermediate opens an anonymous file with O_TMPFILE, falling back to
+// creating a file and unlinking it immediately on filesystems without support.
+// Child processes reach it through /proc/<pid>/fd while it is open
+func createIntermediate(dir, pattern string) (*intermediate, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	f, err := os.OpenFile(dir, os.O_RDWR|oTmpfile, 0600)
+	if err != nil {
+		if f, err = os.CreateTemp(dir, pattern); err != nil {
+			return nil, err
+		}
+		os.Remove(f.Name())
+	}
+
+	return &intermediate{
+		file: f,
+		path: fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), f.Fd()),
+	}, nil
+}
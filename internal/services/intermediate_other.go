@@ -0,0 +1,15 @@
+//go:build !linux
+
+package services
+
+import "os"
+
+// createIntermediate creates a named temp file; without /proc there is no
+// portable way to hand an unlinked file to a child process
+func createIntermediate(dir, pattern string) (*intermediate, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &intermediate{file: f, path: f.Name(), named: true}, nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+func TestIntermediateLeavesNoDirectoryEntry(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte("intermediate contents")
+
+	in, err := newIntermediate(dir, "video-input-*.mp4", data)
+	if err != nil {
+		t.Fatalf("newIntermediate: %v", err)
+	}
+
+	got, err := os.ReadFile(in.Path())
+	if err != nil {
+		t.Fatalf("reading %s: %v", in.Path(), err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("contents = %q, want %q", got, data)
+	}
+
+	// External tools open the same path from a child process
+	if cat, err := exec.LookPath("cat"); err == nil {
+		out, err := exec.Command(cat, in.Path()).Output()
+		if err != nil || !bytes.Equal(out, data) {
+			t.Errorf("child process read %q, %v; want %q", out, err, data)
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if runtime.GOOS == "linux" && len(entries) != 0 {
+		t.Errorf("intermediate visible in temp dir: %v", entries)
+	}
+
+	path := in.Path()
+	if err := in.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("intermediate still reachable after Close: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("temp dir not empty after Close: %v", entries)
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Limits on caller-supplied template variables and metadata fields
+const (
+	MaxTemplateVariables = 32
+	MaxMetadataFields    = 16
+	maxVariableLength    = 256
+	maxMetadataLength    = 1024
+)
+
+var (
+	templatePlaceholder = regexp.MustCompile(`\{([A-Za-z0-9_]*)\}`)
+	variableNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,64}$`)
+	metadataKeyPattern  = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+)
+
+// ValidateVariables checks template variable names and values
+// Values may not contain braces, so an expansion never yields a new placeholder
+func ValidateVariables(vars map[string]string) error {
+	if len(vars) > MaxTemplateVariables {
+		return fmt.Errorf("at most %d variables are allowed", MaxTemplateVariables)
+	}
+	for name, value := range vars {
+		if !variableNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid variable name %q (letters, digits and _ only)", name)
+		}
+		if len(value) > maxVariableLength {
+			return fmt.Errorf("variable %s exceeds %d bytes", name, maxVariableLength)
+		}
+		if strings.ContainsAny(value, "{}") || hasControlChars(value) {
+			return fmt.Errorf("variable %s contains braces or control characters", name)
+		}
+	}
+	return nil
+}
+
+// ExpandTemplate replaces each {name} in tmpl with vars[name]
+// Unknown names are an error so typos don't silently produce literal braces
+func ExpandTemplate(tmpl string, vars map[string]string) (string, error) {
+	var missing *string
+	out := templatePlaceholder.ReplaceAllStringFunc(tmpl, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		value, ok := vars[name]
+		if !ok && missing == nil {
+			missing = &name
+		}
+		return value
+	})
+	if missing != nil {
+		return "", fmt.Errorf("unknown variable {%s}", *missing)
+	}
+	return out, nil
+}
+
+// ExpandMetadata validates metadata fields and expands variables in their values
+func ExpandMetadata(fields, vars map[string]string) (map[string]string, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	if len(fields) > MaxMetadataFields {
+		return nil, fmt.Errorf("at most %d metadata fields are allowed", MaxMetadataFields)
+	}
+
+	expanded := make(map[string]string, len(fields))
+	for key, tmpl := range fields {
+		if !metadataKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid metadata key %q (lowercase letters, digits and _)", key)
+		}
+		value, err := ExpandTemplate(tmpl, vars)
+		if err != nil {
+			return nil, fmt.Errorf("metadata %s: %w", key, err)
+		}
+		if len(value) > maxMetadataLength || hasControlChars(value) {
+			return nil, fmt.Errorf("metadata %s exceeds %d bytes or contains control characters", key, maxMetadataLength)
+		}
+		expanded[key] = value
+	}
+	return expanded, nil
+}
+
+// ValidateMetadataTemplates checks metadata keys and values before the
+// variables their placeholders refer to are known
+func ValidateMetadataTemplates(fields map[string]string) error {
+	if len(fields) > MaxMetadataFields {
+		return fmt.Errorf("at most %d metadata fields are allowed", MaxMetadataFields)
+	}
+	for key, tmpl := range fields {
+		if !metadataKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid metadata key %q (lowercase letters, digits and _)", key)
+		}
+		if len(tmpl) > maxMetadataLength || hasControlChars(tmpl) {
+			return fmt.Errorf("metadata %s exceeds %d bytes or contains control characters", key, maxMetadataLength)
+		}
+	}
+	return nil
+}
+
+// MergeMetadata expands a tenant's default fields under the request's fields,
+// which win on the same key. A default whose placeholders the request's
+// variables do not supply is left out rather than failing the request
+func MergeMetadata(defaults, fields, vars map[string]string) (map[string]string, error) {
+	expanded, err := ExpandMetadata(fields, vars)
+	if err != nil || len(defaults) == 0 {
+		return expanded, err
+	}
+
+	merged := make(map[string]string, len(defaults)+len(expanded))
+	for key, tmpl := range defaults {
+		value, err := ExpandTemplate(tmpl, vars)
+		if err != nil || len(value) > maxMetadataLength {
+			continue
+		}
+		merged[key] = value
+	}
+	for key, value := range expanded {
+		merged[key] = value
+	}
+	return merged, nil
+}
+
+// outputMetadataArgs returns the ffmpeg metadata arguments of an output: the
+// marker and the caller's fields, or with NoMarker bitexact flags so not even
+// the encoder tags ffmpeg writes by default end up in the file
+func outputMetadataArgs(markerKey, marker string, opts ProcessOptions) []string {
+	if opts.NoMarker {
+		return []string{"-fflags", "+bitexact", "-flags", "+bitexact"}
+	}
+	return metadataArgs(markerKey, marker, opts.Metadata)
+}
+
+// metadataArgs returns ffmpeg -metadata arguments for the uniqueness marker
+// under markerKey plus the caller's fields. A caller value for markerKey is
+// kept and the marker appended, so outputs always carry their uid
+func metadataArgs(markerKey, marker string, fields map[string]string) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		if key != markerKey {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	markerValue := marker
+	if value, ok := fields[markerKey]; ok && value != "" {
+		markerValue = value + " " + marker
+	}
+
+	args := []string{"-metadata", markerKey + "=" + markerValue}
+	for _, key := range keys {
+		args = append(args, "-metadata", key+"="+fields[key])
+	}
+	return args
+}
+
+// hasControlChars reports whether s contains ASCII control characters
+func hasControlChars(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
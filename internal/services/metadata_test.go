@@ -0,0 +1,145 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	vars := map[string]string{"campaign_id": "c42", "recipient": "9f1e"}
+
+	tests := []struct {
+		tmpl    string
+		want    string
+		wantErr bool
+	}{
+		{tmpl: "promo-{campaign_id}-{recipient}", want: "promo-c42-9f1e"},
+		{tmpl: "no placeholders", want: "no placeholders"},
+		{tmpl: "", want: ""},
+		{tmpl: "{campaign_id}{campaign_id}", want: "c42c42"},
+		{tmpl: "{unknown}", wantErr: true},
+		{tmpl: "{}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ExpandTemplate(tt.tmpl, vars)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ExpandTemplate(%q) error = %v, wantErr %v", tt.tmpl, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ExpandTemplate(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestValidateVariables(t *testing.T) {
+	if err := ValidateVariables(map[string]string{"campaign_id": "c42", "Recipient2": "a b"}); err != nil {
+		t.Errorf("valid variables rejected: %v", err)
+	}
+	for _, vars := range []map[string]string{
+		{"bad-name": "x"},
+		{"": "x"},
+		{"x": "{y}"},
+		{"x": "line\nbreak"},
+		{"x": strings.Repeat("a", maxVariableLength+1)},
+	} {
+		if err := ValidateVariables(vars); err == nil {
+			t.Errorf("ValidateVariables(%v) succeeded", vars)
+		}
+	}
+}
+
+func TestExpandMetadata(t *testing.T) {
+	vars := map[string]string{"campaign_id": "c42"}
+
+	got, err := ExpandMetadata(map[string]string{"comment": "campaign={campaign_id}", "artist": "Acme"}, vars)
+	if err != nil {
+		t.Fatalf("ExpandMetadata: %v", err)
+	}
+	want := map[string]string{"comment": "campaign=c42", "artist": "Acme"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExpandMetadata = %v, want %v", got, want)
+	}
+
+	for _, fields := range []map[string]string{
+		{"Comment": "x"},
+		{"comment=x": "y"},
+		{"comment": "{missing}"},
+	} {
+		if _, err := ExpandMetadata(fields, vars); err == nil {
+			t.Errorf("ExpandMetadata(%v) succeeded", fields)
+		}
+	}
+}
+
+func TestMergeMetadata(t *testing.T) {
+	defaults := map[string]string{
+		"copyright": "Acme Corp",
+		"artist":    "Acme",
+		"comment":   "campaign={campaign_id}",
+	}
+
+	got, err := MergeMetadata(defaults, map[string]string{"artist": "Guest"}, map[string]string{"campaign_id": "c42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"copyright": "Acme Corp", "artist": "Guest", "comment": "campaign=c42"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeMetadata = %v, want %v", got, want)
+	}
+
+	// A default whose variable is missing is left out; a request field is not
+	got, err = MergeMetadata(defaults, nil, nil)
+	if err != nil || got["comment"] != "" || got["copyright"] != "Acme Corp" {
+		t.Errorf("MergeMetadata without variables = %v, %v", got, err)
+	}
+	if _, err := MergeMetadata(defaults, map[string]string{"title": "{missing}"}, nil); err == nil {
+		t.Error("request field with an unknown variable accepted")
+	}
+}
+
+func TestValidateMetadataTemplates(t *testing.T) {
+	if err := ValidateMetadataTemplates(map[string]string{"comment": "campaign={campaign_id}"}); err != nil {
+		t.Errorf("template rejected: %v", err)
+	}
+	for _, fields := range []map[string]string{
+		{"Comment": "x"},
+		{"comment": "a\nb"},
+		{"comment": strings.Repeat("x", maxMetadataLength+1)},
+	} {
+		if err := ValidateMetadataTemplates(fields); err == nil {
+			t.Errorf("ValidateMetadataTemplates(%.40v) succeeded", fields)
+		}
+	}
+}
+
+func TestMetadataArgsKeepsMarker(t *testing.T) {
+	got := metadataArgs("title", "uid:abc", map[string]string{"title": "Promo", "comment": "c42", "artist": "Acme"})
+	want := []string{
+		"-metadata", "title=Promo uid:abc",
+		"-metadata", "artist=Acme",
+		"-metadata", "comment=c42",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("metadataArgs = %v, want %v", got, want)
+	}
+
+	if got := metadataArgs("comment", "uid:abc", nil); !reflect.DeepEqual(got, []string{"-metadata", "comment=uid:abc"}) {
+		t.Errorf("metadataArgs without fields = %v", got)
+	}
+}
+
+func TestOutputMetadataArgsNoMarker(t *testing.T) {
+	opts := ProcessOptions{Metadata: map[string]string{"artist": "Acme"}}
+	if got := outputMetadataArgs("title", "uid:abc", opts); !reflect.DeepEqual(got, metadataArgs("title", "uid:abc", opts.Metadata)) {
+		t.Errorf("outputMetadataArgs = %v", got)
+	}
+
+	opts.NoMarker = true
+	got := strings.Join(outputMetadataArgs("title", "uid:abc", opts), " ")
+	if strings.Contains(got, "-metadata") || !strings.Contains(got, "+bitexact") {
+		t.Errorf("outputMetadataArgs with NoMarker = %q", got)
+	}
+}
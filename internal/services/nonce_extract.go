@@ -0,0 +1,80 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// ErrNoNonce means a file carries no uid marker
+var ErrNoNonce = errors.New("no embedded nonce found")
+
+// uidMarker matches the "uid:<unix nanos>_<32 hex>" marker every converter
+// embeds: the title of audio and video, the comment of images, a comment in
+// SVG and the FPNonce object of PDFs. All are stored as plain ASCII
+var uidMarker = regexp.MustCompile(`uid:([0-9]{1,19})_([0-9a-f]{32})`)
+
+// uidMarkerMaxLen is the longest marker, the overlap kept between chunks
+const uidMarkerMaxLen = len("uid:") + 19 + 1 + 32
+
+// nonceScanChunk is how much of a file is searched at a time
+const nonceScanChunk = 1 << 20
+
+// FindNonce returns the first uid marker in data
+func FindNonce(data []byte) (*ProcessingNonce, bool) {
+	m := uidMarker.FindSubmatch(data)
+	if m == nil {
+		return nil, false
+	}
+	timestamp, err := strconv.ParseInt(string(m[1]), 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &ProcessingNonce{
+		Timestamp: timestamp,
+		Random:    string(m[2]),
+		Nonce:     fmt.Sprintf("%d_%s", timestamp, m[2]),
+	}, true
+}
+
+// ExtractNonce scans the file at path for its uid marker. Containers put
+// metadata at either end, so the whole file is read in chunks
+func ExtractNonce(path string) (*ProcessingNonce, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ScanNonce(f)
+}
+
+// ScanNonce searches r chunk by chunk for a uid marker, carrying the tail of
+// each chunk over so a marker split across two reads is still found
+func ScanNonce(r io.Reader) (*ProcessingNonce, error) {
+	buf := make([]byte, 0, nonceScanChunk+uidMarkerMaxLen)
+	chunk := make([]byte, nonceScanChunk)
+	for {
+		n, err := io.ReadFull(r, chunk)
+		buf = append(buf, chunk[:n]...)
+		if nonce, ok := FindNonce(buf); ok {
+			return nonce, nil
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrNoNonce
+		}
+		if err != nil {
+			return nil, err
+		}
+		keep := min(len(buf), uidMarkerMaxLen-1)
+		buf = append(buf[:0], buf[len(buf)-keep:]...)
+	}
+}
+
+// ProcessedAt is when the nonce was generated, i.e. when the file was processed
+func (n *ProcessingNonce) ProcessedAt() time.Time {
+	return time.Unix(0, n.Timestamp).UTC()
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestScanNonceAcrossChunks(t *testing.T) {
+	nonce := GenerateNonce()
+	marker := []byte("title=uid:" + nonce.Nonce)
+
+	// Place the marker so it straddles the first chunk boundary
+	data := append(bytes.Repeat([]byte{0xAA}, nonceScanChunk-20), marker...)
+	data = append(data, bytes.Repeat([]byte{0x55}, 1000)...)
+
+	got, err := ScanNonce(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ScanNonce: %v", err)
+	}
+	if got.Nonce != nonce.Nonce || got.Timestamp != nonce.Timestamp || !got.ProcessedAt().Equal(nonce.ProcessedAt()) {
+		t.Errorf("ScanNonce = %+v, want %+v", got, nonce)
+	}
+
+	if _, err := ScanNonce(bytes.NewReader(bytes.Repeat([]byte("uid:123_"), 1000))); !errors.Is(err, ErrNoNonce) {
+		t.Errorf("ScanNonce without marker: %v", err)
+	}
+}
+
+func TestFindNonceInConverterOutputs(t *testing.T) {
+	nonce := GenerateNonce()
+
+	pdf, err := appendNonceObject(minimalPDF(), nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg, err := sanitizeSVG([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1"/></svg>`), nonce, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{"pdf": pdf, "svg": svg} {
+		got, ok := FindNonce(data)
+		if !ok || got.Nonce != nonce.Nonce {
+			t.Errorf("%s: FindNonce = %+v, %v, want %s", name, got, ok, nonce.Nonce)
+		}
+	}
+
+	// Callers may prepend their own value to the marker field
+	args := metadataArgs("title", "uid:"+nonce.Nonce, map[string]string{"title": "Promo"})
+	if got, ok := FindNonce([]byte(strings.Join(args, " "))); !ok || got.Nonce != nonce.Nonce {
+		t.Errorf("FindNonce(%q) = %+v, %v", args, got, ok)
+	}
+}
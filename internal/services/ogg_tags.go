@@ -0,0 +1,186 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	oggHeaderLen  = 27 // Page header before the segment table
+	oggMaxSegs    = 255
+	oggScanPages  = 4 // The comment header is the second packet, on page 2
+	oggContinued  = 0x01
+	oggCRCOffset  = 22
+	oggSegsOffset = 26
+)
+
+var (
+	opusTagsMagic      = []byte("OpusTags")
+	vorbisCommentMagic = []byte("\x03vorbis")
+)
+
+// oggCRCTable is the CRC-32 of Ogg pages: polynomial 0x04c11db7, unreflected,
+// zero initial value and no final xor
+var oggCRCTable = func() (table [256]uint32) {
+	for i := range table {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+func oggCRC(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// rewriteOggComments replaces the vendor string of the Opus or Vorbis comment
+// header of an Ogg stream and sets its ENCODER comment, dropping it when
+// encoder is "". data is returned unchanged (false) when the header cannot be
+// rewritten in place, e.g. because it spans pages
+func rewriteOggComments(data []byte, vendor, encoder string) ([]byte, bool) {
+	off := 0
+	for page := 0; page < oggScanPages && off+oggHeaderLen <= len(data); page++ {
+		if !bytes.HasPrefix(data[off:], []byte("OggS")) {
+			return data, false
+		}
+		nsegs := int(data[off+oggSegsOffset])
+		bodyStart := off + oggHeaderLen + nsegs
+		if bodyStart > len(data) {
+			return data, false
+		}
+		lacing := data[off+oggHeaderLen : bodyStart]
+		bodyLen := 0
+		for _, l := range lacing {
+			bodyLen += int(l)
+		}
+		end := bodyStart + bodyLen
+		if end > len(data) {
+			return data, false
+		}
+		body := data[bodyStart:end]
+
+		// Length and segment count of the first packet starting on the page
+		packetLen, packetSegs, complete := 0, 0, false
+		for _, l := range lacing {
+			packetLen += int(l)
+			packetSegs++
+			if l < 255 {
+				complete = true
+				break
+			}
+		}
+
+		first := body[:packetLen]
+		if data[off+5]&oggContinued == 0 && (bytes.HasPrefix(first, opusTagsMagic) || bytes.HasPrefix(first, vorbisCommentMagic)) {
+			if !complete {
+				return data, false
+			}
+			packet, ok := rewriteCommentPacket(first, vendor, encoder)
+			if !ok {
+				return data, false
+			}
+			newLacing := oggLacing(len(packet))
+			newLacing = append(newLacing, lacing[packetSegs:]...)
+			if len(newLacing) > oggMaxSegs {
+				return data, false
+			}
+
+			var out bytes.Buffer
+			out.Grow(len(data) + len(packet) - len(first) + len(newLacing) - nsegs)
+			out.Write(data[:off])
+			pageStart := out.Len()
+			out.Write(data[off : off+oggSegsOffset])
+			out.WriteByte(byte(len(newLacing)))
+			out.Write(newLacing)
+			out.Write(packet)
+			out.Write(body[packetLen:])
+			pageEnd := out.Len()
+			out.Write(data[end:])
+
+			result := out.Bytes()
+			newPage := result[pageStart:pageEnd]
+			binary.LittleEndian.PutUint32(newPage[oggCRCOffset:], 0)
+			binary.LittleEndian.PutUint32(newPage[oggCRCOffset:], oggCRC(newPage))
+			return result, true
+		}
+		off = end
+	}
+	return data, false
+}
+
+// oggLacing returns the segment table entries of one packet of length n
+func oggLacing(n int) []byte {
+	lacing := bytes.Repeat([]byte{255}, n/255)
+	return append(lacing, byte(n%255))
+}
+
+// rewriteCommentPacket rebuilds a comment header with vendor and encoder,
+// keeping every other comment and any trailing bytes (Vorbis framing bit,
+// Opus padding)
+func rewriteCommentPacket(packet []byte, vendor, encoder string) ([]byte, bool) {
+	magic := opusTagsMagic
+	if bytes.HasPrefix(packet, vorbisCommentMagic) {
+		magic = vorbisCommentMagic
+	}
+	r := packet[len(magic):]
+
+	next := func() ([]byte, bool) {
+		if len(r) < 4 {
+			return nil, false
+		}
+		n := binary.LittleEndian.Uint32(r)
+		if uint64(n) > uint64(len(r)-4) {
+			return nil, false
+		}
+		field := r[4 : 4+n]
+		r = r[4+n:]
+		return field, true
+	}
+
+	if _, ok := next(); !ok { // Vendor
+		return nil, false
+	}
+	if len(r) < 4 {
+		return nil, false
+	}
+	count := binary.LittleEndian.Uint32(r)
+	r = r[4:]
+
+	var comments [][]byte
+	for i := uint32(0); i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			return nil, false
+		}
+		if len(comment) >= 8 && bytes.EqualFold(comment[:8], []byte("encoder=")) {
+			continue
+		}
+		comments = append(comments, comment)
+	}
+	if encoder != "" {
+		comments = append(comments, []byte("ENCODER="+encoder))
+	}
+
+	var out bytes.Buffer
+	out.Write(magic)
+	binary.Write(&out, binary.LittleEndian, uint32(len(vendor)))
+	out.WriteString(vendor)
+	binary.Write(&out, binary.LittleEndian, uint32(len(comments)))
+	for _, comment := range comments {
+		binary.Write(&out, binary.LittleEndian, uint32(len(comment)))
+		out.Write(comment)
+	}
+	out.Write(r)
+	return out.Bytes(), true
+}
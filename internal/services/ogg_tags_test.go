@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// oggPage builds one page holding the given complete packets
+func oggPage(seq uint32, flags byte, packets ...[]byte) []byte {
+	var lacing, body []byte
+	for _, p := range packets {
+		lacing = append(lacing, oggLacing(len(p))...)
+		body = append(body, p...)
+	}
+	page := make([]byte, oggHeaderLen, oggHeaderLen+len(lacing)+len(body))
+	copy(page, "OggS")
+	page[5] = flags
+	binary.LittleEndian.PutUint32(page[18:], seq)
+	page[oggSegsOffset] = byte(len(lacing))
+	page = append(page, lacing...)
+	page = append(page, body...)
+	binary.LittleEndian.PutUint32(page[oggCRCOffset:], oggCRC(page))
+	return page
+}
+
+// commentPacket builds an OpusTags packet
+func commentPacket(vendor string, comments ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("OpusTags")
+	binary.Write(&b, binary.LittleEndian, uint32(len(vendor)))
+	b.WriteString(vendor)
+	binary.Write(&b, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		binary.Write(&b, binary.LittleEndian, uint32(len(c)))
+		b.WriteString(c)
+	}
+	return b.Bytes()
+}
+
+func TestRewriteOggComments(t *testing.T) {
+	head := oggPage(0, 0x02, []byte("OpusHead\x01\x01\x38\x01\x80\xbb\x00\x00\x00\x00\x00"))
+	tags := oggPage(1, 0, commentPacket("Lavf61.7.100", "title=uid:1_abc", "encoder=Lavc61.19.100 libopus"))
+	audio := oggPage(2, 0, []byte{0xf8, 0xff, 0xfe})
+	data := append(append(append([]byte{}, head...), tags...), audio...)
+
+	out, ok := rewriteOggComments(data, "libopus 1.4", "opusenc from opus-tools 0.2")
+	if !ok {
+		t.Fatal("comment header not rewritten")
+	}
+	if !bytes.HasPrefix(out, head) || !bytes.HasSuffix(out, audio) {
+		t.Fatal("pages around the comment header changed")
+	}
+
+	page := out[len(head) : len(out)-len(audio)]
+	want := oggPage(1, 0, commentPacket("libopus 1.4", "title=uid:1_abc", "ENCODER=opusenc from opus-tools 0.2"))
+	if !bytes.Equal(page, want) {
+		t.Fatalf("rewritten page = %q\nwant %q", page, want)
+	}
+	if bytes.Contains(out, []byte("Lav")) {
+		t.Error("ffmpeg identification left in the output")
+	}
+
+	// No encoder drops the comment altogether
+	out, _ = rewriteOggComments(data, "libopus 1.3.1", "")
+	if bytes.Contains(bytes.ToLower(out), []byte("encoder=")) {
+		t.Error("encoder comment kept")
+	}
+}
+
+func TestRewriteOggCommentsLeavesUnknownData(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"not ogg":   []byte("ID3\x04\x00\x00\x00\x00\x00\x00"),
+		"no tags":   oggPage(0, 0x02, []byte("OpusHead\x01")),
+		"truncated": oggPage(1, 0, commentPacket("Lavf", "encoder=Lavc"))[:40],
+		"bad count": oggPage(1, 0, []byte("OpusTags\x04\x00\x00\x00Lavf\xff\xff\xff\xff")),
+	} {
+		out, ok := rewriteOggComments(data, "libopus 1.4", "")
+		if ok || !bytes.Equal(out, data) {
+			t.Errorf("%s: data changed", name)
+		}
+	}
+}
+
+func TestEncoderIdentityArgs(t *testing.T) {
+	id := encoderIdentity{Encoder: "HandBrake 1.7.3", VideoHandler: "VideoHandler", AudioHandler: "SoundHandler"}
+
+	got := id.args(true, false)
+	want := []string{"-metadata", "encoder=HandBrake 1.7.3", "-metadata:s:v:0", "handler_name=VideoHandler"}
+	if len(got) != len(want) {
+		t.Fatalf("args = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("args = %q, want %q", got, want)
+		}
+	}
+
+	// Empty values would clear the tag and let ffmpeg write its own
+	for _, arg := range (encoderIdentity{Vendor: "libopus 1.4"}).args(false, true) {
+		t.Errorf("unexpected arg %q", arg)
+	}
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"math"
+	mathrand "math/rand"
+	"slices"
+	"time"
+)
+
+// Supported playback speed range (atempo accepts 0.5-2.0 in a single instance)
+const (
+	MinSpeed = 0.5
+	MaxSpeed = 2.0
+)
+
+// AFLevels are the anti-fingerprint levels of the converters' Convert
+// pipelines, weakest first
+var AFLevels = []string{"basic", "moderate", "paranoid"}
+
+// ValidAFLevel reports whether level is one of AFLevels
+func ValidAFLevel(level string) bool {
+	return slices.Contains(AFLevels, level)
+}
+
+// maxAudioOffset keeps escalated audio offsets inside the ~45ms lead that
+// viewers notice first
+const maxAudioOffset = 0.040
+
+// ProcessOptions holds optional per-request settings for the script-technique pipelines
+// The zero value keeps the original behavior
+type ProcessOptions struct {
+	// Profile enables optional uniqueness techniques (zero value = none)
+	Profile Profile
+
+	// Level runs the anti-fingerprint pipeline of that strength (one of
+	// AFLevels) instead of the script techniques; empty keeps them
+	// Documents have a single pipeline and ignore it
+	Level string
+
+	// Silence trimming (audio only) - strips leading/trailing silence before uniqueness filters
+	TrimSilence        bool
+	SilenceThresholdDB float64       // Level below which audio counts as silence, e.g. -50
+	SilenceKeep        time.Duration // Silence kept at each edge so the cut sounds natural
+
+	// Keep the audio duration equal to the input (audio only): the leading delay
+	// replaces the same amount of audio instead of lengthening the file, and the
+	// profile's time-stretch is skipped
+	PreserveDuration bool
+
+	// Playback speed (audio and video) - 0 or 1 leaves the speed unchanged
+	// When SpeedMin/SpeedMax are set, a random speed in that range is picked per request
+	Speed    float64
+	SpeedMin float64
+	SpeedMax float64
+
+	// Extra metadata tags embedded next to the uid marker (audio, image and video)
+	Metadata map[string]string
+
+	// Embed no uid marker and suppress ffmpeg's encoder tags, leaving output
+	// metadata empty; uniqueness then rests on the content techniques alone
+	NoMarker bool
+
+	// Embed GPS EXIF coordinates picked within this region (JPEG/PNG images only)
+	GPSRegion *GeoRegion
+
+	// Stream receives encoded bytes as ffmpeg emits them (audio only; other
+	// pipelines write their output file and ignore it). The output file is
+	// still written in full
+	Stream io.Writer
+}
+
+// ResolveSpeed fixes Speed to a random value when a range was requested
+// Uses a nonce-seeded RNG so concurrent requests pick independent speeds
+func (o ProcessOptions) ResolveSpeed() ProcessOptions {
+	if o.SpeedMax > o.SpeedMin && o.SpeedMin > 0 {
+		rng := mathrand.New(mathrand.NewSource(GenerateNonce().GetSeedForRand()))
+		o.Speed = o.SpeedMin + rng.Float64()*(o.SpeedMax-o.SpeedMin)
+	} else if o.SpeedMin > 0 && o.SpeedMin == o.SpeedMax {
+		o.Speed = o.SpeedMin
+	}
+	o.SpeedMin, o.SpeedMax = 0, 0
+	return o
+}
+
+// hasSpeedChange reports whether a non-identity speed is set
+func (o ProcessOptions) hasSpeedChange() bool {
+	return o.Speed > 0 && o.Speed != 1.0
+}
+
+// delayCompensationFilter trims delayMs from the start of the audio so a
+// following adelay of the same length leaves the duration unchanged
+func delayCompensationFilter(delayMs int) []*Filter {
+	return []*Filter{
+		NewFilter("atrim").Setf("start", "%.3f", float64(delayMs)/1000),
+		NewFilter("asetpts").Arg("PTS-STARTPTS"),
+	}
+}
+
+// timeStretchFactor returns a nonce-derived tempo factor within ±0.05%
+// The magnitude is kept above 0.01% so the stretch always changes the waveform
+func timeStretchFactor(rng *mathrand.Rand, strength float64) float64 {
+	delta := (0.0001 + rng.Float64()*0.0004) * strength // 0.01% - 0.05% at strength 1
+	if rng.Intn(2) == 0 {
+		delta = -delta
+	}
+	return 1.0 + delta
+}
+
+// phaseEQFilter returns a nonce-derived spectral tilt and all-pass phase shift
+// Opposing low/high shelves below 0.1dB move frequency-domain fingerprints
+// (Chromaprint-style) while staying perceptually identical
+func phaseEQFilter(rng *mathrand.Rand, strength float64) []*Filter {
+	tilt := (0.03 + rng.Float64()*0.06) * strength // 0.03 - 0.09 dB at strength 1
+	if rng.Intn(2) == 0 {
+		tilt = -tilt
+	}
+	lowFreq := 150 + rng.Intn(150)    // 150-299 Hz
+	highFreq := 3000 + rng.Intn(3000) // 3-6 kHz
+	phaseFreq := 500 + rng.Intn(1500) // 500-1999 Hz
+
+	return []*Filter{
+		NewFilter("bass").Setf("g", "%.3f", tilt).Set("f", lowFreq),
+		NewFilter("treble").Setf("g", "%.3f", -tilt).Set("f", highFreq),
+		NewFilter("allpass").Set("f", phaseFreq).Set("width_type", "q").Set("width", 0.707),
+	}
+}
+
+// microWarpFilter returns a perspective warp moving each corner by less than
+// 0.5px times strength. Corners are expressed relative to the frame so the
+// filter works for any size
+func microWarpFilter(rng *mathrand.Rand, strength float64) *Filter {
+	offset := func() float64 {
+		return (rng.Float64()*2 - 1) * 0.49 * strength // ±0.49 px at strength 1
+	}
+	return NewFilter("perspective").
+		Setf("x0", "%.3f", offset()).Setf("y0", "%.3f", offset()).
+		Setf("x1", "W%+.3f", offset()).Setf("y1", "%.3f", offset()).
+		Setf("x2", "%.3f", offset()).Setf("y2", "H%+.3f", offset()).
+		Setf("x3", "W%+.3f", offset()).Setf("y3", "H%+.3f", offset()).
+		Set("interpolation", "cubic")
+}
+
+// chromaNoiseFilter returns nonce-seeded noise applied to the U/V planes only
+// Converting to yuv444p first keeps full chroma resolution for RGB sources
+func chromaNoiseFilter(rng *mathrand.Rand, strength float64) []*Filter {
+	amount := func() int {
+		return int(math.Round(float64(2+rng.Intn(3)) * strength)) // 2-4 at strength 1
+	}
+	return []*Filter{
+		NewFilter("format").Arg("yuv444p"),
+		NewFilter("noise").
+			Set("c1s", amount()).Set("c1_seed", rng.Int31()).
+			Set("c2s", amount()).Set("c2_seed", rng.Int31()),
+	}
+}
+
+// whiteBalanceFilter returns a colorchannelmixer applying nonce-derived
+// per-channel gains within ±0.2% times strength. The luma weights of the gains
+// sum to 1 (0.299·rr+0.587·gg+0.114·bb), so grays keep their luma while
+// colored pixels shift slightly: the change is aimed at color histograms
+func whiteBalanceFilter(rng *mathrand.Rand, strength float64) *Filter {
+	bound := 0.002 * strength
+	var gains [3]float64
+	for i := range gains {
+		gains[i] = (rng.Float64()*2 - 1) * bound
+	}
+	// Remove the luma-weighted mean, then scale all gains back within the
+	// bound; scaling keeps the weighted sum at zero where clamping would not
+	mean := 0.299*gains[0] + 0.587*gains[1] + 0.114*gains[2]
+	peak := 0.0
+	for i := range gains {
+		gains[i] -= mean
+		peak = max(peak, math.Abs(gains[i]))
+	}
+	if peak > bound {
+		for i := range gains {
+			gains[i] *= bound / peak
+		}
+	}
+	return NewFilter("colorchannelmixer").
+		Setf("rr", "%.6f", 1+gains[0]).
+		Setf("gg", "%.6f", 1+gains[1]).
+		Setf("bb", "%.6f", 1+gains[2])
+}
+
+// gammaDitherFilter returns an eq filter whose gamma and brightness drift per frame
+// Each follows the sum of two slow sines (periods of seconds) with nonce-derived
+// periods and phases; amplitudes stay below visible thresholds
+func gammaDitherFilter(rng *mathrand.Rand, baseGamma, strength float64) *Filter {
+	curve := func(amplitude float64) string {
+		p1 := 2 + rng.Float64()*4 // 2-6 s
+		p2 := 7 + rng.Float64()*8 // 7-15 s
+		ph1 := rng.Float64() * 2 * math.Pi
+		ph2 := rng.Float64() * 2 * math.Pi
+		return fmt.Sprintf("%.6f*sin(2*PI*t/%.3f+%.4f)+%.6f*sin(2*PI*t/%.3f+%.4f)",
+			amplitude*0.6, p1, ph1, amplitude*0.4, p2, ph2)
+	}
+	return NewFilter("eq").
+		Setf("gamma", "%.6f+%s", baseGamma, curve(0.002*strength)).
+		Set("brightness", curve(0.002*strength)).
+		Set("eval", "frame")
+}
+
+// audioOffsetSeconds returns a nonce-derived ±10-30ms audio offset scaled by
+// strength and capped at maxAudioOffset, within the ~45ms lead / ~125ms lag
+// lip-sync detectability window
+func audioOffsetSeconds(rng *mathrand.Rand, strength float64) float64 {
+	offset := min((0.010+rng.Float64()*0.020)*strength, maxAudioOffset)
+	if rng.Intn(2) == 0 {
+		offset = -offset
+	}
+	return offset
+}
+
+// atempoFilter returns the audio filter for the configured speed
+func (o ProcessOptions) atempoFilter() *Filter {
+	return NewFilter("atempo").Setf("tempo", "%.4f", o.Speed)
+}
+
+// setptsFilter returns the video filter for the configured speed
+func (o ProcessOptions) setptsFilter() *Filter {
+	return NewFilter("setpts").Setf("expr", "PTS/%.4f", o.Speed)
+}
+
+// silenceTrimFilter builds a filter chain that removes leading and trailing silence
+// Trailing silence is handled by reversing, trimming the start and reversing back,
+// so pauses in the middle of a voice note are kept
+func (o ProcessOptions) silenceTrimFilter() []*Filter {
+	trim := func() *Filter {
+		return NewFilter("silenceremove").
+			Set("start_periods", 1).
+			Setf("start_threshold", "%.1fdB", o.SilenceThresholdDB).
+			Setf("start_silence", "%.3f", o.SilenceKeep.Seconds())
+	}
+	return []*Filter{trim(), NewFilter("areverse"), trim(), NewFilter("areverse")}
+}
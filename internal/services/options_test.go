@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"math"
+	mathrand "math/rand"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestTimeStretchFactorBounds(t *testing.T) {
+	rng := mathrand.New(mathrand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		f := timeStretchFactor(rng, 1)
+		delta := math.Abs(f - 1.0)
+		if delta < 0.0001 || delta > 0.0005 {
+			t.Fatalf("factor %.6f outside ±0.01%%-0.05%%", f)
+		}
+	}
+}
+
+func TestResolveSpeed(t *testing.T) {
+	opts := ProcessOptions{SpeedMin: 0.98, SpeedMax: 1.02}.ResolveSpeed()
+	if opts.Speed < 0.98 || opts.Speed > 1.02 {
+		t.Fatalf("resolved speed %.4f outside range", opts.Speed)
+	}
+	if opts.SpeedMin != 0 || opts.SpeedMax != 0 {
+		t.Fatalf("range should be cleared after resolving")
+	}
+
+	if got := (ProcessOptions{Speed: 1.1}).ResolveSpeed().Speed; got != 1.1 {
+		t.Fatalf("fixed speed changed to %.4f", got)
+	}
+}
+
+func TestAudioOffsetSecondsBounds(t *testing.T) {
+	rng := mathrand.New(mathrand.NewSource(7))
+	for i := 0; i < 1000; i++ {
+		offset := math.Abs(audioOffsetSeconds(rng, 1))
+		if offset < 0.010 || offset > 0.030 {
+			t.Fatalf("offset %.4fs outside 10-30ms", offset)
+		}
+	}
+}
+
+func TestWhiteBalanceFilterKeepsLuma(t *testing.T) {
+	rng := mathrand.New(mathrand.NewSource(3))
+	for _, strength := range []float64{1, 3} {
+		for i := 0; i < 1000; i++ {
+			f := whiteBalanceFilter(rng, strength)
+			if len(f.args) != 3 {
+				t.Fatalf("filter = %s", f)
+			}
+			var gains [3]float64
+			for j, arg := range f.args {
+				v, err := strconv.ParseFloat(arg.value, 64)
+				if err != nil {
+					t.Fatalf("%s = %q", arg.key, arg.value)
+				}
+				if math.Abs(v-1) > 0.002*strength+1e-6 {
+					t.Fatalf("%s gain %.6f outside ±%.1f%%", arg.key, v, 0.2*strength)
+				}
+				gains[j] = v
+			}
+			// Only the 6-decimal rounding of each gain may unbalance the weights
+			if luma := 0.299*gains[0] + 0.587*gains[1] + 0.114*gains[2]; math.Abs(luma-1) > 1e-6 {
+				t.Fatalf("gains %v weigh luma by %.9f, want 1", gains, luma)
+			}
+		}
+	}
+}
+
+func TestDelayCompensationFilter(t *testing.T) {
+	got, err := NewFilterGraph(delayCompensationFilter(37)...).Add(NewFilter("adelay").Arg(37).Set("all", 1)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "atrim=start=0.037,asetpts=PTS-STARTPTS,adelay=37:all=1"; got != want {
+		t.Errorf("graph = %q, want %q", got, want)
+	}
+}
+
+func TestPreserveDurationKeepsAudioLength(t *testing.T) {
+	for _, tool := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+	paranoid, _ := LookupProfile("paranoid")
+	output := filepath.Join(t.TempDir(), "out.wav")
+	converter := NewAudioConverter(nil, nil)
+	opts := ProcessOptions{Profile: paranoid, PreserveDuration: true}
+	if _, err := converter.Process(context.Background(), makeSineWAV(1, 48000), output, "wav", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := ProbeMedia(context.Background(), output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(info.DurationSeconds-1) > 0.001 {
+		t.Errorf("duration = %.4fs, want 1s", info.DurationSeconds)
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Register JPEG decoder
+	_ "image/png"  // Register PNG decoder
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// pHash parameters: the image is reduced to 32x32 luma and the top-left
+// 8x8 DCT coefficients (low frequencies) form the 64-bit hash
+const (
+	phashSize    = 32
+	phashLowFreq = 8
+)
+
+// PerceptualHash computes a DCT-based 64-bit perceptual hash of a JPEG or PNG image
+func PerceptualHash(data []byte) (uint64, error) {
+	img, err := decodeHashImage(data)
+	if err != nil {
+		return 0, err
+	}
+	return phashLuma(lumaGrid(img, phashSize)), nil
+}
+
+// decodeHashImage decodes an image for hashing
+func decodeHashImage(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode failed: %w", err)
+	}
+	if b := img.Bounds(); b.Dx() <= 0 || b.Dy() <= 0 {
+		return nil, fmt.Errorf("invalid dimensions")
+	}
+	return img, nil
+}
+
+// lumaGrid downscales img to size x size luma by box averaging
+func lumaGrid(img image.Image, size int) [][]float64 {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	pixels := make([][]float64, size)
+	for py := 0; py < size; py++ {
+		pixels[py] = make([]float64, size)
+		y0 := bounds.Min.Y + py*h/size
+		y1 := bounds.Min.Y + (py+1)*h/size
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for px := 0; px < size; px++ {
+			x0 := bounds.Min.X + px*w/size
+			x1 := bounds.Min.X + (px+1)*w/size
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			sum, n := 0.0, 0
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					r, g, b, _ := img.At(x, y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					n++
+				}
+			}
+			pixels[py][px] = sum / float64(n)
+		}
+	}
+	return pixels
+}
+
+// phashLuma hashes a phashSize x phashSize luma grid
+func phashLuma(pixels [][]float64) uint64 {
+	// 2D DCT-II, only the low-frequency block is needed
+	var coeffs [phashLowFreq * phashLowFreq]float64
+	for u := 0; u < phashLowFreq; u++ {
+		for v := 0; v < phashLowFreq; v++ {
+			sum := 0.0
+			for y := 0; y < phashSize; y++ {
+				for x := 0; x < phashSize; x++ {
+					sum += pixels[y][x] *
+						math.Cos(float64(2*y+1)*float64(u)*math.Pi/(2*phashSize)) *
+						math.Cos(float64(2*x+1)*float64(v)*math.Pi/(2*phashSize))
+				}
+			}
+			coeffs[u*phashLowFreq+v] = sum
+		}
+	}
+
+	// Compare against the median, skipping the DC term which only tracks brightness
+	sorted := make([]float64, len(coeffs)-1)
+	copy(sorted, coeffs[1:])
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for i, c := range coeffs {
+		if i > 0 && c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// HammingDistance returns the number of differing bits between two hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// PairwiseDistances returns the Hamming distance of every pair of hashes
+func PairwiseDistances(hashes []uint64) []int {
+	distances := make([]int, 0, len(hashes)*(len(hashes)-1)/2)
+	for i := range hashes {
+		for j := i + 1; j < len(hashes); j++ {
+			distances = append(distances, HammingDistance(hashes[i], hashes[j]))
+		}
+	}
+	return distances
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func encodeTestPNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func patternImage(w, h int, invert bool) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(128 + 100*math.Sin(float64(x)/9)*math.Cos(float64(y)/13))
+			if invert {
+				v = 255 - v
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func TestPerceptualHash(t *testing.T) {
+	base := patternImage(64, 64, false)
+	h1, err := PerceptualHash(encodeTestPNG(t, base))
+	if err != nil {
+		t.Fatalf("hash base: %v", err)
+	}
+
+	// A single-pixel change must keep the hash (nearly) identical
+	tweaked := patternImage(64, 64, false)
+	tweaked.SetGray(10, 10, color.Gray{Y: 255})
+	h2, err := PerceptualHash(encodeTestPNG(t, tweaked))
+	if err != nil {
+		t.Fatalf("hash tweaked: %v", err)
+	}
+	if d := HammingDistance(h1, h2); d > 4 {
+		t.Errorf("near-identical images differ by %d bits", d)
+	}
+
+	// An inverted image must be far away
+	h3, err := PerceptualHash(encodeTestPNG(t, patternImage(64, 64, true)))
+	if err != nil {
+		t.Fatalf("hash inverted: %v", err)
+	}
+	if d := HammingDistance(h1, h3); d < 16 {
+		t.Errorf("inverted image only differs by %d bits", d)
+	}
+}
+
+func TestPerceptualHashInvalid(t *testing.T) {
+	if _, err := PerceptualHash([]byte("not an image")); err == nil {
+		t.Fatal("expected error for invalid data")
+	}
+}
+
+func TestPairwiseDistances(t *testing.T) {
+	got := PairwiseDistances([]uint64{0, 0b1, 0b111})
+	want := []int{1, 3, 2} // (0,1) (0,2) (1,2)
+	if len(got) != len(want) {
+		t.Fatalf("PairwiseDistances = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("PairwiseDistances = %v, want %v", got, want)
+		}
+	}
+	if got := PairwiseDistances([]uint64{42}); len(got) != 0 {
+		t.Errorf("single hash gave %v", got)
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// verifyTimeout bounds the decode check of one output
+const verifyTimeout = 60 * time.Second
+
+// SetVerifyOutput makes Process fully decode every output before reporting
+// success. Call before the converter is used
+func (b *baseConverter) SetVerifyOutput(enabled bool) {
+	b.verifyOutput = enabled
+}
+
+// verified runs convert, which returns the path it wrote ("" skips the check),
+// and decodes the result when output verification is on. An output that does
+// not decode is converted once more with fallback settings before failing
+func (b *baseConverter) verified(ctx context.Context, opts ProcessOptions, convert func(ProcessOptions) (string, error)) error {
+	path, err := convert(opts)
+	if err != nil || !b.verifyOutput || path == "" {
+		return err
+	}
+	verifyErr := verifyPlayable(ctx, path)
+	if verifyErr == nil {
+		return nil
+	}
+
+	// Streamed bytes already reached the client, a second encode would repeat
+	// them; and a retry that changes nothing would only fail the same way
+	fallback, changed := opts.fallback()
+	if opts.Stream != nil || ctx.Err() != nil || !changed {
+		b.recordFailure()
+		return verifyErr
+	}
+
+	convertLog.Warnf("⚠️  Output does not decode (%v), retrying with fallback settings", verifyErr)
+	// ffmpeg runs without -y and would refuse to overwrite the failed output
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if path, err = convert(fallback); err != nil {
+		return err
+	}
+	if err := verifyPlayable(ctx, path); err != nil {
+		b.recordFailure()
+		return fmt.Errorf("%w (fallback output also failed: %v)", verifyErr, err)
+	}
+	b.recordFallback()
+	return nil
+}
+
+// fallback drops the optional profile techniques, the part of the pipeline
+// most likely to produce an output some decoder rejects. It reports false
+// when that changes nothing: levels ignore the profile, and a profile without
+// techniques has nothing to drop
+func (o ProcessOptions) fallback() (ProcessOptions, bool) {
+	standard, _ := LookupProfile(DefaultProfileName)
+	techniques := o.Profile
+	techniques.Name, techniques.Strength = standard.Name, standard.Strength
+	if o.Level != "" || techniques == standard {
+		return o, false
+	}
+	o.Profile = standard
+	return o, true
+}
+
+// verifyPlayable decodes every stream of path and fails on any error ffmpeg reports
+func verifyPlayable(ctx context.Context, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+
+	cmd := toolCommand(ctx, "ffmpeg",
+		"-hide_banner",
+		"-v", "error",
+		"-xerror",
+		"-i", path,
+		"-f", "null",
+		"-",
+	)
+	stderr := newCappedBuffer()
+	cmd.Stderr = stderr
+
+	started := time.Now()
+	err := cmd.Run()
+	traceCommand(ctx, cmd, started, err)
+	if err == nil && strings.TrimSpace(stderr.String()) == "" {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("output verification: %w", ctx.Err())
+	}
+	return &ExecError{Tool: "ffmpeg", Kind: ErrUnplayableOutput, Tail: stderrTail(stderr.String()), Err: err}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestFallbackDropsProfileTechniques(t *testing.T) {
+	paranoid, _ := LookupProfile("paranoid")
+	opts := ProcessOptions{Profile: paranoid, Speed: 1.1, Metadata: map[string]string{"artist": "x"}}
+
+	fallback, changed := opts.fallback()
+	if !changed || fallback.Profile.Name != DefaultProfileName || fallback.Profile.AudioTimeStretch {
+		t.Errorf("fallback profile = %+v, changed = %v", fallback.Profile, changed)
+	}
+	if fallback.Speed != 1.1 || fallback.Metadata["artist"] != "x" {
+		t.Error("fallback dropped requested options")
+	}
+
+	// Nothing to drop: levels ignore the profile, the others have no techniques
+	standard, _ := LookupProfile(DefaultProfileName)
+	for name, opts := range map[string]ProcessOptions{
+		"level":    {Level: "paranoid"},
+		"zero":     {},
+		"standard": {Profile: standard},
+	} {
+		if _, changed := opts.fallback(); changed {
+			t.Errorf("%s: fallback reported a change", name)
+		}
+	}
+}
+
+func TestVerifiedSkipsIdenticalRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.mp4")
+	b := &baseConverter{mediaType: "video", verifyOutput: true}
+	calls := 0
+	convert := func(ProcessOptions) (string, error) {
+		calls++
+		return path, os.WriteFile(path, []byte("not a video"), 0644)
+	}
+
+	if err := b.verified(context.Background(), ProcessOptions{Level: "basic"}, convert); err == nil {
+		t.Fatal("unplayable output accepted")
+	}
+	if calls != 1 {
+		t.Errorf("conversions = %d, want no retry", calls)
+	}
+}
+
+func TestVerifiedRetriesUnplayableOutput(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not available")
+	}
+	dir := t.TempDir()
+	good := makeSineWAV(0.2, 8000)
+	paranoid, _ := LookupProfile("paranoid")
+
+	b := &baseConverter{mediaType: "audio", verifyOutput: true}
+	var profiles []string
+	convert := func(opts ProcessOptions) (string, error) {
+		profiles = append(profiles, opts.Profile.Name)
+		path := filepath.Join(dir, "out.wav")
+		data := good[:60] // Header promising samples that are missing
+		if len(profiles) > 1 {
+			data = good
+		}
+		return path, os.WriteFile(path, data, 0644)
+	}
+
+	if err := b.verified(context.Background(), ProcessOptions{Profile: paranoid}, convert); err != nil {
+		t.Fatalf("verified: %v", err)
+	}
+	if len(profiles) != 2 || profiles[0] != "paranoid" || profiles[1] != DefaultProfileName {
+		t.Errorf("conversions = %v", profiles)
+	}
+	if stats := b.GetStats(); stats.FallbackConversions != 1 {
+		t.Errorf("fallbacks = %d", stats.FallbackConversions)
+	}
+}
+
+func TestVerifiedRetriesIntoFreshOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.mp4")
+	paranoid, _ := LookupProfile("paranoid")
+
+	b := &baseConverter{mediaType: "video", verifyOutput: true}
+	var retry *ProcessOptions
+	convert := func(opts ProcessOptions) (string, error) {
+		if retry == nil && opts.Profile.Name == DefaultProfileName {
+			retry = &opts
+			// The failed output is gone before the retry writes its own
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("failed output still present on retry: %v", err)
+			}
+		}
+		return path, os.WriteFile(path, []byte("not a video"), 0644)
+	}
+
+	b.verified(context.Background(), ProcessOptions{Profile: paranoid, Speed: 1.05, Metadata: map[string]string{"title": "x"}}, convert)
+	if retry == nil {
+		t.Fatal("no fallback conversion")
+	}
+	if retry.Profile.VideoGammaDither || retry.Speed != 1.05 || retry.Metadata["title"] != "x" {
+		t.Errorf("fallback options = %+v", *retry)
+	}
+}
+
+func TestVerifiedFailsWhenFallbackIsUnplayable(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not available")
+	}
+	path := filepath.Join(t.TempDir(), "out.mp3")
+	b := &baseConverter{mediaType: "audio", verifyOutput: true}
+	convert := func(ProcessOptions) (string, error) {
+		return path, os.WriteFile(path, []byte("definitely not audio"), 0644)
+	}
+
+	err := b.verified(context.Background(), ProcessOptions{}, convert)
+	if !errors.Is(err, ErrUnplayableOutput) || FailureClass(err) != "unplayable_output" {
+		t.Fatalf("err = %v", err)
+	}
+	if stats := b.GetStats(); stats.FailedConversions != 1 {
+		t.Errorf("failures = %d", stats.FailedConversions)
+	}
+}
+
+func TestVerifiedSkipsWhenDisabled(t *testing.T) {
+	b := &baseConverter{mediaType: "audio"}
+	calls := 0
+	err := b.verified(context.Background(), ProcessOptions{}, func(ProcessOptions) (string, error) {
+		calls++
+		return "/nonexistent/out.mp3", nil
+	})
+	if err != nil || calls != 1 {
+		t.Errorf("err = %v, calls = %d", err, calls)
+	}
+}
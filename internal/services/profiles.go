@@ -0,0 +1,113 @@
+package services
+
+import (
+	"sort"
+	"strings"
+)
+
+// Profile selects which optional uniqueness techniques run on top of the base pipeline
+type Profile struct {
+	Name string
+
+	// Audio techniques
+	AudioTimeStretch bool // Inaudible ±0.05% tempo variation derived from the nonce
+	AudioPhaseEQ     bool // Sub-0.1dB EQ tilt plus a tiny all-pass phase shift
+
+	// Image techniques
+	ImageMicroWarp   bool // Sub-pixel perspective warp, defeats crop-invariant perceptual hashes
+	ImageChromaNoise bool // Low-amplitude noise on chroma planes only, luma untouched
+	// ±0.2% per-channel gain imbalance in place of the gamma micro-variation,
+	// for images where luminance shifts are undesirable
+	ImageWhiteBalance bool
+
+	// Video techniques
+	VideoGammaDither bool // Gamma/brightness drift frame-to-frame along a nonce-seeded curve
+	VideoAudioOffset bool // ±10-30ms audio/video offset within lip-sync tolerance
+
+	// Amplitude multiplier of the techniques above (0 or 1 = as designed)
+	// Raised by escalation when variants keep getting deduplicated
+	Strength float64
+}
+
+// strength returns the technique amplitude multiplier
+func (p Profile) strength() float64 {
+	if p.Strength <= 0 {
+		return 1
+	}
+	return p.Strength
+}
+
+// DefaultProfileName is used when neither the request nor the config selects a profile
+const DefaultProfileName = "standard"
+
+var profiles = map[string]Profile{
+	"standard": {
+		Name: "standard",
+	},
+	// The white-balance shift in place of the gamma micro-variation, for
+	// images where luminance shifts are undesirable
+	"color": {
+		Name:              "color",
+		ImageWhiteBalance: true,
+	},
+	"paranoid": {
+		Name:              "paranoid",
+		AudioTimeStretch:  true,
+		AudioPhaseEQ:      true,
+		ImageMicroWarp:    true,
+		ImageChromaNoise:  true,
+		ImageWhiteBalance: true,
+		VideoGammaDither:  true,
+		VideoAudioOffset:  true,
+	},
+}
+
+// LookupProfile returns the profile registered under name (case-insensitive)
+func LookupProfile(name string) (Profile, bool) {
+	p, ok := profiles[strings.ToLower(strings.TrimSpace(name))]
+	return p, ok
+}
+
+// ProfileNames returns the registered profile names in sorted order
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// techniques names each optional technique and reports whether a profile enables it
+var techniques = []struct {
+	name    string
+	enabled func(Profile) bool
+}{
+	{"audio_time_stretch", func(p Profile) bool { return p.AudioTimeStretch }},
+	{"audio_phase_eq", func(p Profile) bool { return p.AudioPhaseEQ }},
+	{"image_micro_warp", func(p Profile) bool { return p.ImageMicroWarp }},
+	{"image_chroma_noise", func(p Profile) bool { return p.ImageChromaNoise }},
+	{"image_white_balance", func(p Profile) bool { return p.ImageWhiteBalance }},
+	{"video_gamma_dither", func(p Profile) bool { return p.VideoGammaDither }},
+	{"video_audio_offset", func(p Profile) bool { return p.VideoAudioOffset }},
+}
+
+// TechniqueNames returns the names of all optional techniques
+func TechniqueNames() []string {
+	names := make([]string, len(techniques))
+	for i, t := range techniques {
+		names[i] = t.name
+	}
+	return names
+}
+
+// Techniques returns the names of the optional techniques p enables
+func (p Profile) Techniques() []string {
+	names := []string{}
+	for _, t := range techniques {
+		if t.enabled(p) {
+			names = append(names, t.name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,19 @@
+//go:build http3
+
+package services
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newQUICTransport returns an HTTP/3 client transport using tlsConfig
+// (nil = system defaults)
+func newQUICTransport(tlsConfig *tls.Config) (http.RoundTripper, error) {
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+	}
+	return &http3.Transport{TLSClientConfig: tlsConfig}, nil
+}
@@ -0,0 +1,14 @@
+//go:build !http3
+
+package services
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// newQUICTransport is unavailable without the http3 build tag, which keeps
+// the QUIC stack out of default builds
+func newQUICTransport(*tls.Config) (http.RoundTripper, error) {
+	return nil, errHTTP3Unavailable
+}
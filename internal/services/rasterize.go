@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// RasterizeDPI is the resolution PDF pages are rendered at
+	RasterizeDPI = 150
+	// MaxRasterPages caps how many pages of a document are rendered
+	MaxRasterPages = 50
+)
+
+// RasterizePages renders each page of a PDF or multi-page TIFF to PNG
+// PDFs use pdftoppm (poppler), TIFFs use ImageMagick. Intermediate files go
+// under tempDir ("" = system temp dir)
+func RasterizePages(ctx context.Context, inputData []byte, format, tempDir string) ([][]byte, error) {
+	if len(inputData) == 0 {
+		return nil, fmt.Errorf("empty input data")
+	}
+
+	dir, err := os.MkdirTemp(tempDir, "raster-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var cmd *exec.Cmd
+	switch format {
+	case "pdf":
+		input := filepath.Join(dir, "input.pdf")
+		if err := os.WriteFile(input, inputData, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write temp input: %w", err)
+		}
+		cmd = toolCommand(ctx, "pdftoppm",
+			"-png",
+			"-r", strconv.Itoa(RasterizeDPI),
+			"-l", strconv.Itoa(MaxRasterPages),
+			input,
+			filepath.Join(dir, "page"),
+		)
+	case "tiff":
+		input := filepath.Join(dir, "input.tiff")
+		if err := os.WriteFile(input, inputData, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write temp input: %w", err)
+		}
+		cmd = toolCommand(ctx, "magick",
+			fmt.Sprintf("%s[0-%d]", input, MaxRasterPages-1),
+			"-strip",
+			filepath.Join(dir, "page-%03d.png"),
+		)
+	default:
+		return nil, fmt.Errorf("rasterization not supported for format %q", format)
+	}
+
+	errorBuffer := newCappedBuffer()
+	cmd.Stderr = errorBuffer
+	started := time.Now()
+	err = cmd.Run()
+	traceCommand(ctx, cmd, started, err)
+	if err != nil {
+		return nil, newExecError(filepath.Base(cmd.Path), err, errorBuffer)
+	}
+
+	// Both tools zero-pad page numbers, so lexical order is page order
+	files, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pages: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no pages rendered")
+	}
+	sort.Strings(files)
+
+	pages := make([][]byte, 0, len(files))
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read page: %w", err)
+		}
+		pages = append(pages, data)
+	}
+
+	return pages, nil
+}
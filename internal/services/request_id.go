@@ -0,0 +1,20 @@
+package services
+
+import "context"
+
+type requestIDKey struct{}
+
+// WithRequestID tags the work done with ctx with the ID the caller traces
+// the request under
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the ID attached by WithRequestID, or ""
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
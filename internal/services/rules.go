@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ProcessRules are request conditions checked before fingerprinting
+// Zero values disable the corresponding rule
+type ProcessRules struct {
+	SkipIfSmallerThan   int64   // Bytes; smaller inputs are returned unmodified
+	OnlyIfDurationBelow float64 // Seconds; longer inputs are returned unmodified
+	MaxWidth, MaxHeight int     // Inputs above this resolution are rejected
+}
+
+// RuleAction is the outcome of evaluating ProcessRules
+type RuleAction int
+
+const (
+	RuleProcess RuleAction = iota // Continue with fingerprinting
+	RuleSkip                      // Return the input unmodified
+	RuleReject                    // Fail the request
+)
+
+// RuleDecision explains which rule fired
+type RuleDecision struct {
+	Action RuleAction
+	Reason string
+}
+
+// MediaInfo holds probed media properties (zero when unknown)
+type MediaInfo struct {
+	DurationSeconds float64
+	Width, Height   int
+}
+
+// Active reports whether any rule is set
+func (r ProcessRules) Active() bool {
+	return r.SkipIfSmallerThan > 0 || r.NeedsProbe()
+}
+
+// NeedsProbe reports whether evaluation requires ProbeMedia
+func (r ProcessRules) NeedsProbe() bool {
+	return r.OnlyIfDurationBelow > 0 || r.MaxWidth > 0 || r.MaxHeight > 0
+}
+
+// Evaluate applies the rules; rejections take precedence over skips
+func (r ProcessRules) Evaluate(size int64, info MediaInfo) RuleDecision {
+	if (r.MaxWidth > 0 && info.Width > r.MaxWidth) || (r.MaxHeight > 0 && info.Height > r.MaxHeight) {
+		return RuleDecision{RuleReject, fmt.Sprintf("resolution %dx%d above %dx%d", info.Width, info.Height, r.MaxWidth, r.MaxHeight)}
+	}
+	if r.SkipIfSmallerThan > 0 && size < r.SkipIfSmallerThan {
+		return RuleDecision{RuleSkip, fmt.Sprintf("size %d bytes below %d", size, r.SkipIfSmallerThan)}
+	}
+	if r.OnlyIfDurationBelow > 0 && info.DurationSeconds >= r.OnlyIfDurationBelow {
+		return RuleDecision{RuleSkip, fmt.Sprintf("duration %.1fs not below %.1fs", info.DurationSeconds, r.OnlyIfDurationBelow)}
+	}
+	return RuleDecision{Action: RuleProcess}
+}
+
+// ParseResolution parses "WIDTHxHEIGHT" (e.g. "1920x1080")
+func ParseResolution(s string) (width, height int, err error) {
+	w, h, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "x")
+	if ok {
+		width, err = strconv.Atoi(w)
+		if err == nil {
+			height, err = strconv.Atoi(h)
+		}
+	}
+	if !ok || err != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid resolution %q (want WIDTHxHEIGHT)", s)
+	}
+	return width, height, nil
+}
+
+// ProbeMedia reads duration and the first video/image stream resolution with ffprobe
+func ProbeMedia(ctx context.Context, path string) (MediaInfo, error) {
+	var info MediaInfo
+
+	output, err := toolCommand(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration:stream=width,height",
+		"-of", "default=noprint_wrappers=1",
+		path,
+	).Output()
+	if err != nil {
+		return info, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "duration":
+			info.DurationSeconds, _ = strconv.ParseFloat(value, 64)
+		case "width":
+			if n, err := strconv.Atoi(value); err == nil && info.Width == 0 {
+				info.Width = n
+			}
+		case "height":
+			if n, err := strconv.Atoi(value); err == nil && info.Height == 0 {
+				info.Height = n
+			}
+		}
+	}
+
+	return info, nil
+}
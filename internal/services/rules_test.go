@@ -0,0 +1,40 @@
+package services
+
+import "testing"
+
+func TestProcessRulesEvaluate(t *testing.T) {
+	rules := ProcessRules{SkipIfSmallerThan: 1000, OnlyIfDurationBelow: 60, MaxWidth: 1920, MaxHeight: 1080}
+
+	tests := []struct {
+		name string
+		size int64
+		info MediaInfo
+		want RuleAction
+	}{
+		{"process", 5000, MediaInfo{DurationSeconds: 10, Width: 1280, Height: 720}, RuleProcess},
+		{"tiny thumbnail", 500, MediaInfo{Width: 64, Height: 64}, RuleSkip},
+		{"too long", 5000, MediaInfo{DurationSeconds: 120, Width: 1280, Height: 720}, RuleSkip},
+		{"4k rejected", 500, MediaInfo{Width: 3840, Height: 2160}, RuleReject},
+	}
+
+	for _, tt := range tests {
+		if got := rules.Evaluate(tt.size, tt.info); got.Action != tt.want {
+			t.Errorf("%s: action = %v (%s), want %v", tt.name, got.Action, got.Reason, tt.want)
+		}
+	}
+
+	if (ProcessRules{}).Active() {
+		t.Error("zero rules must be inactive")
+	}
+}
+
+func TestParseResolution(t *testing.T) {
+	if w, h, err := ParseResolution("1920x1080"); err != nil || w != 1920 || h != 1080 {
+		t.Errorf("got %d, %d, %v", w, h, err)
+	}
+	for _, bad := range []string{"", "1920", "x1080", "axb", "0x10"} {
+		if _, _, err := ParseResolution(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	sizeWindow          = 1000 // Recent input sizes the size percentile is computed over
+	minSizeObservations = 20   // No size-based samples until this many requests were seen
+)
+
+// Phase is the time spent in one step of a request
+type Phase struct {
+	Name string `json:"name"`
+	Ms   int64  `json:"ms"`
+}
+
+// Command is one external tool run during a request
+type Command struct {
+	Args  []string `json:"args"` // Absolute paths reduced to file names
+	Ms    int64    `json:"ms"`
+	Error string   `json:"error,omitempty"`
+}
+
+// RequestTrace collects the diagnostics of one request; a nil trace records nothing
+type RequestTrace struct {
+	mu          sync.Mutex
+	start       time.Time
+	last        time.Time
+	phases      []Phase
+	commands    []Command
+	mediaType   string
+	inputFormat string
+	inputBytes  int64
+	outputBytes int64
+}
+
+// NewRequestTrace starts timing a request
+func NewRequestTrace() *RequestTrace {
+	now := time.Now()
+	return &RequestTrace{start: now, last: now}
+}
+
+// Mark ends the current phase, naming it phase
+func (t *RequestTrace) Mark(phase string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.phases = append(t.phases, Phase{Name: phase, Ms: now.Sub(t.last).Milliseconds()})
+	t.last = now
+}
+
+// SetInput records what was downloaded
+func (t *RequestTrace) SetInput(mediaType, inputFormat string, size int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.mediaType, t.inputFormat, t.inputBytes = mediaType, inputFormat, int64(size)
+	t.mu.Unlock()
+}
+
+// SetOutput records the size of the stored result
+func (t *RequestTrace) SetOutput(size int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.outputBytes = size
+	t.mu.Unlock()
+}
+
+// PhaseTotals returns the time spent in each phase, summed by name, and the
+// time since the trace started
+func (t *RequestTrace) PhaseTotals() (map[string]int64, int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	totals := make(map[string]int64, len(t.phases))
+	for _, p := range t.phases {
+		totals[p.Name] += p.Ms
+	}
+	return totals, time.Since(t.start).Milliseconds()
+}
+
+type traceKey struct{}
+
+// WithTrace makes converters record the commands they run in t
+func WithTrace(ctx context.Context, t *RequestTrace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// TraceFrom returns the trace attached by WithTrace, or nil
+func TraceFrom(ctx context.Context) *RequestTrace {
+	t, _ := ctx.Value(traceKey{}).(*RequestTrace)
+	return t
+}
+
+// traceCommand records cmd, started at start, in the request's trace
+func traceCommand(ctx context.Context, cmd *exec.Cmd, start time.Time, err error) {
+	t := TraceFrom(ctx)
+	if t == nil {
+		return
+	}
+	c := Command{Args: make([]string, len(cmd.Args)), Ms: time.Since(start).Milliseconds()}
+	for i, arg := range cmd.Args {
+		if filepath.IsAbs(arg) {
+			arg = filepath.Base(arg)
+		}
+		c.Args[i] = arg
+	}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	t.mu.Lock()
+	t.commands = append(t.commands, c)
+	t.mu.Unlock()
+}
+
+// Sample is the extended diagnostics of one slow or large request
+type Sample struct {
+	At          time.Time `json:"at"`
+	Reasons     []string  `json:"reasons"` // slow and/or large
+	Status      int       `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	MediaType   string    `json:"media_type,omitempty"`
+	InputFormat string    `json:"input_format,omitempty"`
+	InputBytes  int64     `json:"input_bytes"`
+	OutputBytes int64     `json:"output_bytes,omitempty"`
+	TotalMs     int64     `json:"total_ms"`
+	Phases      []Phase   `json:"phases"`
+	Commands    []Command `json:"commands,omitempty"`
+}
+
+// SampleList is the response of the samples admin endpoint
+type SampleList struct {
+	SlowThresholdMs int64    `json:"slow_threshold_ms"`
+	SizePercentile  float64  `json:"size_percentile"`
+	Count           int      `json:"count"`
+	Samples         []Sample `json:"samples"`
+}
+
+// Sampler keeps the last requests that were slower than a threshold or
+// whose input was above a size percentile of recent requests
+type Sampler struct {
+	slow       time.Duration // 0 disables latency sampling
+	percentile float64       // 0 disables size sampling
+	mu         sync.Mutex
+	sizes      []int64 // Ring of recent input sizes
+	sizeNext   int
+	samples    []Sample // Ring of kept samples
+	next       int
+}
+
+// NewSampler keeps the last keep samples of requests slower than slow or
+// larger than the given percentile (0-100) of recent input sizes
+func NewSampler(slow time.Duration, percentile float64, keep int) *Sampler {
+	if keep <= 0 {
+		keep = 50
+	}
+	return &Sampler{
+		slow:       slow,
+		percentile: percentile,
+		samples:    make([]Sample, 0, keep),
+	}
+}
+
+// Observe decides whether the finished request traced by t is kept
+func (s *Sampler) Observe(t *RequestTrace, status int, errMsg string) (Sample, bool) {
+	t.mu.Lock()
+	sample := Sample{
+		At:          t.start,
+		Status:      status,
+		Error:       errMsg,
+		MediaType:   t.mediaType,
+		InputFormat: t.inputFormat,
+		InputBytes:  t.inputBytes,
+		OutputBytes: t.outputBytes,
+		TotalMs:     time.Since(t.start).Milliseconds(),
+		Phases:      append([]Phase(nil), t.phases...),
+		Commands:    append([]Command(nil), t.commands...),
+	}
+	t.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.slow > 0 && sample.TotalMs >= s.slow.Milliseconds() {
+		sample.Reasons = append(sample.Reasons, "slow")
+	}
+	if sample.InputBytes > 0 && s.percentile > 0 {
+		if len(s.sizes) >= minSizeObservations && sample.InputBytes >= s.sizeThreshold() {
+			sample.Reasons = append(sample.Reasons, "large")
+		}
+		s.recordSize(sample.InputBytes)
+	}
+	if len(sample.Reasons) == 0 {
+		return sample, false
+	}
+
+	if len(s.samples) < cap(s.samples) {
+		s.samples = append(s.samples, sample)
+	} else {
+		s.samples[s.next] = sample
+	}
+	s.next = (s.next + 1) % cap(s.samples)
+	return sample, true
+}
+
+// sizeThreshold is the configured percentile of recent input sizes
+func (s *Sampler) sizeThreshold() int64 {
+	sorted := append([]int64(nil), s.sizes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(float64(len(sorted)-1) * s.percentile / 100)
+	return sorted[i]
+}
+
+func (s *Sampler) recordSize(size int64) {
+	if len(s.sizes) < sizeWindow {
+		s.sizes = append(s.sizes, size)
+		return
+	}
+	s.sizes[s.sizeNext] = size
+	s.sizeNext = (s.sizeNext + 1) % sizeWindow
+}
+
+// List returns up to limit samples, newest first (limit <= 0 returns all)
+func (s *Sampler) List(limit int) SampleList {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.samples)
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+	list := SampleList{
+		SlowThresholdMs: s.slow.Milliseconds(),
+		SizePercentile:  s.percentile,
+		Samples:         make([]Sample, 0, limit),
+	}
+	for i := 1; i <= limit; i++ {
+		// next points at the oldest sample once the ring is full
+		list.Samples = append(list.Samples, s.samples[(s.next-i+cap(s.samples))%cap(s.samples)])
+	}
+	list.Count = len(list.Samples)
+	return list
+}
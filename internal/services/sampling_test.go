@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestSamplerKeepsSlowRequests(t *testing.T) {
+	s := NewSampler(time.Millisecond, 0, 2)
+
+	fast := NewRequestTrace()
+	fast.SetInput("image", "jpg", 1000)
+	if _, kept := NewSampler(time.Hour, 0, 2).Observe(fast, 200, ""); kept {
+		t.Error("fast request was sampled")
+	}
+
+	for i := 0; i < 3; i++ {
+		trace := NewRequestTrace()
+		trace.SetInput("video", "mp4", 1000+i)
+		ctx := WithTrace(context.Background(), trace)
+		traceCommand(ctx, exec.Command("ffmpeg", "-i", "pipe:0", "/var/tmp/media/out.mp4"), time.Now(), errors.New("boom"))
+		time.Sleep(2 * time.Millisecond)
+		trace.Mark("convert")
+		if _, kept := s.Observe(trace, 500, "failed"); !kept {
+			t.Fatalf("slow request %d was not sampled", i)
+		}
+	}
+
+	list := s.List(0)
+	if list.Count != 2 {
+		t.Fatalf("kept %d samples, want 2", list.Count)
+	}
+	if list.Samples[0].InputBytes != 1002 || list.Samples[1].InputBytes != 1001 {
+		t.Errorf("samples not newest first: %d, %d", list.Samples[0].InputBytes, list.Samples[1].InputBytes)
+	}
+	sample := list.Samples[0]
+	if sample.Reasons[0] != "slow" || len(sample.Phases) != 1 || sample.Phases[0].Name != "convert" {
+		t.Errorf("sample = %+v", sample)
+	}
+	if args := sample.Commands[0].Args; args[len(args)-1] != "out.mp4" || sample.Commands[0].Error != "boom" {
+		t.Errorf("command = %+v", sample.Commands[0])
+	}
+	if got := s.List(1).Count; got != 1 {
+		t.Errorf("List(1) returned %d samples", got)
+	}
+}
+
+func TestSamplerKeepsLargeInputs(t *testing.T) {
+	s := NewSampler(0, 90, 10)
+	observe := func(size int) bool {
+		trace := NewRequestTrace()
+		trace.SetInput("audio", "mp3", size)
+		_, kept := s.Observe(trace, 200, "")
+		return kept
+	}
+
+	// Nothing is large until enough sizes were seen
+	for i := 1; i <= minSizeObservations; i++ {
+		if observe(i * 1000) {
+			t.Fatalf("request %d sampled before the window filled", i)
+		}
+	}
+	if observe(500) {
+		t.Error("small input sampled")
+	}
+	if !observe(50000) {
+		t.Error("input above p90 not sampled")
+	}
+}
+
+func TestNilTraceIsNoop(t *testing.T) {
+	var trace *RequestTrace
+	trace.Mark("download")
+	trace.SetInput("image", "png", 1)
+	trace.SetOutput(1)
+	traceCommand(context.Background(), exec.Command("ffmpeg"), time.Now(), nil)
+	if TraceFrom(context.Background()) != nil {
+		t.Error("trace found in empty context")
+	}
+}
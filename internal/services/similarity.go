@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Verdicts of a similarity comparison
+const (
+	VerdictPass  = "pass"  // The engine no longer matches the output to its input
+	VerdictFail  = "fail"  // The engine still matches the output to its input
+	VerdictError = "error" // The engine could not compare the pair
+)
+
+// SimilarityEngine measures how close a processed output still is to its
+// input, as a content-matching system would
+type SimilarityEngine interface {
+	Name() string
+	MediaTypes() []string // Media types the engine can compare
+	Tools() []string      // External binaries the engine runs
+	// Compare returns the raw engine score (a distance, or SSIM) and the
+	// similarity it maps to, from 0 (unrelated) to 1 (identical)
+	Compare(ctx context.Context, original, variant string) (score, similarity float64, err error)
+}
+
+// similarityEngine is a built-in engine and its defaults
+type similarityEngine struct {
+	engine     SimilarityEngine
+	mediaTypes []string // Enabled when the configuration names none
+	threshold  float64
+}
+
+// similarityEngines are the built-in engines; the thresholds sit near the
+// match thresholds commonly used with each algorithm
+var similarityEngines = map[string]similarityEngine{
+	"phash":       {phashEngine{}, []string{"image"}, 0.85},
+	"blockhash":   {blockhashEngine{}, []string{"image"}, 0.90},
+	"ssim":        {ssimEngine{}, []string{"image", "video"}, 0.95},
+	"chromaprint": {chromaprintEngine{}, []string{"audio"}, 0.80},
+	"videohash":   {videohashEngine{}, []string{"video"}, 0.85},
+}
+
+// SimilarityEngineNames lists the built-in engines in a stable order
+func SimilarityEngineNames() []string {
+	names := make([]string, 0, len(similarityEngines))
+	for name := range similarityEngines {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// SimilarityConfig enables one engine for some media types
+type SimilarityConfig struct {
+	Engine     string   `json:"engine"`
+	MediaTypes []string `json:"media_types"`
+	Threshold  float64  `json:"threshold"` // Similarity at or above which the verdict is fail
+}
+
+// ParseSimilarityConfig parses entries of the form engine[:type+type][:threshold],
+// e.g. "ssim:image+video:0.97"; omitted parts take the engine's defaults
+func ParseSimilarityConfig(entries []string) ([]SimilarityConfig, error) {
+	configs := make([]SimilarityConfig, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		name := strings.ToLower(parts[0])
+		builtin, ok := similarityEngines[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown similarity engine %q (known: %s)", name, strings.Join(SimilarityEngineNames(), ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("similarity engine %q configured twice", name)
+		}
+		seen[name] = true
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("similarity engine %q: expected engine[:types][:threshold]", entry)
+		}
+
+		cfg := SimilarityConfig{Engine: name, MediaTypes: builtin.mediaTypes, Threshold: builtin.threshold}
+		if len(parts) > 1 && parts[1] != "" {
+			cfg.MediaTypes = nil
+			for _, mediaType := range strings.Split(parts[1], "+") {
+				if !slices.Contains(builtin.engine.MediaTypes(), mediaType) {
+					return nil, fmt.Errorf("similarity engine %q cannot compare %q (supports %s)", name, mediaType, strings.Join(builtin.engine.MediaTypes(), ", "))
+				}
+				cfg.MediaTypes = append(cfg.MediaTypes, mediaType)
+			}
+		}
+		if len(parts) > 2 {
+			threshold, err := strconv.ParseFloat(parts[2], 64)
+			if err != nil || threshold <= 0 || threshold > 1 {
+				return nil, fmt.Errorf("similarity engine %q: threshold must be in (0, 1]", name)
+			}
+			cfg.Threshold = threshold
+		}
+		configs = append(configs, cfg)
+	}
+	return configs, nil
+}
+
+// SimilarityResult is the outcome of one engine on one input/output pair
+type SimilarityResult struct {
+	Engine     string  `json:"engine"`
+	Score      float64 `json:"score"`      // Raw engine output: a distance, or SSIM
+	Similarity float64 `json:"similarity"` // 0 (unrelated) to 1 (identical)
+	Threshold  float64 `json:"threshold"`
+	Verdict    string  `json:"verdict"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Comparator runs the configured engines on input/output pairs
+type Comparator struct {
+	configs []SimilarityConfig
+}
+
+// NewComparator enables configs, dropping engines whose tools are not
+// installed; it returns the configurations that were dropped
+func NewComparator(configs []SimilarityConfig) (*Comparator, []SimilarityConfig) {
+	c := &Comparator{}
+	var missing []SimilarityConfig
+	for _, cfg := range configs {
+		if toolsInstalled(similarityEngines[cfg.Engine].engine.Tools()) {
+			c.configs = append(c.configs, cfg)
+		} else {
+			missing = append(missing, cfg)
+		}
+	}
+	return c, missing
+}
+
+func toolsInstalled(tools []string) bool {
+	for _, tool := range tools {
+		if _, err := exec.LookPath(toolPath(tool)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Engines returns the enabled engine configurations
+func (c *Comparator) Engines() []SimilarityConfig {
+	return slices.Clone(c.configs)
+}
+
+// Supports reports whether any engine is enabled for mediaType
+func (c *Comparator) Supports(mediaType string) bool {
+	for _, cfg := range c.configs {
+		if slices.Contains(cfg.MediaTypes, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare runs every engine enabled for mediaType on the files at original
+// and variant. An engine that fails reports VerdictError without stopping the others
+func (c *Comparator) Compare(ctx context.Context, mediaType, original, variant string) []SimilarityResult {
+	var results []SimilarityResult
+	for _, cfg := range c.configs {
+		if !slices.Contains(cfg.MediaTypes, mediaType) {
+			continue
+		}
+		result := SimilarityResult{Engine: cfg.Engine, Threshold: cfg.Threshold}
+		score, similarity, err := similarityEngines[cfg.Engine].engine.Compare(ctx, original, variant)
+		switch {
+		case err != nil:
+			result.Verdict, result.Error = VerdictError, err.Error()
+		case similarity >= cfg.Threshold:
+			result.Verdict = VerdictFail
+		default:
+			result.Verdict = VerdictPass
+		}
+		result.Score, result.Similarity = roundScore(score), roundScore(similarity)
+		results = append(results, result)
+	}
+	return results
+}
+
+// OverallVerdict is fail when any engine still matches, pass when every
+// engine compared and none matches, and error otherwise ("" for no results)
+func OverallVerdict(results []SimilarityResult) string {
+	verdict := ""
+	for _, r := range results {
+		switch {
+		case r.Verdict == VerdictFail:
+			return VerdictFail
+		case r.Verdict == VerdictError:
+			verdict = VerdictError
+		case verdict == "":
+			verdict = VerdictPass
+		}
+	}
+	return verdict
+}
+
+func roundScore(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// phashEngine compares the DCT perceptual hashes of two JPEG or PNG images
+type phashEngine struct{}
+
+func (phashEngine) Name() string         { return "phash" }
+func (phashEngine) MediaTypes() []string { return []string{"image"} }
+func (phashEngine) Tools() []string      { return nil }
+
+func (phashEngine) Compare(_ context.Context, original, variant string) (float64, float64, error) {
+	a, b, err := hashFiles(original, variant, func(img image.Image) []uint64 {
+		return []uint64{phashLuma(lumaGrid(img, phashSize))}
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	distance := hashDistance(a, b)
+	return float64(distance), 1 - float64(distance)/64, nil
+}
+
+// blockhashEngine compares 256-bit block mean hashes of two JPEG or PNG images
+type blockhashEngine struct{}
+
+// blockhashSize is the grid of blocks, one bit each
+const blockhashSize = 16
+
+func (blockhashEngine) Name() string         { return "blockhash" }
+func (blockhashEngine) MediaTypes() []string { return []string{"image"} }
+func (blockhashEngine) Tools() []string      { return nil }
+
+func (blockhashEngine) Compare(_ context.Context, original, variant string) (float64, float64, error) {
+	a, b, err := hashFiles(original, variant, blockhash)
+	if err != nil {
+		return 0, 0, err
+	}
+	distance := hashDistance(a, b)
+	return float64(distance), 1 - float64(distance)/(blockhashSize*blockhashSize), nil
+}
+
+// blockhash sets one bit per block whose mean luma is above the median of
+// its horizontal band, each band being a quarter of the image, as blockhash.io
+func blockhash(img image.Image) []uint64 {
+	grid := lumaGrid(img, blockhashSize)
+	rowsPerBand := blockhashSize / 4
+	hash := make([]uint64, 4)
+	for band := range hash {
+		values := make([]float64, 0, rowsPerBand*blockhashSize)
+		for _, row := range grid[band*rowsPerBand : (band+1)*rowsPerBand] {
+			values = append(values, row...)
+		}
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+		for i, v := range values {
+			if v > median {
+				hash[band] |= 1 << uint(i)
+			}
+		}
+	}
+	return hash
+}
+
+// hashFiles decodes and hashes two image files
+func hashFiles(original, variant string, hash func(image.Image) []uint64) ([]uint64, []uint64, error) {
+	var hashes [2][]uint64
+	for i, path := range []string{original, variant} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s", filepath.Base(path))
+		}
+		img, err := decodeHashImage(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		hashes[i] = hash(img)
+	}
+	return hashes[0], hashes[1], nil
+}
+
+// hashDistance is the Hamming distance of two equally long multi-word hashes
+func hashDistance(a, b []uint64) int {
+	distance := 0
+	for i := range a {
+		distance += bits.OnesCount64(a[i] ^ b[i])
+	}
+	return distance
+}
+
+// ssimEngine measures the structural similarity of two images or videos with
+// ffmpeg, scaling the variant to the original's size
+type ssimEngine struct{}
+
+var ssimAllRe = regexp.MustCompile(`SSIM .*All:([0-9.]+)`)
+
+func (ssimEngine) Name() string         { return "ssim" }
+func (ssimEngine) MediaTypes() []string { return []string{"image", "video"} }
+func (ssimEngine) Tools() []string      { return []string{"ffmpeg"} }
+
+func (ssimEngine) Compare(ctx context.Context, original, variant string) (float64, float64, error) {
+	cmd := toolCommand(ctx, "ffmpeg",
+		"-hide_banner", "-nostdin", "-nostats", "-loglevel", "info",
+		"-i", variant, "-i", original,
+		"-lavfi", "[0:v][1:v]scale2ref=flags=bicubic[v][r];[v][r]ssim",
+		"-f", "null", "-",
+	)
+	stderr := newCappedBuffer()
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return 0, 0, newExecError("ffmpeg", err, stderr)
+	}
+
+	m := ssimAllRe.FindStringSubmatch(stderr.String())
+	if m == nil {
+		return 0, 0, fmt.Errorf("ffmpeg reported no SSIM")
+	}
+	ssim, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid SSIM %q", m[1])
+	}
+	return ssim, ssim, nil
+}
+
+// chromaprintEngine compares the raw Chromaprint fingerprints of two audio
+// files, as computed by fpcalc, by their bit error rate
+type chromaprintEngine struct{}
+
+const (
+	chromaprintSeconds   = 120 // Audio fingerprinted from the start of each file
+	chromaprintMaxOffset = 8   // Fingerprint frames (~0.12s each) the alignment search covers
+)
+
+func (chromaprintEngine) Name() string         { return "chromaprint" }
+func (chromaprintEngine) MediaTypes() []string { return []string{"audio"} }
+func (chromaprintEngine) Tools() []string      { return []string{"fpcalc"} }
+
+func (chromaprintEngine) Compare(ctx context.Context, original, variant string) (float64, float64, error) {
+	a, err := chromaprintFingerprint(ctx, original)
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := chromaprintFingerprint(ctx, variant)
+	if err != nil {
+		return 0, 0, err
+	}
+	ber, ok := fingerprintBitErrorRate(a, b, chromaprintMaxOffset)
+	if !ok {
+		return 0, 0, fmt.Errorf("audio too short to fingerprint")
+	}
+	return ber, 1 - ber, nil
+}
+
+func chromaprintFingerprint(ctx context.Context, path string) ([]uint32, error) {
+	cmd := toolCommand(ctx, "fpcalc", "-raw", "-json", "-length", strconv.Itoa(chromaprintSeconds), path)
+	stderr := newCappedBuffer()
+	cmd.Stderr = stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, newExecError("fpcalc", err, stderr)
+	}
+	var result struct {
+		Fingerprint []uint32 `json:"fingerprint"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("invalid fpcalc output: %w", err)
+	}
+	return result.Fingerprint, nil
+}
+
+// fingerprintBitErrorRate is the lowest share of differing bits between a
+// and b shifted by up to maxOffset frames, over an overlap of at least half
+// the shorter fingerprint
+func fingerprintBitErrorRate(a, b []uint32, maxOffset int) (float64, bool) {
+	minOverlap := max(min(len(a), len(b))/2, 1)
+	best, found := 1.0, false
+	for offset := -maxOffset; offset <= maxOffset; offset++ {
+		differing, overlap := 0, 0
+		for i := range a {
+			j := i + offset
+			if j < 0 || j >= len(b) {
+				continue
+			}
+			differing += bits.OnesCount32(a[i] ^ b[j])
+			overlap++
+		}
+		if overlap < minOverlap {
+			continue
+		}
+		if ber := float64(differing) / float64(overlap*32); ber < best {
+			best = ber
+		}
+		found = true
+	}
+	return best, found
+}
+
+// videohashEngine compares the perceptual hashes of one frame per second of
+// two videos, averaging the distance of frames at the same position
+type videohashEngine struct{}
+
+const videohashMaxFrames = 60
+
+func (videohashEngine) Name() string         { return "videohash" }
+func (videohashEngine) MediaTypes() []string { return []string{"video"} }
+func (videohashEngine) Tools() []string      { return []string{"ffmpeg"} }
+
+func (videohashEngine) Compare(ctx context.Context, original, variant string) (float64, float64, error) {
+	a, err := videoFrameHashes(ctx, original)
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := videoFrameHashes(ctx, variant)
+	if err != nil {
+		return 0, 0, err
+	}
+	frames := min(len(a), len(b))
+	if frames == 0 {
+		return 0, 0, fmt.Errorf("no frames decoded")
+	}
+	total := 0
+	for i := 0; i < frames; i++ {
+		total += HammingDistance(a[i], b[i])
+	}
+	mean := float64(total) / float64(frames)
+	return mean, 1 - mean/64, nil
+}
+
+// videoFrameHashes decodes one phashSize x phashSize gray frame per second and hashes each
+func videoFrameHashes(ctx context.Context, path string) ([]uint64, error) {
+	cmd := toolCommand(ctx, "ffmpeg",
+		"-hide_banner", "-nostdin", "-loglevel", "error",
+		"-i", path,
+		"-vf", fmt.Sprintf("fps=1,scale=%d:%d:flags=area,format=gray", phashSize, phashSize),
+		"-frames:v", strconv.Itoa(videohashMaxFrames),
+		"-f", "rawvideo", "pipe:1",
+	)
+	output, err := execFFmpeg(cmd, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	const frameSize = phashSize * phashSize
+	hashes := make([]uint64, 0, len(output)/frameSize)
+	for off := 0; off+frameSize <= len(output); off += frameSize {
+		pixels := make([][]float64, phashSize)
+		for y := range pixels {
+			pixels[y] = make([]float64, phashSize)
+			for x := range pixels[y] {
+				pixels[y][x] = float64(output[off+y*phashSize+x])
+			}
+		}
+		hashes = append(hashes, phashLuma(pixels))
+	}
+	return hashes, nil
+}
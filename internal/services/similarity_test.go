@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"image/color"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseSimilarityConfig(t *testing.T) {
+	configs, err := ParseSimilarityConfig([]string{"phash", "ssim:video:0.97", "blockhash::0.8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SimilarityConfig{
+		{Engine: "phash", MediaTypes: []string{"image"}, Threshold: 0.85},
+		{Engine: "ssim", MediaTypes: []string{"video"}, Threshold: 0.97},
+		{Engine: "blockhash", MediaTypes: []string{"image"}, Threshold: 0.8},
+	}
+	for i, cfg := range configs {
+		if cfg.Engine != want[i].Engine || !slices.Equal(cfg.MediaTypes, want[i].MediaTypes) || cfg.Threshold != want[i].Threshold {
+			t.Errorf("config %d = %+v, want %+v", i, cfg, want[i])
+		}
+	}
+
+	for _, entries := range [][]string{
+		{"md5"},
+		{"phash", "phash"},
+		{"chromaprint:image"},
+		{"phash:image:1.5"},
+		{"phash:image:x"},
+		{"phash:image:0.9:extra"},
+	} {
+		if _, err := ParseSimilarityConfig(entries); err == nil {
+			t.Errorf("%v accepted", entries)
+		}
+	}
+}
+
+func TestComparatorImageVerdicts(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	original := write("original.png", encodeTestPNG(t, patternImage(64, 64, false)))
+	tweakedImg := patternImage(64, 64, false)
+	tweakedImg.SetGray(10, 10, color.Gray{Y: 255})
+	tweaked := write("tweaked.png", encodeTestPNG(t, tweakedImg))
+	inverted := write("inverted.png", encodeTestPNG(t, patternImage(64, 64, true)))
+	garbage := write("garbage.png", []byte("not an image"))
+
+	configs, _ := ParseSimilarityConfig([]string{"phash", "blockhash", "chromaprint"})
+	c, _ := NewComparator(configs)
+	if !c.Supports("image") || c.Supports("document") {
+		t.Fatal("Supports does not follow the configured media types")
+	}
+
+	results := c.Compare(context.Background(), "image", original, tweaked)
+	if len(results) != 2 || OverallVerdict(results) != VerdictFail {
+		t.Fatalf("near-identical: %+v", results)
+	}
+	results = c.Compare(context.Background(), "image", original, inverted)
+	if OverallVerdict(results) != VerdictPass {
+		t.Fatalf("inverted: %+v", results)
+	}
+	for _, r := range results {
+		if r.Similarity >= r.Threshold || r.Score <= 0 {
+			t.Errorf("inverted %s: %+v", r.Engine, r)
+		}
+	}
+	results = c.Compare(context.Background(), "image", original, garbage)
+	if OverallVerdict(results) != VerdictError || results[0].Error == "" {
+		t.Fatalf("garbage: %+v", results)
+	}
+	if results := c.Compare(context.Background(), "audio", original, tweaked); len(results) > 1 {
+		t.Errorf("image engines ran on audio: %+v", results)
+	}
+}
+
+func TestOverallVerdict(t *testing.T) {
+	tests := []struct {
+		verdicts []string
+		want     string
+	}{
+		{nil, ""},
+		{[]string{VerdictPass, VerdictPass}, VerdictPass},
+		{[]string{VerdictPass, VerdictError}, VerdictError},
+		{[]string{VerdictError, VerdictFail}, VerdictFail},
+	}
+	for _, tt := range tests {
+		var results []SimilarityResult
+		for _, v := range tt.verdicts {
+			results = append(results, SimilarityResult{Verdict: v})
+		}
+		if got := OverallVerdict(results); got != tt.want {
+			t.Errorf("OverallVerdict(%v) = %q, want %q", tt.verdicts, got, tt.want)
+		}
+	}
+}
+
+func TestFingerprintBitErrorRate(t *testing.T) {
+	a := make([]uint32, 40)
+	for i := range a {
+		a[i] = uint32(i) * 2654435761
+	}
+	// The same fingerprint delayed by three frames aligns exactly
+	shifted := append([]uint32{1, 2, 3}, a...)
+	if ber, ok := fingerprintBitErrorRate(a, shifted, 8); !ok || ber != 0 {
+		t.Errorf("shifted: ber = %v, ok = %v", ber, ok)
+	}
+
+	inverted := make([]uint32, len(a))
+	for i := range a {
+		inverted[i] = ^a[i]
+	}
+	if ber, _ := fingerprintBitErrorRate(a, inverted, 0); ber != 1 {
+		t.Errorf("inverted: ber = %v", ber)
+	}
+
+	if _, ok := fingerprintBitErrorRate(nil, a, 8); ok {
+		t.Error("empty fingerprint compared")
+	}
+}
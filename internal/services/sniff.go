@@ -0,0 +1,99 @@
+package services
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// contentTypeFormats maps MIME types to media type and format
+var contentTypeFormats = map[string][2]string{
+	"audio/mpeg":      {"audio", "mp3"},
+	"audio/ogg":       {"audio", "ogg"},
+	"audio/opus":      {"audio", "opus"},
+	"audio/mp4":       {"audio", "m4a"},
+	"audio/x-m4a":     {"audio", "m4a"},
+	"audio/wav":       {"audio", "wav"},
+	"audio/x-wav":     {"audio", "wav"},
+	"audio/aac":       {"audio", "aac"},
+	"image/jpeg":      {"image", "jpg"},
+	"image/png":       {"image", "png"},
+	"image/webp":      {"image", "webp"},
+	"image/svg+xml":   {"image", "svg"},
+	"image/tiff":      {"image", "tiff"},
+	"image/bmp":       {"image", "bmp"},
+	"video/mp4":       {"video", "mp4"},
+	"video/quicktime": {"video", "mov"},
+	"video/3gpp":      {"video", "3gp"},
+	"video/mp2t":      {"video", "ts"},
+	"video/webm":      {"video", "webm"},
+	"application/pdf": {"document", "pdf"},
+}
+
+// MediaFromContentType classifies a MIME type (without parameters)
+// Returns empty strings for generic types such as application/octet-stream
+func MediaFromContentType(contentType string) (mediaType, format string) {
+	if f, ok := contentTypeFormats[contentType]; ok {
+		return f[0], f[1]
+	}
+	return "", ""
+}
+
+// SniffMedia classifies downloaded bytes by their signature
+// Returns empty strings when the content is not recognized; formats use the
+// same names as URL detection (jpg, png, mp4, ...)
+func SniffMedia(data []byte) (mediaType, format string) {
+	if bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "document", "pdf"
+	}
+
+	switch f := detectImageFormat(data); f {
+	case "unknown":
+	case "jpeg":
+		return "image", "jpg"
+	default:
+		return "image", f
+	}
+
+	if detectVideoContainer(data) == "ts" {
+		return "video", "ts"
+	}
+
+	// ISO BMFF: the ftyp brand separates audio-only M4A from video
+	if len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")) {
+		switch brand := string(data[8:12]); {
+		case brand == "M4A " || brand == "M4B ":
+			return "audio", "m4a"
+		case brand[:3] == "3gp" || brand[:3] == "3g2":
+			return "video", "3gp"
+		case brand == "qt  ":
+			return "video", "mov"
+		default:
+			return "video", "mp4"
+		}
+	}
+
+	return "", ""
+}
+
+// textPayload names the kind of a textual download that is not media (html,
+// xml, json or text), such as a CDN error page served with status 200.
+// Returns "" for anything else, including SVG images
+func textPayload(data []byte) string {
+	if mediaType, _ := SniffMedia(data); mediaType != "" {
+		return ""
+	}
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	switch contentType := http.DetectContentType(trimmed); {
+	case strings.HasPrefix(contentType, "text/html"):
+		return "html"
+	case strings.HasPrefix(contentType, "text/xml"):
+		return "xml"
+	case strings.HasPrefix(contentType, "text/plain"):
+		if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			return "json"
+		}
+		return "text"
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package services
+
+import "testing"
+
+func TestSniffMedia(t *testing.T) {
+	pad := make([]byte, 16)
+	withPad := func(header ...byte) []byte { return append(header, pad...) }
+	ftyp := func(brand string) []byte {
+		return withPad(append([]byte{0, 0, 0, 0x18, 'f', 't', 'y', 'p'}, brand...)...)
+	}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		mediaType string
+		format    string
+	}{
+		{"jpeg", withPad(0xFF, 0xD8, 0xFF), "image", "jpg"},
+		{"png", withPad(0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A), "image", "png"},
+		{"pdf", withPad([]byte("%PDF-1.7")...), "document", "pdf"},
+		{"mp4", ftyp("isom"), "video", "mp4"},
+		{"3gp", ftyp("3gp4"), "video", "3gp"},
+		{"m4a", ftyp("M4A "), "audio", "m4a"},
+		{"unknown", withPad([]byte("hello world")...), "", ""},
+	}
+
+	for _, tt := range tests {
+		mediaType, format := SniffMedia(tt.data)
+		if mediaType != tt.mediaType || format != tt.format {
+			t.Errorf("%s: got (%q, %q), want (%q, %q)", tt.name, mediaType, format, tt.mediaType, tt.format)
+		}
+	}
+}
+
+func TestMediaFromContentType(t *testing.T) {
+	if mediaType, format := MediaFromContentType("video/quicktime"); mediaType != "video" || format != "mov" {
+		t.Errorf("video/quicktime: got (%q, %q)", mediaType, format)
+	}
+	if mediaType, _ := MediaFromContentType("application/octet-stream"); mediaType != "" {
+		t.Errorf("octet-stream should be unclassified, got %q", mediaType)
+	}
+}
+
+func TestTextPayload(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"html error page", "<!DOCTYPE html><html><head><title>403 Forbidden</title></head></html>", "html"},
+		{"html with bom", "\xef\xbb\xbf\n  <html><body>Not Found</body></html>", "html"},
+		{"json error", `{"error":"AccessDenied","message":"Request has expired"}`, "json"},
+		{"xml error", `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code></Error>`, "xml"},
+		{"plain text", "Service Unavailable", "text"},
+		{"svg image", `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" width="1" height="1"/>`, ""},
+		{"pdf", "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n", ""},
+		{"binary", "\x00\x01\xfe\x7fID3", ""},
+		{"wav", string(makeSineWAV(0.01, 8000)), ""},
+	}
+	for _, tt := range tests {
+		if got := textPayload([]byte(tt.data)); got != tt.want {
+			t.Errorf("%s: textPayload = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
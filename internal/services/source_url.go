@@ -0,0 +1,187 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	neturl "net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxSourceURLLength caps accepted source URLs (presigned URLs are ~1-1.5KB)
+const MaxSourceURLLength = 2048
+
+// URLError describes why a source URL was rejected; Code is stable for clients
+type URLError struct {
+	Code   string // url_too_long, url_invalid, url_scheme, url_credentials, url_fragment, url_host
+	Reason string
+}
+
+func (e *URLError) Error() string {
+	return e.Reason
+}
+
+// ValidateSourceURL checks a source URL before it is logged or downloaded and
+// returns it normalized: scheme and host lowercased, IDN hosts in punycode
+func ValidateSourceURL(raw string) (string, error) {
+	if len(raw) > MaxSourceURLLength {
+		return "", &URLError{"url_too_long", fmt.Sprintf("URL longer than %d characters", MaxSourceURLLength)}
+	}
+	if !utf8.ValidString(raw) || strings.IndexFunc(raw, func(r rune) bool { return unicode.IsControl(r) || unicode.IsSpace(r) }) >= 0 {
+		return "", &URLError{"url_invalid", "URL contains whitespace, control characters or invalid UTF-8"}
+	}
+
+	u, err := neturl.Parse(raw)
+	if err != nil {
+		return "", &URLError{"url_invalid", "URL could not be parsed"}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", &URLError{"url_scheme", "URL scheme must be http or https"}
+	}
+	if u.User != nil {
+		return "", &URLError{"url_credentials", "URL must not contain credentials"}
+	}
+	if u.Fragment != "" || strings.Contains(raw, "#") {
+		return "", &URLError{"url_fragment", "URL must not contain a fragment"}
+	}
+
+	host, err := asciiHost(u.Hostname())
+	if err != nil {
+		return "", &URLError{"url_host", err.Error()}
+	}
+	if port := u.Port(); port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	u.Host = host
+
+	return u.String(), nil
+}
+
+// asciiHost lowercases a hostname and converts internationalized labels to punycode
+func asciiHost(host string) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("URL has no host")
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(host, ".")), ".")
+	for i, label := range labels {
+		if label == "" || len(label) > 63 {
+			return "", fmt.Errorf("invalid host %q", host)
+		}
+		if isASCII(label) {
+			if strings.IndexFunc(label, func(r rune) bool {
+				return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+			}) >= 0 {
+				return "", fmt.Errorf("invalid character in host %q", host)
+			}
+			continue
+		}
+		labels[i] = "xn--" + punycodeEncode(label)
+	}
+	return strings.Join(labels, "."), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// punycodeEncode implements the RFC 3492 encoder for a single label
+func punycodeEncode(label string) string {
+	const (
+		base        = 36
+		tMin        = 1
+		tMax        = 26
+		skew        = 38
+		damp        = 700
+		initialBias = 72
+		initialN    = 128
+	)
+
+	adapt := func(delta, numPoints int, first bool) int {
+		if first {
+			delta /= damp
+		} else {
+			delta /= 2
+		}
+		delta += delta / numPoints
+		k := 0
+		for delta > ((base-tMin)*tMax)/2 {
+			delta /= base - tMin
+			k += base
+		}
+		return k + (base-tMin+1)*delta/(delta+skew)
+	}
+	digit := func(d int) byte {
+		if d < 26 {
+			return byte('a' + d)
+		}
+		return byte('0' + d - 26)
+	}
+
+	runes := []rune(label)
+	var out []byte
+	for _, r := range runes {
+		if r < 0x80 {
+			out = append(out, byte(r))
+		}
+	}
+	basicCount := len(out)
+	handled := basicCount
+	if basicCount > 0 {
+		out = append(out, '-')
+	}
+
+	n, delta, bias := initialN, 0, initialBias
+	for handled < len(runes) {
+		m := int(unicode.MaxRune) + 1
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		delta += (m - n) * (handled + 1)
+		n = m
+
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := base; ; k += base {
+				t := k - bias
+				if t < tMin {
+					t = tMin
+				} else if t > tMax {
+					t = tMax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, digit(t+(q-t)%(base-t)))
+				q = (q - t) / (base - t)
+			}
+			out = append(out, digit(q))
+			bias = adapt(delta, handled+1, handled == basicCount)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+
+	return string(out)
+}
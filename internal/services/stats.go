@@ -0,0 +1,246 @@
+package services
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	histSubBuckets = 16                  // Buckets per power of two, bounds quantile error to ~6%
+	histBuckets    = histSubBuckets * 40 // Covers up to 2^40µs (~12 days)
+)
+
+// ConverterStats tracks conversion metrics
+type ConverterStats struct {
+	TotalConversions    int64                  `json:"total_conversions"`
+	FailedConversions   int64                  `json:"failed_conversions"`
+	FallbackConversions int64                  `json:"fallback_conversions"` // Succeeded only with the fallback strategy
+	DurationMismatches  int64                  `json:"duration_mismatches"`  // Outputs whose duration was off (see DurationCheck)
+	AvgConversionTime   time.Duration          `json:"avg_ns"`
+	P50ConversionTime   time.Duration          `json:"p50_ns"`
+	P95ConversionTime   time.Duration          `json:"p95_ns"`
+	P99ConversionTime   time.Duration          `json:"p99_ns"`
+	LastError           string                 `json:"last_error,omitempty"`
+	LastErrorAt         time.Time              `json:"last_error_at,omitempty"`
+	Formats             map[string]FormatStats `json:"formats,omitempty"` // Keyed by input format
+}
+
+// FormatStats is the breakdown of ConverterStats for one input format
+type FormatStats struct {
+	Conversions int64            `json:"conversions"`
+	Failures    int64            `json:"failures"`
+	FailureRate float64          `json:"failure_rate"`     // Failures / Conversions
+	Errors      map[string]int64 `json:"errors,omitempty"` // Failures by FailureClass
+	P50         time.Duration    `json:"p50_ns"`
+	P95         time.Duration    `json:"p95_ns"`
+	P99         time.Duration    `json:"p99_ns"`
+}
+
+// latencyHistogram is a lock-free log-linear histogram of durations in the
+// spirit of HDR histograms, with microsecond resolution
+type latencyHistogram struct {
+	counts [histBuckets]atomic.Int64
+	sumUs  atomic.Int64
+}
+
+// histIndex maps a value in µs to its bucket: values below histSubBuckets
+// get their own bucket, larger ones keep their top 5 significant bits
+func histIndex(us int64) int {
+	if us < histSubBuckets {
+		return int(max(us, 0))
+	}
+	shift := bits.Len64(uint64(us)) - 5
+	i := (shift+1)*histSubBuckets + int(us>>shift) - histSubBuckets
+	return min(i, histBuckets-1)
+}
+
+// histUpper is the highest value in µs that falls into bucket i
+func histUpper(i int) int64 {
+	if i < histSubBuckets {
+		return int64(i)
+	}
+	shift := i/histSubBuckets - 1
+	sub := int64(i%histSubBuckets + histSubBuckets)
+	return (sub+1)<<shift - 1
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	us := d.Microseconds()
+	h.counts[histIndex(us)].Add(1)
+	h.sumUs.Add(us)
+}
+
+// quantiles returns the values at qs (0-1) and the number of recorded values
+func (h *latencyHistogram) quantiles(qs ...float64) ([]time.Duration, int64) {
+	var counts [histBuckets]int64
+	var total int64
+	for i := range h.counts {
+		counts[i] = h.counts[i].Load()
+		total += counts[i]
+	}
+
+	out := make([]time.Duration, len(qs))
+	if total == 0 {
+		return out, 0
+	}
+	for j, q := range qs {
+		rank := max(int64(math.Ceil(q*float64(total))), 1)
+		var seen int64
+		for i, c := range counts {
+			if seen += c; seen >= rank {
+				out[j] = time.Duration(histUpper(i)) * time.Microsecond
+				break
+			}
+		}
+	}
+	return out, total
+}
+
+// formatStats counts the conversions of one input format
+type formatStats struct {
+	failures [len(FailureClasses)]atomic.Int64 // Indexed like FailureClasses
+	latency  latencyHistogram                  // Successful conversions only
+}
+
+// lastError is the most recent conversion error
+type lastError struct {
+	message string
+	at      time.Time
+}
+
+// statsRecorder collects converter metrics without locking on the hot path
+type statsRecorder struct {
+	failed             atomic.Int64
+	fallback           atomic.Int64
+	durationMismatches atomic.Int64
+	latency            latencyHistogram // Successful conversions only
+	last               atomic.Pointer[lastError]
+	formats            sync.Map // Input format -> *formatStats
+}
+
+func (s *statsRecorder) recordSuccess(duration time.Duration) {
+	s.latency.record(duration)
+}
+
+func (s *statsRecorder) recordFailure() {
+	s.failed.Add(1)
+}
+
+func (s *statsRecorder) recordFallback() {
+	s.fallback.Add(1)
+}
+
+// observe records the outcome of one Process call for an input format
+// of mediaType; formats the media type does not accept count as "other"
+func (s *statsRecorder) observe(mediaType, format string, duration time.Duration, err error) {
+	format = strings.ToLower(format)
+	switch {
+	case format == "":
+		format = "unknown"
+	case !slices.Contains(inputFormats[mediaType], format):
+		format = "other"
+	}
+	fs := s.format(format)
+	if err != nil {
+		fs.failures[slices.Index(FailureClasses[:], FailureClass(err))].Add(1)
+		s.last.Store(&lastError{message: err.Error(), at: time.Now()})
+		return
+	}
+	fs.latency.record(duration)
+}
+
+// format returns the counters of format
+func (s *statsRecorder) format(format string) *formatStats {
+	if fs, ok := s.formats.Load(format); ok {
+		return fs.(*formatStats)
+	}
+	fs, _ := s.formats.LoadOrStore(format, new(formatStats))
+	return fs.(*formatStats)
+}
+
+// snapshot returns the current statistics
+func (s *statsRecorder) snapshot() ConverterStats {
+	ps, total := s.latency.quantiles(0.5, 0.95, 0.99)
+	stats := ConverterStats{
+		TotalConversions:    total,
+		FailedConversions:   s.failed.Load(),
+		FallbackConversions: s.fallback.Load(),
+		DurationMismatches:  s.durationMismatches.Load(),
+		P50ConversionTime:   ps[0],
+		P95ConversionTime:   ps[1],
+		P99ConversionTime:   ps[2],
+	}
+	if total > 0 {
+		stats.AvgConversionTime = time.Duration(s.latency.sumUs.Load()/total) * time.Microsecond
+	}
+	if last := s.last.Load(); last != nil {
+		stats.LastError, stats.LastErrorAt = last.message, last.at
+	}
+
+	var names []string
+	s.formats.Range(func(key, _ any) bool {
+		names = append(names, key.(string))
+		return true
+	})
+	if len(names) > 0 {
+		sort.Strings(names)
+		stats.Formats = make(map[string]FormatStats, len(names))
+		for _, name := range names {
+			stats.Formats[name] = s.format(name).snapshot()
+		}
+	}
+	return stats
+}
+
+func (fs *formatStats) snapshot() FormatStats {
+	ps, n := fs.latency.quantiles(0.5, 0.95, 0.99)
+	stats := FormatStats{P50: ps[0], P95: ps[1], P99: ps[2]}
+	for i, class := range FailureClasses {
+		if count := fs.failures[i].Load(); count > 0 {
+			if stats.Errors == nil {
+				stats.Errors = make(map[string]int64)
+			}
+			stats.Errors[class] = count
+			stats.Failures += count
+		}
+	}
+	stats.Conversions = n + stats.Failures
+	if stats.Conversions > 0 {
+		stats.FailureRate = float64(stats.Failures) / float64(stats.Conversions)
+	}
+	return stats
+}
+
+// FormatStatsRow is one media type and input format of a stats table
+type FormatStatsRow struct {
+	MediaType string `json:"media_type"`
+	Format    string `json:"format"`
+	FormatStats
+}
+
+// FormatTable flattens the per-format stats of every converter, highest
+// failure rate first so the most troublesome formats stand out
+func (r *Registry) FormatTable() []FormatStatsRow {
+	rows := make([]FormatStatsRow, 0)
+	for _, mediaType := range r.MediaTypes() {
+		for format, fs := range r.converters[mediaType].GetStats().Formats {
+			rows = append(rows, FormatStatsRow{MediaType: mediaType, Format: format, FormatStats: fs})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].FailureRate != rows[j].FailureRate {
+			return rows[i].FailureRate > rows[j].FailureRate
+		}
+		if rows[i].MediaType != rows[j].MediaType {
+			return rows[i].MediaType < rows[j].MediaType
+		}
+		return rows[i].Format < rows[j].Format
+	})
+	return rows
+}
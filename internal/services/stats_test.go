@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistIndexRoundTrip(t *testing.T) {
+	prev := -1
+	for us := int64(0); us < 1<<20; us += 7 {
+		i := histIndex(us)
+		if i < prev {
+			t.Fatalf("histIndex(%d) = %d, below previous %d", us, i, prev)
+		}
+		prev = i
+		upper := histUpper(i)
+		if upper < us {
+			t.Fatalf("histUpper(%d) = %d, below value %d", i, upper, us)
+		}
+		// Relative error stays within one sub-bucket
+		if us >= histSubBuckets && float64(upper-us)/float64(us) > 1.0/histSubBuckets {
+			t.Fatalf("value %d reported as %d", us, upper)
+		}
+	}
+}
+
+func TestStatsRecorderPercentiles(t *testing.T) {
+	var s statsRecorder
+	for i := 1; i <= 100; i++ {
+		d := time.Duration(i) * time.Millisecond
+		s.recordSuccess(d)
+		s.observe("audio", "mp3", d, nil)
+	}
+	s.recordFailure()
+	s.observe("audio", "wav", time.Second, errors.New("ffmpeg failed"))
+	s.observe("audio", "WAV", time.Second, &ExecError{Tool: "ffmpeg", Kind: ErrInvalidInput})
+
+	stats := s.snapshot()
+	if stats.TotalConversions != 100 || stats.FailedConversions != 1 {
+		t.Fatalf("counts = %d/%d", stats.TotalConversions, stats.FailedConversions)
+	}
+	within := func(got, want time.Duration) bool {
+		return got >= want && got <= want+want/histSubBuckets
+	}
+	if !within(stats.P50ConversionTime, 50*time.Millisecond) ||
+		!within(stats.P95ConversionTime, 95*time.Millisecond) ||
+		!within(stats.P99ConversionTime, 99*time.Millisecond) {
+		t.Errorf("percentiles = %v/%v/%v", stats.P50ConversionTime, stats.P95ConversionTime, stats.P99ConversionTime)
+	}
+	if stats.AvgConversionTime != 50500*time.Microsecond {
+		t.Errorf("avg = %v", stats.AvgConversionTime)
+	}
+	if stats.LastError == "" || stats.LastErrorAt.IsZero() {
+		t.Errorf("last error = %q at %v", stats.LastError, stats.LastErrorAt)
+	}
+	if mp3 := stats.Formats["mp3"]; mp3.Conversions != 100 || mp3.Failures != 0 {
+		t.Errorf("mp3 = %+v", mp3)
+	}
+	wav := stats.Formats["wav"]
+	if wav.Conversions != 2 || wav.Failures != 2 || wav.FailureRate != 1 {
+		t.Errorf("wav = %+v", wav)
+	}
+	if wav.Errors["other"] != 1 || wav.Errors["invalid_input"] != 1 {
+		t.Errorf("wav errors = %v", wav.Errors)
+	}
+}
+
+func TestStatsRecorderConcurrent(t *testing.T) {
+	var s statsRecorder
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				s.recordSuccess(time.Millisecond)
+				s.observe("image", fmt.Sprintf("f%d", i%4), time.Millisecond, nil)
+				s.observe("image", inputFormats["image"][i%len(inputFormats["image"])], time.Millisecond, nil)
+				_ = s.snapshot()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	stats := s.snapshot()
+	if stats.TotalConversions != 4000 {
+		t.Errorf("total = %d", stats.TotalConversions)
+	}
+	// Unknown formats share one "other" entry
+	if len(stats.Formats) != len(inputFormats["image"])+1 || stats.Formats["other"].Conversions != 4000 {
+		t.Errorf("formats = %v", stats.Formats)
+	}
+	var sum int64
+	for _, fs := range stats.Formats {
+		sum += fs.Conversions
+	}
+	if sum != 8000 {
+		t.Errorf("format conversions sum to %d", sum)
+	}
+}
+
+func TestFormatTableOrdersByFailureRate(t *testing.T) {
+	audio := NewAudioConverter(nil, nil)
+	image := NewImageConverter(nil, nil, "jpeg")
+	for i := 0; i < 10; i++ {
+		audio.observe("mp3", time.Millisecond, nil)
+		image.observe("png", time.Millisecond, nil)
+		var err error
+		if i%2 == 0 {
+			err = errors.New("decode failed")
+		}
+		image.observe("webp", time.Millisecond, err)
+	}
+	image.observe("jpg", time.Millisecond, errors.New("decode failed"))
+
+	rows := NewRegistry(audio, image).FormatTable()
+	var got []string
+	for _, row := range rows {
+		got = append(got, fmt.Sprintf("%s/%s=%.2f", row.MediaType, row.Format, row.FailureRate))
+	}
+	want := []string{"image/jpg=1.00", "image/webp=0.50", "audio/mp3=0.00", "image/png=0.00"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("FormatTable = %v, want %v", got, want)
+	}
+}
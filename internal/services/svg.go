@@ -0,0 +1,216 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// svgDropElements are removed together with their whole subtree
+var svgDropElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true, // Can embed arbitrary HTML
+}
+
+// svgAnimationElements can set attributes after sanitizing
+var svgAnimationElements = map[string]bool{
+	"set": true, "animate": true, "animatetransform": true, "animatemotion": true,
+}
+
+// svgJitterAttrs hold plain numbers or number lists that tolerate sub-visual jitter
+var svgJitterAttrs = map[string]bool{
+	"x": true, "y": true, "x1": true, "y1": true, "x2": true, "y2": true,
+	"cx": true, "cy": true, "r": true, "rx": true, "ry": true,
+	"width": true, "height": true, "points": true, "d": true,
+}
+
+var (
+	svgNumberRe    = regexp.MustCompile(`-?(?:\d+\.\d*|\.\d+|\d+)(?:[eE][-+]?\d+)?`)
+	svgImportRe    = regexp.MustCompile(`(?i)@import[^;]*;?`)
+	svgExtURLRe    = regexp.MustCompile(`(?i)url\(\s*['"]?\s*(?:https?:|//|javascript:)[^)]*\)`)
+	svgSafeDataRe  = regexp.MustCompile(`(?i)^data:image/(?:png|jpe?g|gif|webp);`)
+	svgScriptURLRe = regexp.MustCompile(`(?i)(?:javascript|data)\s*:`)
+)
+
+// isSVG sniffs the first bytes for an <svg> root element
+func isSVG(data []byte) bool {
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	return bytes.Contains(bytes.ToLower(head), []byte("<svg"))
+}
+
+// sanitizeSVG strips scripts, event handlers and external references, then makes
+// the document byte-unique by shuffling attribute order, jittering coordinates
+// far below rendering precision and, with marker, injecting a nonce comment
+func sanitizeSVG(data []byte, nonce *ProcessingNonce, marker bool) ([]byte, error) {
+	rng := mathrand.New(mathrand.NewSource(nonce.GetSeedForRand()))
+
+	// RawToken keeps namespace prefixes as written (xlink:href stays xlink:href)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	var out bytes.Buffer
+	skipDepth := 0
+	rootSeen := false
+	inStyle := false
+
+	for {
+		tok, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("svg parse failed: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 || svgDropElements[strings.ToLower(t.Name.Local)] || isUnsafeSVGAnimation(t) {
+				skipDepth++
+				continue
+			}
+			inStyle = strings.EqualFold(t.Name.Local, "style")
+
+			attrs := sanitizeSVGAttrs(t.Attr, rng)
+			rng.Shuffle(len(attrs), func(i, j int) { attrs[i], attrs[j] = attrs[j], attrs[i] })
+
+			out.WriteString("<" + svgName(t.Name))
+			for _, a := range attrs {
+				out.WriteString(" " + svgName(a.Name) + `="`)
+				escapeSVGAttr(&out, a.Value)
+				out.WriteString(`"`)
+			}
+			out.WriteString(">")
+
+			if !rootSeen && strings.EqualFold(t.Name.Local, "svg") {
+				rootSeen = true
+				if marker {
+					fmt.Fprintf(&out, "<!-- uid:%s -->", nonce.Nonce)
+				}
+			}
+
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			inStyle = false
+			out.WriteString("</" + svgName(t.Name) + ">")
+
+		case xml.CharData:
+			if skipDepth > 0 {
+				continue
+			}
+			text := []byte(t)
+			if inStyle {
+				text = svgImportRe.ReplaceAll(text, nil)
+				text = svgExtURLRe.ReplaceAll(text, []byte("none"))
+			}
+			xml.EscapeText(&out, text)
+
+		case xml.Comment:
+			// Original comments can carry editor fingerprints; drop them
+
+		case xml.ProcInst:
+			if skipDepth > 0 || t.Target != "xml" {
+				continue // Only the XML declaration is kept (no stylesheets)
+			}
+			fmt.Fprintf(&out, "<?%s %s?>", t.Target, t.Inst)
+
+		case xml.Directive:
+			// DOCTYPE/ENTITY declarations are dropped to avoid entity tricks
+		}
+	}
+
+	if !rootSeen {
+		return nil, fmt.Errorf("no <svg> root element found")
+	}
+
+	return out.Bytes(), nil
+}
+
+// isUnsafeSVGAnimation reports whether an animation element would bring
+// back a link or a script URL: one animating href, or one whose values
+// hold a javascript: or data: URL
+func isUnsafeSVGAnimation(t xml.StartElement) bool {
+	if !svgAnimationElements[strings.ToLower(t.Name.Local)] {
+		return false
+	}
+	for _, a := range t.Attr {
+		switch strings.ToLower(a.Name.Local) {
+		case "attributename":
+			name := strings.ToLower(strings.TrimSpace(a.Value))
+			if i := strings.LastIndex(name, ":"); i >= 0 {
+				name = name[i+1:] // xlink:href
+			}
+			if name == "href" {
+				return true
+			}
+		case "to", "from", "values", "by":
+			if svgScriptURLRe.MatchString(a.Value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sanitizeSVGAttrs drops event handlers and external references and jitters geometry
+// Any attribute may reference external content through url(), e.g. fill,
+// filter, mask, clip-path and marker-*, so every value is cleaned
+func sanitizeSVGAttrs(attrs []xml.Attr, rng *mathrand.Rand) []xml.Attr {
+	kept := make([]xml.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		local := strings.ToLower(a.Name.Local)
+		value := strings.TrimSpace(a.Value)
+		a.Value = svgExtURLRe.ReplaceAllString(a.Value, "none")
+
+		switch {
+		case strings.HasPrefix(local, "on"):
+			continue // onload, onclick, ...
+		case local == "href":
+			if !strings.HasPrefix(value, "#") && !svgSafeDataRe.MatchString(value) {
+				continue
+			}
+		case svgJitterAttrs[local]:
+			// Arc flags in paths must stay exactly 0/1
+			if !(local == "d" && strings.ContainsAny(a.Value, "Aa")) {
+				a.Value = jitterSVGNumbers(a.Value, rng)
+			}
+		}
+		kept = append(kept, a)
+	}
+	return kept
+}
+
+// jitterSVGNumbers shifts every number by less than 0.001 user units
+func jitterSVGNumbers(value string, rng *mathrand.Rand) string {
+	return svgNumberRe.ReplaceAllStringFunc(value, func(num string) string {
+		f, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			return num
+		}
+		f += (rng.Float64()*2 - 1) * 0.0009
+		return strconv.FormatFloat(f, 'f', 4, 64)
+	})
+}
+
+// svgName renders a raw (prefix-preserving) XML name
+func svgName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+// escapeSVGAttr writes an attribute value with XML escaping (including quotes)
+func escapeSVGAttr(buf *bytes.Buffer, value string) {
+	xml.EscapeText(buf, []byte(value))
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+const testSVG = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE svg>
+<!-- Created with Inkscape -->
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="100" height="100" onload="alert(1)">
+  <script>alert('x')</script>
+  <style>@import url(https://evil.example/a.css); rect { fill: url(http://evil.example/p.svg#g); }</style>
+  <rect id="r" x="10" y="10" width="80" height="80" onclick="steal()" fill="#f00"/>
+  <use xlink:href="#r"/>
+  <image href="https://tracker.example/pixel.png" width="1" height="1"/>
+  <foreignObject><div xmlns="http://www.w3.org/1999/xhtml">hi</div></foreignObject>
+  <path d="M0 0 A5 5 0 0 1 10 10"/>
+</svg>`
+
+func TestSanitizeSVGStripsActiveContent(t *testing.T) {
+	out, err := sanitizeSVG([]byte(testSVG), GenerateNonce(), true)
+	if err != nil {
+		t.Fatalf("sanitizeSVG: %v", err)
+	}
+	s := string(out)
+
+	for _, banned := range []string{"<script", "onload", "onclick", "foreignObject", "@import", "evil.example", "tracker.example", "Inkscape", "DOCTYPE"} {
+		if strings.Contains(s, banned) {
+			t.Errorf("output still contains %q:\n%s", banned, s)
+		}
+	}
+	for _, kept := range []string{`xlink:href="#r"`, "<!-- uid:", `d="M0 0 A5 5 0 0 1 10 10"`} {
+		if !strings.Contains(s, kept) {
+			t.Errorf("output is missing %q:\n%s", kept, s)
+		}
+	}
+
+	// Output must remain well-formed XML
+	d := xml.NewDecoder(bytes.NewReader(out))
+	for {
+		if _, err := d.Token(); err != nil {
+			if err != io.EOF {
+				t.Fatalf("output is not well-formed: %v", err)
+			}
+			break
+		}
+	}
+}
+
+func TestSanitizeSVGStripsAnimatedLinksAndExternalURLs(t *testing.T) {
+	tests := []struct {
+		name   string
+		svg    string
+		banned string
+	}{
+		{"set href", `<svg><a href="#x"><set attributeName="href" to="javascript:alert(1)"/><text>go</text></a></svg>`, "javascript"},
+		{"set xlink:href", `<svg><a><set attributeName="xlink:href" to="https://evil.example"/></a></svg>`, "evil.example"},
+		{"animate values", `<svg><a><animate attributeName="href" values="#a;javascript:alert(1)"/></a></svg>`, "javascript"},
+		{"animate data URL", `<svg><rect><animate attributeName="fill" values="data:text/html,x"/></rect></svg>`, "data:"},
+		{"animateTransform script", `<svg><g><animateTransform attributeName="transform" from="javascript:alert(1)"/></g></svg>`, "javascript"},
+		{"fill", `<svg><rect fill="url(https://evil.example/p.svg#g)"/></svg>`, "evil.example"},
+		{"filter", `<svg><rect filter="url(//evil.example/f.svg#f)"/></svg>`, "evil.example"},
+		{"mask", `<svg><rect mask="url('http://evil.example/m.svg#m')"/></svg>`, "evil.example"},
+		{"clip-path", `<svg><rect clip-path="url(http://evil.example/c.svg#c)"/></svg>`, "evil.example"},
+		{"marker-end", `<svg><path d="M0 0L1 1" marker-end="url(https://evil.example/k.svg#k)"/></svg>`, "evil.example"},
+	}
+	for _, tt := range tests {
+		out, err := sanitizeSVG([]byte(tt.svg), GenerateNonce(), false)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if strings.Contains(string(out), tt.banned) {
+			t.Errorf("%s: output still contains %q: %s", tt.name, tt.banned, out)
+		}
+	}
+
+	// Harmless animations and local references are kept
+	out, err := sanitizeSVG([]byte(`<svg><rect fill="url(#grad)"><animate attributeName="opacity" values="0;1"/></rect></svg>`), GenerateNonce(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kept := range []string{`url(#grad)`, `attributeName="opacity"`} {
+		if !strings.Contains(string(out), kept) {
+			t.Errorf("output is missing %q: %s", kept, out)
+		}
+	}
+}
+
+func TestSanitizeSVGUnique(t *testing.T) {
+	a, err := sanitizeSVG([]byte(testSVG), GenerateNonce(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := sanitizeSVG([]byte(testSVG), GenerateNonce(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, b) {
+		t.Error("two runs produced identical SVG output")
+	}
+}
+
+func TestSanitizeSVGWithoutMarker(t *testing.T) {
+	a, err := sanitizeSVG([]byte(testSVG), GenerateNonce(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := sanitizeSVG([]byte(testSVG), GenerateNonce(), false)
+	if bytes.Contains(a, []byte("uid:")) {
+		t.Errorf("marker embedded: %s", a)
+	}
+	if bytes.Equal(a, b) {
+		t.Error("two runs produced identical SVG output")
+	}
+}
+
+func TestSanitizeSVGRejectsNonSVG(t *testing.T) {
+	if _, err := sanitizeSVG([]byte(`<html><body/></html>`), GenerateNonce(), true); err == nil {
+		t.Error("expected error for document without <svg> root")
+	}
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// toolPaths holds the binary run for a tool; tools without an entry are looked up on PATH
+var toolPaths sync.Map
+
+// SetToolPath runs path for tool instead of the binary found on PATH ("" restores
+// the PATH lookup). Call before the converters are used
+func SetToolPath(tool, path string) {
+	if path == "" {
+		toolPaths.Delete(tool)
+		return
+	}
+	toolPaths.Store(tool, path)
+}
+
+// toolPath returns the binary to run for tool
+func toolPath(tool string) string {
+	if path, ok := toolPaths.Load(tool); ok {
+		return path.(string)
+	}
+	return tool
+}
+
+// ToolchainRequirements are what VerifyToolchain checks ffmpeg against
+type ToolchainRequirements struct {
+	MinFFmpegVersion string   // e.g. "5.1" ("" accepts any release)
+	Encoders         []string // Encoders that must be compiled in
+}
+
+// VerifyToolchain checks that ffmpeg and ffprobe run, that ffmpeg is at least
+// the minimum version and has the required encoders, and returns its version.
+// Development builds (e.g. "N-113000-g...") have no comparable version and pass
+func VerifyToolchain(ctx context.Context, req ToolchainRequirements) (string, error) {
+	output, err := exec.CommandContext(ctx, toolPath("ffmpeg"), "-version").Output()
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg (%s) does not run: %w", toolPath("ffmpeg"), err)
+	}
+	version := parseFFmpegVersion(string(output))
+
+	if _, err := exec.CommandContext(ctx, toolPath("ffprobe"), "-version").Output(); err != nil {
+		return version, fmt.Errorf("ffprobe (%s) does not run: %w", toolPath("ffprobe"), err)
+	}
+
+	if req.MinFFmpegVersion != "" {
+		if cmp, ok := compareVersions(version, req.MinFFmpegVersion); ok && cmp < 0 {
+			return version, fmt.Errorf("ffmpeg %s is older than the required %s", version, req.MinFFmpegVersion)
+		}
+	}
+
+	if len(req.Encoders) > 0 {
+		output, err := exec.CommandContext(ctx, toolPath("ffmpeg"), "-hide_banner", "-encoders").Output()
+		if err != nil {
+			return version, fmt.Errorf("failed to list ffmpeg encoders: %w", err)
+		}
+		available := parseEncoderList(string(output))
+		var missing []string
+		for _, name := range req.Encoders {
+			if !available[name] {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return version, fmt.Errorf("ffmpeg %s lacks encoders: %s", version, strings.Join(missing, ", "))
+		}
+	}
+
+	return version, nil
+}
+
+// parseFFmpegVersion extracts the version from `ffmpeg -version`, whose first
+// line looks like "ffmpeg version 6.1.1-static https://... Copyright ..."
+func parseFFmpegVersion(output string) string {
+	line, _, _ := strings.Cut(output, "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[1] != "version" {
+		return "unknown"
+	}
+	return fields[2]
+}
+
+// compareVersions compares the leading dotted numbers of two versions, with
+// an "n" prefix (git tags) and suffixes like "-static" ignored. ok is false
+// when version has no leading number
+func compareVersions(version, min string) (cmp int, ok bool) {
+	a, b := versionNumbers(version), versionNumbers(min)
+	if len(a) == 0 {
+		return 0, false
+	}
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
+// versionNumbers returns the dotted numbers at the start of version
+func versionNumbers(version string) []int {
+	version = strings.TrimPrefix(version, "n")
+	end := strings.IndexFunc(version, func(r rune) bool { return r != '.' && (r < '0' || r > '9') })
+	if end >= 0 {
+		version = version[:end]
+	}
+
+	var numbers []int
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
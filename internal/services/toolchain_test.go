@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		version, min string
+		cmp          int
+		ok           bool
+	}{
+		{"6.1.1-static", "4.4", 1, true},
+		{"n4.4", "4.4", 0, true},
+		{"4.3.2-0+deb11u1", "4.4", -1, true},
+		{"5", "5.0.1", -1, true},
+		{"N-113000-g1a2b3c", "4.4", 0, false},
+		{"unknown", "4.4", 0, false},
+	}
+	for _, tt := range tests {
+		cmp, ok := compareVersions(tt.version, tt.min)
+		if cmp != tt.cmp || ok != tt.ok {
+			t.Errorf("compareVersions(%q, %q) = %d, %v, want %d, %v", tt.version, tt.min, cmp, ok, tt.cmp, tt.ok)
+		}
+	}
+}
+
+func TestParseFFmpegVersion(t *testing.T) {
+	output := "ffmpeg version 6.1.1-static https://johnvansickle.com/ffmpeg/  Copyright (c) 2000-2023\nbuilt with gcc 8\n"
+	if got := parseFFmpegVersion(output); got != "6.1.1-static" {
+		t.Errorf("parseFFmpegVersion = %q", got)
+	}
+	if got := parseFFmpegVersion("bash: ffmpeg: not found"); got != "unknown" {
+		t.Errorf("parseFFmpegVersion(garbage) = %q", got)
+	}
+}
+
+// fakeTool writes a shell script standing in for a tool that prints output
+func fakeTool(t *testing.T, dir, name, output string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	path := filepath.Join(dir, name)
+	script := "#!/bin/sh\ncat <<'OUT'\n" + output + "\nOUT\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerifyToolchain(t *testing.T) {
+	dir := t.TempDir()
+	ffmpegOutput := "ffmpeg version 5.1.4 Copyright (c) 2000-2023\n" +
+		"Encoders:\n V..... = Video\n ------\n V....D libx264              libx264 H.264\n A....D aac                  AAC"
+	SetToolPath("ffmpeg", fakeTool(t, dir, "ffmpeg", ffmpegOutput))
+	SetToolPath("ffprobe", fakeTool(t, dir, "ffprobe", "ffprobe version 5.1.4"))
+	t.Cleanup(func() {
+		SetToolPath("ffmpeg", "")
+		SetToolPath("ffprobe", "")
+	})
+
+	ctx := context.Background()
+	version, err := VerifyToolchain(ctx, ToolchainRequirements{MinFFmpegVersion: "4.4", Encoders: []string{"libx264", "aac"}})
+	if err != nil || version != "5.1.4" {
+		t.Fatalf("VerifyToolchain = %q, %v", version, err)
+	}
+	if _, err := VerifyToolchain(ctx, ToolchainRequirements{MinFFmpegVersion: "6.0"}); err == nil || !strings.Contains(err.Error(), "older") {
+		t.Errorf("old version accepted: %v", err)
+	}
+	if _, err := VerifyToolchain(ctx, ToolchainRequirements{Encoders: []string{"libx264", "libopus"}}); err == nil || !strings.Contains(err.Error(), "libopus") {
+		t.Errorf("missing encoder accepted: %v", err)
+	}
+
+	if cmd := toolCommand(ctx, "ffprobe", "-version"); cmd.Path != filepath.Join(dir, "ffprobe") {
+		t.Errorf("toolCommand ran %s", cmd.Path)
+	}
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"math"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// toolWaitDelay bounds how long Wait blocks on the pipes of a killed tool
+const toolWaitDelay = 5 * time.Second
+
+// toolCommand is exec.CommandContext for the external tools (ffmpeg, ffprobe,
+// qpdf, ...), running the binary set by SetToolPath. The tool runs in its own
+// process group, killed as a whole when ctx ends, and ffmpeg gets a
+// -timelimit derived from the deadline of ctx
+func toolCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if name == "ffmpeg" {
+		args = withTimeLimit(ctx, args)
+	}
+	cmd := exec.CommandContext(ctx, toolPath(name), args...)
+	killProcessGroup(cmd)
+	cmd.WaitDelay = toolWaitDelay
+	return cmd
+}
+
+// withTimeLimit replaces any -timelimit in args with the budget left before
+// the deadline of ctx. ffmpeg counts CPU time across all threads, so the
+// budget is scaled by the CPU count: the limit never fires before the
+// deadline, but still ends an ffmpeg orphaned by a crashed service
+func withTimeLimit(ctx context.Context, args []string) []string {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return args
+	}
+
+	out := make([]string, 0, len(args)+2)
+	seconds := int(math.Ceil(time.Until(deadline).Seconds())) * runtime.NumCPU()
+	out = append(out, "-timelimit", strconv.Itoa(max(seconds, 1)))
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-timelimit" && i+1 < len(args) {
+			i++
+			continue
+		}
+		out = append(out, args[i])
+	}
+	return out
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package services
+
+import "os/exec"
+
+// killProcessGroup keeps the default cancellation, which kills only the tool
+// itself; process groups are not portable
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWithTimeLimit(t *testing.T) {
+	args := []string{"-hide_banner", "-i", "pipe:0", "pipe:1"}
+	if got := withTimeLimit(context.Background(), args); !slices.Equal(got, args) {
+		t.Errorf("without deadline: %v", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel()
+	want := append([]string{"-timelimit", strconv.Itoa(90 * runtime.NumCPU())}, args...)
+	got := withTimeLimit(ctx, args)
+	if !slices.Equal(got, want) {
+		t.Errorf("withTimeLimit = %v, want %v", got, want)
+	}
+
+	// Commands rebuilt from the args of an earlier one keep a single limit
+	if again := withTimeLimit(ctx, got); !slices.Equal(again, want) {
+		t.Errorf("rebuilt = %v, want %v", again, want)
+	}
+}
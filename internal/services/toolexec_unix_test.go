@@ -0,0 +1,29 @@
+//go:build unix
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestToolCommandKillsProcessGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// The backgrounded sleep inherits stdout; if only sh were killed it would
+	// keep the pipe open and Wait would block until toolWaitDelay
+	cmd := toolCommand(ctx, "sh", "-c", "sleep 30 & sleep 30")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	start := time.Now()
+	if err := cmd.Run(); err == nil {
+		t.Fatal("expected the cancelled command to fail")
+	}
+	if elapsed := time.Since(start); elapsed > toolWaitDelay/2 {
+		t.Errorf("Run returned after %v, the child outlived the request", elapsed)
+	}
+}
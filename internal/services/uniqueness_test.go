@@ -1,14 +1,15 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"crypto/md5"
-	"encoding/binary"
+	"encoding/xml"
 	"fmt"
 	"io"
-	"math"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -36,7 +37,7 @@ func TestImageUniqueness(t *testing.T) {
 		t.Skip("ffmpeg not available, skipping image uniqueness test")
 	}
 
-	ic := NewImageConverter(nil, nil)
+	ic := NewImageConverter(nil, nil, "jpeg")
 
 	// generate a small PNG via ffmpeg from raw color data
 	tmpRaw := os.TempDir() + string(os.PathSeparator) + "uniq_raw.png"
@@ -66,14 +67,14 @@ func TestImageUniqueness(t *testing.T) {
 		}
 	}
 
-	if err := ic.ConvertWithScriptTechniques(context.Background(), rawData, out1); err != nil {
+	if err := ic.ConvertWithScriptTechniques(context.Background(), rawData, out1, ProcessOptions{}); err != nil {
 		t.Fatalf("ConvertWithScriptTechniques failed 1: %v", err)
 	}
 
 	// small sleep to allow RNG differences
 	time.Sleep(10 * time.Millisecond)
 
-	if err := ic.ConvertWithScriptTechniques(context.Background(), rawData, out2); err != nil {
+	if err := ic.ConvertWithScriptTechniques(context.Background(), rawData, out2, ProcessOptions{}); err != nil {
 		t.Fatalf("ConvertWithScriptTechniques failed 2: %v", err)
 	}
 
@@ -119,43 +120,21 @@ func TestAudioUniqueness(t *testing.T) {
 	ac := NewAudioConverter(nil, nil)
 
 	// generate 0.5s sine wave 16000Hz mono 16-bit PCM in WAV
-	dur := 0.5
-	sr := 16000
-	ns := int(float64(sr) * dur)
-	buf := make([]byte, 44+ns*2)
-	// WAV header
-	copy(buf[0:], []byte("RIFF"))
-	binary.LittleEndian.PutUint32(buf[4:], uint32(36+ns*2))
-	copy(buf[8:], []byte("WAVEfmt "))
-	binary.LittleEndian.PutUint32(buf[16:], 16)
-	binary.LittleEndian.PutUint16(buf[20:], 1) // PCM
-	binary.LittleEndian.PutUint16(buf[22:], 1) // mono
-	binary.LittleEndian.PutUint32(buf[24:], uint32(sr))
-	binary.LittleEndian.PutUint32(buf[28:], uint32(sr*2))
-	binary.LittleEndian.PutUint16(buf[32:], 2)
-	binary.LittleEndian.PutUint16(buf[34:], 16)
-	copy(buf[36:], []byte("data"))
-	binary.LittleEndian.PutUint32(buf[40:], uint32(ns*2))
-
-	// samples
-	for i := 0; i < ns; i++ {
-		s := int16(30000 * math.Sin(2*math.Pi*440*float64(i)/float64(sr)))
-		binary.LittleEndian.PutUint16(buf[44+i*2:], uint16(s))
-	}
+	buf := makeSineWAV(0.5, 16000)
 
 	out1 := os.TempDir() + string(os.PathSeparator) + "uniq_audio1.opus"
 	out2 := os.TempDir() + string(os.PathSeparator) + "uniq_audio2.opus"
 	defer os.Remove(out1)
 	defer os.Remove(out2)
 
-	if err := ac.ConvertWithScriptTechniques(context.Background(), buf, out1, "wav"); err != nil {
+	if err := ac.ConvertWithScriptTechniques(context.Background(), buf, out1, "wav", ProcessOptions{}); err != nil {
 		t.Fatalf("audio convert 1 failed: %v", err)
 	}
 
 	// small jitter
 	time.Sleep(10 * time.Millisecond)
 
-	if err := ac.ConvertWithScriptTechniques(context.Background(), buf, out2, "wav"); err != nil {
+	if err := ac.ConvertWithScriptTechniques(context.Background(), buf, out2, "wav", ProcessOptions{}); err != nil {
 		t.Fatalf("audio convert 2 failed: %v", err)
 	}
 
@@ -172,3 +151,218 @@ func TestAudioUniqueness(t *testing.T) {
 		t.Fatalf("expected different MD5 for unique audio processing, got same: %s", md1)
 	}
 }
+
+// uniquenessRuns is how many times each matrix cell is converted per profile
+// and escalation level
+const uniquenessRuns = 3
+
+// uniquenessMaxLevel is the escalation ceiling of the default policy
+const uniquenessMaxLevel = 3
+
+// uniquenessCase is one row of the uniqueness matrix: a source rendered by
+// ffmpeg with args (or built in Go when args is nil), converted as format
+type uniquenessCase struct {
+	mediaType string
+	format    string
+	args      []string
+	encoders  []string // Needed to render the source and convert it
+}
+
+var (
+	uniquenessImage = []string{"-f", "lavfi", "-i", "testsrc=size=96x96", "-frames:v", "1"}
+	uniquenessAudio = []string{"-f", "lavfi", "-i", "sine=frequency=440:duration=1"}
+	uniquenessVideo = []string{
+		"-f", "lavfi", "-i", "testsrc=size=96x96:rate=10:duration=1",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=1",
+		"-c:v", "libx264", "-pix_fmt", "yuv420p", "-c:a", "aac", "-shortest",
+	}
+)
+
+var uniquenessMatrix = []uniquenessCase{
+	{"image", "png", uniquenessImage, []string{"png"}},
+	{"image", "jpg", uniquenessImage, []string{"mjpeg"}},
+	{"image", "webp", uniquenessImage, []string{"libwebp"}},
+	{"image", "tiff", uniquenessImage, []string{"tiff"}},
+	{"image", "bmp", uniquenessImage, []string{"bmp"}},
+	{"image", "svg", nil, nil},
+	{"audio", "mp3", append(uniquenessAudio, "-c:a", "libmp3lame"), []string{"libmp3lame"}},
+	{"audio", "opus", append(uniquenessAudio, "-c:a", "libopus"), []string{"libopus"}},
+	{"audio", "ogg", append(uniquenessAudio, "-c:a", "libvorbis"), []string{"libvorbis"}},
+	{"audio", "m4a", append(uniquenessAudio, "-c:a", "aac"), []string{"aac"}},
+	{"audio", "wav", uniquenessAudio, []string{"pcm_s16le"}},
+	{"audio", "aac", append(uniquenessAudio, "-c:a", "aac"), []string{"aac"}},
+	{"video", "mp4", uniquenessVideo, []string{"libx264", "aac"}},
+	{"video", "mov", uniquenessVideo, []string{"libx264", "aac"}},
+	{"video", "3gp", uniquenessVideo, []string{"libx264", "aac"}},
+	{"video", "ts", uniquenessVideo, []string{"libx264", "aac"}},
+	{"document", "pdf", nil, nil},
+}
+
+// TestUniquenessMatrix converts every media type x format x profile x
+// escalation level several times; every output must differ from all others
+// and still decode cleanly. Each anti-fingerprint level runs once per format
+// and must decode cleanly too. Matroska, WebM and AVI are left out: the script
+// pipeline only takes ISO media and MPEG-TS containers and rejects them
+func TestUniquenessMatrix(t *testing.T) {
+	if testing.Short() {
+		t.Skip("uniqueness matrix is slow")
+	}
+	for _, tool := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available, skipping uniqueness matrix", tool)
+		}
+	}
+	output, err := exec.Command("ffmpeg", "-hide_banner", "-encoders").Output()
+	if err != nil {
+		t.Fatalf("ffmpeg -encoders: %v", err)
+	}
+	available := parseEncoderList(string(output))
+
+	converters := map[string]Converter{
+		"audio":    NewAudioConverter(nil, nil),
+		"image":    NewImageConverter(nil, nil, "jpeg"),
+		"video":    NewVideoConverter(nil, nil),
+		"document": NewDocumentConverter(nil, nil, true),
+	}
+
+	for _, tc := range uniquenessMatrix {
+		t.Run(tc.mediaType+"/"+tc.format, func(t *testing.T) {
+			for _, encoder := range tc.encoders {
+				if !available[encoder] {
+					t.Skipf("ffmpeg lacks encoder %s", encoder)
+				}
+			}
+			if tc.mediaType == "document" {
+				if _, err := exec.LookPath("qpdf"); err != nil {
+					t.Skip("qpdf not available")
+				}
+			}
+
+			dir := t.TempDir()
+			input := uniquenessSource(t, tc, dir)
+			seen := make(map[string]string)
+			for _, name := range ProfileNames() {
+				base, _ := LookupProfile(name)
+				for level := 0; level <= uniquenessMaxLevel; level++ {
+					profile := Escalate(base, level)
+					for run := 0; run < uniquenessRuns; run++ {
+						cell := fmt.Sprintf("%s level %d run %d", name, level, run)
+						outputPath := filepath.Join(dir, fmt.Sprintf("out-%s-%d-%d.%s", name, level, run, tc.format))
+						result, err := converters[tc.mediaType].Process(context.Background(), input, outputPath, tc.format, ProcessOptions{Profile: profile})
+						if err != nil {
+							t.Fatalf("%s: %v", cell, err)
+						}
+						if result.OutputPath != "" {
+							outputPath = result.OutputPath
+						}
+
+						sum, err := md5File(outputPath)
+						if err != nil {
+							t.Fatalf("%s: %v", cell, err)
+						}
+						if previous, dup := seen[sum]; dup {
+							t.Errorf("%s produced the same bytes as %s", cell, previous)
+						}
+						seen[sum] = cell
+						assertPlayable(t, tc.mediaType, outputPath)
+					}
+				}
+			}
+
+			// The levels replace the script pipeline and only promise a valid
+			// output; documents have no level pipeline and SVG is refused
+			if tc.mediaType == "document" || tc.format == "svg" {
+				return
+			}
+			for _, level := range AFLevels {
+				outputPath := filepath.Join(dir, fmt.Sprintf("out-%s.%s", level, tc.format))
+				result, err := converters[tc.mediaType].Process(context.Background(), input, outputPath, tc.format, ProcessOptions{Level: level})
+				if err != nil {
+					t.Fatalf("level %s: %v", level, err)
+				}
+				if result.OutputPath != "" {
+					outputPath = result.OutputPath
+				}
+				assertPlayable(t, tc.mediaType, outputPath)
+			}
+		})
+	}
+}
+
+// uniquenessSource renders the input of tc into dir and returns its bytes
+func uniquenessSource(t *testing.T, tc uniquenessCase, dir string) []byte {
+	t.Helper()
+	if tc.args == nil {
+		if tc.format == "svg" {
+			return []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" width="96" height="96"><rect width="48" height="48" fill="red"/></svg>`)
+		}
+		return minimalPDF()
+	}
+	path := filepath.Join(dir, "source."+tc.format)
+	args := append([]string{"-hide_banner", "-loglevel", "error", "-y"}, tc.args...)
+	if output, err := exec.Command("ffmpeg", append(args, path)...).CombinedOutput(); err != nil {
+		t.Fatalf("failed to render source: %v: %s", err, output)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// assertPlayable fully decodes path (qpdf --check for documents, an XML
+// parse for SVG) and fails on any error the decoder reports
+func assertPlayable(t *testing.T, mediaType, path string) {
+	t.Helper()
+	if filepath.Ext(path) == ".svg" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = xml.Unmarshal(data, new(struct{}))
+		}
+		if err != nil {
+			t.Errorf("%s is not valid SVG: %v", filepath.Base(path), err)
+		}
+		return
+	}
+	var cmd *exec.Cmd
+	if mediaType == "document" {
+		cmd = exec.Command("qpdf", "--check", path)
+	} else {
+		cmd = exec.Command("ffmpeg", "-hide_banner", "-v", "error", "-i", path, "-f", "null", "-")
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil || (mediaType != "document" && len(bytes.TrimSpace(output)) > 0) {
+		t.Errorf("%s does not decode cleanly: %v: %s", filepath.Base(path), err, output)
+	}
+}
+
+func TestNoMarkerOutputsStayUnique(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not available, skipping no_marker test")
+	}
+
+	ac := NewAudioConverter(nil, nil)
+	input := makeSineWAV(0.5, 16000)
+	dir := t.TempDir()
+
+	var outputs [][]byte
+	for i := 0; i < 2; i++ {
+		out := filepath.Join(dir, fmt.Sprintf("out%d.mp3", i))
+		if err := ac.ConvertWithScriptTechniques(context.Background(), input, out, "wav", ProcessOptions{NoMarker: true}); err != nil {
+			t.Fatalf("audio convert %d failed: %v", i, err)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tag := range []string{"uid:", "Lavf", "Lavc"} {
+			if bytes.Contains(data, []byte(tag)) {
+				t.Errorf("output %d contains %q", i, tag)
+			}
+		}
+		outputs = append(outputs, data)
+	}
+	if bytes.Equal(outputs[0], outputs[1]) {
+		t.Error("no_marker outputs are identical")
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"fingerprint-converter/internal/pool"
@@ -18,25 +17,58 @@ import (
 
 // VideoConverter handles video conversion with anti-fingerprinting
 type VideoConverter struct {
-	workerPool *pool.WorkerPool
-	bufferPool *pool.BufferPool
-	mu         sync.RWMutex
-	stats      VideoStats
+	baseConverter
 }
 
 // VideoStats tracks conversion metrics
-type VideoStats struct {
-	TotalConversions  int64
-	FailedConversions int64
-	AvgConversionTime time.Duration
-}
+type VideoStats = ConverterStats
 
 // NewVideoConverter creates a new video converter
 func NewVideoConverter(workerPool *pool.WorkerPool, bufferPool *pool.BufferPool) *VideoConverter {
 	return &VideoConverter{
-		workerPool: workerPool,
-		bufferPool: bufferPool,
+		baseConverter: baseConverter{
+			mediaType:  "video",
+			outputExt:  ".mp4",
+			workerPool: workerPool,
+			bufferPool: bufferPool,
+		},
+	}
+}
+
+// Process implements Converter using the script techniques, or the
+// anti-fingerprint level of opts when set
+// Output is always MP4, so other containers (3GP, MPEG-TS) get an .mp4 path
+func (vc *VideoConverter) Process(ctx context.Context, inputData []byte, outputPath, inputFormat string, opts ProcessOptions) (*ProcessResult, error) {
+	result := &ProcessResult{}
+	if ext := filepath.Ext(outputPath); ext != "" && ext != vc.outputExt {
+		outputPath = strings.TrimSuffix(outputPath, ext) + vc.outputExt
+		result.OutputPath = outputPath
+	}
+
+	start := time.Now()
+	var decision *EncodingDecision
+	err := vc.verified(ctx, opts, func(opts ProcessOptions) (string, error) {
+		if opts.Level != "" {
+			return outputPath, vc.Convert(ctx, inputData, opts.Level, outputPath)
+		}
+		var err error
+		decision, err = vc.ConvertWithScriptTechniques(ctx, inputData, outputPath, opts)
+		return outputPath, err
+	})
+	if err == nil {
+		err = vc.checkDuration(ctx, inputData, outputPath, opts)
+	}
+	vc.observe(inputFormat, time.Since(start), err)
+	if err != nil {
+		return nil, err
 	}
+	result.Encoding = decision
+	return result, nil
+}
+
+// OutputFormat returns the format written for inputFormat (always MP4)
+func (vc *VideoConverter) OutputFormat(inputFormat string) string {
+	return strings.TrimPrefix(vc.outputExt, ".")
 }
 
 // Convert processes video with anti-fingerprinting
@@ -58,34 +90,51 @@ func (vc *VideoConverter) Convert(ctx context.Context, inputData []byte, level s
 	// Get randomized parameters based on level
 	params := vc.getRandomizedParams(level, originalBitrate)
 
+	// faststart needs a seekable output and MP4 sources may keep their index
+	// at the end, so neither side can be a pipe
+	input, err := newIntermediate(vc.scratchDir(ctx), "video-input-*."+detectVideoContainer(inputData), inputData)
+	if err != nil {
+		vc.recordFailure()
+		return err
+	}
+	defer input.Close()
+
 	// Build FFmpeg command with anti-fingerprinting
-	cmd := exec.CommandContext(ctx, "ffmpeg",
+	cmd := toolCommand(ctx, "ffmpeg",
 		"-hide_banner",
 		"-loglevel", "error",
-		"-i", "pipe:0", // Input from stdin
+		"-i", input.Path(),
 	)
 
 	// Video filters for anti-fingerprinting
-	videoFilters := []string{}
+	graph := NewFilterGraph()
 
 	// Add subtle noise (basic, moderate, paranoid)
 	if params.addNoise {
-		videoFilters = append(videoFilters, fmt.Sprintf("noise=alls=%d:allf=t+u", params.noiseStrength))
+		graph.Add(NewFilter("noise").Set("alls", params.noiseStrength).Set("allf", "t+u"))
 	}
 
 	// Add color adjustment (moderate, paranoid)
 	if params.colorAdjust {
-		videoFilters = append(videoFilters, fmt.Sprintf("eq=brightness=%.6f:contrast=%.6f:saturation=%.6f",
-			params.brightness, params.contrast, params.saturation))
+		graph.Add(NewFilter("eq").
+			Setf("brightness", "%.6f", params.brightness).
+			Setf("contrast", "%.6f", params.contrast).
+			Setf("saturation", "%.6f", params.saturation))
 	}
 
-	// Add timestamp in metadata (paranoid)
+	// Add an invisible mark (paranoid); drawbox needs no font, unlike drawtext
 	if params.addTimestamp {
-		videoFilters = append(videoFilters, "drawtext=text='':x=0:y=0:fontsize=1:fontcolor=black@0.01")
+		graph.Add(NewFilter("drawbox").Set("x", 0).Set("y", 0).Set("w", 1).Set("h", 1).
+			Set("color", "black@0.01").Set("t", "fill"))
 	}
 
-	if len(videoFilters) > 0 {
-		cmd.Args = append(cmd.Args, "-vf", strings.Join(videoFilters, ","))
+	if !graph.Empty() {
+		filter, err := graph.Build()
+		if err != nil {
+			vc.recordFailure()
+			return fmt.Errorf("invalid video filter: %w", err)
+		}
+		cmd.Args = append(cmd.Args, "-vf", filter)
 	}
 
 	// Video codec settings
@@ -115,32 +164,11 @@ func (vc *VideoConverter) Convert(ctx context.Context, inputData []byte, level s
 	cmd.Args = append(cmd.Args,
 		"-f", "mp4",
 		"-threads", "0",
-		"pipe:1", // Output to stdout
+		outputPath,
 	)
 
-	// Set up pipes
-	cmd.Stdin = bytes.NewReader(inputData)
-	var outputBuffer bytes.Buffer
-	var errorBuffer bytes.Buffer
-	cmd.Stdout = &outputBuffer
-	cmd.Stderr = &errorBuffer
-
-	// Execute conversion
-	if err := cmd.Run(); err != nil {
-		vc.recordFailure()
-		return fmt.Errorf("ffmpeg error: %v, stderr: %s", err, errorBuffer.String())
-	}
-
-	output := outputBuffer.Bytes()
-	if len(output) == 0 {
-		vc.recordFailure()
-		return fmt.Errorf("ffmpeg produced no output")
-	}
-
-	// Write to file
-	if err := os.WriteFile(outputPath, output, 0644); err != nil {
-		vc.recordFailure()
-		return fmt.Errorf("failed to write output file: %w", err)
+	if err := vc.runToFile(ctx, cmd, outputPath); err != nil {
+		return err
 	}
 
 	vc.recordSuccess(time.Since(start))
@@ -148,24 +176,32 @@ func (vc *VideoConverter) Convert(ctx context.Context, inputData []byte, level s
 }
 
 // ConvertWithScriptTechniques processes video using micro-variation gamma and a safe crop to guarantee binary uniqueness
-func (vc *VideoConverter) ConvertWithScriptTechniques(ctx context.Context, inputData []byte, outputPath string) error {
+// Returns the encoding decision taken for the source
+func (vc *VideoConverter) ConvertWithScriptTechniques(ctx context.Context, inputData []byte, outputPath string, opts ProcessOptions) (*EncodingDecision, error) {
 	start := time.Now()
 
 	if len(inputData) == 0 {
-		return fmt.Errorf("empty input data")
+		return nil, fmt.Errorf("empty input data")
 	}
 
-	// Validate MP4 integrity before processing
-	if err := validateMP4Integrity(inputData); err != nil {
-		return fmt.Errorf("invalid MP4 file: %w", err)
+	// Validate container integrity before processing
+	container := detectVideoContainer(inputData)
+	if container == "ts" {
+		if err := validateMPEGTS(inputData); err != nil {
+			return nil, fmt.Errorf("invalid MPEG-TS file: %w", err)
+		}
+	} else if err := validateMP4Integrity(inputData); err != nil {
+		return nil, fmt.Errorf("invalid MP4 file: %w", err)
 	}
 
-	// Save to temporary file first (workaround for pipe issues with some MP4 files)
-	tempInput := outputPath + ".input.mp4"
-	if err := os.WriteFile(tempInput, inputData, 0644); err != nil {
-		return fmt.Errorf("failed to write temp input: %w", err)
+	// Save to an unlinked temporary file first (workaround for pipe issues with
+	// some MP4 files); it cannot outlive the request even if the process crashes
+	input, err := newIntermediate(vc.scratchDir(ctx), "video-input-*."+container, inputData)
+	if err != nil {
+		return nil, err
 	}
-	defer os.Remove(tempInput)
+	defer input.Close()
+	tempInput := input.Path()
 
 	// Generate unique nonce for this processing (guarantees uniqueness)
 	nonce := GenerateNonce()
@@ -183,8 +219,8 @@ func (vc *VideoConverter) ConvertWithScriptTechniques(ctx context.Context, input
 		cropPixels = 1
 	}
 
-	cropExprW := fmt.Sprintf("if(gt(iw\\,32)\\,iw-%d\\,iw)", cropPixels*2)
-	cropExprH := fmt.Sprintf("if(gt(ih\\,32)\\,ih-%d\\,ih)", cropPixels*2)
+	cropExprW := fmt.Sprintf("if(gt(iw,32),iw-%d,iw)", cropPixels*2)
+	cropExprH := fmt.Sprintf("if(gt(ih,32),ih-%d,ih)", cropPixels*2)
 	xExpr := "(iw-ow)/2"
 	yExpr := "(ih-oh)/2"
 
@@ -201,40 +237,151 @@ func (vc *VideoConverter) ConvertWithScriptTechniques(ctx context.Context, input
 	// Position influenced by nonce for extra uniqueness
 	boxX := int(nonce.Timestamp % 2)        // 0 or 1
 	boxY := int((nonce.Timestamp / 10) % 2) // 0 or 1
-	drawBox := fmt.Sprintf("drawbox=x=%d:y=%d:w=1:h=1:color=black@0.01:t=fill", boxX, boxY)
-	vfilter := fmt.Sprintf("crop=w=%s:h=%s:x=%s:y=%s,eq=gamma=%.6f,%s", cropExprW, cropExprH, xExpr, yExpr, gamma, drawBox)
+	drawBox := NewFilter("drawbox").Set("x", boxX).Set("y", boxY).Set("w", 1).Set("h", 1).
+		Set("color", "black@0.01").Set("t", "fill")
+	eqFilter := NewFilter("eq").Setf("gamma", "%.6f", gamma)
+
+	// Per-frame dithering (profile) - gamma/brightness drift along a slow curve
+	// so per-frame hashes diverge, not just the global one
+	if opts.Profile.VideoGammaDither {
+		eqFilter = gammaDitherFilter(localRand, gamma, opts.Profile.strength())
+	}
+
+	graph := NewFilterGraph(
+		NewFilter("crop").Set("w", cropExprW).Set("h", cropExprH).Set("x", xExpr).Set("y", yExpr),
+		eqFilter,
+		drawBox,
+	)
+
+	// Optional speed change - video via setpts, audio via atempo
+	if opts.hasSpeedChange() {
+		graph.Add(opts.setptsFilter())
+	}
+
+	vfilter, err := graph.Build()
+	if err != nil {
+		vc.recordFailure()
+		return nil, fmt.Errorf("invalid video filter: %w", err)
+	}
 
 	// 3. Metadata standard field - includes nonce for guaranteed uniqueness
 	uniqueTitle := fmt.Sprintf("uid:%s", nonce.Nonce)
 
+	// 4. Adaptive CRF - avoid bloating compressed sources and keep detail on pristine ones
+	decision := chooseEncodingDecision(vc.probeSource(ctx, tempInput))
+
+	// 5. Audio passthrough - AAC 48kHz within target bitrate is copied as-is
+	audio := vc.probeAudioStream(ctx, tempInput)
+	audioArgs, copied := buildAudioArgs(audio, opts, nonce)
+	decision.AudioCopied = copied
+
 	// faststart requires seekable output, so write directly to file
-	cmd := exec.CommandContext(ctx, "ffmpeg",
+	cmd := toolCommand(ctx, "ffmpeg",
 		"-hide_banner",
 		"-loglevel", "error",
 		"-i", tempInput, // Use temp file instead of pipe for better compatibility
+	)
+
+	// 6. Audio/video offset (profile) - the same file is opened a second time with
+	// -itsoffset and its audio mapped, shifting audio within lip-sync tolerance
+	if opts.Profile.VideoAudioOffset && !audio.Absent {
+		cmd.Args = append(cmd.Args,
+			"-itsoffset", fmt.Sprintf("%.3f", audioOffsetSeconds(localRand, opts.Profile.strength())),
+			"-i", tempInput,
+			"-map", "0:v:0",
+			"-map", "1:a:0?",
+		)
+	}
+
+	cmd.Args = append(cmd.Args,
 		"-vf", vfilter,
 		"-c:v", "libx264",
-		"-crf", "20",
-		"-preset", "medium",
-		"-c:a", "aac",
-		"-b:a", "128k",
-		"-ar", "48000",
-		// Metadata in title field (more portable)
-		"-map_metadata", "-1",
-		"-metadata", "title="+uniqueTitle,
+		"-crf", strconv.Itoa(decision.CRF),
+	)
+
+	// Cap bitrate near the source so re-encoding never inflates the file much
+	if decision.MaxBitrateKbps > 0 {
+		cmd.Args = append(cmd.Args,
+			"-maxrate", fmt.Sprintf("%dk", decision.MaxBitrateKbps),
+			"-bufsize", fmt.Sprintf("%dk", decision.MaxBitrateKbps*2),
+		)
+	}
+
+	cmd.Args = append(cmd.Args, "-preset", "medium")
+
+	cmd.Args = append(cmd.Args, audioArgs...)
+
+	// Metadata in title field (more portable)
+	cmd.Args = append(cmd.Args, "-map_metadata", "-1")
+	cmd.Args = append(cmd.Args, outputMetadataArgs("title", uniqueTitle, opts)...)
+
+	if vc.randomizeEncoderTags && !opts.NoMarker {
+		identity := pickIdentity(videoIdentities, localRand)
+		if copied {
+			identity.AudioHandler = "" // Keep the nonce handler name of the copied track
+		}
+		cmd.Args = append(cmd.Args, identity.args(true, !audio.Absent)...)
+	}
+
+	created, dated := vc.creationTime(localRand)
+	if dated && !opts.NoMarker {
+		cmd.Args = append(cmd.Args, creationTimeArgs("mp4", created)...)
+	}
+
+	cmd.Args = append(cmd.Args,
 		"-movflags", "+faststart", // WhatsApp compatibility - moov atom at start
 		"-f", "mp4",
 		"-threads", "0",
 		outputPath, // Write directly to output file (faststart needs seekable output)
 	)
 
+	if err := vc.runToFile(ctx, cmd, outputPath); err != nil {
+		return nil, err
+	}
+	if dated {
+		stampFile(outputPath, created)
+	}
+
+	vc.recordSuccess(time.Since(start))
+	return decision, nil
+}
+
+// runToFile runs cmd, which writes outputPath itself, retrying once with
+// error tolerance when the failure allows it
+func (vc *VideoConverter) runToFile(ctx context.Context, cmd *exec.Cmd, outputPath string) error {
 	// Capture only stderr for error reporting
-	var errorBuffer bytes.Buffer
-	cmd.Stderr = &errorBuffer
+	errorBuffer := newCappedBuffer()
+	cmd.Stderr = errorBuffer
 
-	if err := cmd.Run(); err != nil {
+	if fault := injectedFault("ffmpeg"); fault != nil {
 		vc.recordFailure()
-		return fmt.Errorf("ffmpeg error: %v, stderr: %s", err, errorBuffer.String())
+		return fault
+	}
+
+	started := time.Now()
+	err := cmd.Run()
+	traceCommand(ctx, cmd, started, err)
+	if err != nil {
+		primaryErr := newExecError("ffmpeg", err, errorBuffer)
+		if !shouldRetryFFmpeg(ctx, primaryErr) {
+			vc.recordFailure()
+			return primaryErr
+		}
+
+		// Fallback: same pipeline, tolerating corrupt packets in the source
+		convertLog.Warnf("⚠️  ffmpeg failed (%v), retrying with fallback strategy", primaryErr)
+		os.Remove(outputPath) // Partial output would make ffmpeg refuse to overwrite
+		retry := toolCommand(ctx, "ffmpeg", withErrorTolerance(cmd.Args[1:])...)
+		retryErrors := newCappedBuffer()
+		retry.Stderr = retryErrors
+		started = time.Now()
+		err := retry.Run()
+		traceCommand(ctx, retry, started, err)
+		if err != nil {
+			vc.recordFailure()
+			return fmt.Errorf("%w (fallback also failed: %v)", primaryErr, newExecError("ffmpeg", err, retryErrors))
+		}
+		vc.recordFallback()
 	}
 
 	// Verify output file was created
@@ -242,11 +389,206 @@ func (vc *VideoConverter) ConvertWithScriptTechniques(ctx context.Context, input
 		vc.recordFailure()
 		return fmt.Errorf("output file not created: %w", err)
 	}
-
-	vc.recordSuccess(time.Since(start))
 	return nil
 }
 
+// sourceInfo holds the probed properties used for adaptive encoding
+type sourceInfo struct {
+	BitrateKbps int
+	Width       int
+	Height      int
+}
+
+// EncodingDecision records how the video encoder was configured for a source
+type EncodingDecision struct {
+	CRF               int
+	MaxBitrateKbps    int // 0 = uncapped
+	SourceBitrateKbps int
+	SourceResolution  string
+	Reason            string
+	AudioCopied       bool // Audio stream passed through without re-encoding
+}
+
+// passthroughMaxAudioKbps is the target AAC bitrate; sources at or below it are copied
+const passthroughMaxAudioKbps = 128
+
+// audioStreamInfo holds the probed properties of the first audio stream
+type audioStreamInfo struct {
+	Codec       string
+	SampleRate  int
+	BitrateKbps int
+	Absent      bool // Probe succeeded and found no audio stream
+}
+
+// probeAudioStream reads codec, sample rate and bitrate of the first audio stream
+// Returns a zero value when probing fails, so unknown files keep the audio options
+func (vc *VideoConverter) probeAudioStream(ctx context.Context, inputPath string) audioStreamInfo {
+	var info audioStreamInfo
+
+	output, err := toolCommand(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=codec_name,sample_rate,bit_rate",
+		"-of", "default=noprint_wrappers=1",
+		inputPath,
+	).Output()
+	if err != nil {
+		return info
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "codec_name":
+			info.Codec = value
+		case "sample_rate":
+			info.SampleRate, _ = strconv.Atoi(value)
+		case "bit_rate":
+			if n, err := strconv.Atoi(value); err == nil {
+				info.BitrateKbps = n / 1000
+			}
+		}
+	}
+	info.Absent = info.Codec == ""
+
+	return info
+}
+
+// buildAudioArgs returns the ffmpeg audio output options for the probed stream
+// and whether the stream is copied without re-encoding
+func buildAudioArgs(audio audioStreamInfo, opts ProcessOptions, nonce *ProcessingNonce) ([]string, bool) {
+	// Silent video: no audio options at all, so ffmpeg never tries to encode one
+	if audio.Absent {
+		return []string{"-an"}, false
+	}
+
+	if canPassthroughAudio(audio) && !opts.hasSpeedChange() {
+		// Copied stream stays bit-identical, so uniqueness comes from the container:
+		// a nonce-derived handler name on the audio track. Without markers the
+		// re-encoded video track alone makes the file unique
+		if opts.NoMarker {
+			return []string{"-c:a", "copy"}, true
+		}
+		return []string{
+			"-c:a", "copy",
+			"-metadata:s:a:0", "handler_name=SoundHandler " + nonce.Random[:8],
+		}, true
+	}
+
+	var args []string
+	if opts.hasSpeedChange() {
+		args = append(args, "-af", opts.atempoFilter().String())
+	}
+	args = append(args,
+		"-c:a", "aac",
+		"-b:a", fmt.Sprintf("%dk", passthroughMaxAudioKbps),
+		"-ar", "48000",
+	)
+	return args, false
+}
+
+// canPassthroughAudio reports whether the audio stream already matches the output target
+func canPassthroughAudio(a audioStreamInfo) bool {
+	return a.Codec == "aac" &&
+		a.SampleRate == 48000 &&
+		a.BitrateKbps > 0 && a.BitrateKbps <= passthroughMaxAudioKbps
+}
+
+// probeSource reads bitrate and resolution of the first video stream
+// Missing fields are left at zero; the caller falls back to defaults
+func (vc *VideoConverter) probeSource(ctx context.Context, inputPath string) sourceInfo {
+	var info sourceInfo
+
+	output, err := toolCommand(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height,bit_rate:format=bit_rate",
+		"-of", "default=noprint_wrappers=1",
+		inputPath,
+	).Output()
+	if err != nil {
+		return info
+	}
+
+	streamBitrate, formatBitrate := 0, 0
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			continue // "N/A" for unknown values
+		}
+		switch key {
+		case "width":
+			info.Width = n
+		case "height":
+			info.Height = n
+		case "bit_rate":
+			// Stream section comes first, format section last
+			if streamBitrate == 0 {
+				streamBitrate = n
+			} else {
+				formatBitrate = n
+			}
+		}
+	}
+
+	// Some containers (e.g. MKV/WebM) only report the overall bitrate
+	if streamBitrate > 0 {
+		info.BitrateKbps = streamBitrate / 1000
+	} else {
+		info.BitrateKbps = formatBitrate / 1000
+	}
+
+	return info
+}
+
+// chooseEncodingDecision picks CRF and a bitrate cap from the source quality
+// Quality is measured as kbps per megapixel so resolution doesn't skew the result
+func chooseEncodingDecision(src sourceInfo) *EncodingDecision {
+	decision := &EncodingDecision{
+		CRF:               20,
+		SourceBitrateKbps: src.BitrateKbps,
+		Reason:            "default",
+	}
+
+	if src.Width > 0 && src.Height > 0 {
+		decision.SourceResolution = fmt.Sprintf("%dx%d", src.Width, src.Height)
+	}
+
+	if src.BitrateKbps <= 0 || src.Width <= 0 || src.Height <= 0 {
+		decision.Reason = "source not probed, using default CRF"
+		return decision
+	}
+
+	megapixels := float64(src.Width*src.Height) / 1000000.0
+	kbpsPerMP := float64(src.BitrateKbps) / megapixels
+
+	switch {
+	case kbpsPerMP < 1000:
+		// Already heavily compressed - a low CRF would only bloat the file
+		decision.CRF = 25
+		decision.MaxBitrateKbps = src.BitrateKbps * 110 / 100
+		decision.Reason = "low quality source"
+	case kbpsPerMP > 4000:
+		// Pristine source - keep detail
+		decision.CRF = 18
+		decision.MaxBitrateKbps = src.BitrateKbps
+		decision.Reason = "high quality source"
+	default:
+		decision.CRF = 21
+		decision.MaxBitrateKbps = src.BitrateKbps * 115 / 100
+		decision.Reason = "medium quality source"
+	}
+
+	return decision
+}
+
 type videoParams struct {
 	bitrate          int
 	crf              int
@@ -316,7 +658,7 @@ func (vc *VideoConverter) getRandomizedParams(level string, originalBitrate int)
 
 // getVideoBitrate probes the video to get its bitrate
 func (vc *VideoConverter) getVideoBitrate(ctx context.Context, inputData []byte) (int, error) {
-	cmd := exec.CommandContext(ctx, "ffprobe",
+	cmd := toolCommand(ctx, "ffprobe",
 		"-v", "error",
 		"-select_streams", "v:0",
 		"-show_entries", "stream=bit_rate",
@@ -340,36 +682,47 @@ func (vc *VideoConverter) getVideoBitrate(ctx context.Context, inputData []byte)
 	return bitrate / 1000, nil
 }
 
-func (vc *VideoConverter) recordSuccess(duration time.Duration) {
-	vc.mu.Lock()
-	defer vc.mu.Unlock()
-	vc.stats.TotalConversions++
-	vc.stats.AvgConversionTime = (vc.stats.AvgConversionTime*time.Duration(vc.stats.TotalConversions-1) + duration) / time.Duration(vc.stats.TotalConversions)
+// detectVideoContainer sniffs MPEG-TS (188-byte packets) and M2TS/MTS
+// (192-byte packets with a timestamp prefix); everything else is treated as
+// ISO BMFF (MP4/MOV/3GP)
+func detectVideoContainer(data []byte) string {
+	if tsPacketSize(data) > 0 {
+		return "ts"
+	}
+	return "mp4"
 }
 
-func (vc *VideoConverter) recordFailure() {
-	vc.mu.Lock()
-	defer vc.mu.Unlock()
-	vc.stats.FailedConversions++
+// tsPacketSize returns 188 or 192 when data looks like a transport stream, else 0
+func tsPacketSize(data []byte) int {
+	for _, size := range []int{188, 192} {
+		offset := size - 188 // M2TS sync byte follows the 4-byte timestamp
+		if len(data) < offset+size*2+1 {
+			continue
+		}
+		if data[offset] == 0x47 && data[offset+size] == 0x47 && data[offset+size*2] == 0x47 {
+			return size
+		}
+	}
+	return 0
 }
 
-// GetStats returns current statistics
-func (vc *VideoConverter) GetStats() VideoStats {
-	vc.mu.RLock()
-	defer vc.mu.RUnlock()
-	return vc.stats
-}
+// validateMPEGTS checks that sync bytes repeat at the packet interval
+func validateMPEGTS(data []byte) error {
+	size := tsPacketSize(data)
+	if size == 0 {
+		return fmt.Errorf("missing sync bytes - not a transport stream")
+	}
 
-// GetOutputExtension returns the file extension for this converter
-func (vc *VideoConverter) GetOutputExtension() string {
-	return ".mp4"
-}
+	offset := size - 188
+	packets := 0
+	for i := offset; i < len(data) && packets < 64; i += size {
+		if data[i] != 0x47 {
+			return fmt.Errorf("lost sync at byte %d - file may be corrupted", i)
+		}
+		packets++
+	}
 
-// GenerateOutputPath creates a unique output path
-func (vc *VideoConverter) GenerateOutputPath(cacheDir, deviceID, urlHash string) string {
-	timestamp := time.Now().UnixNano()
-	filename := fmt.Sprintf("%s_%s_%d%s", deviceID, urlHash[:8], timestamp, vc.GetOutputExtension())
-	return filepath.Join(cacheDir, filename)
+	return nil
 }
 
 // validateMP4Integrity performs basic integrity checks on MP4 data
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestChooseEncodingDecision(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     sourceInfo
+		wantCRF int
+		wantCap bool
+	}{
+		{"unprobed", sourceInfo{}, 20, false},
+		{"compressed 720p", sourceInfo{BitrateKbps: 500, Width: 1280, Height: 720}, 25, true},
+		{"typical 1080p", sourceInfo{BitrateKbps: 4000, Width: 1920, Height: 1080}, 21, true},
+		{"pristine 720p", sourceInfo{BitrateKbps: 8000, Width: 1280, Height: 720}, 18, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := chooseEncodingDecision(tt.src)
+			if d.CRF != tt.wantCRF {
+				t.Errorf("CRF = %d, want %d (%s)", d.CRF, tt.wantCRF, d.Reason)
+			}
+			if (d.MaxBitrateKbps > 0) != tt.wantCap {
+				t.Errorf("MaxBitrateKbps = %d, want cap=%v", d.MaxBitrateKbps, tt.wantCap)
+			}
+		})
+	}
+}
+
+func TestCanPassthroughAudio(t *testing.T) {
+	tests := []struct {
+		name string
+		in   audioStreamInfo
+		want bool
+	}{
+		{"aac 48k 128k", audioStreamInfo{Codec: "aac", SampleRate: 48000, BitrateKbps: 128}, true},
+		{"aac 44.1k", audioStreamInfo{Codec: "aac", SampleRate: 44100, BitrateKbps: 128}, false},
+		{"aac over target", audioStreamInfo{Codec: "aac", SampleRate: 48000, BitrateKbps: 256}, false},
+		{"opus", audioStreamInfo{Codec: "opus", SampleRate: 48000, BitrateKbps: 64}, false},
+		{"unknown bitrate", audioStreamInfo{Codec: "aac", SampleRate: 48000}, false},
+		{"no audio", audioStreamInfo{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := canPassthroughAudio(tt.in); got != tt.want {
+			t.Errorf("%s: canPassthroughAudio = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectVideoContainer(t *testing.T) {
+	makeTS := func(packetSize, packets int) []byte {
+		data := make([]byte, packetSize*packets)
+		for i := packetSize - 188; i < len(data); i += packetSize {
+			data[i] = 0x47
+		}
+		return data
+	}
+
+	if got := detectVideoContainer(makeTS(188, 10)); got != "ts" {
+		t.Errorf("MPEG-TS: got %q, want ts", got)
+	}
+	if got := detectVideoContainer(makeTS(192, 10)); got != "ts" {
+		t.Errorf("M2TS: got %q, want ts", got)
+	}
+	if err := validateMPEGTS(makeTS(188, 10)); err != nil {
+		t.Errorf("valid MPEG-TS rejected: %v", err)
+	}
+
+	broken := makeTS(188, 10)
+	broken[188*5] = 0x00
+	if err := validateMPEGTS(broken); err == nil {
+		t.Error("expected sync loss error")
+	}
+
+	mp4 := append([]byte{0, 0, 0, 0x18, 'f', 't', 'y', 'p', '3', 'g', 'p', '4'}, make([]byte, 400)...)
+	if got := detectVideoContainer(mp4); got != "mp4" {
+		t.Errorf("3GP: got %q, want mp4", got)
+	}
+}
+
+func TestBuildAudioArgs(t *testing.T) {
+	nonce := GenerateNonce()
+
+	args, copied := buildAudioArgs(audioStreamInfo{Absent: true}, ProcessOptions{}, nonce)
+	if copied || len(args) != 1 || args[0] != "-an" {
+		t.Errorf("silent video: got %v (copied=%v), want [-an]", args, copied)
+	}
+
+	args, copied = buildAudioArgs(audioStreamInfo{Codec: "aac", SampleRate: 48000, BitrateKbps: 128}, ProcessOptions{}, nonce)
+	if !copied || !containsArg(args, "copy") {
+		t.Errorf("aac passthrough: got %v (copied=%v), want stream copy", args, copied)
+	}
+
+	args, copied = buildAudioArgs(audioStreamInfo{Codec: "mp3", SampleRate: 44100, BitrateKbps: 192}, ProcessOptions{}, nonce)
+	if copied || !containsArg(args, "aac") || containsArg(args, "-an") {
+		t.Errorf("mp3 audio: got %v (copied=%v), want aac re-encode", args, copied)
+	}
+
+	// Failed probe (zero value) must keep the audio options
+	args, _ = buildAudioArgs(audioStreamInfo{}, ProcessOptions{}, nonce)
+	if containsArg(args, "-an") {
+		t.Errorf("unprobed audio: got %v, must not drop audio", args)
+	}
+}
+
+func containsArg(args []string, want string) bool {
+	for _, a := range args {
+		if a == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConvertVideoWithAndWithoutAudio(t *testing.T) {
+	requireFFmpeg(t)
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		t.Skip("ffprobe not available, skipping")
+	}
+
+	vc := NewVideoConverter(nil, nil)
+	for _, withAudio := range []bool{false, true} {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src.mp4")
+		args := []string{"-hide_banner", "-loglevel", "error",
+			"-f", "lavfi", "-i", "testsrc=size=160x120:rate=15:duration=1"}
+		if withAudio {
+			args = append(args, "-f", "lavfi", "-i", "sine=frequency=440:duration=1", "-c:a", "aac", "-shortest")
+		}
+		args = append(args, "-c:v", "libx264", "-preset", "ultrafast", src)
+		if out, err := exec.Command("ffmpeg", args...).CombinedOutput(); err != nil {
+			t.Fatalf("generate fixture: %v: %s", err, out)
+		}
+		input, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := filepath.Join(dir, "out.mp4")
+		if _, err := vc.ConvertWithScriptTechniques(context.Background(), input, out, ProcessOptions{}); err != nil {
+			t.Fatalf("withAudio=%v: convert: %v", withAudio, err)
+		}
+		if got := vc.probeAudioStream(context.Background(), out); got.Absent == withAudio {
+			t.Errorf("withAudio=%v: output audio absent=%v", withAudio, got.Absent)
+		}
+	}
+}
+
+func TestConvertVideoLevels(t *testing.T) {
+	requireFFmpeg(t)
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		t.Skip("ffprobe not available, skipping")
+	}
+
+	// Written without faststart, so the index trails the media as in most uploads
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.mp4")
+	if out, err := exec.Command("ffmpeg", "-hide_banner", "-loglevel", "error",
+		"-f", "lavfi", "-i", "testsrc=size=160x120:rate=15:duration=1",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=1",
+		"-c:v", "libx264", "-preset", "ultrafast", "-c:a", "aac", "-shortest", src).CombinedOutput(); err != nil {
+		t.Fatalf("generate fixture: %v: %s", err, out)
+	}
+	input, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vc := NewVideoConverter(nil, nil)
+	for _, level := range AFLevels {
+		out := filepath.Join(dir, level+".mp4")
+		if _, err := vc.Process(context.Background(), input, out, "mp4", ProcessOptions{Level: level}); err != nil {
+			t.Errorf("%s: %v", level, err)
+			continue
+		}
+		if err := verifyPlayable(context.Background(), out); err != nil {
+			t.Errorf("%s: output not playable: %v", level, err)
+		}
+		if got := vc.probeAudioStream(context.Background(), out); got.Absent {
+			t.Errorf("%s: output lost its audio", level)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probedEncoders are the ffmpeg encoders the converters rely on
+var probedEncoders = []string{"libx264", "aac", "libopus", "libmp3lame", "libwebp", "mjpeg", "png"}
+
+// probedHardwareEncoders are GPU/ASIC H.264 and HEVC encoders reported to clients
+// An encoder listed by ffmpeg may still fail at runtime without the device
+var probedHardwareEncoders = []string{"h264_nvenc", "hevc_nvenc", "h264_qsv", "hevc_qsv", "h264_vaapi", "hevc_vaapi", "h264_videotoolbox", "hevc_videotoolbox"}
+
+// probedTools are the external binaries the converters shell out to
+var probedTools = []string{"ffmpeg", "ffprobe", "qpdf", "pdftoppm", "magick", "fpcalc"}
+
+// Capabilities describes the installed toolchain, detected once per process
+type Capabilities struct {
+	FFmpegVersion    string          `json:"ffmpeg_version"`
+	Encoders         map[string]bool `json:"encoders"`
+	HardwareEncoders map[string]bool `json:"hardware_encoders"`
+	Tools            map[string]bool `json:"tools"`
+}
+
+var (
+	capabilitiesOnce sync.Once
+	capabilities     Capabilities
+)
+
+// DetectCapabilities returns the cached toolchain capabilities, probing
+// ffmpeg and PATH on first use (after SetToolPath)
+func DetectCapabilities() Capabilities {
+	capabilitiesOnce.Do(func() {
+		capabilities = probeCapabilities()
+	})
+	return capabilities
+}
+
+func probeCapabilities() Capabilities {
+	caps := Capabilities{
+		FFmpegVersion:    "unknown",
+		Encoders:         make(map[string]bool, len(probedEncoders)),
+		HardwareEncoders: make(map[string]bool, len(probedHardwareEncoders)),
+		Tools:            make(map[string]bool, len(probedTools)),
+	}
+
+	for _, tool := range probedTools {
+		_, err := exec.LookPath(toolPath(tool))
+		caps.Tools[tool] = err == nil
+	}
+	for _, name := range probedEncoders {
+		caps.Encoders[name] = false
+	}
+	for _, name := range probedHardwareEncoders {
+		caps.HardwareEncoders[name] = false
+	}
+	if !caps.Tools["ffmpeg"] {
+		return caps
+	}
+
+	if output, err := exec.Command(toolPath("ffmpeg"), "-version").Output(); err == nil {
+		if line, _, _ := strings.Cut(string(output), "\n"); line != "" {
+			caps.FFmpegVersion = strings.TrimSpace(line)
+		}
+	}
+	if output, err := exec.Command(toolPath("ffmpeg"), "-hide_banner", "-encoders").Output(); err == nil {
+		available := parseEncoderList(string(output))
+		for _, name := range probedEncoders {
+			caps.Encoders[name] = available[name]
+		}
+		for _, name := range probedHardwareEncoders {
+			caps.HardwareEncoders[name] = available[name]
+		}
+	}
+
+	return caps
+}
+
+// parseEncoderList extracts encoder names from `ffmpeg -encoders` output,
+// whose entries look like " V....D libx264   H.264 / AVC ..."
+func parseEncoderList(output string) map[string]bool {
+	encoders := make(map[string]bool)
+	listing := false
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if !listing {
+			// The legend ends with a " ------" separator line
+			listing = len(fields) == 1 && strings.HasPrefix(fields[0], "---")
+			continue
+		}
+		if len(fields) >= 2 && len(fields[0]) == 6 {
+			encoders[fields[1]] = true
+		}
+	}
+	return encoders
+}
+
+// WarmUp runs each registered pipeline once on a tiny synthetic input so the
+// first real requests don't pay for cold binaries, page cache misses and
+// codec initialization. Failures are logged and never fatal
+func WarmUp(ctx context.Context, registry *Registry) {
+	start := time.Now()
+	caps := DetectCapabilities()
+	convertLog.Infof("🔧 Toolchain: %s, encoders=%v, tools=%v", caps.FFmpegVersion, caps.Encoders, caps.Tools)
+
+	dir, err := os.MkdirTemp("", "warmup-*")
+	if err != nil {
+		convertLog.Warnf("⚠️  Warm-up skipped: %v", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	for _, mediaType := range registry.MediaTypes() {
+		converter, _ := registry.Get(mediaType)
+		stepStart := time.Now()
+
+		input, format, err := synthesizeSample(ctx, mediaType, dir, caps, warmUpSpec)
+		if err == nil {
+			output := filepath.Join(dir, "out-"+mediaType+converter.GetOutputExtension())
+			_, err = converter.Process(ctx, input, output, format, ProcessOptions{})
+		}
+		if err != nil {
+			convertLog.Warnf("⚠️  Warm-up %s failed: %v", mediaType, err)
+			continue
+		}
+		convertLog.Infof("🔥 Warm-up %s: %dms", mediaType, time.Since(stepStart).Milliseconds())
+	}
+
+	convertLog.Infof("✅ Warm-up complete in %dms", time.Since(start).Milliseconds())
+}
+
+// sampleSpec sizes a synthetic input: frame size for images and video,
+// frame rate and duration for video and audio
+type sampleSpec struct {
+	Size    string
+	Rate    int
+	Seconds float64
+}
+
+// warmUpSpec keeps warm-up inputs tiny so startup stays fast
+var warmUpSpec = sampleSpec{Size: "64x64", Rate: 10, Seconds: 0.5}
+
+// synthesizeSample generates an input for mediaType as described by spec
+func synthesizeSample(ctx context.Context, mediaType, dir string, caps Capabilities, spec sampleSpec) ([]byte, string, error) {
+	if mediaType == "document" {
+		if !caps.Tools["qpdf"] {
+			return nil, "", fmt.Errorf("qpdf not installed")
+		}
+		return minimalPDF(), "pdf", nil
+	}
+	if !caps.Tools["ffmpeg"] {
+		return nil, "", fmt.Errorf("ffmpeg not installed")
+	}
+
+	var args []string
+	var format string
+	switch mediaType {
+	case "image":
+		format = "jpg"
+		args = []string{"-f", "lavfi", "-i", "testsrc=size=" + spec.Size, "-frames:v", "1"}
+	case "audio":
+		format = "wav"
+		args = []string{"-f", "lavfi", "-i", fmt.Sprintf("sine=frequency=440:duration=%g", spec.Seconds)}
+	case "video":
+		format = "mp4"
+		args = []string{
+			"-f", "lavfi", "-i", fmt.Sprintf("testsrc=size=%s:rate=%d:duration=%g", spec.Size, spec.Rate, spec.Seconds),
+			"-f", "lavfi", "-i", fmt.Sprintf("sine=frequency=440:duration=%g", spec.Seconds),
+			"-c:v", "libx264", "-pix_fmt", "yuv420p", "-c:a", "aac", "-shortest",
+		}
+	default:
+		return nil, "", fmt.Errorf("no synthetic sample for %s", mediaType)
+	}
+
+	path := filepath.Join(dir, "sample-"+mediaType+"."+format)
+	args = append([]string{"-hide_banner", "-loglevel", "error", "-y"}, args...)
+	cmd := toolCommand(ctx, "ffmpeg", append(args, path)...)
+	errorBuffer := newCappedBuffer()
+	cmd.Stderr = errorBuffer
+	if err := cmd.Run(); err != nil {
+		return nil, "", newExecError("ffmpeg", err, errorBuffer)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read synthetic sample: %w", err)
+	}
+	return data, format, nil
+}
+
+// minimalPDF builds a one-page PDF with a classic xref table and correct offsets
+func minimalPDF() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /ID [<aa><bb>] >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseEncoderList(t *testing.T) {
+	output := `Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC (codec h264)
+ V....D png                  PNG (Portable Network Graphics) image
+ A....D aac                  AAC (Advanced Audio Coding)
+`
+	got := parseEncoderList(output)
+	for _, name := range []string{"libx264", "png", "aac"} {
+		if !got[name] {
+			t.Errorf("encoder %s not detected", name)
+		}
+	}
+	if got["Video"] || got["Audio"] || len(got) != 3 {
+		t.Errorf("legend lines parsed as encoders: %v", got)
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	requireFFmpeg(t)
+
+	image := NewImageConverter(nil, nil, "jpeg")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	WarmUp(ctx, NewRegistry(image))
+	if stats := image.GetStats(); stats.TotalConversions != 1 || stats.FailedConversions != 0 {
+		t.Errorf("image warm-up stats = %+v, want one successful conversion", stats)
+	}
+}
@@ -0,0 +1,26 @@
+package services
+
+import "context"
+
+type workdirKey struct{}
+
+// WithWorkdir makes converters place the intermediates of one job in dir,
+// so the caller can remove them wholesale when the job ends
+func WithWorkdir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, workdirKey{}, dir)
+}
+
+// workdirFrom returns the directory attached by WithWorkdir, or ""
+func workdirFrom(ctx context.Context) string {
+	dir, _ := ctx.Value(workdirKey{}).(string)
+	return dir
+}
+
+// scratchDir is where a job's intermediates go: its working directory when
+// the caller set one, the converter's temp dir otherwise
+func (b *baseConverter) scratchDir(ctx context.Context) string {
+	if dir := workdirFrom(ctx); dir != "" {
+		return dir
+	}
+	return b.tempDir
+}
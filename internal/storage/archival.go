@@ -0,0 +1,42 @@
+package storage
+
+// SetArchiver makes expiry hand files marked with MarkArchive to archive
+// (e.g. an upload to long-term storage) before deleting them. Call before
+// serving requests
+func (ts *TempStorage) SetArchiver(archive func(tf *TempFile) error) {
+	ts.archiver = archive
+}
+
+// SetRemoveHook calls hook with every stored file once it is deleted, by
+// expiry or on request, e.g. to purge it from a CDN. hook must not block.
+// Call before serving requests
+func (ts *TempStorage) SetRemoveHook(hook func(tf *TempFile)) {
+	ts.onRemove = hook
+}
+
+// MarkArchive keeps a live file in long-term storage once its TTL ends
+// instead of only deleting it. It reports whether id was found
+func (ts *TempStorage) MarkArchive(id string) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	tf, exists := ts.files[id]
+	if exists {
+		tf.Archive = true
+	}
+	return exists
+}
+
+// expire archives tf when it is marked, then deletes its files. A file that
+// could not be archived is kept and reports false, so cleanup retries it
+func (ts *TempStorage) expire(tf *TempFile) bool {
+	if tf.Archive && ts.archiver != nil {
+		if err := ts.archiver(tf); err != nil {
+			storageLog.Errorf("❌ Failed to archive %s, retrying at next cleanup: %v", tf.ID, err)
+			return false
+		}
+		storageLog.Infof("🗄️  Archived expired file: id=%s", tf.ID)
+	}
+	ts.remove(tf)
+	return true
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExpiryArchivesMarkedFiles(t *testing.T) {
+	ts := NewTempStorage(t.TempDir(), time.Hour)
+	defer ts.Stop()
+
+	var mu sync.Mutex
+	fail := true
+	var archived []string
+	ts.SetArchiver(func(tf *TempFile) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			return errors.New("bucket unavailable")
+		}
+		archived = append(archived, tf.ID)
+		return nil
+	})
+
+	kept, keptPath := storeTestFile(t, ts, "kept.mp3", "audio", "", 5)
+	plain, plainPath := storeTestFile(t, ts, "plain.mp3", "audio", "", 5)
+	if !ts.MarkArchive(kept) || ts.MarkArchive("missing") {
+		t.Fatal("MarkArchive did not report which files exist")
+	}
+	ts.mu.Lock()
+	for _, tf := range ts.files {
+		tf.ExpiresAt = time.Now().Add(-time.Second)
+	}
+	ts.mu.Unlock()
+
+	// A failed upload keeps the file for the next cleanup
+	ts.cleanup()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(keptPath); err != nil {
+		t.Fatalf("file deleted although archiving failed: %v", err)
+	}
+	if _, err := os.Stat(plainPath); !os.IsNotExist(err) {
+		t.Errorf("unmarked file %s not deleted: %v", plain, err)
+	}
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	ts.cleanup()
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(archived) != 1 || archived[0] != kept {
+		t.Fatalf("archived = %v, want [%s]", archived, kept)
+	}
+	if _, err := os.Stat(keptPath); !os.IsNotExist(err) {
+		t.Errorf("archived file still in temp storage: %v", err)
+	}
+}
+
+func TestRemoveHook(t *testing.T) {
+	dir := t.TempDir()
+	ts := NewTempStorage(dir, time.Hour)
+	defer ts.Stop()
+	removed := make(chan string, 2)
+	ts.SetRemoveHook(func(tf *TempFile) { removed <- tf.ID })
+
+	store := func(name string, ttl time.Duration) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte("data"), 0644)
+		id, err := ts.StoreWithTTL(path, "", "image", "", ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	deleted := store("deleted.png", time.Hour)
+	ts.Delete(deleted)
+	if got := <-removed; got != deleted {
+		t.Errorf("hook got %s, want %s", got, deleted)
+	}
+
+	expired := store("expired.png", 10*time.Millisecond)
+	select {
+	case got := <-removed:
+		if got != expired {
+			t.Errorf("hook got %s, want %s", got, expired)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("hook not called on expiry")
+	}
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// SaveIndex writes the stored files to path so a process taking over after
+// a binary upgrade can keep serving them. Expired files are left out unless
+// they still await archival
+func (ts *TempStorage) SaveIndex(path string) error {
+	ts.mu.RLock()
+	now := time.Now()
+	files := make([]TempFile, 0, len(ts.files))
+	for _, tf := range ts.files {
+		if now.Before(tf.ExpiresAt) || tf.Archive {
+			files = append(files, *tf)
+		}
+	}
+	ts.mu.RUnlock()
+
+	data, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write storage index: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadIndex adopts the files listed at path by SaveIndex, then removes it
+// Entries that expired (after archival, when marked) or whose file is gone are dropped
+func (ts *TempStorage) LoadIndex(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer os.Remove(path)
+
+	var files []TempFile
+	if err := json.Unmarshal(data, &files); err != nil {
+		return 0, fmt.Errorf("invalid storage index: %w", err)
+	}
+
+	adopted := 0
+	for i := range files {
+		tf := &files[i]
+		remaining := time.Until(tf.ExpiresAt)
+		if remaining <= 0 && ts.expire(tf) {
+			continue
+		}
+		if _, err := os.Stat(tf.Path); err != nil {
+			continue
+		}
+
+		ts.mu.Lock()
+		_, exists := ts.files[tf.ID]
+		if !exists {
+			ts.files[tf.ID] = tf
+		}
+		ts.mu.Unlock()
+		if exists {
+			continue
+		}
+
+		if remaining > 0 {
+			go ts.scheduleDeletion(tf.ID, tf.Path, tf.OriginalPath, remaining)
+			adopted++
+		}
+	}
+	return adopted, nil
+}
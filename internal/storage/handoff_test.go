@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIndexHandoff(t *testing.T) {
+	dir := t.TempDir()
+	old := NewTempStorage(dir, time.Hour)
+	kept, _ := storeTestFile(t, old, "kept.mp3", "audio", "team-a", 5)
+	gone, gonePath := storeTestFile(t, old, "gone.mp3", "audio", "", 5)
+	os.Remove(gonePath)
+	old.Stop()
+
+	index := filepath.Join(dir, "handoff.json")
+	if err := old.SaveIndex(index); err != nil {
+		t.Fatalf("SaveIndex: %v", err)
+	}
+
+	next := NewTempStorage(dir, time.Hour)
+	defer next.Stop()
+	n, err := next.LoadIndex(index)
+	if err != nil || n != 1 {
+		t.Fatalf("LoadIndex = %d, %v, want 1 file", n, err)
+	}
+
+	tf, err := next.Get(kept)
+	if err != nil {
+		t.Fatalf("adopted file missing: %v", err)
+	}
+	if tf.Tenant != "team-a" || tf.Size != 5 {
+		t.Errorf("adopted file = %+v", tf)
+	}
+	if _, err := next.Get(gone); err == nil {
+		t.Errorf("file missing on disk was adopted")
+	}
+	if _, err := os.Stat(index); !os.IsNotExist(err) {
+		t.Errorf("index not removed after load: %v", err)
+	}
+
+	if n, err := next.LoadIndex(index); n != 0 || err != nil {
+		t.Errorf("LoadIndex without index = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// jobDirPrefix names job directories so leftovers can be recognized
+const jobDirPrefix = "job-"
+
+// JobDir is the private working directory of one request. Everything the job
+// writes lands there; finished files are moved into storage by Keep and Close
+// removes whatever is left, whether the job succeeded or failed
+type JobDir struct {
+	ts        *TempStorage
+	dir       string
+	kept      map[string]string // Job path -> storage path
+	requestID string
+}
+
+// NewJobDir creates a job directory among the files of mediaType, on the
+// same volume so kept files are renamed rather than copied
+func (ts *TempStorage) NewJobDir(mediaType string) (*JobDir, error) {
+	return ts.NewJobDirFor(mediaType, "")
+}
+
+// NewJobDirFor is NewJobDir for the request with requestID, which names the
+// directory so every intermediate a tool writes can be traced to the request.
+// requestID must be safe in a file name
+func (ts *TempStorage) NewJobDirFor(mediaType, requestID string) (*JobDir, error) {
+	pattern := jobDirPrefix + "*"
+	if requestID != "" {
+		pattern = jobDirPrefix + requestID + "-*"
+	}
+	dir, err := os.MkdirTemp(ts.Dir(mediaType), pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job directory: %w", err)
+	}
+	return &JobDir{ts: ts, dir: dir, kept: make(map[string]string), requestID: requestID}, nil
+}
+
+// RequestID returns the ID of the request the job runs for, or ""
+func (j *JobDir) RequestID() string {
+	return j.requestID
+}
+
+// Dir returns the job directory
+func (j *JobDir) Dir() string {
+	return j.dir
+}
+
+// Path returns the path of name inside the job directory
+func (j *JobDir) Path(name string) string {
+	return filepath.Join(j.dir, name)
+}
+
+// Keep moves path out of the job directory into the storage directory of
+// mediaType, keeping its extension, and returns the new path. Keeping the
+// same file again returns the same path; files outside the job are left alone
+func (j *JobDir) Keep(path, mediaType string) (string, error) {
+	if dest, ok := j.kept[path]; ok {
+		return dest, nil
+	}
+	if filepath.Dir(path) != j.dir {
+		return path, nil
+	}
+
+	dest := filepath.Join(j.ts.Dir(mediaType), generateID()[:12]+filepath.Ext(path))
+	if err := os.Rename(path, dest); err != nil {
+		// Media types can live on different volumes
+		if err := copyFile(path, dest); err != nil {
+			return "", fmt.Errorf("failed to move %s out of job directory: %w", filepath.Base(path), err)
+		}
+	}
+	j.kept[path] = dest
+	return dest, nil
+}
+
+// Close removes the job directory and everything still in it
+func (j *JobDir) Close() error {
+	return os.RemoveAll(j.dir)
+}
+
+// RemoveStaleJobDirs deletes job directories left behind by a crashed
+// process. Call at startup, before serving requests. With shared storage
+// the directories may belong to running replicas; the cleanup leader removes
+// them once they are old instead
+func (ts *TempStorage) RemoveStaleJobDirs() int {
+	if ts.shared != nil {
+		return 0
+	}
+	return ts.removeJobDirs(0)
+}
+
+// removeJobDirs deletes job directories last modified at least minAge ago
+func (ts *TempStorage) removeJobDirs(minAge time.Duration) int {
+	dirs := map[string]bool{ts.baseDir: true}
+	for _, dir := range ts.mediaDirs {
+		dirs[dir] = true
+	}
+
+	removed := 0
+	for dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || !strings.HasPrefix(entry.Name(), jobDirPrefix) {
+				continue
+			}
+			if info, err := entry.Info(); err != nil || time.Since(info.ModTime()) < minAge {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err == nil {
+				removed++
+			}
+		}
+	}
+	return removed
+}
+
+// copyFile copies src to dst, removing a partial dst on failure
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	// Converters may have dated the output, keep that across volumes
+	if info, err := in.Stat(); err == nil {
+		os.Chtimes(dst, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobDirKeepAndClose(t *testing.T) {
+	ts := NewTempStorage(t.TempDir(), time.Hour)
+	defer ts.Stop()
+
+	job, err := ts.NewJobDir("video")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := job.Path("output.mp4")
+	scratch := job.Path("pass-0.log")
+	for _, path := range []string{output, scratch} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kept, err := job.Keep(output, "video")
+	if err != nil {
+		t.Fatalf("Keep: %v", err)
+	}
+	if filepath.Dir(kept) != ts.Dir("video") || filepath.Ext(kept) != ".mp4" {
+		t.Errorf("kept path = %s", kept)
+	}
+	if again, _ := job.Keep(output, "video"); again != kept {
+		t.Errorf("second Keep = %s, want %s", again, kept)
+	}
+
+	if err := job.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(job.Dir()); !os.IsNotExist(err) {
+		t.Errorf("job directory still exists: %v", err)
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("kept file removed with the job: %v", err)
+	}
+}
+
+func TestRemoveStaleJobDirs(t *testing.T) {
+	ts := NewTempStorage(t.TempDir(), time.Hour)
+	defer ts.Stop()
+	if err := ts.SetMediaDir("video", t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mediaType := range []string{"image", "video"} {
+		job, err := ts.NewJobDir(mediaType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(job.Path("input.original"), []byte("data"), 0644)
+	}
+	stored := filepath.Join(ts.Dir("image"), "abc.jpg")
+	os.WriteFile(stored, []byte("data"), 0644)
+
+	if n := ts.RemoveStaleJobDirs(); n != 2 {
+		t.Errorf("removed %d job directories, want 2", n)
+	}
+	if _, err := os.Stat(stored); err != nil {
+		t.Errorf("stored file removed: %v", err)
+	}
+}
+
+func TestJobDirNamedAfterRequest(t *testing.T) {
+	ts := NewTempStorage(t.TempDir(), time.Hour)
+	defer ts.Stop()
+
+	job, err := ts.NewJobDirFor("video", "order-42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer job.Close()
+	if name := filepath.Base(job.Dir()); !strings.HasPrefix(name, jobDirPrefix+"order-42-") {
+		t.Errorf("job directory = %s, want it named after the request", name)
+	}
+	if job.RequestID() != "order-42" {
+		t.Errorf("RequestID = %q", job.RequestID())
+	}
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Leader is a lease on a lock file in shared storage, held by at most one
+// replica. The holder renews it before it expires; another replica takes it
+// over only once it has been expired for half the TTL, which tolerates that
+// much clock skew between replicas. Works on any filesystem with atomic
+// rename and hard links (local disks, NFSv3+)
+type Leader struct {
+	path   string
+	holder string
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// leaderLease is the content of the lock file
+type leaderLease struct {
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewLeader creates a lease on the lock file at path for holder, a name
+// unique among the replicas
+func NewLeader(path, holder string, ttl time.Duration) *Leader {
+	if ttl <= 0 {
+		ttl = 3 * time.Minute
+	}
+	return &Leader{path: path, holder: holder, ttl: ttl, now: time.Now}
+}
+
+// Acquire takes or renews the lease and reports whether holder leads
+func (l *Leader) Acquire() (bool, error) {
+	current, err := l.read()
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return l.create()
+	case err != nil:
+		return false, err
+	case current.Holder == l.holder && l.now().Before(current.ExpiresAt):
+		return true, l.write(l.path)
+	case l.now().Before(current.ExpiresAt.Add(l.ttl / 2)):
+		return false, nil
+	}
+
+	// Expired: move the lock aside and check it is still the lease we saw,
+	// since another replica may have taken over in between
+	stale := l.path + ".stale-" + l.holder
+	if err := os.Rename(l.path, stale); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	moved, err := readLease(stale)
+	if err == nil && moved != current {
+		// A fresh lease; put it back unless yet another one appeared
+		os.Link(stale, l.path)
+		os.Remove(stale)
+		return false, nil
+	}
+	os.Remove(stale)
+	return l.create()
+}
+
+// Release gives the lease up so another replica can lead without waiting
+func (l *Leader) Release() {
+	if current, err := l.read(); err == nil && current.Holder == l.holder {
+		os.Remove(l.path)
+	}
+}
+
+// create takes the lock file if nobody holds it; the hard link fails when
+// another replica created it first
+func (l *Leader) create() (bool, error) {
+	tmp := l.path + ".new-" + l.holder
+	if err := l.write(tmp); err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp)
+
+	if err := os.Link(tmp, l.path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to take cleanup lease: %w", err)
+	}
+	return true, nil
+}
+
+// write stores a lease for holder expiring one TTL from now at path
+func (l *Leader) write(path string) error {
+	data, err := json.Marshal(leaderLease{Holder: l.holder, ExpiresAt: l.now().Add(l.ttl).UTC()})
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (l *Leader) read() (leaderLease, error) {
+	return readLease(l.path)
+}
+
+// readLease reads a lock file; an unreadable one counts as long expired
+func readLease(path string) (leaderLease, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return leaderLease{}, err
+	}
+	var lease leaderLease
+	if json.Unmarshal(data, &lease) != nil {
+		return leaderLease{}, nil
+	}
+	return lease, nil
+}
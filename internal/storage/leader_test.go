@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLeaderExclusion(t *testing.T) {
+	lock := filepath.Join(t.TempDir(), "cleanup.lock")
+	now := time.Now()
+	clock := func() time.Time { return now }
+	a := NewLeader(lock, "a", time.Minute)
+	b := NewLeader(lock, "b", time.Minute)
+	a.now, b.now = clock, clock
+
+	if ok, err := a.Acquire(); !ok || err != nil {
+		t.Fatalf("a.Acquire = %v, %v", ok, err)
+	}
+	if ok, err := b.Acquire(); ok || err != nil {
+		t.Fatalf("b.Acquire while a leads = %v, %v", ok, err)
+	}
+
+	// a renews; b waits out the expiry plus the skew grace
+	now = now.Add(50 * time.Second)
+	if ok, _ := a.Acquire(); !ok {
+		t.Fatal("a lost its own lease")
+	}
+	now = now.Add(70 * time.Second)
+	if ok, _ := b.Acquire(); ok {
+		t.Fatal("b took over within the grace period")
+	}
+	now = now.Add(time.Minute)
+	if ok, err := b.Acquire(); !ok || err != nil {
+		t.Fatalf("b.Acquire after expiry = %v, %v", ok, err)
+	}
+	if ok, _ := a.Acquire(); ok {
+		t.Fatal("a leads after b took over")
+	}
+
+	b.Release()
+	if ok, _ := a.Acquire(); !ok {
+		t.Fatal("a could not lead after b released")
+	}
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// replicasDir holds each replica's index and the cleanup lease
+	replicasDir = ".replicas"
+	// deadReplicaAge is how long an index goes unpublished before the
+	// replica is considered gone and its index removed
+	deadReplicaAge = 10 * time.Minute
+)
+
+// sharedCleanup coordinates expiry across replicas sharing the storage
+// directories. Every replica publishes its index; only the lease holder
+// deletes expired files, so no two replicas delete the same file
+type sharedCleanup struct {
+	dir       string // baseDir/.replicas
+	replica   string
+	leader    *Leader
+	jobDirAge time.Duration // Job directories older than this are abandoned
+	leading   bool
+
+	publishMu sync.Mutex // Serializes writes of this replica's index
+	indexMu   sync.Mutex
+	indexes   map[string]replicaIndex // Other replicas' indexes by path
+}
+
+// replicaIndex is a parsed index of another replica, reread when its file changes
+type replicaIndex struct {
+	modTime time.Time
+	size    int64
+	files   map[string]TempFile
+}
+
+// SetShared switches cleanup to shared mode for replicas whose temp
+// directories live on the same volume (NFS, a mounted bucket). replica must
+// be unique among the running processes; leaseTTL must outlast the one
+// minute cleanup interval. Call before serving requests
+func (ts *TempStorage) SetShared(replica string, leaseTTL, jobDirAge time.Duration) error {
+	dir := filepath.Join(ts.baseDir, replicasDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create replica directory: %w", err)
+	}
+	if jobDirAge <= 0 {
+		jobDirAge = 6 * time.Hour
+	}
+	ts.shared = &sharedCleanup{
+		dir:       dir,
+		replica:   replica,
+		leader:    NewLeader(filepath.Join(dir, "cleanup.lock"), replica, leaseTTL),
+		jobDirAge: jobDirAge,
+		indexes:   make(map[string]replicaIndex),
+	}
+	storageLog.Infof("🤝 Shared temp storage: replica=%s", replica)
+	return nil
+}
+
+// cleanupShared publishes this replica's index, drops entries the leader
+// has deleted and, while holding the lease, deletes every replica's
+// expired files
+func (ts *TempStorage) cleanupShared() {
+	s := ts.shared
+	now := time.Now()
+
+	// Expired entries stay listed until their files are gone, so a leader
+	// change cannot leave them behind
+	ts.mu.Lock()
+	for id, tf := range ts.files {
+		if now.After(tf.ExpiresAt) && !exists(tf.Path) {
+			delete(ts.files, id)
+		}
+	}
+	ts.mu.Unlock()
+	ts.publishShared()
+
+	leading, err := s.leader.Acquire()
+	if err != nil {
+		storageLog.Warnf("⚠️  Cleanup lease failed: %v", err)
+	}
+	if leading != s.leading {
+		s.leading = leading
+		if leading {
+			storageLog.Infof("👑 Leading shared storage cleanup: replica=%s", s.replica)
+		} else {
+			storageLog.Infof("🤝 Cleanup lease held by another replica")
+		}
+	}
+	if leading {
+		ts.sweepShared(now)
+	}
+}
+
+// publishShared writes this replica's index, through which other replicas
+// serve and expire its files
+func (ts *TempStorage) publishShared() {
+	s := ts.shared
+	s.publishMu.Lock()
+	defer s.publishMu.Unlock()
+
+	ts.mu.RLock()
+	files := make([]TempFile, 0, len(ts.files))
+	for _, tf := range ts.files {
+		files = append(files, *tf)
+	}
+	ts.mu.RUnlock()
+
+	if err := writeIndex(filepath.Join(s.dir, s.replica+".json"), files); err != nil {
+		storageLog.Warnf("⚠️  Failed to publish storage index: %v", err)
+	}
+}
+
+// lookup finds a file stored by another replica in the indexes they publish
+func (s *sharedCleanup) lookup(id string) (TempFile, bool) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return TempFile{}, false
+	}
+
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	seen := make(map[string]bool, len(entries))
+	var found TempFile
+	ok := false
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") || entry.Name() == s.replica+".json" {
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+		seen[path] = true
+		if tf, listed := s.readIndex(path, entry)[id]; listed && !ok {
+			found, ok = tf, true
+		}
+	}
+	// Forget the indexes of departed replicas
+	for path := range s.indexes {
+		if !seen[path] {
+			delete(s.indexes, path)
+		}
+	}
+	return found, ok
+}
+
+// readIndex returns the files in the index at path, parsing it again only
+// when it changed; callers hold s.indexMu
+func (s *sharedCleanup) readIndex(path string, entry os.DirEntry) map[string]TempFile {
+	info, err := entry.Info()
+	if err != nil {
+		return nil
+	}
+	if cached, ok := s.indexes[path]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.files
+	}
+
+	var files []TempFile
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &files) != nil {
+		return nil
+	}
+	index := make(map[string]TempFile, len(files))
+	for _, tf := range files {
+		index[tf.ID] = tf
+	}
+	s.indexes[path] = replicaIndex{modTime: info.ModTime(), size: info.Size(), files: index}
+	return index
+}
+
+// sweepShared deletes the expired files of every replica, the indexes of
+// replicas that are gone and abandoned job directories
+func (ts *TempStorage) sweepShared(now time.Time) {
+	s := ts.shared
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		storageLog.Warnf("⚠️  Failed to read replica indexes: %v", err)
+		return
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+		var files []TempFile
+		data, err := os.ReadFile(path)
+		if err != nil || json.Unmarshal(data, &files) != nil {
+			continue
+		}
+
+		live := 0
+		for i := range files {
+			if now.After(files[i].ExpiresAt) {
+				if exists(files[i].Path) && ts.expire(&files[i]) {
+					removed++
+				}
+			} else {
+				live++
+			}
+		}
+		if info, err := entry.Info(); err == nil && live == 0 && now.Sub(info.ModTime()) > deadReplicaAge {
+			os.Remove(path)
+			storageLog.Infof("🧹 Removed index of departed replica %s", strings.TrimSuffix(entry.Name(), ".json"))
+		}
+	}
+	if removed > 0 {
+		storageLog.Infof("🧹 Cleanup: removed %d expired files across replicas", removed)
+	}
+	if n := ts.removeJobDirs(s.jobDirAge); n > 0 {
+		storageLog.Infof("🧹 Removed %d abandoned job directories", n)
+	}
+}
+
+// writeIndex stores files at path through a temporary file
+func writeIndex(path string, files []TempFile) error {
+	data, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return !os.IsNotExist(err)
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSharedCleanupRunsOnLeader(t *testing.T) {
+	dir := t.TempDir()
+	leader := NewTempStorage(dir, time.Hour)
+	defer leader.Stop()
+	replica := NewTempStorage(dir, time.Hour)
+	defer replica.Stop()
+	for name, ts := range map[string]*TempStorage{"leader": leader, "replica": replica} {
+		if err := ts.SetShared(name, time.Minute, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	leader.cleanup()
+
+	path := filepath.Join(dir, "expiring.mp3")
+	os.WriteFile(path, []byte("data"), 0644)
+	id, err := replica.StoreWithTTL(path, "", "audio", "", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// The replica only publishes its index
+	replica.cleanup()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("replica deleted its file without the lease: %v", err)
+	}
+
+	leader.cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("leader left the expired file: %v", err)
+	}
+	replica.cleanup()
+	replica.mu.RLock()
+	_, listed := replica.files[id]
+	replica.mu.RUnlock()
+	if listed {
+		t.Error("replica kept the entry the leader deleted")
+	}
+}
+
+func TestSharedJobDirsByAge(t *testing.T) {
+	dir := t.TempDir()
+	ts := NewTempStorage(dir, time.Hour)
+	defer ts.Stop()
+	if err := ts.SetShared("r1", time.Minute, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	running, _ := ts.NewJobDir("video")
+	abandoned, _ := ts.NewJobDir("video")
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(abandoned.Dir(), old, old)
+
+	if n := ts.RemoveStaleJobDirs(); n != 0 {
+		t.Fatalf("RemoveStaleJobDirs removed %d directories of other replicas", n)
+	}
+	ts.cleanup()
+	if _, err := os.Stat(abandoned.Dir()); !os.IsNotExist(err) {
+		t.Errorf("abandoned job directory kept: %v", err)
+	}
+	if _, err := os.Stat(running.Dir()); err != nil {
+		t.Errorf("running job directory removed: %v", err)
+	}
+}
+
+func TestSharedGetFindsOtherReplicasFiles(t *testing.T) {
+	dir := t.TempDir()
+	producer := NewTempStorage(dir, time.Hour)
+	defer producer.Stop()
+	server := NewTempStorage(dir, time.Hour)
+	defer server.Stop()
+	for name, ts := range map[string]*TempStorage{"producer": producer, "server": server} {
+		if err := ts.SetShared(name, time.Minute, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(dir, "output.png")
+	os.WriteFile(path, []byte("data"), 0644)
+	id, err := producer.Store(path, "", "image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tf, err := server.Get(id)
+	if err != nil {
+		t.Fatalf("file stored by another replica not found: %v", err)
+	}
+	if tf.Path != path || tf.Size != 4 {
+		t.Errorf("Get = %+v", tf)
+	}
+
+	// Later stores are picked up without waiting for the cleanup interval
+	second := filepath.Join(dir, "second.png")
+	os.WriteFile(second, []byte("more"), 0644)
+	id2, _ := producer.Store(second, "", "image")
+	if _, err := server.Get(id2); err != nil {
+		t.Errorf("second file not found: %v", err)
+	}
+	if _, err := server.Get("unknown"); err == nil {
+		t.Error("unknown ID found")
+	}
+}
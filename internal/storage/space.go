@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errFreeSpaceUnsupported is returned where free space cannot be queried
+var errFreeSpaceUnsupported = errors.New("free space query not supported on this platform")
+
+// InsufficientSpaceError reports a job that would not fit on its volume
+type InsufficientSpaceError struct {
+	Dir  string
+	Need int64 // Estimated bytes the job writes plus the reserve
+	Free int64
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("insufficient storage: need ~%dMB free, %dMB available", e.Need>>20, e.Free>>20)
+}
+
+// SpaceGuard rejects jobs whose estimated disk usage (input size x factor)
+// would not leave reserve bytes free on the temp volume, so they fail up
+// front instead of mid-encode
+type SpaceGuard struct {
+	factor    float64
+	reserve   int64
+	freeSpace func(dir string) (int64, error)
+}
+
+// NewSpaceGuard creates a guard; factor covers the stored original,
+// intermediates and output per input byte
+func NewSpaceGuard(factor float64, reserve int64) *SpaceGuard {
+	return &SpaceGuard{factor: factor, reserve: reserve, freeSpace: FreeSpace}
+}
+
+// Check returns an *InsufficientSpaceError when a job with inputSize bytes
+// does not fit in dir. Volumes whose free space cannot be read are allowed
+func (g *SpaceGuard) Check(dir string, inputSize int64) error {
+	free, err := g.freeSpace(dir)
+	if err != nil {
+		return nil
+	}
+
+	need := int64(float64(inputSize)*g.factor) + g.reserve
+	if free < need {
+		return &InsufficientSpaceError{Dir: dir, Need: need, Free: free}
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package storage
+
+// FreeSpace is not implemented on this platform; the space guard allows all jobs
+func FreeSpace(dir string) (int64, error) {
+	return 0, errFreeSpaceUnsupported
+}
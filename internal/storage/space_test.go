@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSpaceGuard(t *testing.T) {
+	g := NewSpaceGuard(3, 100)
+	g.freeSpace = func(string) (int64, error) { return 1000, nil }
+
+	if err := g.Check("/tmp", 300); err != nil {
+		t.Errorf("300 bytes x3 + 100 reserve should fit in 1000: %v", err)
+	}
+
+	err := g.Check("/tmp", 400)
+	var spaceErr *InsufficientSpaceError
+	if !errors.As(err, &spaceErr) || spaceErr.Need != 1300 || spaceErr.Free != 1000 {
+		t.Errorf("Check(400) = %v, want need 1300 free 1000", err)
+	}
+
+	g.freeSpace = func(string) (int64, error) { return 0, errFreeSpaceUnsupported }
+	if err := g.Check("/tmp", 1<<40); err != nil {
+		t.Errorf("unknown free space should allow the job: %v", err)
+	}
+}
+
+func TestFreeSpace(t *testing.T) {
+	free, err := FreeSpace(t.TempDir())
+	if errors.Is(err, errFreeSpaceUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil || free <= 0 {
+		t.Errorf("FreeSpace = %d, %v", free, err)
+	}
+}
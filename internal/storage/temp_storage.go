@@ -4,33 +4,47 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	"fingerprint-converter/internal/logx"
+	"fingerprint-converter/internal/redact"
 )
 
+// storageLog carries stored, deleted and expired file events
+var storageLog = logx.For(logx.Storage)
+
 // TempFile represents a temporary file with expiration
 type TempFile struct {
 	ID          string
 	Path        string
 	OriginalPath string // Path to original downloaded file
 	MediaType   string
+	Tenant      string // Owning tenant name ("" without an API key)
 	CreatedAt   time.Time
 	ExpiresAt   time.Time
 	Size        int64
+	Archive     bool // Moved to long-term storage at expiry (see SetArchiver)
+	RequestID   string // ID of the request that produced the file
 }
 
 // TempStorage manages temporary files with automatic expiration
 type TempStorage struct {
 	baseDir    string
+	mediaDirs  map[string]string // Per-media-type directories overriding baseDir
 	files      map[string]*TempFile
 	mu         sync.RWMutex
 	ttl        time.Duration // 10 minutes
 	cleanupTicker *time.Ticker
 	stopCleanup chan struct{}
+
+	shared   *sharedCleanup           // Replicas share the directories (nil = this process owns them)
+	archiver func(tf *TempFile) error // Receives expiring files marked for archival (nil = none)
+	onRemove func(tf *TempFile)       // Told about every file deleted from disk (nil = none)
 }
 
 // NewTempStorage creates a new temporary storage manager
@@ -41,11 +55,12 @@ func NewTempStorage(baseDir string, ttl time.Duration) *TempStorage {
 
 	// Create base directory
 	if err := os.MkdirAll(baseDir, 0755); err != nil {
-		log.Printf("Warning: Failed to create temp storage directory %s: %v", baseDir, err)
+		storageLog.Warnf("Warning: Failed to create temp storage directory %s: %v", baseDir, err)
 	}
 
 	ts := &TempStorage{
 		baseDir:    baseDir,
+		mediaDirs:  make(map[string]string),
 		files:      make(map[string]*TempFile),
 		ttl:        ttl,
 		stopCleanup: make(chan struct{}),
@@ -55,20 +70,47 @@ func NewTempStorage(baseDir string, ttl time.Duration) *TempStorage {
 	ts.cleanupTicker = time.NewTicker(1 * time.Minute)
 	go ts.cleanupLoop()
 
-	log.Printf("✅ Temp storage initialized: TTL=%v, Dir=%s", ttl, baseDir)
+	storageLog.Infof("✅ Temp storage initialized: TTL=%v, Dir=%s", ttl, baseDir)
 
 	return ts
 }
 
 // Store stores a file and returns a unique ID for access
 func (ts *TempStorage) Store(filePath, originalPath, mediaType string) (string, error) {
+	return ts.StoreWithTTL(filePath, originalPath, mediaType, "", ts.ttl)
+}
+
+// StoreWithTTL stores a file owned by tenant that expires after ttl instead of the default
+func (ts *TempStorage) StoreWithTTL(filePath, originalPath, mediaType, tenant string, ttl time.Duration) (string, error) {
 	// Generate unique ID
 	id := generateID()
+	if err := ts.StoreAs(id, filePath, originalPath, mediaType, tenant, ttl); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// SetRequestID records the ID of the request that produced the file with id
+func (ts *TempStorage) SetRequestID(id, requestID string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if tf, exists := ts.files[id]; exists {
+		tf.RequestID = requestID
+	}
+}
+
+// StoreAs stores a file under an ID assigned elsewhere, e.g. by the worker
+// node that produced it. It fails when the ID is already taken
+func (ts *TempStorage) StoreAs(id, filePath, originalPath, mediaType, tenant string, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = ts.ttl
+	}
 
 	// Get file info
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to stat file: %w", err)
+		return fmt.Errorf("failed to stat file: %w", err)
 	}
 
 	now := time.Now()
@@ -77,21 +119,30 @@ func (ts *TempStorage) Store(filePath, originalPath, mediaType string) (string,
 		Path:         filePath,
 		OriginalPath: originalPath,
 		MediaType:    mediaType,
+		Tenant:       tenant,
 		CreatedAt:    now,
-		ExpiresAt:    now.Add(ts.ttl),
+		ExpiresAt:    now.Add(ttl),
 		Size:         fileInfo.Size(),
 	}
 
 	ts.mu.Lock()
+	if _, exists := ts.files[id]; exists {
+		ts.mu.Unlock()
+		return fmt.Errorf("file already stored: %s", id)
+	}
 	ts.files[id] = tf
 	ts.mu.Unlock()
+	if ts.shared != nil {
+		// Other replicas serve the file as soon as it is stored
+		ts.publishShared()
+	}
 
 	// Schedule deletion
-	go ts.scheduleDeletion(id, filePath, originalPath, ts.ttl)
+	go ts.scheduleDeletion(id, filePath, originalPath, ttl)
 
-	log.Printf("📦 Stored temp file: id=%s, type=%s, expires=%v", id, mediaType, tf.ExpiresAt.Format("15:04:05"))
+	storageLog.Infof("📦 Stored temp file: id=%s, type=%s, expires=%v", id, mediaType, tf.ExpiresAt.Format("15:04:05"))
 
-	return id, nil
+	return nil
 }
 
 // Get retrieves a temporary file by ID
@@ -99,8 +150,14 @@ func (ts *TempStorage) Get(id string) (*TempFile, error) {
 	ts.mu.RLock()
 	defer ts.mu.RUnlock()
 
-	tf, exists := ts.files[id]
-	if !exists {
+	tf, listed := ts.files[id]
+	if !listed && ts.shared != nil {
+		// Stored by another replica on the shared volume
+		if found, ok := ts.shared.lookup(id); ok {
+			tf, listed = &found, true
+		}
+	}
+	if !listed {
 		return nil, fmt.Errorf("file not found: %s", id)
 	}
 
@@ -115,29 +172,30 @@ func (ts *TempStorage) Get(id string) (*TempFile, error) {
 // scheduleDeletion deletes files after TTL
 func (ts *TempStorage) scheduleDeletion(id, filePath, originalPath string, ttl time.Duration) {
 	time.Sleep(ttl)
+	if ts.shared != nil {
+		// The cleanup leader deletes it
+		return
+	}
+
+	ts.mu.RLock()
+	tf, exists := ts.files[id]
+	ts.mu.RUnlock()
+	if exists && !ts.expire(tf) {
+		// Left listed for cleanup to retry
+		return
+	}
 
 	// Remove from map
 	ts.mu.Lock()
 	delete(ts.files, id)
 	ts.mu.Unlock()
 
-	// Delete processed file
-	if err := os.Remove(filePath); err != nil {
-		if !os.IsNotExist(err) {
-			log.Printf("⚠️  Failed to delete processed file %s: %v", filePath, err)
-		}
-	}
-
-	// Delete original file if different
-	if originalPath != "" && originalPath != filePath {
-		if err := os.Remove(originalPath); err != nil {
-			if !os.IsNotExist(err) {
-				log.Printf("⚠️  Failed to delete original file %s: %v", originalPath, err)
-			}
-		}
+	if !exists {
+		// Unlisted already; make sure nothing is left on disk
+		removeFiles(&TempFile{Path: filePath, OriginalPath: originalPath})
 	}
 
-	log.Printf("🗑️  Deleted expired files: id=%s", id)
+	storageLog.Infof("🗑️  Deleted expired files: id=%s", id)
 }
 
 // cleanupLoop runs periodic cleanup
@@ -148,6 +206,9 @@ func (ts *TempStorage) cleanupLoop() {
 			ts.cleanup()
 		case <-ts.stopCleanup:
 			ts.cleanupTicker.Stop()
+			if ts.shared != nil {
+				ts.shared.leader.Release()
+			}
 			return
 		}
 	}
@@ -155,6 +216,11 @@ func (ts *TempStorage) cleanupLoop() {
 
 // cleanup removes expired entries and files
 func (ts *TempStorage) cleanup() {
+	if ts.shared != nil {
+		ts.cleanupShared()
+		return
+	}
+
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
@@ -172,26 +238,120 @@ func (ts *TempStorage) cleanup() {
 	if len(expiredFiles) > 0 {
 		go func() {
 			for _, tf := range expiredFiles {
-				// Delete processed file
-				if err := os.Remove(tf.Path); err != nil && !os.IsNotExist(err) {
-					log.Printf("⚠️  Cleanup failed to delete %s: %v", tf.Path, err)
-				}
-				// Delete original file if different
-				if tf.OriginalPath != "" && tf.OriginalPath != tf.Path {
-					if err := os.Remove(tf.OriginalPath); err != nil && !os.IsNotExist(err) {
-						log.Printf("⚠️  Cleanup failed to delete %s: %v", tf.OriginalPath, err)
+				if !ts.expire(tf) {
+					ts.mu.Lock()
+					if _, exists := ts.files[tf.ID]; !exists {
+						ts.files[tf.ID] = tf
 					}
+					ts.mu.Unlock()
 				}
 			}
-			log.Printf("🧹 Cleanup: removed %d expired files", len(expiredFiles))
+			storageLog.Infof("🧹 Cleanup: removed %d expired files", len(expiredFiles))
 		}()
 	}
 }
 
+// Filter selects stored files; zero fields match everything
+type Filter struct {
+	Tenant    string
+	MediaType string
+	OlderThan time.Duration // Minimum age
+}
+
+// matches reports whether tf passes the filter at now
+func (f Filter) matches(tf *TempFile, now time.Time) bool {
+	if f.Tenant != "" && tf.Tenant != f.Tenant {
+		return false
+	}
+	if f.MediaType != "" && tf.MediaType != f.MediaType {
+		return false
+	}
+	return now.Sub(tf.CreatedAt) >= f.OlderThan
+}
+
+// List returns copies of the live files matching f, newest first
+func (ts *TempStorage) List(f Filter) []TempFile {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	now := time.Now()
+	files := []TempFile{}
+	for _, tf := range ts.files {
+		if now.Before(tf.ExpiresAt) && f.matches(tf, now) {
+			files = append(files, *tf)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].CreatedAt.After(files[j].CreatedAt) })
+	return files
+}
+
+// Purge deletes every file matching f before its TTL and returns how many
+// files and bytes were removed. With dryRun nothing is deleted
+func (ts *TempStorage) Purge(f Filter, dryRun bool) (int, int64) {
+	ts.mu.Lock()
+	now := time.Now()
+	purged := []*TempFile{}
+	var freed int64
+	for id, tf := range ts.files {
+		if !f.matches(tf, now) {
+			continue
+		}
+		purged = append(purged, tf)
+		freed += tf.Size
+		if !dryRun {
+			delete(ts.files, id)
+		}
+	}
+	ts.mu.Unlock()
+
+	if !dryRun {
+		for _, tf := range purged {
+			ts.remove(tf)
+		}
+		storageLog.Infof("🧹 Purged %d files (%d bytes): tenant=%q, type=%q, older_than=%v", len(purged), freed, f.Tenant, f.MediaType, f.OlderThan)
+	}
+	return len(purged), freed
+}
+
+// Delete removes one file before its TTL; it is unavailable immediately
+func (ts *TempStorage) Delete(id string) bool {
+	ts.mu.Lock()
+	tf, exists := ts.files[id]
+	delete(ts.files, id)
+	ts.mu.Unlock()
+
+	if !exists {
+		return false
+	}
+	ts.remove(tf)
+	storageLog.Infof("🗑️  Deleted file on request: id=%s", id)
+	return true
+}
+
+// remove deletes a stored file and its original, then tells the remove hook
+func (ts *TempStorage) remove(tf *TempFile) {
+	removeFiles(tf)
+	if ts.onRemove != nil {
+		ts.onRemove(tf)
+	}
+}
+
+// removeFiles deletes a stored file and its original from disk
+func removeFiles(tf *TempFile) {
+	if err := os.Remove(tf.Path); err != nil && !os.IsNotExist(err) {
+		storageLog.Warnf("⚠️  Failed to delete %s: %v", redact.Path(tf.Path), err)
+	}
+	if tf.OriginalPath != "" && tf.OriginalPath != tf.Path {
+		if err := os.Remove(tf.OriginalPath); err != nil && !os.IsNotExist(err) {
+			storageLog.Warnf("⚠️  Failed to delete %s: %v", redact.Path(tf.OriginalPath), err)
+		}
+	}
+}
+
 // Stop gracefully shuts down the storage
 func (ts *TempStorage) Stop() {
 	close(ts.stopCleanup)
-	log.Println("🛑 Temp storage stopped")
+	storageLog.Infof("🛑 Temp storage stopped")
 }
 
 // GetStats returns storage statistics
@@ -227,17 +387,40 @@ func GetFileExtension(mediaType string) string {
 		return ".jpg" // Will be adjusted based on input format
 	case "video":
 		return ".mp4"
+	case "document":
+		return ".pdf"
+	case "archive":
+		return ".zip"
 	default:
 		return ".bin"
 	}
 }
 
+// SetMediaDir places files of mediaType in dir instead of the base directory,
+// e.g. video on a large scratch volume. Call before serving requests
+func (ts *TempStorage) SetMediaDir(mediaType, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s temp directory: %w", mediaType, err)
+	}
+	ts.mediaDirs[mediaType] = dir
+	storageLog.Infof("📂 Temp dir for %s: %s", mediaType, dir)
+	return nil
+}
+
+// Dir returns the directory holding files of mediaType
+func (ts *TempStorage) Dir(mediaType string) string {
+	if dir, ok := ts.mediaDirs[mediaType]; ok {
+		return dir
+	}
+	return ts.baseDir
+}
+
 // GenerateTempPath creates a temporary file path
 func (ts *TempStorage) GenerateTempPath(mediaType string) string {
 	id := generateID()
 	ext := GetFileExtension(mediaType)
 	filename := fmt.Sprintf("%s%s", id[:12], ext)
-	return filepath.Join(ts.baseDir, filename)
+	return filepath.Join(ts.Dir(mediaType), filename)
 }
 
 // GenerateTempPathWithFormat creates a temporary file path with specific format
@@ -245,7 +428,7 @@ func (ts *TempStorage) GenerateTempPathWithFormat(mediaType string, format strin
 	id := generateID()
 	ext := getExtensionForFormat(format)
 	filename := fmt.Sprintf("%s%s", id[:12], ext)
-	return filepath.Join(ts.baseDir, filename)
+	return filepath.Join(ts.Dir(mediaType), filename)
 }
 
 // getExtensionForFormat returns extension for a specific format
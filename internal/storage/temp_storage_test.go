@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// storeTestFile writes size bytes and stores them for tenant
+func storeTestFile(t *testing.T, ts *TempStorage, name, mediaType, tenant string, size int) (string, string) {
+	t.Helper()
+	path := filepath.Join(ts.baseDir, name)
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	id, err := ts.StoreWithTTL(path, "", mediaType, tenant, time.Hour)
+	if err != nil {
+		t.Fatalf("StoreWithTTL: %v", err)
+	}
+	return id, path
+}
+
+func TestPurgeByFilter(t *testing.T) {
+	ts := NewTempStorage(t.TempDir(), time.Hour)
+	defer ts.Stop()
+
+	imageA, imageAPath := storeTestFile(t, ts, "a.jpg", "image", "team-a", 10)
+	videoA, _ := storeTestFile(t, ts, "a.mp4", "video", "team-a", 20)
+	imageB, _ := storeTestFile(t, ts, "b.jpg", "image", "team-b", 40)
+
+	if n, freed := ts.Purge(Filter{Tenant: "team-a", MediaType: "image"}, true); n != 1 || freed != 10 {
+		t.Errorf("dry run = %d files, %d bytes, want 1, 10", n, freed)
+	}
+	if _, err := ts.Get(imageA); err != nil {
+		t.Errorf("dry run deleted %s: %v", imageA, err)
+	}
+
+	if n, freed := ts.Purge(Filter{Tenant: "team-a"}, false); n != 2 || freed != 30 {
+		t.Errorf("purge = %d files, %d bytes, want 2, 30", n, freed)
+	}
+	for _, id := range []string{imageA, videoA} {
+		if _, err := ts.Get(id); err == nil {
+			t.Errorf("%s still stored after purge", id)
+		}
+	}
+	if _, err := os.Stat(imageAPath); !os.IsNotExist(err) {
+		t.Errorf("purged file still on disk: %v", err)
+	}
+	if _, err := ts.Get(imageB); err != nil {
+		t.Errorf("other tenant's file purged: %v", err)
+	}
+
+	if n, _ := ts.Purge(Filter{OlderThan: time.Hour}, false); n != 0 {
+		t.Errorf("older_than purge removed %d fresh files", n)
+	}
+}
+
+func TestListAndDelete(t *testing.T) {
+	ts := NewTempStorage(t.TempDir(), time.Hour)
+	defer ts.Stop()
+
+	first, _ := storeTestFile(t, ts, "1.png", "image", "", 1)
+	time.Sleep(time.Millisecond)
+	second, _ := storeTestFile(t, ts, "2.png", "image", "", 2)
+
+	files := ts.List(Filter{MediaType: "image"})
+	if len(files) != 2 || files[0].ID != second || files[1].ID != first {
+		t.Fatalf("List = %+v, want newest first", files)
+	}
+	if files := ts.List(Filter{MediaType: "audio"}); len(files) != 0 {
+		t.Errorf("List(audio) = %+v", files)
+	}
+
+	if !ts.Delete(first) {
+		t.Errorf("Delete(%s) = false", first)
+	}
+	if ts.Delete(first) {
+		t.Errorf("second Delete(%s) = true", first)
+	}
+	if files := ts.List(Filter{}); len(files) != 1 {
+		t.Errorf("List after delete = %+v", files)
+	}
+}
+
+func TestMediaDirs(t *testing.T) {
+	base := t.TempDir()
+	ts := NewTempStorage(base, time.Hour)
+	defer ts.Stop()
+
+	videoDir := filepath.Join(t.TempDir(), "scratch", "video")
+	if err := ts.SetMediaDir("video", videoDir); err != nil {
+		t.Fatalf("SetMediaDir: %v", err)
+	}
+	if _, err := os.Stat(videoDir); err != nil {
+		t.Errorf("video dir not created: %v", err)
+	}
+
+	if got := filepath.Dir(ts.GenerateTempPathWithFormat("video", "mp4")); got != videoDir {
+		t.Errorf("video path dir = %s, want %s", got, videoDir)
+	}
+	if got := filepath.Dir(ts.GenerateTempPath("image")); got != base {
+		t.Errorf("image path dir = %s, want %s", got, base)
+	}
+}
@@ -0,0 +1,90 @@
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MetadataDefaults holds the metadata fields merged into every output of a
+// tenant, set through the admin API and persisted so they survive restarts
+type MetadataDefaults struct {
+	mu     sync.RWMutex
+	fields map[string]map[string]string // By tenant name
+	path   string                       // Persistence file ("" keeps defaults in memory only)
+}
+
+// NewMetadataDefaults loads the defaults persisted at path
+func NewMetadataDefaults(path string) (*MetadataDefaults, error) {
+	d := &MetadataDefaults{fields: make(map[string]map[string]string), path: path}
+	if path == "" {
+		return d, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant metadata file: %w", err)
+	}
+	if err := json.Unmarshal(data, &d.fields); err != nil {
+		return nil, fmt.Errorf("invalid tenant metadata file: %w", err)
+	}
+	if d.fields == nil {
+		d.fields = make(map[string]map[string]string)
+	}
+	return d, nil
+}
+
+// Get returns a copy of the tenant's default fields (nil when none are set)
+func (d *MetadataDefaults) Get(tenant string) map[string]string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return maps.Clone(d.fields[tenant])
+}
+
+// Set replaces the tenant's default fields; no fields removes them
+func (d *MetadataDefaults) Set(tenant string, fields map[string]string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(fields) == 0 {
+		delete(d.fields, tenant)
+	} else {
+		d.fields[tenant] = maps.Clone(fields)
+	}
+	return d.persist()
+}
+
+// All returns a copy of every tenant's default fields
+func (d *MetadataDefaults) All() map[string]map[string]string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	all := make(map[string]map[string]string, len(d.fields))
+	for tenant, fields := range d.fields {
+		all[tenant] = maps.Clone(fields)
+	}
+	return all
+}
+
+// persist atomically writes all defaults to the metadata file; callers hold d.mu
+func (d *MetadataDefaults) persist() error {
+	if d.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(d.fields)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(d.path), 0755); err != nil {
+		return err
+	}
+	tmp := d.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.path)
+}
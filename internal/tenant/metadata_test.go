@@ -0,0 +1,41 @@
+package tenant
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMetadataDefaultsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenant-metadata.json")
+	d, err := NewMetadataDefaults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("marketing", map[string]string{"copyright": "Acme"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("support", map[string]string{"artist": "Help desk"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Callers get copies
+	d.Get("marketing")["copyright"] = "changed"
+
+	reloaded, err := NewMetadataDefaults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Get("marketing")["copyright"]; got != "Acme" {
+		t.Errorf("reloaded copyright = %q", got)
+	}
+	if len(reloaded.All()) != 2 {
+		t.Errorf("All = %v", reloaded.All())
+	}
+
+	if err := reloaded.Set("support", nil); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Get("support") != nil || len(reloaded.All()) != 1 {
+		t.Errorf("defaults not removed: %v", reloaded.All())
+	}
+}
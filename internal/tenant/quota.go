@@ -0,0 +1,216 @@
+package tenant
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Quota kinds reported in QuotaError and QuotaStatus
+const (
+	QuotaConversionsDay = "conversions_per_day"
+	QuotaBytesMonth     = "bytes_per_month"
+)
+
+// Limits are the per-tenant quotas; zero means unlimited
+type Limits struct {
+	ConversionsPerDay int64 `json:"conversions_per_day"`
+	BytesPerMonth     int64 `json:"bytes_per_month"`
+}
+
+// QuotaError reports an exhausted quota and when it resets
+type QuotaError struct {
+	Kind    string
+	Limit   int64
+	Used    int64
+	ResetAt time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota %s exceeded (%d/%d), resets at %s", e.Kind, e.Used, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+// QuotaStatus is a tenant's current limits and usage
+type QuotaStatus struct {
+	Tenant           string    `json:"tenant"`
+	Limits           Limits    `json:"limits"`
+	ConversionsToday int64     `json:"conversions_today"`
+	BytesThisMonth   int64     `json:"bytes_this_month"`
+	DayResetsAt      time.Time `json:"day_resets_at"`
+	MonthResetsAt    time.Time `json:"month_resets_at"`
+}
+
+// usage counts consumption within the current UTC day and month
+type usage struct {
+	day         time.Time // Start of the counted day
+	conversions int64
+	month       time.Time // Start of the counted month
+	bytes       int64
+}
+
+// Quotas enforces per-tenant conversion and byte quotas over UTC calendar
+// windows. Usage is kept in memory and starts over on restart
+type Quotas struct {
+	mu     sync.Mutex
+	limits map[string]Limits // By tenant name
+	usage  map[string]*usage
+	now    func() time.Time
+}
+
+// NewQuotas seeds limits from the configured tenants
+func NewQuotas(store *Store) *Quotas {
+	q := &Quotas{
+		limits: make(map[string]Limits),
+		usage:  make(map[string]*usage),
+		now:    time.Now,
+	}
+	for _, t := range store.All() {
+		q.limits[t.Name] = Limits{ConversionsPerDay: t.ConversionsPerDay, BytesPerMonth: t.BytesPerMonth}
+	}
+	return q
+}
+
+// Reservation is the quota held by one admitted conversion. It counts as
+// used at once, so concurrent requests cannot all pass the same check
+type Reservation struct {
+	q      *Quotas
+	tenant string
+	day    time.Time // Windows the reservation was counted in
+	month  time.Time
+	bytes  int64
+}
+
+// Admit reserves one conversion for tenant
+// Returns a *QuotaError when a quota is exhausted
+func (q *Quotas) Admit(tenant string) (*Reservation, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	limits := q.limits[tenant]
+	u := q.current(tenant)
+	if limits.ConversionsPerDay > 0 && u.conversions >= limits.ConversionsPerDay {
+		return nil, &QuotaError{Kind: QuotaConversionsDay, Limit: limits.ConversionsPerDay, Used: u.conversions, ResetAt: u.day.AddDate(0, 0, 1)}
+	}
+	if err := q.checkBytes(limits, u); err != nil {
+		return nil, err
+	}
+	u.conversions++
+	return &Reservation{q: q, tenant: tenant, day: u.day, month: u.month}, nil
+}
+
+// AddBytes counts n more bytes of the conversion once its size is known
+// Returns a *QuotaError, reserving nothing, when the byte quota is exhausted
+func (r *Reservation) AddBytes(n int64) error {
+	r.q.mu.Lock()
+	defer r.q.mu.Unlock()
+
+	u := r.q.current(r.tenant)
+	if err := r.q.checkBytes(r.q.limits[r.tenant], u); err != nil {
+		return err
+	}
+	u.bytes += n
+	if u.month.Equal(r.month) {
+		r.bytes += n
+	}
+	return nil
+}
+
+// Refund returns the conversion and bytes of a failed conversion. Usage of
+// windows that have ended since stays counted
+func (r *Reservation) Refund() {
+	r.q.mu.Lock()
+	defer r.q.mu.Unlock()
+
+	u := r.q.current(r.tenant)
+	if u.day.Equal(r.day) && u.conversions > 0 {
+		u.conversions--
+	}
+	if u.month.Equal(r.month) {
+		u.bytes = max(u.bytes-r.bytes, 0)
+	}
+	r.day, r.month, r.bytes = time.Time{}, time.Time{}, 0
+}
+
+// checkBytes reports an exhausted byte quota; callers hold q.mu
+func (q *Quotas) checkBytes(limits Limits, u *usage) error {
+	if limits.BytesPerMonth > 0 && u.bytes >= limits.BytesPerMonth {
+		return &QuotaError{Kind: QuotaBytesMonth, Limit: limits.BytesPerMonth, Used: u.bytes, ResetAt: u.month.AddDate(0, 1, 0)}
+	}
+	return nil
+}
+
+// SetLimits replaces the limits of a configured tenant at runtime
+func (q *Quotas) SetLimits(tenant string, limits Limits) error {
+	if limits.ConversionsPerDay < 0 || limits.BytesPerMonth < 0 {
+		return fmt.Errorf("limits must be positive")
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.limits[tenant]; !ok {
+		return fmt.Errorf("unknown tenant %q", tenant)
+	}
+	q.limits[tenant] = limits
+	return nil
+}
+
+// Status returns the limits and usage of tenant
+func (q *Quotas) Status(tenant string) (QuotaStatus, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	limits, ok := q.limits[tenant]
+	if !ok {
+		return QuotaStatus{}, false
+	}
+	u := q.current(tenant)
+	return QuotaStatus{
+		Tenant:           tenant,
+		Limits:           limits,
+		ConversionsToday: u.conversions,
+		BytesThisMonth:   u.bytes,
+		DayResetsAt:      u.day.AddDate(0, 0, 1),
+		MonthResetsAt:    u.month.AddDate(0, 1, 0),
+	}, true
+}
+
+// All returns the status of every tenant sorted by name
+func (q *Quotas) All() []QuotaStatus {
+	q.mu.Lock()
+	names := make([]string, 0, len(q.limits))
+	for name := range q.limits {
+		names = append(names, name)
+	}
+	q.mu.Unlock()
+	sort.Strings(names)
+
+	statuses := make([]QuotaStatus, 0, len(names))
+	for _, name := range names {
+		if status, ok := q.Status(name); ok {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// current returns tenant's usage, rolling windows that have ended; callers hold q.mu
+func (q *Quotas) current(tenant string) *usage {
+	now := q.now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	u, ok := q.usage[tenant]
+	if !ok {
+		u = &usage{day: day, month: month}
+		q.usage[tenant] = u
+	}
+	if !u.day.Equal(day) {
+		u.day, u.conversions = day, 0
+	}
+	if !u.month.Equal(month) {
+		u.month, u.bytes = month, 0
+	}
+	return u
+}
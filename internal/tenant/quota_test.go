@@ -0,0 +1,110 @@
+package tenant
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQuotasAdmitAndReset(t *testing.T) {
+	store, err := Parse([]byte(`{"tenants": [{"name": "a", "key": "k", "conversions_per_day": 2, "bytes_per_month": 1000}]}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	q := NewQuotas(store)
+	now := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		r, err := q.Admit("a")
+		if err != nil {
+			t.Fatalf("conversion %d rejected: %v", i+1, err)
+		}
+		if err := r.AddBytes(100); err != nil {
+			t.Fatalf("conversion %d bytes rejected: %v", i+1, err)
+		}
+	}
+
+	var quotaErr *QuotaError
+	if _, err := q.Admit("a"); !errors.As(err, &quotaErr) || quotaErr.Kind != QuotaConversionsDay {
+		t.Fatalf("third conversion: err = %v, want daily quota error", err)
+	}
+	if want := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC); !quotaErr.ResetAt.Equal(want) {
+		t.Errorf("ResetAt = %v, want %v", quotaErr.ResetAt, want)
+	}
+
+	// The next day (and month) starts over
+	now = now.Add(2 * time.Hour)
+	r, err := q.Admit("a")
+	if err != nil {
+		t.Fatalf("after reset: %v", err)
+	}
+	r.AddBytes(1000)
+	if _, err := q.Admit("a"); !errors.As(err, &quotaErr) || quotaErr.Kind != QuotaBytesMonth {
+		t.Errorf("err = %v, want monthly byte quota error", err)
+	}
+
+	// Raising the limit at runtime readmits the tenant
+	if err := q.SetLimits("a", Limits{ConversionsPerDay: 2, BytesPerMonth: 0}); err != nil {
+		t.Fatalf("SetLimits: %v", err)
+	}
+	if _, err := q.Admit("a"); err != nil {
+		t.Errorf("after raising limit: %v", err)
+	}
+	if err := q.SetLimits("missing", Limits{}); err == nil {
+		t.Error("SetLimits accepted an unknown tenant")
+	}
+}
+
+func TestQuotasReserveConcurrently(t *testing.T) {
+	store, err := Parse([]byte(`{"tenants": [{"name": "a", "key": "k", "conversions_per_day": 5}]}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	q := NewQuotas(store)
+
+	// Admissions count at once, so requests in flight cannot overshoot
+	var wg sync.WaitGroup
+	var admitted atomic.Int64
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := q.Admit("a"); err == nil {
+				admitted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if admitted.Load() != 5 {
+		t.Errorf("admitted %d conversions, want 5", admitted.Load())
+	}
+}
+
+func TestQuotasRefund(t *testing.T) {
+	store, err := Parse([]byte(`{"tenants": [{"name": "a", "key": "k", "conversions_per_day": 1, "bytes_per_month": 1000}]}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	q := NewQuotas(store)
+
+	r, err := q.Admit("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddBytes(1000)
+	if _, err := q.Admit("a"); err == nil {
+		t.Fatal("admitted past the limits")
+	}
+
+	// A failed conversion gives its quota back
+	r.Refund()
+	if status, _ := q.Status("a"); status.ConversionsToday != 0 || status.BytesThisMonth != 0 {
+		t.Errorf("after refund: %+v", status)
+	}
+	if _, err := q.Admit("a"); err != nil {
+		t.Errorf("after refund: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tenant holds the per-API-key policy that overrides the global configuration
+// Zero values mean "use the server default"
+type Tenant struct {
+	Name              string   `json:"name"`
+	Key               string   `json:"key"`
+	DefaultProfile    string   `json:"default_profile,omitempty"`     // Profile when the request names none
+	MaxFileSize       int64    `json:"max_file_size,omitempty"`       // Bytes; capped by MAX_DOWNLOAD_SIZE
+	AllowedMediaTypes []string `json:"allowed_media_types,omitempty"` // audio/image/video/document (all when empty)
+	FileTTLRaw        string   `json:"file_ttl,omitempty"`            // e.g. "30m"
+	ConversionsPerDay int64    `json:"conversions_per_day,omitempty"` // Quota per UTC day (unlimited when 0)
+	BytesPerMonth     int64    `json:"bytes_per_month,omitempty"`     // Downloaded bytes per UTC month (unlimited when 0)
+
+	FileTTL time.Duration `json:"-"` // Parsed FileTTLRaw
+}
+
+// AllowsMediaType reports whether the tenant may process mediaType
+func (t *Tenant) AllowsMediaType(mediaType string) bool {
+	if len(t.AllowedMediaTypes) == 0 {
+		return true
+	}
+	for _, allowed := range t.AllowedMediaTypes {
+		if strings.EqualFold(allowed, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Store maps API keys to tenants; it is read-only once loaded
+type Store struct {
+	byKey map[string]*Tenant
+}
+
+// storeFile is the on-disk format: {"tenants": [{...}, ...]}
+type storeFile struct {
+	Tenants []*Tenant `json:"tenants"`
+}
+
+// Load reads and validates a tenant store from a JSON file
+func Load(path string) (*Store, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse validates a tenant store from its JSON encoding
+func Parse(data []byte) (*Store, error) {
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid tenants file: %w", err)
+	}
+
+	s := &Store{byKey: make(map[string]*Tenant, len(file.Tenants))}
+	names := make(map[string]bool, len(file.Tenants))
+	for i, t := range file.Tenants {
+		if t.Name == "" {
+			t.Name = fmt.Sprintf("tenant-%d", i+1)
+		}
+		if t.Key == "" {
+			return nil, fmt.Errorf("tenant %s: key is required", t.Name)
+		}
+		if _, dup := s.byKey[t.Key]; dup {
+			return nil, fmt.Errorf("tenant %s: duplicate key", t.Name)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("tenant %s: duplicate name", t.Name)
+		}
+		names[t.Name] = true
+		if t.MaxFileSize < 0 || t.ConversionsPerDay < 0 || t.BytesPerMonth < 0 {
+			return nil, fmt.Errorf("tenant %s: sizes and quotas must be positive", t.Name)
+		}
+		if t.FileTTLRaw != "" {
+			ttl, err := time.ParseDuration(t.FileTTLRaw)
+			if err != nil || ttl <= 0 {
+				return nil, fmt.Errorf("tenant %s: invalid file_ttl %q", t.Name, t.FileTTLRaw)
+			}
+			t.FileTTL = ttl
+		}
+		s.byKey[t.Key] = t
+	}
+	return s, nil
+}
+
+// Lookup returns the tenant owning key
+func (s *Store) Lookup(key string) (*Tenant, bool) {
+	t, ok := s.byKey[key]
+	return t, ok
+}
+
+// ByName returns the tenant called name
+func (s *Store) ByName(name string) (*Tenant, bool) {
+	for _, t := range s.byKey {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// All returns every tenant sorted by name
+func (s *Store) All() []*Tenant {
+	tenants := make([]*Tenant, 0, len(s.byKey))
+	for _, t := range s.byKey {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].Name < tenants[j].Name })
+	return tenants
+}
@@ -0,0 +1,52 @@
+package tenant
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	store, err := Parse([]byte(`{"tenants": [
+		{"name": "marketing", "key": "k1", "default_profile": "paranoid", "file_ttl": "30m", "allowed_media_types": ["image", "Video"]},
+		{"key": "k2", "max_file_size": 1048576}
+	]}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	marketing, ok := store.Lookup("k1")
+	if !ok {
+		t.Fatal("k1 not found")
+	}
+	if marketing.FileTTL != 30*time.Minute || marketing.DefaultProfile != "paranoid" {
+		t.Errorf("marketing = %+v", marketing)
+	}
+	if !marketing.AllowsMediaType("video") || marketing.AllowsMediaType("audio") {
+		t.Errorf("allowed media types not enforced: %v", marketing.AllowedMediaTypes)
+	}
+
+	other, _ := store.Lookup("k2")
+	if other.Name != "tenant-2" || !other.AllowsMediaType("audio") {
+		t.Errorf("defaults not applied: %+v", other)
+	}
+	if _, ok := store.Lookup("missing"); ok {
+		t.Error("unknown key resolved")
+	}
+	if got := len(store.All()); got != 2 {
+		t.Errorf("All() = %d tenants, want 2", got)
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	for name, input := range map[string]string{
+		"missing key":   `{"tenants": [{"name": "a"}]}`,
+		"duplicate key": `{"tenants": [{"key": "x"}, {"key": "x"}]}`,
+		"bad ttl":       `{"tenants": [{"key": "x", "file_ttl": "soon"}]}`,
+		"negative size": `{"tenants": [{"key": "x", "max_file_size": -1}]}`,
+		"not json":      `tenants`,
+	} {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package upgrade
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// soReusePort is SO_REUSEPORT, which package syscall doesn't define on Linux
+const soReusePort = 0xf
+
+// setReusePort lets other sockets bind the same address while this one listens
+func setReusePort(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// bindToDevice restricts the socket to the network interface iface
+func bindToDevice(c syscall.RawConn, iface string) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface)
+	})
+	if err != nil {
+		return err
+	}
+	if sockErr != nil {
+		return fmt.Errorf("failed to bind to interface %s: %w", iface, sockErr)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package upgrade
+
+import (
+	"errors"
+	"syscall"
+)
+
+// setReusePort is not implemented on this platform
+func setReusePort(network, address string, c syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is only supported on Linux")
+}
+
+// bindToDevice is not implemented on this platform
+func bindToDevice(c syscall.RawConn, iface string) error {
+	return errors.New("binding to an interface is only supported on Linux")
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package upgrade
+
+import "os"
+
+// Notify does nothing: there is no upgrade signal on this platform
+func Notify(c chan<- os.Signal) {}
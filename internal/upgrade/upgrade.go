@@ -0,0 +1,220 @@
+// Package upgrade replaces the running binary without closing the listening
+// socket. The new process inherits the listener, warms up, then takes over
+// persisted state while the old process drains in-flight requests and exits
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Inherited descriptors are passed as ExtraFiles, so they start at fd 3
+const (
+	envListenFD  = "UPGRADE_LISTEN_FD"
+	envReadyFD   = "UPGRADE_READY_FD"
+	envReleaseFD = "UPGRADE_RELEASE_FD"
+)
+
+// released stays open until this process exits; the new process reads EOF
+// from it once the last in-flight request is done. Kept here so the
+// finalizer of an unreferenced *os.File can't close it early
+var released *os.File
+
+// Inherited reports whether this process was started by Upgrade
+func Inherited() bool {
+	return os.Getenv(envListenFD) != ""
+}
+
+// ListenOptions are socket options of a new listener
+type ListenOptions struct {
+	// SO_REUSEPORT so independently started copies can share the port,
+	// e.g. a blue/green deploy on a single host
+	ReusePort bool
+	// Accept only connections arriving on this network interface, over
+	// either address family (Linux)
+	Interface string
+}
+
+// Listen returns the listener inherited from the previous process, or a new
+// one on addr with opts
+func Listen(network, addr string, opts ListenOptions) (net.Listener, error) {
+	if Inherited() {
+		f, err := inheritedFile(envListenFD)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		ln, err := net.FileListener(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to use inherited listener: %w", err)
+		}
+		os.Unsetenv(envListenFD)
+		return ln, nil
+	}
+
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			if opts.ReusePort {
+				if err := setReusePort(network, address, c); err != nil {
+					return err
+				}
+			}
+			if opts.Interface != "" {
+				return bindToDevice(c, opts.Interface)
+			}
+			return nil
+		},
+	}
+	return lc.Listen(context.Background(), network, addr)
+}
+
+// Upgrade starts the current executable with ln inherited and waits until
+// it calls TakeOver. It then runs release, which must stop background work
+// writing persisted state, and lets the new process load that state. On
+// error before release the new process is killed and nothing has changed
+func Upgrade(ln net.Listener, timeout time.Duration, release func()) error {
+	filer, ok := ln.(interface{ File() (*os.File, error) })
+	if !ok {
+		return fmt.Errorf("listener %T cannot be inherited", ln)
+	}
+	lnFile, err := filer.File()
+	if err != nil {
+		return fmt.Errorf("failed to duplicate listener: %w", err)
+	}
+	defer lnFile.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+	releaseR, releaseW, err := os.Pipe()
+	if err != nil {
+		readyW.Close()
+		return err
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(withoutUpgradeEnv(os.Environ()), envListenFD+"=3", envReadyFD+"=4", envReleaseFD+"=5")
+	cmd.ExtraFiles = []*os.File{lnFile, readyW, releaseR}
+	err = cmd.Start()
+	readyW.Close()
+	releaseR.Close()
+	if err != nil {
+		releaseW.Close()
+		return fmt.Errorf("failed to start new process: %w", err)
+	}
+
+	if err := waitByte(readyR, timeout); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		releaseW.Close()
+		return fmt.Errorf("new process did not take over: %w", err)
+	}
+
+	release()
+	if _, err := releaseW.Write([]byte{1}); err != nil {
+		releaseW.Close()
+		return fmt.Errorf("new process exited during handoff: %w", err)
+	}
+	released = releaseW
+	cmd.Process.Release()
+	return nil
+}
+
+// TakeOver is called by a process started by Upgrade once it is warmed up
+// and about to load persisted state. It asks the previous process to
+// release that state and waits until it has. The returned channel is closed
+// when the previous process has exited; it is nil without a previous process
+func TakeOver(timeout time.Duration) (<-chan struct{}, error) {
+	if os.Getenv(envReadyFD) == "" {
+		return nil, nil
+	}
+	defer os.Unsetenv(envReadyFD)
+	defer os.Unsetenv(envReleaseFD)
+
+	ready, err := inheritedFile(envReadyFD)
+	if err != nil {
+		return nil, err
+	}
+	_, err = ready.Write([]byte{1})
+	ready.Close()
+	if err != nil {
+		return nil, fmt.Errorf("previous process is gone: %w", err)
+	}
+
+	release, err := inheritedFile(envReleaseFD)
+	if err != nil {
+		return nil, err
+	}
+	if err := waitByte(release, timeout); err != nil {
+		release.Close()
+		return nil, fmt.Errorf("previous process did not release state: %w", err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, release)
+		release.Close()
+		close(exited)
+	}()
+	return exited, nil
+}
+
+// waitByte reads one byte from f; EOF means the other side exited first
+// Inherited pipes are blocking, so the timeout can't use read deadlines
+func waitByte(f *os.File, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := f.Read(make([]byte, 1))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if errors.Is(err, io.EOF) {
+			return errors.New("other process exited")
+		}
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
+
+// inheritedFile opens the descriptor named by the environment variable env
+func inheritedFile(env string) (*os.File, error) {
+	fd, err := strconv.Atoi(os.Getenv(env))
+	if err != nil || fd < 3 {
+		return nil, fmt.Errorf("invalid %s %q", env, os.Getenv(env))
+	}
+	return os.NewFile(uintptr(fd), env), nil
+}
+
+// withoutUpgradeEnv drops descriptors inherited by this process, which the
+// next one must not see
+func withoutUpgradeEnv(env []string) []string {
+	out := make([]string, 0, len(env))
+	for _, kv := range env {
+		switch name, _, _ := strings.Cut(kv, "="); name {
+		case envListenFD, envReadyFD, envReleaseFD:
+		default:
+			out = append(out, kv)
+		}
+	}
+	return out
+}
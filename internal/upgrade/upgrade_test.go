@@ -0,0 +1,103 @@
+package upgrade
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestMain runs the new process side when Upgrade re-executes the test binary
+func TestMain(m *testing.M) {
+	if Inherited() {
+		os.Exit(serveOnce())
+	}
+	os.Exit(m.Run())
+}
+
+// serveOnce takes over the inherited listener and answers one connection
+func serveOnce() int {
+	ln, err := Listen("tcp4", "", ListenOptions{})
+	if err != nil {
+		return 1
+	}
+	if _, err := TakeOver(5 * time.Second); err != nil {
+		return 1
+	}
+	conn, err := ln.Accept()
+	if err != nil {
+		return 1
+	}
+	conn.Write([]byte("new process\n"))
+	conn.Close()
+	return 0
+}
+
+func TestUpgradeHandsOverListener(t *testing.T) {
+	ln, err := Listen("tcp4", "127.0.0.1:0", ListenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	released := false
+	if err := Upgrade(ln, 10*time.Second, func() { released = true }); err != nil {
+		t.Fatalf("Upgrade: %v", err)
+	}
+	if !released {
+		t.Error("release was not called")
+	}
+
+	// The old process stops accepting; the socket stays open in the new one
+	addr := ln.Addr().String()
+	ln.Close()
+
+	conn, err := net.DialTimeout("tcp4", addr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("dial after handoff: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "new process\n" {
+		t.Errorf("response = %q, %v", line, err)
+	}
+}
+
+func TestTakeOverWithoutPrevious(t *testing.T) {
+	exited, err := TakeOver(time.Second)
+	if exited != nil || err != nil {
+		t.Errorf("TakeOver = %v, %v, want nil, nil", exited, err)
+	}
+}
+
+func TestListenReusePort(t *testing.T) {
+	first, err := Listen("tcp4", "127.0.0.1:0", ListenOptions{ReusePort: true})
+	if err != nil {
+		t.Skipf("SO_REUSEPORT unavailable: %v", err)
+	}
+	defer first.Close()
+
+	second, err := Listen("tcp4", first.Addr().String(), ListenOptions{ReusePort: true})
+	if err != nil {
+		t.Fatalf("second listener on %s: %v", first.Addr(), err)
+	}
+	second.Close()
+}
+
+func TestListenInterface(t *testing.T) {
+	ln, err := Listen("tcp", "127.0.0.1:0", ListenOptions{Interface: "lo"})
+	if err != nil {
+		t.Skipf("binding to an interface unavailable: %v", err)
+	}
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial through lo: %v", err)
+	}
+	conn.Close()
+
+	if _, err := Listen("tcp", "127.0.0.1:0", ListenOptions{Interface: "no-such-interface0"}); err == nil {
+		t.Error("listened on an unknown interface")
+	}
+}